@@ -0,0 +1,168 @@
+// Package api defines the JSON documents that shed prints or writes for other programs to consume,
+// such as the output of 'shed list --format=json'. Tools can unmarshal the documents into these types
+// instead of defining their own.
+//
+// Every document has a schemaVersion field, set to SchemaVersion. The following rules apply
+// to the documents for a given schema version:
+//
+//   - Fields may be added, but are never removed or renamed.
+//   - The type and meaning of a field never changes.
+//   - Fields marked as omitted when empty may be missing.
+//
+// Consumers must ignore fields they don't know about. A change that breaks these rules
+// increases SchemaVersion, and consumers should check the version before using a document.
+package api
+
+import "time"
+
+// SchemaVersion is the version of the documents defined in this package.
+// It is shared by all documents, so it increases if any of them changes in a breaking way.
+const SchemaVersion = 1
+
+// ToolList is printed by 'shed list --format=json'.
+type ToolList struct {
+	SchemaVersion int `json:"schemaVersion"`
+	// Tools are the tools in the lockfile sorted by import path. It is never null.
+	Tools []Tool `json:"tools"`
+}
+
+// Tool is a tool in a ToolList.
+type Tool struct {
+	// Name is the name of the tool, used with commands like 'shed run'.
+	Name string `json:"name"`
+	// ImportPath is the import path of the tool.
+	ImportPath string `json:"importPath"`
+	// Version is the version of the tool.
+	Version string `json:"version"`
+	// Module is the module that provides the tool, if known.
+	Module string `json:"module,omitempty"`
+	// Sum is the hash of the module in the format used by go.sum, if known.
+	Sum string `json:"sum,omitempty"`
+	// Path is the path to the binary of the tool, whether or not it is installed.
+	// It is omitted when import paths are redacted, since it contains the import path.
+	Path string `json:"path,omitempty"`
+	// Installed reports whether the binary of the tool exists.
+	Installed bool `json:"installed"`
+	// Stale reports whether the installed files don't match their hashes, see 'shed verify'.
+	Stale bool `json:"stale"`
+	// Groups are the groups the tool belongs to, if any.
+	Groups []string `json:"groups,omitempty"`
+	// Why is the note saved for the tool with 'shed install --why', if any.
+	Why string `json:"why,omitempty"`
+	// Branch is the branch the tool tracks if it was installed from a branch, ex: 'master'.
+	// Version is the pseudo-version the branch resolved to.
+	Branch string `json:"branch,omitempty"`
+	// Provenance describes how the installed binary was built. It is omitted if the tool
+	// isn't installed or no provenance was recorded, ex: for release tools.
+	Provenance *Provenance `json:"provenance,omitempty"`
+	// ReportedVersion is the first line the tool printed when it was asked for its version after it
+	// was installed. It is omitted if the tool has no version probe or wasn't probed at its version.
+	ReportedVersion string `json:"reportedVersion,omitempty"`
+}
+
+// LockfileDiff is printed by 'shed diff --format=json'.
+type LockfileDiff struct {
+	SchemaVersion int `json:"schemaVersion"`
+	// Changes are the tools that differ between the lockfiles, sorted by import path. It is never null.
+	Changes []ToolChange `json:"changes"`
+}
+
+// ToolChange is a tool in a LockfileDiff.
+type ToolChange struct {
+	// Change is how the tool changed, one of 'added', 'removed', or 'changed'.
+	Change string `json:"change"`
+	// ImportPath is the import path of the tool.
+	ImportPath string `json:"importPath"`
+	// OldVersion is the version in the old lockfile. It is omitted for added tools.
+	OldVersion string `json:"oldVersion,omitempty"`
+	// NewVersion is the version in the new lockfile. It is omitted for removed tools.
+	NewVersion string `json:"newVersion,omitempty"`
+	// Fields are the fields of a changed tool that differ, ex: 'version' or 'sum'.
+	Fields []string `json:"fields,omitempty"`
+}
+
+// InstallSummary is written by 'shed install --summary-file' and 'shed update --summary-file',
+// ex: for bots to post the changes on the pull request of an update.
+type InstallSummary struct {
+	SchemaVersion int `json:"schemaVersion"`
+	// Changes are the tools whose versions changed, sorted by import path. It is never null.
+	Changes []VersionChange `json:"changes"`
+}
+
+// VersionChange is a tool in an InstallSummary.
+type VersionChange struct {
+	// Change is how the tool changed, one of 'added', 'removed', or 'changed'.
+	Change string `json:"change"`
+	// ImportPath is the import path of the tool.
+	ImportPath string `json:"importPath"`
+	// OldVersion is the version before the install. It is omitted for added tools.
+	OldVersion string `json:"oldVersion,omitempty"`
+	// NewVersion is the version after the install. It is omitted for removed tools.
+	NewVersion string `json:"newVersion,omitempty"`
+	// ReleaseURL is the GitHub release of the new version. It is omitted if it can't be derived
+	// from the module of the tool, ex: if the module isn't hosted on GitHub.
+	ReleaseURL string `json:"releaseURL,omitempty"`
+	// CompareURL is the GitHub page listing the commits between the old and new versions.
+	// It is only set for changed tools whose module is hosted on GitHub.
+	CompareURL string `json:"compareURL,omitempty"`
+}
+
+// Provenance describes how the binary of a tool was built, see Tool.
+type Provenance struct {
+	// GoVersion is the version of the go command used to build the tool, ex: 'go1.21.0'.
+	GoVersion string `json:"goVersion"`
+	// GOOS and GOARCH are the platform the tool was built for.
+	GOOS   string `json:"goos"`
+	GOARCH string `json:"goarch"`
+	// Revision is the VCS revision of the module of the tool, if known.
+	Revision string `json:"revision,omitempty"`
+	// BuildFlags are the flags the tool was built with, if any.
+	BuildFlags []string `json:"buildFlags,omitempty"`
+	// Time is when the tool was built.
+	Time time.Time `json:"time"`
+}
+
+// Env is printed by 'shed env --json'. Each field is named after the variable printed by 'shed env'.
+// Fields are empty if they don't apply, ex: LockfilePath outside of a project.
+type Env struct {
+	SchemaVersion  int    `json:"schemaVersion"`
+	CacheDir       string `json:"SHED_CACHE_DIR"`
+	ConfigDir      string `json:"SHED_CONFIG_DIR"`
+	ConfigFile     string `json:"SHED_CONFIG_FILE"`
+	StateDir       string `json:"SHED_STATE_DIR"`
+	BinDir         string `json:"SHED_BIN_DIR"`
+	Lockfile       string `json:"SHED_LOCKFILE"`
+	GlobalLockfile string `json:"SHED_GLOBAL_LOCKFILE"`
+	ProjectDir     string `json:"SHED_PROJECT_DIR"`
+	ShimsDir       string `json:"SHED_SHIMS_DIR"`
+	Context        string `json:"SHED_CONTEXT"`
+}
+
+// CaptureManifest is written alongside the output of a tool captured with 'shed run --capture'.
+// Import paths are redacted according to the redaction policy of the project.
+type CaptureManifest struct {
+	SchemaVersion int `json:"schemaVersion"`
+	// Tool is the import path of the tool.
+	Tool string `json:"tool"`
+	// Version is the version of the tool.
+	Version string `json:"version"`
+	// Args are the arguments the tool was run with.
+	Args []string `json:"args"`
+	// StartTime is when the tool was first run.
+	StartTime time.Time `json:"startTime"`
+	// Stdout is the name of the file containing stdout, in the same directory as the manifest.
+	Stdout string `json:"stdout"`
+	// Stderr is the name of the file containing stderr, in the same directory as the manifest.
+	Stderr string `json:"stderr"`
+	// Attempts are the attempts to run the tool in order, there is more than one if it was retried.
+	Attempts []CaptureAttempt `json:"attempts"`
+}
+
+// CaptureAttempt is an attempt to run a tool in a CaptureManifest.
+type CaptureAttempt struct {
+	ExitCode int `json:"exitCode"`
+	// Duration is formatted like time.Duration.String, ex: 1.5s.
+	Duration string `json:"duration"`
+	// Error is the error that stopped the tool from running, if any.
+	Error string `json:"error,omitempty"`
+}
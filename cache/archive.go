@@ -0,0 +1,253 @@
+package cache
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/getshiphub/shed/internal/util"
+	"github.com/pkg/errors"
+)
+
+// binaryName returns the name of the binary in a tool directory, given the escaped name of the tool.
+// Binaries use the unescaped name, see tool.Tool.BinaryFilepath.
+func binaryName(escaped string) string {
+	var sb strings.Builder
+	bang := false
+	for _, r := range escaped {
+		switch {
+		case bang:
+			sb.WriteRune(unicode.ToUpper(r))
+			bang = false
+		case r == '!':
+			bang = true
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// toolDirs returns the directories of the tools that have been built in the tools directory root.
+// Tool directories are named 'NAME@VERSION', directories that are missing the binary of their current
+// build are skipped since they are leftovers from a failed install.
+func toolDirs(root string) ([]string, error) {
+	var dirs []string
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && p == root {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		i := strings.LastIndexByte(info.Name(), '@')
+		if i == -1 {
+			return nil
+		}
+		if _, err := toolBinary(p); err == nil {
+			dirs = append(dirs, p)
+		}
+		return filepath.SkipDir
+	})
+	return dirs, err
+}
+
+// toolBinary returns the path of the binary of the current build of the tool directory dir.
+// An error is returned if it doesn't exist.
+func toolBinary(dir string) (string, error) {
+	bdir, err := buildDir(dir)
+	if err != nil {
+		return "", err
+	}
+	base := filepath.Base(dir)
+	name := binaryName(base[:strings.LastIndexByte(base, '@')])
+	// Tools built for windows have a '.exe' extension
+	for _, n := range []string{name, name + ".exe"} {
+		if p := filepath.Join(bdir, n); util.FileOrDirExists(p) {
+			return p, nil
+		}
+	}
+	return "", errors.Errorf("cache: no binary found in %q", bdir)
+}
+
+// Export writes a gzipped tar archive of all the tools that have been built to w.
+// The archive can be imported into another cache with Import, which allows a cache
+// to be seeded with prebuilt tools. Export returns the number of tools exported.
+func (c *Cache) Export(w io.Writer) (int, error) {
+	root := c.toolsDir()
+	dirs, err := toolDirs(root)
+	if err != nil {
+		return 0, errors.Wrapf(err, "cache: failed to find tools in %q", root)
+	}
+	if err := exportDirs(w, root, dirs); err != nil {
+		return 0, err
+	}
+	return len(dirs), nil
+}
+
+// exportDirs writes a gzipped tar archive of the tool directories dirs to w.
+// Paths in the archive are relative to root, the tools directory.
+func exportDirs(w io.Writer, root string, dirs []string) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			// Builds that aren't finished aren't part of the tool
+			if info.IsDir() && strings.HasPrefix(info.Name(), stagePrefix) {
+				return filepath.SkipDir
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			hdr := &tar.Header{
+				Name: filepath.ToSlash(rel),
+				Mode: int64(info.Mode().Perm()),
+				Size: info.Size(),
+				// Use a fixed time so exports of the same tools are identical
+				ModTime:  time.Unix(0, 0),
+				Typeflag: tar.TypeReg,
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(tw, f)
+			return err
+		})
+		if err != nil {
+			return errors.Wrapf(err, "cache: failed to export %q", dir)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, "cache: failed to export tools")
+	}
+	if err := gw.Close(); err != nil {
+		return errors.Wrap(err, "cache: failed to export tools")
+	}
+	return nil
+}
+
+// Import reads an archive created by Export from r and adds the tools it contains to the cache.
+// Tools that are already in the cache are left as is. Import returns the number of tools imported.
+// ctx is used to stop waiting for other shed processes that are removing tools from the cache.
+func (c *Cache) Import(ctx context.Context, r io.Reader) (int, error) {
+	unlock, err := c.lock(ctx, cacheLockFile, true)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	if err := c.checkLayout(); err != nil {
+		return 0, err
+	}
+	return c.importArchive(r, "")
+}
+
+// importArchive adds the tools in the archive created by exportDirs read from r to the cache.
+// If only is not empty, it is the path of the only tool directory, relative to the tools directory,
+// that is imported. The caller must hold the cache lock.
+func (c *Cache) importArchive(r io.Reader, only string) (int, error) {
+	// Extract to a temp dir first so that partially imported tools never end up in the cache
+	tmpDir, err := ioutil.TempDir(c.rootDir, "tmp-")
+	if err != nil {
+		return 0, errors.Wrap(err, "cache: failed to create temp directory")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, errors.Wrap(err, "cache: invalid archive")
+	}
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, errors.Wrap(err, "cache: invalid archive")
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		// The archive may come from an untrusted source so make sure
+		// it can't write outside of the cache
+		name := path.Clean(hdr.Name)
+		if !util.IsLocalPath(name) {
+			return 0, errors.Errorf("cache: archive contains invalid path %q", hdr.Name)
+		}
+		p := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), dirPerm); err != nil {
+			return 0, errors.Wrapf(err, "cache: failed to create directory for %q", p)
+		}
+		// Make sure the file is writable by the owner, published tools are read-only
+		f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(hdr.Mode).Perm()|0o200)
+		if err != nil {
+			return 0, errors.Wrapf(err, "cache: failed to create %q", p)
+		}
+		if _, err := io.Copy(f, tr); err != nil {
+			f.Close()
+			return 0, errors.Wrapf(err, "cache: failed to write %q", p)
+		}
+		if err := f.Close(); err != nil {
+			return 0, errors.Wrapf(err, "cache: failed to write %q", p)
+		}
+	}
+
+	dirs, err := toolDirs(tmpDir)
+	if err != nil {
+		return 0, errors.Wrap(err, "cache: failed to find tools in archive")
+	}
+	imported := 0
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(tmpDir, dir)
+		if err != nil {
+			return imported, errors.Wrapf(err, "cache: failed to import %q", dir)
+		}
+		if only != "" && rel != only {
+			c.logger.WithField("path", rel).Debug("unexpected tool in archive, skipping import")
+			continue
+		}
+		dst := filepath.Join(c.toolsDir(), rel)
+		if util.FileOrDirExists(dst) {
+			c.logger.WithField("path", dst).Debug("tool already exists, skipping import")
+			continue
+		}
+		if err := c.mkdirAll(filepath.Dir(dst)); err != nil {
+			return imported, errors.Wrapf(err, "cache: failed to create directory %q", filepath.Dir(dst))
+		}
+		if err := os.Rename(dir, dst); err != nil {
+			return imported, errors.Wrapf(err, "cache: failed to import %q", rel)
+		}
+		binPath, err := toolBinary(dst)
+		if err != nil {
+			return imported, err
+		}
+		if err := c.publish(filepath.Dir(binPath), binPath); err != nil {
+			return imported, errors.Wrapf(err, "cache: failed to import %q", rel)
+		}
+		imported++
+	}
+	return imported, nil
+}
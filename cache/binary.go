@@ -0,0 +1,187 @@
+package cache
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// CheckBinary checks that the binary of t in the cache can be run. It must exist, match the hash recorded
+// when it was built, be executable, and be an executable for the platform of t, see tool.Tool.Platform.
+// Only ELF, Mach-O, and PE executables are checked for their platform, other files like scripts are assumed to be
+// for the right one. The returned problems describe what is wrong with the binary, none means it is fine.
+// Reinstall broken tools with Install and InstallRebuild.
+func (c *Cache) CheckBinary(t tool.Tool) ([]Problem, error) {
+	binPath, err := c.BinaryPath(t)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(binPath)
+	if os.IsNotExist(err) {
+		return []Problem{{Path: binPath, Issue: "binary does not exist"}}, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "cache: failed to stat binary %q", binPath)
+	}
+	if !info.Mode().IsRegular() {
+		return []Problem{{Path: binPath, Issue: "binary is not a regular file"}}, nil
+	}
+
+	var problems []Problem
+	add := func(issue string) {
+		problems = append(problems, Problem{Path: binPath, Issue: issue})
+	}
+	if runtime.GOOS != "windows" && info.Mode()&0o111 == 0 {
+		add("binary is not executable")
+	}
+	want, err := readBinarySum(filepath.Dir(binPath))
+	if err != nil {
+		return nil, err
+	}
+	if want != "" {
+		got, err := hashFile(binPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cache: failed to hash binary %q", binPath)
+		}
+		if got != want {
+			add(fmt.Sprintf("binary has hash %s, want %s", got, want))
+		}
+	}
+
+	goos, goarch := runtime.GOOS, runtime.GOARCH
+	if t.Platform != "" {
+		if goos, goarch, err = tool.ParsePlatform(t.Platform); err != nil {
+			return nil, err
+		}
+	}
+	issue, err := checkExecutable(binPath, goos, goarch)
+	if err != nil {
+		return nil, err
+	}
+	if issue != "" {
+		add(issue)
+	}
+	return problems, nil
+}
+
+// Magic numbers at the start of executable files.
+var (
+	elfMagic   = []byte("\x7fELF")
+	peMagic    = []byte("MZ")
+	machoMagic = [][]byte{
+		{0xfe, 0xed, 0xfa, 0xce}, {0xfe, 0xed, 0xfa, 0xcf}, {0xce, 0xfa, 0xed, 0xfe}, {0xcf, 0xfa, 0xed, 0xfe},
+		// Universal binaries, which contain an executable for each architecture
+		{0xca, 0xfe, 0xba, 0xbe},
+	}
+)
+
+// Architectures of each executable format, by GOARCH. Architectures that aren't listed aren't checked.
+var (
+	elfArchs = map[elf.Machine]string{
+		elf.EM_386: "386", elf.EM_X86_64: "amd64", elf.EM_ARM: "arm", elf.EM_AARCH64: "arm64",
+		elf.EM_PPC64: "ppc64", elf.EM_S390: "s390x", elf.EM_RISCV: "riscv64",
+	}
+	machoArchs = map[macho.Cpu]string{
+		macho.Cpu386: "386", macho.CpuAmd64: "amd64", macho.CpuArm: "arm", macho.CpuArm64: "arm64",
+	}
+	peArchs = map[uint16]string{
+		pe.IMAGE_FILE_MACHINE_I386: "386", pe.IMAGE_FILE_MACHINE_AMD64: "amd64",
+		pe.IMAGE_FILE_MACHINE_ARMNT: "arm", pe.IMAGE_FILE_MACHINE_ARM64: "arm64",
+	}
+)
+
+// checkExecutable checks that the file at p is an executable for goos and goarch. It returns a description
+// of the problem, or an empty string if there is none or the file isn't in a known executable format.
+func checkExecutable(p, goos, goarch string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", errors.Wrapf(err, "cache: failed to open binary %q", p)
+	}
+	defer f.Close()
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(f, header); err == io.EOF || err == io.ErrUnexpectedEOF {
+		return "", nil
+	} else if err != nil {
+		return "", errors.Wrapf(err, "cache: failed to read binary %q", p)
+	}
+
+	var format, arch string
+	switch {
+	case bytes.HasPrefix(header, elfMagic):
+		format = "ELF"
+		ef, err := elf.NewFile(f)
+		if err != nil {
+			return "binary is corrupt: " + err.Error(), nil
+		}
+		arch = elfArchs[ef.Machine]
+		if ef.Machine == elf.EM_PPC64 && ef.Data == elf.ELFDATA2LSB {
+			arch = "ppc64le"
+		}
+	case bytes.HasPrefix(header, peMagic):
+		format = "PE"
+		pf, err := pe.NewFile(f)
+		if err != nil {
+			return "binary is corrupt: " + err.Error(), nil
+		}
+		arch = peArchs[pf.Machine]
+	case containsPrefix(machoMagic, header):
+		format = "Mach-O"
+		if bytes.Equal(header, machoMagic[len(machoMagic)-1]) {
+			ff, err := macho.NewFatFile(f)
+			if err != nil {
+				return "binary is corrupt: " + err.Error(), nil
+			}
+			for _, a := range ff.Arches {
+				if arch = machoArchs[a.Cpu]; arch == goarch {
+					break
+				}
+			}
+		} else {
+			mf, err := macho.NewFile(f)
+			if err != nil {
+				return "binary is corrupt: " + err.Error(), nil
+			}
+			arch = machoArchs[mf.Cpu]
+		}
+	default:
+		return "", nil
+	}
+
+	var want string
+	switch goos {
+	case "windows":
+		want = "PE"
+	case "darwin", "ios":
+		want = "Mach-O"
+	case "aix", "plan9", "js", "wasip1":
+		// Not executables in a format that is checked
+		return "", nil
+	default:
+		want = "ELF"
+	}
+	if format != want {
+		return fmt.Sprintf("binary is a %s executable, want %s for %s", format, want, tool.Platform(goos, goarch)), nil
+	}
+	if arch != "" && arch != goarch {
+		return fmt.Sprintf("binary is for %s, want %s", arch, goarch), nil
+	}
+	return "", nil
+}
+
+// containsPrefix reports whether b starts with any of prefixes.
+func containsPrefix(prefixes [][]byte, b []byte) bool {
+	for _, p := range prefixes {
+		if bytes.HasPrefix(b, p) {
+			return true
+		}
+	}
+	return false
+}
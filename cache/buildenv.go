@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// ErrBuildEnvMissing is returned by Install when the environment is missing something that a tool needs to be built,
+// ex: a C compiler for a tool that needs cgo.
+var ErrBuildEnvMissing = errors.New("cache: build environment is missing requirements")
+
+// BuildEnvError describes what a tool needs in its build environment that is missing.
+// It matches ErrBuildEnvMissing when using errors.Is.
+type BuildEnvError struct {
+	// Tool is the tool that can't be built.
+	Tool tool.Tool
+	// Var is the environment variable that the requirement comes from, ex: 'CC'.
+	Var string
+	// Value is the value of Var, ex: 'clang'.
+	Value string
+	// Reason describes what is missing, ex: 'C compiler clang not found'.
+	Reason string
+}
+
+func (e *BuildEnvError) Error() string {
+	return fmt.Sprintf("%v: %s needs %s=%s, but %s", ErrBuildEnvMissing, e.Tool, e.Var, e.Value, e.Reason)
+}
+
+func (e *BuildEnvError) Unwrap() error {
+	return ErrBuildEnvMissing
+}
+
+// compilerEnv are the environment variables of the go command that name a compiler used by cgo.
+var compilerEnv = []struct {
+	name string
+	kind string
+}{
+	{"CC", "C compiler"},
+	{"CXX", "C++ compiler"},
+}
+
+// checkBuildEnv returns a *BuildEnvError if the environment of the go command, env, doesn't have what t
+// needs to be built, based on the build environment of t. The compilers it sets, and the C compiler if
+// t needs cgo, must be found in PATH. Tools without a build environment aren't checked, since the go
+// command reports what is missing as well as it can.
+func (c *Cache) checkBuildEnv(ctx context.Context, t tool.Tool, env []string) error {
+	toolEnv := make(map[string]string)
+	for _, kv := range t.BuildFlags.EnvList() {
+		i := strings.IndexByte(kv, '=')
+		toolEnv[kv[:i]] = kv[i+1:]
+	}
+	if len(toolEnv) == 0 {
+		return nil
+	}
+	for _, ce := range compilerEnv {
+		v, ok := toolEnv[ce.name]
+		if !ok {
+			continue
+		}
+		if err := lookCompiler(t, ce.name, v, v, ce.kind); err != nil {
+			return err
+		}
+	}
+	if _, ok := toolEnv["CC"]; ok || toolEnv["CGO_ENABLED"] != "1" {
+		return nil
+	}
+	// The go command has a default C compiler, which may not be installed
+	values, err := c.goClient.Env(ctx, env, "CC")
+	if err != nil {
+		return errors.WithMessage(err, "cache: failed to get C compiler of go")
+	}
+	if values[0] == "" {
+		return nil
+	}
+	return lookCompiler(t, "CGO_ENABLED", "1", values[0], "C compiler")
+}
+
+// lookCompiler returns a *BuildEnvError for the variable name with value, which t needs, if compiler can't be found.
+// compiler is a command with optional arguments, ex: 'zig cc', like the go command accepts for CC.
+func lookCompiler(t tool.Tool, name, value, compiler, kind string) error {
+	fields := strings.Fields(compiler)
+	if len(fields) == 0 {
+		return &BuildEnvError{Tool: t, Var: name, Value: value, Reason: fmt.Sprintf("%s is empty", kind)}
+	}
+	if _, err := exec.LookPath(fields[0]); err != nil {
+		return &BuildEnvError{Tool: t, Var: name, Value: value, Reason: fmt.Sprintf("%s %s was not found", kind, fields[0])}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// buildTimesFile is the name of the file in the cache directory that records how long recent builds
+// of each tool took, as a JSON object mapping import paths to durations in nanoseconds.
+const buildTimesFile = "build-times.json"
+
+// maxBuildTimes is the number of recent build times kept for each tool.
+const maxBuildTimes = 5
+
+// BuildTimes returns the expected time to download and build each tool that has been built in the cache,
+// keyed by import path. It is the average of the most recent builds of the tool, for any version and platform,
+// since build times are mostly decided by the size of the tool. Tools that were never built are omitted.
+func (c *Cache) BuildTimes() (map[string]time.Duration, error) {
+	recorded, err := c.readBuildTimes()
+	if err != nil {
+		return nil, err
+	}
+	times := make(map[string]time.Duration, len(recorded))
+	for importPath, durations := range recorded {
+		if len(durations) == 0 {
+			continue
+		}
+		var total time.Duration
+		for _, d := range durations {
+			total += d
+		}
+		times[importPath] = total / time.Duration(len(durations))
+	}
+	return times, nil
+}
+
+func (c *Cache) readBuildTimes() (map[string][]time.Duration, error) {
+	p := filepath.Join(c.rootDir, buildTimesFile)
+	data, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return map[string][]time.Duration{}, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "cache: failed to read file %q", p)
+	}
+	var recorded map[string][]time.Duration
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return nil, errors.Wrapf(err, "cache: failed to parse file %q", p)
+	}
+	if recorded == nil {
+		recorded = map[string][]time.Duration{}
+	}
+	return recorded, nil
+}
+
+// recordBuildTime adds d to the recent build times of the tool with the given import path.
+// The build times are only used for estimates, so errors are logged instead of being returned.
+func (c *Cache) recordBuildTime(importPath string, d time.Duration) {
+	logger := c.logger.WithField("importPath", importPath)
+	unlock, err := c.lock(context.Background(), buildTimesLockFile, false)
+	if err != nil {
+		logger.WithError(err).Debug("failed to lock build times")
+		return
+	}
+	defer unlock()
+	recorded, err := c.readBuildTimes()
+	if err != nil {
+		// Start over since the file is only a cache of estimates
+		logger.WithError(err).Debug("failed to read build times")
+		recorded = map[string][]time.Duration{}
+	}
+	durations := append(recorded[importPath], d)
+	if len(durations) > maxBuildTimes {
+		durations = durations[len(durations)-maxBuildTimes:]
+	}
+	recorded[importPath] = durations
+	data, err := json.Marshal(recorded)
+	if err != nil {
+		logger.WithError(err).Debug("failed to serialize build times")
+		return
+	}
+	if err := c.writeStateFile(buildTimesFile, data); err != nil {
+		logger.WithError(err).Debug("failed to write build times")
+	}
+}
@@ -6,25 +6,84 @@ package cache
 
 import (
 	"context"
+	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/getshiphub/shed/internal/ratelimit"
+	"github.com/getshiphub/shed/internal/retry"
 	"github.com/getshiphub/shed/internal/util"
+	"github.com/getshiphub/shed/remote"
 	"github.com/getshiphub/shed/tool"
+	"github.com/getshiphub/shed/xdg"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
 )
 
+// DirEnvVar is the environment variable that overrides the default cache directory, see DefaultDir.
+const DirEnvVar = "SHED_CACHE_DIR"
+
+// DefaultDir returns the directory used for the cache when none is configured. It is the value of
+// SHED_CACHE_DIR if it is set, which makes it easy to point shed at a shared or relocated cache,
+// ex: on a CI runner. A relative path is made absolute using the current directory.
+// Otherwise it is xdg.CacheDir(), which respects XDG_CACHE_HOME and the conventions of the platform.
+func DefaultDir() (string, error) {
+	if dir := os.Getenv(DirEnvVar); dir != "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return "", errors.Wrapf(err, "cache: invalid %s %q", DirEnvVar, dir)
+		}
+		return abs, nil
+	}
+	dir, err := xdg.CacheDir()
+	if err != nil {
+		return "", errors.Wrap(err, "cache: failed to find user cache directory")
+	}
+	return dir, nil
+}
+
 // Cache manages tools in an OS filesystem directory.
+// A Cache is safe for concurrent use by multiple goroutines.
 type Cache struct {
 	rootDir string
 	// Used to download and build tools.
 	goClient Go
 	// For diagnostics.
 	logger logrus.FieldLogger
+	// Used to explain why a download failed.
+	diagnose DiagnoseFunc
+	// Whether the cache is shared between users.
+	shared    bool
+	umaskOnce sync.Once
+	// Maps import paths to a *sync.Mutex, so the same tool isn't installed concurrently.
+	// Lock files are used in addition to coordinate with other processes.
+	toolLocks sync.Map
+	// How long to wait for other processes using the cache, 0 means no limit.
+	lockTimeout time.Duration
+	// Whether the cache has its own module cache.
+	moduleCache bool
+	// Whether the cache has its own module cache and build cache.
+	isolated bool
+	// Module cache and build cache shared with other caches, empty means use the environment.
+	goModCache   string
+	goBuildCache string
+	// Used to download release tools and prebuilt binaries.
+	httpClient *http.Client
+	// Limits the rate of downloads made with httpClient, nil means no limit.
+	downloadLimiter *ratelimit.Limiter
+	// Used to share built tools, nil means tools are always built locally.
+	remote         remote.Backend
+	remoteReadOnly bool
+	// How downloads are retried when they fail with transient errors.
+	retry retry.Policy
 }
 
 // New creates a new Cache instance that uses the directory dir.
@@ -45,6 +104,7 @@ func New(dir string, opts ...Option) *Cache {
 		logger.Out = ioutil.Discard
 		c.logger = logger
 	}
+	c.goClient = retryingGo{Go: loggingGo{Go: c.goClient, logger: c.logger}, policy: c.retry, logger: c.logger}
 	return c
 }
 
@@ -59,8 +119,35 @@ func WithGo(goClient Go) Option {
 	}
 }
 
+// WithRetry sets how commands and requests that use the network, like downloading modules, resolving versions,
+// and downloading release assets, are retried when they fail with a transient error, ex: a dropped connection
+// or a proxy responding with 503. They are attempted up to attempts times, waiting baseDelay before the
+// second attempt and doubling the delay after that. jitter is the fraction of each delay that is randomized,
+// between 0 and 1. Errors that retrying won't fix, like a version that doesn't exist, are returned right away.
+// By default nothing is retried.
+func WithRetry(attempts int, baseDelay time.Duration, jitter float64) Option {
+	return func(c *Cache) {
+		c.retry = retry.Policy{Attempts: attempts, BaseDelay: baseDelay, Jitter: jitter}
+	}
+}
+
+// DiagnoseFunc checks for problems resolving importPath after downloading it failed.
+// It returns an error describing the problem, or nil if no problem was found.
+type DiagnoseFunc func(ctx context.Context, importPath string) error
+
+// WithDiagnose sets a function that is used to explain why downloading a tool failed,
+// since the errors from the go command can be hard to understand. For example,
+// vanity.Check can be used to find problems with vanity import paths.
+// By default no diagnostics are done.
+func WithDiagnose(fn DiagnoseFunc) Option {
+	return func(c *Cache) {
+		c.diagnose = fn
+	}
+}
+
 // WithLogger sets a logger that should be used for writing debug messages.
-// By default no logging is done.
+// Each command run by the Go client is logged at debug level, along with the
+// tools that are built, imported, and pruned. By default no logging is done.
 func WithLogger(logger logrus.FieldLogger) Option {
 	return func(c *Cache) {
 		c.logger = logger
@@ -73,43 +160,316 @@ func (c *Cache) Dir() string {
 }
 
 // Clean removes the cache directory and all contents from the filesystem.
-func (c *Cache) Clean() error {
-	if err := os.RemoveAll(c.rootDir); err != nil {
+// It waits for other shed processes using the cache to finish first, unless ctx is done.
+func (c *Cache) Clean(ctx context.Context) error {
+	if !util.FileOrDirExists(c.rootDir) {
+		return nil
+	}
+	unlock, err := c.lock(ctx, cacheLockFile, false)
+	if err != nil {
+		return err
+	}
+	if err := makeWritable(c.rootDir); err != nil {
+		unlock()
 		return errors.Wrapf(err, "cache: clean failed")
 	}
+	entries, err := ioutil.ReadDir(c.rootDir)
+	if err != nil {
+		unlock()
+		return errors.Wrapf(err, "cache: clean failed")
+	}
+	for _, e := range entries {
+		// The lock file can't be removed while it is open on all platforms, so remove it last
+		if e.Name() == cacheLockFile {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(c.rootDir, e.Name())); err != nil {
+			unlock()
+			return errors.Wrapf(err, "cache: clean failed")
+		}
+	}
+	unlock()
+	// Another process may have started using the cache as soon as it was unlocked,
+	// in which case the lock file or directory won't be removed, which is fine.
+	if err := os.Remove(filepath.Join(c.rootDir, cacheLockFile)); err == nil {
+		os.Remove(c.rootDir)
+	}
 	return nil
 }
 
+// GitHubDir returns the directory where responses from the GitHub API are cached, see github.Client.WithCache.
+func (c *Cache) GitHubDir() string {
+	return filepath.Join(c.rootDir, "github")
+}
+
 // toolsDir returns the path to the directory where tools are installed.
 func (c *Cache) toolsDir() string {
 	return filepath.Join(c.rootDir, "tools")
 }
 
+// InstallOption customizes how a single tool is installed.
+type InstallOption func(*installOptions)
+
+type installOptions struct {
+	env      []string
+	progress func(Stage)
+	source   func(Source)
+	rebuild  bool
+	// checkToolchain is set by InstallCheckToolchain
+	checkToolchain bool
+	prebuilt       *Prebuilt
+	// vendorDir is set by InstallOffline
+	vendorDir string
+}
+
+// Stage is a step of installing a tool that does work, as opposed to using what's already in the cache.
+type Stage int
+
+const (
+	// StageResolve means the version of the tool is being resolved and the tool is being downloaded.
+	StageResolve Stage = iota + 1
+	// StageDownload means the tool is being downloaded.
+	StageDownload
+	// StageBuild means the tool is being built.
+	StageBuild
+)
+
+func (s Stage) String() string {
+	switch s {
+	case StageResolve:
+		return "resolve"
+	case StageDownload:
+		return "download"
+	case StageBuild:
+		return "build"
+	}
+	return fmt.Sprintf("Stage(%d)", int(s))
+}
+
+// InstallProgress sets a function that is called when installing the tool enters a new stage.
+// Stages are skipped if they aren't needed, ex: the build stage does not happen if the tool
+// has already been built.
+func InstallProgress(fn func(Stage)) InstallOption {
+	return func(o *installOptions) {
+		o.progress = fn
+	}
+}
+
+func (o installOptions) report(s Stage) {
+	if o.progress != nil {
+		o.progress(s)
+	}
+}
+
+// Source is where the binary of an installed tool came from.
+type Source int
+
+const (
+	// SourceCache means the binary was already in the cache and was reused.
+	SourceCache Source = iota + 1
+	// SourceRemote means the binary was pulled from the remote cache, see WithRemote.
+	SourceRemote
+	// SourceBuild means the tool was built from source.
+	SourceBuild
+	// SourceRelease means the binary was downloaded from the release of a release tool.
+	SourceRelease
+	// SourcePrebuilt means a prebuilt binary was downloaded instead of building the tool, see InstallPrebuilt.
+	SourcePrebuilt
+)
+
+var sourceNames = map[Source]string{
+	SourceCache:    "cache",
+	SourceRemote:   "remote",
+	SourceBuild:    "build",
+	SourceRelease:  "release",
+	SourcePrebuilt: "prebuilt",
+}
+
+func (s Source) String() string {
+	if name, ok := sourceNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Source(%d)", int(s))
+}
+
+// MarshalText encodes s as its name, ex: 'cache'.
+func (s Source) MarshalText() ([]byte, error) {
+	name, ok := sourceNames[s]
+	if !ok {
+		return nil, errors.Errorf("cache: invalid source %d", int(s))
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText decodes a source from its name.
+func (s *Source) UnmarshalText(text []byte) error {
+	for src, name := range sourceNames {
+		if name == string(text) {
+			*s = src
+			return nil
+		}
+	}
+	return errors.Errorf("cache: unknown source %q", text)
+}
+
+// InstallRebuild makes Install remove the tool from the cache before installing it, so that it is
+// downloaded and built again even if its binary exists. Use it to reinstall tools that are stale, see CheckStale.
+func InstallRebuild() InstallOption {
+	return func(o *installOptions) {
+		o.rebuild = true
+	}
+}
+
+// InstallSource sets a function that is called with where the binary of the tool came from
+// once it is installed, ex: SourceCache if it was already in the cache. It is not called if
+// the install fails.
+func InstallSource(fn func(Source)) InstallOption {
+	return func(o *installOptions) {
+		o.source = fn
+	}
+}
+
+func (o installOptions) reportSource(s Source) {
+	if o.source != nil {
+		o.source(s)
+	}
+}
+
+// InstallEnv sets additional environment variables, in the form 'key=value',
+// for the go commands run to install the tool. This allows for settings like
+// GOINSECURE to only apply to a specific tool.
+func InstallEnv(env ...string) InstallOption {
+	return func(o *installOptions) {
+		o.env = append(o.env, env...)
+	}
+}
+
 // Install installs the given tool. t must have ImportPath set, otherwise
 // an error will be returned. If t.Version is empty, then the latest version
 // of the tool will be installed. The returned tool will have Version set
-// to the version that was installed.
+// to the version that was installed, ModulePath set to the module that provides it,
+// and Sum set to the hash of the module.
+//
+// If t.Sum is set, the downloaded module must have the same hash, otherwise
+// a *ChecksumError is returned.
+//
+// If the tool fails to download or build, an *InstallError is returned, which matches
+// ErrVersionNotFound if the version doesn't exist.
+//
+// The go command is always run with GOBIN set to the directory of the tool in the cache,
+// so installing never writes binaries to the user's GOBIN or GOPATH/bin.
+//
+// If the cache has a remote, see WithRemote, and t has an exact version, the built tool is pulled
+// from the remote if it is there, instead of being downloaded and built.
+//
+// If t.Platform is set, the tool is built for that platform instead of the current one, and the
+// binary is stored separately, see tool.Tool.Filepath. The returned tool has the same Platform.
+//
+// If t is a release tool, the asset of the release for the platform is downloaded
+// instead and the binary is extracted from it, see tool.Release. t must have an exact version.
+// If t has a hash for the asset of the platform, the asset must have the same hash, otherwise
+// a *ChecksumError is returned. The returned tool has the hash of the asset for the platform.
+//
+// If t.Reproducible is set, the tool is built reproducibly with its version of Go, see tool.Reproducible.
+// If t has a hash for the binary of the platform, the binary must have the same hash, otherwise a
+// *ChecksumError is returned. The returned tool has the hash of the binary for the platform.
 //
 // The provided context is used to terminate the install if the context becomes
 // done before the install completes on its own.
-func (c *Cache) Install(ctx context.Context, t tool.Tool) (tool.Tool, error) {
+func (c *Cache) Install(ctx context.Context, t tool.Tool, opts ...InstallOption) (tool.Tool, error) {
+	var o installOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.env = c.goEnv(o.env)
 	select {
 	case <-ctx.Done():
 		return t, ctx.Err()
 	default:
 	}
+	if t.Platform != "" {
+		goos, goarch, err := tool.ParsePlatform(t.Platform)
+		if err != nil {
+			return t, err
+		}
+		// Put them last so they can't be overridden by InstallEnv
+		o.env = append(o.env, "GOOS="+goos, "GOARCH="+goarch)
+	}
+	// The tool needs them to build, so they take precedence over InstallEnv as well
+	o.env = append(o.env, t.BuildFlags.EnvList()...)
+	if t.Reproducible != nil {
+		if err := tool.CheckReproducible(t); err != nil {
+			return t, err
+		}
+		o.env = append(o.env, reproducibleEnv(t.Reproducible)...)
+	}
+	if o.vendorDir != "" {
+		o.env = append(o.env, offlineEnv(o.vendorDir)...)
+	}
 
 	// Make sure import path is set as it's required for download
 	if t.ImportPath == "" {
 		return t, errors.New("import path is required on module")
 	}
+	unlock := c.lockTool(t.ImportPath)
+	defer unlock()
+	// Tools are added to the cache, so other processes can do the same, but not remove tools
+	unlockCache, err := c.lock(ctx, cacheLockFile, true)
+	if err != nil {
+		return t, err
+	}
+	defer unlockCache()
+	unlockTool, err := c.lock(ctx, toolLockFile(t.ImportPath), false)
+	if err != nil {
+		return t, err
+	}
+	defer unlockTool()
+	if err := c.checkLayout(); err != nil {
+		return t, err
+	}
+	if c.shared {
+		// The go command creates files so the umask needs to be set for it to respect the shared permissions
+		c.umaskOnce.Do(func() {
+			setUmask(sharedUmask)
+		})
+	}
+	if o.rebuild && t.HasSemver() {
+		fp, err := t.Filepath()
+		if err != nil {
+			return t, err
+		}
+		if dir := filepath.Join(c.toolsDir(), fp); util.FileOrDirExists(dir) {
+			c.logger.WithField("tool", t).Debug("removing tool to rebuild it")
+			if err := c.removeToolDir(dir); err != nil {
+				return t, err
+			}
+		}
+	}
+	if t.IsRelease() {
+		return c.installRelease(ctx, t, o)
+	}
+	// Waiting for locks isn't part of the build time
+	start := time.Now()
+
+	pulled := false
+	if c.remote != nil && t.HasSemver() && o.vendorDir == "" {
+		pulled = c.pullTool(ctx, t, o)
+	}
 
 	// Download step
 
-	downloadedTool, err := c.download(ctx, t)
+	downloadedTool, err := c.download(ctx, t, o)
+	if err != nil {
+		return t, downloadError(t, err)
+	}
+
+	// Make sure the module is the one in the lockfile, it could be different if it
+	// was served by a different proxy or the cache was modified
+	sum, err := c.verifyModule(downloadedTool)
 	if err != nil {
-		return t, errors.WithMessagef(err, "failed to download tool: %s", t)
+		return downloadedTool, errors.WithMessagef(err, "failed to verify tool: %s", downloadedTool)
 	}
+	downloadedTool.Sum = sum
 
 	// Build step
 
@@ -117,36 +477,149 @@ func (c *Cache) Install(ctx context.Context, t tool.Tool) (tool.Tool, error) {
 	if err != nil {
 		return downloadedTool, err
 	}
-	baseDir := c.toolsDir()
-	binDir := filepath.Join(baseDir, fp)
-
+	binDir := filepath.Join(c.toolsDir(), fp)
 	bfp, err := downloadedTool.BinaryFilepath()
 	if err != nil {
 		return downloadedTool, err
 	}
-	binPath := filepath.Join(baseDir, bfp)
+	binName := filepath.Base(bfp)
+	values, err := c.goClient.Env(ctx, o.env, "GOOS", "GOARCH", "GOVERSION")
+	if err != nil {
+		return downloadedTool, errors.WithMessage(err, "failed to get build environment")
+	}
+	platform, goVersion := tool.Platform(values[0], values[1]), values[2]
+	if downloadedTool.Reproducible != nil {
+		if err := checkToolchainVersion(downloadedTool, goVersion); err != nil {
+			return downloadedTool, err
+		}
+		goVersion += reproducibleKeySuffix
+	}
+	key := buildKey(fp, values[0], values[1], goVersion)
+	if p := filepath.Join(binDir, buildsDir, key, binName); runtime.GOOS == "windows" && len(p) >= maxWindowsPath {
+		c.logger.Warnf("The path of %s is %d characters long, which is longer than windows allows by default. "+
+			"If it fails to run, enable long paths in windows or set %s to a shorter directory", p, len(p), DirEnvVar)
+	}
 
-	// Check if already built
-	if util.FileOrDirExists(binPath) {
+	// Check if already built in the same environment
+	binPath, built, err := c.findBuild(binDir, key, binName)
+	if err != nil {
+		return downloadedTool, err
+	}
+	if built {
 		c.logger.WithFields(logrus.Fields{
 			"tool": downloadedTool,
 			"path": binPath,
 		}).Debug("tool binary already exists, skipping build")
+		if downloadedTool.Reproducible != nil {
+			sum, err := readBinarySum(filepath.Dir(binPath))
+			if err != nil {
+				return downloadedTool, err
+			}
+			if downloadedTool, err = checkBinarySum(downloadedTool, platform, binPath, sum); err != nil {
+				return downloadedTool, err
+			}
+		}
+		if pulled {
+			o.reportSource(SourceRemote)
+		} else {
+			o.reportSource(SourceCache)
+		}
 		return downloadedTool, nil
 	}
 
-	err = c.goClient.Build(ctx, downloadedTool.ImportPath, binPath, binDir)
+	// Prebuilt binaries are built without any custom flags, so they can't be used for tools that have them or are reproducible
+	if o.prebuilt != nil && o.vendorDir == "" && downloadedTool.BuildFlags.IsZero() && downloadedTool.Reproducible == nil {
+		ok, err := c.installPrebuilt(ctx, downloadedTool, *o.prebuilt, binDir, key, o)
+		if err != nil {
+			return downloadedTool, errors.WithMessagef(err, "failed to install prebuilt binary of tool: %s", downloadedTool)
+		}
+		if ok {
+			o.reportSource(SourcePrebuilt)
+			return downloadedTool, nil
+		}
+	}
+
+	if o.checkToolchain {
+		if err := c.checkToolchain(ctx, downloadedTool, o.env); err != nil {
+			return downloadedTool, err
+		}
+	}
+	if err := c.checkBuildEnv(ctx, downloadedTool, o.env); err != nil {
+		return downloadedTool, err
+	}
+	o.report(StageBuild)
+	// Build in a temp dir so the binary is never seen partially written
+	stage, err := c.stageBuild(binDir)
 	if err != nil {
 		return downloadedTool, errors.WithMessagef(err, "failed to build tool: %s", downloadedTool)
 	}
+	defer os.RemoveAll(stage)
+	stageBin := filepath.Join(stage, binName)
+	flags := downloadedTool.BuildFlags.Args()
+	if downloadedTool.Reproducible != nil {
+		flags = reproducibleBuildFlags(downloadedTool)
+	}
+	err = c.goClient.Build(ctx, downloadedTool.ImportPath, stageBin, binDir, flags, withGOBIN(o.env, binDir))
+	if err != nil {
+		return downloadedTool, &InstallError{Kind: ErrBuildFailed, Tool: downloadedTool, Err: err}
+	}
+	if err := writeBinarySum(stage, stageBin); err != nil {
+		return downloadedTool, errors.WithMessagef(err, "failed to build tool: %s", downloadedTool)
+	}
+	if downloadedTool.Reproducible != nil {
+		sum, err := readBinarySum(stage)
+		if err != nil {
+			return downloadedTool, err
+		}
+		// The build is discarded if it doesn't match, so it isn't used by other installs
+		if downloadedTool, err = checkBinarySum(downloadedTool, platform, stageBin, sum); err != nil {
+			return downloadedTool, err
+		}
+	}
+	c.writeProvenance(ctx, downloadedTool, stage, o.env)
+
+	binPath, err = c.commitBuild(binDir, stage, key, binName)
+	if err != nil {
+		return downloadedTool, errors.WithMessagef(err, "failed to publish tool: %s", downloadedTool)
+	}
 
 	c.logger.WithFields(logrus.Fields{
 		"tool": downloadedTool,
 		"path": binPath,
 	}).Debug("tool built")
+	c.recordBuildTime(downloadedTool.ImportPath, time.Since(start))
+	if c.remote != nil && !c.remoteReadOnly && o.vendorDir == "" {
+		c.pushTool(ctx, downloadedTool, o)
+	}
+	o.reportSource(SourceBuild)
 	return downloadedTool, nil
 }
 
+// lockTool locks the tool with the given import path for installing.
+// The returned function must be called to unlock it.
+func (c *Cache) lockTool(importPath string) func() {
+	v, _ := c.toolLocks.LoadOrStore(importPath, &sync.Mutex{})
+	mu := v.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// getD downloads t using the go client. If it fails, the failure is diagnosed if possible.
+func (c *Cache) getD(ctx context.Context, t tool.Tool, modDir string, o installOptions) error {
+	err := c.goClient.GetD(ctx, t.Module(), modDir, withGOBIN(o.env, modDir))
+	// Diagnosing uses the network, which isn't available offline
+	if err == nil || c.diagnose == nil || ctx.Err() != nil || o.vendorDir != "" {
+		return err
+	}
+	derr := c.diagnose(ctx, t.ImportPath)
+	if derr == nil {
+		return err
+	}
+	c.logger.WithError(derr).Debugf("found problem resolving %s", t.ImportPath)
+	// Use the diagnosis as the cause since it explains the problem better
+	return errors.WithMessagef(derr, "%v", err)
+}
+
 // download does half the work of Install. It is responsible for downloading the tool
 // using go get -d. It does this by creating an empty go.mod which can then be used to install
 // the desired tool. If no version is specified for the tool, the latest version will be resolved
@@ -156,7 +629,7 @@ func (c *Cache) Install(ctx context.Context, t tool.Tool) (tool.Tool, error) {
 // For example if the import path is golang.org/x/tools/cmd/stringer then download will create
 // BASE_DIR/golang.org/x/tools/cmd/stringer@VERSION/go.mod where BASE_DIR is the baseDir parameter
 // and VERSION is the version of the tool (either explicit or resolved).
-func (c *Cache) download(ctx context.Context, t tool.Tool) (tool.Tool, error) {
+func (c *Cache) download(ctx context.Context, t tool.Tool, o installOptions) (tool.Tool, error) {
 	// Get the path to where the tool will be installed
 	// This is where the go.mod file will be
 	fp, err := t.Filepath()
@@ -181,16 +654,19 @@ func (c *Cache) download(ctx context.Context, t tool.Tool) (tool.Tool, error) {
 			}
 
 			modfileOK := true
-			// There should only be a single require, otherwise something is wrong
+			var mod module.Version
+			// There should only be a single require, otherwise something is wrong.
+			// There are none if a previous download failed.
 			if len(modFile.Require) != 1 {
 				modfileOK = false
 				c.logger.Debugf("expected 1 required statement in go.mod, found %d", len(modFile.Require))
+			} else {
+				mod = modFile.Require[0].Mod
 			}
 
-			mod := modFile.Require[0].Mod
 			// Use contains since actual module could have less then what we are installing
 			// Ex: golang.org/x/tools vs golang.org/x/tools/cmd/stringer
-			if !strings.Contains(t.ImportPath, mod.Path) {
+			if modfileOK && !strings.Contains(t.ImportPath, mod.Path) {
 				modfileOK = false
 				c.logger.WithFields(logrus.Fields{
 					"expected": t.ImportPath,
@@ -198,7 +674,7 @@ func (c *Cache) download(ctx context.Context, t tool.Tool) (tool.Tool, error) {
 				}).Debug("incorrect dependency in go.mod")
 			}
 
-			if t.Version != mod.Version {
+			if modfileOK && t.Version != mod.Version {
 				modfileOK = false
 				c.logger.WithFields(logrus.Fields{
 					"expected": t.Version,
@@ -210,6 +686,7 @@ func (c *Cache) download(ctx context.Context, t tool.Tool) (tool.Tool, error) {
 				c.logger.WithFields(logrus.Fields{
 					"tool": t,
 				}).Debug("tool already exists, skipping download")
+				t.ModulePath = mod.Path
 				return t, nil
 			}
 
@@ -222,7 +699,7 @@ func (c *Cache) download(ctx context.Context, t tool.Tool) (tool.Tool, error) {
 			}
 		}
 
-		if err := os.MkdirAll(modDir, 0o755); err != nil {
+		if err := c.mkdirAll(modDir); err != nil {
 			return t, errors.Wrapf(err, "failed to create directory %q", modDir)
 		}
 
@@ -237,10 +714,18 @@ func (c *Cache) download(ctx context.Context, t tool.Tool) (tool.Tool, error) {
 		// go get so we don't need to reinvent the module resolution & downloading.
 		// Also we can reuse an existing download that's already cached.
 
-		err = c.goClient.GetD(ctx, t.Module(), modDir)
+		o.report(StageDownload)
+		err = c.getD(ctx, t, modDir, o)
+		if err != nil {
+			return t, err
+		}
+
+		// Read the go.mod file to find out which module provides the tool
+		mod, err := readRequire(modfilePath)
 		if err != nil {
 			return t, err
 		}
+		t.ModulePath = mod.Path
 
 		c.logger.WithFields(logrus.Fields{
 			"tool":    t,
@@ -252,7 +737,7 @@ func (c *Cache) download(ctx context.Context, t tool.Tool) (tool.Tool, error) {
 	// Don't have the version, this process is a bit more complicated because
 	// we need to resolve the correct version.
 
-	if err := os.MkdirAll(modDir, 0o755); err != nil {
+	if err := c.mkdirAll(modDir); err != nil {
 		return t, errors.Wrapf(err, "failed to create directory %q", modDir)
 	}
 
@@ -274,27 +759,19 @@ func (c *Cache) download(ctx context.Context, t tool.Tool) (tool.Tool, error) {
 
 	// Download the module source. This will do the heavy lifting to figure out
 	// the correct version.
-	err = c.goClient.GetD(ctx, t.Module(), modDir)
+	o.report(StageResolve)
+	err = c.getD(ctx, t, modDir, o)
 	if err != nil {
 		return t, err
 	}
 
 	// Need to read go.mod file so we can figure out what version was installed
-	data, err := ioutil.ReadFile(modfilePath)
-	if err != nil {
-		return t, errors.Wrapf(err, "failed to read file %q", modfilePath)
-	}
-
-	modFile, err := modfile.Parse(modfilePath, data, nil)
+	mod, err := readRequire(modfilePath)
 	if err != nil {
-		return t, errors.Wrapf(err, "failed to parse go.mod file %q", modfilePath)
-	}
-
-	// There should only be a single require, otherwise we have a bug
-	if len(modFile.Require) != 1 {
-		return t, errors.Errorf("expected 1 required statement in go.mod, found %d", len(modFile.Require))
+		return t, err
 	}
-	t.Version = modFile.Require[0].Mod.Version
+	t.Version = mod.Version
+	t.ModulePath = mod.Path
 
 	// We got the version, now we need to rename the dir so it includes the version
 	vfp, err := t.Filepath()
@@ -322,18 +799,182 @@ func (c *Cache) download(ctx context.Context, t tool.Tool) (tool.Tool, error) {
 	return t, nil
 }
 
+// readRequire returns the module required by the go.mod file at modfilePath.
+// The go.mod files created by download only ever require the module that provides the tool.
+func readRequire(modfilePath string) (module.Version, error) {
+	data, err := ioutil.ReadFile(modfilePath)
+	if err != nil {
+		return module.Version{}, errors.Wrapf(err, "failed to read file %q", modfilePath)
+	}
+
+	modFile, err := modfile.Parse(modfilePath, data, nil)
+	if err != nil {
+		return module.Version{}, errors.Wrapf(err, "failed to parse go.mod file %q", modfilePath)
+	}
+
+	// There should only be a single require, otherwise we have a bug
+	if len(modFile.Require) != 1 {
+		return module.Version{}, errors.Errorf("expected 1 required statement in go.mod, found %d", len(modFile.Require))
+	}
+	return modFile.Require[0].Mod, nil
+}
+
+// BinaryPath returns the absolute path where the binary for the given tool is installed.
+// Unlike ToolPath, the binary doesn't need to exist. See LayoutVersion for where it is located.
+func (c *Cache) BinaryPath(t tool.Tool) (string, error) {
+	bfp, err := t.BinaryFilepath()
+	if err != nil {
+		return "", err
+	}
+	dir, err := buildDir(filepath.Join(c.toolsDir(), filepath.Dir(bfp)))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(bfp)), nil
+}
+
 // ToolPath returns the absolute path the the installed binary for the given tool.
-// If the binary cannot be found, an error is returned.
+// If the binary cannot be found, a *StaleError is returned. If t.Sum is set, the module
+// of the tool must have the same hash, otherwise a *ChecksumError is returned.
+// If the binary was changed after it was built, the error is ErrBinaryModified.
 func (c *Cache) ToolPath(t tool.Tool) (string, error) {
-	baseDir := c.toolsDir()
-	bfp, err := t.BinaryFilepath()
+	binPath, err := c.toolPath(t)
 	if err != nil {
 		return "", err
 	}
+	if err := c.checkBinary(t, binPath); err != nil {
+		return "", err
+	}
+	return binPath, nil
+}
 
-	binPath := filepath.Join(baseDir, bfp)
+// toolPath is like ToolPath but doesn't check the binary.
+func (c *Cache) toolPath(t tool.Tool) (string, error) {
+	binPath, err := c.BinaryPath(t)
+	if err != nil {
+		return "", err
+	}
 	if !util.FileOrDirExists(binPath) {
-		return "", errors.Errorf("binary for tool %s does not exist", t)
+		return "", &StaleError{Tool: t, Reason: "binary does not exist"}
+	}
+	if err := c.checkNoSymlinks(binPath); err != nil {
+		return "", err
+	}
+	if t.Sum != "" {
+		if _, err := c.verifyModule(t); err != nil {
+			return "", err
+		}
 	}
 	return binPath, nil
 }
+
+// LatestVersion returns the latest version of the module that provides t, as resolved by
+// the 'latest' version query. It is the same as QueryVersion with 'latest'.
+func (c *Cache) LatestVersion(ctx context.Context, t tool.Tool, opts ...InstallOption) (string, error) {
+	return c.QueryVersion(ctx, t, "latest", opts...)
+}
+
+// QueryVersion returns the version of the module that provides t that the module query resolves to,
+// ex: the pseudo-version of the latest commit of a branch. Only InstallEnv and InstallOffline are used
+// from opts. The module is found using t.ModulePath, or the go.mod of t in the cache if ModulePath is empty.
+// An error is returned if the module of t is not known, which is always the case for release tools.
+//
+// The provided context is used to terminate the query if the context becomes
+// done before the query completes on its own.
+func (c *Cache) QueryVersion(ctx context.Context, t tool.Tool, query string, opts ...InstallOption) (string, error) {
+	var o installOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.env = c.goEnv(o.env)
+	if o.vendorDir != "" {
+		o.env = append(o.env, offlineEnv(o.vendorDir)...)
+	}
+	if t.IsRelease() {
+		return "", errors.Errorf("cache: %s version of release tool %s is unknown", query, t)
+	}
+
+	modPath, err := c.ModulePath(t)
+	if err != nil {
+		return "", err
+	}
+
+	// go list -m needs to run in a module, use a throwaway one
+	if err := c.mkdirAll(c.rootDir); err != nil {
+		return "", errors.Wrapf(err, "cache: failed to create directory %q", c.rootDir)
+	}
+	tmpDir, err := ioutil.TempDir(c.rootDir, "tmp-")
+	if err != nil {
+		return "", errors.Wrap(err, "cache: failed to create temp directory")
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := createGoModFile("_", tmpDir); err != nil {
+		return "", err
+	}
+
+	mod, err := c.goClient.ListM(ctx, modPath+"@"+query, tmpDir, o.env)
+	if err != nil {
+		return "", errors.WithMessagef(err, "cache: failed to find %s version of %s", query, modPath)
+	}
+	c.logger.WithFields(logrus.Fields{
+		"tool":    t,
+		"query":   query,
+		"version": mod.Version,
+	}).Debug("resolved version query")
+	return mod.Version, nil
+}
+
+// ModulePath returns the path of the module that provides t. It is t.ModulePath,
+// or if that is empty, the module of t in the cache. An error is returned if
+// ModulePath is empty and t is not installed.
+func (c *Cache) ModulePath(t tool.Tool) (string, error) {
+	if t.ModulePath != "" {
+		return t.ModulePath, nil
+	}
+	if t.HasSemver() {
+		fp, err := t.Filepath()
+		if err != nil {
+			return "", err
+		}
+		modfilePath := filepath.Join(c.toolsDir(), fp, "go.mod")
+		if util.FileOrDirExists(modfilePath) {
+			mod, err := readRequire(modfilePath)
+			if err != nil {
+				return "", err
+			}
+			return mod.Path, nil
+		}
+	}
+	return "", errors.Errorf("cache: module of %s is unknown, it must be installed first", t)
+}
+
+// ModuleSum returns the hash of the source of the module that provides t, in the format
+// used by go.sum, ex: 'h1:...'. The hash is recorded by the go command when t is downloaded,
+// so t must be installed, otherwise an error is returned.
+func (c *Cache) ModuleSum(t tool.Tool) (string, error) {
+	modPath, err := c.ModulePath(t)
+	if err != nil {
+		return "", err
+	}
+	fp, err := t.Filepath()
+	if err != nil {
+		return "", err
+	}
+	gosumPath := filepath.Join(c.toolsDir(), fp, "go.sum")
+	data, err := ioutil.ReadFile(gosumPath)
+	if os.IsNotExist(err) {
+		return "", errors.Errorf("cache: %s is not installed, no go.sum file found", t)
+	} else if err != nil {
+		return "", errors.Wrapf(err, "cache: failed to read file %q", gosumPath)
+	}
+
+	// Each line has the format 'MODULE VERSION HASH', the hash of the module's
+	// go.mod file uses the version 'VERSION/go.mod' and is skipped
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[0] == modPath && fields[1] == t.Version {
+			return fields[2], nil
+		}
+	}
+	return "", errors.Errorf("cache: no hash for %s@%s found in %q", modPath, t.Version, gosumPath)
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/getshiphub/shed/internal/retry"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// ErrVersionNotFound is returned by Install when the version of a tool doesn't exist.
+var ErrVersionNotFound = errors.New("cache: version not found")
+
+// ErrDownloadFailed is returned by Install when a tool can't be downloaded for any other reason.
+var ErrDownloadFailed = errors.New("cache: download failed")
+
+// ErrBuildFailed is returned by Install when the go command fails to build a tool.
+var ErrBuildFailed = errors.New("cache: build failed")
+
+// InstallError describes why Install failed to download or build a tool.
+// It matches Kind when using errors.Is, and Err can be inspected with errors.Is and errors.As.
+type InstallError struct {
+	// Kind is ErrVersionNotFound, ErrDownloadFailed, or ErrBuildFailed.
+	Kind error
+	// Tool is the tool that failed to install, with the version that was requested.
+	Tool tool.Tool
+	// Err is the error from the step that failed, ex: the error from the go command.
+	Err error
+}
+
+func (e *InstallError) Error() string {
+	op := "download"
+	if e.Kind == ErrBuildFailed {
+		op = "build"
+	}
+	return fmt.Sprintf("failed to %s tool: %s: %v", op, e.Tool, e.Err)
+}
+
+func (e *InstallError) Unwrap() error {
+	return e.Err
+}
+
+func (e *InstallError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// versionNotFoundMessages are parts of the errors of the go command that mean the version
+// of a module doesn't exist. The go command only reports them as text.
+var versionNotFoundMessages = []string{
+	"unknown revision",
+	"invalid version",
+	"no matching versions",
+}
+
+// downloadError returns an *InstallError for err, which is why t failed to download.
+// Its kind is ErrVersionNotFound if err means the version doesn't exist.
+func downloadError(t tool.Tool, err error) error {
+	kind := ErrDownloadFailed
+	if !retry.IsTransient(err) {
+		msg := err.Error()
+		for _, m := range versionNotFoundMessages {
+			if strings.Contains(msg, m) {
+				kind = ErrVersionNotFound
+				break
+			}
+		}
+	}
+	return &InstallError{Kind: kind, Tool: t, Err: err}
+}
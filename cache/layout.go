@@ -0,0 +1,258 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/getshiphub/shed/internal/util"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// LayoutVersion is the version of the layout of the cache directory. Other programs can rely on
+// the layout as long as the version is the same, it is only changed in incompatible ways by
+// increasing the version. The layout for version 2 is:
+//
+//	DIR/layout-version            the layout version of the cache, ex: "2"
+//	DIR/tools/TOOL@VERSION/       the tool directory, see Cache.ToolDir
+//	DIR/tools/TOOL@VERSION/go.mod the module used to build the tool, requiring the module of the tool
+//	DIR/tools/TOOL@VERSION/go.sum the hashes of the modules used to build the tool
+//	DIR/tools/TOOL@VERSION/build  the key of the current build of the tool
+//	DIR/tools/TOOL@VERSION/builds/KEY/
+//	                              a build of the tool
+//	DIR/tools/TOOL@VERSION/builds/KEY/shed.sum
+//	                              the hash of the binary, in the form 'sha256:HEX'
+//	DIR/tools/TOOL@VERSION/builds/KEY/provenance.json
+//	                              how the binary was built, see Provenance
+//	DIR/tools/TOOL@VERSION/builds/KEY/NAME
+//	                              the binary, see Cache.BinaryPath
+//
+// Builds are content-addressed: KEY is the hex encoded hash of the tool directory, which identifies the
+// module, version, build flags, and platform of the tool, along with the GOOS, GOARCH, and version of the
+// go command that built it. A build is assembled in a temporary directory and renamed into place once it is
+// complete, and it never changes after that. Building the tool with another version of Go adds a build instead
+// of replacing the binary, and the build file, which is also replaced atomically, is changed to point to it.
+// This allows one cache to be used by many projects and shed processes at once without any of them seeing
+// a partially written tool.
+//
+// Release tools are downloaded instead of built, so they have no go.mod or go.sum, and the version of Go
+// isn't part of the key of their builds. Instead they have:
+//
+//	DIR/tools/TOOL@VERSION/asset.sum
+//	                              the hash of the downloaded asset, in the form 'sha256:HEX'
+//
+// TOOL is the escaped import path of the tool and VERSION is its escaped version, see tool.Tool.Filepath.
+// If the tool has build flags, '+build.HASH' is added to the end of the directory name,
+// if it is a release tool, '+release.HASH' is added, and if it was installed for another
+// platform, '+platform.GOOS_GOARCH' is added.
+// NAME is the last element of the import path, unescaped, with '.exe' added if the tool is built for windows,
+// either because shed is running on windows or because the tool was installed for windows from another platform.
+// Uppercase letters in TOOL and VERSION are escaped, so the layout also works on case-insensitive file systems,
+// like the defaults on windows and macOS.
+//
+// A tool directory without a build file has its binary, shed.sum, and provenance.json directly in it,
+// like in version 1. Caches that use version 1 are upgraded to version 2 the first time a tool is installed,
+// which leaves their tools as they are until they are built again.
+//
+// Build directories of shared caches are read-only once they are in place. Every other
+// file and directory is private to shed and can change without the version changing.
+const LayoutVersion = 2
+
+// maxWindowsPath is the maximum length of a path on windows, MAX_PATH, unless long paths are enabled.
+// Programs that don't support long paths can't use longer paths, even if the go command can.
+const maxWindowsPath = 260
+
+// LayoutFileName is the name of the file in the cache directory that contains its layout version.
+const LayoutFileName = "layout-version"
+
+// ReadLayoutVersion returns the layout version of the cache in dir. Caches created before the
+// version was recorded use version 1. If dir does not exist, LayoutVersion is returned since
+// a cache created there would use the current layout.
+func ReadLayoutVersion(dir string) (int, error) {
+	p := filepath.Join(dir, LayoutFileName)
+	data, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		if _, err := os.Stat(filepath.Join(dir, "tools")); err == nil {
+			return 1, nil
+		}
+		return LayoutVersion, nil
+	} else if err != nil {
+		return 0, errors.Wrapf(err, "cache: failed to read file %q", p)
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || v < 1 {
+		return 0, errors.Errorf("cache: invalid layout version %q in %q", strings.TrimSpace(string(data)), p)
+	}
+	return v, nil
+}
+
+// checkLayout makes sure the cache uses the current layout version, and records it if it hasn't been.
+// Caches that use version 1 are upgraded, since their tool directories are still valid.
+func (c *Cache) checkLayout() error {
+	v, err := ReadLayoutVersion(c.rootDir)
+	if err != nil {
+		return err
+	}
+	if v != LayoutVersion && v != 1 {
+		return errors.Errorf("cache: %q uses layout version %d but this version of shed uses %d, clean the cache or use a different directory", c.rootDir, v, LayoutVersion)
+	}
+	p := filepath.Join(c.rootDir, LayoutFileName)
+	if _, err := os.Stat(p); err == nil && v == LayoutVersion {
+		return nil
+	}
+	if v == 1 {
+		c.logger.WithField("path", c.rootDir).Debugf("upgrading cache to layout version %d", LayoutVersion)
+	}
+	// Installs check the layout concurrently, so the file must never be seen partially written
+	return c.writeStateFile(LayoutFileName, []byte(strconv.Itoa(LayoutVersion)+"\n"))
+}
+
+// Files in a tool directory that keep track of its builds, see LayoutVersion.
+const (
+	// BuildFileName is the name of the file in a tool directory that contains the key of its current build.
+	BuildFileName = "build"
+	// buildsDir is the directory in a tool directory that contains its builds.
+	buildsDir = "builds"
+	// stagePrefix is the prefix of the temporary directories in buildsDir that builds are assembled in.
+	stagePrefix = ".tmp-"
+)
+
+// buildKey returns the key of a build of the tool directory fp, relative to the tools directory, made for
+// goos and goarch with goVersion, the version of the go command. goVersion is empty for release tools, and
+// is followed by reproducibleKeySuffix for reproducible builds, since they are built with different settings.
+func buildKey(fp, goos, goarch, goVersion string) string {
+	h := sha256.Sum256([]byte(strings.Join([]string{filepath.ToSlash(fp), goos, goarch, goVersion}, "\x00")))
+	// Half of the hash is plenty to avoid collisions, and keeps paths short
+	return hex.EncodeToString(h[:16])
+}
+
+// readBuild returns the key of the current build of the tool directory dir. An empty string is returned
+// if there is none, either because the tool hasn't been built or because it uses layout version 1.
+func readBuild(dir string) (string, error) {
+	p := filepath.Join(dir, BuildFileName)
+	data, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", errors.Wrapf(err, "cache: failed to read file %q", p)
+	}
+	// The key is used in paths, so make sure it can't point outside of the tool directory
+	key := strings.TrimSpace(string(data))
+	if _, err := hex.DecodeString(key); err != nil || len(key) != 32 {
+		return "", errors.Errorf("cache: invalid build %q in %q", key, p)
+	}
+	return key, nil
+}
+
+// buildDir returns the directory of the current build of the tool directory dir,
+// which is dir itself if it has no build file.
+func buildDir(dir string) (string, error) {
+	key, err := readBuild(dir)
+	if err != nil || key == "" {
+		return dir, err
+	}
+	return filepath.Join(dir, buildsDir, key), nil
+}
+
+// findBuild returns the path of the binary named binName in the build key of the tool directory dir, and
+// whether it exists. If it does, it is made the current build. A binary from layout version 1 is used
+// if the tool has no builds, since there is no way to tell how it was built.
+func (c *Cache) findBuild(dir, key, binName string) (string, bool, error) {
+	cur, err := readBuild(dir)
+	if err != nil {
+		return "", false, err
+	}
+	binPath := filepath.Join(dir, buildsDir, key, binName)
+	if util.FileOrDirExists(binPath) {
+		if cur != key {
+			if err := c.setBuild(dir, key); err != nil {
+				return "", false, err
+			}
+		}
+		return binPath, true, nil
+	}
+	if legacyPath := filepath.Join(dir, binName); cur == "" && util.FileOrDirExists(legacyPath) {
+		return legacyPath, true, nil
+	}
+	return binPath, false, nil
+}
+
+// stageBuild creates a temporary directory in the tool directory dir to assemble a build in.
+// It is moved into place by commitBuild, the caller must remove it if the build fails.
+func (c *Cache) stageBuild(dir string) (string, error) {
+	builds := filepath.Join(dir, buildsDir)
+	if err := c.mkdirAll(builds); err != nil {
+		return "", errors.Wrapf(err, "failed to create directory %q", builds)
+	}
+	stage, err := ioutil.TempDir(builds, stagePrefix)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create temp directory")
+	}
+	// TempDir only gives the owner access, but other processes and users need to read the build
+	perm := dirPerm
+	if c.shared {
+		perm = sharedDirPerm
+	}
+	if err := os.Chmod(stage, perm); err != nil {
+		os.RemoveAll(stage)
+		return "", errors.Wrapf(err, "failed to set permissions of %q", stage)
+	}
+	return stage, nil
+}
+
+// commitBuild renames the build assembled in stage, see stageBuild, to the build key of the tool directory dir
+// and makes it the current build. binName is the name of the binary in the build. It returns the path of the binary.
+func (c *Cache) commitBuild(dir, stage, key, binName string) (string, error) {
+	dst := filepath.Join(dir, buildsDir, key)
+	binPath := filepath.Join(dst, binName)
+	err := os.Rename(stage, dst)
+	if err != nil && !util.FileOrDirExists(binPath) && util.FileOrDirExists(dst) {
+		// The build is missing its binary, ex: because it was deleted, or because it was built before the binary
+		// had a '.exe' extension on windows. Nothing can use it, so replace it.
+		c.logger.WithField("path", dst).Debug("build is missing its binary, replacing it")
+		// Published builds are read-only in shared mode
+		if rerr := makeWritable(dst); rerr != nil {
+			return "", errors.Wrapf(rerr, "failed to remove incomplete build %q", dst)
+		}
+		if rerr := os.RemoveAll(dst); rerr != nil {
+			return "", errors.Wrapf(rerr, "failed to remove incomplete build %q", dst)
+		}
+		err = os.Rename(stage, dst)
+	}
+	if err != nil {
+		// Builds with the same key are interchangeable, so if another one was put in place first, it is used instead
+		if !util.FileOrDirExists(binPath) {
+			return "", errors.Wrapf(err, "failed to move build to %q", dst)
+		}
+		c.logger.WithField("path", dst).Debug("build already exists, discarding new build")
+		os.RemoveAll(stage)
+	} else if err := c.publish(dst, binPath); err != nil {
+		return "", err
+	}
+	if err := c.setBuild(dir, key); err != nil {
+		return "", err
+	}
+	return binPath, nil
+}
+
+// setBuild makes key the current build of the tool directory dir. The build file is replaced
+// atomically, so running the tool at the same time always finds a complete build.
+func (c *Cache) setBuild(dir, key string) error {
+	return c.writeFileAtomic(filepath.Join(dir, BuildFileName), []byte(key+"\n"))
+}
+
+// ToolDir returns the absolute path of the directory where t is installed, which contains
+// its binary along with the files used to build it. See LayoutVersion for the contents.
+// The directory doesn't need to exist.
+func (c *Cache) ToolDir(t tool.Tool) (string, error) {
+	fp, err := t.Filepath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(c.toolsDir(), fp), nil
+}
@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"time"
+
+	"github.com/getshiphub/shed/internal/filelock"
+	"github.com/pkg/errors"
+)
+
+// ErrLocked is returned when the cache is being used by another shed process,
+// and waiting for it timed out or was cancelled.
+var ErrLocked = filelock.ErrLocked
+
+// Lock files in the cache directory, used to coordinate shed processes that share the cache.
+const (
+	// cacheLockFile is held shared while tools are added to the cache and
+	// exclusively while they are removed.
+	cacheLockFile = "lock"
+	// lockfilesLockFile is held while the lockfiles file is updated.
+	lockfilesLockFile = "lockfiles.lock"
+	// buildTimesLockFile is held while the build times file is updated.
+	buildTimesLockFile = "build-times.lock"
+	// toolLocksDir contains a lock file for each tool, held while the tool is installed.
+	toolLocksDir = "locks"
+)
+
+// WithLockTimeout sets how long to wait for another shed process using the cache before
+// giving up with ErrLocked. Install also stops waiting if its context is done.
+// A value of 0, the default, means wait until the other process is done.
+func WithLockTimeout(d time.Duration) Option {
+	return func(c *Cache) {
+		c.lockTimeout = d
+	}
+}
+
+// lock acquires the lock file name in the cache directory. If the lock is held by another process,
+// it waits until the lock is released, ctx is done, or the lock timeout is reached.
+// The returned function must be called to release the lock.
+func (c *Cache) lock(ctx context.Context, name string, shared bool) (func(), error) {
+	p := filepath.Join(c.rootDir, name)
+	if err := c.mkdirAll(filepath.Dir(p)); err != nil {
+		return nil, errors.Wrapf(err, "cache: failed to create directory %q", filepath.Dir(p))
+	}
+	if c.lockTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.lockTimeout)
+		defer cancel()
+	}
+	l, err := filelock.Acquire(ctx, p, filelock.Options{
+		Shared: shared,
+		OnWait: func() {
+			c.logger.Infof("Waiting for another shed process to finish using the cache %s", c.rootDir)
+		},
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "cache: failed to lock cache")
+	}
+	return func() {
+		if err := l.Unlock(); err != nil {
+			c.logger.WithError(err).Debugf("failed to unlock %s", p)
+		}
+	}, nil
+}
+
+// toolLockFile returns the name of the lock file for the tool with the given import path.
+func toolLockFile(importPath string) string {
+	// Hash the import path since it can contain characters that aren't valid in file names
+	sum := sha256.Sum256([]byte(importPath))
+	return filepath.Join(toolLocksDir, hex.EncodeToString(sum[:16]))
+}
@@ -0,0 +1,375 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/module"
+)
+
+// modulesDir is the name of the directory in the cache used as the module cache of the go command,
+// if WithModuleCache or WithIsolated is used.
+const modulesDir = "modules"
+
+// buildCacheDir is the name of the directory in the cache used as the build cache of the go command,
+// if WithIsolated is used.
+const buildCacheDir = "gocache"
+
+// WithModuleCache makes the cache use its own module cache for the go command, by setting GOMODCACHE,
+// instead of the module cache shared with everything else on the machine. This isolates the modules
+// of tools, so they can be pruned with PruneModules. By default the module cache of the go command is used.
+func WithModuleCache(enabled bool) Option {
+	return func(c *Cache) {
+		c.moduleCache = enabled
+	}
+}
+
+// WithIsolated makes the cache use its own module cache and build cache for the go command, by setting
+// GOMODCACHE and GOCACHE to directories in the cache. Nothing downloaded or compiled outside of the cache
+// is used to build tools, which makes builds hermetic at the cost of downloading and compiling everything
+// again. The module cache can be pruned like with WithModuleCache. It takes precedence over WithGoModCache
+// and WithGoBuildCache.
+func WithIsolated(enabled bool) Option {
+	return func(c *Cache) {
+		c.isolated = enabled
+	}
+}
+
+// WithGoModCache makes the go command use dir as its module cache, by setting GOMODCACHE, so that multiple
+// caches, ex: of different CI jobs, reuse the modules they download. dir must be an absolute path.
+// By default, the module cache of the go command is used. The cache doesn't manage dir, so it can't
+// be pruned with PruneModules. WithModuleCache takes precedence over it.
+func WithGoModCache(dir string) Option {
+	return func(c *Cache) {
+		c.goModCache = dir
+	}
+}
+
+// WithGoBuildCache makes the go command use dir as its build cache, by setting GOCACHE, so that multiple
+// caches reuse the packages they compile. dir must be an absolute path. By default, the build cache of
+// the go command is used.
+func WithGoBuildCache(dir string) Option {
+	return func(c *Cache) {
+		c.goBuildCache = dir
+	}
+}
+
+// ModuleCacheDir returns the directory used as the module cache of the go command.
+// It is empty if WithModuleCache or WithIsolated wasn't used, since the cache doesn't manage the module cache.
+func (c *Cache) ModuleCacheDir() string {
+	if !c.moduleCache && !c.isolated {
+		return ""
+	}
+	return filepath.Join(c.rootDir, modulesDir)
+}
+
+// BuildCacheDir returns the directory used as the build cache of the go command. It is empty if
+// the build cache of the go command is used, see WithIsolated and WithGoBuildCache.
+func (c *Cache) BuildCacheDir() string {
+	if c.isolated {
+		return filepath.Join(c.rootDir, buildCacheDir)
+	}
+	return c.goBuildCache
+}
+
+// goEnv returns the environment for the go command, adding env to the settings of the cache.
+func (c *Cache) goEnv(env []string) []string {
+	var cacheEnv []string
+	if dir := c.ModuleCacheDir(); dir != "" {
+		cacheEnv = append(cacheEnv, "GOMODCACHE="+dir)
+	} else if c.goModCache != "" {
+		cacheEnv = append(cacheEnv, "GOMODCACHE="+c.goModCache)
+	}
+	if dir := c.BuildCacheDir(); dir != "" {
+		cacheEnv = append(cacheEnv, "GOCACHE="+dir)
+	}
+	if len(cacheEnv) == 0 {
+		return env
+	}
+	// Put them first so they can still be overridden by InstallEnv
+	return append(cacheEnv, env...)
+}
+
+// withGOBIN returns a copy of env with GOBIN set to dir. It is put last so it can't be overridden by InstallEnv,
+// which makes sure the go command never installs binaries into the user's GOBIN, even if GOFLAGS or
+// the go version being used makes it install instead of only downloading or building.
+func withGOBIN(env []string, dir string) []string {
+	out := make([]string, len(env), len(env)+1)
+	copy(out, env)
+	return append(out, "GOBIN="+dir)
+}
+
+// GoEnv returns the values of the go environment variables vars, ex: GOBIN, in the same order,
+// as seen by the go command when installing tools.
+func (c *Cache) GoEnv(ctx context.Context, vars ...string) ([]string, error) {
+	return c.goClient.Env(ctx, c.goEnv(nil), vars...)
+}
+
+// PrunedModule is a module version removed by PruneModules.
+type PrunedModule struct {
+	Module module.Version
+	// Size is the size of the files of the module in bytes, including the downloaded
+	// archive and the extracted source.
+	Size int64
+}
+
+// ModulePruneResult describes what PruneModules removed.
+type ModulePruneResult struct {
+	// Removed are the module versions that were removed, sorted by path and version.
+	Removed []PrunedModule
+	// Reclaimed is the number of bytes freed by removing the modules.
+	Reclaimed int64
+}
+
+// PruneModules removes the module versions from the module cache that aren't needed to build the tools in keep.
+// The modules needed by a tool are the ones in the go.sum of its tool directory, which lists every module
+// used to build it, including the ones whose go.mod was only needed to resolve dependencies.
+// Tools in keep that aren't installed don't need any modules. If dryRun is true, the module versions
+// that would be removed are reported without removing them.
+//
+// It is an error to prune the module cache if WithModuleCache or WithIsolated wasn't used, since the
+// module cache of the go command is used by other programs.
+func (c *Cache) PruneModules(ctx context.Context, keep []tool.Tool, dryRun bool) (*ModulePruneResult, error) {
+	if c.ModuleCacheDir() == "" {
+		return nil, errors.New("cache: the module cache is not managed by the cache")
+	}
+	unlock, err := c.lock(ctx, cacheLockFile, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	kept := make(map[module.Version]bool)
+	for _, t := range keep {
+		dir, err := c.ToolDir(t)
+		if err != nil {
+			return nil, err
+		}
+		mods, err := readGoSumModules(filepath.Join(dir, "go.sum"), true)
+		if os.IsNotExist(errors.Cause(err)) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		for _, m := range mods {
+			kept[m] = true
+		}
+	}
+
+	// Files of each module version, along with their total size
+	type moduleFiles struct {
+		paths []string
+		size  int64
+	}
+	unused := make(map[module.Version]*moduleFiles)
+	add := func(m module.Version, p string, size int64) {
+		mf, ok := unused[m]
+		if !ok {
+			mf = &moduleFiles{}
+			unused[m] = mf
+		}
+		mf.paths = append(mf.paths, p)
+		mf.size += size
+	}
+
+	root := c.ModuleCacheDir()
+	// Downloaded files are stored as cache/download/MODULE/@v/VERSION.EXT
+	downloadDir := filepath.Join(root, "cache", "download")
+	err = filepath.Walk(downloadDir, func(p string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && p == downloadDir {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if p == filepath.Join(downloadDir, "sumdb") {
+			return filepath.SkipDir
+		}
+		if info.Name() != "@v" {
+			return nil
+		}
+		rel, err := filepath.Rel(downloadDir, filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		modPath, err := module.UnescapePath(filepath.ToSlash(rel))
+		if err != nil {
+			// Not a module, leave it alone
+			return filepath.SkipDir
+		}
+		entries, err := readDir(p)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			ext := filepath.Ext(e.Name())
+			if e.IsDir() || ext == "" || strings.HasPrefix(e.Name(), "list") {
+				continue
+			}
+			v, err := module.UnescapeVersion(strings.TrimSuffix(e.Name(), ext))
+			if err != nil {
+				continue
+			}
+			m := module.Version{Path: modPath, Version: v}
+			if !kept[m] {
+				add(m, filepath.Join(p, e.Name()), e.Size())
+			}
+		}
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "cache: failed to find modules in %q", downloadDir)
+	}
+
+	// Extracted source is stored as MODULE@VERSION
+	err = filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && p == root {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if p == filepath.Join(root, "cache") {
+			return filepath.SkipDir
+		}
+		i := strings.LastIndexByte(info.Name(), '@')
+		if i < 0 {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		escapedPath, escapedVersion := rel[:strings.LastIndexByte(rel, '@')], info.Name()[i+1:]
+		modPath, err := module.UnescapePath(escapedPath)
+		if err != nil {
+			return filepath.SkipDir
+		}
+		v, err := module.UnescapeVersion(escapedVersion)
+		if err != nil {
+			return filepath.SkipDir
+		}
+		m := module.Version{Path: modPath, Version: v}
+		if !kept[m] {
+			size, err := dirSize(p)
+			if err != nil {
+				return err
+			}
+			add(m, p, size)
+		}
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "cache: failed to find modules in %q", root)
+	}
+
+	res := &ModulePruneResult{}
+	for m, mf := range unused {
+		res.Removed = append(res.Removed, PrunedModule{Module: m, Size: mf.size})
+	}
+	sort.Slice(res.Removed, func(i, j int) bool {
+		a, b := res.Removed[i].Module, res.Removed[j].Module
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Version < b.Version
+	})
+	for _, pm := range res.Removed {
+		if !dryRun {
+			for _, p := range unused[pm.Module].paths {
+				// The go command makes extracted modules read-only
+				if err := makeWritable(p); err != nil {
+					return res, errors.Wrapf(err, "cache: failed to remove %q", p)
+				}
+				if err := os.RemoveAll(p); err != nil {
+					return res, errors.Wrapf(err, "cache: failed to remove %q", p)
+				}
+			}
+		}
+		c.logger.Debugf("pruned module %s@%s", pm.Module.Path, pm.Module.Version)
+		res.Reclaimed += pm.Size
+	}
+	return res, nil
+}
+
+// BuildModules returns the module versions whose source was used to build t, including the module of t,
+// sorted by path. They are read from the go.sum file in the tool directory of t, and modules that were only
+// needed for their go.mod are left out. If t is not installed, or is a release tool that wasn't built,
+// the cause of the error satisfies os.IsNotExist.
+func (c *Cache) BuildModules(t tool.Tool) ([]module.Version, error) {
+	dir, err := c.ToolDir(t)
+	if err != nil {
+		return nil, err
+	}
+	mods, err := readGoSumModules(filepath.Join(dir, "go.sum"), false)
+	if err != nil {
+		return nil, err
+	}
+	var built []module.Version
+	seen := make(map[module.Version]bool)
+	for _, m := range mods {
+		if seen[m] {
+			continue
+		}
+		seen[m] = true
+		built = append(built, m)
+	}
+	module.Sort(built)
+	return built, nil
+}
+
+// readGoSumModules returns the module versions in the go.sum file at p. If goMod is false,
+// modules that were only needed for their go.mod are left out. Modules can be listed more than once.
+func readGoSumModules(p string, goMod bool) ([]module.Version, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cache: failed to open file %q", p)
+	}
+	defer f.Close()
+	var mods []module.Version
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		// Modules that were only needed for their go.mod have a separate line
+		version := strings.TrimSuffix(fields[1], "/go.mod")
+		if version != fields[1] && !goMod {
+			continue
+		}
+		mods = append(mods, module.Version{Path: fields[0], Version: version})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errors.Wrapf(err, "cache: failed to read file %q", p)
+	}
+	return mods, nil
+}
+
+// dirSize returns the size of the files in dir in bytes.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/getshiphub/shed/github"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Prebuilt describes where prebuilt binaries of a tool built from a Go module are published.
+// URL, Asset, and Binary are templates like those of tool.Release. The repository of Asset
+// is the first three elements of the import path of the tool, which must be on github.com.
+type Prebuilt struct {
+	URL    string
+	Asset  string
+	Binary string
+	// Checksums is the URL, or the name of the asset if Asset is set, of a file containing
+	// the SHA-256 hashes of the assets in the format written by sha256sum.
+	Checksums string
+}
+
+// InstallPrebuilt makes Install download a prebuilt binary of the tool from p instead of building it,
+// if one is published for the version and platform being installed. The asset must be listed in the
+// checksums file of p, and its hash must match, otherwise a *ChecksumError is returned.
+// If no prebuilt binary is available, or it fails to download, the tool is built from source.
+// Tools with build flags are always built from source.
+func InstallPrebuilt(p Prebuilt) InstallOption {
+	return func(o *installOptions) {
+		o.prebuilt = &p
+	}
+}
+
+// installPrebuilt downloads the prebuilt binary of t from p and adds it to the tool directory dir as the build key,
+// standing in for building it. It returns false if no prebuilt binary is available, in which case the tool should
+// be built instead.
+func (c *Cache) installPrebuilt(ctx context.Context, t tool.Tool, p Prebuilt, dir, key string, o installOptions) (bool, error) {
+	logger := c.logger.WithField("tool", t)
+	goos, goarch, err := targetPlatform(t)
+	if err != nil {
+		return false, err
+	}
+	url, err := prebuiltURL(t, p, p.URL, p.Asset, goos, goarch)
+	if err != nil {
+		return false, err
+	}
+	sumsURL, err := prebuiltURL(t, p, p.Checksums, p.Checksums, goos, goarch)
+	if err != nil {
+		return false, err
+	}
+	rt := t
+	rt.Release = &tool.Release{Binary: p.Binary}
+	binary, err := rt.ReleaseBinary(goos, goarch)
+	if err != nil {
+		return false, err
+	}
+
+	o.report(StageDownload)
+	var sums bytes.Buffer
+	err = c.retry.Do(ctx, func() error {
+		sums.Reset()
+		_, err := c.fetch(ctx, sumsURL, &sums)
+		return err
+	}, func(attempt int, delay time.Duration, err error) {
+		logger.WithFields(logrus.Fields{
+			"url":     sumsURL,
+			"attempt": attempt,
+			"delay":   delay,
+		}).WithError(err).Debug("download of checksums failed with a transient error, retrying")
+	})
+	if err != nil {
+		logger.WithError(err).Debug("failed to download checksums of prebuilt binary, building from source")
+		return false, nil
+	}
+	sum, ok := github.FindChecksum(sums.Bytes(), assetName(url))
+	if !ok {
+		logger.WithField("url", sumsURL).Debugf("no checksum for %s, building from source", assetName(url))
+		return false, nil
+	}
+	want := "sha256:" + sum
+
+	stage, err := c.stageBuild(dir)
+	if err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(stage)
+	tmpBin, _, err := c.downloadAsset(ctx, t, stage, url, binary, "", func(sum string) error {
+		if sum != want {
+			return &ChecksumError{Tool: t, Path: url, Want: want, Got: sum}
+		}
+		return nil
+	})
+	var sumErr *ChecksumError
+	if errors.As(err, &sumErr) {
+		return false, err
+	}
+	if err != nil {
+		logger.WithError(err).Warn("failed to download prebuilt binary, building from source")
+		return false, nil
+	}
+	bfp, err := t.BinaryFilepath()
+	if err != nil {
+		return false, err
+	}
+	stageBin := filepath.Join(stage, filepath.Base(bfp))
+	if err := os.Rename(tmpBin, stageBin); err != nil {
+		return false, errors.Wrapf(err, "cache: failed to write binary %q", stageBin)
+	}
+	if err := writeBinarySum(stage, stageBin); err != nil {
+		return false, err
+	}
+	binPath, err := c.commitBuild(dir, stage, key, filepath.Base(bfp))
+	if err != nil {
+		return false, errors.WithMessagef(err, "failed to publish tool: %s", t)
+	}
+	logger.WithFields(logrus.Fields{
+		"path": binPath,
+		"url":  url,
+	}).Debug("downloaded prebuilt binary")
+	return true, nil
+}
+
+// prebuiltURL executes the template urlTmpl, or assetTmpl if p uses GitHub release assets,
+// and returns the URL of the file for the platform goos/goarch.
+func prebuiltURL(t tool.Tool, p Prebuilt, urlTmpl, assetTmpl, goos, goarch string) (string, error) {
+	rt := t
+	if p.URL != "" {
+		rt.Release = &tool.Release{URL: urlTmpl}
+		return rt.ReleaseURL(goos, goarch)
+	}
+	parts := strings.SplitN(t.ImportPath, "/", 4)
+	if len(parts) < 3 || parts[0] != "github.com" {
+		return "", errors.Errorf("cache: prebuilt binaries of %s can't use assets since it is not hosted on github.com", t.ImportPath)
+	}
+	rt.ImportPath = strings.Join(parts[:3], "/")
+	rt.Release = &tool.Release{Asset: assetTmpl}
+	return rt.ReleaseURL(goos, goarch)
+}
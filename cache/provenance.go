@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/module"
+)
+
+// provenanceFile is the name of the file in a tool directory that describes how the binary was built.
+// It is written when the tool is built.
+const provenanceFile = "provenance.json"
+
+// Provenance describes how the binary of a tool was built. It is recorded on the machine that built
+// the tool, so tools pulled from the remote cache have the provenance of the machine that pushed them.
+type Provenance struct {
+	// GoVersion is the version of the go command used to build the tool, ex: 'go1.21.0'.
+	GoVersion string `json:"goVersion"`
+	// GOOS and GOARCH are the platform the tool was built for.
+	GOOS   string `json:"goos"`
+	GOARCH string `json:"goarch"`
+	// Revision is the VCS revision of the module of the tool, ex: a git commit hash.
+	// It is empty if it is unknown, ex: because the module proxy didn't provide it.
+	Revision string `json:"revision,omitempty"`
+	// BuildFlags are the flags the tool was built with, see tool.BuildFlags.Args.
+	BuildFlags []string `json:"buildFlags,omitempty"`
+	// Env is the build environment of the tool, see tool.BuildFlags.EnvList.
+	Env []string `json:"env,omitempty"`
+	// Time is when the tool was built.
+	Time time.Time `json:"time"`
+}
+
+// writeProvenance records the provenance of t, which was just built in the tool directory dir
+// using env. Errors are logged instead of being returned, since the binary is still usable.
+func (c *Cache) writeProvenance(ctx context.Context, t tool.Tool, dir string, env []string) {
+	logger := c.logger.WithField("tool", t)
+	values, err := c.goClient.Env(ctx, env, "GOVERSION", "GOOS", "GOARCH", "GOMODCACHE")
+	if err != nil {
+		logger.WithError(err).Debug("failed to get build environment")
+		return
+	}
+	p := Provenance{
+		GoVersion:  values[0],
+		GOOS:       values[1],
+		GOARCH:     values[2],
+		BuildFlags: t.BuildFlags.Args(),
+		Env:        t.BuildFlags.EnvList(),
+		Time:       time.Now().UTC(),
+	}
+	if modPath, err := c.ModulePath(t); err == nil {
+		p.Revision = moduleRevision(values[3], module.Version{Path: modPath, Version: t.Version})
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		logger.WithError(err).Debug("failed to serialize provenance")
+		return
+	}
+	path := filepath.Join(dir, provenanceFile)
+	if err := ioutil.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		logger.WithError(err).Debugf("failed to write file %q", path)
+	}
+}
+
+// moduleRevision returns the VCS revision of m. The go command records where each module came from in the
+// module cache modCache, if the module proxy provides it. Otherwise the revision of a pseudo-version is used.
+// An empty string is returned if the revision is unknown.
+func moduleRevision(modCache string, m module.Version) string {
+	if modCache != "" {
+		if rev := readOriginHash(modCache, m); rev != "" {
+			return rev
+		}
+	}
+	if match := pseudoRevRE.FindStringSubmatch(strings.TrimSuffix(m.Version, "+incompatible")); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// pseudoRevRE matches the timestamp and revision at the end of a pseudo-version, see https://golang.org/ref/mod#pseudo-versions.
+var pseudoRevRE = regexp.MustCompile(`[-.]\d{14}-([0-9a-f]{12})$`)
+
+// readOriginHash returns the VCS hash recorded in the .info file of m in the module cache modCache.
+func readOriginHash(modCache string, m module.Version) string {
+	escPath, err := module.EscapePath(m.Path)
+	if err != nil {
+		return ""
+	}
+	escVersion, err := module.EscapeVersion(m.Version)
+	if err != nil {
+		return ""
+	}
+	data, err := ioutil.ReadFile(filepath.Join(modCache, "cache", "download", filepath.FromSlash(escPath), "@v", escVersion+".info"))
+	if err != nil {
+		return ""
+	}
+	var info struct {
+		Origin struct {
+			Hash string
+		}
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return ""
+	}
+	return info.Origin.Hash
+}
+
+// Provenance returns how the binary of t was built. If no provenance was recorded, ex: because t is a
+// release tool or was built before provenance was recorded, nil is returned. t must be installed.
+func (c *Cache) Provenance(t tool.Tool) (*Provenance, error) {
+	binPath, err := c.toolPath(t)
+	if err != nil {
+		return nil, err
+	}
+	p := filepath.Join(filepath.Dir(binPath), provenanceFile)
+	data, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "cache: failed to read file %q", p)
+	}
+	var prov Provenance
+	if err := json.Unmarshal(data, &prov); err != nil {
+		return nil, errors.Wrapf(err, "cache: failed to parse %q", p)
+	}
+	return &prov, nil
+}
@@ -0,0 +1,370 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// lockfilesFile is the name of the file in the cache directory that lists the lockfiles
+// whose tools have been installed in the cache, one absolute path per line.
+const lockfilesFile = "lockfiles"
+
+// RecordLockfile adds the lockfile at path to the lockfiles known to use the cache.
+// Tools used by known lockfiles are kept by Prune.
+func (c *Cache) RecordLockfile(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return errors.Wrapf(err, "cache: failed to get absolute path of %q", path)
+	}
+	unlock, err := c.lock(context.Background(), lockfilesLockFile, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	paths, err := c.Lockfiles()
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		if p == path {
+			return nil
+		}
+	}
+	return c.writeLockfiles(append(paths, path))
+}
+
+// Lockfiles returns the paths of the lockfiles known to use the cache, see RecordLockfile.
+// Lockfiles that have been deleted since they were recorded are included.
+func (c *Cache) Lockfiles() ([]string, error) {
+	p := filepath.Join(c.rootDir, lockfilesFile)
+	data, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "cache: failed to read file %q", p)
+	}
+	var paths []string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			paths = append(paths, line)
+		}
+	}
+	return paths, nil
+}
+
+func (c *Cache) writeLockfiles(paths []string) error {
+	sort.Strings(paths)
+	var sb strings.Builder
+	for _, p := range paths {
+		sb.WriteString(p + "\n")
+	}
+	return c.writeStateFile(lockfilesFile, []byte(sb.String()))
+}
+
+// writeStateFile writes data to the file name in the cache directory, which is shared by every
+// user of the cache. The data is written to a temp file that is renamed, so a concurrent read
+// never sees a partial file.
+func (c *Cache) writeStateFile(name string, data []byte) error {
+	return c.writeFileAtomic(filepath.Join(c.rootDir, name), data)
+}
+
+// writeFileAtomic writes data to the file at p in the cache, like writeStateFile.
+func (c *Cache) writeFileAtomic(p string, data []byte) error {
+	dir := filepath.Dir(p)
+	if err := c.mkdirAll(dir); err != nil {
+		return errors.Wrapf(err, "cache: failed to create directory %q", dir)
+	}
+	// Use a unique temp file since the file can be written concurrently
+	f, err := ioutil.TempFile(dir, filepath.Base(p)+".tmp*")
+	if err != nil {
+		return errors.Wrapf(err, "cache: failed to create temp file for %q", p)
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return errors.Wrapf(err, "cache: failed to write file %q", tmp)
+	}
+	var perm os.FileMode = 0o644
+	if c.shared {
+		// Other users need to write it too
+		perm = 0o664
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		os.Remove(tmp)
+		return errors.Wrapf(err, "cache: failed to set permissions of %q", tmp)
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		os.Remove(tmp)
+		return errors.Wrapf(err, "cache: failed to write file %q", p)
+	}
+	return nil
+}
+
+// ForgetLockfiles removes paths from the lockfiles known to use the cache.
+func (c *Cache) ForgetLockfiles(paths ...string) error {
+	unlock, err := c.lock(context.Background(), lockfilesLockFile, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	known, err := c.Lockfiles()
+	if err != nil {
+		return err
+	}
+	forget := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		forget[p] = true
+	}
+	var kept []string
+	for _, p := range known {
+		if !forget[p] {
+			kept = append(kept, p)
+		}
+	}
+	if len(kept) == len(known) {
+		return nil
+	}
+	return c.writeLockfiles(kept)
+}
+
+// PruneOptions controls which tools are removed by Prune.
+// If no limits are set, every tool that isn't kept is removed.
+type PruneOptions struct {
+	// MaxAge removes tools that were installed longer than MaxAge ago.
+	MaxAge time.Duration
+	// MaxSize removes tools, starting with the ones that were installed the longest ago,
+	// until the size of the cache in bytes is at most MaxSize.
+	MaxSize int64
+	// DryRun reports the tools that would be removed without removing them.
+	DryRun bool
+	// TrashTTL is how long removed tools are kept in the trash, where they can be brought back
+	// with Untrash, before they are purged. If 0, DefaultTrashTTL is used.
+	TrashTTL time.Duration
+	// SkipTrash deletes removed tools right away instead of moving them to the trash.
+	SkipTrash bool
+}
+
+// PrunedTool is a tool directory, or a build of a tool, removed by Prune.
+type PrunedTool struct {
+	// Path is the path of the tool directory or build directory.
+	Path string
+	// Size is the size of the files in the directory in bytes.
+	Size int64
+	// ModTime is when the tool was installed.
+	ModTime time.Time
+}
+
+// PruneResult describes what Prune removed.
+type PruneResult struct {
+	// Removed are the tool directories that were removed, oldest first, along with
+	// the builds of tools that were replaced by another build, see LayoutVersion.
+	Removed []PrunedTool
+	// Reclaimed is the number of bytes freed by removing the tools. Unless SkipTrash was set,
+	// the tools are only deleted from disk once they are purged from the trash.
+	Reclaimed int64
+	// Size is the size of the tools remaining in the cache in bytes, not including the trash.
+	Size int64
+	// Purged is the number of bytes deleted from disk by purging expired tools from the trash.
+	Purged int64
+}
+
+// toolEntry is a tool directory in the cache.
+type toolEntry struct {
+	// rel is the path of the directory relative to the tools directory.
+	rel     string
+	size    int64
+	modTime time.Time
+}
+
+// toolEntries returns every tool directory in the cache, including ones that were
+// downloaded but never built. The modification time of a directory is the latest
+// modification time of the files in it.
+func (c *Cache) toolEntries() ([]toolEntry, error) {
+	root := c.toolsDir()
+	var entries []toolEntry
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && p == root {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() || !strings.ContainsRune(info.Name(), '@') {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		e := toolEntry{rel: rel, modTime: info.ModTime()}
+		err = filepath.Walk(p, func(_ string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if fi.Mode().IsRegular() {
+				e.size += fi.Size()
+			}
+			if fi.ModTime().After(e.modTime) {
+				e.modTime = fi.ModTime()
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		entries = append(entries, e)
+		return filepath.SkipDir
+	})
+	return entries, err
+}
+
+// Prune removes tools from the cache that are not in keep, according to the limits in opts.
+// Tools in keep are never removed, even if the cache is larger than MaxSize. A tool is only
+// removed as a whole, so the files of partially installed tools are removed as well. The builds
+// of the remaining tools that were replaced by another build, see LayoutVersion, are removed
+// unless they are newer than MaxAge.
+//
+// Removed tools are moved to the trash, unless opts.SkipTrash is set, and can be brought back with
+// Untrash until they are purged. Each prune purges the tools that have been in the trash for longer
+// than opts.TrashTTL. The trash doesn't count towards MaxSize.
+//
+// Prune waits for other shed processes that are installing tools to finish first, since the
+// tools they install may not be in keep, unless ctx is done. The module cache is not changed,
+// see PruneModules.
+func (c *Cache) Prune(ctx context.Context, keep []tool.Tool, opts PruneOptions) (*PruneResult, error) {
+	unlock, err := c.lock(ctx, cacheLockFile, opts.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	kept := make(map[string]bool, len(keep))
+	for _, t := range keep {
+		fp, err := t.Filepath()
+		if err != nil {
+			return nil, err
+		}
+		kept[fp] = true
+	}
+	entries, err := c.toolEntries()
+	if err != nil {
+		return nil, errors.Wrapf(err, "cache: failed to find tools in %q", c.toolsDir())
+	}
+	// Remove the oldest tools first, so that the most recently installed are kept when limiting size
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+
+	res := &PruneResult{}
+	for _, e := range entries {
+		res.Size += e.size
+	}
+	now := time.Now()
+	trash := filepath.Join(c.trashDir(), strconv.FormatInt(now.Unix(), 10))
+	noLimits := opts.MaxAge <= 0 && opts.MaxSize <= 0
+	for _, e := range entries {
+		expired := opts.MaxAge > 0 && now.Sub(e.modTime) > opts.MaxAge
+		tooBig := opts.MaxSize > 0 && res.Size > opts.MaxSize
+		if kept[e.rel] || (!noLimits && !expired && !tooBig) {
+			builds, err := c.pruneBuilds(e.rel, opts, now)
+			if err != nil {
+				return res, err
+			}
+			for _, b := range builds {
+				res.Removed = append(res.Removed, b)
+				res.Reclaimed += b.Size
+				res.Size -= b.Size
+			}
+			continue
+		}
+		dir := filepath.Join(c.toolsDir(), e.rel)
+		if !opts.DryRun {
+			var err error
+			if opts.SkipTrash {
+				err = c.removeToolDir(dir)
+			} else {
+				err = c.trashToolDir(trash, e.rel)
+			}
+			if err != nil {
+				return res, err
+			}
+		}
+		c.logger.Debugf("pruned %s", dir)
+		res.Removed = append(res.Removed, PrunedTool{Path: dir, Size: e.size, ModTime: e.modTime})
+		res.Reclaimed += e.size
+		res.Size -= e.size
+	}
+	ttl := opts.TrashTTL
+	if ttl <= 0 {
+		ttl = DefaultTrashTTL
+	}
+	res.Purged, err = c.purgeTrash(ttl, opts.DryRun)
+	return res, err
+}
+
+// pruneBuilds removes the builds of the tool directory rel, relative to the tools directory, that have been
+// replaced by another build, ex: because the tool was built again with a newer version of Go. If opts.MaxAge
+// is set, only builds older than it are removed. Builds are deleted right away instead of being moved to
+// the trash. The caller must hold the cache lock exclusively.
+func (c *Cache) pruneBuilds(rel string, opts PruneOptions, now time.Time) ([]PrunedTool, error) {
+	dir := filepath.Join(c.toolsDir(), rel)
+	cur, err := readBuild(dir)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := ioutil.ReadDir(filepath.Join(dir, buildsDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "cache: failed to read builds of %q", dir)
+	}
+	var pruned []PrunedTool
+	for _, info := range infos {
+		// Nothing is being built while the lock is held, so unfinished builds are leftovers from failed installs
+		unfinished := strings.HasPrefix(info.Name(), stagePrefix)
+		if !info.IsDir() || info.Name() == cur || (!unfinished && opts.MaxAge > 0 && now.Sub(info.ModTime()) <= opts.MaxAge) {
+			continue
+		}
+		p := filepath.Join(dir, buildsDir, info.Name())
+		size, err := dirSize(p)
+		if err != nil {
+			return pruned, errors.Wrapf(err, "cache: failed to remove %q", p)
+		}
+		if !opts.DryRun {
+			if err := c.removeToolDir(p); err != nil {
+				return pruned, err
+			}
+		}
+		c.logger.Debugf("pruned %s", p)
+		pruned = append(pruned, PrunedTool{Path: p, Size: size, ModTime: info.ModTime()})
+	}
+	return pruned, nil
+}
+
+// removeToolDir removes the tool directory dir, along with any parent directories that are left empty.
+func (c *Cache) removeToolDir(dir string) error {
+	// Published tools are read-only in shared mode
+	if err := makeWritable(dir); err != nil {
+		return errors.Wrapf(err, "cache: failed to remove %q", dir)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return errors.Wrapf(err, "cache: failed to remove %q", dir)
+	}
+	removeEmptyParents(filepath.Dir(dir), c.toolsDir())
+	return nil
+}
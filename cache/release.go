@@ -0,0 +1,359 @@
+package cache
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/getshiphub/shed/internal/ratelimit"
+	"github.com/getshiphub/shed/internal/retry"
+	"github.com/getshiphub/shed/internal/util"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// assetSumFile is the name of the file in the directory of a release tool that contains
+// the hash of the asset the binary was extracted from.
+const assetSumFile = "asset.sum"
+
+// WithHTTPClient sets the HTTP client used to download the assets of release tools.
+// By default http.DefaultClient is used.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Cache) {
+		c.httpClient = client
+	}
+}
+
+// WithDownloadLimiter limits the rate that the assets of release tools and prebuilt binaries are
+// downloaded to l, which can be shared with other downloads. A nil l, the default, means no limit.
+func WithDownloadLimiter(l *ratelimit.Limiter) Option {
+	return func(c *Cache) {
+		c.downloadLimiter = l
+	}
+}
+
+// installRelease downloads the release asset of t for its platform and extracts the binary.
+// If t has a hash for the platform, the asset must match it, otherwise a *ChecksumError is returned.
+// The returned tool has the hash of the asset recorded for the platform.
+func (c *Cache) installRelease(ctx context.Context, t tool.Tool, o installOptions) (tool.Tool, error) {
+	if !t.HasSemver() {
+		return t, errors.Errorf("release tool %s must have an exact version", t)
+	}
+	goos, goarch, err := targetPlatform(t)
+	if err != nil {
+		return t, err
+	}
+	platform := tool.Platform(goos, goarch)
+	fp, err := t.Filepath()
+	if err != nil {
+		return t, err
+	}
+	dir := filepath.Join(c.toolsDir(), fp)
+	binPath, err := c.BinaryPath(t)
+	if err != nil {
+		return t, err
+	}
+
+	var sum string
+	source := SourceRelease
+	if util.FileOrDirExists(binPath) {
+		source = SourceCache
+		c.logger.WithFields(logrus.Fields{
+			"tool": t,
+			"path": binPath,
+		}).Debug("tool binary already exists, skipping download")
+		data, err := ioutil.ReadFile(filepath.Join(dir, assetSumFile))
+		if err != nil {
+			return t, errors.Wrapf(err, "cache: failed to read hash of asset of %s", t)
+		}
+		sum = strings.TrimSpace(string(data))
+	} else {
+		o.report(StageDownload)
+		key := buildKey(fp, goos, goarch, "")
+		if sum, err = c.downloadRelease(ctx, t, dir, key, filepath.Base(binPath), o.vendorDir); err != nil {
+			return t, downloadError(t, err)
+		}
+	}
+	if err := checkAssetSum(t, sum); err != nil {
+		return t, err
+	}
+	// Copy the release since it is shared with the tool that was passed in
+	r := *t.Release
+	r.Sums = make(map[string]string, len(t.Release.Sums)+1)
+	for p, s := range t.Release.Sums {
+		r.Sums[p] = s
+	}
+	r.Sums[platform] = sum
+	t.Release = &r
+	o.reportSource(source)
+	return t, nil
+}
+
+// downloadRelease downloads the release asset of t and extracts the binary, named binName, to the build key
+// of the tool directory dir. It returns the hash of the asset. If it fails, dir is left without a binary,
+// so the download is attempted again the next time the tool is installed. If vendorDir is set, the asset
+// is read from it instead, see InstallOffline.
+func (c *Cache) downloadRelease(ctx context.Context, t tool.Tool, dir, key, binName, vendorDir string) (string, error) {
+	goos, goarch, err := targetPlatform(t)
+	if err != nil {
+		return "", err
+	}
+	url, err := t.ReleaseURL(goos, goarch)
+	if err != nil {
+		return "", err
+	}
+	binary, err := t.ReleaseBinary(goos, goarch)
+	if err != nil {
+		return "", err
+	}
+	stage, err := c.stageBuild(dir)
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(stage)
+	tmpBin, sum, err := c.downloadAsset(ctx, t, stage, url, binary, vendorDir, func(sum string) error {
+		return checkAssetSum(t, sum)
+	})
+	if err != nil {
+		return "", err
+	}
+	stageBin := filepath.Join(stage, binName)
+	if err := os.Rename(tmpBin, stageBin); err != nil {
+		return "", errors.Wrapf(err, "cache: failed to write binary %q", stageBin)
+	}
+	if err := writeBinarySum(stage, stageBin); err != nil {
+		return "", err
+	}
+	// The build is committed last, since the tool is treated as installed once it is in place
+	p := filepath.Join(dir, assetSumFile)
+	if err := ioutil.WriteFile(p, []byte(sum+"\n"), 0o644); err != nil {
+		return "", errors.Wrapf(err, "failed to write file %q", p)
+	}
+	if _, err := c.commitBuild(dir, stage, key, binName); err != nil {
+		return "", errors.WithMessagef(err, "failed to publish tool: %s", t)
+	}
+	return sum, nil
+}
+
+// downloadAsset downloads the asset at url to the tool directory dir of t and extracts the binary in it,
+// see extractBinary. If vendorDir is set, the asset is read from it instead of being downloaded.
+// check is called with the hash of the asset before the binary is extracted.
+// It returns the path of an executable temp file in dir containing the binary, which the caller
+// must rename or remove, and the hash of the asset.
+func (c *Cache) downloadAsset(ctx context.Context, t tool.Tool, dir, url, binary, vendorDir string, check func(sum string) error) (string, string, error) {
+	if err := c.mkdirAll(dir); err != nil {
+		return "", "", errors.Wrapf(err, "cache: failed to create directory %q", dir)
+	}
+
+	asset, err := ioutil.TempFile(dir, "asset-")
+	if err != nil {
+		return "", "", errors.Wrap(err, "cache: failed to create temp file")
+	}
+	defer os.Remove(asset.Name())
+	defer asset.Close()
+	var sum string
+	err = c.retry.Do(ctx, func() error {
+		// Start over if a previous attempt wrote part of the asset
+		if err := asset.Truncate(0); err != nil {
+			return errors.Wrapf(err, "cache: failed to truncate %q", asset.Name())
+		}
+		if _, err := asset.Seek(0, io.SeekStart); err != nil {
+			return errors.Wrapf(err, "cache: failed to seek %q", asset.Name())
+		}
+		var err error
+		if vendorDir != "" {
+			sum, err = readVendoredAsset(vendorDir, url, asset)
+		} else {
+			sum, err = c.fetch(ctx, url, asset)
+		}
+		return err
+	}, func(attempt int, delay time.Duration, err error) {
+		c.logger.WithFields(logrus.Fields{
+			"url":     url,
+			"attempt": attempt,
+			"delay":   delay,
+		}).WithError(err).Infof("download of %s failed with a transient error, retrying", t)
+	})
+	if err != nil {
+		return "", "", err
+	}
+	c.logger.WithFields(logrus.Fields{
+		"tool": t,
+		"url":  url,
+		"sum":  sum,
+	}).Debug("downloaded asset")
+	// Check before extracting so the binary of an unexpected asset never ends up in the cache
+	if err := check(sum); err != nil {
+		return "", "", err
+	}
+
+	// Extract to a temp file first so a partial binary is never used
+	tmpBin, err := ioutil.TempFile(dir, "bin-")
+	if err != nil {
+		return "", "", errors.Wrap(err, "cache: failed to create temp file")
+	}
+	// Only remove the binary if it isn't returned
+	binName := tmpBin.Name()
+	defer func() {
+		if err != nil {
+			os.Remove(binName)
+		}
+	}()
+	defer tmpBin.Close()
+	if err = extractBinary(asset, assetName(url), binary, path.Base(t.ImportPath), tmpBin); err != nil {
+		return "", "", errors.WithMessagef(err, "cache: failed to extract binary from %s", url)
+	}
+	if err = tmpBin.Close(); err != nil {
+		return "", "", errors.Wrapf(err, "cache: failed to write binary %q", binName)
+	}
+	if err = os.Chmod(binName, 0o755); err != nil {
+		return "", "", errors.Wrapf(err, "cache: failed to make binary %q executable", binName)
+	}
+	return binName, sum, nil
+}
+
+// targetPlatform returns the GOOS and GOARCH that t is installed for,
+// which is the current platform unless t.Platform is set.
+func targetPlatform(t tool.Tool) (goos, goarch string, err error) {
+	if t.Platform == "" {
+		return runtime.GOOS, runtime.GOARCH, nil
+	}
+	return tool.ParsePlatform(t.Platform)
+}
+
+// checkAssetSum checks that the hash of the asset of t for its platform is sum, if t has one.
+func checkAssetSum(t tool.Tool, sum string) error {
+	goos, goarch, err := targetPlatform(t)
+	if err != nil {
+		return err
+	}
+	want := t.Release.Sums[tool.Platform(goos, goarch)]
+	if want == "" || want == sum {
+		return nil
+	}
+	url, err := t.ReleaseURL(goos, goarch)
+	if err != nil {
+		return err
+	}
+	return &ChecksumError{Tool: t, Path: url, Want: want, Got: sum}
+}
+
+// fetch downloads url to w and returns the hash of the contents in the form 'sha256:HEX'.
+func (c *Cache) fetch(ctx context.Context, url string, w io.Writer) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", errors.Wrapf(err, "cache: failed to create request for %s", url)
+	}
+	client := c.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", errors.Wrapf(err, "cache: failed to download %s", url)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		err := errors.Errorf("cache: failed to download %s: %s", url, res.Status)
+		if res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500 {
+			err = retry.Transient(err)
+		}
+		return "", err
+	}
+	h := sha256.New()
+	body := ratelimit.NewReader(ctx, res.Body, c.downloadLimiter)
+	if _, err := io.Copy(io.MultiWriter(w, h), body); err != nil {
+		return "", errors.Wrapf(err, "cache: failed to download %s", url)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// assetName returns the file name of the asset at url, without any query or fragment.
+func assetName(url string) string {
+	if i := strings.IndexAny(url, "?#"); i != -1 {
+		url = url[:i]
+	}
+	return path.Base(url)
+}
+
+// extractBinary writes the binary in the asset f with the file name name to w. Archives are detected by the
+// extension of name. If binary is empty, the binary is the file named binName, or binName.exe, in the archive.
+// Any other asset is copied as is.
+func extractBinary(f *os.File, name, binary, binName string, w io.Writer) error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	matches := func(p string) bool {
+		p = path.Clean(p)
+		if binary != "" {
+			return p == path.Clean(binary)
+		}
+		base := path.Base(p)
+		return base == binName || base == binName+".exe"
+	}
+	notFound := func() error {
+		if binary != "" {
+			return errors.Errorf("%s does not contain %s", name, binary)
+		}
+		return errors.Errorf("%s does not contain a file named %s", name, binName)
+	}
+
+	switch {
+	case strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz"):
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return errors.Wrapf(err, "invalid archive %s", name)
+		}
+		tr := tar.NewReader(gr)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return notFound()
+			}
+			if err != nil {
+				return errors.Wrapf(err, "invalid archive %s", name)
+			}
+			if hdr.Typeflag == tar.TypeReg && matches(hdr.Name) {
+				_, err := io.Copy(w, tr)
+				return err
+			}
+		}
+	case strings.HasSuffix(name, ".zip"):
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		zr, err := zip.NewReader(f, info.Size())
+		if err != nil {
+			return errors.Wrapf(err, "invalid archive %s", name)
+		}
+		for _, zf := range zr.File {
+			if !zf.Mode().IsRegular() || !matches(zf.Name) {
+				continue
+			}
+			r, err := zf.Open()
+			if err != nil {
+				return errors.Wrapf(err, "invalid archive %s", name)
+			}
+			defer r.Close()
+			_, err = io.Copy(w, r)
+			return err
+		}
+		return notFound()
+	}
+	_, err := io.Copy(w, f)
+	return err
+}
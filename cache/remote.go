@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+
+	"github.com/getshiphub/shed/internal/util"
+	"github.com/getshiphub/shed/remote"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// WithRemote sets a backend that built tools are shared through, so a tool only needs to be built
+// on one machine, ex: a CI job. Before a tool is downloaded and built, it is pulled from b if it is there,
+// and after it is built, it is pushed to b unless readOnly is true. Tools are identified by their import
+// path, version, and build flags, along with the GOOS, GOARCH, and version of the go command used to build them.
+// Release tools aren't shared since they are downloaded instead of built.
+//
+// The remote cache is only an optimization, so errors using it are logged instead of failing the install.
+// Tools pulled from b are trusted, so only use a backend that only trusted machines can push to.
+func WithRemote(b remote.Backend, readOnly bool) Option {
+	return func(c *Cache) {
+		c.remote = b
+		c.remoteReadOnly = readOnly
+	}
+}
+
+// remoteKey returns the key used to store the tool directory fp of t in the remote cache,
+// for the build environment of env.
+func (c *Cache) remoteKey(ctx context.Context, t tool.Tool, fp string, env []string) (string, error) {
+	values, err := c.goClient.Env(ctx, env, "GOOS", "GOARCH", "GOVERSION")
+	if err != nil {
+		return "", errors.WithMessage(err, "failed to get build environment")
+	}
+	if t.Reproducible != nil {
+		// Like the build key, so reproducible builds are stored separately
+		values[2] += reproducibleKeySuffix
+	}
+	// Hash the parts so the key is valid for every backend, ex: OCI tags can't contain '@'
+	h := sha256.Sum256([]byte(strings.Join(append([]string{filepath.ToSlash(fp)}, values...), "\x00")))
+	return "tools/" + hex.EncodeToString(h[:]) + ".tar.gz", nil
+}
+
+// pullTool adds the tool directory of t from the remote cache to the cache, if it is there and the tool
+// directory doesn't exist yet, and reports whether it did. t must have an exact version.
+// Errors are logged instead of being returned.
+func (c *Cache) pullTool(ctx context.Context, t tool.Tool, o installOptions) bool {
+	logger := c.logger.WithField("tool", t)
+	fp, err := t.Filepath()
+	if err != nil {
+		logger.WithError(err).Debug("failed to get path of tool")
+		return false
+	}
+	if util.FileOrDirExists(filepath.Join(c.toolsDir(), fp)) {
+		return false
+	}
+	key, err := c.remoteKey(ctx, t, fp, o.env)
+	if err != nil {
+		logger.WithError(err).Debug("failed to get remote cache key of tool")
+		return false
+	}
+	logger = logger.WithField("key", key)
+	rc, err := c.remote.Get(ctx, key)
+	if errors.Is(err, remote.ErrNotFound) {
+		logger.Debug("tool not found in remote cache")
+		return false
+	}
+	if err != nil {
+		logger.WithError(err).Debug("failed to get tool from remote cache")
+		return false
+	}
+	defer rc.Close()
+	o.report(StageDownload)
+	n, err := c.importArchive(rc, filepath.FromSlash(fp))
+	if err != nil {
+		logger.WithError(err).Debug("failed to import tool from remote cache")
+		return false
+	}
+	if n == 0 {
+		logger.Debug("tool from remote cache was not imported")
+		return false
+	}
+	logger.Debug("pulled tool from remote cache")
+	return true
+}
+
+// pushTool uploads the tool directory of t to the remote cache.
+// Like pullTool, errors are logged instead of being returned.
+func (c *Cache) pushTool(ctx context.Context, t tool.Tool, o installOptions) {
+	logger := c.logger.WithField("tool", t)
+	fp, err := t.Filepath()
+	if err != nil {
+		logger.WithError(err).Debug("failed to get path of tool")
+		return
+	}
+	key, err := c.remoteKey(ctx, t, fp, o.env)
+	if err != nil {
+		logger.WithError(err).Debug("failed to get remote cache key of tool")
+		return
+	}
+	logger = logger.WithField("key", key)
+	var buf bytes.Buffer
+	if err := exportDirs(&buf, c.toolsDir(), []string{filepath.Join(c.toolsDir(), fp)}); err != nil {
+		logger.WithError(err).Debug("failed to export tool")
+		return
+	}
+	if err := c.remote.Put(ctx, key, &buf); err != nil {
+		logger.WithError(err).Debug("failed to push tool to remote cache")
+		return
+	}
+	logger.Debug("pushed tool to remote cache")
+}
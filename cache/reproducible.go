@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// reproducibleKeySuffix is added to the version of Go in the build key of reproducible builds.
+const reproducibleKeySuffix = "+reproducible"
+
+// reproducibleEnv returns the environment to build a reproducible tool with. GOTOOLCHAIN makes the go command
+// download and use exactly the version of Go of the tool, the same way as golang.org/dl but without installing
+// another go command. GOFLAGS is cleared so flags from the environment can't change the binary, and cgo is
+// disabled since it makes the binary depend on the C toolchain of the machine.
+func reproducibleEnv(r *tool.Reproducible) []string {
+	return []string{"GOTOOLCHAIN=" + r.GoVersion, "GOFLAGS=", "CGO_ENABLED=0"}
+}
+
+// reproducibleBuildFlags returns the build flags of t, which is built reproducibly. File system paths
+// and VCS information are left out of the binary, since they depend on the machine it was built on.
+func reproducibleBuildFlags(t tool.Tool) []string {
+	flags := t.BuildFlags
+	flags.Trimpath = true
+	return append(flags.Args(), "-buildvcs=false")
+}
+
+// checkToolchainVersion checks that the go command used goVersion, the version it reported with
+// GOTOOLCHAIN set, to build the reproducible tool t.
+func checkToolchainVersion(t tool.Tool, goVersion string) error {
+	if goVersion == t.Reproducible.GoVersion {
+		return nil
+	}
+	return errors.Errorf("cache: go command is %s, not %s needed to build %s reproducibly; go1.21.0 or newer is needed to switch versions of Go", goVersion, t.Reproducible.GoVersion, t)
+}
+
+// checkBinarySum checks that the binary at binPath has the hash sum recorded for the platform of t,
+// if t has one. It returns t with the hash recorded for the platform.
+func checkBinarySum(t tool.Tool, platform, binPath, sum string) (tool.Tool, error) {
+	if want := t.Reproducible.Sums[platform]; want != "" && want != sum {
+		return t, &ChecksumError{Tool: t, Path: binPath, Want: want, Got: sum}
+	}
+	// Copy since it is shared with the tool that was passed in
+	r := *t.Reproducible
+	r.Sums = make(map[string]string, len(t.Reproducible.Sums)+1)
+	for p, s := range t.Reproducible.Sums {
+		r.Sums[p] = s
+	}
+	r.Sums[platform] = sum
+	t.Reproducible = &r
+	return t, nil
+}
@@ -0,0 +1,234 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/getshiphub/shed/internal/util"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// scriptsDir is the name of the directory in the cache where Go scripts are built.
+const scriptsDir = "scripts"
+
+// scriptModule is the module path of the throwaway module that scripts are built in.
+const scriptModule = "shed.local/script"
+
+// ErrScriptRequires is returned by BuildScript when a script needs modules that weren't provided,
+// or needs other versions of them. This means the script changed since its modules were resolved.
+var ErrScriptRequires = errors.New("cache: script requires modules that are not locked")
+
+// ResolveScript finds the modules needed to build the Go script src, which must be a single file
+// main package, like 'go mod tidy' does. The latest version of each module is used.
+// The modules are sorted by path. Only InstallEnv is used from opts.
+//
+// The provided context is used to terminate the resolution if the context becomes
+// done before it completes on its own.
+func (c *Cache) ResolveScript(ctx context.Context, src []byte, opts ...InstallOption) ([]module.Version, error) {
+	var o installOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.env = c.goEnv(o.env)
+	tmpDir, err := c.scriptModuleDir(src, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := c.goClient.ModTidy(ctx, tmpDir, withGOBIN(o.env, tmpDir)); err != nil {
+		return nil, errors.WithMessage(err, "cache: failed to resolve modules of script")
+	}
+	return readRequires(filepath.Join(tmpDir, "go.mod"))
+}
+
+// BuildScript builds the Go script src, which must be a single file main package, with the modules
+// in requires, and returns the path to the binary. Built scripts are stored by the hash of src and
+// requires, so a script is only built again when it or its modules change. If the script needs
+// modules that aren't in requires, or other versions of them, the error matches ErrScriptRequires,
+// and the modules should be resolved again with ResolveScript. Only InstallEnv is used from opts.
+//
+// The provided context is used to terminate the build if the context becomes
+// done before the build completes on its own.
+func (c *Cache) BuildScript(ctx context.Context, src []byte, requires []module.Version, opts ...InstallOption) (string, error) {
+	var o installOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.env = c.goEnv(o.env)
+	key := scriptKey(src, requires)
+	dir := filepath.Join(c.rootDir, scriptsDir, key)
+	binName := "script"
+	if runtime.GOOS == "windows" {
+		binName += ".exe"
+	}
+	binPath := filepath.Join(dir, binName)
+
+	unlockCache, err := c.lock(ctx, cacheLockFile, true)
+	if err != nil {
+		return "", err
+	}
+	defer unlockCache()
+	unlockScript, err := c.lock(ctx, toolLockFile(scriptModule+"/"+key), false)
+	if err != nil {
+		return "", err
+	}
+	defer unlockScript()
+	if util.FileOrDirExists(binPath) {
+		c.logger.WithField("path", binPath).Debug("script binary already exists, skipping build")
+		return binPath, nil
+	}
+
+	tmpDir, err := c.scriptModuleDir(src, requires)
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+	tmpBin := filepath.Join(tmpDir, binName)
+	// -mod=mod lets the go command fill in go.sum, any missing modules are caught below
+	err = c.goClient.Build(ctx, ".", tmpBin, tmpDir, []string{"-mod=mod"}, withGOBIN(o.env, tmpDir))
+	if err != nil {
+		return "", errors.WithMessage(err, "cache: failed to build script")
+	}
+	built, err := readRequires(filepath.Join(tmpDir, "go.mod"))
+	if err != nil {
+		return "", err
+	}
+	if diff := diffRequires(requires, built); len(diff) > 0 {
+		return "", errors.Wrapf(ErrScriptRequires, "script also requires %s", strings.Join(diff, ", "))
+	}
+
+	if err := c.mkdirAll(dir); err != nil {
+		return "", errors.Wrapf(err, "cache: failed to create directory %q", dir)
+	}
+	if err := os.Rename(tmpBin, binPath); err != nil {
+		return "", errors.Wrapf(err, "cache: failed to move script binary to %q", binPath)
+	}
+	c.logger.WithField("path", binPath).Debug("script built")
+	return binPath, nil
+}
+
+// scriptModuleDir creates a temporary module in the cache containing the script src as main.go
+// and requiring requires. The caller must remove the directory.
+func (c *Cache) scriptModuleDir(src []byte, requires []module.Version) (string, error) {
+	if err := c.mkdirAll(c.rootDir); err != nil {
+		return "", errors.Wrapf(err, "cache: failed to create directory %q", c.rootDir)
+	}
+	tmpDir, err := ioutil.TempDir(c.rootDir, "tmp-")
+	if err != nil {
+		return "", errors.Wrap(err, "cache: failed to create temp directory")
+	}
+	if err := createGoModFile(scriptModule, tmpDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", err
+	}
+	if err := addRequires(filepath.Join(tmpDir, "go.mod"), requires); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", err
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, "main.go"), stripBuildConstraints(src), 0o644); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", errors.Wrap(err, "cache: failed to write script")
+	}
+	return tmpDir, nil
+}
+
+// stripBuildConstraints removes the build constraints from src. Scripts usually have an 'ignore'
+// constraint so they aren't part of any package in the project, which would stop them from building.
+func stripBuildConstraints(src []byte) []byte {
+	lines := strings.SplitAfter(string(src), "\n")
+	var b strings.Builder
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "package ") {
+			// Constraints can only appear before the package clause
+			b.WriteString(strings.Join(lines[i:], ""))
+			break
+		}
+		if strings.HasPrefix(trimmed, "//go:build") || strings.HasPrefix(trimmed, "// +build") {
+			continue
+		}
+		b.WriteString(line)
+	}
+	return []byte(b.String())
+}
+
+// addRequires adds requires to the go.mod file at modfilePath.
+func addRequires(modfilePath string, requires []module.Version) error {
+	if len(requires) == 0 {
+		return nil
+	}
+	data, err := ioutil.ReadFile(modfilePath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read file %q", modfilePath)
+	}
+	modFile, err := modfile.Parse(modfilePath, data, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse go.mod file %q", modfilePath)
+	}
+	for _, mod := range requires {
+		modFile.AddNewRequire(mod.Path, mod.Version, false)
+	}
+	data, err = modFile.Format()
+	if err != nil {
+		return errors.Wrapf(err, "failed to update go.mod file %q", modfilePath)
+	}
+	if err := ioutil.WriteFile(modfilePath, data, 0o644); err != nil {
+		return errors.Wrapf(err, "failed to write file %q", modfilePath)
+	}
+	return nil
+}
+
+// readRequires returns all the modules required by the go.mod file at modfilePath sorted by path.
+func readRequires(modfilePath string) ([]module.Version, error) {
+	data, err := ioutil.ReadFile(modfilePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read file %q", modfilePath)
+	}
+	modFile, err := modfile.Parse(modfilePath, data, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse go.mod file %q", modfilePath)
+	}
+	var requires []module.Version
+	for _, r := range modFile.Require {
+		requires = append(requires, r.Mod)
+	}
+	sort.Slice(requires, func(i, j int) bool {
+		return requires[i].Path < requires[j].Path
+	})
+	return requires, nil
+}
+
+// diffRequires returns the modules in got that aren't in want with the same version.
+func diffRequires(want, got []module.Version) []string {
+	versions := make(map[string]string, len(want))
+	for _, mod := range want {
+		versions[mod.Path] = mod.Version
+	}
+	var diff []string
+	for _, mod := range got {
+		if versions[mod.Path] != mod.Version {
+			diff = append(diff, mod.String())
+		}
+	}
+	return diff
+}
+
+// scriptKey returns the key a script is stored under in the cache, which is a hash of its source and modules.
+func scriptKey(src []byte, requires []module.Version) string {
+	h := sha256.New()
+	h.Write(src)
+	for _, mod := range requires {
+		fmt.Fprintf(h, "\n%s %s", mod.Path, mod.Version)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
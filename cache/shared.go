@@ -0,0 +1,306 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/getshiphub/shed/internal/util"
+	"github.com/pkg/errors"
+)
+
+// Permissions used for the cache. In shared mode, everything is group writable until it
+// is published so that any user in the group can install tools. Directories also have
+// the setgid bit set so that new files inherit the group of the cache.
+const (
+	dirPerm        os.FileMode = 0o755
+	sharedDirPerm              = os.ModeSetgid | 0o775
+	publishedPerm  os.FileMode = 0o444
+	publishedXPerm os.FileMode = 0o555
+	// sharedUmask is the umask used in shared mode so that files created by
+	// the go command are group writable.
+	sharedUmask = 0o002
+)
+
+// WithShared enables or disables shared mode. Shared mode allows the cache directory
+// to be used by multiple users on the same machine that belong to the same group.
+//
+// In shared mode, directories are group writable and have the setgid bit set so that
+// files inherit the cache's group. Once a tool has been built, its build directory is made
+// read-only so that it can't be modified by other users. Since the go command creates
+// some of the files, the process umask is set to 002 the first time a tool is installed.
+func WithShared(shared bool) Option {
+	return func(c *Cache) {
+		c.shared = shared
+	}
+}
+
+// Shared reports whether the cache is in shared mode.
+func (c *Cache) Shared() bool {
+	return c.shared
+}
+
+// mkdirAll is like os.MkdirAll but ensures any created directories
+// have the correct permissions for the cache's mode. Directories within the cache
+// must not be symlinks, so that writes can't be redirected outside of the cache.
+func (c *Cache) mkdirAll(dir string) error {
+	if err := c.checkNoSymlinks(dir); err != nil {
+		return err
+	}
+	if !c.shared {
+		return os.MkdirAll(dir, dirPerm)
+	}
+
+	// Find which directories within the cache don't exist so they can be fixed after creation.
+	// Directories outside of the cache are left alone.
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		rel, err := filepath.Rel(c.rootDir, d)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			break
+		}
+		if _, err := os.Stat(d); err == nil {
+			break
+		}
+		missing = append(missing, d)
+		if rel == "." {
+			break
+		}
+	}
+	if err := os.MkdirAll(dir, sharedDirPerm); err != nil {
+		return err
+	}
+	// MkdirAll is subject to the umask and won't set the setgid bit on all platforms
+	for _, d := range missing {
+		if err := os.Chmod(d, sharedDirPerm); err != nil {
+			return errors.Wrapf(err, "failed to set permissions of %q", d)
+		}
+	}
+	return nil
+}
+
+// checkNoSymlinks returns an error if p is within the cache and a directory or file in it
+// below the cache directory is a symlink. The cache directory itself may be a symlink.
+// The cache can be inside a project from an untrusted source, which could contain
+// symlinks to files elsewhere.
+func (c *Cache) checkNoSymlinks(p string) error {
+	rel, err := filepath.Rel(c.rootDir, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil
+	}
+	return errors.Wrap(util.CheckNoSymlinks(c.rootDir, p), "cache: symlinks are not allowed in the cache")
+}
+
+// publish makes the build directory dir read-only once the tool has been built in shared mode.
+// This prevents other users from modifying a tool after it is in use. dir is the tool directory
+// for tools that have no builds, see LayoutVersion.
+func (c *Cache) publish(dir, binPath string) error {
+	if !c.shared {
+		return nil
+	}
+	// Walk in reverse so children are handled before their parent directory becomes read-only
+	var paths []string
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, p)
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to publish %q", dir)
+	}
+	for i := len(paths) - 1; i >= 0; i-- {
+		p := paths[i]
+		info, err := os.Lstat(p)
+		if err != nil {
+			return errors.Wrapf(err, "failed to publish %q", p)
+		}
+		var perm os.FileMode
+		switch {
+		case info.IsDir():
+			perm = os.ModeSetgid | publishedXPerm
+		case p == binPath || info.Mode()&0o111 != 0:
+			perm = publishedXPerm
+		case info.Mode().IsRegular():
+			perm = publishedPerm
+		default:
+			continue
+		}
+		if err := os.Chmod(p, perm); err != nil {
+			return errors.Wrapf(err, "failed to set permissions of %q", p)
+		}
+	}
+	return nil
+}
+
+// makeWritable makes all directories under dir writable by the owner so they can be removed.
+// Published tool directories are read-only, which would otherwise cause removal to fail.
+func makeWritable(dir string) error {
+	return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Mode().Perm()&0o200 == 0 {
+			return os.Chmod(p, info.Mode()|0o200)
+		}
+		return nil
+	})
+}
+
+// Problem is an issue found in the cache by Doctor.
+type Problem struct {
+	// Path is the path of the file or directory with the problem.
+	Path string
+	// Issue describes the problem.
+	Issue string
+}
+
+func (p Problem) String() string {
+	return p.Path + ": " + p.Issue
+}
+
+// Doctor checks the cache for problems that would prevent it from working correctly.
+// In shared mode, this includes files that are not accessible by the group, files owned by
+// a different group than the cache directory, and published tools that are still writable.
+// The returned problems are sorted by path.
+func (c *Cache) Doctor() ([]Problem, error) {
+	var problems []Problem
+	add := func(p, issue string) {
+		problems = append(problems, Problem{Path: p, Issue: issue})
+	}
+
+	rootInfo, err := os.Stat(c.rootDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "cache: failed to stat %q", c.rootDir)
+	}
+	if c.shared {
+		checkSharedDir(c.rootDir, rootInfo, false, add)
+	}
+
+	toolsDir := c.toolsDir()
+	err = filepath.Walk(toolsDir, func(p string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && p == toolsDir {
+			return nil
+		}
+		if err != nil {
+			add(p, err.Error())
+			return nil
+		}
+		if c.shared && !sameGroup(rootInfo, info) {
+			add(p, "owned by a different group than the cache directory")
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		published, binaries, err := inspectToolDir(p)
+		if err != nil {
+			add(p, err.Error())
+			return nil
+		}
+		for _, b := range binaries {
+			if b.Mode()&0o111 == 0 {
+				add(filepath.Join(p, b.Name()), "binary is not executable")
+			}
+		}
+		if !c.shared {
+			return nil
+		}
+		checkSharedDir(p, info, published, add)
+		if !published {
+			return nil
+		}
+		// Check the contents of published tools directly since they won't be walked as directories
+		entries, err := readDir(p)
+		if err != nil {
+			add(p, err.Error())
+			return nil
+		}
+		for _, e := range entries {
+			if e.Mode().IsRegular() {
+				fp := filepath.Join(p, e.Name())
+				if e.Mode().Perm()&0o222 != 0 {
+					add(fp, "published file is writable")
+				}
+				if e.Mode().Perm()&0o040 == 0 {
+					add(fp, "file is not readable by the group")
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "cache: failed to check %q", toolsDir)
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Path < problems[j].Path
+	})
+	return problems, nil
+}
+
+func checkSharedDir(p string, info os.FileInfo, published bool, add func(p, issue string)) {
+	perm := info.Mode().Perm()
+	if perm&0o050 != 0o050 {
+		add(p, "directory is not readable by the group")
+	}
+	if published {
+		if perm&0o222 != 0 {
+			add(p, "published directory is writable")
+		}
+		return
+	}
+	if perm&0o020 == 0 {
+		add(p, "directory is not writable by the group")
+	}
+	if info.Mode()&os.ModeSetgid == 0 && setgidSupported {
+		add(p, "directory does not have the setgid bit set")
+	}
+}
+
+// inspectToolDir determines if dir contains a built tool, which is either a build directory or a tool
+// directory without builds. It returns whether the tool has been published, that is a binary exists,
+// along with the binaries in the directory.
+func inspectToolDir(dir string) (bool, []os.FileInfo, error) {
+	entries, err := readDir(dir)
+	if err != nil {
+		return false, nil, err
+	}
+	// Release tools are downloaded so they don't have a go.mod
+	isTool := false
+	var binaries []os.FileInfo
+	for _, e := range entries {
+		if !e.Mode().IsRegular() {
+			continue
+		}
+		switch e.Name() {
+		case "go.mod", assetSumFile:
+			isTool = true
+		case "go.sum", binarySumFile, provenanceFile, BuildFileName:
+		default:
+			binaries = append(binaries, e)
+		}
+	}
+	if filepath.Base(filepath.Dir(dir)) == buildsDir && !strings.HasPrefix(filepath.Base(dir), stagePrefix) {
+		isTool = true
+	}
+	if !isTool {
+		return false, nil, nil
+	}
+	return len(binaries) > 0, binaries, nil
+}
+
+func readDir(dir string) ([]os.FileInfo, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Readdir(-1)
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+// +build !windows
+
+package cache
+
+import (
+	"os"
+	"syscall"
+)
+
+const setgidSupported = true
+
+func setUmask(mask int) {
+	syscall.Umask(mask)
+}
+
+// sameGroup reports whether a and b are owned by the same group.
+func sameGroup(a, b os.FileInfo) bool {
+	sa, ok := a.Sys().(*syscall.Stat_t)
+	if !ok {
+		return true
+	}
+	sb, ok := b.Sys().(*syscall.Stat_t)
+	if !ok {
+		return true
+	}
+	return sa.Gid == sb.Gid
+}
@@ -0,0 +1,13 @@
+package cache
+
+import "os"
+
+// Windows has no concept of a setgid bit, umask, or group ownership of files.
+// Access to a shared cache is instead controlled with ACLs on the cache directory.
+const setgidSupported = false
+
+func setUmask(mask int) {}
+
+func sameGroup(a, b os.FileInfo) bool {
+	return true
+}
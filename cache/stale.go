@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/getshiphub/shed/internal/util"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// ErrStale is returned when the binary of a tool in the cache is missing or out of date.
+var ErrStale = errors.New("cache: binary is stale")
+
+// StaleError describes why the binary of a tool is stale.
+// It matches ErrStale when using errors.Is.
+type StaleError struct {
+	// Tool is the tool whose binary is stale.
+	Tool tool.Tool
+	// Reason describes why the binary is stale, ex: 'binary does not exist'.
+	Reason string
+}
+
+func (e *StaleError) Error() string {
+	return fmt.Sprintf("%v: %s: %s", ErrStale, e.Tool, e.Reason)
+}
+
+func (e *StaleError) Unwrap() error {
+	return ErrStale
+}
+
+// CheckStale checks whether the binary of t needs to be built again. It returns a *StaleError if the binary
+// does not exist, if it was built from a different version of the module than t.Version, or if it was built
+// with a different version of Go than the go command that would build it now, ex: after Go was upgraded.
+// The Go version is only known for tools whose provenance was recorded, see Provenance, and isn't
+// checked for release tools since they aren't built. Reinstall stale tools with Install and InstallRebuild.
+//
+// Unlike ToolPath, CheckStale runs the go command to find its version.
+func (c *Cache) CheckStale(ctx context.Context, t tool.Tool) error {
+	binPath, err := c.BinaryPath(t)
+	if err != nil {
+		return err
+	}
+	if !util.FileOrDirExists(binPath) {
+		return &StaleError{Tool: t, Reason: "binary does not exist"}
+	}
+	if t.IsRelease() {
+		return nil
+	}
+	dir, err := c.ToolDir(t)
+	if err != nil {
+		return err
+	}
+	mod, err := readRequire(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return err
+	}
+	if mod.Version != t.Version {
+		return &StaleError{Tool: t, Reason: fmt.Sprintf("binary was built from version %s", mod.Version)}
+	}
+	prov, err := c.Provenance(t)
+	if err != nil || prov == nil {
+		return err
+	}
+	values, err := c.goClient.Env(ctx, c.goEnv(nil), "GOVERSION")
+	if err != nil {
+		return errors.WithMessage(err, "cache: failed to get version of go")
+	}
+	if goVersion := values[0]; prov.GoVersion != goVersion {
+		return &StaleError{Tool: t, Reason: fmt.Sprintf("binary was built with %s, but go is %s", prov.GoVersion, goVersion)}
+	}
+	return nil
+}
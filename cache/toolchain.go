@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// ErrToolchainTooOld is returned when a tool requires a newer version of Go than the one that would build it.
+var ErrToolchainTooOld = errors.New("cache: go is too old")
+
+// ToolchainError describes a tool whose module requires a newer version of Go than the go command.
+// It matches ErrToolchainTooOld when using errors.Is.
+type ToolchainError struct {
+	// Tool is the tool that can't be built.
+	Tool tool.Tool
+	// GoVersion is the go directive of the module of the tool, ex: '1.21'.
+	GoVersion string
+	// LocalVersion is the version of the go command, ex: 'go1.20.5'.
+	LocalVersion string
+}
+
+func (e *ToolchainError) Error() string {
+	return fmt.Sprintf("%v: %s requires go %s, but go is %s", ErrToolchainTooOld, e.Tool, e.GoVersion, e.LocalVersion)
+}
+
+func (e *ToolchainError) Unwrap() error {
+	return ErrToolchainTooOld
+}
+
+// InstallCheckToolchain makes Install fail with a *ToolchainError, before building the tool, if the go directive
+// of its module is newer than the version of the go command. Otherwise the go command may fail to build the tool,
+// or try to download a newer toolchain. Tools that are already built, or pulled from the remote, aren't checked.
+func InstallCheckToolchain() InstallOption {
+	return func(o *installOptions) {
+		o.checkToolchain = true
+	}
+}
+
+// checkToolchain returns a *ToolchainError if t, which was just downloaded, requires a newer version of Go
+// than the go command run with env. If either version is unknown, ex: for development builds of Go, t is not checked.
+func (c *Cache) checkToolchain(ctx context.Context, t tool.Tool, env []string) error {
+	values, err := c.goClient.Env(ctx, env, "GOVERSION", "GOMODCACHE")
+	if err != nil {
+		return errors.WithMessage(err, "cache: failed to get version of go")
+	}
+	modPath, err := c.ModulePath(t)
+	if err != nil {
+		return err
+	}
+	goVersion, err := readGoDirective(values[1], module.Version{Path: modPath, Version: t.Version})
+	if os.IsNotExist(errors.Cause(err)) {
+		// The module cache may have been cleaned since the tool was downloaded, the build will fail if go is too old
+		c.logger.WithField("tool", t).Debug("go.mod not found in the module cache, skipping toolchain check")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if goVersion == "" || goSemver(values[0]) == "" {
+		return nil
+	}
+	if CompareGoVersions(goVersion, values[0]) > 0 {
+		return &ToolchainError{Tool: t, GoVersion: goVersion, LocalVersion: values[0]}
+	}
+	return nil
+}
+
+// GoDirective returns the go directive of the module that provides t, ex: '1.21', which is the minimum
+// version of Go needed to build t. It is read from the go.mod file of the module in the module cache, so t
+// must have been downloaded, otherwise the cause of the error satisfies os.IsNotExist. An empty string is
+// returned if the module has no go directive. Release tools aren't built, so they have no go directive.
+func (c *Cache) GoDirective(ctx context.Context, t tool.Tool) (string, error) {
+	if t.IsRelease() {
+		return "", nil
+	}
+	modPath, err := c.ModulePath(t)
+	if err != nil {
+		return "", err
+	}
+	values, err := c.goClient.Env(ctx, c.goEnv(nil), "GOMODCACHE")
+	if err != nil {
+		return "", errors.WithMessage(err, "cache: failed to get module cache of go")
+	}
+	return readGoDirective(values[0], module.Version{Path: modPath, Version: t.Version})
+}
+
+// readGoDirective returns the go directive of the go.mod file of m in the module cache modCache.
+// The file is read by hand since older versions of the go.mod syntax, which modfile supports,
+// don't allow the patch version used by newer go directives, ex: 'go 1.21.0'.
+func readGoDirective(modCache string, m module.Version) (string, error) {
+	escPath, err := module.EscapePath(m.Path)
+	if err != nil {
+		return "", errors.Wrapf(err, "cache: invalid module path %q", m.Path)
+	}
+	escVersion, err := module.EscapeVersion(m.Version)
+	if err != nil {
+		return "", errors.Wrapf(err, "cache: invalid module version %q", m.Version)
+	}
+	p := filepath.Join(modCache, "cache", "download", filepath.FromSlash(escPath), "@v", escVersion+".mod")
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return "", errors.Wrapf(err, "cache: failed to read go.mod of %s", m)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "go" {
+			return fields[1], nil
+		}
+	}
+	return "", nil
+}
+
+// goVersionRE matches a version of Go, either as a go directive, ex: '1.21.0', or as reported by the go command,
+// ex: 'go1.21rc2'. Development builds of Go, ex: 'devel go1.22-abcdef', don't match.
+var goVersionRE = regexp.MustCompile(`^(?:go)?(\d+)\.(\d+)(?:\.(\d+))?((?:alpha|beta|rc)\d+)?`)
+
+// goSemver converts the Go version v to a semantic version so it can be compared, ex: 'go1.21rc2' is 'v1.21.0-rc2'.
+// It returns an empty string if v isn't a version of Go.
+func goSemver(v string) string {
+	m := goVersionRE.FindStringSubmatch(v)
+	if m == nil {
+		return ""
+	}
+	patch := m[3]
+	if patch == "" {
+		patch = "0"
+	}
+	sv := fmt.Sprintf("v%s.%s.%s", m[1], m[2], patch)
+	if m[4] != "" {
+		sv += "-" + m[4]
+	}
+	return sv
+}
+
+// CompareGoVersions returns an integer comparing two versions of Go, which can either be go directives, ex: '1.21',
+// or versions reported by the go command, ex: 'go1.21.3'. The result is 0 if a == b, -1 if a < b, or +1 if a > b.
+// A version that can't be parsed is considered smaller than a valid one, and two invalid versions are equal.
+func CompareGoVersions(a, b string) int {
+	return semver.Compare(goSemver(a), goSemver(b))
+}
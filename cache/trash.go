@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getshiphub/shed/internal/util"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// trashDirName is the directory in the cache where Prune moves the tools it removes, so that
+// they can be brought back with Untrash until they are purged. Each prune moves tools to a
+// directory named after the Unix time it ran at, which is used to purge them once they expire.
+const trashDirName = "trash"
+
+// DefaultTrashTTL is how long tools removed by Prune are kept in the trash, unless PruneOptions.TrashTTL is set.
+const DefaultTrashTTL = 7 * 24 * time.Hour
+
+func (c *Cache) trashDir() string {
+	return filepath.Join(c.rootDir, trashDirName)
+}
+
+// trashEntry is a directory in the trash containing the tools removed by a single prune.
+type trashEntry struct {
+	dir  string
+	time time.Time
+}
+
+// trashEntries returns the directories in the trash, newest first.
+func (c *Cache) trashEntries() ([]trashEntry, error) {
+	infos, err := ioutil.ReadDir(c.trashDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "cache: failed to read trash %q", c.trashDir())
+	}
+	var entries []trashEntry
+	for _, info := range infos {
+		sec, err := strconv.ParseInt(info.Name(), 10, 64)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		entries = append(entries, trashEntry{dir: filepath.Join(c.trashDir(), info.Name()), time: time.Unix(sec, 0)})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].time.After(entries[j].time)
+	})
+	return entries, nil
+}
+
+// trashToolDir moves the tool directory rel, relative to the tools directory, to the trash directory trash.
+// Any parent directories left empty are removed. The caller must hold the cache lock exclusively.
+func (c *Cache) trashToolDir(trash, rel string) error {
+	src := filepath.Join(c.toolsDir(), rel)
+	dst := filepath.Join(trash, rel)
+	// Published tools are read-only in shared mode, and moving a directory can require it to be writable
+	if err := makeWritable(src); err != nil {
+		return errors.Wrapf(err, "cache: failed to move %q to the trash", src)
+	}
+	if util.FileOrDirExists(dst) {
+		// The same tool was installed and pruned again within a second
+		if err := os.RemoveAll(dst); err != nil {
+			return errors.Wrapf(err, "cache: failed to remove %q", dst)
+		}
+	}
+	if err := c.mkdirAll(filepath.Dir(dst)); err != nil {
+		return errors.Wrapf(err, "cache: failed to create directory %q", filepath.Dir(dst))
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return errors.Wrapf(err, "cache: failed to move %q to the trash", src)
+	}
+	removeEmptyParents(filepath.Dir(src), c.toolsDir())
+	return nil
+}
+
+// purgeTrash removes the tools that were moved to the trash longer than ttl ago,
+// and returns the number of bytes freed. The caller must hold the cache lock exclusively.
+func (c *Cache) purgeTrash(ttl time.Duration, dryRun bool) (int64, error) {
+	entries, err := c.trashEntries()
+	if err != nil {
+		return 0, err
+	}
+	var purged int64
+	now := time.Now()
+	for _, e := range entries {
+		if now.Sub(e.time) <= ttl {
+			continue
+		}
+		size, err := dirSize(e.dir)
+		if err != nil {
+			return purged, errors.Wrapf(err, "cache: failed to purge %q", e.dir)
+		}
+		if !dryRun {
+			if err := makeWritable(e.dir); err != nil {
+				return purged, errors.Wrapf(err, "cache: failed to purge %q", e.dir)
+			}
+			if err := os.RemoveAll(e.dir); err != nil {
+				return purged, errors.Wrapf(err, "cache: failed to purge %q", e.dir)
+			}
+		}
+		c.logger.Debugf("purged %s from the trash", e.dir)
+		purged += size
+	}
+	return purged, nil
+}
+
+// Untrash moves t back from the trash if it was removed by Prune and hasn't been purged yet, so that it can be
+// used without installing it again. If t was removed by multiple prunes, the most recently removed one is used.
+// Untrash reports whether t is installed afterwards, which is also the case if t was never removed.
+// If t is in neither the cache nor the trash, Untrash returns false and t must be installed again.
+func (c *Cache) Untrash(ctx context.Context, t tool.Tool) (bool, error) {
+	fp, err := t.Filepath()
+	if err != nil {
+		return false, err
+	}
+	unlock := c.lockTool(t.ImportPath)
+	defer unlock()
+	// Like Install, tools are added to the cache so the cache lock is shared
+	unlockCache, err := c.lock(ctx, cacheLockFile, true)
+	if err != nil {
+		return false, err
+	}
+	defer unlockCache()
+	unlockTool, err := c.lock(ctx, toolLockFile(t.ImportPath), false)
+	if err != nil {
+		return false, err
+	}
+	defer unlockTool()
+	binPath, err := c.BinaryPath(t)
+	if err != nil {
+		return false, err
+	}
+	if util.FileOrDirExists(binPath) {
+		return true, nil
+	}
+
+	entries, err := c.trashEntries()
+	if err != nil {
+		return false, err
+	}
+	dir := filepath.Join(c.toolsDir(), fp)
+	for _, e := range entries {
+		src := filepath.Join(e.dir, fp)
+		if !util.FileOrDirExists(src) {
+			continue
+		}
+		if err := c.checkNoSymlinks(src); err != nil {
+			return false, err
+		}
+		// A partial install that failed before the binary was built
+		if util.FileOrDirExists(dir) {
+			if err := c.removeToolDir(dir); err != nil {
+				return false, err
+			}
+		}
+		if err := c.mkdirAll(filepath.Dir(dir)); err != nil {
+			return false, errors.Wrapf(err, "cache: failed to create directory %q", filepath.Dir(dir))
+		}
+		if err := os.Rename(src, dir); err != nil {
+			return false, errors.Wrapf(err, "cache: failed to restore %q from the trash", dir)
+		}
+		removeEmptyParents(filepath.Dir(src), c.trashDir())
+		// The restored tool can have a build
+		if binPath, err = c.BinaryPath(t); err != nil {
+			return false, err
+		}
+		if err := c.publish(filepath.Dir(binPath), binPath); err != nil {
+			return false, errors.Wrapf(err, "cache: failed to restore %q from the trash", dir)
+		}
+		c.logger.Debugf("restored %s from the trash", dir)
+		return util.FileOrDirExists(binPath), nil
+	}
+	return false, nil
+}
+
+// removeEmptyParents removes dir and its parents up to, but not including, root as long as they are empty.
+func removeEmptyParents(dir, root string) {
+	for d := dir; d != root && strings.HasPrefix(d, root); d = filepath.Dir(d) {
+		// Remove fails if the directory isn't empty, which means it's still used
+		if err := os.Remove(d); err != nil {
+			break
+		}
+	}
+}
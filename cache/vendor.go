@@ -0,0 +1,224 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/module"
+)
+
+// ErrNotVendored is returned when installing offline needs something that isn't in the vendor directory.
+var ErrNotVendored = errors.New("cache: not vendored")
+
+// Directories in a vendor directory, see Vendor.
+const (
+	// vendorModulesDir contains modules in the layout of a module proxy, so it can be used as GOPROXY.
+	vendorModulesDir = "modules"
+	// vendorAssetsDir contains the release assets of release tools.
+	vendorAssetsDir = "assets"
+)
+
+// InstallOffline makes Install use only what was added to vendorDir by Vendor, so nothing is downloaded.
+// The go command uses the modules in vendorDir as its only module proxy, with the checksum database
+// disabled, so it fails right away if a module isn't there. The assets of release tools are read from
+// vendorDir, and if they aren't there the error matches ErrNotVendored. The remote cache and prebuilt
+// binaries are not used. Modules and toolchains that are already in the module cache are still used.
+// vendorDir must be an absolute path.
+func InstallOffline(vendorDir string) InstallOption {
+	return func(o *installOptions) {
+		o.vendorDir = vendorDir
+	}
+}
+
+// offlineEnv returns the environment that makes the go command only use the modules in vendorDir.
+// It must be added last so nothing else can change where modules come from.
+func offlineEnv(vendorDir string) []string {
+	p := filepath.ToSlash(filepath.Join(vendorDir, vendorModulesDir))
+	if !strings.HasPrefix(p, "/") {
+		// Windows paths, ex: file:///C:/vendor
+		p = "/" + p
+	}
+	// GONOPROXY and GOPRIVATE are cleared since they make the go command fetch from version control instead
+	return []string{"GOPROXY=file://" + p, "GONOPROXY=", "GOPRIVATE=", "GOSUMDB=off"}
+}
+
+// Vendor adds everything needed to install t offline to the directory dir, see InstallOffline.
+// For tools built from source, the modules used to build t, and the ones whose go.mod was needed
+// to resolve its dependencies, are copied from the module cache of the go command. t must already be
+// installed so that they are there. For release tools, the asset of the platform is downloaded,
+// and it must match the hash of t for the platform if it has one. Vendoring the same files again
+// does nothing, so multiple tools can be added to dir.
+//
+// The layout of dir is:
+//
+//	DIR/modules/  the modules, in the layout of a module proxy
+//	DIR/assets/   the release assets, named after the hash of their URL
+func (c *Cache) Vendor(ctx context.Context, t tool.Tool, dir string) error {
+	if t.IsRelease() {
+		return c.vendorAsset(ctx, t, dir)
+	}
+	toolDir, err := c.ToolDir(t)
+	if err != nil {
+		return err
+	}
+	mods, err := readGoSumModules(filepath.Join(toolDir, "go.sum"), true)
+	if err != nil {
+		return errors.WithMessagef(err, "cache: failed to find modules of %s, it must be installed", t)
+	}
+	// Modules only needed for their go.mod have no source, so only the zips of the others are vendored
+	sources, err := readGoSumModules(filepath.Join(toolDir, "go.sum"), false)
+	if err != nil {
+		return err
+	}
+	hasSource := make(map[module.Version]bool, len(sources))
+	for _, m := range sources {
+		hasSource[m] = true
+	}
+
+	values, err := c.GoEnv(ctx, "GOMODCACHE")
+	if err != nil {
+		return errors.WithMessage(err, "cache: failed to find module cache")
+	}
+	if values[0] == "" {
+		return errors.New("cache: GOMODCACHE is not set")
+	}
+	downloadDir := filepath.Join(values[0], "cache", "download")
+	seen := make(map[module.Version]bool, len(mods))
+	for _, m := range mods {
+		if seen[m] {
+			continue
+		}
+		seen[m] = true
+		escPath, err := module.EscapePath(m.Path)
+		if err != nil {
+			return errors.Wrapf(err, "cache: invalid module path %q", m.Path)
+		}
+		escVersion, err := module.EscapeVersion(m.Version)
+		if err != nil {
+			return errors.Wrapf(err, "cache: invalid module version %q", m.Version)
+		}
+		src := filepath.Join(downloadDir, escPath, "@v")
+		dst := filepath.Join(dir, vendorModulesDir, escPath, "@v")
+		exts := []string{".mod", ".info"}
+		if hasSource[m] {
+			exts = append(exts, ".zip")
+		}
+		for _, ext := range exts {
+			name := escVersion + ext
+			err := copyVendorFile(filepath.Join(src, name), filepath.Join(dst, name))
+			// The go command doesn't always keep the info file, the others are needed to build
+			if os.IsNotExist(errors.Cause(err)) && ext == ".info" {
+				continue
+			}
+			if os.IsNotExist(errors.Cause(err)) {
+				return errors.Errorf("cache: module %s of %s is not in the module cache %s", m, t, values[0])
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	c.logger.WithField("tool", t).Debugf("vendored %d modules", len(seen))
+	return nil
+}
+
+// vendorAsset downloads the release asset of t for its platform to the vendor directory dir.
+func (c *Cache) vendorAsset(ctx context.Context, t tool.Tool, dir string) error {
+	goos, goarch, err := targetPlatform(t)
+	if err != nil {
+		return err
+	}
+	url, err := t.ReleaseURL(goos, goarch)
+	if err != nil {
+		return err
+	}
+	p := vendoredAssetPath(dir, url)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return errors.Wrapf(err, "cache: failed to create directory %q", filepath.Dir(p))
+	}
+	f, err := ioutil.TempFile(filepath.Dir(p), "asset-")
+	if err != nil {
+		return errors.Wrap(err, "cache: failed to create temp file")
+	}
+	defer os.Remove(f.Name())
+	sum, err := c.fetch(ctx, url, f)
+	if cerr := f.Close(); err == nil && cerr != nil {
+		err = errors.Wrapf(cerr, "cache: failed to write file %q", f.Name())
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkAssetSum(t, sum); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), p); err != nil {
+		return errors.Wrapf(err, "cache: failed to write file %q", p)
+	}
+	c.logger.WithField("tool", t).Debugf("vendored asset %s", url)
+	return nil
+}
+
+// vendoredAssetPath returns the path of the asset at url in the vendor directory dir. The URL is hashed since
+// it can contain characters that aren't allowed in paths, and the name is kept so the archive format is known.
+func vendoredAssetPath(dir, url string) string {
+	h := sha256.Sum256([]byte(url))
+	return filepath.Join(dir, vendorAssetsDir, hex.EncodeToString(h[:]), assetName(url))
+}
+
+// readVendoredAsset writes the asset at url in the vendor directory dir to w and returns its hash in the
+// form 'sha256:HEX'. If the asset isn't vendored, the error matches ErrNotVendored.
+func readVendoredAsset(dir, url string, w io.Writer) (string, error) {
+	p := vendoredAssetPath(dir, url)
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return "", errors.Wrapf(ErrNotVendored, "asset %s", url)
+	} else if err != nil {
+		return "", errors.Wrapf(err, "cache: failed to open file %q", p)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, h), f); err != nil {
+		return "", errors.Wrapf(err, "cache: failed to read file %q", p)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copyVendorFile copies the file src to dst, unless dst already exists. The file is written to a temp file first,
+// so an interrupted copy never leaves a partial file that would be skipped the next time.
+func copyVendorFile(src, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return errors.Wrapf(err, "cache: failed to open file %q", src)
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return errors.Wrapf(err, "cache: failed to create directory %q", filepath.Dir(dst))
+	}
+	out, err := ioutil.TempFile(filepath.Dir(dst), ".tmp-")
+	if err != nil {
+		return errors.Wrap(err, "cache: failed to create temp file")
+	}
+	defer os.Remove(out.Name())
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return errors.Wrapf(err, "cache: failed to copy %q to %q", src, dst)
+	}
+	if err := os.Chmod(out.Name(), 0o644); err != nil {
+		return errors.Wrapf(err, "cache: failed to copy %q to %q", src, dst)
+	}
+	return errors.Wrapf(os.Rename(out.Name(), dst), "cache: failed to copy %q to %q", src, dst)
+}
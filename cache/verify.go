@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// binarySumFile is the name of the file in a tool directory that contains the hash of the binary.
+// It is written when the tool is built.
+const binarySumFile = "shed.sum"
+
+// binaryStatDir is the name of the directory in the cache that records the size and modification time
+// of each binary whose hash was checked, so it only needs to be hashed again if they change.
+const binaryStatDir = "stat"
+
+// ErrChecksumMismatch is returned when the files of a tool in the cache don't match their hashes.
+var ErrChecksumMismatch = errors.New("cache: checksum mismatch")
+
+// ErrBinaryModified is returned by ToolPath when the binary of a tool doesn't match the hash
+// recorded when it was built, which means it was changed outside of shed.
+var ErrBinaryModified = errors.New("cache: binary was modified after it was installed")
+
+// ChecksumError describes a file of a tool that doesn't match its hash.
+// It matches ErrChecksumMismatch when using errors.Is.
+type ChecksumError struct {
+	// Tool is the tool that failed verification.
+	Tool tool.Tool
+	// Path is the path to the file that was checked.
+	Path string
+	// Want is the expected hash.
+	Want string
+	// Got is the actual hash.
+	Got string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%v: %s: %s has hash %s, want %s", ErrChecksumMismatch, e.Tool, e.Path, e.Got, e.Want)
+}
+
+func (e *ChecksumError) Unwrap() error {
+	return ErrChecksumMismatch
+}
+
+// verifyModule checks that the module that provides t has the hash t.Sum. It returns the hash
+// of the module, which is empty if the hash is unknown and t.Sum is empty, since there is
+// nothing to verify in that case.
+func (c *Cache) verifyModule(t tool.Tool) (string, error) {
+	sum, err := c.ModuleSum(t)
+	if err != nil {
+		if t.Sum == "" {
+			c.logger.WithError(err).Debugf("hash of %s is unknown, skipping verification", t)
+			return "", nil
+		}
+		return "", err
+	}
+	if t.Sum != "" && t.Sum != sum {
+		fp, err := t.Filepath()
+		if err != nil {
+			return "", err
+		}
+		return "", &ChecksumError{Tool: t, Path: filepath.Join(c.toolsDir(), fp, "go.sum"), Want: t.Sum, Got: sum}
+	}
+	return sum, nil
+}
+
+// hashFile returns the SHA-256 hash of the file at p in the format 'sha256:HEX'.
+func hashFile(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeBinarySum records the hash of the binary at binPath in the tool directory dir.
+func writeBinarySum(dir, binPath string) error {
+	sum, err := hashFile(binPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to hash binary %q", binPath)
+	}
+	p := filepath.Join(dir, binarySumFile)
+	if err := ioutil.WriteFile(p, []byte(sum+"\n"), 0o644); err != nil {
+		return errors.Wrapf(err, "failed to write file %q", p)
+	}
+	return nil
+}
+
+// readBinarySum returns the hash of the binary recorded in the tool directory dir.
+// If no hash was recorded, an empty string is returned.
+func readBinarySum(dir string) (string, error) {
+	p := filepath.Join(dir, binarySumFile)
+	data, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", errors.Wrapf(err, "cache: failed to read file %q", p)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Verify checks that the installed files of t have not been modified. The hash of the module
+// source is compared against t.Sum, if it is set, and the binary is compared against the hash
+// recorded when it was built. Binaries built before hashes were recorded are not checked.
+//
+// If a file doesn't match its hash, a *ChecksumError is returned. If t is not installed,
+// an error is returned.
+func (c *Cache) Verify(t tool.Tool) error {
+	binPath, err := c.toolPath(t)
+	if err != nil {
+		return err
+	}
+	want, err := readBinarySum(filepath.Dir(binPath))
+	if err != nil {
+		return err
+	}
+	if want == "" {
+		c.logger.Debugf("no hash recorded for binary of %s, skipping verification", t)
+		return nil
+	}
+	got, err := hashFile(binPath)
+	if err != nil {
+		return errors.Wrapf(err, "cache: failed to hash binary %q", binPath)
+	}
+	if got != want {
+		return &ChecksumError{Tool: t, Path: binPath, Want: want, Got: got}
+	}
+	return nil
+}
+
+// checkBinary checks that the binary of t at binPath matches the hash recorded when it was built.
+// Hashing a large binary every time a tool is run is slow, so once the hash has been checked the
+// size and modification time of the binary are recorded, and it is only hashed again if they change.
+// Binaries built before hashes were recorded are not checked.
+func (c *Cache) checkBinary(t tool.Tool, binPath string) error {
+	want, err := readBinarySum(filepath.Dir(binPath))
+	if err != nil || want == "" {
+		return err
+	}
+	info, err := os.Stat(binPath)
+	if err != nil {
+		return errors.Wrapf(err, "cache: failed to stat binary %q", binPath)
+	}
+	fp, err := t.Filepath()
+	if err != nil {
+		return err
+	}
+	statPath := filepath.Join(c.rootDir, binaryStatDir, fp)
+	stat := fmt.Sprintf("%s %d %d", want, info.Size(), info.ModTime().UnixNano())
+	if data, err := ioutil.ReadFile(statPath); err == nil && strings.TrimSpace(string(data)) == stat {
+		return nil
+	}
+
+	got, err := hashFile(binPath)
+	if err != nil {
+		return errors.Wrapf(err, "cache: failed to hash binary %q", binPath)
+	}
+	if got != want {
+		return fmt.Errorf("%w: %s: %s has hash %s, want %s", ErrBinaryModified, t, binPath, got, want)
+	}
+	// The record only saves work, so failing to write it, ex: in a shared cache
+	// owned by another user, isn't an error
+	perm := os.FileMode(0o644)
+	if c.shared {
+		perm = 0o664
+	}
+	if err := c.mkdirAll(filepath.Dir(statPath)); err != nil {
+		c.logger.WithError(err).Debugf("failed to record stat of binary of %s", t)
+	} else if err := ioutil.WriteFile(statPath, []byte(stat+"\n"), perm); err != nil {
+		c.logger.WithError(err).Debugf("failed to record stat of binary of %s", t)
+	}
+	return nil
+}
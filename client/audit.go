@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"os"
+
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+	"github.com/getshiphub/shed/vulndb"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/module"
+)
+
+// AuditReport is the result of checking the tools in the lockfile for vulnerabilities.
+type AuditReport struct {
+	// Tools are the tools in the lockfile in the same order as List, whether or not they are vulnerable.
+	Tools []AuditTool
+}
+
+// Vulnerable returns the tools that are affected by at least one vulnerability.
+func (r *AuditReport) Vulnerable() []AuditTool {
+	var vulnerable []AuditTool
+	for _, at := range r.Tools {
+		if len(at.Vulns) > 0 {
+			vulnerable = append(vulnerable, at)
+		}
+	}
+	return vulnerable
+}
+
+// AuditTool is a tool in an AuditReport.
+type AuditTool struct {
+	Tool tool.Tool
+	// Vulns are the vulnerabilities that affect the tool, sorted by module and then ID.
+	Vulns []AuditVuln
+	// DepsChecked reports whether the dependencies of the tool were checked. This requires the tool to be
+	// built in the cache, otherwise only the module of the tool is checked.
+	DepsChecked bool
+}
+
+// AuditVuln is a vulnerability that affects a tool.
+type AuditVuln struct {
+	vulndb.Entry
+	// Module is the affected module, which is either the module of the tool or one of its dependencies.
+	Module module.Version
+	// Fixed is the version of Module that fixes the vulnerability, empty if there is none.
+	Fixed string
+}
+
+// Audit checks the tools in the lockfile for known vulnerabilities. See WithVulnDB for the database that is used.
+//
+// The modules that were used to build each tool are checked, which are read from the cache, see
+// cache.Cache.BuildModules. If a tool isn't installed, or shed was created without a cache, only the
+// module of the tool is checked and AuditTool.DepsChecked is false. Unlike govulncheck, the code of the
+// tools isn't analyzed, so a tool is reported even if it doesn't call the vulnerable code.
+//
+// The report is always returned. If some tools couldn't be checked, the error is a lockfile.ErrorList with the failures.
+func (s *Shed) Audit(ctx context.Context) (*AuditReport, error) {
+	db := s.vulnDB
+	if db == nil {
+		db = vulndb.FromEnv()
+	}
+	// Tools often share dependencies, so only look up each module once
+	dbModules := make(map[string]*vulndb.Module)
+
+	report := &AuditReport{}
+	var errs lockfile.ErrorList
+	for _, t := range s.List() {
+		at, toolErrs := s.auditTool(ctx, db, dbModules, t)
+		errs = append(errs, toolErrs...)
+		report.Tools = append(report.Tools, at)
+	}
+	if len(errs) > 0 {
+		return report, errs
+	}
+	return report, nil
+}
+
+// auditTool checks t for known vulnerabilities in db. dbModules holds the modules that were already
+// looked up, and is added to. The errors are the modules that couldn't be checked.
+func (s *Shed) auditTool(ctx context.Context, db *vulndb.Client, dbModules map[string]*vulndb.Module, t tool.Tool) (AuditTool, []error) {
+	at := AuditTool{Tool: t}
+	var errs []error
+	mods, err := s.auditModules(t)
+	if err != nil {
+		errs = append(errs, errors.WithMessagef(err, "failed to find modules of tool %s", t))
+	}
+	at.DepsChecked = mods != nil
+	if mods == nil {
+		modPath := t.ModulePath
+		if modPath == "" {
+			modPath = t.ImportPath
+		}
+		mods = []module.Version{{Path: modPath, Version: t.Version}}
+	}
+	for _, mv := range mods {
+		m, ok := dbModules[mv.Path]
+		if !ok {
+			m, err = db.Module(ctx, mv.Path)
+			if err != nil {
+				errs = append(errs, errors.WithMessagef(err, "failed to check module %s of tool %s", mv, t))
+				continue
+			}
+			dbModules[mv.Path] = m
+		}
+		for _, e := range m.Affecting(mv.Version) {
+			at.Vulns = append(at.Vulns, AuditVuln{Entry: e, Module: mv, Fixed: m.Fix(e.ID, mv.Version)})
+		}
+	}
+	return at, errs
+}
+
+// auditModules returns the modules used to build t, or nil if they aren't known
+// because t isn't installed or there is no cache.
+func (s *Shed) auditModules(t tool.Tool) ([]module.Version, error) {
+	if s.cache == nil {
+		return nil, nil
+	}
+	mods, err := s.cache.BuildModules(t)
+	if os.IsNotExist(errors.Cause(err)) {
+		return nil, nil
+	}
+	return mods, err
+}
@@ -0,0 +1,109 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/vulndb"
+	"golang.org/x/mod/module"
+)
+
+func TestAudit(t *testing.T) {
+	files := map[string]string{
+		"/index/modules.json": `[
+			{"path": "github.com/golangci/golangci-lint", "vulns": [{"id": "GO-2022-0001"}]},
+			{"path": "golang.org/x/text", "vulns": [{"id": "GO-2022-0002"}, {"id": "GO-2022-0003"}]}
+		]`,
+		"/ID/GO-2022-0001.json": `{"id": "GO-2022-0001", "summary": "Crash", "affected": [{"package": {"name": "github.com/golangci/golangci-lint"}, "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.30.0"}]}]}]}`,
+		"/ID/GO-2022-0002.json": `{"id": "GO-2022-0002", "summary": "Infinite loop", "affected": [{"package": {"name": "golang.org/x/text"}, "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "0.3.8"}]}]}]}`,
+		"/ID/GO-2022-0003.json": `{"id": "GO-2022-0003", "summary": "Unfixed", "affected": [{"package": {"name": "golang.org/x/text"}, "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}]}]}]}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(data)) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	td := t.TempDir()
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	c := cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo))
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithCache(c),
+		client.WithVulnDB(vulndb.New(srv.Client(), srv.URL)),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	installSet, err := s.Install("github.com/Shopify/ejson/cmd/ejson@v1.1.0", "github.com/golangci/golangci-lint/cmd/golangci-lint@v1.28.3")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	tools := s.List()
+
+	// Add dependencies to ejson, one of them only for its go.mod, and uninstall golangci-lint
+	ejsonDir, err := c.ToolDir(tools[0])
+	if err != nil {
+		t.Fatalf("failed to get tool dir: %v", err)
+	}
+	f, err := os.OpenFile(filepath.Join(ejsonDir, "go.sum"), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("failed to open go.sum: %v", err)
+	}
+	_, err = f.WriteString("golang.org/x/text v0.3.7 h1:abc=\ngolang.org/x/text v0.3.7/go.mod h1:def=\ngolang.org/x/text v0.3.0/go.mod h1:ghi=\n")
+	f.Close()
+	if err != nil {
+		t.Fatalf("failed to write go.sum: %v", err)
+	}
+	lintDir, err := c.ToolDir(tools[1])
+	if err != nil {
+		t.Fatalf("failed to get tool dir: %v", err)
+	}
+	if err := os.RemoveAll(lintDir); err != nil {
+		t.Fatalf("failed to remove tool: %v", err)
+	}
+
+	report, err := s.Audit(context.Background())
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	text := module.Version{Path: "golang.org/x/text", Version: "v0.3.7"}
+	lint := module.Version{Path: "github.com/golangci/golangci-lint", Version: "v1.28.3"}
+	want := &client.AuditReport{Tools: []client.AuditTool{
+		{
+			Tool: tools[0],
+			Vulns: []client.AuditVuln{
+				{Entry: vulndb.Entry{ID: "GO-2022-0002", Summary: "Infinite loop"}, Module: text, Fixed: "v0.3.8"},
+				{Entry: vulndb.Entry{ID: "GO-2022-0003", Summary: "Unfixed"}, Module: text},
+			},
+			DepsChecked: true,
+		},
+		{
+			Tool:  tools[1],
+			Vulns: []client.AuditVuln{{Entry: vulndb.Entry{ID: "GO-2022-0001", Summary: "Crash"}, Module: lint, Fixed: "v1.30.0"}},
+		},
+	}}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("got report %+v, want %+v", report, want)
+	}
+	if got := report.Vulnerable(); len(got) != 2 {
+		t.Errorf("got %d vulnerable tools, want 2", len(got))
+	}
+}
@@ -0,0 +1,187 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/config"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// BundleCacheDir is the name of the directory in a bundle containing the cache with the tools.
+const BundleCacheDir = "cache"
+
+// BundleOptions customizes Bundle.
+type BundleOptions struct {
+	// Platform is the platform the bundle is for, in the form 'GOOS/GOARCH', ex: 'linux/amd64'.
+	// If empty, the platform shed is running on is used.
+	Platform string
+	// ShedBinary is the path to a shed executable built for Platform, which is copied into the bundle.
+	// If empty, the bundle only contains the tools, and shed must already be available where it is used.
+	ShedBinary string
+}
+
+// Bundle creates a directory dir containing the tools in the lockfile, built for opts.Platform, so they can be
+// run on machines that have neither the go command nor network access. The tools must already be installed
+// for the platform, ex: with InstallSet.Apply and TargetPlatform. dir must not exist, or be empty.
+//
+// The bundle is a project that uses a project cache in BundleCacheDir:
+//
+//	DIR/shed             the shed executable, if opts.ShedBinary is set, with '.exe' added for windows
+//	DIR/shed.lock        a copy of the lockfile
+//	DIR/shed.config.json the config file, with the cache set to BundleCacheDir
+//	DIR/cache/           the cache containing the tools, in the layout shed uses on the platform
+//
+// Tools are run from the bundle with 'shed run' like any other project. Bundle returns the number of tools bundled.
+func (s *Shed) Bundle(dir string, opts BundleOptions) (int, error) {
+	if s.cache == nil {
+		return 0, ErrNoCache
+	}
+	platform := hostPlatform(opts.Platform)
+	goos := runtime.GOOS
+	if platform != "" {
+		var err error
+		if goos, _, err = tool.ParsePlatform(platform); err != nil {
+			return 0, err
+		}
+	}
+	if entries, err := ioutil.ReadDir(dir); err == nil && len(entries) > 0 {
+		return 0, errors.Errorf("bundle directory %s is not empty", dir)
+	} else if err != nil && !os.IsNotExist(err) {
+		return 0, errors.Wrapf(err, "failed to read directory %s", dir)
+	}
+
+	// Find every tool first so nothing is written if one is missing
+	tools := s.List()
+	binPaths := make([]string, len(tools))
+	toolDirs := make([]string, len(tools))
+	for i, t := range tools {
+		t.Platform = platform
+		binPath, err := s.cache.ToolPath(t)
+		if err != nil {
+			return 0, errors.WithMessagef(err, "failed to find tool %s, it must be installed for the platform", t)
+		}
+		binPaths[i] = binPath
+		if toolDirs[i], err = s.cache.ToolDir(t); err != nil {
+			return 0, err
+		}
+	}
+	// The tools are stored the way shed on the platform expects them, which is as if they
+	// were built there, so they can be found without selecting the platform
+	bundleCache := cache.New(filepath.Join(dir, BundleCacheDir))
+	for i, t := range tools {
+		dstDir, err := bundleCache.ToolDir(t)
+		if err != nil {
+			return 0, err
+		}
+		dstBin, err := bundleCache.BinaryPath(t)
+		if err != nil {
+			return 0, err
+		}
+		// Only the current build is bundled, so its files are put directly in the tool directory
+		if err := copyToolDir(toolDirs[i], dstDir, filepath.Base(binPaths[i]), filepath.Base(dstBin)); err != nil {
+			return 0, errors.WithMessagef(err, "failed to bundle tool %s", t)
+		}
+		if binDir := filepath.Dir(binPaths[i]); binDir != toolDirs[i] {
+			if err := copyToolDir(binDir, dstDir, filepath.Base(binPaths[i]), filepath.Base(dstBin)); err != nil {
+				return 0, errors.WithMessagef(err, "failed to bundle tool %s", t)
+			}
+		}
+	}
+	layoutPath := filepath.Join(dir, BundleCacheDir, cache.LayoutFileName)
+	if err := os.MkdirAll(filepath.Dir(layoutPath), 0o755); err != nil {
+		return 0, errors.Wrapf(err, "failed to create directory %s", filepath.Dir(layoutPath))
+	}
+	if err := ioutil.WriteFile(layoutPath, []byte(strconv.Itoa(cache.LayoutVersion)+"\n"), 0o644); err != nil {
+		return 0, errors.Wrapf(err, "failed to write file %s", layoutPath)
+	}
+
+	lockfilePath := filepath.Join(dir, LockfileName)
+	var buf bytes.Buffer
+	s.mu.RLock()
+	_, err := s.lf.WriteTo(&buf)
+	s.mu.RUnlock()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to serialize lockfile")
+	}
+	if err := ioutil.WriteFile(lockfilePath, buf.Bytes(), 0o644); err != nil {
+		return 0, errors.Wrapf(err, "failed to write file %s", lockfilePath)
+	}
+	cfg := *s.config
+	cfg.Cache = &config.ProjectCache{Dir: BundleCacheDir}
+	data, err := json.MarshalIndent(&cfg, "", "  ")
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to serialize config")
+	}
+	configPath := filepath.Join(dir, config.ProjectFileName)
+	if err := ioutil.WriteFile(configPath, append(data, '\n'), 0o644); err != nil {
+		return 0, errors.Wrapf(err, "failed to write file %s", configPath)
+	}
+
+	if opts.ShedBinary != "" {
+		name := "shed"
+		if goos == "windows" {
+			name += ".exe"
+		}
+		if err := copyFile(opts.ShedBinary, filepath.Join(dir, name), 0o755); err != nil {
+			return 0, err
+		}
+	}
+	return len(tools), nil
+}
+
+// copyToolDir copies the files in the tool or build directory src to dst, renaming the binary from srcBin to dstBin.
+// The build file is skipped, since dst has no builds.
+func copyToolDir(src, dst, srcBin, dstBin string) error {
+	entries, err := ioutil.ReadDir(src)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read directory %s", src)
+	}
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return errors.Wrapf(err, "failed to create directory %s", dst)
+	}
+	for _, e := range entries {
+		if !e.Mode().IsRegular() || e.Name() == cache.BuildFileName {
+			continue
+		}
+		name := e.Name()
+		perm := os.FileMode(0o644)
+		if name == srcBin {
+			name = dstBin
+			perm = 0o755
+		}
+		if err := copyFile(filepath.Join(src, e.Name()), filepath.Join(dst, name), perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile copies the file src to dst, creating dst with the permissions perm.
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open file %s", src)
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create file %s", dst)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return errors.Wrapf(err, "failed to copy %s to %s", src, dst)
+	}
+	if err := out.Close(); err != nil {
+		return errors.Wrapf(err, "failed to write file %s", dst)
+	}
+	return nil
+}
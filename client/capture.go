@@ -0,0 +1,94 @@
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/getshiphub/shed/api"
+	"github.com/getshiphub/shed/internal/redact"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// captureTimeFormat is the format used for timestamps in capture file names.
+// It is based on RFC 3339 but avoids characters that are not allowed in file names on some platforms.
+const captureTimeFormat = "20060102T150405.000Z"
+
+// capture manages the files used to capture the output of a tool run.
+// Captured files are meant to be shared, so all data about the tool is redacted.
+type capture struct {
+	redaction    *redact.Policy
+	stdout       *os.File
+	stderr       *os.File
+	manifestPath string
+	manifest     api.CaptureManifest
+}
+
+// newCapture creates the capture files for a run of t in dir.
+// The files are prefixed by the redacted name of the tool and the current time.
+func newCapture(dir string, t tool.Tool, redaction *redact.Policy) (*capture, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Wrapf(err, "failed to create directory %q", dir)
+	}
+
+	start := time.Now()
+	name := redaction.Tool(t).Name()
+	prefix := filepath.Join(dir, name+"-"+start.UTC().Format(captureTimeFormat))
+	c := &capture{
+		redaction:    redaction,
+		manifestPath: prefix + ".json",
+		manifest:     api.CaptureManifest{SchemaVersion: api.SchemaVersion, StartTime: start},
+	}
+	var err error
+	c.stdout, err = os.Create(prefix + ".stdout.log")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create file %q", prefix+".stdout.log")
+	}
+	c.stderr, err = os.Create(prefix + ".stderr.log")
+	if err != nil {
+		c.stdout.Close()
+		return nil, errors.Wrapf(err, "failed to create file %q", prefix+".stderr.log")
+	}
+	c.manifest.Stdout = filepath.Base(c.stdout.Name())
+	c.manifest.Stderr = filepath.Base(c.stderr.Name())
+	return c, nil
+}
+
+// tee returns a writer that writes to both w and f. w may be nil.
+func tee(w io.Writer, f *os.File) io.Writer {
+	if w == nil {
+		return f
+	}
+	return io.MultiWriter(w, f)
+}
+
+// finish closes the capture files and writes the manifest for report.
+func (c *capture) finish(report *RunReport) error {
+	c.stdout.Close()
+	c.stderr.Close()
+
+	c.manifest.Tool = c.redaction.ImportPath(report.Tool.ImportPath)
+	c.manifest.Version = report.Tool.Version
+	c.manifest.Args = report.Args
+	for _, a := range report.Attempts {
+		ca := api.CaptureAttempt{ExitCode: a.ExitCode, Duration: a.Duration.String()}
+		if a.Err != nil {
+			ca.Error = c.redaction.String(report.Tool, a.Err.Error())
+		}
+		c.manifest.Attempts = append(c.manifest.Attempts, ca)
+	}
+
+	data, err := json.MarshalIndent(c.manifest, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to serialize capture manifest")
+	}
+	if err := ioutil.WriteFile(c.manifestPath, data, 0o644); err != nil {
+		return errors.Wrapf(err, "failed to write capture manifest %q", c.manifestPath)
+	}
+	report.CaptureManifest = c.manifestPath
+	return nil
+}
@@ -2,28 +2,73 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/config"
+	"github.com/getshiphub/shed/features"
+	"github.com/getshiphub/shed/internal/filelock"
+	"github.com/getshiphub/shed/internal/ratelimit"
+	"github.com/getshiphub/shed/internal/redact"
+	"github.com/getshiphub/shed/internal/retry"
+	"github.com/getshiphub/shed/internal/taskcache"
+	"github.com/getshiphub/shed/internal/trust"
 	"github.com/getshiphub/shed/internal/util"
 	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/policy"
+	"github.com/getshiphub/shed/releasenotes"
+	"github.com/getshiphub/shed/remote"
+	"github.com/getshiphub/shed/resolver"
 	"github.com/getshiphub/shed/tool"
+	"github.com/getshiphub/shed/vanity"
+	"github.com/getshiphub/shed/vulndb"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/mod/module"
 )
 
 const LockfileName = "shed.lock"
 
+// ErrNoCache is returned when an operation requires the cache
+// but the Shed instance was created with WithNoCache.
+var ErrNoCache = errors.New("shed was created without a cache")
+
+// ErrFrozen is returned by InstallSet.Apply when the Frozen option is used and installing
+// the tools would change the lockfile.
+var ErrFrozen = errors.New("lockfile is frozen")
+
+// ErrLocked is returned when another shed process is using the lockfile or the cache,
+// and waiting for it timed out or was cancelled.
+var ErrLocked = filelock.ErrLocked
+
+// ProjectDirName is the name of the directory where shed stores project specific data,
+// such as cached task outputs. It is located in the project root and should not be
+// checked into source control.
+const ProjectDirName = ".shed"
+
+// RemoteCacheTokenEnvVar is the environment variable containing the token used
+// to authenticate with the remote cache.
+const RemoteCacheTokenEnvVar = "SHED_REMOTE_CACHE_TOKEN"
+
 // noneVersion is a special module version that signifies the module should be removed.
 const noneVersion = "none"
 
 // ResolveLockfilePath resolves the path to the nearest shed lockfile starting at dir.
 // It will keep searching parent directories until either a lockfile is found,
 // or the root directory is reached. If no lockfile is found, an empty string will be returned.
+//
+// Only regular files are considered lockfiles. Directories and symlinks named shed.lock are
+// skipped, so a symlink can't make shed use a lockfile from outside of the project.
 func ResolveLockfilePath(dir string) string {
 	// "" is synonymous with "."
 	// This makes sure we do at least one check in the current directory
@@ -33,7 +78,7 @@ func ResolveLockfilePath(dir string) string {
 	var prev string
 	for dir != prev {
 		p := filepath.Join(dir, LockfileName)
-		if util.FileOrDirExists(p) {
+		if util.IsRegularFile(p) {
 			return p
 		}
 		prev = dir
@@ -43,16 +88,76 @@ func ResolveLockfilePath(dir string) string {
 }
 
 // Shed provides the API for managing tool dependencies with shed.
+//
+// A Shed instance is safe for concurrent use by multiple goroutines. Changes
+// to the lockfile are serialized, so concurrent installs and uninstalls will
+// not lose each other's changes.
 type Shed struct {
-	cache        *cache.Cache
+	cache *cache.Cache
+	// mu guards lf and writing the lockfile.
+	mu           sync.RWMutex
 	lf           *lockfile.Lockfile
 	lockfilePath string
-	logger       logrus.FieldLogger
+	config       *config.Project
+	configPath   string
+	taskCache    *taskcache.Cache
+	remote       remote.Backend
+	// remoteReadOnly prevents pushing to remote.
+	remoteReadOnly bool
+	redaction      *redact.Policy
+	// Tools that aren't in lf are looked up in globalLf, nil means there is no global lockfile.
+	globalLf           *lockfile.Lockfile
+	globalLockfilePath string
+	// Used to create the default cache.
+	cacheDir    string
+	sharedCache bool
+	moduleCache bool
+	// Settings for the caches of the go command, see WithIsolatedCache and WithGoCaches.
+	isolatedCache bool
+	goModCache    string
+	goBuildCache  string
+	noCache       bool
+	// Maximum number of tools downloaded at once, 0 means no limit.
+	downloadConcurrency int
+	// Limits the rate of downloads shed makes itself.
+	downloadLimiter *ratelimit.Limiter
+	// Used for cache images, nil means use the default client.
+	ociClient *remote.OCIClient
+	// Install tools only from the vendor directory, see WithOffline.
+	offline bool
+	// Resolves versions of tools, nil means the go command resolves them.
+	resolver resolver.Resolver
+	// Rules that installed tools must follow, nil means any tool can be installed.
+	policy *policy.Policy
+	// Used to describe updates, nil means use the defaults.
+	vulnDB       *vulndb.Client
+	releaseNotes *releasenotes.GitHub
+	// Value of GOPROXY used to install tools, empty means use the environment.
+	goProxy string
+	// How long to wait for other shed processes, 0 means no limit.
+	lockTimeout time.Duration
+	// How operations that use the network are retried, nil means use the config or the defaults.
+	retry *retry.Policy
+	// Running version of shed, empty means the version required by the config isn't checked.
+	shedVersion string
+	// Settings for experimental features that take precedence over the config, and the resolved features.
+	featureLayers []features.Layer
+	features      *features.Set
+	// Used for trust on first use, nil means all tools are trusted.
+	trustStore *trust.Store
+	trustFunc  TrustFunc
+	// Old tool names that a deprecation warning has been logged for.
+	warnedRenames sync.Map
+	// historyMu guards the run and install history files.
+	historyMu sync.Mutex
+	logger    logrus.FieldLogger
 }
 
 // NewShed creates a new Shed instance. Options can be provided to customize the created Shed instance.
 //
-// By default, the lockfile path used is './shed.lock' and the cache directory is 'os.UserCacheDir()/shed'.
+// By default, the lockfile path used is './shed.lock' and the cache directory is 'cache.DefaultDir()'.
+// The config file is looked for in the same directory as the lockfile. If the config file
+// configures a project cache, it is used instead of the cache directory.
 func NewShed(opts ...Option) (*Shed, error) {
 	s := &Shed{}
 	for _, opt := range opts {
@@ -63,6 +168,9 @@ func NewShed(opts ...Option) (*Shed, error) {
 	if s.lockfilePath == "" {
 		s.lockfilePath = LockfileName
 	}
+	if s.configPath == "" {
+		s.configPath = filepath.Join(filepath.Dir(s.lockfilePath), config.ProjectFileName)
+	}
 	if s.logger == nil {
 		// Logging is disabled by default, but we don't want to have to check
 		// for nil all the time, so create a logger that logs to nowhere
@@ -70,17 +178,84 @@ func NewShed(opts ...Option) (*Shed, error) {
 		logger.Out = ioutil.Discard
 		s.logger = logger
 	}
-	if s.cache == nil {
-		userCacheDir, err := os.UserCacheDir()
+	if s.taskCache == nil {
+		s.taskCache = taskcache.New(filepath.Join(s.projectRoot(), ProjectDirName, "tasks"))
+	}
+
+	if err := s.readConfig(); err != nil {
+		return nil, err
+	}
+	if s.shedVersion != "" {
+		if err := s.config.CheckShedVersion(s.shedVersion); err != nil {
+			return nil, errors.WithMessagef(err, "failed to check shed version required by %s", s.configPath)
+		}
+	}
+	if s.retry == nil {
+		s.retry = &retry.Policy{Attempts: defaultRetryAttempts, BaseDelay: defaultRetryBaseDelay, Jitter: defaultRetryJitter}
+		if r := s.config.Install.Retry; r != nil {
+			s.retry = &retry.Policy{Attempts: r.Attempts, BaseDelay: time.Duration(r.BaseDelay), Jitter: r.Jitter}
+		}
+	}
+	// The project is the lowest layer so that the user and environment can override it
+	s.features = features.Resolve(append([]features.Layer{{Source: "project", Values: s.config.Features}}, s.featureLayers...)...)
+	if s.remote == nil && s.config.RemoteCache != nil {
+		rc := s.config.RemoteCache
+		b, err := remote.New(rc.URL, os.Getenv(RemoteCacheTokenEnvVar))
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to find user cache directory")
+			return nil, errors.Wrapf(err, "failed to setup remote cache from %s", s.configPath)
+		}
+		s.remote = b
+		s.remoteReadOnly = rc.ReadOnly
+	}
+	if s.noCache {
+		s.cache = nil
+	} else if s.cache == nil {
+		if pc := s.config.Cache; pc != nil {
+			// The project cache takes precedence since the project relies on the tools being there
+			cacheDir := filepath.Join(s.projectRoot(), filepath.FromSlash(pc.Dir))
+			// The project may not be trusted, so it must not be able to make shed write outside of it
+			if err := util.CheckNoSymlinks(s.projectRoot(), cacheDir); err != nil {
+				return nil, errors.Wrapf(err, "invalid cache directory %s in %s", pc.Dir, s.configPath)
+			}
+			cacheDir, err := filepath.Abs(cacheDir)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to get absolute path of cache directory %s", pc.Dir)
+			}
+			s.cacheDir = cacheDir
+		}
+		if s.cacheDir == "" {
+			cacheDir, err := cache.DefaultDir()
+			if err != nil {
+				return nil, err
+			}
+			s.cacheDir = cacheDir
 		}
-		s.cache = cache.New(filepath.Join(userCacheDir, "shed"), cache.WithLogger(s.logger))
+		cacheOpts := []cache.Option{
+			cache.WithLogger(s.logger),
+			cache.WithShared(s.sharedCache),
+			cache.WithModuleCache(s.moduleCache),
+			cache.WithIsolated(s.isolatedCache),
+			cache.WithGoModCache(s.goModCache),
+			cache.WithGoBuildCache(s.goBuildCache),
+			cache.WithDiagnose(checkImportPath),
+			cache.WithLockTimeout(s.lockTimeout),
+			cache.WithRetry(s.retry.Attempts, s.retry.BaseDelay, s.retry.Jitter),
+			cache.WithDownloadLimiter(s.downloadLimiter),
+		}
+		if s.remote != nil && s.features.Enabled(features.RemoteTools) && !s.offline {
+			cacheOpts = append(cacheOpts, cache.WithRemote(limitedBackend{s.remote, s.downloadLimiter}, s.remoteReadOnly))
+		}
+		s.cache = cache.New(s.cacheDir, cacheOpts...)
+	}
+	s.redaction = redact.New(s.config.Redaction)
+	if err := s.readGlobalLockfile(); err != nil {
+		return nil, err
 	}
 
 	f, err := os.Open(s.lockfilePath)
 	if os.IsNotExist(err) {
 		// No lockfile, create an empty one
+		s.logger.WithField("path", s.lockfilePath).Debug("lockfile does not exist, using an empty one")
 		s.lf = &lockfile.Lockfile{}
 		return s, nil
 	}
@@ -93,9 +268,46 @@ func NewShed(opts ...Option) (*Shed, error) {
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to parse lockfile %s", s.lockfilePath)
 	}
+	s.logger.WithFields(logrus.Fields{
+		"path":    s.lockfilePath,
+		"version": s.lf.FileVersion(),
+	}).Debug("read lockfile")
 	return s, nil
 }
 
+// checkImportPath looks for problems with vanity import paths when a download fails.
+func checkImportPath(ctx context.Context, importPath string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return vanity.Check(ctx, importPath)
+}
+
+// projectRoot returns the directory containing the project config file.
+// All paths in the config are relative to it.
+func (s *Shed) projectRoot() string {
+	return filepath.Dir(s.configPath)
+}
+
+// readConfig reads the project config file. A config file is optional,
+// so if it does not exist, an empty config is used.
+func (s *Shed) readConfig() error {
+	f, err := os.Open(s.configPath)
+	if os.IsNotExist(err) {
+		s.config = &config.Project{}
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to open file %s", s.configPath)
+	}
+	defer f.Close()
+
+	s.config, err = config.Parse(f)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse config %s", s.configPath)
+	}
+	return nil
+}
+
 // Option is a function that takes a Shed instance and applies a configuration to it.
 type Option func(*Shed)
 
@@ -106,7 +318,17 @@ func WithLockfilePath(lfp string) Option {
 	}
 }
 
+// WithConfigPath sets the path to the project config file.
+func WithConfigPath(cfp string) Option {
+	return func(s *Shed) {
+		s.configPath = cfp
+	}
+}
+
 // WithLogger sets a logger that should be used for writing debug messages.
+// It is also used by the cache unless WithCache is used. At debug level, it
+// describes how tools are resolved, downloaded, and built, including each command
+// run by the go command, and when the lockfile is read and written.
 // By default no logging is done.
 func WithLogger(logger logrus.FieldLogger) Option {
 	return func(s *Shed) {
@@ -114,6 +336,50 @@ func WithLogger(logger logrus.FieldLogger) Option {
 	}
 }
 
+// WithCacheDir sets the directory of the cache used for installing tools.
+// If dir is empty, cache.DefaultDir is used. It is ignored if WithCache is used.
+func WithCacheDir(dir string) Option {
+	return func(s *Shed) {
+		s.cacheDir = dir
+	}
+}
+
+// WithSharedCache enables shared mode for the cache used for installing tools.
+// See cache.WithShared for details. It is ignored if WithCache is used.
+func WithSharedCache(shared bool) Option {
+	return func(s *Shed) {
+		s.sharedCache = shared
+	}
+}
+
+// WithModuleCache makes the cache used for installing tools have its own module cache, instead of
+// using the module cache of the go command. See cache.WithModuleCache for details.
+// It is ignored if WithCache is used.
+func WithModuleCache(enabled bool) Option {
+	return func(s *Shed) {
+		s.moduleCache = enabled
+	}
+}
+
+// WithIsolatedCache makes the cache used for installing tools have its own module cache and build cache,
+// for hermetic builds. See cache.WithIsolated for details. It is ignored if WithCache is used.
+func WithIsolatedCache(enabled bool) Option {
+	return func(s *Shed) {
+		s.isolatedCache = enabled
+	}
+}
+
+// WithGoCaches sets the module cache and build cache of the go command used for installing tools, so that
+// multiple projects and CI jobs can share them, regardless of GOMODCACHE and GOCACHE in the environment.
+// Either can be empty to use the one in the environment. See cache.WithGoModCache and cache.WithGoBuildCache
+// for details. It is ignored if WithCache is used.
+func WithGoCaches(modCache, buildCache string) Option {
+	return func(s *Shed) {
+		s.goModCache = modCache
+		s.goBuildCache = buildCache
+	}
+}
+
 // WithCache sets the Cache instance to use for installing tools.
 func WithCache(c *cache.Cache) Option {
 	return func(s *Shed) {
@@ -121,51 +387,561 @@ func WithCache(c *cache.Cache) Option {
 	}
 }
 
+// WithNoCache creates a Shed instance without a cache. This is useful for read-only
+// operations like List that never need the cache, since the cache directory is not
+// required to exist or be writable. Any operation that requires the cache will
+// return ErrNoCache. WithCache is ignored if this is used.
+func WithNoCache() Option {
+	return func(s *Shed) {
+		s.noCache = true
+	}
+}
+
+// WithRemoteCache sets the backend used to share task outputs and built tools between machines.
+// If readOnly is true, outputs and tools are only pulled from the backend and never pushed.
+// This takes precedence over the remote cache in the config file. Tools are only shared if the
+// remote-tools feature is enabled, and only through a cache created by NewShed, a cache provided
+// with WithCache must use cache.WithRemote.
+func WithRemoteCache(b remote.Backend, readOnly bool) Option {
+	return func(s *Shed) {
+		s.remote = b
+		s.remoteReadOnly = readOnly
+	}
+}
+
+// WithFeatures sets layers of settings for experimental features, ex: from the user config.
+// They take precedence over the features in the config file, and later layers take precedence
+// over earlier ones. See package features for details.
+func WithFeatures(layers ...features.Layer) Option {
+	return func(s *Shed) {
+		s.featureLayers = append(s.featureLayers, layers...)
+	}
+}
+
+// limitedBackend limits the rate that artifacts are downloaded from a remote cache.
+type limitedBackend struct {
+	remote.Backend
+	limiter *ratelimit.Limiter
+}
+
+func (b limitedBackend) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	rc, err := b.Backend.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{ratelimit.NewReader(ctx, rc, b.limiter), rc}, nil
+}
+
+// WithDownloadLimits limits the network usage of shed. concurrent is the maximum number
+// of tools that are downloaded and installed at once. bytesPerSec is the maximum rate that
+// shed downloads artifacts from the remote cache, release assets, and prebuilt binaries,
+// shared by all downloads. A value of 0 means no limit. By default there are no limits.
+// bytesPerSec has no effect on a cache provided with WithCache, see cache.WithDownloadLimiter.
+//
+// Modules are downloaded by the go command, whose transfer rate cannot be limited,
+// so limiting concurrent is the way to reduce network usage when tools are built from source.
+func WithDownloadLimits(concurrent int, bytesPerSec int64) Option {
+	return func(s *Shed) {
+		s.downloadConcurrency = concurrent
+		s.downloadLimiter = ratelimit.New(bytesPerSec)
+	}
+}
+
+// WithLockTimeout sets how long to wait for another shed process that is changing the lockfile
+// or the cache before giving up with ErrLocked. Operations that take a context also stop waiting
+// if it is done. It has no effect on a cache provided with WithCache, see cache.WithLockTimeout.
+// A value of 0, the default, means wait until the other process is done.
+func WithLockTimeout(d time.Duration) Option {
+	return func(s *Shed) {
+		s.lockTimeout = d
+	}
+}
+
+// The default retry policy for operations that use the network, see WithRetry.
+const (
+	defaultRetryAttempts  = 3
+	defaultRetryBaseDelay = time.Second
+	defaultRetryJitter    = 0.5
+)
+
+// WithRetry sets how operations that use the network, like downloading modules and resolving versions,
+// are retried when they fail with a transient error, ex: a dropped connection. See cache.WithRetry for details.
+// It takes precedence over the retry install setting of the config. It has no effect on a cache provided
+// with WithCache. By default operations are attempted 3 times, waiting about 1s before the first retry.
+func WithRetry(attempts int, baseDelay time.Duration, jitter float64) Option {
+	return func(s *Shed) {
+		s.retry = &retry.Policy{Attempts: attempts, BaseDelay: baseDelay, Jitter: jitter}
+	}
+}
+
+// WithShedVersion sets the version of shed that is running, ex: v0.8.0. If the project config has a
+// shedVersion that doesn't allow it, NewShed returns an error wrapping a *config.ShedVersionError.
+// If version is empty, the default, the required version isn't checked.
+func WithShedVersion(version string) Option {
+	return func(s *Shed) {
+		s.shedVersion = version
+	}
+}
+
+// WithGoProxy sets the value of GOPROXY used by the go command to install tools.
+// It replaces the value in the environment. By default, GOPROXY is not changed.
+func WithGoProxy(proxy string) Option {
+	return func(s *Shed) {
+		s.goProxy = proxy
+	}
+}
+
+// WithResolver sets the resolver used to determine which version of a tool to install when
+// the version is not exact, ex: it is empty, 'latest', or a module query like 'v1.2'.
+// This allows the versions that can be installed to be controlled, ex: to only allow
+// versions that have been approved.
+//
+// By default, versions are resolved by the go command while downloading the tool,
+// which uses GOPROXY. Exact versions, including all versions in shed.lock, are never resolved.
+func WithResolver(r resolver.Resolver) Option {
+	return func(s *Shed) {
+		s.resolver = r
+	}
+}
+
+// WithPolicy sets the policy that tools must follow to be installed by InstallSet.Apply, ex: to only allow
+// tools from approved sources. The tools are checked before they are installed, and again once their versions
+// are resolved, before the lockfile is written, so the lockfile is never changed to break the policy.
+// If DenyVulnerable is set, vulnerabilities are looked up in the database set with WithVulnDB.
+// By default, any tool can be installed.
+func WithPolicy(p *policy.Policy) Option {
+	return func(s *Shed) {
+		s.policy = p
+	}
+}
+
+// Redact returns a copy of t with its import path redacted according to the redaction
+// policy in the config file. If there is no policy, t is returned unchanged.
+// This should be used for any data about tools that is exported from shed.
+func (s *Shed) Redact(t tool.Tool) tool.Tool {
+	return s.redaction.Tool(t)
+}
+
+// Features returns the state of experimental features, resolved from the config file
+// and the layers set with WithFeatures.
+func (s *Shed) Features() *features.Set {
+	return s.features
+}
+
 // CacheDir returns the OS filesystem directory where the shed cache is located.
+// If there is no cache because WithNoCache was used, an empty string is returned.
 func (s *Shed) CacheDir() string {
+	if s.cache == nil {
+		return ""
+	}
 	return s.cache.Dir()
 }
 
+// CheckCache checks the cache for problems, such as files with incorrect permissions
+// when the cache is shared between users. See cache.Cache.Doctor for details.
+// The binary of each tool in the lockfile is also checked, since a binary that is missing, modified,
+// or built for the wrong platform fails in confusing ways when the tool is run, see cache.Cache.CheckBinary.
+// Use RepairCache to reinstall the broken tools.
+func (s *Shed) CheckCache() ([]cache.Problem, error) {
+	if s.cache == nil {
+		return nil, ErrNoCache
+	}
+	problems, err := s.cache.Doctor()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[cache.Problem]bool)
+	for _, p := range problems {
+		seen[p] = true
+	}
+	for _, t := range s.List() {
+		toolProblems, err := s.cache.CheckBinary(t)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed to check tool %s", t)
+		}
+		for _, p := range toolProblems {
+			// The cache check also finds binaries that aren't executable
+			if !seen[p] {
+				seen[p] = true
+				problems = append(problems, p)
+			}
+		}
+	}
+	return problems, nil
+}
+
+// RepairCache reinstalls the tools in the lockfile whose binaries are broken, see CheckCache.
+// They are downloaded and built again from scratch, replacing their files in the cache. It returns
+// the tools that were reinstalled. If some tools couldn't be reinstalled, the error is a lockfile.ErrorList
+// with the failures. Problems with the cache that aren't about a tool, like permissions in a shared cache,
+// aren't repaired.
+//
+// The provided context is used to terminate the builds if the context becomes done before they complete.
+func (s *Shed) RepairCache(ctx context.Context) ([]tool.Tool, error) {
+	if s.cache == nil {
+		return nil, ErrNoCache
+	}
+	var repaired []tool.Tool
+	var errs lockfile.ErrorList
+	for _, t := range s.List() {
+		problems, err := s.cache.CheckBinary(t)
+		if err != nil {
+			errs = append(errs, errors.WithMessagef(err, "failed to check tool %s", t))
+			continue
+		}
+		if len(problems) == 0 {
+			continue
+		}
+		s.logger.Infof("Reinstalling %s since its binary is broken: %s", t, problems[0].Issue)
+		installOpts, err := s.installOptions(t)
+		if err == nil {
+			_, err = s.cache.Install(ctx, t, append(installOpts, cache.InstallRebuild())...)
+		}
+		if err != nil {
+			errs = append(errs, errors.WithMessagef(err, "failed to reinstall tool %s", t))
+			continue
+		}
+		repaired = append(repaired, t)
+	}
+	if len(errs) > 0 {
+		return repaired, errs
+	}
+	return repaired, nil
+}
+
 // CleanCache removes the cache directory and all contents from the filesystem.
-func (s *Shed) CleanCache() error {
-	return s.cache.Clean()
+// ctx is used to stop waiting for other shed processes using the cache.
+func (s *Shed) CleanCache(ctx context.Context) error {
+	if s.cache == nil {
+		return ErrNoCache
+	}
+	return s.cache.Clean(ctx)
 }
 
+// updateLockfile calls fn to change the lockfile and writes it to disk. Another shed process
+// may have changed the lockfile since it was read, so it is locked and read again before fn is called,
+// which keeps the changes of both. fn is called with s.mu held. If fn or writing fails, the changes
+// made by fn are undone so s matches the lockfile on disk.
+func (s *Shed) updateLockfile(ctx context.Context, fn func() error) error {
+	p := filepath.Join(s.projectRoot(), ProjectDirName, "lock")
+	if s.lockTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.lockTimeout)
+		defer cancel()
+	}
+	l, err := filelock.Acquire(ctx, p, filelock.Options{
+		OnWait: func() {
+			s.logger.Infof("Waiting for another shed process to finish updating %s", s.lockfilePath)
+		},
+	})
+	if err != nil {
+		return errors.WithMessagef(err, "failed to lock %s", s.lockfilePath)
+	}
+	defer func() {
+		if err := l.Unlock(); err != nil {
+			s.logger.WithError(err).Debugf("failed to unlock %s", p)
+		}
+	}()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.Open(s.lockfilePath)
+	if err == nil {
+		lf, err := lockfile.Parse(f)
+		f.Close()
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse lockfile %s", s.lockfilePath)
+		}
+		s.lf = lf
+	} else if !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to open file %s", s.lockfilePath)
+	}
+	var prev bytes.Buffer
+	if _, err := s.lf.WriteTo(&prev); err != nil {
+		return errors.Wrap(err, "failed to copy lockfile")
+	}
+	err = fn()
+	if err == nil {
+		err = s.writeLockfile()
+	}
+	if err != nil {
+		lf, perr := lockfile.Parse(&prev)
+		if perr != nil {
+			return errors.Wrapf(err, "failed to restore lockfile: %v", perr)
+		}
+		s.lf = lf
+		s.logger.WithError(err).Debug("lockfile update failed, discarded changes")
+		return err
+	}
+	return nil
+}
+
+// writeLockfile writes the lockfile to disk. s.mu must be held.
+// It is written to a temp file which replaces the lockfile, so the lockfile
+// is never left partially written if shed fails or is killed.
 func (s *Shed) writeLockfile() error {
-	f, err := os.OpenFile(s.lockfilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	var perm os.FileMode = 0o644
+	if info, err := os.Stat(s.lockfilePath); err == nil {
+		perm = info.Mode().Perm()
+	}
+	f, err := ioutil.TempFile(filepath.Dir(s.lockfilePath), ".shed.lock-")
 	if err != nil {
-		return errors.Wrapf(err, "failed to create/open file %s", s.lockfilePath)
+		return errors.Wrapf(err, "failed to create temp file for %s", s.lockfilePath)
 	}
-	defer f.Close()
+	// Clean up if anything fails, does nothing once the file is renamed
+	defer os.Remove(f.Name())
 	if _, err = s.lf.WriteTo(f); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "failed to write lockfile to %s", s.lockfilePath)
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrapf(err, "failed to write lockfile to %s", s.lockfilePath)
+	}
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		return errors.Wrapf(err, "failed to set permissions of %s", f.Name())
+	}
+	if err := os.Rename(f.Name(), s.lockfilePath); err != nil {
 		return errors.Wrapf(err, "failed to write lockfile to %s", s.lockfilePath)
 	}
+	s.logger.WithField("path", s.lockfilePath).Debug("wrote lockfile")
 	return nil
 }
 
 // Install computes a set of tools that should be installed. It can be given zero or
-// more tools as arguments. These will be unioned with the tools in the lockfile
-// to produce a final set of tools to install. Install will return an InstallSet instance
-// which can be used to perform the actual installation.
+// more tools as arguments. Install will return an InstallSet instance which can be used
+// to perform the actual installation.
 //
 // Install does not modify any state, therefore, if you wish to abort the install simply
 // discard the returned InstallSet.
 //
-// All tool names provided must be full import paths, not binary names.
-// If a tool name is invalid, Install will return an error.
+// A tool name can either be a full import path or the name of a tool in the lockfile
+// (i.e. the name of the binary). Import paths are treated as new pins and will install
+// the latest version of the tool if no version is provided. Tool names are resolved to
+// the version in the lockfile.
+//
+// Which tools are installed depends on whether any names are given:
+//
+//   - If only import paths are given, or no tools at all, they are unioned with the tools
+//     in the lockfile, so every tool in the lockfile is installed as well.
+//   - If at least one name is given, only the given tools are installed, including any import
+//     paths given alongside the names. This allows installing just a subset of the locked tools.
+//
+// In both cases, tools in the lockfile that aren't installed are kept in the lockfile.
+//
+// If a tool name is invalid or is not in the lockfile, Install will return a lockfile.ErrorList
+// with a *ToolError for each invalid tool.
 func (s *Shed) Install(toolNames ...string) (*InstallSet, error) {
+	specs := make([]ToolSpec, 0, len(toolNames))
+	var errs lockfile.ErrorList
+	for _, toolName := range toolNames {
+		spec, err := ParseToolSpec(toolName)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		specs = append(specs, spec)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return s.InstallSpecs(specs)
+}
+
+// ParseToolSpec parses a tool name, as accepted by Install, into a ToolSpec.
+// A tool name containing a slash is an import path, optionally with a version,
+// anything else is the name of a tool in the lockfile.
+func ParseToolSpec(toolName string) (ToolSpec, error) {
+	// Import paths always contain a slash since the first element must be a domain
+	// name, so anything else must be the name of a locked tool.
+	if !strings.Contains(toolName, "/") {
+		return ToolSpec{Name: toolName}, nil
+	}
+	// This also serves to validate the the given tool name is a valid module name
+	// Use ParseLax since the version might be a query that should be passed to go get.
+	t, err := tool.ParseLax(toolName)
+	if err != nil {
+		return ToolSpec{}, &ToolError{Kind: ErrInvalidSpec, Spec: toolName, Err: errors.WithMessagef(err, "invalid tool name %s", toolName)}
+	}
+	return ToolSpec{ImportPath: t.ImportPath, Version: t.Version}, nil
+}
+
+// ToolSpec describes a tool to install. It allows tools to be provided to InstallSpecs
+// as structured data instead of strings that need to be formatted and parsed.
+// Exactly one of Name or ImportPath must be set.
+type ToolSpec struct {
+	// Name is the name of a tool in the lockfile, either the name of the binary
+	// or the full import path. The version in the lockfile is installed.
+	Name string
+	// ImportPath is the import path of the package containing the main executable.
+	ImportPath string
+	// Version is the version to install when ImportPath is set. It can be any module
+	// query supported by 'go get', ex: a version, a branch, or a commit.
+	// If empty, the latest version is installed. If it is a branch, the tool tracks
+	// the branch, see tool.Tool.Branch.
+	Version string
+	// BuildFlags are the flags used to build the tool when ImportPath is set.
+	// If they are not set and the tool is already in the lockfile, the build flags
+	// in the lockfile are kept.
+	BuildFlags tool.BuildFlags
+	// Alias is the name to give the tool when ImportPath is set, see tool.Tool.Alias.
+	// If it is not set and the tool is already in the lockfile, the alias in the
+	// lockfile is kept.
+	Alias string
+	// Release is set to download the tool from a release when ImportPath is set, see tool.Release.
+	// Version must be an exact version in that case. If it is not set and the tool is already
+	// in the lockfile, the release in the lockfile is kept.
+	Release *tool.Release
+	// Groups are added to the groups of the tool, see tool.Tool.Groups. Unlike the other fields,
+	// they can be used with Name to add a tool in the lockfile to groups. The tool stays in the
+	// groups it is already in.
+	Groups []string
+	// Why is the note to save for the tool, see tool.Tool.Why. Like Groups, it can be used with Name.
+	// If it is not set, the note in the lockfile is kept.
+	Why string
+	// Reproducible is the exact version of Go to build the tool reproducibly with when ImportPath is set,
+	// ex: 'go1.22.4', see tool.Reproducible. If it is not set and the tool is already in the lockfile,
+	// the tool is built the same way as in the lockfile.
+	Reproducible string
+}
+
+func (ts ToolSpec) String() string {
+	if ts.Name != "" {
+		return ts.Name
+	}
+	if ts.Version == "" {
+		return ts.ImportPath
+	}
+	return ts.ImportPath + "@" + ts.Version
+}
+
+// InstallSpecs is like Install but takes structured tool specs. Specs with a Name behave
+// like tool names passed to Install and specs with an ImportPath behave like import paths.
+// If any spec has a Name, only the given tools are installed, see Install.
+func (s *Shed) InstallSpecs(specs []ToolSpec) (*InstallSet, error) {
 	// Collect all the tools that need to be installed.
-	// Merge the given tools with what exists in the lockfile.
+	// Merge the given tools with what exists in the lockfile, unless any were given by name.
 	seenTools := make(map[string]bool)
 	var tools []tool.Tool
+	selective := false
 
 	var errs lockfile.ErrorList
-	for _, toolName := range toolNames {
-		// This also serves to validate the the given tool name is a valid module name
-		// Use ParseLax since the version might be a query that should be passed to go get.
-		t, err := tool.ParseLax(toolName)
+	for _, spec := range specs {
+		switch {
+		case spec.Name != "" && (spec.ImportPath != "" || spec.Version != "" || !spec.BuildFlags.IsZero() || spec.Alias != "" || spec.Release != nil || spec.Reproducible != ""):
+			errs = append(errs, specError(spec, tool.Tool{}, errors.Errorf("invalid tool %s: name cannot be combined with an import path, version, build flags, alias, release, or reproducible build", spec)))
+			continue
+		case spec.Name != "":
+			selective = true
+			t, err := s.getTool(spec.Name)
+			if err != nil {
+				errs = append(errs, specError(spec, tool.Tool{}, errors.WithMessagef(err, "invalid tool name %s", spec.Name)))
+				continue
+			}
+			if err := checkGroups(spec.Groups); err != nil {
+				errs = append(errs, specError(spec, t, errors.WithMessagef(err, "invalid tool %s", spec)))
+				continue
+			}
+			t = t.WithGroups(spec.Groups...)
+			if spec.Why != "" {
+				t.Why = spec.Why
+			}
+			if !seenTools[t.ImportPath] {
+				seenTools[t.ImportPath] = true
+				tools = append(tools, t)
+			}
+			continue
+		case spec.ImportPath == "" || strings.ContainsRune(spec.ImportPath, '@'):
+			errs = append(errs, specError(spec, tool.Tool{}, errors.Errorf("invalid tool %s: must have a name or an import path without a version", spec)))
+			continue
+		}
+
+		t, err := tool.ParseLax(spec.ImportPath)
 		if err != nil {
-			errs = append(errs, errors.WithMessagef(err, "invalid tool name %s", toolName))
+			errs = append(errs, specError(spec, tool.Tool{}, errors.WithMessagef(err, "invalid tool %s", spec)))
+			continue
+		}
+		t.Version = spec.Version
+		t.BuildFlags = spec.BuildFlags
+		t.Alias = spec.Alias
+		t.Release = spec.Release
+		t.Why = spec.Why
+		if tool.IsBranch(t.Version) {
+			t.Branch = t.Version
+		}
+		if spec.Reproducible != "" {
+			t.Reproducible = &tool.Reproducible{GoVersion: spec.Reproducible}
+		}
+		if t.Alias != "" {
+			if err := tool.CheckAlias(t.Alias); err != nil {
+				errs = append(errs, specError(spec, t, errors.WithMessagef(err, "invalid tool %s", spec)))
+				continue
+			}
+		}
+		if err := checkGroups(spec.Groups); err != nil {
+			errs = append(errs, specError(spec, t, errors.WithMessagef(err, "invalid tool %s", spec)))
+			continue
+		}
+		t = t.WithGroups(spec.Groups...)
+		// The go command requires the exact import path, so use the one from the
+		// lockfile in case the tool was typed with a different case
+		s.mu.RLock()
+		if lt, err := s.lf.GetTool(t.ImportPath); err == nil {
+			t.ImportPath = lt.ImportPath
+			if t.BuildFlags.IsZero() {
+				t.BuildFlags = lt.BuildFlags
+			}
+			if t.Alias == "" {
+				t.Alias = lt.Alias
+			}
+			t = t.WithGroups(lt.GroupList()...)
+			if t.Why == "" {
+				t.Why = lt.Why
+			}
+			if t.Release == nil && t.BuildFlags.IsZero() && lt.Release != nil {
+				r := *lt.Release
+				if t.Version != lt.Version {
+					// The hashes are of the assets of the version in the lockfile
+					r.Sums = nil
+				}
+				t.Release = &r
+			}
+			if t.Reproducible == nil && t.Release == nil && lt.Reproducible != nil {
+				r := *lt.Reproducible
+				t.Reproducible = &r
+			}
+			if t.Reproducible != nil {
+				// The hashes are of the binaries built the way the lockfile says
+				if lr := lt.Reproducible; lr != nil && lr.GoVersion == t.Reproducible.GoVersion && t.Version == lt.Version && t.BuildFlags == lt.BuildFlags {
+					t.Reproducible.Sums = lr.Sums
+				} else {
+					t.Reproducible.Sums = nil
+				}
+			}
+		}
+		if err := tool.CheckReproducible(t); err != nil {
+			s.mu.RUnlock()
+			errs = append(errs, specError(spec, t, errors.WithMessagef(err, "invalid tool %s", spec)))
+			continue
+		}
+		if t.Release != nil {
+			err = tool.CheckRelease(t)
+			if err == nil && !t.HasSemver() {
+				err = errors.Errorf("release tool must have an exact version")
+			}
+			if err != nil {
+				s.mu.RUnlock()
+				errs = append(errs, specError(spec, t, errors.WithMessagef(err, "invalid tool %s", spec)))
+				continue
+			}
+		}
+		// Check for conflicts now instead of after the tool has been installed
+		err = s.lf.CheckAlias(t)
+		s.mu.RUnlock()
+		if err != nil {
+			errs = append(errs, specError(spec, t, errors.WithMessagef(err, "invalid tool %s", spec)))
 			continue
 		}
 		seenTools[t.ImportPath] = true
@@ -174,8 +950,13 @@ func (s *Shed) Install(toolNames ...string) (*InstallSet, error) {
 	if len(errs) > 0 {
 		return nil, errs
 	}
+	if selective {
+		return &InstallSet{s: s, tools: tools}, nil
+	}
 
 	// Take union with lockfile
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	it := s.lf.Iter()
 	for it.Next() {
 		t := it.Value()
@@ -193,6 +974,10 @@ type InstallSet struct {
 	s        *Shed
 	tools    []tool.Tool
 	notifyCh chan<- tool.Tool
+	// timings are the timings of the last call to Apply
+	timings *InstallTimings
+	// summary is how the last call to Apply changed the lockfile
+	summary *InstallSummary
 }
 
 // Len returns the number of tools in the InstallSet.
@@ -209,12 +994,68 @@ func (is *InstallSet) Notify(ch chan<- tool.Tool) {
 }
 
 // Apply will install each tool in the InstallSet and add them to the lockfile.
+// Options can be provided to customize the install, such as WithProgress.
+//
+// Apply is all or nothing: the lockfile is only changed if every tool is installed, and it is
+// replaced atomically so it is never left partially written. If any tool fails, the error is
+// a lockfile.ErrorList with a *ToolError for each tool that failed, and the lockfile is left as is.
+// Tools that were installed are kept in the cache, since they will be needed once the failures
+// are fixed, but nothing uses them until then. Use BestEffort to add them to the lockfile instead.
+//
+// Once the tools are installed, the ones with a version probe are run to record the version
+// they report, see config.VersionProbe and VerifyReportedVersions.
+//
+// How long installing each tool took is recorded and can be read with Timings once Apply returns.
+// How the versions of the tools in the lockfile changed can be read with Summary.
 //
 // The provided context is used to terminate the install if the context becomes
 // done before the install completes on its own.
-func (is *InstallSet) Apply(ctx context.Context) error {
-	successCh := make(chan tool.Tool)
-	failedCh := make(chan error)
+func (is *InstallSet) Apply(ctx context.Context, opts ...ApplyOption) error {
+	if is.s.cache == nil {
+		return ErrNoCache
+	}
+	var o applyOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	is.summary = nil
+	applyStart := time.Now()
+	o.timings = &timingRecorder{}
+	defer func() {
+		is.timings = o.timings.result(time.Since(applyStart))
+	}()
+	if o.platform != "" {
+		if _, _, err := tool.ParsePlatform(o.platform); err != nil {
+			return err
+		}
+	}
+	if o.frozen {
+		if err := is.checkFrozen(); err != nil {
+			return err
+		}
+	}
+	if err := is.s.checkPolicy(ctx, is.tools, false); err != nil {
+		return err
+	}
+	if is.s.config.EnforceToolchain {
+		o.enforceToolchain = true
+	}
+	if o.progress != nil {
+		buildTimes, err := is.s.cache.BuildTimes()
+		if err != nil {
+			// Only the estimate is lost
+			is.s.logger.WithError(err).Debug("failed to read build times")
+		}
+		o.progress = newEstimator(is.tools, buildTimes, is.s.downloadConcurrency).wrap(o.progress)
+	}
+	// Buffered so the goroutines can finish if Apply is aborted
+	successCh := make(chan installedTool, len(is.tools))
+	failedCh := make(chan error, len(is.tools))
+	// Used to limit the number of tools being installed at once, nil if there is no limit
+	var sem chan struct{}
+	if is.s.downloadConcurrency > 0 {
+		sem = make(chan struct{}, is.s.downloadConcurrency)
+	}
 	for _, tl := range is.tools {
 		go func(t tool.Tool) {
 			// go get supports the special version suffix '@none' which means remove the module.
@@ -222,28 +1063,37 @@ func (is *InstallSet) Apply(ctx context.Context) error {
 			// Support this for consistency since we want to shed to just work with all module queries.
 			if t.Version == noneVersion {
 				is.s.logger.Debugf("Uninstalling tool: %s", t.ImportPath)
-				successCh <- t
+				o.report(Event{Kind: EventDone, Tool: t})
+				successCh <- installedTool{tool: t}
 				return
 			}
 
-			is.s.logger.Debugf("Installing tool: %v", t)
-			installed, err := is.s.cache.Install(ctx, t)
+			if sem != nil {
+				sem <- struct{}{}
+				defer func() { <-sem }()
+			}
+			start := time.Now()
+			installed, source, err := is.s.installTool(ctx, t, o)
 			if err != nil {
-				failedCh <- errors.WithMessagef(err, "failed to install tool %s", t)
+				failedCh <- err
 				return
 			}
-			successCh <- installed
+			successCh <- installedTool{tool: installed, source: source, duration: time.Since(start)}
 		}(tl)
 	}
 
 	var completedTools []tool.Tool
+	var installed []installedTool
 	var errs lockfile.ErrorList
 	for i := 0; i < len(is.tools); i++ {
 		select {
-		case t := <-successCh:
-			completedTools = append(completedTools, t)
+		case it := <-successCh:
+			completedTools = append(completedTools, it.tool)
+			if it.source != 0 {
+				installed = append(installed, it)
+			}
 			if is.notifyCh != nil {
-				is.notifyCh <- t
+				is.notifyCh <- it.tool
 			}
 		case err := <-failedCh:
 			// Continue even if a tool failed because they are cached so it will
@@ -253,37 +1103,252 @@ func (is *InstallSet) Apply(ctx context.Context) error {
 			return errors.Wrap(ctx.Err(), "installation was aborted")
 		}
 	}
+	if o.recordStats {
+		if err := is.s.recordInstall(installed); err != nil {
+			// The stats are only informational, so don't fail the install
+			is.s.logger.WithError(err).Warn("Failed to record install stats")
+		}
+	}
+	if err := is.s.checkPolicy(ctx, completedTools, true); err != nil {
+		var policyErrs lockfile.ErrorList
+		if !errors.As(err, &policyErrs) {
+			return err
+		}
+		return append(errs, policyErrs...)
+	}
+	if len(errs) > 0 && (!o.bestEffort || o.frozen || len(completedTools) == 0) {
+		for _, t := range completedTools {
+			if t.Version != noneVersion {
+				is.s.logger.Infof("Installed %s but did not add it to %s since other tools failed", t, is.s.lockfilePath)
+			}
+		}
+		return errs
+	}
+
+	is.s.recordLockfile()
+	if o.platform == "" {
+		// Tools installed for another platform can't be run
+		is.s.probeVersions(ctx, completedTools)
+	}
+	if o.frozen {
+		return nil
+	}
+	var summary *InstallSummary
+	err := is.s.updateLockfile(ctx, func() error {
+		old, err := copyLockfile(is.s.lf)
+		if err != nil {
+			return errors.Wrap(err, "failed to copy lockfile")
+		}
+		for _, t := range completedTools {
+			if t.Version == noneVersion {
+				// Uninstall the tool by removing it from the lockfile.
+				// Unlike Uninstall() this will not error if the tool is not in the lockfile,
+				// instead it will be silently ignored.
+				t.Version = ""
+				is.s.lf.DeleteTool(t)
+				continue
+			}
+			if err := is.s.lf.PutTool(t); err != nil {
+				return errors.Wrapf(err, "failed to add tool %v to lockfile", t)
+			}
+		}
+		summary = summarizeChanges(old, is.s.lf)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	is.summary = summary
 	if len(errs) > 0 {
 		return errs
 	}
+	return nil
+}
+
+// installedTool is a tool installed by InstallSet.Apply.
+type installedTool struct {
+	tool     tool.Tool
+	source   cache.Source
+	duration time.Duration
+}
+
+// installTool installs t in the cache, resolving its version first if a resolver is set
+// or the version is a range. Progress is reported using o. It returns the installed tool
+// and where its binary came from.
+func (s *Shed) installTool(ctx context.Context, t tool.Tool, o applyOptions) (tool.Tool, cache.Source, error) {
+	start := time.Now()
+	timer := newStageTimer(start)
+	report := func(ev Event) {
+		if o.timings != nil {
+			timer.observe(ev, time.Now())
+			if ev.Kind == EventDone || ev.Kind == EventFailed {
+				o.timings.add(timer.timing)
+			}
+		}
+		o.report(ev)
+	}
+	if r := s.versionResolver(t); r != nil && !t.HasSemver() && !s.offline {
+		report(Event{Kind: EventResolving, Tool: t})
+		resolved, err := s.resolve(ctx, r, t)
+		if err != nil {
+			err = installError(t, errors.WithMessagef(err, "failed to resolve version of tool %s", t))
+			report(Event{Kind: EventFailed, Tool: t, Err: err, Duration: time.Since(start)})
+			return t, 0, err
+		}
+		t = resolved
+	}
+	s.logger.Debugf("Installing tool: %v", t)
+	installOpts, err := s.installOptions(t)
+	if err != nil {
+		err = installError(t, errors.WithMessagef(err, "failed to install tool %s", t))
+		report(Event{Kind: EventFailed, Tool: t, Err: err, Duration: time.Since(start)})
+		return t, 0, err
+	}
+	// Hooks aren't run for other platforms since the binary can't be run
+	hooks := s.config.Tool(t).InstallHooks
+	if o.platform != "" {
+		hooks = nil
+	}
+	if hooks != nil && len(hooks.PreInstall) > 0 {
+		if err := s.runInstallHook(ctx, "preInstall", hooks.PreInstall, t); err != nil {
+			err = installError(t, errors.WithMessagef(err, "failed to install tool %s", t))
+			report(Event{Kind: EventFailed, Tool: t, Err: err, Duration: time.Since(start)})
+			return t, 0, err
+		}
+	}
+	progress := cache.InstallProgress(func(stage cache.Stage) {
+		report(Event{Kind: stageEvents[stage], Tool: t})
+	})
+	var source cache.Source
+	sourceOpt := cache.InstallSource(func(src cache.Source) {
+		source = src
+	})
+	t.Platform = o.platform
+	installOpts = append(installOpts, progress, sourceOpt)
+	if o.enforceToolchain {
+		installOpts = append(installOpts, cache.InstallCheckToolchain())
+	}
+	if pb := s.config.Tool(t).Prebuilt; pb != nil && s.features.Enabled(features.Prebuilt) {
+		installOpts = append(installOpts, cache.InstallPrebuilt(cache.Prebuilt{
+			URL:       pb.URL,
+			Asset:     pb.Asset,
+			Binary:    pb.Binary,
+			Checksums: pb.Checksums,
+		}))
+	}
+	installed, err := s.cache.Install(ctx, t, installOpts...)
+	// The platform only decides where the tool is installed, the lockfile is the same for every platform
+	t.Platform = ""
+	installed.Platform = ""
+	if err == nil && hooks != nil && len(hooks.PostInstall) > 0 {
+		err = s.runPostInstallHook(ctx, hooks.PostInstall, installed, source)
+	}
+	if err != nil {
+		err = installError(t, errors.WithMessagef(err, "failed to install tool %s", t))
+		report(Event{Kind: EventFailed, Tool: t, Err: err, Duration: time.Since(start)})
+		return t, 0, err
+	}
+	report(Event{Kind: EventDone, Tool: installed, Source: source, Duration: time.Since(start)})
+	return installed, source, nil
+}
+
+// versionResolver returns the resolver used to resolve the version of t, or nil if the go command resolves it.
+// The go command doesn't support caret and tilde ranges, so they are resolved with the module proxies in GOPROXY
+// if no resolver is set.
+func (s *Shed) versionResolver(t tool.Tool) resolver.Resolver {
+	if s.resolver != nil || !resolver.IsRange(t.Version) {
+		return s.resolver
+	}
+	return s.proxyResolver(t)
+}
+
+// listResolver returns the resolver used to list the versions of t, which is the resolver set
+// with WithResolver, or the module proxies the go command would use to install t if there is none.
+func (s *Shed) listResolver(t tool.Tool) resolver.Resolver {
+	if s.resolver != nil {
+		return s.resolver
+	}
+	return s.proxyResolver(t)
+}
+
+// proxyResolver returns a resolver that uses the same module proxies the go command would use to install t.
+func (s *Shed) proxyResolver(t tool.Tool) resolver.Resolver {
+	if goProxy := s.toolGoProxy(t); goProxy != "" {
+		s.logger.WithFields(logrus.Fields{
+			"tool":    t.ImportPath,
+			"goproxy": goProxy,
+		}).Debug("using module proxies from config")
+		return resolver.ProxyFromList(goProxy).WithRetry(s.retry.Attempts, s.retry.BaseDelay, s.retry.Jitter)
+	}
+	return resolver.ProxyFromEnv().WithRetry(s.retry.Attempts, s.retry.BaseDelay, s.retry.Jitter)
+}
+
+// resolve returns t with its version resolved to an exact version using r.
+func (s *Shed) resolve(ctx context.Context, r resolver.Resolver, t tool.Tool) (tool.Tool, error) {
+	var mv module.Version
+	var err error
+	if t.Version == "" || t.Version == "latest" {
+		mv, err = r.ResolveLatest(ctx, t.ImportPath)
+	} else {
+		mv, err = r.ResolveConstraint(ctx, t.ImportPath, t.Version)
+	}
+	if err != nil {
+		s.logger.WithError(err).Debugf("failed to resolve %s", t)
+		return t, err
+	}
+	// Make sure the resolver returned something usable, since it can be implemented by anyone
+	resolved := t
+	resolved.Version = mv.Version
+	if !resolved.HasSemver() {
+		return t, errors.Errorf("resolver returned invalid version %q", mv.Version)
+	}
+	if mv.Path != t.ImportPath && !strings.HasPrefix(t.ImportPath, mv.Path+"/") {
+		return t, errors.Errorf("resolver returned module %s which does not provide %s", mv.Path, t.ImportPath)
+	}
+	s.logger.Debugf("Resolved %s to %s", t, resolved.Version)
+	return resolved, nil
+}
 
-	for _, t := range completedTools {
-		if t.Version == noneVersion {
-			// Uninstall the tool by removing it from the lockfile.
-			// Unlike Uninstall() this will not error if the tool is not in the lockfile,
-			// instead it will be silently ignored.
-			t.Version = ""
-			is.s.lf.DeleteTool(t)
+// checkFrozen checks that each tool in the InstallSet is in the lockfile as is.
+// Only the fields that determine what is installed are compared, since the others,
+// like the module hash, are not known until the tool is installed.
+func (is *InstallSet) checkFrozen() error {
+	is.s.mu.RLock()
+	defer is.s.mu.RUnlock()
+	var errs lockfile.ErrorList
+	for _, t := range is.tools {
+		lt, err := is.s.lf.GetTool(t.ImportPath)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(ErrFrozen, "tool %s is not in the lockfile", t))
 			continue
 		}
-		if err := is.s.lf.PutTool(t); err != nil {
-			return errors.Wrapf(err, "failed to add tool %v to lockfile", t)
+		if lt.Version != t.Version || lt.BuildFlags != t.BuildFlags || lt.Alias != t.Alias || lt.Groups != t.Groups || lt.Why != t.Why || lt.Branch != t.Branch || reproducibleGo(lt) != reproducibleGo(t) {
+			errs = append(errs, errors.Wrapf(ErrFrozen, "tool %s is different in the lockfile", t))
 		}
 	}
-	if err := is.s.writeLockfile(); err != nil {
-		return err
+	if len(errs) > 0 {
+		return errs
 	}
 	return nil
 }
 
+// reproducibleGo returns the version of Go t is built reproducibly with, or an empty string if it isn't.
+func reproducibleGo(t tool.Tool) string {
+	if t.Reproducible == nil {
+		return ""
+	}
+	return t.Reproducible.GoVersion
+}
+
 // Uninstall uninstalls the given tools. This only removes them from the lockfile.
 // The actual tool binaries are not removed, since they might be used by other projects.
-// To remove the actual binaries, use CleanCache.
-func (s *Shed) Uninstall(toolNames ...string) error {
+// To remove the actual binaries, use CleanCache. Uninstalled tools are recorded so they can be
+// added back with Restore. ctx is used to stop waiting for other shed processes updating the lockfile.
+func (s *Shed) Uninstall(ctx context.Context, toolNames ...string) error {
 	var tools []tool.Tool
 	var errs lockfile.ErrorList
 	for _, toolName := range toolNames {
-		t, err := s.lf.GetTool(toolName)
+		t, err := s.getTool(toolName)
 		if err != nil {
 			errs = append(errs, err)
 			continue
@@ -294,33 +1359,161 @@ func (s *Shed) Uninstall(toolNames ...string) error {
 		return errs
 	}
 
-	for _, t := range tools {
-		s.logger.Debugf("Uninstalling tool: %v", t)
-		s.lf.DeleteTool(t)
+	return s.updateLockfile(ctx, func() error {
+		for _, t := range tools {
+			s.logger.Debugf("Uninstalling tool: %v", t)
+			s.lf.DeleteTool(t)
+		}
+		return s.recordUninstalled(tools)
+	})
+}
+
+// PathOption customizes which binary ToolPath returns.
+type PathOption func(*pathOptions)
+
+type pathOptions struct {
+	platform string
+	// rebuildCtx is set if stale binaries are rebuilt, see Rebuild.
+	rebuildCtx context.Context
+}
+
+// ForPlatform makes ToolPath return the binary of the tool for platform, in the form 'GOOS/GOARCH',
+// that was installed with TargetPlatform. By default the binary for the current platform is returned.
+func ForPlatform(platform string) PathOption {
+	return func(o *pathOptions) {
+		o.platform = hostPlatform(platform)
 	}
+}
 
-	if err := s.writeLockfile(); err != nil {
-		return err
+// Rebuild makes ToolPath check if the binary of the tool is stale, ex: because it was deleted from the cache
+// or built with an older version of Go, and rebuild it if it is. See cache.Cache.CheckStale for when a binary
+// is stale. ctx is used to stop the rebuild. By default ToolPath returns an error matching cache.ErrStale if the
+// binary does not exist, and doesn't check if an existing binary is stale.
+func Rebuild(ctx context.Context) PathOption {
+	return func(o *pathOptions) {
+		o.rebuildCtx = ctx
 	}
-	return nil
+}
+
+// hostPlatform returns platform, or an empty string if it is the platform shed is running on,
+// since tools for the current platform are installed without one.
+func hostPlatform(platform string) string {
+	if platform == tool.Platform(runtime.GOOS, runtime.GOARCH) {
+		return ""
+	}
+	return platform
 }
 
 // ToolPath returns the absolute path to the binary of the tool if it is installed.
 // If the tool cannot be found, or toolName is invalid, an error will be returned.
-func (s *Shed) ToolPath(toolName string) (string, error) {
-	t, err := s.lf.GetTool(toolName)
+// If the binary was modified after it was installed, the error matches cache.ErrBinaryModified.
+// Options can be provided to select the binary for another platform, see ForPlatform.
+func (s *Shed) ToolPath(toolName string, opts ...PathOption) (string, error) {
+	var o pathOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	t, err := s.findTool(toolName)
 	if err != nil {
 		return "", err
 	}
+	if s.cache == nil {
+		return "", ErrNoCache
+	}
+	t.Platform = o.platform
+	if o.rebuildCtx != nil {
+		if err := s.rebuildStale(o.rebuildCtx, t); err != nil {
+			return "", err
+		}
+	}
 	return s.cache.ToolPath(t)
 }
 
-// List returns a list of all the tools specified in the lockfile.
-func (s *Shed) List() []tool.Tool {
+// ToolPathVersion is like ToolPath, but returns the binary of the given version of the tool instead of the
+// version in the lockfile, which allows many versions of a tool to be used side by side, ex: for different
+// branches of a project. version must be an exact version, ex: v1.50.0. The lockfile is not changed.
+//
+// The tool is looked up in the lockfile by toolName to find its import path and build flags. A full import path
+// of a tool that isn't in the lockfile can also be used. The version must already be in the cache, ex: because
+// another project installed it, unless Rebuild is used, in which case it is installed if it is missing.
+func (s *Shed) ToolPathVersion(toolName, version string, opts ...PathOption) (string, error) {
+	var o pathOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	t, err := s.toolVersion(toolName, version)
+	if err != nil {
+		return "", err
+	}
+	if s.cache == nil {
+		return "", ErrNoCache
+	}
+	t.Platform = o.platform
+	if o.rebuildCtx != nil {
+		if err := s.rebuildStale(o.rebuildCtx, t); err != nil {
+			return "", err
+		}
+	}
+	return s.cache.ToolPath(t)
+}
+
+// toolVersion returns the tool named toolName at version, which doesn't have to be the version in the lockfile.
+func (s *Shed) toolVersion(toolName, version string) (tool.Tool, error) {
+	t, err := s.findTool(toolName)
+	if errors.Is(err, lockfile.ErrNotFound) && strings.Contains(toolName, "/") {
+		t, err = tool.ParseLax(toolName)
+	}
+	if err != nil {
+		return t, err
+	}
+	if t.Version != version {
+		t.Version = version
+		// The hash is only known for the version in the lockfile
+		t.Sum = ""
+	}
+	if !t.HasSemver() {
+		return t, errors.Errorf("invalid version %q of %s, must be an exact version", version, t.ImportPath)
+	}
+	return t, nil
+}
+
+// rebuildStale rebuilds t if its binary is stale, see cache.Cache.CheckStale.
+func (s *Shed) rebuildStale(ctx context.Context, t tool.Tool) error {
+	err := s.cache.CheckStale(ctx, t)
+	var serr *cache.StaleError
+	if !errors.As(err, &serr) {
+		return err
+	}
+	s.logger.Infof("Rebuilding %s since it is stale: %s", t, serr.Reason)
+	installOpts, err := s.installOptions(t)
+	if err == nil {
+		_, err = s.cache.Install(ctx, t, append(installOpts, cache.InstallRebuild())...)
+	}
+	if err != nil {
+		return errors.WithMessagef(err, "failed to rebuild tool %s", t)
+	}
+	return nil
+}
+
+// List returns a list of all the tools specified in the lockfile, sorted by import path.
+// Options can be provided to only list some of the tools, see InGroups, or to also list
+// the tools in the global lockfile, see IncludeGlobal.
+func (s *Shed) List(opts ...ListOption) []tool.Tool {
+	var o listOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	var tools []tool.Tool
 	it := s.lf.Iter()
 	for it.Next() {
-		tools = append(tools, it.Value())
+		if t := it.Value(); o.matches(t) {
+			tools = append(tools, t)
+		}
+	}
+	if o.global {
+		tools = append(tools, s.globalTools(o)...)
 	}
 	sort.Slice(tools, func(i, j int) bool {
 		return tools[i].ImportPath < tools[j].ImportPath
@@ -1,18 +1,42 @@
 package client_test
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
 	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/getshiphub/shed/cache"
 	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/config"
+	"github.com/getshiphub/shed/features"
+	"github.com/getshiphub/shed/internal/filelock"
+	"github.com/getshiphub/shed/internal/ratelimit"
 	"github.com/getshiphub/shed/internal/util"
 	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/remote"
+	"github.com/getshiphub/shed/resolver"
 	"github.com/getshiphub/shed/tool"
+	"github.com/getshiphub/shed/vanity"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"golang.org/x/mod/module"
 )
 
 func TestResolveLockfilePath(t *testing.T) {
@@ -83,6 +107,29 @@ func TestResolveLockfilePath(t *testing.T) {
 	}
 }
 
+func TestResolveLockfilePathNotRegular(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks requires extra privileges on windows")
+	}
+	td := t.TempDir()
+	outside := filepath.Join(td, "outside.lock")
+	createLockfile(t, outside, nil)
+	project := filepath.Join(td, "project")
+	dir := filepath.Join(project, "a", "b")
+	if err := os.MkdirAll(filepath.Join(dir, "shed.lock"), 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(project, "a", "shed.lock")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	createLockfile(t, filepath.Join(project, "shed.lock"), nil)
+
+	// Both the directory and the symlink are skipped
+	if got, want := client.ResolveLockfilePath(dir), filepath.Join(project, "shed.lock"); got != want {
+		t.Errorf("got lockfile path %s, want %s", got, want)
+	}
+}
+
 func TestClientCache(t *testing.T) {
 	td := t.TempDir()
 	s, err := client.NewShed(client.WithCache(cache.New(td)))
@@ -97,7 +144,7 @@ func TestClientCache(t *testing.T) {
 		t.Errorf("expected %s to exist, but it doesn't", s.CacheDir())
 	}
 
-	err = s.CleanCache()
+	err = s.CleanCache(context.Background())
 	if err != nil {
 		t.Errorf("want nil error, got %v", err)
 	}
@@ -106,6 +153,290 @@ func TestClientCache(t *testing.T) {
 	}
 }
 
+func TestCacheSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks requires extra privileges on windows")
+	}
+	td := t.TempDir()
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	c := cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo))
+	s, err := client.NewShed(client.WithLockfilePath(filepath.Join(td, "shed.lock")), client.WithCache(c))
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	installSet, err := s.Install("github.com/cszatmary/go-fish@v0.1.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	// Replace the tool with a symlink to a copy outside of the cache
+	toolDir, err := c.ToolDir(s.List()[0])
+	if err != nil {
+		t.Fatalf("failed to get tool dir: %v", err)
+	}
+	outside := filepath.Join(td, "outside")
+	if err := os.Rename(toolDir, outside); err != nil {
+		t.Fatalf("failed to move tool: %v", err)
+	}
+	if err := os.Symlink(outside, toolDir); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	if _, err := s.ToolPath("go-fish"); err == nil || !strings.Contains(err.Error(), "is a symlink") {
+		t.Errorf("got error %v, want symlink error", err)
+	}
+
+	// Installing a tool in a symlinked directory fails instead of writing outside of the cache
+	outside = filepath.Join(td, "outside-shopify")
+	if err := os.Mkdir(outside, 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(c.Dir(), "tools", "github.com", "!shopify")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	installSet, err = s.Install("github.com/Shopify/ejson/cmd/ejson@v1.1.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err == nil {
+		t.Error("want non-nil error, got nil")
+	}
+	if entries, err := ioutil.ReadDir(outside); err != nil || len(entries) > 0 {
+		t.Errorf("got %d files written outside of the cache, %v, want none", len(entries), err)
+	}
+}
+
+func TestCacheLayout(t *testing.T) {
+	td := t.TempDir()
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	c := cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo))
+	s, err := client.NewShed(client.WithLockfilePath(filepath.Join(td, "shed.lock")), client.WithCache(c))
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	installSet, err := s.Install("github.com/cszatmary/go-fish@v0.1.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	v, err := cache.ReadLayoutVersion(c.Dir())
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if v != cache.LayoutVersion {
+		t.Errorf("got layout version %d, want %d", v, cache.LayoutVersion)
+	}
+	tl := s.List()[0]
+	dir, err := c.ToolDir(tl)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	wantDir := filepath.Join(c.Dir(), "tools", "github.com", "cszatmary", "go-fish@v0.1.0")
+	if dir != wantDir {
+		t.Errorf("got tool dir %s, want %s", dir, wantDir)
+	}
+	for _, name := range []string{"go.mod", "go.sum", cache.BuildFileName} {
+		if !util.FileOrDirExists(filepath.Join(dir, name)) {
+			t.Errorf("want %s to exist in the tool dir", name)
+		}
+	}
+	key, err := ioutil.ReadFile(filepath.Join(dir, cache.BuildFileName))
+	if err != nil {
+		t.Fatalf("failed to read build file: %v", err)
+	}
+	buildDir := filepath.Join(dir, "builds", strings.TrimSpace(string(key)))
+	for _, name := range []string{"go-fish", "shed.sum", "provenance.json"} {
+		if !util.FileOrDirExists(filepath.Join(buildDir, name)) {
+			t.Errorf("want %s to exist in the build dir", name)
+		}
+	}
+	binPath, err := c.BinaryPath(tl)
+	if err != nil || binPath != filepath.Join(buildDir, "go-fish") {
+		t.Errorf("got binary path %s, %v, want %s", binPath, err, filepath.Join(buildDir, "go-fish"))
+	}
+
+	// Building with another version of go adds a build instead of replacing the binary
+	mockGo, err = cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	c2 := cache.New(c.Dir(), cache.WithGo(mockGo))
+	if _, err := c2.Install(context.Background(), tl, cache.InstallEnv("GOVERSION=go1.99.0")); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	newBinPath, err := c.BinaryPath(tl)
+	if err != nil || newBinPath == binPath || filepath.Dir(filepath.Dir(newBinPath)) != filepath.Join(dir, "builds") {
+		t.Errorf("got binary path %s, %v, want a new build in %s", newBinPath, err, filepath.Join(dir, "builds"))
+	}
+	if !util.FileOrDirExists(binPath) {
+		t.Errorf("want previous build %s to be kept", binPath)
+	}
+	// Building with the first version again reuses its build
+	if _, err := c2.Install(context.Background(), tl); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if p, err := c.BinaryPath(tl); err != nil || p != binPath {
+		t.Errorf("got binary path %s, %v, want %s", p, err, binPath)
+	}
+
+	// A cache with a newer layout can't be used
+	err = ioutil.WriteFile(filepath.Join(c.Dir(), cache.LayoutFileName), []byte("3\n"), 0o644)
+	if err != nil {
+		t.Fatalf("failed to write layout version: %v", err)
+	}
+	installSet, err = s.Install("github.com/Shopify/ejson/cmd/ejson@v1.1.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err == nil {
+		t.Error("want error installing into a cache with a different layout, got nil")
+	}
+}
+
+func TestCacheLayoutUpgrade(t *testing.T) {
+	td := t.TempDir()
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	c := cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo))
+	s, err := client.NewShed(client.WithLockfilePath(filepath.Join(td, "shed.lock")), client.WithCache(c))
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	installSet, err := s.Install("github.com/cszatmary/go-fish@v0.1.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	// Turn the cache into one that uses layout version 1, where the binary is in the tool dir
+	dir, err := c.ToolDir(s.List()[0])
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	binPath, err := s.ToolPath("go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	for _, name := range []string{"go-fish", "shed.sum", "provenance.json"} {
+		if err := os.Rename(filepath.Join(filepath.Dir(binPath), name), filepath.Join(dir, name)); err != nil {
+			t.Fatalf("failed to move %s: %v", name, err)
+		}
+	}
+	for _, p := range []string{filepath.Join(dir, "builds"), filepath.Join(dir, cache.BuildFileName), filepath.Join(c.Dir(), cache.LayoutFileName)} {
+		if err := os.RemoveAll(p); err != nil {
+			t.Fatalf("failed to remove %s: %v", p, err)
+		}
+	}
+	if v, err := cache.ReadLayoutVersion(c.Dir()); err != nil || v != 1 {
+		t.Fatalf("got layout version %d, %v, want 1", v, err)
+	}
+
+	// Tools from version 1 are used as is, and the cache is upgraded on the next install
+	if p, err := s.ToolPath("go-fish"); err != nil || p != filepath.Join(dir, "go-fish") {
+		t.Errorf("got tool path %s, %v, want %s", p, err, filepath.Join(dir, "go-fish"))
+	}
+	installSet, err = s.Install("github.com/Shopify/ejson/cmd/ejson@v1.1.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if v, err := cache.ReadLayoutVersion(c.Dir()); err != nil || v != cache.LayoutVersion {
+		t.Errorf("got layout version %d, %v, want %d", v, err, cache.LayoutVersion)
+	}
+	if p, err := s.ToolPath("go-fish"); err != nil || p != filepath.Join(dir, "go-fish") {
+		t.Errorf("got tool path %s, %v, want %s", p, err, filepath.Join(dir, "go-fish"))
+	}
+}
+
+func TestSharedCache(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shared mode permissions are not supported on windows")
+	}
+
+	td := t.TempDir()
+	cacheDir := filepath.Join(td, "cache")
+	lockfilePath := filepath.Join(td, "shed.lock")
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	s, err := client.NewShed(
+		client.WithLockfilePath(lockfilePath),
+		client.WithCache(cache.New(cacheDir, cache.WithGo(mockGo), cache.WithShared(true))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	installSet, err := s.Install("github.com/cszatmary/go-fish@v0.1.0")
+	if err != nil {
+		t.Fatalf("failed to create install set: %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("failed to install tools: %v", err)
+	}
+
+	binPath, err := s.ToolPath("go-fish")
+	if err != nil {
+		t.Fatalf("failed to get tool path: %v", err)
+	}
+	info, err := os.Stat(binPath)
+	if err != nil {
+		t.Fatalf("failed to stat binary: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o555 {
+		t.Errorf("got binary permissions %o, want %o", perm, 0o555)
+	}
+	info, err = os.Stat(filepath.Join(cacheDir, "tools", "github.com"))
+	if err != nil {
+		t.Fatalf("failed to stat directory: %v", err)
+	}
+	if mode := info.Mode() & (os.ModeSetgid | os.ModePerm); mode != os.ModeSetgid|0o775 {
+		t.Errorf("got directory mode %s, want %s", mode, os.ModeSetgid|0o775)
+	}
+
+	problems, err := s.CheckCache()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("want no problems, got %v", problems)
+	}
+
+	// Simulate someone modifying a published tool
+	if err := os.Chmod(binPath, 0o755); err != nil {
+		t.Fatalf("failed to change permissions: %v", err)
+	}
+	problems, err = s.CheckCache()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if len(problems) != 1 || problems[0].Path != binPath {
+		t.Errorf("want problem with %s, got %v", binPath, problems)
+	}
+
+	// Read only published tools should not prevent cleaning
+	if err := s.CleanCache(context.Background()); err != nil {
+		t.Errorf("want nil error, got %v", err)
+	}
+}
+
 var availableTools = map[string]map[string]string{
 	"github.com/cszatmary/go-fish": {
 		"v0.1.0": "v0.1.0",
@@ -160,6 +491,25 @@ func readLockfile(t *testing.T, path string) *lockfile.Lockfile {
 	return lf
 }
 
+// newLockfileShed returns a client for a lockfile in td that contains tools, with the cache in td
+// as well. The tools that can be installed are mockTools, see cache.NewMockGo.
+func newLockfileShed(t *testing.T, td string, mockTools map[string]map[string]string, tools []tool.Tool) *client.Shed {
+	lockfilePath := filepath.Join(td, "shed.lock")
+	createLockfile(t, lockfilePath, tools)
+	mockGo, err := cache.NewMockGo(mockTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	s, err := client.NewShed(
+		client.WithLockfilePath(lockfilePath),
+		client.WithCache(cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	return s
+}
+
 func TestInstall(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -178,9 +528,9 @@ func TestInstall(t *testing.T) {
 			},
 			wantLen: 3,
 			wantTools: []tool.Tool{
-				{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
-				{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
-				{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.2"},
+				{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0", ModulePath: "github.com/cszatmary/go-fish"},
+				{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0", ModulePath: "github.com/golangci/golangci-lint"},
+				{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.2", ModulePath: "github.com/Shopify/ejson"},
 			},
 		},
 		{
@@ -193,9 +543,9 @@ func TestInstall(t *testing.T) {
 			},
 			wantLen: 3,
 			wantTools: []tool.Tool{
-				{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.0.0-20201203230243-22d10c9b658d"},
-				{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.28.3"},
-				{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.1.0"},
+				{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.0.0-20201203230243-22d10c9b658d", ModulePath: "github.com/cszatmary/go-fish"},
+				{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.28.3", ModulePath: "github.com/golangci/golangci-lint"},
+				{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.1.0", ModulePath: "github.com/Shopify/ejson"},
 			},
 		},
 		{
@@ -208,9 +558,9 @@ func TestInstall(t *testing.T) {
 			installTools: nil,
 			wantLen:      3,
 			wantTools: []tool.Tool{
-				{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
-				{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.28.3"},
-				{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.1.0"},
+				{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0", ModulePath: "github.com/cszatmary/go-fish"},
+				{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.28.3", ModulePath: "github.com/golangci/golangci-lint"},
+				{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.1.0", ModulePath: "github.com/Shopify/ejson"},
 			},
 		},
 		{
@@ -225,10 +575,23 @@ func TestInstall(t *testing.T) {
 			},
 			wantLen: 3,
 			wantTools: []tool.Tool{
-				{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
-				{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
+				{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0", ModulePath: "github.com/cszatmary/go-fish"},
+				{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0", ModulePath: "github.com/golangci/golangci-lint"},
+				{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.1.0", ModulePath: "github.com/Shopify/ejson"},
+			},
+		},
+		{
+			name: "update tool with different case",
+			lockfileTools: []tool.Tool{
 				{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.1.0"},
 			},
+			installTools: []string{
+				"GitHub.com/shopify/ejson/cmd/ejson@v1.2.2",
+			},
+			wantLen: 1,
+			wantTools: []tool.Tool{
+				{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.2", ModulePath: "github.com/Shopify/ejson"},
+			},
 		},
 		{
 			name: "remove tool",
@@ -243,8 +606,8 @@ func TestInstall(t *testing.T) {
 			},
 			wantLen: 4,
 			wantTools: []tool.Tool{
-				{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
-				{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.1.0"},
+				{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0", ModulePath: "github.com/cszatmary/go-fish"},
+				{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.1.0", ModulePath: "github.com/Shopify/ejson"},
 			},
 		},
 	}
@@ -300,6 +663,7 @@ func TestInstall(t *testing.T) {
 					t.Errorf("tool %v does not exist in lockfile", tl)
 					continue
 				}
+				wantTool.Sum = cache.MockSum(module.Version{Path: wantTool.ModulePath, Version: wantTool.Version})
 				if tl != wantTool {
 					t.Errorf("got %+v, want %+v", tl, wantTool)
 				}
@@ -347,70 +711,2502 @@ func TestInstallError(t *testing.T) {
 	}
 }
 
-func TestUninstall(t *testing.T) {
+func TestInstallByName(t *testing.T) {
+	tests := []struct {
+		name         string
+		installTools []string
+		wantTools    []tool.Tool
+	}{
+		{
+			name:         "locked tools only",
+			installTools: []string{"golangci-lint", "ejson"},
+			wantTools: []tool.Tool{
+				{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.28.3", ModulePath: "github.com/golangci/golangci-lint"},
+				{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.1.0", ModulePath: "github.com/Shopify/ejson"},
+			},
+		},
+		{
+			// Giving any tool by name only installs the given tools, even with import paths
+			name:         "locked tool and new tool",
+			installTools: []string{"ejson", "golang.org/x/tools/cmd/stringer@v0.0.0-20201211185031-d93e913c1a58"},
+			wantTools: []tool.Tool{
+				{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.1.0", ModulePath: "github.com/Shopify/ejson"},
+				{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58", ModulePath: "golang.org/x/tools"},
+			},
+		},
+		{
+			name:         "new tool before locked tool",
+			installTools: []string{"golang.org/x/tools/cmd/stringer@v0.0.0-20201211185031-d93e913c1a58", "ejson"},
+			wantTools: []tool.Tool{
+				{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58", ModulePath: "golang.org/x/tools"},
+				{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.1.0", ModulePath: "github.com/Shopify/ejson"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			td := t.TempDir()
+			lockfilePath := filepath.Join(td, "shed.lock")
+			mockGo, err := cache.NewMockGo(availableTools)
+			if err != nil {
+				t.Fatalf("failed to create mock go %v", err)
+			}
+			lockfileTools := []tool.Tool{
+				{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+				{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.28.3"},
+				{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.1.0"},
+			}
+			createLockfile(t, lockfilePath, lockfileTools)
+			s, err := client.NewShed(
+				client.WithLockfilePath(lockfilePath),
+				client.WithCache(cache.New(td, cache.WithGo(mockGo))),
+			)
+			if err != nil {
+				t.Fatalf("failed to create shed client %v", err)
+			}
+
+			installSet, err := s.Install(tt.installTools...)
+			if err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+			if installSet.Len() != len(tt.wantTools) {
+				t.Errorf("want install set len %d, got %d", len(tt.wantTools), installSet.Len())
+			}
+			if err := installSet.Apply(context.Background()); err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+
+			for _, wantTool := range tt.wantTools {
+				// ToolPath will return an error if the binary does not exist
+				if _, err := s.ToolPath(wantTool.ImportPath); err != nil {
+					t.Errorf("want nil error, got %v", err)
+				}
+			}
+			// Tools that were not selected must not be installed
+			if _, err := s.ToolPath("go-fish"); err == nil {
+				t.Errorf("want error for tool that was not installed, got nil")
+			}
+			// Tools that were not selected must remain in the lockfile, and new tools must be added
+			lf := readLockfile(t, lockfilePath)
+			for _, lt := range append(lockfileTools, tt.wantTools...) {
+				if _, err := lf.GetTool(lt.ImportPath); err != nil {
+					t.Errorf("want tool %v in lockfile, got %v", lt, err)
+				}
+			}
+		})
+	}
+}
+
+func TestInstallSpecs(t *testing.T) {
 	td := t.TempDir()
 	lockfilePath := filepath.Join(td, "shed.lock")
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
 	createLockfile(t, lockfilePath, []tool.Tool{
 		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
-		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
-		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.2"},
 	})
 	s, err := client.NewShed(
 		client.WithLockfilePath(lockfilePath),
-		client.WithCache(cache.New(td)),
+		client.WithCache(cache.New(td, cache.WithGo(mockGo))),
 	)
 	if err != nil {
 		t.Fatalf("failed to create shed client %v", err)
 	}
 
-	uninstallTools := []string{"go-fish", "golangci-lint"}
-	err = s.Uninstall(uninstallTools...)
+	installSet, err := s.InstallSpecs([]client.ToolSpec{
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
+		{ImportPath: "golang.org/x/tools/cmd/stringer"},
+	})
 	if err != nil {
-		t.Errorf("want nil error, got %v", err)
+		t.Fatalf("want nil error, got %v", err)
+	}
+	// Import paths are unioned with the lockfile just like with Install
+	if installSet.Len() != 3 {
+		t.Errorf("want install set len 3, got %d", installSet.Len())
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
 	}
-
 	lf := readLockfile(t, lockfilePath)
-	for _, tn := range uninstallTools {
-		_, err := lf.GetTool(tn)
-		if !errors.Is(err, lockfile.ErrNotFound) {
-			t.Errorf("want ErrNotFound, got %v", err)
+	wantTools := []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0", ModulePath: "github.com/cszatmary/go-fish"},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0", ModulePath: "github.com/golangci/golangci-lint"},
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58", ModulePath: "golang.org/x/tools"},
+	}
+	for _, wt := range wantTools {
+		wt.Sum = cache.MockSum(module.Version{Path: wt.ModulePath, Version: wt.Version})
+		if lt, err := lf.GetTool(wt.ImportPath); err != nil || lt != wt {
+			t.Errorf("got tool %v with error %v, want %v", lt, err, wt)
 		}
-		// ToolPath will return an error if the binary does not exist
-		_, err = s.ToolPath(tn)
-		if err == nil {
+	}
+
+	installSet, err = s.InstallSpecs([]client.ToolSpec{{Name: "golangci-lint"}})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if installSet.Len() != 1 {
+		t.Errorf("want install set len 1, got %d", installSet.Len())
+	}
+
+	invalid := []client.ToolSpec{
+		{},
+		{Name: "go-fish", Version: "v0.1.0"},
+		{Name: "go-fish", BuildFlags: tool.BuildFlags{Trimpath: true}},
+		{ImportPath: "github.com/cszatmary/go-fish@v0.1.0"},
+		{ImportPath: "not a path"},
+	}
+	for _, spec := range invalid {
+		if _, err := s.InstallSpecs([]client.ToolSpec{spec}); err == nil {
+			t.Errorf("want error for spec %+v, got nil", spec)
+		}
+	}
+}
+
+func TestInstallBuildFlags(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	s, err := client.NewShed(
+		client.WithLockfilePath(lockfilePath),
+		client.WithCache(cache.New(td, cache.WithGo(mockGo))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	flags := tool.BuildFlags{Tags: "netgo", Ldflags: "-X main.version=v0.1.0"}
+	installSet, err := s.InstallSpecs([]client.ToolSpec{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0", BuildFlags: flags},
+	})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	lt, err := readLockfile(t, lockfilePath).GetTool("go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if lt.BuildFlags != flags {
+		t.Errorf("got build flags %+v, want %+v", lt.BuildFlags, flags)
+	}
+
+	// The mock writes the build flags to the binary
+	binPath, err := s.ToolPath("go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	data, err := ioutil.ReadFile(binPath)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", binPath, err)
+	}
+	if want := "-tags=netgo -ldflags=-X main.version=v0.1.0"; string(data) != want {
+		t.Errorf("got build flags %q, want %q", data, want)
+	}
+
+	// Updating the version keeps the build flags
+	installSet, err = s.Install("github.com/cszatmary/go-fish@22d10c9b658df297b17b33c836a60fb943ef5a5f")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	lt, err = readLockfile(t, lockfilePath).GetTool("go-fish")
+	if err != nil || lt.BuildFlags != flags {
+		t.Errorf("got tool %+v with error %v, want build flags %+v", lt, err, flags)
+	}
+}
+
+func TestInstallBuildEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh as the C compiler")
+	}
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	s, err := client.NewShed(
+		client.WithLockfilePath(lockfilePath),
+		client.WithCache(cache.New(td, cache.WithGo(mockGo))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	env, err := tool.JoinBuildEnv([]string{"CGO_ENABLED=1", "CC=sh -c"})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	flags := tool.BuildFlags{Env: env}
+	installSet, err := s.InstallSpecs([]client.ToolSpec{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0", BuildFlags: flags},
+	})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	lt, err := readLockfile(t, lockfilePath).GetTool("go-fish")
+	if err != nil || lt.BuildFlags != flags {
+		t.Errorf("got tool %+v with error %v, want build flags %+v", lt, err, flags)
+	}
+
+	// The compiler the tool needs is checked before it is built
+	installSet, err = s.InstallSpecs([]client.ToolSpec{
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.2", BuildFlags: tool.BuildFlags{Env: "CC=shed-missing-cc"}},
+	})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	err = installSet.Apply(context.Background())
+	var errs lockfile.ErrorList
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("got error %v, want an error for ejson", err)
+	}
+	var envErr *cache.BuildEnvError
+	if !errors.As(errs[0], &envErr) || envErr.Tool.Name() != "ejson" || envErr.Var != "CC" || envErr.Value != "shed-missing-cc" {
+		t.Errorf("got error %v, want ejson to need CC=shed-missing-cc", errs[0])
+	}
+	if !errors.Is(errs[0], cache.ErrBuildEnvMissing) {
+		t.Errorf("got error %v, want %v", errs[0], cache.ErrBuildEnvMissing)
+	}
+}
+
+func TestInstallAlias(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	s, err := client.NewShed(
+		client.WithLockfilePath(lockfilePath),
+		client.WithCache(cache.New(td, cache.WithGo(mockGo))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	installSet, err := s.InstallSpecs([]client.ToolSpec{
+		{ImportPath: "github.com/cszatmary/go-fish"},
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Alias: "xstringer"},
+	})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	// The tool is referred to by its alias, but the binary keeps its name in the cache
+	binPath, err := s.ToolPath("xstringer")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	wantDir := filepath.FromSlash("golang.org/x/tools/cmd/stringer@v0.0.0-20201211185031-d93e913c1a58/")
+	if !strings.Contains(binPath, wantDir) || filepath.Base(binPath) != "stringer" {
+		t.Errorf("got binary path %s, want stringer in %s", binPath, wantDir)
+	}
+	if _, err := s.ToolPath("stringer"); !errors.Is(err, lockfile.ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, lockfile.ErrNotFound)
+	}
+	lt, err := readLockfile(t, lockfilePath).GetTool("xstringer")
+	if err != nil || lt.ImportPath != "golang.org/x/tools/cmd/stringer" {
+		t.Errorf("got tool %+v with error %v, want aliased tool", lt, err)
+	}
+
+	// Updating the tool keeps the alias
+	installSet, err = s.Install("golang.org/x/tools/cmd/stringer@v0.0.0-20201211185031-d93e913c1a58")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if lt, err := readLockfile(t, lockfilePath).GetTool("xstringer"); err != nil || lt.Alias != "xstringer" {
+		t.Errorf("got tool %+v with error %v, want alias xstringer", lt, err)
+	}
+
+	// Aliases must be unique
+	_, err = s.InstallSpecs([]client.ToolSpec{
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Alias: "xstringer"},
+	})
+	var errs lockfile.ErrorList
+	if !errors.As(err, &errs) || len(errs) != 1 || !errors.Is(errs[0], lockfile.ErrAliasConflict) {
+		t.Errorf("got error %v, want %v", err, lockfile.ErrAliasConflict)
+	}
+}
+
+// tarGz returns a gzipped tar archive containing the given files.
+func tarGz(t *testing.T, files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("failed to write archive: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("failed to write archive: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestInstallRelease(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("release test uses a shell script")
+	}
+	asset := tarGz(t, map[string]string{
+		"shellcheck-v0.9.0/README.txt": "readme",
+		"shellcheck-v0.9.0/shellcheck": "#!/bin/sh\necho checked\n",
+	})
+	var requested []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		w.Write(asset) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	newShed := func() *client.Shed {
+		mockGo, err := cache.NewMockGo(availableTools)
+		if err != nil {
+			t.Fatalf("failed to create mock go %v", err)
+		}
+		s, err := client.NewShed(
+			client.WithLockfilePath(lockfilePath),
+			client.WithCache(cache.New(t.TempDir(), cache.WithGo(mockGo), cache.WithHTTPClient(srv.Client()))),
+		)
+		if err != nil {
+			t.Fatalf("failed to create shed client %v", err)
+		}
+		return s
+	}
+	s := newShed()
+	installSet, err := s.InstallSpecs([]client.ToolSpec{{
+		ImportPath: "github.com/koalaman/shellcheck",
+		Version:    "v0.9.0",
+		Release: &tool.Release{
+			URL:    srv.URL + "/{{.Version}}/shellcheck-{{.OS}}-{{.Arch}}.tar.gz",
+			Binary: "shellcheck-{{.Version}}/shellcheck",
+		},
+	}})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	wantPath := fmt.Sprintf("/v0.9.0/shellcheck-%s-%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+	if len(requested) != 1 || requested[0] != wantPath {
+		t.Errorf("got requests %v, want %s", requested, wantPath)
+	}
+
+	stdout := &bytes.Buffer{}
+	if _, err := s.Run(context.Background(), "shellcheck", nil, client.RunOptions{Stdout: stdout}); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if got := stdout.String(); got != "checked\n" {
+		t.Errorf("got output %q, want %q", got, "checked\n")
+	}
+	lt, err := readLockfile(t, lockfilePath).GetTool("shellcheck")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	platform := tool.Platform(runtime.GOOS, runtime.GOARCH)
+	if lt.Release == nil || !strings.HasPrefix(lt.Release.Sums[platform], "sha256:") {
+		t.Fatalf("got tool %+v, want release with a hash for %s", lt, platform)
+	}
+
+	// Installing again with a fresh cache checks the asset against the hash in the lockfile
+	asset = tarGz(t, map[string]string{"shellcheck-v0.9.0/shellcheck": "#!/bin/sh\necho tampered\n"})
+	installSet, err = newShed().Install()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	err = installSet.Apply(context.Background())
+	var errs lockfile.ErrorList
+	if !errors.As(err, &errs) || len(errs) != 1 || !errors.Is(errs[0], cache.ErrChecksumMismatch) {
+		t.Errorf("got error %v, want %v", err, cache.ErrChecksumMismatch)
+	}
+
+	// Release tools must have an exact version
+	_, err = s.Install("github.com/koalaman/shellcheck@latest")
+	if err == nil {
+		t.Error("want error for release tool without an exact version, got nil")
+	}
+}
+
+func TestInstallReleaseDownloadLimit(t *testing.T) {
+	// The asset isn't an archive, so it is the binary as is
+	asset := "#!/bin/sh\necho checked\n" + strings.Repeat("#", 4000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(asset)) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	td := t.TempDir()
+	// The default cache is used since download limits don't apply to a cache provided with WithCache
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithCacheDir(filepath.Join(td, "cache")),
+		client.WithDownloadLimits(0, 8000),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	installSet, err := s.InstallSpecs([]client.ToolSpec{{
+		ImportPath: "github.com/koalaman/shellcheck",
+		Version:    "v0.9.0",
+		Release:    &tool.Release{URL: srv.URL + "/{{.Version}}/shellcheck-{{.OS}}-{{.Arch}}"},
+	}})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	start := time.Now()
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	// The first read of a tenth of a second's worth of bytes isn't delayed
+	if elapsed, want := time.Since(start), 300*time.Millisecond; elapsed < want {
+		t.Errorf("got download of %d bytes in %s at 8000 bytes/s, want at least %s", len(asset), elapsed, want)
+	}
+}
+
+func TestInstallReproducible(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	newShed := func() *client.Shed {
+		mockGo, err := cache.NewMockGo(availableTools)
+		if err != nil {
+			t.Fatalf("failed to create mock go %v", err)
+		}
+		s, err := client.NewShed(
+			client.WithLockfilePath(lockfilePath),
+			client.WithCache(cache.New(t.TempDir(), cache.WithGo(mockGo))),
+		)
+		if err != nil {
+			t.Fatalf("failed to create shed client %v", err)
+		}
+		return s
+	}
+	s := newShed()
+	installSet, err := s.InstallSpecs([]client.ToolSpec{{
+		ImportPath:   "github.com/cszatmary/go-fish",
+		Version:      "v0.1.0",
+		Reproducible: "go1.22.4",
+	}})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	binPath, err := s.ToolPath("go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	// The mock go command writes the build flags to the binary
+	if data, _ := ioutil.ReadFile(binPath); string(data) != "-trimpath -buildvcs=false" {
+		t.Errorf("got build flags %q, want %q", data, "-trimpath -buildvcs=false")
+	}
+	lt, err := readLockfile(t, lockfilePath).GetTool("go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	platform := tool.Platform(runtime.GOOS, runtime.GOARCH)
+	if r := lt.Reproducible; r == nil || r.GoVersion != "go1.22.4" || !strings.HasPrefix(r.Sums[platform], "sha256:") {
+		t.Fatalf("got tool %+v, want reproducible build with go1.22.4 and a hash for %s", lt, platform)
+	}
+
+	// Installing again with a fresh cache builds the same binary
+	installSet, err = newShed().Install()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	// A different binary fails to install
+	lt.Reproducible.Sums = map[string]string{platform: "sha256:" + strings.Repeat("00", 32)}
+	createLockfile(t, lockfilePath, []tool.Tool{lt})
+	installSet, err = newShed().Install()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	err = installSet.Apply(context.Background())
+	var errs lockfile.ErrorList
+	if !errors.As(err, &errs) || len(errs) != 1 || !errors.Is(errs[0], cache.ErrChecksumMismatch) {
+		t.Errorf("got error %v, want %v", err, cache.ErrChecksumMismatch)
+	}
+
+	// Go versions that can't be switched to are rejected
+	_, err = s.InstallSpecs([]client.ToolSpec{{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0", Reproducible: "go1.20.14"}})
+	if err == nil {
+		t.Error("want error for go version older than go1.21.0, got nil")
+	}
+}
+
+func TestInstallPrebuilt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("prebuilt test uses a shell script")
+	}
+	assetName := fmt.Sprintf("go-fish-%s-%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+	asset := tarGz(t, map[string]string{"go-fish": "#!/bin/sh\necho prebuilt\n"})
+	h := sha256.Sum256(asset)
+	sums := fmt.Sprintf("%x  %s\n", h, assetName)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v0.1.0/checksums.txt":
+			io.WriteString(w, sums) //nolint:errcheck
+		case "/v0.1.0/" + assetName:
+			w.Write(asset) //nolint:errcheck
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	td := t.TempDir()
+	cfg := fmt.Sprintf(`{"tools": {"go-fish": {"prebuilt": {
+  "url": "%[1]s/{{.Version}}/go-fish-{{.OS}}-{{.Arch}}.tar.gz",
+  "checksums": "%[1]s/{{.Version}}/checksums.txt"
+}}}}`, srv.URL)
+	if err := ioutil.WriteFile(filepath.Join(td, config.ProjectFileName), []byte(cfg), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	prebuilt := true
+	install := func() (*client.Shed, cache.Source, error) {
+		mockGo, err := cache.NewMockGo(availableTools)
+		if err != nil {
+			t.Fatalf("failed to create mock go %v", err)
+		}
+		s, err := client.NewShed(
+			client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+			client.WithCache(cache.New(t.TempDir(), cache.WithGo(mockGo), cache.WithHTTPClient(srv.Client()))),
+			client.WithFeatures(features.Layer{Source: "test", Values: map[string]bool{features.Prebuilt: prebuilt}}),
+		)
+		if err != nil {
+			t.Fatalf("failed to create shed client %v", err)
+		}
+		installSet, err := s.Install("github.com/cszatmary/go-fish@v0.1.0")
+		if err != nil {
+			t.Fatalf("want nil error, got %v", err)
+		}
+		var source cache.Source
+		err = installSet.Apply(context.Background(), client.WithProgress(func(ev client.Event) {
+			if ev.Kind == client.EventDone {
+				source = ev.Source
+			}
+		}))
+		return s, source, err
+	}
+
+	s, source, err := install()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if source != cache.SourcePrebuilt {
+		t.Errorf("got source %s, want %s", source, cache.SourcePrebuilt)
+	}
+	stdout := &bytes.Buffer{}
+	if _, err := s.Run(context.Background(), "go-fish", nil, client.RunOptions{Stdout: stdout}); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if got := stdout.String(); got != "prebuilt\n" {
+		t.Errorf("got output %q, want %q", got, "prebuilt\n")
+	}
+
+	// The tool is built if the asset isn't in the checksums file
+	sums = ""
+	if _, source, err = install(); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if source != cache.SourceBuild {
+		t.Errorf("got source %s, want %s", source, cache.SourceBuild)
+	}
+
+	// An asset that doesn't match its checksum is an error instead of being built
+	sums = fmt.Sprintf("%x  %s\n", sha256.Sum256([]byte("other")), assetName)
+	_, _, err = install()
+	var errs lockfile.ErrorList
+	if !errors.As(err, &errs) || len(errs) != 1 || !errors.Is(errs[0], cache.ErrChecksumMismatch) {
+		t.Errorf("got error %v, want %v", err, cache.ErrChecksumMismatch)
+	}
+
+	// Prebuilt binaries aren't used unless the feature is enabled
+	prebuilt = false
+	if _, source, err = install(); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if source != cache.SourceBuild {
+		t.Errorf("got source %s, want %s", source, cache.SourceBuild)
+	}
+}
+
+func TestInstallPrebuiltDownloadLimit(t *testing.T) {
+	// The asset isn't an archive, so it is the binary as is
+	assetName := fmt.Sprintf("go-fish-%s-%s", runtime.GOOS, runtime.GOARCH)
+	asset := "#!/bin/sh\necho prebuilt\n" + strings.Repeat("#", 4000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v0.1.0/checksums.txt":
+			fmt.Fprintf(w, "%x  %s\n", sha256.Sum256([]byte(asset)), assetName)
+		case "/v0.1.0/" + assetName:
+			io.WriteString(w, asset) //nolint:errcheck
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	td := t.TempDir()
+	cfg := fmt.Sprintf(`{"tools": {"go-fish": {"prebuilt": {
+  "url": "%[1]s/{{.Version}}/go-fish-{{.OS}}-{{.Arch}}",
+  "checksums": "%[1]s/{{.Version}}/checksums.txt"
+}}}}`, srv.URL)
+	if err := ioutil.WriteFile(filepath.Join(td, config.ProjectFileName), []byte(cfg), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	c := cache.New(t.TempDir(), cache.WithGo(mockGo), cache.WithHTTPClient(srv.Client()), cache.WithDownloadLimiter(ratelimit.New(8000)))
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithCache(c),
+		client.WithFeatures(features.Layer{Source: "test", Values: map[string]bool{features.Prebuilt: true}}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	installSet, err := s.Install("github.com/cszatmary/go-fish@v0.1.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	var source cache.Source
+	start := time.Now()
+	err = installSet.Apply(context.Background(), client.WithProgress(func(ev client.Event) {
+		if ev.Kind == client.EventDone {
+			source = ev.Source
+		}
+	}))
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if source != cache.SourcePrebuilt {
+		t.Fatalf("got source %s, want %s", source, cache.SourcePrebuilt)
+	}
+	// The first read of a tenth of a second's worth of bytes isn't delayed
+	if elapsed, want := time.Since(start), 300*time.Millisecond; elapsed < want {
+		t.Errorf("got download of %d bytes in %s at 8000 bytes/s, want at least %s", len(asset), elapsed, want)
+	}
+}
+
+func TestInstallHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("install hooks test uses shell commands")
+	}
+	td := t.TempDir()
+	cfg := `{"tools": {
+  "go-fish": {"installHooks": {
+    "preInstall": ["sh", "-c", "echo \"pre $SHED_TOOL $SHED_TOOL_VERSION\" >> hooks.log"],
+    "postInstall": ["sh", "-c", "echo \"post $SHED_TOOL_IMPORT_PATH $SHED_INSTALL_SOURCE $SHED_TOOL_PATH\" >> hooks.log"]
+  }},
+  "ejson": {"installHooks": {"postInstall": ["sh", "-c", "echo codesign failed >&2; exit 1"]}}
+}}`
+	if err := ioutil.WriteFile(filepath.Join(td, config.ProjectFileName), []byte(cfg), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithCache(cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	installSet, err := s.Install("github.com/cszatmary/go-fish@v0.1.0", "github.com/Shopify/ejson/cmd/ejson@v1.1.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	err = installSet.Apply(context.Background(), client.BestEffort())
+	var errs lockfile.ErrorList
+	if !errors.As(err, &errs) || len(errs) != 1 || !strings.Contains(errs[0].Error(), "ejson") || !strings.Contains(errs[0].Error(), "codesign failed") {
+		t.Errorf("got error %v, want postInstall hook of ejson to fail with its output", err)
+	}
+
+	binPath, err := s.ToolPath("go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if _, err := s.ToolPath("ejson"); err == nil {
+		t.Error("want ejson not to be added to the lockfile, got nil error")
+	}
+	data, err := ioutil.ReadFile(filepath.Join(td, "hooks.log"))
+	if err != nil {
+		t.Fatalf("failed to read hooks log: %v", err)
+	}
+	want := "pre go-fish v0.1.0\npost github.com/cszatmary/go-fish build " + binPath + "\n"
+	if string(data) != want {
+		t.Errorf("got hooks log %q, want %q", data, want)
+	}
+}
+
+func TestInstallFrozen(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+	})
+	before, err := ioutil.ReadFile(lockfilePath)
+	if err != nil {
+		t.Fatalf("failed to read lockfile: %v", err)
+	}
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	s, err := client.NewShed(
+		client.WithLockfilePath(lockfilePath),
+		client.WithCache(cache.New(td, cache.WithGo(mockGo))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	installSet, err := s.Install()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background(), client.Frozen()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if _, err := s.ToolPath("go-fish"); err != nil {
+		t.Errorf("want go-fish to be installed, got %v", err)
+	}
+	// The lockfile isn't written, even though the module hash is now known
+	after, err := ioutil.ReadFile(lockfilePath)
+	if err != nil {
+		t.Fatalf("failed to read lockfile: %v", err)
+	}
+	if !bytes.Equal(after, before) {
+		t.Errorf("got lockfile\n%s\nwant it to be unchanged\n%s", after, before)
+	}
+
+	// Adding a tool or changing a version changes the lockfile
+	for _, toolName := range []string{"golang.org/x/tools/cmd/stringer", "github.com/cszatmary/go-fish@22d10c9b658df297b17b33c836a60fb943ef5a5f"} {
+		installSet, err := s.Install(toolName)
+		if err != nil {
+			t.Fatalf("want nil error, got %v", err)
+		}
+		err = installSet.Apply(context.Background(), client.Frozen())
+		var errs lockfile.ErrorList
+		if !errors.As(err, &errs) || len(errs) != 1 || !errors.Is(errs[0], client.ErrFrozen) {
+			t.Errorf("installing %s: got error %v, want %v", toolName, err, client.ErrFrozen)
+		}
+	}
+}
+
+func TestInstallPlatform(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	s, err := client.NewShed(
+		client.WithLockfilePath(lockfilePath),
+		client.WithCache(cache.New(td, cache.WithGo(mockGo))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	installSet, err := s.Install("github.com/cszatmary/go-fish@v0.1.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background(), client.TargetPlatform("windows/arm64")); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	binPath, err := s.ToolPath("go-fish", client.ForPlatform("windows/arm64"))
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if want := "go-fish@v0.1.0+platform.windows_arm64" + string(filepath.Separator); !strings.Contains(binPath, want) || filepath.Base(binPath) != "go-fish.exe" {
+		t.Errorf("got path %s, want go-fish.exe in %s", binPath, want)
+	}
+	// Only the binary for windows/arm64 was built
+	if _, err := s.ToolPath("go-fish"); err == nil {
+		t.Error("want non-nil error for the current platform, got nil")
+	}
+	// The lockfile doesn't depend on the platform
+	lf := readLockfile(t, lockfilePath)
+	goFish, err := lf.GetTool("go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if goFish.Platform != "" {
+		t.Errorf("got platform %q in lockfile, want none", goFish.Platform)
+	}
+
+	installSet, err = s.Install()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background(), client.TargetPlatform("windows")); err == nil {
+		t.Error("want non-nil error for invalid platform, got nil")
+	}
+}
+
+// offlineGo wraps a Go instance and fails to download or build anything,
+// so tests can check that tools came from somewhere else.
+type offlineGo struct {
+	cache.Go
+}
+
+func (offlineGo) GetD(ctx context.Context, mod, dir string, env []string) error {
+	return fmt.Errorf("offline, can't download %s", mod)
+}
+
+func (offlineGo) Build(ctx context.Context, pkg, outPath, dir string, flags, env []string) error {
+	return fmt.Errorf("offline, can't build %s", pkg)
+}
+
+func TestBundle(t *testing.T) {
+	td := t.TempDir()
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithCache(cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	installSet, err := s.Install("github.com/cszatmary/go-fish@v0.1.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background(), client.TargetPlatform("windows/arm64")); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	shedBinary := filepath.Join(td, "shed-windows-arm64.exe")
+	if err := ioutil.WriteFile(shedBinary, []byte("shed"), 0o755); err != nil {
+		t.Fatalf("failed to write shed binary: %v", err)
+	}
+
+	bundleDir := filepath.Join(td, "bundle")
+	if _, err := s.Bundle(bundleDir, client.BundleOptions{Platform: "linux/riscv64"}); err == nil {
+		t.Error("want non-nil error for tools not installed for the platform, got nil")
+	}
+	n, err := s.Bundle(bundleDir, client.BundleOptions{Platform: "windows/arm64", ShedBinary: shedBinary})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if n != 1 {
+		t.Errorf("got %d tools bundled, want 1", n)
+	}
+	if _, err := os.Stat(filepath.Join(bundleDir, "shed.exe")); err != nil {
+		t.Errorf("want shed.exe in bundle, got %v", err)
+	}
+	if _, err := s.Bundle(bundleDir, client.BundleOptions{Platform: "windows/arm64"}); err == nil {
+		t.Error("want non-nil error for a directory that isn't empty, got nil")
+	}
+
+	// The bundle is a project that finds the tools in its own cache, as if they were built there
+	bundled, err := client.NewShed(client.WithLockfilePath(filepath.Join(bundleDir, "shed.lock")))
+	if err != nil {
+		t.Fatalf("failed to create shed client for bundle %v", err)
+	}
+	if got, want := bundled.CacheDir(), filepath.Join(bundleDir, client.BundleCacheDir); got != want {
+		t.Errorf("got cache dir %s, want %s", got, want)
+	}
+	binPath, err := bundled.ToolPath("go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if want := filepath.Join("go-fish@v0.1.0", "go-fish"); !strings.HasSuffix(binPath, want) {
+		t.Errorf("got path %s, want it to end with %s", binPath, want)
+	}
+}
+
+func TestInstallRemoteCache(t *testing.T) {
+	backend := remote.NewDirBackend(t.TempDir())
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	install := func(goClient cache.Go, readOnly bool, toolName string) (*client.Shed, error) {
+		td := t.TempDir()
+		s, err := client.NewShed(
+			client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+			client.WithCache(cache.New(td, cache.WithGo(goClient), cache.WithRemote(backend, readOnly))),
+		)
+		if err != nil {
+			t.Fatalf("failed to create shed client %v", err)
+		}
+		installSet, err := s.Install(toolName)
+		if err != nil {
+			t.Fatalf("want nil error, got %v", err)
+		}
+		return s, installSet.Apply(context.Background())
+	}
+
+	// The first install builds the tool and pushes it
+	if _, err := install(mockGo, false, "github.com/cszatmary/go-fish@v0.1.0"); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	// Another cache pulls it instead of downloading and building it
+	s, err := install(offlineGo{mockGo}, true, "github.com/cszatmary/go-fish@v0.1.0")
+	if err != nil {
+		t.Fatalf("want tool to be pulled from remote cache, got %v", err)
+	}
+	if _, err := s.ToolPath("go-fish"); err != nil {
+		t.Errorf("want go-fish to be installed, got %v", err)
+	}
+	// Tools built for a different platform aren't shared
+	c := cache.New(t.TempDir(), cache.WithGo(offlineGo{mockGo}), cache.WithRemote(backend, true))
+	goFish := tool.Tool{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"}
+	if _, err := c.Install(context.Background(), goFish, cache.InstallEnv("GOOS=plan9")); err == nil {
+		t.Error("want non-nil error for a different platform, got nil")
+	}
+	// Read-only caches don't push
+	if _, err := install(mockGo, true, "github.com/Shopify/ejson/cmd/ejson@v1.1.0"); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if _, err := install(offlineGo{mockGo}, true, "github.com/Shopify/ejson/cmd/ejson@v1.1.0"); err == nil {
+		t.Error("want non-nil error for a tool that wasn't pushed, got nil")
+	}
+}
+
+func TestApplyAllOrNothing(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+	})
+	before, err := ioutil.ReadFile(lockfilePath)
+	if err != nil {
+		t.Fatalf("failed to read lockfile: %v", err)
+	}
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	s, err := client.NewShed(
+		client.WithLockfilePath(lockfilePath),
+		client.WithCache(cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	specs := []string{"github.com/Shopify/ejson/cmd/ejson@v1.1.0", "github.com/golangci/golangci-lint/cmd/golangci-lint@v9.9.9"}
+	installSet, err := s.Install(specs...)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	err = installSet.Apply(context.Background())
+	errList, ok := err.(lockfile.ErrorList)
+	if !ok || len(errList) != 1 {
+		t.Fatalf("want error to be lockfile.ErrorList with 1 error, got %T: %v", err, err)
+	}
+	after, err := ioutil.ReadFile(lockfilePath)
+	if err != nil {
+		t.Fatalf("failed to read lockfile: %v", err)
+	}
+	if string(after) != string(before) {
+		t.Errorf("want lockfile to be unchanged, got\n%s", after)
+	}
+	if _, err := s.ToolPath("ejson"); err == nil {
+		t.Error("want ejson to not be added, got nil error")
+	}
+
+	// The tools that were installed are added with BestEffort
+	installSet, err = s.Install(specs...)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	err = installSet.Apply(context.Background(), client.BestEffort())
+	errList, ok = err.(lockfile.ErrorList)
+	if !ok || len(errList) != 1 {
+		t.Fatalf("want error to be lockfile.ErrorList with 1 error, got %T: %v", err, err)
+	}
+	lf := readLockfile(t, lockfilePath)
+	if _, err := lf.GetTool("ejson"); err != nil {
+		t.Errorf("want ejson to be added, got %v", err)
+	}
+	if _, err := lf.GetTool("golangci-lint"); err == nil {
+		t.Error("want golangci-lint to not be added, got nil error")
+	}
+	if _, err := s.ToolPath("go-fish"); err != nil {
+		t.Errorf("want go-fish to be kept, got %v", err)
+	}
+}
+
+func TestInstallDiagnose(t *testing.T) {
+	td := t.TempDir()
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	wantErr := &vanity.Error{ImportPath: "go.acme.dev/tool", Kind: vanity.KindAuth, Detail: "server responded with 401"}
+	diagnose := func(ctx context.Context, importPath string) error {
+		if importPath != wantErr.ImportPath {
+			return nil
+		}
+		return wantErr
+	}
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithCache(cache.New(td, cache.WithGo(mockGo), cache.WithDiagnose(diagnose))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	installSet, err := s.Install("go.acme.dev/tool@v1.0.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	err = installSet.Apply(context.Background())
+	errList, ok := err.(lockfile.ErrorList)
+	if !ok || len(errList) != 1 {
+		t.Fatalf("want error to be lockfile.ErrorList with 1 error, got %T: %v", err, err)
+	}
+	var vErr *vanity.Error
+	if !errors.As(errList[0], &vErr) {
+		t.Fatalf("want error to be *vanity.Error, got %T: %v", err, err)
+	}
+	if vErr != wantErr {
+		t.Errorf("got error %v, want %v", vErr, wantErr)
+	}
+}
+
+// envGo wraps a Go instance and records the env each module was downloaded with.
+// GOBIN is left out since it is always set, the mock Go checks it.
+type envGo struct {
+	cache.Go
+	mu  sync.Mutex
+	env map[string][]string
+}
+
+func (g *envGo) GetD(ctx context.Context, mod, dir string, env []string) error {
+	var recorded []string
+	for _, e := range env {
+		if !strings.HasPrefix(e, "GOBIN=") {
+			recorded = append(recorded, e)
+		}
+	}
+	g.mu.Lock()
+	g.env[mod] = recorded
+	g.mu.Unlock()
+	return g.Go.GetD(ctx, mod, dir, env)
+}
+
+func TestInstallEnv(t *testing.T) {
+	os.Setenv("GIT_CONFIG_COUNT", "1")
+	defer os.Unsetenv("GIT_CONFIG_COUNT")
+
+	td := t.TempDir()
+	cfg := `{
+  "install": {"goinsecure": ["git.acme.dev"], "gitConfig": {"http.sslVerify": "true"}},
+  "tools": {
+    "go-fish": {"install": {"govcs": "*:git", "gitConfig": {"http.sslVerify": "false", "core.askPass": "true"}}}
+  }
+}`
+	if err := ioutil.WriteFile(filepath.Join(td, config.ProjectFileName), []byte(cfg), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	g := &envGo{Go: mockGo, env: make(map[string][]string)}
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithCache(cache.New(td, cache.WithGo(g))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	installSet, err := s.Install("github.com/cszatmary/go-fish@v0.1.0", "github.com/Shopify/ejson/cmd/ejson@v1.1.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	want := map[string][]string{
+		"github.com/cszatmary/go-fish@v0.1.0": {
+			"GOINSECURE=git.acme.dev",
+			"GOVCS=*:git",
+			"GIT_CONFIG_KEY_1=core.askPass",
+			"GIT_CONFIG_VALUE_1=true",
+			"GIT_CONFIG_KEY_2=http.sslVerify",
+			"GIT_CONFIG_VALUE_2=false",
+			"GIT_CONFIG_COUNT=3",
+		},
+		"github.com/Shopify/ejson/cmd/ejson@v1.1.0": {
+			"GOINSECURE=git.acme.dev",
+			"GIT_CONFIG_KEY_1=http.sslVerify",
+			"GIT_CONFIG_VALUE_1=true",
+			"GIT_CONFIG_COUNT=2",
+		},
+	}
+	if !reflect.DeepEqual(g.env, want) {
+		t.Errorf("got env %v, want %v", g.env, want)
+	}
+}
+
+func TestInstallLogger(t *testing.T) {
+	td := t.TempDir()
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	logger, hook := logtest.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithCache(cache.New(td, cache.WithGo(mockGo), cache.WithLogger(logger))),
+		client.WithLogger(logger),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	installSet, err := s.Install("github.com/cszatmary/go-fish@v0.1.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	var msgs []string
+	for _, e := range hook.AllEntries() {
+		if e.Level != logrus.DebugLevel {
+			continue
+		}
+		msgs = append(msgs, e.Message)
+	}
+	got := strings.Join(msgs, "\n")
+	for _, want := range []string{"lockfile does not exist", "ran go get", "ran go build", "tool built", "wrote lockfile"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got debug messages %q, want one containing %q", msgs, want)
+		}
+	}
+}
+
+func TestInstallGoProxy(t *testing.T) {
+	td := t.TempDir()
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	g := &envGo{Go: mockGo, env: make(map[string][]string)}
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithCache(cache.New(td, cache.WithGo(g))),
+		client.WithGoProxy("https://proxy.acme.dev"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	installSet, err := s.Install("github.com/cszatmary/go-fish@v0.1.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := map[string][]string{
+		"github.com/cszatmary/go-fish@v0.1.0": {"GOPROXY=https://proxy.acme.dev"},
+	}
+	if !reflect.DeepEqual(g.env, want) {
+		t.Errorf("got env %v, want %v", g.env, want)
+	}
+}
+
+func TestInstallToolGoProxy(t *testing.T) {
+	td := t.TempDir()
+	cfg := `{
+  "install": {
+    "proxies": [{"match": "github.com/Shopify", "goproxy": "https://proxy.acme.dev"}],
+    "gonosumdb": ["github.com/Shopify"]
+  },
+  "tools": {
+    "go-fish": {"install": {"goprivate": ["github.com/cszatmary"]}}
+  }
+}`
+	if err := ioutil.WriteFile(filepath.Join(td, config.ProjectFileName), []byte(cfg), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	g := &envGo{Go: mockGo, env: make(map[string][]string)}
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithCache(cache.New(td, cache.WithGo(g))),
+		client.WithGoProxy("https://proxy.golang.org"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	installSet, err := s.Install("github.com/cszatmary/go-fish@v0.1.0", "github.com/Shopify/ejson/cmd/ejson@v1.1.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	want := map[string][]string{
+		"github.com/cszatmary/go-fish@v0.1.0": {
+			"GOPROXY=https://proxy.golang.org",
+			"GOPRIVATE=github.com/cszatmary",
+			"GONOSUMDB=github.com/Shopify",
+		},
+		"github.com/Shopify/ejson/cmd/ejson@v1.1.0": {
+			"GOPROXY=https://proxy.acme.dev",
+			"GONOSUMDB=github.com/Shopify",
+		},
+	}
+	if !reflect.DeepEqual(g.env, want) {
+		t.Errorf("got env %v, want %v", g.env, want)
+	}
+}
+
+func TestInstallCredentials(t *testing.T) {
+	td := t.TempDir()
+	cfg := `{
+  "install": {"credentials": {"sshAgent": true, "netrc": "ci/netrc"}},
+  "tools": {
+    "go-fish": {"install": {"credentials": {"askPass": true, "credentialHelper": true}}}
+  }
+}`
+	if err := ioutil.WriteFile(filepath.Join(td, config.ProjectFileName), []byte(cfg), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	g := &envGo{Go: mockGo, env: make(map[string][]string)}
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithCache(cache.New(td, cache.WithGo(g))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	installSet, err := s.Install("github.com/cszatmary/go-fish@v0.1.0", "github.com/Shopify/ejson/cmd/ejson@v1.1.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	want := map[string][]string{
+		"github.com/cszatmary/go-fish@v0.1.0": {
+			"SSH_AUTH_SOCK=",
+			"NETRC=" + os.DevNull,
+		},
+		"github.com/Shopify/ejson/cmd/ejson@v1.1.0": {
+			"GIT_ASKPASS=",
+			"SSH_ASKPASS=",
+			"NETRC=" + filepath.Join(td, "ci", "netrc"),
+			"GIT_CONFIG_KEY_0=credential.helper",
+			"GIT_CONFIG_VALUE_0=",
+			"GIT_CONFIG_COUNT=1",
+		},
+	}
+	if !reflect.DeepEqual(g.env, want) {
+		t.Errorf("got env %v, want %v", g.env, want)
+	}
+}
+
+// concurrencyGo wraps a Go instance and records the maximum number of concurrent downloads.
+type concurrencyGo struct {
+	cache.Go
+	mu      sync.Mutex
+	current int
+	max     int
+}
+
+func (g *concurrencyGo) GetD(ctx context.Context, mod, dir string, env []string) error {
+	g.mu.Lock()
+	g.current++
+	if g.current > g.max {
+		g.max = g.current
+	}
+	g.mu.Unlock()
+	// Give other installs a chance to run
+	time.Sleep(20 * time.Millisecond)
+	g.mu.Lock()
+	g.current--
+	g.mu.Unlock()
+	return g.Go.GetD(ctx, mod, dir, env)
+}
+
+func TestInstallDownloadLimits(t *testing.T) {
+	td := t.TempDir()
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	g := &concurrencyGo{Go: mockGo}
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithCache(cache.New(td, cache.WithGo(g))),
+		client.WithDownloadLimits(1, 0),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	installSet, err := s.Install(
+		"github.com/cszatmary/go-fish@v0.1.0",
+		"github.com/golangci/golangci-lint/cmd/golangci-lint@v1.33.0",
+		"github.com/Shopify/ejson/cmd/ejson@v1.1.0",
+	)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if g.max != 1 {
+		t.Errorf("got %d concurrent downloads, want 1", g.max)
+	}
+	if got := len(s.List()); got != 3 {
+		t.Errorf("got %d tools, want 3", got)
+	}
+}
+
+func TestProjectCache(t *testing.T) {
+	td := t.TempDir()
+	cfg := `{"cache": {"dir": ".shed/cache"}}`
+	if err := ioutil.WriteFile(filepath.Join(td, config.ProjectFileName), []byte(cfg), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithCacheDir(filepath.Join(td, "user-cache")),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	if got, want := s.CacheDir(), filepath.Join(td, ".shed", "cache"); got != want {
+		t.Errorf("got cache dir %s, want %s", got, want)
+	}
+}
+
+func TestProjectCacheSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks requires extra privileges on windows")
+	}
+	td := t.TempDir()
+	project := filepath.Join(td, "project")
+	if err := os.Mkdir(project, 0o755); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	cfg := `{"cache": {"dir": ".shed/cache"}}`
+	if err := ioutil.WriteFile(filepath.Join(project, config.ProjectFileName), []byte(cfg), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	// A project could point the cache at any directory the user can write to
+	if err := os.Symlink(td, filepath.Join(project, ".shed")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	_, err := client.NewShed(client.WithLockfilePath(filepath.Join(project, "shed.lock")))
+	if err == nil || !strings.Contains(err.Error(), "is a symlink") {
+		t.Errorf("got error %v, want symlink error", err)
+	}
+}
+
+func TestShedVersion(t *testing.T) {
+	td := t.TempDir()
+	cfg := `{"shedVersion": "v0.8.0"}`
+	if err := ioutil.WriteFile(filepath.Join(td, config.ProjectFileName), []byte(cfg), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	newShed := func(version string) error {
+		_, err := client.NewShed(
+			client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+			client.WithNoCache(),
+			client.WithShedVersion(version),
+		)
+		return err
+	}
+
+	var verr *config.ShedVersionError
+	if err := newShed("v0.7.3"); !errors.As(err, &verr) {
+		t.Fatalf("got error %v, want *config.ShedVersionError", err)
+	}
+	if verr.Version != "v0.7.3" || verr.Required != "v0.8.0" {
+		t.Errorf("got version %s and required %s, want v0.7.3 and v0.8.0", verr.Version, verr.Required)
+	}
+	for _, v := range []string{"v0.8.0", "v0.9.1", ""} {
+		if err := newShed(v); err != nil {
+			t.Errorf("want nil error for version %q, got %v", v, err)
+		}
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	td := t.TempDir()
+	cfg := `{"features": {"remote-tools": true}}`
+	if err := ioutil.WriteFile(filepath.Join(td, config.ProjectFileName), []byte(cfg), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	newShed := func(layers ...features.Layer) *client.Shed {
+		s, err := client.NewShed(
+			client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+			client.WithNoCache(),
+			client.WithFeatures(layers...),
+		)
+		if err != nil {
+			t.Fatalf("failed to create shed instance %v", err)
+		}
+		return s
+	}
+
+	if s := newShed(); !s.Features().Enabled(features.RemoteTools) {
+		t.Errorf("want %s to be enabled by the project", features.RemoteTools)
+	}
+	s := newShed(
+		features.Layer{Source: "user", Values: map[string]bool{features.RemoteTools: false}},
+		features.Layer{Source: "env", Values: map[string]bool{}},
+	)
+	if s.Features().Enabled(features.RemoteTools) {
+		t.Errorf("want %s to be disabled by the user", features.RemoteTools)
+	}
+}
+
+func TestReadToolList(t *testing.T) {
+	r := strings.NewReader(`# Linters
+github.com/golangci/golangci-lint/cmd/golangci-lint@v1.33.0
+
+  golang.org/x/tools/cmd/stringer # for generating String methods
+go-fish
+`)
+	tools, err := client.ReadToolList(r)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := []string{
+		"github.com/golangci/golangci-lint/cmd/golangci-lint@v1.33.0",
+		"golang.org/x/tools/cmd/stringer",
+		"go-fish",
+	}
+	if !reflect.DeepEqual(tools, want) {
+		t.Errorf("got %v, want %v", tools, want)
+	}
+
+	if _, err := client.ReadToolList(strings.NewReader("go-fish\nstringer golangci-lint\n")); err == nil {
+		t.Error("want error for line with multiple tools, got nil")
+	}
+}
+
+func TestNoCache(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	cacheDir := filepath.Join(td, "cache")
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+	})
+	s, err := client.NewShed(
+		client.WithLockfilePath(lockfilePath),
+		client.WithCacheDir(cacheDir),
+		client.WithNoCache(),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	if tools := s.List(); len(tools) != 1 {
+		t.Errorf("got %d tools, want 1", len(tools))
+	}
+	if _, err := s.ToolPath("go-fish"); !errors.Is(err, client.ErrNoCache) {
+		t.Errorf("got error %v, want %v", err, client.ErrNoCache)
+	}
+	installSet, err := s.Install()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); !errors.Is(err, client.ErrNoCache) {
+		t.Errorf("got error %v, want %v", err, client.ErrNoCache)
+	}
+	if _, err := os.Stat(cacheDir); !os.IsNotExist(err) {
+		t.Errorf("want cache dir to not exist, got %v", err)
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.1.0"},
+	})
+	s, err := client.NewShed(
+		client.WithLockfilePath(lockfilePath),
+		client.WithCache(cache.New(td, cache.WithGo(mockGo))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	installTools := []string{
+		"github.com/cszatmary/go-fish@v0.1.0",
+		"github.com/golangci/golangci-lint/cmd/golangci-lint@v1.33.0",
+		"golang.org/x/tools/cmd/stringer@v0.0.0-20201211185031-d93e913c1a58",
+	}
+	var wg sync.WaitGroup
+	errCh := make(chan error, 2*len(installTools)+1)
+	for _, name := range installTools {
+		wg.Add(2)
+		go func(name string) {
+			defer wg.Done()
+			installSet, err := s.Install(name)
+			if err == nil {
+				err = installSet.Apply(context.Background())
+			}
+			errCh <- err
+		}(name)
+		go func() {
+			defer wg.Done()
+			s.List()
+			_, err := s.ToolPath("ejson")
+			if errors.Is(err, lockfile.ErrNotFound) {
+				// Uninstall may have already run
+				err = nil
+			}
+			errCh <- err
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errCh <- s.Uninstall(context.Background(), "ejson")
+	}()
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		if err != nil {
+			t.Errorf("want nil error, got %v", err)
+		}
+	}
+
+	// None of the changes to the lockfile should have been lost
+	lf := readLockfile(t, lockfilePath)
+	var got []string
+	it := lf.Iter()
+	for it.Next() {
+		got = append(got, it.Value().String())
+	}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, installTools) {
+		t.Errorf("got tools %v, want %v", got, installTools)
+	}
+}
+
+func TestConcurrentProcesses(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.1.0"},
+	})
+
+	// Each Shed has its own cache instance and reads the lockfile before the others write it,
+	// like separate processes would
+	installTools := []string{
+		"github.com/Shopify/ejson/cmd/ejson@v1.1.0",
+		"github.com/cszatmary/go-fish@v0.1.0",
+		"github.com/golangci/golangci-lint/cmd/golangci-lint@v1.33.0",
+		"golang.org/x/tools/cmd/stringer@v0.0.0-20201211185031-d93e913c1a58",
+	}
+	var installSets []*client.InstallSet
+	for _, name := range installTools[1:] {
+		s, err := client.NewShed(
+			client.WithLockfilePath(lockfilePath),
+			client.WithCache(cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo))),
+		)
+		if err != nil {
+			t.Fatalf("failed to create shed client %v", err)
+		}
+		installSet, err := s.Install(name)
+		if err != nil {
+			t.Fatalf("want nil error, got %v", err)
+		}
+		installSets = append(installSets, installSet)
+	}
+	var wg sync.WaitGroup
+	errCh := make(chan error, len(installSets))
+	for _, installSet := range installSets {
+		wg.Add(1)
+		go func(installSet *client.InstallSet) {
+			defer wg.Done()
+			errCh <- installSet.Apply(context.Background())
+		}(installSet)
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		if err != nil {
+			t.Errorf("want nil error, got %v", err)
+		}
+	}
+
+	lf := readLockfile(t, lockfilePath)
+	var got []string
+	it := lf.Iter()
+	for it.Next() {
+		got = append(got, it.Value().String())
+	}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, installTools) {
+		t.Errorf("got tools %v, want %v", got, installTools)
+	}
+}
+
+func TestLockTimeout(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.1.0"},
+	})
+	s, err := client.NewShed(
+		client.WithLockfilePath(lockfilePath),
+		client.WithCacheDir(filepath.Join(td, "cache")),
+		client.WithLockTimeout(20*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	// Another process is updating the lockfile
+	l, err := filelock.Acquire(context.Background(), filepath.Join(td, client.ProjectDirName, "lock"), filelock.Options{})
+	if err != nil {
+		t.Fatalf("failed to lock: %v", err)
+	}
+	err = s.Uninstall(context.Background(), "ejson")
+	if !errors.Is(err, client.ErrLocked) {
+		t.Errorf("got error %v, want %v", err, client.ErrLocked)
+	}
+	l.Unlock()
+
+	// Another process is pruning the cache
+	l, err = filelock.Acquire(context.Background(), filepath.Join(td, "cache", "lock"), filelock.Options{})
+	if err != nil {
+		t.Fatalf("failed to lock: %v", err)
+	}
+	defer l.Unlock()
+	if err := s.CleanCache(context.Background()); !errors.Is(err, client.ErrLocked) {
+		t.Errorf("got error %v, want %v", err, client.ErrLocked)
+	}
+}
+
+func TestLockContext(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.1.0"},
+	})
+	s, err := client.NewShed(
+		client.WithLockfilePath(lockfilePath),
+		client.WithCacheDir(filepath.Join(td, "cache")),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	// Another process is updating the lockfile
+	l, err := filelock.Acquire(context.Background(), filepath.Join(td, client.ProjectDirName, "lock"), filelock.Options{})
+	if err != nil {
+		t.Fatalf("failed to lock: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = s.Uninstall(ctx, "ejson")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	l.Unlock()
+
+	// Another process is installing tools
+	l, err = filelock.Acquire(context.Background(), filepath.Join(td, "cache", "lock"), filelock.Options{Shared: true})
+	if err != nil {
+		t.Fatalf("failed to lock: %v", err)
+	}
+	defer l.Unlock()
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := s.CleanCache(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if _, err := s.PruneCache(ctx, cache.PruneOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestUninstall(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.2"},
+	})
+	s, err := client.NewShed(
+		client.WithLockfilePath(lockfilePath),
+		client.WithCache(cache.New(td)),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	uninstallTools := []string{"go-fish", "golangci-lint"}
+	err = s.Uninstall(context.Background(), uninstallTools...)
+	if err != nil {
+		t.Errorf("want nil error, got %v", err)
+	}
+
+	lf := readLockfile(t, lockfilePath)
+	for _, tn := range uninstallTools {
+		_, err := lf.GetTool(tn)
+		if !errors.Is(err, lockfile.ErrNotFound) {
+			t.Errorf("want ErrNotFound, got %v", err)
+		}
+		// ToolPath will return an error if the binary does not exist
+		_, err = s.ToolPath(tn)
+		if err == nil {
 			t.Error("want non-nil error, got nil")
 		}
 	}
 
-	tl, err := lf.GetTool("ejson")
+	tl, err := lf.GetTool("ejson")
+	if err != nil {
+		t.Errorf("want nil error, got %v", err)
+	}
+	wantTool := tool.Tool{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.2"}
+	if tl != wantTool {
+		t.Errorf("got %+v, want %+v", tl, wantTool)
+	}
+}
+
+func TestList(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	wantTools := []tool.Tool{
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.2"},
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
+	}
+	createLockfile(t, lockfilePath, wantTools)
+	s, err := client.NewShed(
+		client.WithLockfilePath(lockfilePath),
+		client.WithCache(cache.New(td)),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	got := s.List()
+	if !reflect.DeepEqual(got, wantTools) {
+		t.Errorf("got tools %+v, want %+v", got, wantTools)
+	}
+}
+
+func TestInstallProgress(t *testing.T) {
+	td := t.TempDir()
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithCache(cache.New(td, cache.WithGo(mockGo))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	apply := func(toolNames ...string) map[string][]client.EventKind {
+		installSet, err := s.Install(toolNames...)
+		if err != nil {
+			t.Fatalf("want nil error, got %v", err)
+		}
+		events := make(map[string][]client.EventKind)
+		err = installSet.Apply(context.Background(), client.WithProgress(func(ev client.Event) {
+			events[ev.Tool.ImportPath] = append(events[ev.Tool.ImportPath], ev.Kind)
+		}))
+		if err != nil {
+			t.Fatalf("want nil error, got %v", err)
+		}
+		return events
+	}
+
+	got := apply("github.com/cszatmary/go-fish", "github.com/Shopify/ejson/cmd/ejson@v1.1.0")
+	want := map[string][]client.EventKind{
+		"github.com/cszatmary/go-fish":       {client.EventResolving, client.EventBuilding, client.EventDone},
+		"github.com/Shopify/ejson/cmd/ejson": {client.EventDownloading, client.EventBuilding, client.EventDone},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+
+	// Tools in the cache are done right away
+	got = apply()
+	want = map[string][]client.EventKind{
+		"github.com/cszatmary/go-fish":       {client.EventDone},
+		"github.com/Shopify/ejson/cmd/ejson": {client.EventDone},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+}
+
+func TestInstallProgressETA(t *testing.T) {
+	td := t.TempDir()
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	c := cache.New(td, cache.WithGo(mockGo))
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithCache(c),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	apply := func(toolName string) []client.Event {
+		installSet, err := s.Install(toolName)
+		if err != nil {
+			t.Fatalf("want nil error, got %v", err)
+		}
+		var events []client.Event
+		err = installSet.Apply(context.Background(), client.WithProgress(func(ev client.Event) {
+			events = append(events, ev)
+		}))
+		if err != nil {
+			t.Fatalf("want nil error, got %v", err)
+		}
+		return events
+	}
+
+	// No estimate the first time the tool is built
+	for _, ev := range apply("github.com/Shopify/ejson/cmd/ejson@v1.1.0") {
+		if ev.ETA != 0 {
+			t.Errorf("got ETA %s for %s event, want 0", ev.ETA, ev.Kind)
+		}
+	}
+	buildTimes, err := c.BuildTimes()
 	if err != nil {
-		t.Errorf("want nil error, got %v", err)
+		t.Fatalf("want nil error, got %v", err)
 	}
-	wantTool := tool.Tool{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.2"}
-	if tl != wantTool {
-		t.Errorf("got %+v, want %+v", tl, wantTool)
+	expected, ok := buildTimes["github.com/Shopify/ejson/cmd/ejson"]
+	if !ok || expected <= 0 {
+		t.Fatalf("got build times %v, want a build time for ejson", buildTimes)
+	}
+
+	// Another version of the tool is expected to take as long as the previous build
+	events := apply("github.com/Shopify/ejson/cmd/ejson@v1.2.2")
+	if len(events) == 0 || events[0].Kind != client.EventDownloading {
+		t.Fatalf("got events %v, want downloading first", events)
+	}
+	if events[0].ETA != expected {
+		t.Errorf("got ETA %s, want %s", events[0].ETA, expected)
+	}
+	if last := events[len(events)-1]; last.Kind != client.EventDone || last.ETA != 0 {
+		t.Errorf("got last event %s with ETA %s, want done with ETA 0", last.Kind, last.ETA)
 	}
 }
 
-func TestList(t *testing.T) {
+func TestInstallProgressFailed(t *testing.T) {
+	td := t.TempDir()
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithCache(cache.New(td, cache.WithGo(mockGo))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	installSet, err := s.Install("github.com/cszatmary/go-fish@v9.9.9")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	var events []client.Event
+	err = installSet.Apply(context.Background(), client.WithProgress(func(ev client.Event) {
+		events = append(events, ev)
+	}))
+	if err == nil {
+		t.Fatal("want non-nil error, got nil")
+	}
+	last := events[len(events)-1]
+	if last.Kind != client.EventFailed || last.Err == nil {
+		t.Errorf("got last event %v with error %v, want %v with an error", last.Kind, last.Err, client.EventFailed)
+	}
+}
+
+// approvedResolver only resolves versions that have been approved.
+type approvedResolver map[string][]string
+
+func (r approvedResolver) ListVersions(ctx context.Context, path string) ([]string, error) {
+	for mod, versions := range r {
+		if path == mod || strings.HasPrefix(path, mod+"/") {
+			return versions, nil
+		}
+	}
+	return nil, resolver.ErrNotFound
+}
+
+func (r approvedResolver) ResolveLatest(ctx context.Context, path string) (module.Version, error) {
+	return r.ResolveConstraint(ctx, path, "latest")
+}
+
+func (r approvedResolver) ResolveConstraint(ctx context.Context, path, constraint string) (module.Version, error) {
+	for mod, versions := range r {
+		if path != mod && !strings.HasPrefix(path, mod+"/") {
+			continue
+		}
+		v := resolver.Latest(versions)
+		if constraint != "latest" {
+			v, _, _ = resolver.Match(versions, constraint)
+		}
+		if v == "" {
+			return module.Version{}, resolver.ErrNotFound
+		}
+		return module.Version{Path: mod, Version: v}, nil
+	}
+	return module.Version{}, resolver.ErrNotFound
+}
+
+func TestInstallWithResolver(t *testing.T) {
+	td := t.TempDir()
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithCache(cache.New(td, cache.WithGo(mockGo))),
+		client.WithResolver(approvedResolver{
+			"github.com/golangci/golangci-lint": {"v1.28.3"},
+			"github.com/Shopify/ejson":          {"v1.1.0"},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	installSet, err := s.Install("github.com/golangci/golangci-lint/cmd/golangci-lint", "github.com/Shopify/ejson/cmd/ejson@v1")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	events := make(map[string][]client.EventKind)
+	err = installSet.Apply(context.Background(), client.WithProgress(func(ev client.Event) {
+		events[ev.Tool.ImportPath] = append(events[ev.Tool.ImportPath], ev.Kind)
+	}))
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	// The latest approved version is used, not the latest version
+	wantTools := []string{"github.com/Shopify/ejson/cmd/ejson@v1.1.0", "github.com/golangci/golangci-lint/cmd/golangci-lint@v1.28.3"}
+	var gotTools []string
+	for _, tl := range s.List() {
+		gotTools = append(gotTools, tl.String())
+	}
+	sort.Strings(gotTools)
+	if !reflect.DeepEqual(gotTools, wantTools) {
+		t.Errorf("got tools %v, want %v", gotTools, wantTools)
+	}
+	// Resolving no longer downloads, since the version is exact afterwards
+	wantEvents := []client.EventKind{client.EventResolving, client.EventDownloading, client.EventBuilding, client.EventDone}
+	if got := events["github.com/golangci/golangci-lint/cmd/golangci-lint"]; !reflect.DeepEqual(got, wantEvents) {
+		t.Errorf("got events %v, want %v", got, wantEvents)
+	}
+
+	// Unapproved versions can't be resolved
+	installSet, err = s.Install("github.com/Shopify/ejson/cmd/ejson@latest", "github.com/cszatmary/go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	err = installSet.Apply(context.Background())
+	var errs lockfile.ErrorList
+	if !errors.As(err, &errs) {
+		t.Fatalf("want error to be lockfile.ErrorList, got %v", err)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], resolver.ErrNotFound) {
+		t.Errorf("want 1 %v error, got %v", resolver.ErrNotFound, errs)
+	}
+}
+
+func TestInstallRange(t *testing.T) {
+	td := t.TempDir()
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	// The go command doesn't support ranges, so they are resolved with the proxy
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/github.com/golangci/golangci-lint/@v/list":
+			w.Write([]byte("v1.28.3\nv1.33.0\n")) //nolint:errcheck
+		case "/github.com/!shopify/ejson/@v/list":
+			w.Write([]byte("v1.1.0\nv1.2.2\n")) //nolint:errcheck
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer proxy.Close()
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithCache(cache.New(td, cache.WithGo(mockGo))),
+		client.WithGoProxy(proxy.URL),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	installSet, err := s.Install("github.com/golangci/golangci-lint/cmd/golangci-lint@^1.28", "github.com/Shopify/ejson/cmd/ejson@~1.1.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	// The highest matching version is pinned
+	wantTools := []string{"github.com/Shopify/ejson/cmd/ejson@v1.1.0", "github.com/golangci/golangci-lint/cmd/golangci-lint@v1.33.0"}
+	var gotTools []string
+	for _, tl := range s.List() {
+		gotTools = append(gotTools, tl.String())
+	}
+	sort.Strings(gotTools)
+	if !reflect.DeepEqual(gotTools, wantTools) {
+		t.Errorf("got tools %v, want %v", gotTools, wantTools)
+	}
+
+	installSet, err = s.Install("github.com/golangci/golangci-lint/cmd/golangci-lint@^2")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	err = installSet.Apply(context.Background())
+	var errs lockfile.ErrorList
+	if !errors.As(err, &errs) || len(errs) != 1 || !errors.Is(errs[0], resolver.ErrNotFound) {
+		t.Errorf("want 1 %v error, got %v", resolver.ErrNotFound, err)
+	}
+}
+
+func TestInstallPlan(t *testing.T) {
 	td := t.TempDir()
 	lockfilePath := filepath.Join(td, "shed.lock")
-	wantTools := []tool.Tool{
-		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.2"},
-		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
-		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
 	}
-	createLockfile(t, lockfilePath, wantTools)
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.28.3"},
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.2"},
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58"},
+	})
 	s, err := client.NewShed(
 		client.WithLockfilePath(lockfilePath),
-		client.WithCache(cache.New(td)),
+		client.WithCache(cache.New(td, cache.WithGo(mockGo))),
+		client.WithResolver(approvedResolver{
+			"github.com/golangci/golangci-lint": {"v1.28.3", "v1.33.0"},
+			"example.org/z/random":              {"v2.1.0"},
+		}),
 	)
 	if err != nil {
 		t.Fatalf("failed to create shed client %v", err)
 	}
+	installSet, err := s.Install("go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
 
-	got := s.List()
-	if !reflect.DeepEqual(got, wantTools) {
-		t.Errorf("got tools %+v, want %+v", got, wantTools)
+	installSet, err = s.InstallSpecs([]client.ToolSpec{
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint"},
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.1.0"},
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "none"},
+		{ImportPath: "example.org/z/random/stringer/v2/cmd/stringer", Alias: "stringer2"},
+	})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	plan, err := installSet.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	got := make(map[string]string)
+	for _, pt := range plan.Tools {
+		got[pt.Tool.ImportPath] = fmt.Sprintf("%s %s -> %s cached=%t", pt.Action, pt.Current.Version, pt.Tool.Version, pt.Cached)
+	}
+	want := map[string]string{
+		"github.com/cszatmary/go-fish":                        "keep v0.1.0 -> v0.1.0 cached=true",
+		"github.com/golangci/golangci-lint/cmd/golangci-lint": "upgrade v1.28.3 -> v1.33.0 cached=false",
+		"github.com/Shopify/ejson/cmd/ejson":                  "downgrade v1.2.2 -> v1.1.0 cached=false",
+		"golang.org/x/tools/cmd/stringer":                     "remove v0.0.0-20201211185031-d93e913c1a58 -> v0.0.0-20201211185031-d93e913c1a58 cached=false",
+		"example.org/z/random/stringer/v2/cmd/stringer":       "add  -> v2.1.0 cached=false",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got plan %v, want %v", got, want)
+	}
+	if !plan.Changed() {
+		t.Error("want plan to change the lockfile")
+	}
+
+	// Nothing was changed
+	if _, err := s.ToolPath("golangci-lint"); err == nil {
+		t.Error("want golangci-lint to not be installed")
+	}
+	lf := readLockfile(t, lockfilePath)
+	if lt, err := lf.GetTool("github.com/golangci/golangci-lint/cmd/golangci-lint"); err != nil || lt.Version != "v1.28.3" {
+		t.Errorf("want lockfile to be unchanged, got %v, %v", lt, err)
+	}
+}
+
+func TestToolPathRebuild(t *testing.T) {
+	td := t.TempDir()
+	s := newPruneShed(t, td, filepath.Join(td, "cache"), []string{"github.com/cszatmary/go-fish@v0.1.0"})
+	ctx := context.Background()
+	binPath, err := s.ToolPath("go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	// The binary was deleted from the cache
+	if err := os.Remove(binPath); err != nil {
+		t.Fatalf("failed to remove binary: %v", err)
+	}
+	if _, err := s.ToolPath("go-fish"); !errors.Is(err, cache.ErrStale) {
+		t.Errorf("got error %v, want %v", err, cache.ErrStale)
+	}
+	if _, err := s.ToolPath("go-fish", client.Rebuild(ctx)); err != nil {
+		t.Fatalf("want binary to be rebuilt, got %v", err)
+	}
+
+	// The binary was built with an older version of Go
+	provPath := filepath.Join(filepath.Dir(binPath), "provenance.json")
+	data, err := ioutil.ReadFile(provPath)
+	if err != nil {
+		t.Fatalf("failed to read provenance: %v", err)
+	}
+	data = bytes.Replace(data, []byte(runtime.Version()), []byte("go1.15"), 1)
+	if err := ioutil.WriteFile(provPath, data, 0o644); err != nil {
+		t.Fatalf("failed to write provenance: %v", err)
+	}
+	// It still works, but is only rebuilt if asked to
+	if _, err := s.ToolPath("go-fish"); err != nil {
+		t.Errorf("want nil error, got %v", err)
+	}
+	if _, err := s.ToolPath("go-fish", client.Rebuild(ctx)); err != nil {
+		t.Fatalf("want binary to be rebuilt, got %v", err)
+	}
+	info, err := s.ToolInfo("go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if info.Provenance == nil || info.Provenance.GoVersion != runtime.Version() {
+		t.Errorf("got provenance %+v, want tool to be built with %s", info.Provenance, runtime.Version())
+	}
+}
+
+// flakyGo fails to download modules with err the first failures times.
+type flakyGo struct {
+	cache.Go
+	mu       sync.Mutex
+	err      error
+	failures int
+	calls    int
+}
+
+func (f *flakyGo) GetD(ctx context.Context, mod, dir string, env []string) error {
+	f.mu.Lock()
+	f.calls++
+	fail := f.calls <= f.failures
+	f.mu.Unlock()
+	if fail {
+		return f.err
+	}
+	return f.Go.GetD(ctx, mod, dir, env)
+}
+
+func TestInstallRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantErr   bool
+		wantCalls int
+	}{
+		{"transient error", errors.New("dial tcp: lookup proxy.golang.org: i/o timeout"), false, 2},
+		{"fatal error", errors.New("reading https://proxy.golang.org/@v/v0.1.0.info: 404 Not Found"), true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			td := t.TempDir()
+			mockGo, err := cache.NewMockGo(availableTools)
+			if err != nil {
+				t.Fatalf("failed to create mock go %v", err)
+			}
+			fg := &flakyGo{Go: mockGo, err: tt.err, failures: 1}
+			s, err := client.NewShed(
+				client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+				client.WithCache(cache.New(filepath.Join(td, "cache"), cache.WithGo(fg), cache.WithRetry(3, time.Millisecond, 0))),
+			)
+			if err != nil {
+				t.Fatalf("failed to create shed client %v", err)
+			}
+			installSet, err := s.Install("github.com/cszatmary/go-fish@v0.1.0")
+			if err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+			err = installSet.Apply(context.Background())
+			if gotErr := err != nil; gotErr != tt.wantErr {
+				t.Errorf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if fg.calls != tt.wantCalls {
+				t.Errorf("got %d downloads, want %d", fg.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestToolPathVersion(t *testing.T) {
+	td := t.TempDir()
+	cacheDir := filepath.Join(td, "cache")
+	s := newPruneShed(t, filepath.Join(td, "a"), cacheDir, []string{
+		"github.com/golangci/golangci-lint/cmd/golangci-lint@v1.33.0",
+		"github.com/Shopify/ejson/cmd/ejson@v1.2.2",
+	})
+	// Another project puts an older version in the cache
+	newPruneShed(t, filepath.Join(td, "b"), cacheDir, []string{"github.com/golangci/golangci-lint/cmd/golangci-lint@v1.28.3"})
+
+	locked, err := s.ToolPath("golangci-lint")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	older, err := s.ToolPathVersion("golangci-lint", "v1.28.3")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if older == locked || !strings.Contains(older, "golangci-lint@v1.28.3") {
+		t.Errorf("got path %s, want the binary of v1.28.3", older)
+	}
+
+	// Not in the cache unless it is rebuilt
+	if _, err := s.ToolPathVersion("ejson", "v1.1.0"); !errors.Is(err, cache.ErrStale) {
+		t.Errorf("got error %v, want %v", err, cache.ErrStale)
+	}
+	if _, err := s.ToolPathVersion("ejson", "v1.1.0", client.Rebuild(context.Background())); err != nil {
+		t.Errorf("want nil error, got %v", err)
+	}
+	if _, err := s.ToolPathVersion("ejson", "latest"); err == nil {
+		t.Error("want error for inexact version, got nil")
+	}
+
+	lf := readLockfile(t, filepath.Join(td, "a", "shed.lock"))
+	for _, name := range []string{"golangci-lint", "ejson"} {
+		tl, err := lf.GetTool(name)
+		if err != nil || (tl.Version != "v1.33.0" && tl.Version != "v1.2.2") {
+			t.Errorf("got %v in lockfile, want it to be unchanged", tl)
+		}
+	}
+}
+
+type failingBuildGo struct {
+	cache.Go
+}
+
+func (failingBuildGo) Build(ctx context.Context, pkg, outPath, dir string, flags, env []string) error {
+	return errors.New("compile: undefined: foo")
+}
+
+func TestInstallToolErrors(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0", Alias: "fish"},
+	})
+	newShed := func(goClient cache.Go) *client.Shed {
+		s, err := client.NewShed(
+			client.WithLockfilePath(lockfilePath),
+			client.WithCache(cache.New(t.TempDir(), cache.WithGo(goClient))),
+		)
+		if err != nil {
+			t.Fatalf("failed to create shed client %v", err)
+		}
+		return s
+	}
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+
+	// toolErrors returns the *client.ToolError elements of err
+	toolErrors := func(err error) []*client.ToolError {
+		t.Helper()
+		var errs lockfile.ErrorList
+		if !errors.As(err, &errs) {
+			t.Fatalf("got error %v, want a lockfile.ErrorList", err)
+		}
+		var tes []*client.ToolError
+		for _, e := range errs {
+			var te *client.ToolError
+			if !errors.As(e, &te) {
+				t.Fatalf("got error %v, want a *client.ToolError", e)
+			}
+			tes = append(tes, te)
+		}
+		return tes
+	}
+
+	s := newShed(mockGo)
+	_, err = s.InstallSpecs([]client.ToolSpec{
+		{Name: "golangci-lint"},
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Alias: "fish"},
+	})
+	tes := toolErrors(err)
+	if len(tes) != 2 || tes[0].Kind != client.ErrInvalidSpec || tes[0].Spec != "golangci-lint" {
+		t.Fatalf("got errors %v, want golangci-lint to be an invalid spec", err)
+	}
+	var nameErr *lockfile.NameError
+	if !errors.Is(tes[1], client.ErrNameCollision) || !errors.As(tes[1], &nameErr) || nameErr.Name != "fish" || len(nameErr.Tools) != 2 {
+		t.Errorf("got error %v, want alias of ejson to collide with go-fish", tes[1])
+	}
+
+	installSet, err := s.Install("github.com/Shopify/ejson/cmd/ejson@v9.9.9")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	tes = toolErrors(installSet.Apply(context.Background()))
+	if len(tes) != 1 || !errors.Is(tes[0], client.ErrVersionNotFound) || tes[0].Tool.Version != "v9.9.9" {
+		t.Errorf("got errors %+v, want version of ejson not to be found", tes)
+	}
+
+	installSet, err = newShed(failingBuildGo{mockGo}).Install()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	tes = toolErrors(installSet.Apply(context.Background()))
+	var installErr *cache.InstallError
+	if len(tes) != 1 || tes[0].Kind != client.ErrBuildFailed || !errors.As(tes[0], &installErr) || installErr.Tool.ImportPath != "github.com/cszatmary/go-fish" {
+		t.Errorf("got errors %+v, want go-fish to fail to build", tes)
+	}
+}
+
+func TestGlobalLockfile(t *testing.T) {
+	td := t.TempDir()
+	cacheDir := filepath.Join(td, "cache")
+	globalDir := filepath.Join(td, "global")
+	newPruneShed(t, globalDir, cacheDir, []string{
+		"github.com/golangci/golangci-lint/cmd/golangci-lint@v1.28.3",
+		"github.com/Shopify/ejson/cmd/ejson@v1.2.2",
+	})
+	projectDir := filepath.Join(td, "project")
+	newPruneShed(t, projectDir, cacheDir, []string{"github.com/golangci/golangci-lint/cmd/golangci-lint@v1.33.0"})
+
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(projectDir, "shed.lock")),
+		client.WithGlobalLockfile(filepath.Join(globalDir, "shed.lock")),
+		client.WithCache(cache.New(cacheDir)),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	// The project lockfile takes precedence
+	p, err := s.ToolPath("golangci-lint")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if !strings.Contains(p, "golangci-lint@v1.33.0") {
+		t.Errorf("got path %s, want the binary of v1.33.0", p)
+	}
+	// Tools that aren't in the project are found in the global lockfile
+	p, err = s.ToolPath("ejson")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if !strings.Contains(p, "ejson@v1.2.2") {
+		t.Errorf("got path %s, want the binary of v1.2.2", p)
+	}
+	// The global tools aren't part of the project
+	if tools := s.List(); len(tools) != 1 {
+		t.Errorf("got tools %v, want only golangci-lint", tools)
+	}
+	// Unless they are asked for, and the project shadows golangci-lint
+	var names []string
+	for _, tl := range s.List(client.IncludeGlobal()) {
+		names = append(names, tl.String())
+	}
+	wantNames := []string{"github.com/Shopify/ejson/cmd/ejson@v1.2.2", "github.com/golangci/golangci-lint/cmd/golangci-lint@v1.33.0"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("got tools %v, want %v", names, wantNames)
+	}
+	if _, err := s.ToolPath("go-fish"); !errors.Is(err, lockfile.ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, lockfile.ErrNotFound)
+	}
+
+	// A missing global lockfile is ignored
+	s, err = client.NewShed(
+		client.WithLockfilePath(filepath.Join(projectDir, "shed.lock")),
+		client.WithGlobalLockfile(filepath.Join(td, "missing", "shed.lock")),
+		client.WithCache(cache.New(cacheDir)),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	if _, err := s.ToolPath("ejson"); !errors.Is(err, lockfile.ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, lockfile.ErrNotFound)
 	}
 }
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/getshiphub/shed/internal/complete"
+	"github.com/pkg/errors"
+)
+
+// CompleteTool returns shell completions for the arguments of a tool. args are the arguments
+// already passed to the tool and toComplete is the partial argument being completed.
+// Each completion is the value, optionally followed by a tab and a description.
+//
+// Completions are provided by the completion settings for the tool in the config file,
+// or by the spec bundled with shed for the tool if there are none. If no completions
+// are available a nil slice is returned, in which case the shell should complete files.
+func (s *Shed) CompleteTool(ctx context.Context, toolName string, args []string, toComplete string) ([]string, error) {
+	t, err := s.getTool(toolName)
+	if err != nil {
+		return nil, err
+	}
+	c := s.config.Tool(t).Completion
+	switch {
+	case c == nil:
+		spec, ok := complete.Bundled(t.ImportPath)
+		if !ok {
+			return nil, nil
+		}
+		return spec.Complete(args, toComplete), nil
+	case c.Cobra:
+		// Completions run the tool, so it must be trusted like when it is run directly
+		if err := s.checkTrust(t); err != nil {
+			return nil, err
+		}
+		return s.completeCobra(ctx, toolName, args, toComplete)
+	}
+
+	specPath := filepath.Join(s.projectRoot(), filepath.FromSlash(c.Spec))
+	f, err := os.Open(specPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open completion spec %s", specPath)
+	}
+	defer f.Close()
+	spec, err := complete.ParseSpec(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read completion spec %s", specPath)
+	}
+	return spec.Complete(args, toComplete), nil
+}
+
+// completeCobra requests completions from a tool built with cobra using its hidden __complete command.
+func (s *Shed) completeCobra(ctx context.Context, toolName string, args []string, toComplete string) ([]string, error) {
+	binPath, err := s.ToolPath(toolName)
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs := append([]string{"__complete"}, args...)
+	var stdout bytes.Buffer
+	c := exec.CommandContext(ctx, binPath, append(cmdArgs, toComplete)...)
+	c.Stdout = &stdout
+	if err := c.Run(); err != nil {
+		return nil, errors.Wrapf(err, "failed to get completions from %s", toolName)
+	}
+	completions, noFiles, err := complete.ParseCobra(&stdout)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get completions from %s", toolName)
+	}
+	if completions == nil && noFiles {
+		// Distinguish from nil which means files should be completed
+		completions = []string{}
+	}
+	return completions, nil
+}
@@ -0,0 +1,74 @@
+package client_test
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/config"
+	"github.com/getshiphub/shed/tool"
+)
+
+func TestCompleteTool(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.2"},
+	})
+	cfg := `{"tools": {"go-fish": {"completion": {"spec": "go-fish.json"}}}}`
+	if err := ioutil.WriteFile(filepath.Join(td, config.ProjectFileName), []byte(cfg), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	spec := `{"commands": [{"name": "swim", "description": "Go for a swim"}, {"name": "sleep"}]}`
+	writeFile(t, filepath.Join(td, "go-fish.json"), spec)
+	s, err := client.NewShed(client.WithLockfilePath(lockfilePath), client.WithNoCache())
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		tool       string
+		args       []string
+		toComplete string
+		want       []string
+	}{
+		{"spec from config", "go-fish", nil, "sw", []string{"swim\tGo for a swim"}},
+		{"bundled spec", "golangci-lint", []string{"run"}, "--fa", []string{"--fast\tRun only fast linters from enabled linters set"}},
+		{"no spec", "ejson", nil, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.CompleteTool(context.Background(), tt.tool, tt.args, tt.toComplete)
+			if err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompleteToolCobra(t *testing.T) {
+	// Echo the arguments back as completions, like a cobra tool would
+	script := `#!/bin/sh
+shift
+for arg in "$@"; do echo "$arg"; done
+echo ":4"
+`
+	s := newScriptShed(t, script, `{"tools": {"go-fish": {"completion": {"cobra": true}}}}`)
+	got, err := s.CompleteTool(context.Background(), "go-fish", []string{"swim"}, "--fa")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := []string{"swim", "--fa"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,237 @@
+package client
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// knownTools maps the binary names of common Go tools to their import paths.
+// DetectTools suggests a tool when its name is run by a script in the project.
+var knownTools = map[string]string{
+	"buf":                "github.com/bufbuild/buf/cmd/buf",
+	"gofumpt":            "mvdan.cc/gofumpt",
+	"goimports":          "golang.org/x/tools/cmd/goimports",
+	"golangci-lint":      "github.com/golangci/golangci-lint/cmd/golangci-lint",
+	"goreleaser":         "github.com/goreleaser/goreleaser/v2",
+	"gotestsum":          "gotest.tools/gotestsum",
+	"govulncheck":        "golang.org/x/vuln/cmd/govulncheck",
+	"mockery":            "github.com/vektra/mockery/v2",
+	"mockgen":            "go.uber.org/mock/mockgen",
+	"protoc-gen-go":      "google.golang.org/protobuf/cmd/protoc-gen-go",
+	"protoc-gen-go-grpc": "google.golang.org/grpc/cmd/protoc-gen-go-grpc",
+	"revive":             "github.com/mgechev/revive",
+	"shfmt":              "mvdan.cc/sh/v3/cmd/shfmt",
+	"sqlc":               "github.com/sqlc-dev/sqlc/cmd/sqlc",
+	"staticcheck":        "honnef.co/go/tools/cmd/staticcheck",
+	"stringer":           "golang.org/x/tools/cmd/stringer",
+}
+
+// configFileTools maps the names of config files to the tools that read them.
+var configFileTools = map[string]string{
+	".golangci.json":   "golangci-lint",
+	".golangci.toml":   "golangci-lint",
+	".golangci.yaml":   "golangci-lint",
+	".golangci.yml":    "golangci-lint",
+	".goreleaser.yaml": "goreleaser",
+	".goreleaser.yml":  "goreleaser",
+	".mockery.yaml":    "mockery",
+	".mockery.yml":     "mockery",
+	"buf.gen.yaml":     "buf",
+	"buf.yaml":         "buf",
+	"revive.toml":      "revive",
+	"sqlc.json":        "sqlc",
+	"sqlc.yaml":        "sqlc",
+	"sqlc.yml":         "sqlc",
+}
+
+// goInstallRegex matches commands that install or run a tool by import path, ex: 'go install example.org/x/cmd/x@latest'.
+var goInstallRegex = regexp.MustCompile(`\bgo\s+(?:install|run|get)\s+(?:-\S+\s+)*([a-z0-9-]+\.[a-z0-9.-]+/[^\s@"']+)@\S+`)
+
+// DetectedTool is a tool that DetectTools found a reference to.
+type DetectedTool struct {
+	// ImportPath is the import path of the tool.
+	ImportPath string
+	// Sources are the files that refer to the tool, relative to the directory that was scanned.
+	// They are sorted and use forward slashes.
+	Sources []string
+}
+
+// DetectTools scans the project in dir for tools that it uses, so they can be added to a lockfile
+// when adopting shed. A tool is detected if:
+//
+//   - It is a blank import of a tools.go file.
+//   - It is installed or run by import path, ex: 'go install example.org/x/cmd/x@latest' in a Makefile,
+//     shell script, Taskfile, justfile, GitHub Actions workflow, or go:generate directive.
+//   - A common tool is run by name in any of those files, ex: 'golangci-lint run'.
+//   - The project has the config file of a common tool, ex: .golangci.yml.
+//
+// Directories named vendor, node_modules, or testdata, and hidden directories other than .github,
+// are skipped. The tools are sorted by import path. No versions are detected, since references
+// usually don't pin them; the latest version of each tool should be installed.
+func DetectTools(dir string) ([]DetectedTool, error) {
+	// Maps import paths to the files that refer to them
+	byImportPath := make(map[string]map[string]bool)
+	// Maps known tool names to the files that refer to them
+	byName := make(map[string]map[string]bool)
+	add := func(m map[string]map[string]bool, key, source string) {
+		if m[key] == nil {
+			m[key] = make(map[string]bool)
+		}
+		m[key][source] = true
+	}
+
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := info.Name()
+		if info.IsDir() {
+			if p == dir {
+				return nil
+			}
+			if name == "vendor" || name == "node_modules" || name == "testdata" || (strings.HasPrefix(name, ".") && name != ".github") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if toolName, ok := configFileTools[name]; ok {
+			add(byName, toolName, rel)
+			return nil
+		}
+		if name == "tools.go" {
+			importPaths, err := toolsGoImports(p)
+			if err != nil {
+				return err
+			}
+			for _, importPath := range importPaths {
+				// Skip the standard library, ex: _ "embed"
+				if isImportPath(importPath) {
+					add(byImportPath, importPath, rel)
+				}
+			}
+			return nil
+		}
+		goFile := strings.HasSuffix(name, ".go")
+		if !goFile && !isScriptFile(rel) {
+			return nil
+		}
+		return scanScript(p, goFile, func(importPath, toolName string) {
+			if importPath != "" {
+				add(byImportPath, importPath, rel)
+			} else {
+				add(byName, toolName, rel)
+			}
+		})
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to detect tools in %s", dir)
+	}
+
+	// Tools found by name are skipped if they were also found by import path,
+	// since the project may use a different module than the one in knownTools
+	names := make(map[string]string)
+	for importPath := range byImportPath {
+		names[binaryName(importPath)] = importPath
+	}
+	for toolName, sources := range byName {
+		importPath, ok := names[toolName]
+		if !ok {
+			importPath = knownTools[toolName]
+		}
+		for s := range sources {
+			add(byImportPath, importPath, s)
+		}
+	}
+
+	tools := make([]DetectedTool, 0, len(byImportPath))
+	for importPath, sources := range byImportPath {
+		dt := DetectedTool{ImportPath: importPath}
+		for s := range sources {
+			dt.Sources = append(dt.Sources, s)
+		}
+		sort.Strings(dt.Sources)
+		tools = append(tools, dt)
+	}
+	sort.Slice(tools, func(i, j int) bool {
+		return tools[i].ImportPath < tools[j].ImportPath
+	})
+	return tools, nil
+}
+
+// isScriptFile reports whether the file at the slash separated path rel can run tools.
+func isScriptFile(rel string) bool {
+	name := path.Base(rel)
+	switch strings.ToLower(name) {
+	case "makefile", "gnumakefile", "justfile", "taskfile.yml", "taskfile.yaml", "dockerfile":
+		return true
+	}
+	switch path.Ext(name) {
+	case ".mk", ".sh", ".bash":
+		return true
+	case ".yml", ".yaml":
+		return path.Dir(rel) == ".github/workflows"
+	}
+	return false
+}
+
+// scanScript calls found with each tool referenced in the file at p, either by import path or by
+// the name of a known tool. If goFile is true, only go:generate directives are scanned.
+func scanScript(p string, goFile bool, found func(importPath, toolName string)) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if goFile {
+			if !strings.HasPrefix(line, "//go:generate ") {
+				continue
+			}
+			line = strings.TrimPrefix(line, "//go:generate ")
+		}
+		for _, m := range goInstallRegex.FindAllStringSubmatch(line, -1) {
+			found(m[1], "")
+		}
+		// Tools are often run from a directory, ex: $(GOBIN)/golangci-lint, so the last element is the name
+		words := strings.FieldsFunc(line, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./", r))
+		})
+		for _, w := range words {
+			if _, ok := knownTools[path.Base(w)]; ok && !isImportPath(w) {
+				found("", path.Base(w))
+			}
+		}
+	}
+	return s.Err()
+}
+
+// isImportPath reports whether p is the import path of a package outside of the standard library,
+// whose first element is a domain name.
+func isImportPath(p string) bool {
+	i := strings.IndexByte(p, '/')
+	return i > 0 && strings.Contains(p[:i], ".") && !strings.HasPrefix(p, ".")
+}
+
+// binaryName returns the name of the binary the go command builds for importPath,
+// which is the last element that isn't a major version suffix.
+func binaryName(importPath string) string {
+	name := path.Base(importPath)
+	if len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		return path.Base(path.Dir(importPath))
+	}
+	return name
+}
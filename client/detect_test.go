@@ -0,0 +1,50 @@
+package client_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/getshiphub/shed/client"
+)
+
+func TestDetectTools(t *testing.T) {
+	td := t.TempDir()
+	files := map[string]string{
+		"Makefile":                 "lint:\n\t$(GOBIN)/golangci-lint run ./...\n\ninstall:\n\tgo install github.com/goreleaser/goreleaser@v1.10.0\n\nrelease:\n\tgoreleaser release\n",
+		".golangci.yml":            "linters:\n  enable-all: true\n",
+		"tools/tools.go":           "//go:build tools\n\npackage tools\n\nimport (\n\t_ \"embed\"\n\n\t_ \"golang.org/x/tools/cmd/stringer\"\n)\n",
+		"gen/gen.go":               "package gen\n\n//go:generate stringer -type=Kind\n//go:generate go run go.uber.org/mock/mockgen@v0.4.0 -source=gen.go\n\n// goimports is mentioned here, but isn't run\n",
+		".github/workflows/ci.yml": "steps:\n  - run: go install golang.org/x/vuln/cmd/govulncheck@latest && govulncheck ./...\n",
+		// Skipped directories
+		"vendor/example.org/x/Makefile": "all:\n\tgofumpt -l .\n",
+		".cache/run.sh":                 "shfmt -l .\n",
+	}
+	for name, data := range files {
+		p := filepath.Join(td, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := ioutil.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	tools, err := client.DetectTools(td)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := []client.DetectedTool{
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Sources: []string{".golangci.yml", "Makefile"}},
+		// The import path in the Makefile is used instead of the known tool
+		{ImportPath: "github.com/goreleaser/goreleaser", Sources: []string{"Makefile"}},
+		{ImportPath: "go.uber.org/mock/mockgen", Sources: []string{"gen/gen.go"}},
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Sources: []string{"gen/gen.go", "tools/tools.go"}},
+		{ImportPath: "golang.org/x/vuln/cmd/govulncheck", Sources: []string{".github/workflows/ci.yml"}},
+	}
+	if !reflect.DeepEqual(tools, want) {
+		t.Errorf("got tools %+v, want %+v", tools, want)
+	}
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/mod/semver"
+)
+
+// devcontainerFeature is the devcontainer-feature.json file of a dev container feature.
+// See https://containers.dev/implementors/features for the full spec.
+type devcontainerFeature struct {
+	ID                string                               `json:"id"`
+	Version           string                               `json:"version"`
+	Name              string                               `json:"name"`
+	Description       string                               `json:"description"`
+	Options           map[string]devcontainerFeatureOption `json:"options"`
+	InstallsAfter     []string                             `json:"installsAfter"`
+	PostCreateCommand string                               `json:"postCreateCommand"`
+}
+
+type devcontainerFeatureOption struct {
+	Type        string `json:"type"`
+	Default     string `json:"default"`
+	Description string `json:"description"`
+}
+
+// devcontainerInstallScript installs shed in the container. VERSION is set by the dev container
+// CLI from the version option of the feature.
+const devcontainerInstallScript = `#!/bin/sh
+# Code generated by 'shed export --format=devcontainer-feature'. DO NOT EDIT.
+set -e
+
+if ! command -v go >/dev/null 2>&1; then
+	echo "go is required to install shed, add a feature that installs Go to the dev container" >&2
+	exit 1
+fi
+GOBIN=/usr/local/bin go install "github.com/getshiphub/shed@${VERSION:-latest}"
+`
+
+func (s *Shed) exportDevcontainerFeature(o exportOptions) ([]ExportFile, error) {
+	// Versions of shed are released without the v prefix but go install needs it
+	version := o.shedVersion
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	if !semver.IsValid(version) {
+		version = "latest"
+	}
+
+	feature := devcontainerFeature{
+		ID:          "shed",
+		Version:     "1.0.0",
+		Name:        "shed",
+		Description: "Installs shed and the Go tools in shed.lock.",
+		Options: map[string]devcontainerFeatureOption{
+			"version": {
+				Type:        "string",
+				Default:     version,
+				Description: "Version of shed to install.",
+			},
+		},
+		// The go feature is the usual way to get Go in a dev container, which is required to install shed
+		InstallsAfter: []string{"ghcr.io/devcontainers/features/go"},
+		// Tools are installed once the workspace is mounted, since the lockfile is in it.
+		// Use --frozen so the tools are exactly the ones in the lockfile.
+		PostCreateCommand: "shed install --frozen",
+	}
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(feature); err != nil {
+		return nil, errors.Wrap(err, "failed to serialize devcontainer-feature.json")
+	}
+	return []ExportFile{
+		{Name: "devcontainer-feature.json", Data: buf.Bytes()},
+		{Name: "install.sh", Data: []byte(devcontainerInstallScript), Executable: true},
+	}, nil
+}
@@ -0,0 +1,299 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/resolver"
+	"github.com/pkg/errors"
+)
+
+// Severity is how serious a problem found by Diagnose is.
+type Severity int
+
+const (
+	// SeverityInfo is something worth knowing that doesn't need to be fixed.
+	SeverityInfo Severity = iota
+	// SeverityWarning is a problem that may cause some operations to fail or be slower.
+	SeverityWarning
+	// SeverityError is a problem that prevents tools from being installed or run.
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// The checks performed by Diagnose, used as Finding.Check.
+const (
+	CheckGo       = "go"
+	CheckCache    = "cache"
+	CheckLockfile = "lockfile"
+	CheckGoProxy  = "goproxy"
+	CheckOrphans  = "orphans"
+)
+
+// Finding is a problem found by Diagnose.
+type Finding struct {
+	// Check is the check that found the problem, ex: CheckGo.
+	Check string
+	// Severity is how serious the problem is.
+	Severity Severity
+	// Message describes the problem.
+	Message string
+	// Fix suggests how to fix the problem. It is empty if there is nothing to do.
+	Fix string
+}
+
+// DiagnoseOptions customizes the checks performed by Diagnose.
+type DiagnoseOptions struct {
+	// Offline skips the checks that use the network, ex: checking that the module proxies are reachable.
+	Offline bool
+	// HTTPClient is used to reach the module proxies. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// proxyCheckTimeout is how long Diagnose waits for a module proxy to respond.
+const proxyCheckTimeout = 10 * time.Second
+
+// Diagnose checks the environment shed runs in for problems that would prevent tools from being installed
+// or run. It checks that the go command is available and new enough to build the tools, that the cache
+// directory is accessible and intact, that the lockfile can be parsed, that the module proxies in GOPROXY
+// are reachable, and whether the cache has tools that aren't used by any known lockfile, see PruneCache.
+//
+// Failing to perform a check is reported as a Finding rather than an error, so that one broken part of
+// the environment doesn't hide problems with the others. The findings are in the order of the checks.
+func (s *Shed) Diagnose(ctx context.Context, opts DiagnoseOptions) []Finding {
+	var findings []Finding
+	add := func(check string, severity Severity, fix, format string, args ...interface{}) {
+		findings = append(findings, Finding{Check: check, Severity: severity, Message: fmt.Sprintf(format, args...), Fix: fix})
+	}
+
+	if s.cache != nil {
+		s.diagnoseGo(ctx, add)
+		s.diagnoseCache(add)
+	}
+	s.diagnoseLockfile(add)
+	if s.cache != nil && !opts.Offline {
+		s.diagnoseGoProxy(ctx, opts.HTTPClient, add)
+	}
+	if s.cache != nil {
+		res, err := s.PruneCache(ctx, cache.PruneOptions{DryRun: true})
+		if err != nil {
+			add(CheckOrphans, SeverityWarning, "", "failed to find unused tools in the cache: %v", err)
+		} else if len(res.Removed) > 0 {
+			var size int64
+			for _, pt := range res.Removed {
+				size += pt.Size
+			}
+			add(CheckOrphans, SeverityInfo, "Run 'shed cache prune' to remove them",
+				"%d tools in the cache, using %d bytes, aren't used by any known lockfile", len(res.Removed), size)
+		}
+	}
+	return findings
+}
+
+type addFindingFunc func(check string, severity Severity, fix, format string, args ...interface{})
+
+// diagnoseGo checks that the go command can be run and is new enough to build the tools in the lockfile.
+func (s *Shed) diagnoseGo(ctx context.Context, add addFindingFunc) {
+	values, err := s.cache.GoEnv(ctx, "GOVERSION")
+	if err != nil {
+		add(CheckGo, SeverityError, "Install Go from https://go.dev/dl and make sure it is in PATH", "failed to run the go command: %v", err)
+		return
+	}
+	goVersion := values[0]
+	if goVersion == "" {
+		// GOVERSION was added in Go 1.16
+		add(CheckGo, SeverityWarning, "Install Go 1.16 or newer", "the go command is too old to report its version")
+		return
+	}
+	tc, err := s.RequiredToolchain(ctx)
+	if tc == nil {
+		add(CheckGo, SeverityWarning, "", "failed to find the version of Go required by the tools: %v", err)
+		return
+	}
+	if err != nil {
+		s.logger.WithError(err).Debug("some tools were skipped when finding the required version of Go")
+	}
+	if requiring := tc.Requiring(goVersion); len(requiring) > 0 {
+		names := make([]string, len(requiring))
+		for i, tgv := range requiring {
+			names[i] = tgv.Tool.Name()
+		}
+		add(CheckGo, SeverityError, fmt.Sprintf("Install go %s or newer", tc.GoVersion),
+			"%s is too old to build %s", goVersion, strings.Join(names, ", "))
+	}
+}
+
+// diagnoseCache checks that the cache directory is writable and that the tools in it are intact.
+func (s *Shed) diagnoseCache(add addFindingFunc) {
+	dir := s.cache.Dir()
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		add(CheckCache, SeverityInfo, "", "cache %s does not exist yet, it is created when tools are installed", dir)
+		return
+	} else if err != nil {
+		add(CheckCache, SeverityError, "", "failed to access cache %s: %v", dir, err)
+		return
+	}
+	f, err := ioutil.TempFile(dir, ".doctor-")
+	if err != nil {
+		add(CheckCache, SeverityError, "Make sure you have permission to write to the cache directory",
+			"cache %s is not writable: %v", dir, err)
+	} else {
+		f.Close()
+		os.Remove(f.Name())
+	}
+
+	problems, err := s.cache.Doctor()
+	if err != nil {
+		add(CheckCache, SeverityError, "", "failed to check cache %s: %v", dir, err)
+		return
+	}
+	for _, p := range problems {
+		add(CheckCache, SeverityError, "Run 'shed cache clean' and 'shed install' to rebuild the cache", "%s", p)
+	}
+}
+
+// diagnoseLockfile checks that the lockfile on disk can be parsed and uses the current format.
+func (s *Shed) diagnoseLockfile(add addFindingFunc) {
+	f, err := os.Open(s.lockfilePath)
+	if os.IsNotExist(err) {
+		add(CheckLockfile, SeverityInfo, "Run 'shed install' with the tools to add", "no lockfile found at %s", s.lockfilePath)
+		return
+	} else if err != nil {
+		add(CheckLockfile, SeverityError, "", "failed to open lockfile %s: %v", s.lockfilePath, err)
+		return
+	}
+	defer f.Close()
+	lf, err := lockfile.Parse(f)
+	if errors.Is(err, lockfile.ErrUnsupportedFormat) {
+		add(CheckLockfile, SeverityError, "Upgrade shed", "lockfile %s was written by a newer version of shed", s.lockfilePath)
+		return
+	} else if err != nil {
+		add(CheckLockfile, SeverityError, "Fix the errors in the lockfile, or restore it from version control",
+			"failed to parse lockfile %s: %v", s.lockfilePath, err)
+		return
+	}
+	if v := lf.FileVersion(); v < lockfile.FormatVersion {
+		add(CheckLockfile, SeverityWarning, "Run 'shed migrate-lockfile' to upgrade it",
+			"lockfile %s uses format version %d, the current version is %d", s.lockfilePath, v, lockfile.FormatVersion)
+	}
+	var unsummed []string
+	for it := lf.Iter(); it.Next(); {
+		t := it.Value()
+		if t.Sum == "" && !t.IsRelease() {
+			unsummed = append(unsummed, t.Name())
+		}
+	}
+	if len(unsummed) > 0 {
+		add(CheckLockfile, SeverityWarning, "Run 'shed install' to record their hashes",
+			"the sources of %s can't be verified since their hashes aren't in the lockfile", strings.Join(unsummed, ", "))
+	}
+}
+
+// diagnoseGoProxy checks that every module proxy used to install the tools in the lockfile responds.
+func (s *Shed) diagnoseGoProxy(ctx context.Context, client *http.Client, add addFindingFunc) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var lists []string
+	if s.goProxy != "" {
+		lists = append(lists, s.goProxy)
+	} else {
+		values, err := s.cache.GoEnv(ctx, "GOPROXY")
+		if err != nil {
+			add(CheckGoProxy, SeverityWarning, "", "failed to find GOPROXY: %v", err)
+			return
+		}
+		lists = append(lists, values[0])
+	}
+	for _, t := range s.List() {
+		if p := s.config.InstallSettings(t).GoProxy; p != "" && !contains(lists, p) {
+			lists = append(lists, p)
+		}
+	}
+
+	checked := make(map[string]bool)
+	for _, list := range lists {
+		if list == "off" {
+			add(CheckGoProxy, SeverityWarning, "Set GOPROXY to a module proxy, ex: "+resolver.DefaultProxyURL,
+				"GOPROXY is off, so tools can only be installed from the module cache")
+			continue
+		}
+		urls := proxyURLs(list)
+		for _, u := range urls {
+			if checked[u] {
+				continue
+			}
+			checked[u] = true
+			s.checkProxy(ctx, client, u, add)
+		}
+	}
+}
+
+// proxyURLs returns the URLs of the module proxies in list, which has the same format as GOPROXY.
+// Like resolver.ProxyFromList, the list stops at 'direct' or 'off', since those aren't proxies.
+func proxyURLs(list string) []string {
+	var urls []string
+	for _, u := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == '|' }) {
+		if u == "direct" || u == "off" {
+			break
+		}
+		urls = append(urls, strings.TrimSuffix(u, "/"))
+	}
+	if len(urls) == 0 && list == "" {
+		urls = []string{resolver.DefaultProxyURL}
+	}
+	return urls
+}
+
+// checkProxy checks that the module proxy at rawURL responds. Any response means the proxy is reachable,
+// since proxies aren't required to serve anything at their root.
+func (s *Shed) checkProxy(ctx context.Context, client *http.Client, rawURL string, add addFindingFunc) {
+	// The URL may contain credentials, which shouldn't be printed
+	display := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		display = u.Redacted()
+	}
+	ctx, cancel := context.WithTimeout(ctx, proxyCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL+"/", nil)
+	if err != nil {
+		add(CheckGoProxy, SeverityError, "Fix the module proxy URL in GOPROXY", "invalid module proxy %s", display)
+		return
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		// The error contains the URL
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		add(CheckGoProxy, SeverityError, "Check your network connection, or set GOPROXY to a module proxy you can reach",
+			"failed to reach module proxy %s: %v", display, err)
+		return
+	}
+	res.Body.Close()
+	if res.StatusCode >= 500 {
+		add(CheckGoProxy, SeverityWarning, "", "module proxy %s responded with %s", display, res.Status)
+		return
+	}
+	s.logger.Debugf("module proxy %s is reachable", display)
+}
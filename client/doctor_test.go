@@ -0,0 +1,71 @@
+package client_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/getshiphub/shed/client"
+)
+
+func findingsFor(findings []client.Finding, check string) []client.Finding {
+	var out []client.Finding
+	for _, f := range findings {
+		if f.Check == check {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func TestDiagnose(t *testing.T) {
+	proxy := httptest.NewServer(http.NotFoundHandler())
+	defer proxy.Close()
+	td := t.TempDir()
+	cacheDir := filepath.Join(td, "cache")
+	s := newPruneShed(t, filepath.Join(td, "a"), cacheDir, []string{
+		"github.com/cszatmary/go-fish@v0.1.0",
+		"github.com/golangci/golangci-lint/cmd/golangci-lint@v1.33.0",
+	}, client.WithGoProxy(proxy.URL+",direct"))
+	findings := s.Diagnose(context.Background(), client.DiagnoseOptions{})
+	if len(findings) != 0 {
+		t.Errorf("got findings %+v, want none", findings)
+	}
+
+	// ejson is only used by a lockfile that no longer exists
+	other := filepath.Join(td, "b")
+	newPruneShed(t, other, cacheDir, []string{"github.com/Shopify/ejson/cmd/ejson@v1.2.2"}, client.WithGoProxy(proxy.URL))
+	if err := os.RemoveAll(other); err != nil {
+		t.Fatalf("failed to remove %s: %v", other, err)
+	}
+	findings = s.Diagnose(context.Background(), client.DiagnoseOptions{})
+	if orphans := findingsFor(findings, client.CheckOrphans); len(orphans) != 1 || orphans[0].Severity != client.SeverityInfo {
+		t.Errorf("got findings %+v, want ejson to be unused", findings)
+	}
+}
+
+func TestDiagnoseProblems(t *testing.T) {
+	td := t.TempDir()
+	// Nothing listens on port 1, so the proxy can't be reached
+	s := newPruneShed(t, td, filepath.Join(td, "cache"), []string{"github.com/cszatmary/go-fish@v0.1.0"}, client.WithGoProxy("http://127.0.0.1:1"))
+	if err := ioutil.WriteFile(filepath.Join(td, "shed.lock"), []byte(`{"tools": `), 0o644); err != nil {
+		t.Fatalf("failed to write lockfile: %v", err)
+	}
+
+	findings := s.Diagnose(context.Background(), client.DiagnoseOptions{})
+	if lf := findingsFor(findings, client.CheckLockfile); len(lf) != 1 || lf[0].Severity != client.SeverityError || lf[0].Fix == "" {
+		t.Errorf("got findings %+v, want the lockfile to fail to parse", findings)
+	}
+	if gp := findingsFor(findings, client.CheckGoProxy); len(gp) != 1 || gp[0].Severity != client.SeverityError {
+		t.Errorf("got findings %+v, want the proxy to be unreachable", findings)
+	}
+
+	findings = s.Diagnose(context.Background(), client.DiagnoseOptions{Offline: true})
+	if gp := findingsFor(findings, client.CheckGoProxy); len(gp) != 0 {
+		t.Errorf("got findings %+v, want the proxy not to be checked offline", gp)
+	}
+}
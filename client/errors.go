@@ -0,0 +1,93 @@
+package client
+
+import (
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/resolver"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidSpec is returned when a tool passed to Install or InstallSpecs is not valid,
+// ex: it has an invalid import path or names a tool that isn't in the lockfile.
+var ErrInvalidSpec = errors.New("invalid tool")
+
+// ErrNameCollision is returned when the name of a tool passed to Install or InstallSpecs
+// refers to more than one tool, or the alias of the tool clashes with another tool.
+// The cause is a *lockfile.NameError.
+var ErrNameCollision = errors.New("tool name collision")
+
+// ErrInstallFailed is returned when installing a tool fails for a reason that doesn't have
+// a more specific error, ex: a failed install hook.
+var ErrInstallFailed = errors.New("install failed")
+
+// ErrPolicyViolation is returned when a tool passed to InstallSet.Apply doesn't follow the policy
+// set with WithPolicy. The cause is a policy.Violation.
+var ErrPolicyViolation = errors.New("policy violation")
+
+// ErrNotVendored is returned when installing a tool offline needs something that isn't
+// in the vendor directory, see WithOffline.
+var ErrNotVendored = cache.ErrNotVendored
+
+// The kinds of errors from downloading and building tools, see cache.InstallError.
+var (
+	ErrVersionNotFound = cache.ErrVersionNotFound
+	ErrDownloadFailed  = cache.ErrDownloadFailed
+	ErrBuildFailed     = cache.ErrBuildFailed
+)
+
+// ToolError describes a single tool that Install, InstallSpecs, Plan, or InstallSet.Apply failed on.
+// The elements of the lockfile.ErrorList they return are *ToolError.
+// It matches Kind when using errors.Is, and Err can be inspected with errors.Is and errors.As,
+// ex: to get the *cache.InstallError or *lockfile.NameError that caused it.
+type ToolError struct {
+	// Kind classifies the error. It is ErrInvalidSpec, ErrNameCollision, ErrVersionNotFound,
+	// ErrDownloadFailed, ErrBuildFailed, ErrPolicyViolation, or ErrInstallFailed.
+	Kind error
+	// Spec is the tool as it was passed in, ex: 'golangci-lint' or 'github.com/cszatmary/go-fish@v0.1.0'.
+	Spec string
+	// Tool is the tool that failed, including the version that was requested.
+	// It is empty if the spec couldn't be parsed.
+	Tool tool.Tool
+	// Err is the cause, with a message describing the step that failed.
+	Err error
+}
+
+func (e *ToolError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ToolError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ToolError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// specError returns a *ToolError for the invalid spec. Its kind is ErrNameCollision
+// if err is a *lockfile.NameError, otherwise it is ErrInvalidSpec.
+func specError(spec ToolSpec, t tool.Tool, err error) *ToolError {
+	kind := ErrInvalidSpec
+	var nameErr *lockfile.NameError
+	if errors.As(err, &nameErr) {
+		kind = ErrNameCollision
+	}
+	return &ToolError{Kind: kind, Spec: spec.String(), Tool: t, Err: err}
+}
+
+// installError returns a *ToolError for t, which failed to install because of err.
+// The kind is found from the cause, and is ErrInstallFailed if it isn't known.
+func installError(t tool.Tool, err error) *ToolError {
+	kind := ErrInstallFailed
+	for _, k := range []error{ErrVersionNotFound, ErrDownloadFailed, ErrBuildFailed} {
+		if errors.Is(err, k) {
+			kind = k
+			break
+		}
+	}
+	if errors.Is(err, resolver.ErrNotFound) {
+		kind = ErrVersionNotFound
+	}
+	return &ToolError{Kind: kind, Spec: t.String(), Tool: t, Err: err}
+}
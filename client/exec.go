@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"strings"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// Exec runs a tool that doesn't need to be in the lockfile, passing args to it, ex: to try a tool
+// before adding it to the project. name is an import path with an optional version, in any form
+// supported by 'go install', ex: 'golang.org/x/tools/cmd/stringer@v0.12.0'. If the version is omitted,
+// the latest version is used. name can also be the name of a tool in the lockfile, in which case the
+// version defaults to the one in the lockfile.
+//
+// The tool is installed in the cache if it isn't already, but the lockfile is never changed.
+// The returned tool is the one that was run, with its version resolved.
+// Otherwise Exec behaves like Run, except that RunOptions.Version is ignored.
+func (s *Shed) Exec(ctx context.Context, name string, args []string, opts RunOptions) (tool.Tool, *RunReport, error) {
+	if s.cache == nil {
+		return tool.Tool{}, nil, ErrNoCache
+	}
+	t, err := s.execTool(name)
+	if err != nil {
+		return t, nil, err
+	}
+	// Queries like 'latest' must be resolved again each time, the same as with 'go run'
+	if _, err := s.cache.ToolPath(t); errors.Is(err, cache.ErrStale) || !t.HasSemver() {
+		s.logger.Debugf("Installing %s in the cache without changing the lockfile", t)
+		t, _, err = s.installTool(ctx, t, applyOptions{})
+		if err != nil {
+			return t, nil, err
+		}
+	} else if opts.Rebuild {
+		if err := s.rebuildStale(ctx, t); err != nil {
+			return t, nil, err
+		}
+	}
+	binPath, err := s.cache.ToolPath(t)
+	if err != nil {
+		return t, nil, err
+	}
+	if err := s.checkTrust(t); err != nil {
+		return t, nil, err
+	}
+	opts.Version = ""
+	rs := resolveRunSettings(opts, s.config.Tool(t))
+	report, err := s.runBinary(ctx, t, binPath, args, rs, opts)
+	return t, report, err
+}
+
+// execTool returns the tool to run for name, see Exec.
+func (s *Shed) execTool(name string) (tool.Tool, error) {
+	toolName, version := name, ""
+	if i := strings.LastIndexByte(name, '@'); i != -1 {
+		toolName, version = name[:i], name[i+1:]
+	}
+	lt, err := s.findTool(toolName)
+	if err == nil {
+		if version != "" && version != lt.Version {
+			// The hashes are only known for the version in the lockfile
+			lt.Version = version
+			lt.Sum = ""
+			if lt.Release != nil {
+				r := *lt.Release
+				r.Sums = nil
+				lt.Release = &r
+			}
+			if lt.Reproducible != nil {
+				r := *lt.Reproducible
+				r.Sums = nil
+				lt.Reproducible = &r
+			}
+		}
+		return lt, nil
+	}
+	if !errors.Is(err, lockfile.ErrNotFound) || !strings.Contains(toolName, "/") {
+		return tool.Tool{}, err
+	}
+	t, err := tool.ParseLax(name)
+	if err != nil {
+		return t, errors.WithMessagef(err, "invalid tool %s", name)
+	}
+	return t, nil
+}
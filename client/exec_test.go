@@ -0,0 +1,64 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/getshiphub/shed/client"
+)
+
+func TestExec(t *testing.T) {
+	s := newScriptShed(t, "#!/bin/sh\necho go-fish \"$@\"\n", "")
+
+	// A tool in the lockfile is run at the locked version
+	var stdout bytes.Buffer
+	tl, report, err := s.Exec(context.Background(), "go-fish", []string{"hi"}, client.RunOptions{Stdout: &stdout})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if tl.Version != "v0.1.0" || report.ExitCode() != 0 || stdout.String() != "go-fish hi\n" {
+		t.Errorf("got %s with exit code %d and output %q, want v0.1.0 with exit code 0", tl, report.ExitCode(), stdout.String())
+	}
+
+	// A tool that isn't in the lockfile is installed in the cache first
+	const ejson = "github.com/Shopify/ejson/cmd/ejson"
+	tl, _, _ = s.Exec(context.Background(), ejson+"@v1.2.2", nil, client.RunOptions{})
+	if tl.ImportPath != ejson || tl.Version != "v1.2.2" {
+		t.Errorf("got tool %s, want %s@v1.2.2", tl, ejson)
+	}
+	binPath, err := s.ToolPathVersion(ejson, "v1.2.2")
+	if err != nil {
+		t.Fatalf("want ejson to be in the cache, got %v", err)
+	}
+	script := "#!/bin/sh\necho ejson \"$@\"\n"
+	if err := ioutil.WriteFile(binPath, []byte(script), 0o755); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	if err := os.Chmod(binPath, 0o755); err != nil {
+		t.Fatalf("failed to make script executable: %v", err)
+	}
+	recordBinarySum(t, binPath)
+
+	// The tool is run from the cache instead of being installed again
+	stdout.Reset()
+	_, _, err = s.Exec(context.Background(), ejson+"@v1.2.2", []string{"keygen"}, client.RunOptions{Stdout: &stdout})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if stdout.String() != "ejson keygen\n" {
+		t.Errorf("got output %q, want %q", stdout.String(), "ejson keygen\n")
+	}
+	for _, lt := range s.List() {
+		if strings.EqualFold(lt.ImportPath, ejson) {
+			t.Errorf("want ejson to not be added to the lockfile, got %s", lt)
+		}
+	}
+
+	if _, _, err := s.Exec(context.Background(), ejson+"@v9.0.0", nil, client.RunOptions{}); err == nil {
+		t.Errorf("want error for a version that doesn't exist, got nil")
+	}
+}
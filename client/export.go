@@ -0,0 +1,267 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/pkg/errors"
+)
+
+// ExportFormat is a format that the tools in the lockfile can be exported to,
+// so that other build systems can use the same versions of the tools as shed.
+type ExportFormat string
+
+const (
+	// ExportBazel is a Starlark file, usually named shed_tools.bzl, that declares a
+	// go_repository rule for the module of each tool using Gazelle. Each repository is pinned
+	// to the version in the lockfile and the module hash, so Bazel builds the same tools as shed.
+	ExportBazel ExportFormat = "bazel"
+	// ExportMise is a mise config file, usually named mise.toml, that pins each tool
+	// using the go backend of mise.
+	ExportMise ExportFormat = "mise"
+	// ExportDevcontainerFeature is a dev container feature, which is a directory containing
+	// devcontainer-feature.json and install.sh. It installs shed and runs 'shed install --frozen'
+	// when the container is created, so the container has the tools in the lockfile.
+	ExportDevcontainerFeature ExportFormat = "devcontainer-feature"
+	// ExportGoMod is the tool and require directives of a go.mod file, which allow the tools
+	// to be run with 'go tool' in Go 1.24 and later. See ExportGoModTools.
+	ExportGoMod ExportFormat = "gomod"
+	// ExportCycloneDX is a CycloneDX 1.5 JSON software bill of materials of the tools and the modules
+	// used to build them. See Export for details.
+	ExportCycloneDX ExportFormat = "cyclonedx"
+	// ExportSPDX is an SPDX 2.3 JSON software bill of materials of the tools and the modules
+	// used to build them. See Export for details.
+	ExportSPDX ExportFormat = "spdx"
+)
+
+// ExportFormats returns all the supported export formats.
+func ExportFormats() []ExportFormat {
+	return []ExportFormat{ExportBazel, ExportMise, ExportDevcontainerFeature, ExportGoMod, ExportCycloneDX, ExportSPDX}
+}
+
+// IsDir reports whether the format is a directory of files instead of a single file.
+// These formats must be exported with ExportFiles.
+func (f ExportFormat) IsDir() bool {
+	return f == ExportDevcontainerFeature
+}
+
+// ExportOption customizes an export.
+type ExportOption func(*exportOptions)
+
+type exportOptions struct {
+	shedVersion string
+	time        time.Time
+}
+
+// ExportShedVersion sets the version of shed that exports which install shed use.
+// If it is not a semantic version, ex: shed was built from source, the latest version is used.
+// SBOMs record it as the tool that created them.
+func ExportShedVersion(version string) ExportOption {
+	return func(o *exportOptions) {
+		o.shedVersion = version
+	}
+}
+
+// ExportTime sets the time SBOMs record they were created at. By default the current time is used,
+// which means SBOMs are only deterministic if this is set, ex: from SOURCE_DATE_EPOCH.
+func ExportTime(t time.Time) ExportOption {
+	return func(o *exportOptions) {
+		o.time = t
+	}
+}
+
+// ExportFile is a file in an export that is a directory, see ExportFiles.
+type ExportFile struct {
+	// Name is the path of the file relative to the directory, using forward slashes.
+	Name string
+	// Data is the contents of the file.
+	Data []byte
+	// Executable reports whether the file is a script that should be executable.
+	Executable bool
+}
+
+// ParseExportFormat returns the ExportFormat with the given name.
+func ParseExportFormat(name string) (ExportFormat, error) {
+	for _, f := range ExportFormats() {
+		if string(f) == name {
+			return f, nil
+		}
+	}
+	return "", errors.Errorf("unknown export format %q", name)
+}
+
+// Export writes the tools in the lockfile to w in the given format.
+// The output is deterministic, so it can be compared against a previous export
+// to find out if the export is out of date with the lockfile.
+//
+// For ExportBazel, tools that don't have a module hash in the lockfile use the hash
+// from the cache, so they must be installed. ExportGoMod has the same requirement for
+// tools that don't have a module in the lockfile.
+//
+// ExportCycloneDX and ExportSPDX are software bills of materials (SBOMs). They list each tool, and every
+// module whose source was used to build it as a dependency, see cache.Cache.BuildModules. Modules are
+// identified by their package URL, ex: 'pkg:golang/golang.org/x/tools@v0.1.0'. Since the modules are read
+// from the cache, the tools must be installed. Release tools aren't built from modules, so they are listed
+// without a package URL or dependencies. SBOMs are only deterministic if ExportTime is used.
+//
+// Formats that are a directory can't be written to w, use ExportFiles for them.
+func (s *Shed) Export(w io.Writer, format ExportFormat, opts ...ExportOption) error {
+	var o exportOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	switch format {
+	case ExportCycloneDX:
+		return s.exportCycloneDX(w, o)
+	case ExportSPDX:
+		return s.exportSPDX(w, o)
+	case ExportBazel:
+		return s.exportBazel(w)
+	case ExportMise:
+		return s.exportMise(w)
+	case ExportGoMod:
+		return s.ExportGoModTools(w)
+	}
+	if format.IsDir() {
+		return errors.Errorf("export format %q is a directory, it can't be written to a single file", format)
+	}
+	return errors.Errorf("unknown export format %q", format)
+}
+
+// ExportFiles is like Export but returns the files of formats that are a directory.
+// The files are sorted by name. If the format is a single file, an error is returned.
+func (s *Shed) ExportFiles(format ExportFormat, opts ...ExportOption) ([]ExportFile, error) {
+	var o exportOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	switch format {
+	case ExportDevcontainerFeature:
+		return s.exportDevcontainerFeature(o)
+	}
+	if _, err := ParseExportFormat(string(format)); err == nil {
+		return nil, errors.Errorf("export format %q is a single file, use Export", format)
+	}
+	return nil, errors.Errorf("unknown export format %q", format)
+}
+
+// bazelRepo is a go_repository rule in the bazel export.
+type bazelRepo struct {
+	name       string
+	importPath string
+	version    string
+	sum        string
+}
+
+func (s *Shed) exportBazel(w io.Writer) error {
+	if s.cache == nil {
+		return ErrNoCache
+	}
+	repos := make(map[string]bazelRepo)
+	// Maps tool names to the labels of the binaries
+	labels := make(map[string]string)
+	var errs lockfile.ErrorList
+	for _, t := range s.List() {
+		modPath, err := s.cache.ModulePath(t)
+		if err != nil {
+			errs = append(errs, errors.WithMessagef(err, "failed to export tool %s", t))
+			continue
+		}
+		t.ModulePath = modPath
+		// Prefer the hash in the lockfile, since it's what the tool is verified against
+		sum := t.Sum
+		if sum == "" {
+			sum, err = s.cache.ModuleSum(t)
+			if err != nil {
+				errs = append(errs, errors.WithMessagef(err, "failed to export tool %s", t))
+				continue
+			}
+		}
+		pkgDir, err := t.PackageDir()
+		if err != nil {
+			errs = append(errs, errors.WithMessagef(err, "failed to export tool %s", t))
+			continue
+		}
+
+		repo := bazelRepo{
+			name:       bazelRepoName(t.ModulePath),
+			importPath: t.ModulePath,
+			version:    t.Version,
+			sum:        sum,
+		}
+		if r, ok := repos[repo.name]; ok && r != repo {
+			errs = append(errs, errors.Errorf("failed to export tool %s: module %s is also used at %s by another tool", t, t.ModulePath, r.version))
+			continue
+		}
+		repos[repo.name] = repo
+		// Gazelle names the binary target after the package directory, even if the tool has an alias
+		labels[t.Name()] = fmt.Sprintf("@%s//%s:%s", repo.name, pkgDir, path.Base(t.ImportPath))
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	names := make([]string, 0, len(repos))
+	for name := range repos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	toolNames := make([]string, 0, len(labels))
+	for name := range labels {
+		toolNames = append(toolNames, name)
+	}
+	sort.Strings(toolNames)
+
+	var sb strings.Builder
+	sb.WriteString("# Code generated by 'shed export --format=bazel'. DO NOT EDIT.\n\n")
+	sb.WriteString("load(\"@bazel_gazelle//:deps.bzl\", \"go_repository\")\n\n")
+	sb.WriteString("# SHED_TOOLS maps the name of each tool to the label of its binary.\n")
+	sb.WriteString("SHED_TOOLS = {\n")
+	for _, name := range toolNames {
+		fmt.Fprintf(&sb, "    %q: %q,\n", name, labels[name])
+	}
+	sb.WriteString("}\n\n")
+	sb.WriteString("def shed_tools():\n")
+	sb.WriteString("    \"\"\"Declares the repositories of the tools in shed.lock.\"\"\"\n")
+	if len(names) == 0 {
+		sb.WriteString("    pass\n")
+	}
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		r := repos[name]
+		sb.WriteString("    go_repository(\n")
+		fmt.Fprintf(&sb, "        name = %q,\n", r.name)
+		fmt.Fprintf(&sb, "        importpath = %q,\n", r.importPath)
+		fmt.Fprintf(&sb, "        sum = %q,\n", r.sum)
+		fmt.Fprintf(&sb, "        version = %q,\n", r.version)
+		sb.WriteString("    )\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// bazelRepoName returns the name Gazelle uses for the repository of the module with the
+// given path. The domain is reversed and all other characters that aren't allowed are
+// replaced with underscores, ex: golang.org/x/tools becomes org_golang_x_tools.
+func bazelRepoName(modPath string) string {
+	parts := strings.Split(modPath, "/")
+	domain := strings.Split(parts[0], ".")
+	for i, j := 0, len(domain)-1; i < j; i, j = i+1, j-1 {
+		domain[i], domain[j] = domain[j], domain[i]
+	}
+	parts[0] = strings.Join(domain, ".")
+	name := strings.Join(parts, "/")
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
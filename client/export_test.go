@@ -0,0 +1,391 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+)
+
+func TestExportBazel(t *testing.T) {
+	s := newLockfileShed(t, t.TempDir(), availableTools, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
+	})
+	installSet, err := s.Install()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := s.Export(buf, client.ExportBazel); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := `# Code generated by 'shed export --format=bazel'. DO NOT EDIT.
+
+load("@bazel_gazelle//:deps.bzl", "go_repository")
+
+# SHED_TOOLS maps the name of each tool to the label of its binary.
+SHED_TOOLS = {
+    "go-fish": "@com_github_cszatmary_go_fish//:go-fish",
+    "golangci-lint": "@com_github_golangci_golangci_lint//cmd/golangci-lint:golangci-lint",
+}
+
+def shed_tools():
+    """Declares the repositories of the tools in shed.lock."""
+    go_repository(
+        name = "com_github_cszatmary_go_fish",
+        importpath = "github.com/cszatmary/go-fish",
+        sum = "h1:DQRb3Ted7y/Y8Wo7NrJv923AyQ942NK6VwbYSix0MMo=",
+        version = "v0.1.0",
+    )
+
+    go_repository(
+        name = "com_github_golangci_golangci_lint",
+        importpath = "github.com/golangci/golangci-lint",
+        sum = "h1:2LQvNcBdwkWytOgG01je44UW/btPPfrcRF0kOTnT318=",
+        version = "v1.33.0",
+    )
+`
+	if buf.String() != want {
+		t.Errorf("got export\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestExportNotInstalled(t *testing.T) {
+	s := newLockfileShed(t, t.TempDir(), availableTools, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+	})
+	err := s.Export(&bytes.Buffer{}, client.ExportBazel)
+	var errs lockfile.ErrorList
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Errorf("got error %v, want 1 error for the tool that isn't installed", err)
+	}
+}
+
+func TestExportMise(t *testing.T) {
+	// The mise export only uses shed.lock so the tools don't need to be installed
+	s := newLockfileShed(t, t.TempDir(), availableTools, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
+	})
+	buf := &bytes.Buffer{}
+	if err := s.Export(buf, client.ExportMise); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := `# Code generated by 'shed export --format=mise'. DO NOT EDIT.
+
+[tools]
+"go:github.com/cszatmary/go-fish" = "v0.1.0"
+"go:github.com/golangci/golangci-lint/cmd/golangci-lint" = "v1.33.0"
+`
+	if buf.String() != want {
+		t.Errorf("got export\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	// Exported tools should be imported as is
+	specs, err := client.ReadMiseTools(buf)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	wantSpecs := []client.ToolSpec{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
+	}
+	if !reflect.DeepEqual(specs, wantSpecs) {
+		t.Errorf("got specs %+v, want %+v", specs, wantSpecs)
+	}
+}
+
+func TestExportGoModTools(t *testing.T) {
+	s := newLockfileShed(t, t.TempDir(), availableTools, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0", ModulePath: "github.com/golangci/golangci-lint"},
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58", ModulePath: "golang.org/x/tools"},
+	})
+	// go-fish has no module in shed.lock so it has to be installed
+	buf := &bytes.Buffer{}
+	if err := s.ExportGoModTools(buf); err == nil {
+		t.Fatal("want non-nil error, got nil")
+	}
+	installSet, err := s.Install()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	buf.Reset()
+	if err := s.Export(buf, client.ExportGoMod); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := `// Code generated by 'shed export --format=gomod'. DO NOT EDIT.
+// Merge these directives into go.mod and run 'go mod tidy'.
+
+tool (
+	github.com/cszatmary/go-fish
+	github.com/golangci/golangci-lint/cmd/golangci-lint
+	golang.org/x/tools/cmd/stringer
+)
+
+require (
+	github.com/cszatmary/go-fish v0.1.0
+	github.com/golangci/golangci-lint v1.33.0
+	golang.org/x/tools v0.0.0-20201211185031-d93e913c1a58
+)
+`
+	if buf.String() != want {
+		t.Errorf("got export\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestReadMiseTools(t *testing.T) {
+	config := `# Project tools
+[env]
+GOFLAGS = "-mod=mod"
+
+[tools]
+node = "20"
+"go:github.com/cszatmary/go-fish" = "0.1.0" # no v prefix
+'go:golang.org/x/tools/cmd/stringer' = "latest"
+"go:github.com/golangci/golangci-lint/cmd/golangci-lint" = ["v1.33.0", "v1.32.0"]
+"go:github.com/Shopify/ejson/cmd/ejson" = { version = "v1.2.2", os = ["linux"] }
+
+[settings]
+"go:example.com/not/a/tool" = "v1.0.0"
+`
+	specs, err := client.ReadMiseTools(strings.NewReader(config))
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := []client.ToolSpec{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "golang.org/x/tools/cmd/stringer"},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.2"},
+	}
+	if !reflect.DeepEqual(specs, want) {
+		t.Errorf("got specs %+v, want %+v", specs, want)
+	}
+}
+
+func TestReadMiseToolsInvalid(t *testing.T) {
+	config := `[tools]
+"go:github.com/cszatmary/go-fish" = 1
+"go:golang.org/x/tools/cmd/stringer
+`
+	_, err := client.ReadMiseTools(strings.NewReader(config))
+	var errs lockfile.ErrorList
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Errorf("got error %v, want 2 errors", err)
+	}
+}
+
+func TestExportDevcontainerFeature(t *testing.T) {
+	s := newLockfileShed(t, t.TempDir(), availableTools, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+	})
+	tests := []struct {
+		name        string
+		shedVersion string
+		wantVersion string
+	}{
+		{"release", "0.3.0", "v0.3.0"},
+		{"release with v", "v0.3.0", "v0.3.0"},
+		{"built from source", "", "latest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := s.ExportFiles(client.ExportDevcontainerFeature, client.ExportShedVersion(tt.shedVersion))
+			if err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+			if len(files) != 2 || files[0].Name != "devcontainer-feature.json" || files[1].Name != "install.sh" {
+				t.Fatalf("got files %+v, want devcontainer-feature.json and install.sh", files)
+			}
+			if !files[1].Executable {
+				t.Errorf("want install.sh to be executable")
+			}
+
+			var feature struct {
+				ID      string `json:"id"`
+				Options map[string]struct {
+					Default string `json:"default"`
+				} `json:"options"`
+				PostCreateCommand string `json:"postCreateCommand"`
+			}
+			if err := json.Unmarshal(files[0].Data, &feature); err != nil {
+				t.Fatalf("failed to parse devcontainer-feature.json: %v", err)
+			}
+			if feature.ID != "shed" {
+				t.Errorf("got id %q, want shed", feature.ID)
+			}
+			if got := feature.Options["version"].Default; got != tt.wantVersion {
+				t.Errorf("got version %q, want %q", got, tt.wantVersion)
+			}
+			if feature.PostCreateCommand != "shed install --frozen" {
+				t.Errorf("got postCreateCommand %q, want shed install --frozen", feature.PostCreateCommand)
+			}
+		})
+	}
+
+	// Formats must be used with the right function
+	if err := s.Export(&bytes.Buffer{}, client.ExportDevcontainerFeature); err == nil {
+		t.Errorf("want non-nil error exporting a directory to a single file, got nil")
+	}
+	if _, err := s.ExportFiles(client.ExportMise); err == nil {
+		t.Errorf("want non-nil error exporting a single file as a directory, got nil")
+	}
+}
+
+func TestExportSBOM(t *testing.T) {
+	s := newLockfileShed(t, t.TempDir(), availableTools, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
+	})
+	installSet, err := s.Install()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	// Add a dependency to both tools and one that is only needed for its go.mod
+	deps := "github.com/pkg/errors v0.9.1 h1:abc=\ngithub.com/pkg/errors v0.9.1/go.mod h1:def=\n" +
+		"github.com/dgrijalva/jwt-go v3.2.0+incompatible/go.mod h1:ghi=\n"
+	for _, tl := range s.List() {
+		appendGoSum(t, s, tl, deps)
+	}
+	// Only go-fish uses this one
+	appendGoSum(t, s, s.List()[0], "github.com/dgrijalva/jwt-go v3.2.0+incompatible h1:jkl=\n")
+
+	buf := &bytes.Buffer{}
+	opts := []client.ExportOption{client.ExportTime(time.Unix(1600000000, 0)), client.ExportShedVersion("v0.9.0")}
+	if err := s.Export(buf, client.ExportCycloneDX, opts...); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	var bom struct {
+		Metadata struct {
+			Timestamp string
+		}
+		Components []struct {
+			BOMRef string `json:"bom-ref"`
+			Type   string
+			PURL   string
+		}
+		Dependencies []struct {
+			Ref       string
+			DependsOn []string
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &bom); err != nil {
+		t.Fatalf("failed to parse CycloneDX export: %v", err)
+	}
+	if want := "2020-09-13T12:26:40Z"; bom.Metadata.Timestamp != want {
+		t.Errorf("got timestamp %s, want %s", bom.Metadata.Timestamp, want)
+	}
+	var purls []string
+	for _, c := range bom.Components {
+		purls = append(purls, c.Type+" "+c.PURL)
+	}
+	wantPURLs := []string{
+		"application pkg:golang/github.com/cszatmary/go-fish@v0.1.0",
+		"application pkg:golang/github.com/golangci/golangci-lint@v1.33.0#cmd/golangci-lint",
+		"library pkg:golang/github.com/cszatmary/go-fish@v0.1.0",
+		"library pkg:golang/github.com/dgrijalva/jwt-go@v3.2.0%2Bincompatible",
+		"library pkg:golang/github.com/golangci/golangci-lint@v1.33.0",
+		"library pkg:golang/github.com/pkg/errors@v0.9.1",
+	}
+	if !reflect.DeepEqual(purls, wantPURLs) {
+		t.Errorf("got components %q, want %q", purls, wantPURLs)
+	}
+	if len(bom.Dependencies) != 2 {
+		t.Fatalf("got %d dependencies, want 2", len(bom.Dependencies))
+	}
+	wantDeps := []string{
+		"pkg:golang/github.com/cszatmary/go-fish@v0.1.0",
+		"pkg:golang/github.com/dgrijalva/jwt-go@v3.2.0%2Bincompatible",
+		"pkg:golang/github.com/pkg/errors@v0.9.1",
+	}
+	if dep := bom.Dependencies[0]; dep.Ref != bom.Components[0].BOMRef || !reflect.DeepEqual(dep.DependsOn, wantDeps) {
+		t.Errorf("got dependencies %q of %s, want %q of %s", dep.DependsOn, dep.Ref, wantDeps, bom.Components[0].BOMRef)
+	}
+
+	buf.Reset()
+	if err := s.Export(buf, client.ExportSPDX, opts...); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	var doc struct {
+		DocumentNamespace string
+		CreationInfo      struct {
+			Creators []string
+		}
+		Packages []struct {
+			SPDXID string
+			Name   string
+		}
+		Relationships []struct {
+			SPDXElementID      string `json:"spdxElementId"`
+			RelationshipType   string
+			RelatedSPDXElement string `json:"relatedSpdxElement"`
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("failed to parse SPDX export: %v", err)
+	}
+	if want := []string{"Tool: shed-v0.9.0"}; !reflect.DeepEqual(doc.CreationInfo.Creators, want) {
+		t.Errorf("got creators %q, want %q", doc.CreationInfo.Creators, want)
+	}
+	if len(doc.Packages) != 6 {
+		t.Errorf("got %d packages, want 6", len(doc.Packages))
+	}
+	counts := make(map[string]int)
+	for _, r := range doc.Relationships {
+		counts[r.RelationshipType]++
+	}
+	if want := map[string]int{"DESCRIBES": 2, "DEPENDS_ON": 5}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("got relationships %v, want %v", counts, want)
+	}
+
+	// The same export at the same time is identical, including the namespace
+	first := buf.String()
+	buf.Reset()
+	if err := s.Export(buf, client.ExportSPDX, opts...); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if buf.String() != first {
+		t.Error("want SPDX export to be deterministic")
+	}
+}
+
+func appendGoSum(t *testing.T, s *client.Shed, tl tool.Tool, lines string) {
+	t.Helper()
+	dir, err := cache.New(s.CacheDir()).ToolDir(tl)
+	if err != nil {
+		t.Fatalf("failed to get tool dir: %v", err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "go.sum"), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("failed to open go.sum: %v", err)
+	}
+	_, err = f.WriteString(lines)
+	f.Close()
+	if err != nil {
+		t.Fatalf("failed to write go.sum: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrGitHookExists is returned by InstallGitHooks when the repository already has a hook
+// that wasn't installed by shed. It is never overwritten.
+var ErrGitHookExists = errors.New("git hook already exists")
+
+// gitHookMarker is the line that identifies the git hooks installed by shed.
+const gitHookMarker = "# Installed by 'shed hooks install'."
+
+// GitHooks are the git hooks installed by InstallGitHooks. They run after the checked out commit changes,
+// so the tools in the lockfile are installed after switching branches or pulling.
+var GitHooks = []string{"post-checkout", "post-merge"}
+
+// gitHookScript returns the contents of the git hook named hook. dir is the directory that contains
+// the lockfile, relative to the root of the repository.
+func gitHookScript(hook, dir string) string {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	b.WriteString(gitHookMarker + "\n")
+	b.WriteString("# It installs the tools in shed.lock that are missing. Remove it with 'shed hooks uninstall'.\n")
+	if hook == "post-checkout" {
+		// The third argument is 0 when files were checked out instead of a branch
+		b.WriteString("[ \"$3\" = \"0\" ] && exit 0\n")
+	}
+	b.WriteString("command -v shed >/dev/null 2>&1 || exit 0\n")
+	if dir != "." {
+		quoted := strings.ReplaceAll(filepath.ToSlash(dir), "'", `'\''`)
+		fmt.Fprintf(&b, "cd '%s' || exit 0\n", quoted)
+	}
+	b.WriteString("exec shed sync\n")
+	return b.String()
+}
+
+// InstallGitHooks installs git hooks that run 'shed sync' in the git repository that contains
+// the lockfile, see GitHooks and Sync. It returns the paths of the hooks.
+// The hooks directory is the one git uses, so core.hooksPath is respected.
+//
+// Hooks that were installed by shed are replaced. If the repository already has one of the
+// hooks and it wasn't installed by shed, the error is ErrGitHookExists, and no hooks are installed.
+// The hooks do nothing if the shed command can't be found, so they never get in the way of git.
+func (s *Shed) InstallGitHooks(ctx context.Context) ([]string, error) {
+	hooksDir, rel, err := s.gitHooksDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, hook := range GitHooks {
+		p := filepath.Join(hooksDir, hook)
+		installed, err := isShedGitHook(p)
+		if os.IsNotExist(err) {
+			paths = append(paths, p)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if !installed {
+			return nil, errors.Wrapf(ErrGitHookExists, "%s was not installed by shed", p)
+		}
+		paths = append(paths, p)
+	}
+	if err := os.MkdirAll(hooksDir, 0o755); err != nil {
+		return nil, errors.Wrapf(err, "failed to create git hooks directory %s", hooksDir)
+	}
+	for i, p := range paths {
+		if err := ioutil.WriteFile(p, []byte(gitHookScript(GitHooks[i], rel)), 0o755); err != nil {
+			return nil, errors.Wrapf(err, "failed to write git hook %s", p)
+		}
+		// WriteFile doesn't change the permissions of an existing file
+		if err := os.Chmod(p, 0o755); err != nil {
+			return nil, errors.Wrapf(err, "failed to make git hook %s executable", p)
+		}
+		s.logger.Debugf("Installed git hook %s", p)
+	}
+	return paths, nil
+}
+
+// UninstallGitHooks removes the git hooks installed by InstallGitHooks and returns their paths.
+// Hooks that weren't installed by shed are left as is.
+func (s *Shed) UninstallGitHooks(ctx context.Context) ([]string, error) {
+	hooksDir, _, err := s.gitHooksDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, hook := range GitHooks {
+		p := filepath.Join(hooksDir, hook)
+		installed, err := isShedGitHook(p)
+		if os.IsNotExist(err) || (err == nil && !installed) {
+			continue
+		} else if err != nil {
+			return removed, err
+		}
+		if err := os.Remove(p); err != nil {
+			return removed, errors.Wrapf(err, "failed to remove git hook %s", p)
+		}
+		removed = append(removed, p)
+	}
+	return removed, nil
+}
+
+// isShedGitHook reports whether the git hook at path was installed by shed.
+// If it doesn't exist, the error satisfies os.IsNotExist.
+func isShedGitHook(path string) (bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, err
+	} else if err != nil {
+		return false, errors.Wrapf(err, "failed to read git hook %s", path)
+	}
+	return bytes.Contains(data, []byte(gitHookMarker)), nil
+}
+
+// gitHooksDir returns the absolute path of the hooks directory of the git repository that contains
+// the lockfile, and the directory of the lockfile relative to the root of the repository.
+func (s *Shed) gitHooksDir(ctx context.Context) (hooksDir, rel string, err error) {
+	dir, err := filepath.Abs(filepath.Dir(s.lockfilePath))
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to get absolute path of %s", s.lockfilePath)
+	}
+	var stdout, stderr bytes.Buffer
+	c := exec.CommandContext(ctx, "git", "rev-parse", "--show-toplevel", "--git-path", "hooks")
+	c.Dir = dir
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		return "", "", errors.Wrapf(err, "failed to find git repository of %s, stderr: %s", dir, strings.TrimSpace(stderr.String()))
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 {
+		return "", "", errors.Errorf("unexpected output from 'git rev-parse': %q", stdout.String())
+	}
+	root, hooksDir := filepath.FromSlash(lines[0]), filepath.FromSlash(lines[1])
+	if !filepath.IsAbs(hooksDir) {
+		hooksDir = filepath.Join(dir, hooksDir)
+	}
+	// The root may be a different path to the same directory, ex: if dir is a symlink
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return "", "", errors.Wrapf(err, "failed to resolve git repository root %s", lines[0])
+	}
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		return "", "", errors.Wrapf(err, "failed to resolve directory %s", dir)
+	}
+	rel, err = filepath.Rel(root, dir)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to find %s in git repository %s", dir, root)
+	}
+	return hooksDir, rel, nil
+}
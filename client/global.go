@@ -0,0 +1,129 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+	"github.com/getshiphub/shed/xdg"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// GlobalLockfilePath returns the path to the global lockfile, which tracks the tools of the current user
+// that aren't part of any project, like 'go install' but reproducible. This is 'xdg.ConfigDir()/shed.lock'.
+// To manage the tools in it, create a Shed with WithLockfilePath and this path.
+func GlobalLockfilePath() (string, error) {
+	dir, err := xdg.ConfigDir()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to find user config directory")
+	}
+	return filepath.Join(dir, LockfileName), nil
+}
+
+// WithGlobalLockfile sets the path to the global lockfile, see GlobalLockfilePath. Tools that aren't in the
+// lockfile are looked up in the global lockfile by ToolPath and Run, so the tools of the user can be run in
+// any project, or outside of one. The lockfile always takes precedence. The global lockfile is never changed,
+// and if it doesn't exist, it is ignored.
+func WithGlobalLockfile(p string) Option {
+	return func(s *Shed) {
+		s.globalLockfilePath = p
+	}
+}
+
+// readGlobalLockfile reads the global lockfile if one was set and it isn't the lockfile.
+func (s *Shed) readGlobalLockfile() error {
+	if s.globalLockfilePath == "" {
+		return nil
+	}
+	if same, err := sameFile(s.globalLockfilePath, s.lockfilePath); err == nil && same {
+		return nil
+	}
+	f, err := os.Open(s.globalLockfilePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to open file %s", s.globalLockfilePath)
+	}
+	defer f.Close()
+
+	s.globalLf, err = lockfile.Parse(f)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse global lockfile %s", s.globalLockfilePath)
+	}
+	s.logger.WithFields(logrus.Fields{
+		"path":    s.globalLockfilePath,
+		"version": s.globalLf.FileVersion(),
+	}).Debug("read global lockfile")
+	return nil
+}
+
+// findTool is like getTool, but if the tool isn't in the lockfile, it is looked up in the global lockfile.
+func (s *Shed) findTool(name string) (tool.Tool, error) {
+	t, err := s.getTool(name)
+	if !errors.Is(err, lockfile.ErrNotFound) || s.globalLf == nil {
+		return t, err
+	}
+	gt, gerr := s.globalLf.GetTool(name)
+	if errors.Is(gerr, lockfile.ErrNotFound) {
+		return t, err
+	}
+	if gerr == nil {
+		s.logger.WithFields(logrus.Fields{
+			"tool": gt,
+			"path": s.globalLockfilePath,
+		}).Debug("found tool in global lockfile")
+	}
+	return gt, gerr
+}
+
+// IncludeGlobal makes List also return the tools in the global lockfile that can be run, which are
+// the ones that don't have the same name or import path as a tool in the lockfile.
+// It has no effect if no global lockfile was set with WithGlobalLockfile.
+func IncludeGlobal() ListOption {
+	return func(o *listOptions) {
+		o.global = true
+	}
+}
+
+// globalTools returns the tools in the global lockfile that match o and aren't shadowed by the lockfile.
+// s.mu must be held.
+func (s *Shed) globalTools(o listOptions) []tool.Tool {
+	if s.globalLf == nil {
+		return nil
+	}
+	var tools []tool.Tool
+	it := s.globalLf.Iter()
+	for it.Next() {
+		t := it.Value()
+		if !o.matches(t) {
+			continue
+		}
+		if _, err := s.lf.GetTool(t.Name()); !errors.Is(err, lockfile.ErrNotFound) {
+			continue
+		}
+		if _, err := s.lf.GetTool(t.ImportPath); !errors.Is(err, lockfile.ErrNotFound) {
+			continue
+		}
+		tools = append(tools, t)
+	}
+	return tools
+}
+
+// Candidates returns all the tools that name could refer to, sorted by import path, ex: to let the user choose
+// between tools that share a name. See lockfile.Lockfile.Candidates for how names are matched. If no tool in the
+// lockfile matches, the tools in the global lockfile are used, if one was set with WithGlobalLockfile.
+//
+// When a name doesn't refer to a single tool, the error from methods that take a tool name has the details:
+// a *lockfile.NameError with the tools that share the name, or a *lockfile.NotFoundError with suggestions.
+func (s *Shed) Candidates(name string) []tool.Tool {
+	s.mu.RLock()
+	tools := s.lf.Candidates(name)
+	s.mu.RUnlock()
+	if len(tools) > 0 || s.globalLf == nil {
+		return tools
+	}
+	return s.globalLf.Candidates(name)
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// GlobalTool is a binary outside of shed with the same name as a tool in the lockfile,
+// ex: one installed with 'go install'. If it comes first in PATH, running the tool by name
+// runs it instead of the version in the lockfile.
+type GlobalTool struct {
+	Tool tool.Tool
+	// Path is the path to the binary.
+	Path string
+	// InGOBIN reports whether the binary is in GOBIN, where 'go install' puts binaries.
+	InGOBIN bool
+	// InPATH reports whether the binary is in a directory in PATH.
+	InPATH bool
+}
+
+// GOBIN returns the directory where 'go install' puts binaries, which is GOBIN if it is set
+// and the bin directory of the first entry in GOPATH otherwise. It is empty if neither is set.
+// shed never installs tools there, the go command is always run with GOBIN set to the cache.
+func (s *Shed) GOBIN(ctx context.Context) (string, error) {
+	if s.cache == nil {
+		return "", ErrNoCache
+	}
+	values, err := s.cache.GoEnv(ctx, "GOBIN", "GOPATH")
+	if err != nil {
+		return "", errors.WithMessage(err, "failed to find GOBIN")
+	}
+	if values[0] != "" {
+		return values[0], nil
+	}
+	gopath := filepath.SplitList(values[1])
+	if len(gopath) == 0 || gopath[0] == "" {
+		return "", nil
+	}
+	return filepath.Join(gopath[0], "bin"), nil
+}
+
+// GlobalTools finds binaries with the same names as the tools in the lockfile in GOBIN and in every
+// directory in PATH. ShimsDir and the cache are not searched, since they only contain tools
+// managed by shed. The binaries are returned in the order of the tools in the lockfile,
+// with the binaries for each tool in the order they are found in PATH.
+//
+// The name of the binary produced by the go command is used, not the alias, since that is
+// the name the tool has when installed with 'go install'.
+func (s *Shed) GlobalTools(ctx context.Context) ([]GlobalTool, error) {
+	tools := s.List()
+	if len(tools) == 0 {
+		return nil, nil
+	}
+	gobin, err := s.GOBIN(ctx)
+	if err != nil {
+		return nil, err
+	}
+	skip := []string{s.ShimsDir(), s.CacheDir()}
+	type searchDir struct {
+		path    string
+		inGOBIN bool
+		inPATH  bool
+	}
+	var dirs []*searchDir
+	seen := make(map[string]*searchDir)
+	addDir := func(dir string, inGOBIN bool) {
+		if dir == "" || !filepath.IsAbs(dir) {
+			return
+		}
+		dir = filepath.Clean(dir)
+		for _, p := range skip {
+			if p != "" && (dir == p || strings.HasPrefix(dir, p+string(filepath.Separator))) {
+				return
+			}
+		}
+		d, ok := seen[dir]
+		if !ok {
+			d = &searchDir{path: dir}
+			seen[dir] = d
+			dirs = append(dirs, d)
+		}
+		if inGOBIN {
+			d.inGOBIN = true
+		} else {
+			d.inPATH = true
+		}
+	}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		addDir(dir, false)
+	}
+	addDir(gobin, true)
+
+	var globalTools []GlobalTool
+	for _, t := range tools {
+		name := path.Base(t.ImportPath)
+		if runtime.GOOS == "windows" {
+			name += ".exe"
+		}
+		for _, d := range dirs {
+			p := filepath.Join(d.path, name)
+			ok, err := isExecutable(p)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				globalTools = append(globalTools, GlobalTool{Tool: t, Path: p, InGOBIN: d.inGOBIN, InPATH: d.inPATH})
+			}
+		}
+	}
+	return globalTools, nil
+}
+
+// isExecutable reports whether p is a file that can be run.
+func isExecutable(p string) (bool, error) {
+	fi, err := os.Stat(p)
+	if os.IsNotExist(err) || os.IsPermission(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrapf(err, "failed to check file %s", p)
+	}
+	if !fi.Mode().IsRegular() {
+		return false, nil
+	}
+	// Windows has no executable bit, the extension is what matters
+	return runtime.GOOS == "windows" || fi.Mode()&0o111 != 0, nil
+}
@@ -0,0 +1,82 @@
+package client_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/tool"
+)
+
+func TestGlobalTools(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("global tool tests use the executable bit")
+	}
+	td := t.TempDir()
+	gobin := filepath.Join(td, "gobin")
+	pathBin := filepath.Join(td, "usr", "bin")
+	shimsDir := filepath.Join(td, ".shed", "bin")
+	files := map[string]os.FileMode{
+		filepath.Join(gobin, "go-fish"):       0o755,
+		filepath.Join(gobin, "stringer"):      0o755,
+		filepath.Join(pathBin, "go-fish"):     0o755,
+		filepath.Join(pathBin, "ejson"):       0o755,
+		filepath.Join(pathBin, "stringer"):    0o644,
+		filepath.Join(shimsDir, "go-fish"):    0o755,
+		filepath.Join(td, "cache", "go-fish"): 0o755,
+	}
+	for p, mode := range files {
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := ioutil.WriteFile(p, []byte("#!/bin/sh\n"), mode); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	for k, v := range map[string]string{
+		"PATH":  shimsDir + string(os.PathListSeparator) + pathBin + string(os.PathListSeparator) + filepath.Join(td, "cache"),
+		"GOBIN": gobin,
+	} {
+		old, ok := os.LookupEnv(k)
+		os.Setenv(k, v)
+		if ok {
+			defer os.Setenv(k, old)
+		} else {
+			defer os.Unsetenv(k)
+		}
+	}
+
+	lockfilePath := filepath.Join(td, "shed.lock")
+	goFish := tool.Tool{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"}
+	stringer := tool.Tool{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58", Alias: "stringer-x"}
+	createLockfile(t, lockfilePath, []tool.Tool{goFish, stringer})
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	s, err := client.NewShed(
+		client.WithLockfilePath(lockfilePath),
+		client.WithCache(cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	globalTools, err := s.GlobalTools(context.Background())
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := []client.GlobalTool{
+		{Tool: goFish, Path: filepath.Join(pathBin, "go-fish"), InPATH: true},
+		{Tool: goFish, Path: filepath.Join(gobin, "go-fish"), InGOBIN: true},
+		{Tool: stringer, Path: filepath.Join(gobin, "stringer"), InGOBIN: true},
+	}
+	if !reflect.DeepEqual(globalTools, want) {
+		t.Errorf("got %+v, want %+v", globalTools, want)
+	}
+}
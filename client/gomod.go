@@ -0,0 +1,155 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/modfile"
+)
+
+// ExportGoModTools writes the tools in the lockfile to w as go.mod tool directives, which are
+// supported by Go 1.24 and later. A require directive is written for the module that provides
+// each tool, pinned to the version in the lockfile. The output is meant to be merged into a
+// go.mod file, after which the tools can be run with 'go tool'. Run 'go mod tidy' afterwards
+// to add the hashes to go.sum.
+//
+// Tools that don't have a module in the lockfile use the module from the cache, so they must
+// be installed. Aliases and build flags can't be expressed with tool directives, so they are
+// not exported and a warning is logged.
+func (s *Shed) ExportGoModTools(w io.Writer) error {
+	// Maps module paths to versions
+	requires := make(map[string]string)
+	var importPaths []string
+	var errs lockfile.ErrorList
+	for _, t := range s.List() {
+		modPath := t.ModulePath
+		if modPath == "" {
+			if s.cache == nil {
+				return ErrNoCache
+			}
+			var err error
+			modPath, err = s.cache.ModulePath(t)
+			if err != nil {
+				errs = append(errs, errors.WithMessagef(err, "failed to export tool %s", t))
+				continue
+			}
+		}
+		if v, ok := requires[modPath]; ok && v != t.Version {
+			errs = append(errs, errors.Errorf("tools from module %s have different versions %s and %s, go.mod can only have one", modPath, v, t.Version))
+			continue
+		}
+		if t.Alias != "" {
+			s.logger.Warnf("Tool %s has alias %s which can't be exported to go.mod, it will be named %s", t, t.Alias, t.Name())
+		}
+		if !t.BuildFlags.IsZero() {
+			s.logger.Warnf("Tool %s has build flags which can't be exported to go.mod, they will not be used by go tool", t)
+		}
+		requires[modPath] = t.Version
+		importPaths = append(importPaths, t.ImportPath)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	modPaths := make([]string, 0, len(requires))
+	for p := range requires {
+		modPaths = append(modPaths, p)
+	}
+	sort.Strings(modPaths)
+	sort.Strings(importPaths)
+
+	var sb strings.Builder
+	sb.WriteString("// Code generated by 'shed export --format=gomod'. DO NOT EDIT.\n")
+	sb.WriteString("// Merge these directives into go.mod and run 'go mod tidy'.\n\n")
+	writeGoModBlock(&sb, "tool", importPaths)
+	sb.WriteString("\n")
+	reqs := make([]string, len(modPaths))
+	for i, p := range modPaths {
+		reqs[i] = p + " " + requires[p]
+	}
+	writeGoModBlock(&sb, "require", reqs)
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeGoModBlock writes a go.mod directive with the given lines, using a block if there is more than one.
+func writeGoModBlock(sb *strings.Builder, verb string, lines []string) {
+	switch len(lines) {
+	case 0:
+		return
+	case 1:
+		fmt.Fprintf(sb, "%s %s\n", verb, lines[0])
+		return
+	}
+	fmt.Fprintf(sb, "%s (\n", verb)
+	for _, l := range lines {
+		fmt.Fprintf(sb, "\t%s\n", l)
+	}
+	sb.WriteString(")\n")
+}
+
+// ImportGoModTools reads the tool directives from the go.mod file at path, so the tools can be
+// installed with InstallSpecs. The version of each tool is the version of the module that
+// provides it in the go.mod file, like ImportToolsGo. It is the inverse of ExportGoModTools.
+//
+// If there are no tool directives, an error is returned. The specs are sorted by import path.
+func ImportGoModTools(path string) ([]ToolSpec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read file %s", path)
+	}
+	// ParseLax ignores directives it doesn't know, like tool, but keeps them in the syntax tree
+	modFile, err := modfile.ParseLax(path, data, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse go.mod file %s", path)
+	}
+	var importPaths []string
+	addTool := func(tokens []string) error {
+		if len(tokens) != 1 {
+			return errors.Errorf("invalid tool directive in %s: %s", path, strings.Join(tokens, " "))
+		}
+		importPath, err := parseGoModString(tokens[0])
+		if err != nil {
+			return errors.Wrapf(err, "invalid tool directive in %s", path)
+		}
+		importPaths = append(importPaths, importPath)
+		return nil
+	}
+	for _, stmt := range modFile.Syntax.Stmt {
+		switch x := stmt.(type) {
+		case *modfile.Line:
+			if len(x.Token) > 0 && x.Token[0] == "tool" {
+				if err := addTool(x.Token[1:]); err != nil {
+					return nil, err
+				}
+			}
+		case *modfile.LineBlock:
+			if len(x.Token) != 1 || x.Token[0] != "tool" {
+				continue
+			}
+			for _, l := range x.Line {
+				if err := addTool(l.Token); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if len(importPaths) == 0 {
+		return nil, errors.Errorf("no tool directives found in %s", path)
+	}
+	return goModToolSpecs(modFile, importPaths)
+}
+
+// parseGoModString returns the value of a go.mod token, which may be quoted.
+func parseGoModString(s string) (string, error) {
+	if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "`") {
+		return strconv.Unquote(s)
+	}
+	return s, nil
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// ListOption customizes which tools List returns.
+type ListOption func(*listOptions)
+
+type listOptions struct {
+	groups []string
+	global bool
+}
+
+// matches reports whether t should be listed.
+func (o listOptions) matches(t tool.Tool) bool {
+	if len(o.groups) == 0 {
+		return true
+	}
+	for _, g := range o.groups {
+		if t.InGroup(g) {
+			return true
+		}
+	}
+	return false
+}
+
+// InGroups makes List only return the tools that belong to at least one of groups.
+func InGroups(groups ...string) ListOption {
+	return func(o *listOptions) {
+		o.groups = append(o.groups, groups...)
+	}
+}
+
+// checkGroups checks that each group is a valid group name, see tool.CheckGroup.
+func checkGroups(groups []string) error {
+	for _, g := range groups {
+		if err := tool.CheckGroup(g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InstallGroups is like Install with the names of every tool in the lockfile that belongs to
+// at least one of groups, which allows installing a set of tools, ex: only the tools needed in CI.
+// If a group has no tools, an error is returned, since the name is most likely misspelled.
+func (s *Shed) InstallGroups(groups ...string) (*InstallSet, error) {
+	tools, err := s.groupTools(groups)
+	if err != nil {
+		return nil, err
+	}
+	return &InstallSet{s: s, tools: tools}, nil
+}
+
+// UninstallGroups removes groups from the lockfile. Tools that only belong to groups are uninstalled,
+// like with Uninstall. Tools that also belong to other groups are kept and removed from groups.
+// If a group has no tools, an error is returned, since the name is most likely misspelled.
+func (s *Shed) UninstallGroups(ctx context.Context, groups ...string) error {
+	tools, err := s.groupTools(groups)
+	if err != nil {
+		return err
+	}
+	return s.updateLockfile(ctx, func() error {
+		var uninstalled []tool.Tool
+		for _, t := range tools {
+			kept := t.WithoutGroups(groups...)
+			if kept.Groups == "" {
+				s.logger.Debugf("Uninstalling tool: %v", t)
+				s.lf.DeleteTool(t)
+				uninstalled = append(uninstalled, t)
+				continue
+			}
+			s.logger.Debugf("Removing tool %v from groups %v", t, groups)
+			if err := s.lf.PutTool(kept); err != nil {
+				return errors.Wrapf(err, "failed to update tool %v in lockfile", t)
+			}
+		}
+		return s.recordUninstalled(uninstalled)
+	})
+}
+
+// groupTools returns the tools in the lockfile that belong to at least one of groups.
+// Each group must be valid and have at least one tool.
+func (s *Shed) groupTools(groups []string) ([]tool.Tool, error) {
+	if len(groups) == 0 {
+		return nil, errors.New("no groups provided")
+	}
+	if err := checkGroups(groups); err != nil {
+		return nil, err
+	}
+	tools := s.List(InGroups(groups...))
+	var errs lockfile.ErrorList
+	for _, g := range groups {
+		found := false
+		for _, t := range tools {
+			if t.InGroup(g) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, errors.Wrapf(lockfile.ErrNotFound, "no tools in group %s", g))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return tools, nil
+}
@@ -0,0 +1,100 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+)
+
+func TestGroups(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0", Groups: "ci,dev"},
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.1.0", Groups: "dev"},
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58"},
+	})
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	s, err := client.NewShed(
+		client.WithLockfilePath(lockfilePath),
+		client.WithCache(cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	if tools := s.List(client.InGroups("dev")); len(tools) != 2 {
+		t.Errorf("got %d tools in group dev, want 2: %v", len(tools), tools)
+	}
+	installSet, err := s.InstallGroups("ci")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if installSet.Len() != 1 {
+		t.Errorf("got %d tools in group ci, want 1", installSet.Len())
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if _, err := s.ToolPath("go-fish"); err != nil {
+		t.Errorf("want go-fish to be installed, got %v", err)
+	}
+	if _, err := s.ToolPath("ejson"); err == nil {
+		t.Errorf("want ejson to not be installed")
+	}
+	_, err = s.InstallGroups("ci", "lint")
+	var errs lockfile.ErrorList
+	if !errors.As(err, &errs) || len(errs) != 1 || !errors.Is(errs[0], lockfile.ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, lockfile.ErrNotFound)
+	}
+
+	// Add a locked tool to a group, it stays in its other groups
+	installSet, err = s.InstallSpecs([]client.ToolSpec{{Name: "ejson", Groups: []string{"release"}}})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	lf := readLockfile(t, lockfilePath)
+	if tl, _ := lf.GetTool("ejson"); tl.Groups != "dev,release" {
+		t.Errorf("got ejson groups %q, want %q", tl.Groups, "dev,release")
+	}
+
+	if err := s.UninstallGroups(context.Background(), "dev"); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	lf = readLockfile(t, lockfilePath)
+	wantGroups := map[string]string{
+		"go-fish":  "ci",
+		"ejson":    "release",
+		"stringer": "",
+	}
+	for name, want := range wantGroups {
+		tl, err := lf.GetTool(name)
+		if err != nil {
+			t.Errorf("want %s in lockfile, got %v", name, err)
+			continue
+		}
+		if tl.Groups != want {
+			t.Errorf("got %s groups %q, want %q", name, tl.Groups, want)
+		}
+	}
+
+	if err := s.UninstallGroups(context.Background(), "ci"); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	lf = readLockfile(t, lockfilePath)
+	if _, err := lf.GetTool("go-fish"); !errors.Is(err, lockfile.ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, lockfile.ErrNotFound)
+	}
+}
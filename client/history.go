@@ -0,0 +1,238 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// HistoryFileName is the name of the file in the project directory containing the run history.
+// Each line is a JSON encoded RunRecord.
+const HistoryFileName = "history.jsonl"
+
+// RunRecord is an entry in the run history containing the stats of a single attempt at running a tool.
+type RunRecord struct {
+	// Time is when the attempt finished.
+	Time time.Time `json:"time"`
+	// ImportPath and Version identify the tool that was run.
+	ImportPath string `json:"importPath"`
+	Version    string `json:"version"`
+	// Task is the name of the task the tool was run for, if any.
+	Task string `json:"task,omitempty"`
+	// Attempt is the number of the attempt, starting at 1.
+	Attempt  int `json:"attempt"`
+	ExitCode int `json:"exitCode"`
+	// Duration, UserTime, SystemTime, and MaxRSS are the same as in RunAttempt.
+	Duration   time.Duration `json:"duration"`
+	UserTime   time.Duration `json:"userTime"`
+	SystemTime time.Duration `json:"systemTime"`
+	MaxRSS     int64         `json:"maxRSS,omitempty"`
+}
+
+func (s *Shed) historyPath() string {
+	return filepath.Join(s.projectRoot(), ProjectDirName, HistoryFileName)
+}
+
+// recordRun appends a record for each attempt in report to the run history.
+func (s *Shed) recordRun(report *RunReport) error {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+
+	p := s.historyPath()
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return errors.Wrapf(err, "failed to create directory %s", filepath.Dir(p))
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open file %s", p)
+	}
+	defer f.Close()
+
+	// Attempts don't record when they finished, so use the same time for all of them
+	now := time.Now().UTC()
+	enc := json.NewEncoder(f)
+	for i, ra := range report.Attempts {
+		rec := RunRecord{
+			Time:       now,
+			ImportPath: report.Tool.ImportPath,
+			Version:    report.Tool.Version,
+			Task:       report.Task,
+			Attempt:    i + 1,
+			ExitCode:   ra.ExitCode,
+			Duration:   ra.Duration,
+			UserTime:   ra.UserTime,
+			SystemTime: ra.SystemTime,
+			MaxRSS:     ra.MaxRSS,
+		}
+		if err := enc.Encode(rec); err != nil {
+			return errors.Wrapf(err, "failed to write run history to %s", p)
+		}
+	}
+	return nil
+}
+
+// RunHistory returns the records in the run history of the project, oldest first.
+// Runs are only recorded if RunOptions.RecordStats is set. If no runs were recorded,
+// RunHistory returns a nil slice. Lines that cannot be parsed, for example because
+// the file was truncated while being written, are skipped.
+func (s *Shed) RunHistory() ([]RunRecord, error) {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+
+	p := s.historyPath()
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open file %s", p)
+	}
+	defer f.Close()
+
+	var records []RunRecord
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var rec RunRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			s.logger.WithError(err).Debugf("Skipping invalid run history entry in %s", p)
+			continue
+		}
+		records = append(records, rec)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read run history from %s", p)
+	}
+	return records, nil
+}
+
+const (
+	defaultCompareWindow    = 30 * 24 * time.Hour
+	defaultCompareThreshold = 0.2
+)
+
+// CompareOptions allows for customizing how run stats are compared.
+type CompareOptions struct {
+	// Window is how far back in the run history to look. If 0, 30 days is used.
+	Window time.Duration
+	// Threshold is the relative increase in the median wall time above which the
+	// current version is considered to have regressed, ex: 0.2 means 20% slower.
+	// If 0, 0.2 is used.
+	Threshold float64
+	// Task limits the comparison to runs for the given task. Tasks often run a tool
+	// with different arguments, so comparing runs of a single task is more accurate.
+	Task string
+}
+
+// VersionStats summarizes the successful runs of a single version of a tool.
+type VersionStats struct {
+	Version string
+	Runs    int
+	// MedianDuration and MedianCPUTime are the median wall time and CPU time.
+	MedianDuration time.Duration
+	MedianCPUTime  time.Duration
+	// MedianMaxRSS is the median maximum resident set size in bytes.
+	MedianMaxRSS int64
+}
+
+// StatsComparison is the result of comparing the run stats of a tool with those of its previous version.
+type StatsComparison struct {
+	// Tool is the tool being compared, with the version from the lockfile.
+	Tool tool.Tool
+	// Current contains the stats for the version in the lockfile and Previous contains
+	// the stats for the version that was run before it. Either is nil if there are no runs.
+	Current  *VersionStats
+	Previous *VersionStats
+	// Change is the relative change in the median wall time from Previous to Current,
+	// ex: 0.5 means 50% slower. It is 0 if either Current or Previous is nil.
+	Change float64
+	// Threshold is the threshold used to determine if the tool regressed.
+	Threshold float64
+	// Regressed reports whether Change is greater than Threshold.
+	Regressed bool
+}
+
+// CompareRunStats compares the runs of a tool in the run history at its current version in the
+// lockfile with the runs of the version that was used before it. Only successful runs within
+// the window are used. This helps determine if a version bump made a tool slower.
+func (s *Shed) CompareRunStats(toolName string, opts CompareOptions) (*StatsComparison, error) {
+	t, err := s.getTool(toolName)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Window == 0 {
+		opts.Window = defaultCompareWindow
+	}
+	if opts.Threshold == 0 {
+		opts.Threshold = defaultCompareThreshold
+	}
+	records, err := s.RunHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	since := time.Now().Add(-opts.Window)
+	byVersion := make(map[string][]RunRecord)
+	// The previous version is the most recently run version other than the current one
+	var prevVersion string
+	var prevTime time.Time
+	for _, rec := range records {
+		if rec.ImportPath != t.ImportPath || rec.ExitCode != 0 || rec.Time.Before(since) {
+			continue
+		}
+		if opts.Task != "" && rec.Task != opts.Task {
+			continue
+		}
+		byVersion[rec.Version] = append(byVersion[rec.Version], rec)
+		if rec.Version != t.Version && !rec.Time.Before(prevTime) {
+			prevVersion = rec.Version
+			prevTime = rec.Time
+		}
+	}
+
+	c := &StatsComparison{Tool: t, Threshold: opts.Threshold}
+	if recs, ok := byVersion[t.Version]; ok {
+		c.Current = summarize(t.Version, recs)
+	}
+	if recs, ok := byVersion[prevVersion]; ok && prevVersion != "" {
+		c.Previous = summarize(prevVersion, recs)
+	}
+	if c.Current != nil && c.Previous != nil && c.Previous.MedianDuration > 0 {
+		c.Change = float64(c.Current.MedianDuration-c.Previous.MedianDuration) / float64(c.Previous.MedianDuration)
+		c.Regressed = c.Change > c.Threshold
+	}
+	return c, nil
+}
+
+func summarize(version string, records []RunRecord) *VersionStats {
+	durations := make([]int64, len(records))
+	cpuTimes := make([]int64, len(records))
+	maxRSSs := make([]int64, len(records))
+	for i, rec := range records {
+		durations[i] = int64(rec.Duration)
+		cpuTimes[i] = int64(rec.UserTime + rec.SystemTime)
+		maxRSSs[i] = rec.MaxRSS
+	}
+	return &VersionStats{
+		Version:        version,
+		Runs:           len(records),
+		MedianDuration: time.Duration(median(durations)),
+		MedianCPUTime:  time.Duration(median(cpuTimes)),
+		MedianMaxRSS:   median(maxRSSs),
+	}
+}
+
+// median returns the median of vals, sorting vals in the process.
+func median(vals []int64) int64 {
+	sort.Slice(vals, func(i, j int) bool { return vals[i] < vals[j] })
+	n := len(vals)
+	if n%2 == 1 {
+		return vals[n/2]
+	}
+	return (vals[n/2-1] + vals[n/2]) / 2
+}
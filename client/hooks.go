@@ -0,0 +1,187 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Environment variables that are passed to run hooks.
+const (
+	// HookToolEnvVar contains the name of the tool being run.
+	HookToolEnvVar = "SHED_TOOL"
+	// HookImportPathEnvVar contains the import path of the tool being run.
+	HookImportPathEnvVar = "SHED_TOOL_IMPORT_PATH"
+	// HookVersionEnvVar contains the version of the tool being run.
+	HookVersionEnvVar = "SHED_TOOL_VERSION"
+	// HookArgsEnvVar contains the arguments passed to the tool as a JSON array.
+	HookArgsEnvVar = "SHED_TOOL_ARGS"
+	// HookExitCodeEnvVar contains the exit code of the tool. Only set for post-run hooks.
+	HookExitCodeEnvVar = "SHED_TOOL_EXIT_CODE"
+	// HookDurationEnvVar contains how long the tool ran for in milliseconds,
+	// including any retries. Only set for post-run hooks.
+	HookDurationEnvVar = "SHED_TOOL_DURATION_MS"
+)
+
+// Environment variables that are passed to install hooks, in addition to
+// HookToolEnvVar, HookImportPathEnvVar, and HookVersionEnvVar.
+const (
+	// HookToolPathEnvVar contains the path of the binary of the tool. Only set for post-install hooks.
+	HookToolPathEnvVar = "SHED_TOOL_PATH"
+	// HookInstallSourceEnvVar contains where the binary of the tool came from, ex: 'cache' if it
+	// was already in the cache or 'build' if it was built, see cache.Source. Only set for post-install hooks.
+	HookInstallSourceEnvVar = "SHED_INSTALL_SOURCE"
+)
+
+// hookEnv returns the environment for a hook with the details of the tool from report.
+func hookEnv(report *RunReport, opts RunOptions) ([]string, error) {
+	env := opts.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	args := report.Args
+	if args == nil {
+		args = []string{}
+	}
+	argsJSON, err := json.Marshal(args)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to serialize tool args")
+	}
+	t := report.Tool
+	// Copy so we don't modify the caller's slice
+	return append(env[:len(env):len(env)],
+		HookToolEnvVar+"="+t.Name(),
+		HookImportPathEnvVar+"="+t.ImportPath,
+		HookVersionEnvVar+"="+t.Version,
+		HookArgsEnvVar+"="+string(argsJSON),
+	), nil
+}
+
+// runHook runs the hook command in the project root.
+func (s *Shed) runHook(ctx context.Context, name string, command []string, env []string, stdout, stderr io.Writer) error {
+	root, err := filepath.Abs(s.projectRoot())
+	if err != nil {
+		return errors.Wrapf(err, "failed to get absolute path of %s", s.configPath)
+	}
+	// Relative paths are relative to the project root, not the current directory
+	bin := command[0]
+	if strings.ContainsRune(bin, '/') && !filepath.IsAbs(bin) {
+		bin = filepath.Join(root, filepath.FromSlash(bin))
+	}
+	c := exec.CommandContext(ctx, bin, command[1:]...)
+	c.Env = env
+	c.Dir = root
+	c.Stdout = stdout
+	c.Stderr = stderr
+	s.logger.WithFields(logrus.Fields{
+		"hook":    name,
+		"command": command,
+	}).Debug("Running hook")
+	if err := c.Run(); err != nil {
+		return errors.Wrapf(err, "%s hook failed", name)
+	}
+	return nil
+}
+
+// runPreRunHook runs the pre-run hook from the config file, if there is one.
+// It returns a copy of opts with the environment variables output by the hook added.
+func (s *Shed) runPreRunHook(ctx context.Context, report *RunReport, opts RunOptions) (RunOptions, error) {
+	if s.config.Hooks == nil || len(s.config.Hooks.PreRun) == 0 {
+		return opts, nil
+	}
+	env, err := hookEnv(report, opts)
+	if err != nil {
+		return opts, err
+	}
+	var stdout bytes.Buffer
+	if err := s.runHook(ctx, "preRun", s.config.Hooks.PreRun, env, &stdout, opts.Stderr); err != nil {
+		return opts, err
+	}
+
+	toolEnv := opts.Env
+	if toolEnv == nil {
+		toolEnv = os.Environ()
+	}
+	// Copy so we don't modify the caller's slice
+	toolEnv = toolEnv[:len(toolEnv):len(toolEnv)]
+	sc := bufio.NewScanner(&stdout)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if strings.IndexByte(line, '=') <= 0 {
+			return opts, errors.Errorf("preRun hook output invalid line %q, must be in the form KEY=VALUE", line)
+		}
+		toolEnv = append(toolEnv, line)
+	}
+	if err := sc.Err(); err != nil {
+		return opts, errors.Wrap(err, "failed to read preRun hook output")
+	}
+	opts.Env = toolEnv
+	return opts, nil
+}
+
+// runPostRunHook runs the post-run hook from the config file, if there is one.
+// The hook is given the results of running the tool from report.
+func (s *Shed) runPostRunHook(ctx context.Context, report *RunReport, opts RunOptions) error {
+	if s.config.Hooks == nil || len(s.config.Hooks.PostRun) == 0 {
+		return nil
+	}
+	env, err := hookEnv(report, opts)
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, ra := range report.Attempts {
+		total += ra.Duration.Milliseconds()
+	}
+	env = append(env,
+		HookExitCodeEnvVar+"="+strconv.Itoa(report.ExitCode()),
+		HookDurationEnvVar+"="+strconv.FormatInt(total, 10),
+	)
+	return s.runHook(ctx, "postRun", s.config.Hooks.PostRun, env, opts.Stdout, opts.Stderr)
+}
+
+// runInstallHook runs the install hook of t named name with the details of t and the extra
+// environment variables env. The output of the hook is only shown if it fails, since tools
+// are installed concurrently.
+func (s *Shed) runInstallHook(ctx context.Context, name string, command []string, t tool.Tool, env ...string) error {
+	env = append(append(os.Environ(),
+		HookToolEnvVar+"="+t.Name(),
+		HookImportPathEnvVar+"="+t.ImportPath,
+		HookVersionEnvVar+"="+t.Version,
+	), env...)
+	var output bytes.Buffer
+	if err := s.runHook(ctx, name, command, env, &output, &output); err != nil {
+		if out := strings.TrimSpace(output.String()); out != "" {
+			return errors.Errorf("%v, output:\n%s", err, out)
+		}
+		return err
+	}
+	return nil
+}
+
+// runPostInstallHook runs the post-install hook command for t, which was just installed from source.
+func (s *Shed) runPostInstallHook(ctx context.Context, command []string, t tool.Tool, source cache.Source) error {
+	binPath, err := s.cache.BinaryPath(t)
+	if err != nil {
+		return err
+	}
+	return s.runInstallHook(ctx, "postInstall", command, t,
+		HookToolPathEnvVar+"="+binPath,
+		HookInstallSourceEnvVar+"="+source.String(),
+	)
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getshiphub/shed/tool"
+	"github.com/sirupsen/logrus"
+)
+
+// installEnv returns the environment variables for the go command used to install t,
+// based on the install settings in the config file. Existing values in the environment
+// are extended rather than replaced, except for GOPROXY, see toolGoProxy.
+func (s *Shed) installEnv(t tool.Tool) []string {
+	install := s.config.InstallSettings(t)
+	var env []string
+	if goProxy := s.toolGoProxy(t); goProxy != "" {
+		env = append(env, "GOPROXY="+goProxy)
+	}
+	env = appendPatternsEnv(env, "GOINSECURE", install.GoInsecure)
+	env = appendPatternsEnv(env, "GOPRIVATE", install.GoPrivate)
+	env = appendPatternsEnv(env, "GONOSUMDB", install.GoNoSumDB)
+	if install.GoVCS != "" {
+		// The first matching rule is used, so put ours first to take precedence
+		govcs := install.GoVCS
+		if v := os.Getenv("GOVCS"); v != "" {
+			govcs += "," + v
+		}
+		env = append(env, "GOVCS="+govcs)
+	}
+
+	gitConfig := install.GitConfig
+	if c := install.Credentials; c != nil {
+		var forwarded []string
+		// An empty value means the credential is not available, which
+		// both ssh and git treat the same as the variable not being set
+		if c.SSHAgent {
+			forwarded = append(forwarded, "ssh agent")
+		} else {
+			env = append(env, "SSH_AUTH_SOCK=")
+		}
+		if c.AskPass {
+			forwarded = append(forwarded, "askpass")
+		} else {
+			env = append(env, "GIT_ASKPASS=", "SSH_ASKPASS=")
+		}
+		if c.CredentialHelper {
+			forwarded = append(forwarded, "git credential helper")
+		} else if _, ok := gitConfig["credential.helper"]; !ok {
+			// An empty helper clears the list of helpers
+			gitConfig = copyStringMap(gitConfig)
+			gitConfig["credential.helper"] = ""
+		}
+		if c.Netrc != "" {
+			netrc := c.Netrc
+			if !filepath.IsAbs(netrc) {
+				netrc = filepath.Join(s.projectRoot(), filepath.FromSlash(netrc))
+			}
+			if abs, err := filepath.Abs(netrc); err == nil {
+				netrc = abs
+			}
+			forwarded = append(forwarded, "netrc "+netrc)
+			env = append(env, "NETRC="+netrc)
+		} else {
+			// The go command falls back to ~/.netrc if NETRC is empty, so use a file that is always empty
+			env = append(env, "NETRC="+os.DevNull)
+		}
+		if len(forwarded) > 0 {
+			s.logger.WithFields(logrus.Fields{
+				"tool":        t,
+				"credentials": forwarded,
+			}).Infof("Forwarding credentials to install of %s: %s", t.ImportPath, strings.Join(forwarded, ", "))
+		}
+	}
+
+	if len(gitConfig) > 0 {
+		// Use the environment to set config so the user's git config isn't modified.
+		// Continue from any existing entries so they aren't overwritten.
+		n, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+		keys := make([]string, 0, len(gitConfig))
+		for k := range gitConfig {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			env = append(env,
+				fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", n, k),
+				fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", n, gitConfig[k]),
+			)
+			n++
+		}
+		env = append(env, "GIT_CONFIG_COUNT="+strconv.Itoa(n))
+	}
+	return env
+}
+
+// toolGoProxy returns the value of GOPROXY used to install t, or an empty string to use the environment.
+// The goproxy in the install settings for t takes precedence over the proxy set with WithGoProxy.
+func (s *Shed) toolGoProxy(t tool.Tool) string {
+	if p := s.config.InstallSettings(t).GoProxy; p != "" {
+		return p
+	}
+	return s.goProxy
+}
+
+// appendPatternsEnv adds the variable name to env with patterns appended to its value in the environment.
+// If there are no patterns, env is returned unchanged.
+func appendPatternsEnv(env []string, name string, patterns []string) []string {
+	if len(patterns) == 0 {
+		return env
+	}
+	if v := os.Getenv(name); v != "" {
+		patterns = append([]string{v}, patterns...)
+	}
+	return append(env, name+"="+strings.Join(patterns, ","))
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m)+1)
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,180 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/pkg/errors"
+)
+
+// InstallHistoryFileName is the name of the file in the project directory containing the install history.
+// Each line is a JSON encoded InstallRecord.
+const InstallHistoryFileName = "installs.jsonl"
+
+// InstallRecord is an entry in the install history describing a tool installed by InstallSet.Apply.
+type InstallRecord struct {
+	// Time is when the install finished. Every tool installed by the same Apply has the same time.
+	Time time.Time `json:"time"`
+	// ImportPath and Version identify the tool that was installed.
+	ImportPath string `json:"importPath"`
+	Version    string `json:"version"`
+	// Source is where the binary of the tool came from.
+	Source cache.Source `json:"source"`
+	// Duration is how long installing the tool took.
+	Duration time.Duration `json:"duration"`
+}
+
+func (s *Shed) installHistoryPath() string {
+	return filepath.Join(s.projectRoot(), ProjectDirName, InstallHistoryFileName)
+}
+
+// recordInstall appends a record for each tool in installed to the install history.
+func (s *Shed) recordInstall(installed []installedTool) error {
+	if len(installed) == 0 {
+		return nil
+	}
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+
+	p := s.installHistoryPath()
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return errors.Wrapf(err, "failed to create directory %s", filepath.Dir(p))
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open file %s", p)
+	}
+	defer f.Close()
+
+	now := time.Now().UTC()
+	enc := json.NewEncoder(f)
+	for _, it := range installed {
+		rec := InstallRecord{
+			Time:       now,
+			ImportPath: it.tool.ImportPath,
+			Version:    it.tool.Version,
+			Source:     it.source,
+			Duration:   it.duration,
+		}
+		if err := enc.Encode(rec); err != nil {
+			return errors.Wrapf(err, "failed to write install history to %s", p)
+		}
+	}
+	return nil
+}
+
+// InstallHistory returns the records in the install history of the project, oldest first.
+// Installs are only recorded if RecordStats is used. If no installs were recorded,
+// InstallHistory returns a nil slice. Lines that cannot be parsed are skipped, like with RunHistory.
+func (s *Shed) InstallHistory() ([]InstallRecord, error) {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+
+	p := s.installHistoryPath()
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open file %s", p)
+	}
+	defer f.Close()
+
+	var records []InstallRecord
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var rec InstallRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			s.logger.WithError(err).Debugf("Skipping invalid install history entry in %s", p)
+			continue
+		}
+		records = append(records, rec)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read install history from %s", p)
+	}
+	return records, nil
+}
+
+// SourceStats summarizes the tools installed from a single source.
+type SourceStats struct {
+	Source cache.Source
+	// Tools is the number of tools installed from Source.
+	Tools int
+	// Rate is the fraction of all installed tools that were installed from Source.
+	Rate float64
+	// MedianDuration is the median time it took to install a tool from Source.
+	MedianDuration time.Duration
+	// TotalDuration is the total time spent installing tools from Source.
+	TotalDuration time.Duration
+}
+
+// CacheStats summarizes how often the tools installed in a window came from the cache.
+type CacheStats struct {
+	// Installs is the number of times tools were installed, ex: by 'shed install'.
+	Installs int
+	// Tools is the number of tools installed across all installs.
+	Tools int
+	// Sources has the stats for each source tools were installed from, in the order of cache.Source.
+	// Sources that no tools came from are omitted.
+	Sources []SourceStats
+	// HitRate is the fraction of tools whose binary was reused from the cache or pulled from the remote cache.
+	HitRate float64
+	// RemoteHitRate is the fraction of tools that weren't in the cache and were pulled from the remote cache,
+	// instead of being built. It is 0 if every tool was in the cache.
+	RemoteHitRate float64
+}
+
+// CacheStats aggregates the install history of the project within window, or the whole history if window is 0.
+// This helps determine if caching is set up correctly, ex: in CI, where most tools should come from the cache
+// or the remote cache. Release tools are downloaded instead of built, so they count as misses unless they were
+// already in the cache.
+func (s *Shed) CacheStats(window time.Duration) (*CacheStats, error) {
+	records, err := s.InstallHistory()
+	if err != nil {
+		return nil, err
+	}
+	var since time.Time
+	if window > 0 {
+		since = time.Now().Add(-window)
+	}
+	stats := &CacheStats{}
+	durations := make(map[cache.Source][]int64)
+	var lastTime time.Time
+	for _, rec := range records {
+		if rec.Time.Before(since) {
+			continue
+		}
+		if !rec.Time.Equal(lastTime) {
+			stats.Installs++
+			lastTime = rec.Time
+		}
+		stats.Tools++
+		durations[rec.Source] = append(durations[rec.Source], int64(rec.Duration))
+	}
+	if stats.Tools == 0 {
+		return stats, nil
+	}
+	for _, src := range []cache.Source{cache.SourceCache, cache.SourceRemote, cache.SourceBuild, cache.SourceRelease, cache.SourcePrebuilt} {
+		ds, ok := durations[src]
+		if !ok {
+			continue
+		}
+		ss := SourceStats{Source: src, Tools: len(ds), Rate: float64(len(ds)) / float64(stats.Tools)}
+		for _, d := range ds {
+			ss.TotalDuration += time.Duration(d)
+		}
+		ss.MedianDuration = time.Duration(median(ds))
+		stats.Sources = append(stats.Sources, ss)
+	}
+	cached, remote := len(durations[cache.SourceCache]), len(durations[cache.SourceRemote])
+	stats.HitRate = float64(cached+remote) / float64(stats.Tools)
+	if misses := stats.Tools - cached; misses > 0 {
+		stats.RemoteHitRate = float64(remote) / float64(misses)
+	}
+	return stats, nil
+}
@@ -0,0 +1,90 @@
+package client_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/remote"
+)
+
+func TestCacheStats(t *testing.T) {
+	td := t.TempDir()
+	backend := remote.NewDirBackend(t.TempDir())
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	install := func(c *cache.Cache, want cache.Source) {
+		t.Helper()
+		s, err := client.NewShed(
+			client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+			client.WithCache(c),
+		)
+		if err != nil {
+			t.Fatalf("failed to create shed client %v", err)
+		}
+		installSet, err := s.Install("github.com/cszatmary/go-fish@v0.1.0")
+		if err != nil {
+			t.Fatalf("want nil error, got %v", err)
+		}
+		var got cache.Source
+		progress := client.WithProgress(func(ev client.Event) {
+			if ev.Kind == client.EventDone {
+				got = ev.Source
+			}
+		})
+		if err := installSet.Apply(context.Background(), client.RecordStats(), progress); err != nil {
+			t.Fatalf("want nil error, got %v", err)
+		}
+		if got != want {
+			t.Errorf("got source %s, want %s", got, want)
+		}
+	}
+
+	c := cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo), cache.WithRemote(backend, false))
+	install(c, cache.SourceBuild)
+	install(c, cache.SourceCache)
+	// A new cache, ex: in CI, pulls the tool from the remote cache
+	install(cache.New(filepath.Join(td, "ci-cache"), cache.WithGo(offlineGo{mockGo}), cache.WithRemote(backend, true)), cache.SourceRemote)
+
+	s, err := client.NewShed(client.WithLockfilePath(filepath.Join(td, "shed.lock")), client.WithNoCache())
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	records, err := s.InstallHistory()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d install records, want 3", len(records))
+	}
+	if rec := records[0]; rec.ImportPath != "github.com/cszatmary/go-fish" || rec.Version != "v0.1.0" || rec.Source != cache.SourceBuild {
+		t.Errorf("got record %+v, want go-fish built from source", rec)
+	}
+
+	stats, err := s.CacheStats(0)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if stats.Installs != 3 || stats.Tools != 3 {
+		t.Errorf("got %d installs of %d tools, want 3 of 3", stats.Installs, stats.Tools)
+	}
+	wantSources := []cache.Source{cache.SourceCache, cache.SourceRemote, cache.SourceBuild}
+	if len(stats.Sources) != len(wantSources) {
+		t.Fatalf("got sources %+v, want %v", stats.Sources, wantSources)
+	}
+	for i, ss := range stats.Sources {
+		if ss.Source != wantSources[i] || ss.Tools != 1 {
+			t.Errorf("got source stats %+v, want 1 tool from %s", ss, wantSources[i])
+		}
+	}
+	if stats.HitRate != 2.0/3 {
+		t.Errorf("got hit rate %f, want %f", stats.HitRate, 2.0/3)
+	}
+	if stats.RemoteHitRate != 0.5 {
+		t.Errorf("got remote hit rate %f, want 0.5", stats.RemoteHitRate)
+	}
+}
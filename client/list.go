@@ -0,0 +1,126 @@
+package client
+
+import (
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/internal/util"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// ToolInfo describes a tool in the lockfile and its state in the cache.
+type ToolInfo struct {
+	// Tool is the tool as specified in the lockfile.
+	Tool tool.Tool
+	// Path is the absolute path to the binary of the tool. It is set even if the tool
+	// is not installed, since it is where the binary will be once it is.
+	Path string
+	// Installed reports whether the binary of the tool exists.
+	Installed bool
+	// Stale reports whether the installed files of the tool don't match their hashes,
+	// see Verify. Stale tools should be reinstalled.
+	Stale bool
+	// Sum is the hash of the module that provides the tool, in the format used by go.sum.
+	// It is the hash in the lockfile, or the hash from the cache if the lockfile doesn't have one.
+	// It is empty if it is unknown.
+	Sum string
+	// Provenance describes how the installed binary was built, see cache.Cache.Provenance.
+	// It is nil if the tool isn't installed or no provenance was recorded.
+	Provenance *cache.Provenance
+	// ReportedVersion is the version the tool reported when it was installed, see config.VersionProbe.
+	// It is nil if the tool has no version probe or wasn't probed at its version in the lockfile.
+	ReportedVersion *ReportedVersion
+}
+
+// ListInfo is like List but also returns the state of each tool in the cache.
+// The tools are sorted by import path. Options can be provided to get the state of
+// the tools installed for another platform, see ForPlatform.
+//
+// An info is returned for every tool in the lockfile. If the state of some tools couldn't be
+// determined, the error is a lockfile.ErrorList with the failures.
+func (s *Shed) ListInfo(opts ...PathOption) ([]ToolInfo, error) {
+	var o pathOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if s.cache == nil {
+		return nil, ErrNoCache
+	}
+
+	var infos []ToolInfo
+	var errs lockfile.ErrorList
+	for _, lt := range s.List() {
+		info, err := s.toolInfo(lt, o)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		infos = append(infos, info)
+	}
+	if len(errs) > 0 {
+		return infos, errs
+	}
+	return infos, nil
+}
+
+// ToolInfo returns the state of a single tool in the cache, like ListInfo. toolName is looked up
+// the same way as with ToolPath. If the state of the tool couldn't be fully determined, the info
+// is returned along with the error.
+func (s *Shed) ToolInfo(toolName string, opts ...PathOption) (ToolInfo, error) {
+	var o pathOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if s.cache == nil {
+		return ToolInfo{}, ErrNoCache
+	}
+	lt, err := s.getTool(toolName)
+	if err != nil {
+		return ToolInfo{}, err
+	}
+	return s.toolInfo(lt, o)
+}
+
+// toolInfo returns the state of the tool lt from the lockfile in the cache.
+func (s *Shed) toolInfo(lt tool.Tool, o pathOptions) (ToolInfo, error) {
+	info := ToolInfo{Tool: lt, Sum: lt.Sum}
+	t := lt
+	t.Platform = o.platform
+	binPath, err := s.cache.BinaryPath(t)
+	if err != nil {
+		return info, errors.WithMessagef(err, "failed to get info of tool %s", t)
+	}
+	info.Path = binPath
+	info.Installed = util.FileOrDirExists(binPath)
+	if !info.Installed {
+		return info, nil
+	}
+	var errs lockfile.ErrorList
+	info.ReportedVersion, err = s.reportedVersion(lt)
+	if err != nil {
+		errs = append(errs, errors.WithMessagef(err, "failed to get reported version of tool %s", t))
+	}
+	err = s.cache.Verify(t)
+	var cerr *cache.ChecksumError
+	if errors.As(err, &cerr) {
+		info.Stale = true
+	} else if err != nil {
+		errs = append(errs, errors.WithMessagef(err, "failed to verify tool %s", t))
+	}
+	if info.Sum == "" {
+		// Tools installed before hashes were recorded don't have one, so it's not an error
+		info.Sum, _ = s.cache.ModuleSum(t)
+	}
+	if !info.Stale {
+		info.Provenance, err = s.cache.Provenance(t)
+		if err != nil {
+			errs = append(errs, errors.WithMessagef(err, "failed to get provenance of tool %s", t))
+		}
+	}
+	switch len(errs) {
+	case 0:
+		return info, nil
+	case 1:
+		return info, errs[0]
+	}
+	return info, errs
+}
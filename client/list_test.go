@@ -0,0 +1,134 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+	"golang.org/x/mod/module"
+)
+
+func TestListInfo(t *testing.T) {
+	s := newLockfileShed(t, t.TempDir(), availableTools, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
+	})
+	// Only install one tool so the other is missing
+	installSet, err := s.Install("go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	infos, err := s.ListInfo()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("got %d infos, want 2", len(infos))
+	}
+	fish, lint := infos[0], infos[1]
+	wantSum := cache.MockSum(module.Version{Path: "github.com/cszatmary/go-fish", Version: "v0.1.0"})
+	if !fish.Installed || fish.Stale || fish.Sum != wantSum {
+		t.Errorf("got info %+v, want go-fish to be installed with sum %s", fish, wantSum)
+	}
+	if filepath.Base(fish.Path) != "go-fish" {
+		t.Errorf("got path %s, want path to go-fish binary", fish.Path)
+	}
+	if lint.Installed || lint.Stale || lint.Sum != "" || lint.Path == "" {
+		t.Errorf("got info %+v, want golangci-lint to not be installed but have a path", lint)
+	}
+
+	// Modify the binary so it is stale
+	if err := ioutil.WriteFile(fish.Path, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("failed to write %s: %v", fish.Path, err)
+	}
+	infos, err = s.ListInfo()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if !infos[0].Installed || !infos[0].Stale {
+		t.Errorf("got info %+v, want go-fish to be stale", infos[0])
+	}
+}
+
+func TestToolInfoProvenance(t *testing.T) {
+	td := t.TempDir()
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	c := cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo), cache.WithModuleCache(true))
+	s, err := client.NewShed(client.WithLockfilePath(filepath.Join(td, "shed.lock")), client.WithCache(c))
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	// The go command records where a module came from when the module proxy provides it
+	infoDir := filepath.Join(c.ModuleCacheDir(), "cache", "download", "github.com", "cszatmary", "go-fish", "@v")
+	if err := os.MkdirAll(infoDir, 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	info := `{"Version":"v0.1.0","Origin":{"VCS":"git","URL":"https://github.com/cszatmary/go-fish","Hash":"0123456789abcdef0123456789abcdef01234567"}}`
+	if err := ioutil.WriteFile(filepath.Join(infoDir, "v0.1.0.info"), []byte(info), 0o644); err != nil {
+		t.Fatalf("failed to write info: %v", err)
+	}
+	installSet, err := s.InstallSpecs([]client.ToolSpec{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58", BuildFlags: tool.BuildFlags{Trimpath: true}},
+	})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	before := time.Now().UTC().Add(-time.Second)
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		wantRevision string
+		wantFlags    []string
+	}{
+		{"go-fish", "0123456789abcdef0123456789abcdef01234567", nil},
+		// The revision of a pseudo-version is used if the origin isn't known
+		{"stringer", "d93e913c1a58", []string{"-trimpath"}},
+	}
+	for _, tt := range tests {
+		info, err := s.ToolInfo(tt.name)
+		if err != nil {
+			t.Fatalf("want nil error, got %v", err)
+		}
+		p := info.Provenance
+		if p == nil {
+			t.Fatalf("want provenance of %s, got nil", tt.name)
+		}
+		if p.GoVersion != runtime.Version() || p.GOOS != runtime.GOOS || p.GOARCH != runtime.GOARCH {
+			t.Errorf("got provenance %+v, want go version and platform of the mock", p)
+		}
+		if p.Revision != tt.wantRevision {
+			t.Errorf("got revision %q, want %q", p.Revision, tt.wantRevision)
+		}
+		if !reflect.DeepEqual(p.BuildFlags, tt.wantFlags) {
+			t.Errorf("got build flags %v, want %v", p.BuildFlags, tt.wantFlags)
+		}
+		if p.Time.Before(before) {
+			t.Errorf("got time %s, want after %s", p.Time, before)
+		}
+	}
+
+	if _, err := s.ToolInfo("golangci-lint"); !errors.Is(err, lockfile.ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, lockfile.ErrNotFound)
+	}
+}
@@ -0,0 +1,22 @@
+package client
+
+import (
+	"context"
+)
+
+// MigrateLockfile rewrites the lockfile using the current format version, see lockfile.FormatVersion.
+// It returns the format version the lockfile had. Any change to the lockfile also migrates it,
+// MigrateLockfile only needs to be used to migrate it without changing any tools.
+//
+// If the lockfile was written by a newer version of shed, the error matches lockfile.ErrUnsupportedFormat.
+func (s *Shed) MigrateLockfile(ctx context.Context) (int, error) {
+	var version int
+	err := s.updateLockfile(ctx, func() error {
+		version = s.lf.FileVersion()
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
@@ -0,0 +1,48 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/lockfile"
+)
+
+func TestMigrateLockfile(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	data := `{"tools": {"github.com/cszatmary/go-fish": {"version": "v0.1.0"}}}`
+	if err := ioutil.WriteFile(lockfilePath, []byte(data), 0o644); err != nil {
+		t.Fatalf("failed to write lockfile: %v", err)
+	}
+	s, err := client.NewShed(client.WithLockfilePath(lockfilePath), client.WithNoCache())
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	version, err := s.MigrateLockfile(context.Background())
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if version != 1 {
+		t.Errorf("got version %d, want 1", version)
+	}
+	lf := readLockfile(t, lockfilePath)
+	if v := lf.FileVersion(); v != lockfile.FormatVersion {
+		t.Errorf("got version %d, want %d", v, lockfile.FormatVersion)
+	}
+	if _, err := lf.GetTool("go-fish"); err != nil {
+		t.Errorf("want nil error, got %v", err)
+	}
+
+	// A newer shed wrote the lockfile
+	data = `{"lockfileVersion": 100, "tools": {}}`
+	if err := ioutil.WriteFile(lockfilePath, []byte(data), 0o644); err != nil {
+		t.Fatalf("failed to write lockfile: %v", err)
+	}
+	if _, err := client.NewShed(client.WithLockfilePath(lockfilePath), client.WithNoCache()); !errors.Is(err, lockfile.ErrUnsupportedFormat) {
+		t.Errorf("got error %v, want %v", err, lockfile.ErrUnsupportedFormat)
+	}
+}
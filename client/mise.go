@@ -0,0 +1,133 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/semver"
+)
+
+// miseGoPrefix is the prefix of tools in a mise config that are installed with the go backend.
+const miseGoPrefix = "go:"
+
+func (s *Shed) exportMise(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("# Code generated by 'shed export --format=mise'. DO NOT EDIT.\n\n")
+	sb.WriteString("[tools]\n")
+	for _, t := range s.List() {
+		fmt.Fprintf(&sb, "%s = %s\n", strconv.Quote(miseGoPrefix+t.ImportPath), strconv.Quote(t.Version))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// ReadMiseTools reads a mise config file, ex: mise.toml, from r and returns the Go tools in it
+// as specs that can be passed to InstallSpecs. Go tools are the ones in the [tools] table that
+// use the go backend, that is the key starts with 'go:'. All other tools are ignored, since
+// shed can't install them.
+//
+// Only the subset of TOML used by the [tools] table is supported. The version of a tool can
+// be a string, an array of versions, in which case the first one is used, or an inline table
+// with a version key. The version 'latest' installs the latest version.
+func ReadMiseTools(r io.Reader) ([]ToolSpec, error) {
+	var specs []ToolSpec
+	var errs lockfile.ErrorList
+	inTools := false
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			inTools = line == "[tools]"
+			continue
+		}
+		if !inTools {
+			continue
+		}
+		key, value, err := parseTOMLKeyValue(line)
+		if err != nil {
+			errs = append(errs, errors.WithMessagef(err, "line %d", n))
+			continue
+		}
+		if !strings.HasPrefix(key, miseGoPrefix) {
+			continue
+		}
+		version, err := miseVersion(value)
+		if err != nil {
+			errs = append(errs, errors.WithMessagef(err, "line %d: invalid version of %s", n, key))
+			continue
+		}
+		specs = append(specs, ToolSpec{ImportPath: strings.TrimPrefix(key, miseGoPrefix), Version: version})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read mise config")
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return specs, nil
+}
+
+// parseTOMLKeyValue splits a TOML key/value pair into the unquoted key and the raw value.
+func parseTOMLKeyValue(line string) (string, string, error) {
+	var key string
+	rest := line
+	if strings.HasPrefix(line, `"`) || strings.HasPrefix(line, "'") {
+		end := strings.IndexByte(line[1:], line[0])
+		if end == -1 {
+			return "", "", errors.Errorf("unterminated key in %q", line)
+		}
+		key = line[1 : end+1]
+		rest = line[end+2:]
+	} else if i := strings.IndexByte(line, '='); i != -1 {
+		key = strings.TrimSpace(line[:i])
+		rest = line[i:]
+	}
+	rest = strings.TrimSpace(rest)
+	if key == "" || !strings.HasPrefix(rest, "=") {
+		return "", "", errors.Errorf("expected key = value, got %q", line)
+	}
+	return key, strings.TrimSpace(rest[1:]), nil
+}
+
+// miseVersion returns the version of a tool from the raw TOML value in a mise config.
+func miseVersion(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "["):
+		// Multiple versions, use the first one which is the default
+		value = strings.TrimSpace(strings.TrimPrefix(value, "["))
+	case strings.HasPrefix(value, "{"):
+		i := strings.Index(value, "version")
+		if i == -1 {
+			return "", errors.Errorf("no version in %s", value)
+		}
+		value = strings.TrimSpace(value[i+len("version"):])
+		if !strings.HasPrefix(value, "=") {
+			return "", errors.Errorf("no version in %s", value)
+		}
+		value = strings.TrimSpace(value[1:])
+	}
+	if value == "" || (value[0] != '"' && value[0] != '\'') {
+		return "", errors.Errorf("expected a string, got %s", value)
+	}
+	end := strings.IndexByte(value[1:], value[0])
+	if end == -1 {
+		return "", errors.Errorf("unterminated string %s", value)
+	}
+	version := value[1 : end+1]
+	if version == "latest" {
+		return "", nil
+	}
+	// mise allows the v prefix to be omitted, but the go command requires it
+	if !strings.HasPrefix(version, "v") && semver.IsValid("v"+version) {
+		version = "v" + version
+	}
+	return version, nil
+}
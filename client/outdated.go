@@ -0,0 +1,131 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// UpdateKind classifies the difference between the version of a tool and its latest version.
+type UpdateKind int
+
+const (
+	// UpdateNone means the tool is already at the latest version, or newer.
+	UpdateNone UpdateKind = iota
+	// UpdatePatch means the latest version only changes the patch version.
+	UpdatePatch
+	// UpdateMinor means the latest version changes the minor version.
+	UpdateMinor
+	// UpdateMajor means the latest version changes the major version.
+	UpdateMajor
+)
+
+func (k UpdateKind) String() string {
+	switch k {
+	case UpdateNone:
+		return "none"
+	case UpdatePatch:
+		return "patch"
+	case UpdateMinor:
+		return "minor"
+	case UpdateMajor:
+		return "major"
+	}
+	return fmt.Sprintf("UpdateKind(%d)", int(k))
+}
+
+// classifyUpdate returns the kind of update from version current to latest.
+func classifyUpdate(current, latest string) UpdateKind {
+	switch {
+	case semver.Compare(latest, current) <= 0:
+		return UpdateNone
+	case semver.Major(latest) != semver.Major(current):
+		return UpdateMajor
+	case semver.MajorMinor(latest) != semver.MajorMinor(current):
+		return UpdateMinor
+	}
+	return UpdatePatch
+}
+
+// OutdatedTool compares a tool in the lockfile with the latest version of it.
+type OutdatedTool struct {
+	// Tool is the tool as specified in the lockfile.
+	Tool tool.Tool
+	// Latest is the latest version of the module that provides the tool.
+	Latest string
+	Update UpdateKind
+}
+
+// Outdated finds the latest version of each tool in the lockfile, without changing anything.
+// The latest version is resolved by the go command, so it respects GOPROXY and the other
+// module settings, as well as the install settings in the config file. If a resolver was set
+// with WithResolver, it is used instead, so only versions that can be installed are reported.
+//
+// Only the module of each tool is queried, so new major versions, which have a different
+// module path, are not found. Tools that don't have a module in the lockfile use the module
+// from the cache, so they must be installed. Release tools are skipped, since their versions can't be listed.
+// Tools that track a branch, see tool.Tool.Branch, are compared with the latest commit of the branch instead.
+//
+// A report is returned for every tool that was queried successfully, even if it is up to date.
+// If some tools couldn't be queried, the error is a lockfile.ErrorList with the failures.
+func (s *Shed) Outdated(ctx context.Context) ([]OutdatedTool, error) {
+	if s.cache == nil {
+		return nil, ErrNoCache
+	}
+
+	var reports []OutdatedTool
+	var errs lockfile.ErrorList
+	for _, t := range s.List() {
+		if t.IsRelease() {
+			s.logger.Debugf("Skipping release tool %s since its versions can't be listed", t)
+			continue
+		}
+		latest, err := s.latestVersion(ctx, t)
+		if err != nil {
+			errs = append(errs, errors.WithMessagef(err, "failed to check tool %s", t))
+			continue
+		}
+		reports = append(reports, OutdatedTool{
+			Tool:   t,
+			Latest: latest,
+			Update: classifyUpdate(t.Version, latest),
+		})
+	}
+	if len(errs) > 0 {
+		return reports, errs
+	}
+	return reports, nil
+}
+
+// latestVersion returns the latest version of the module that provides t.
+// If t tracks a branch, it is the version of the latest commit of the branch.
+func (s *Shed) latestVersion(ctx context.Context, t tool.Tool) (string, error) {
+	if s.resolver == nil {
+		if t.Branch != "" {
+			return s.cache.QueryVersion(ctx, t, t.Branch, cache.InstallEnv(s.installEnv(t)...))
+		}
+		return s.cache.LatestVersion(ctx, t, cache.InstallEnv(s.installEnv(t)...))
+	}
+	// Query the module, if it is known, so that the major version doesn't change
+	p := t.ModulePath
+	if p == "" {
+		p = t.ImportPath
+	}
+	var mv module.Version
+	var err error
+	if t.Branch != "" {
+		mv, err = s.resolver.ResolveConstraint(ctx, p, t.Branch)
+	} else {
+		mv, err = s.resolver.ResolveLatest(ctx, p)
+	}
+	if err != nil {
+		return "", err
+	}
+	return mv.Version, nil
+}
@@ -0,0 +1,366 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/github"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/releasenotes"
+	"github.com/getshiphub/shed/tool"
+	"github.com/getshiphub/shed/vulndb"
+)
+
+var outdatedTools = map[string]map[string]string{
+	"example.org/a/patch/cmd/patch": {"v1.0.0": "v1.0.0", "v1.0.3": "v1.0.3"},
+	"example.org/a/minor/cmd/minor": {"v1.0.0": "v1.0.0", "v1.2.0": "v1.2.0"},
+	"example.org/a/major/cmd/major": {"v0.9.0": "v0.9.0", "v1.0.0": "v1.0.0"},
+	"example.org/a/latest":          {"v1.5.0": "v1.5.0"},
+	"example.org/a/pseudo/cmd/p":    {"v0.0.0-20201203230243-22d10c9b658d": "v0.0.0-20201203230243-22d10c9b658d", "v0.1.0": "v0.1.0"},
+}
+
+func TestOutdated(t *testing.T) {
+	tools := []tool.Tool{
+		{ImportPath: "example.org/a/latest", Version: "v1.5.0", ModulePath: "example.org/a/latest"},
+		{ImportPath: "example.org/a/major/cmd/major", Version: "v0.9.0", ModulePath: "example.org/a/major"},
+		{ImportPath: "example.org/a/minor/cmd/minor", Version: "v1.0.0", ModulePath: "example.org/a/minor"},
+		{ImportPath: "example.org/a/patch/cmd/patch", Version: "v1.0.0", ModulePath: "example.org/a/patch"},
+		{ImportPath: "example.org/a/pseudo/cmd/p", Version: "v0.0.0-20201203230243-22d10c9b658d", ModulePath: "example.org/a/pseudo"},
+	}
+	s := newLockfileShed(t, t.TempDir(), outdatedTools, tools)
+	reports, err := s.Outdated(context.Background())
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := []client.OutdatedTool{
+		{Tool: tools[0], Latest: "v1.5.0", Update: client.UpdateNone},
+		{Tool: tools[1], Latest: "v1.0.0", Update: client.UpdateMajor},
+		{Tool: tools[2], Latest: "v1.2.0", Update: client.UpdateMinor},
+		{Tool: tools[3], Latest: "v1.0.3", Update: client.UpdatePatch},
+		{Tool: tools[4], Latest: "v0.1.0", Update: client.UpdateMinor},
+	}
+	if len(reports) != len(want) {
+		t.Fatalf("got %d reports, want %d: %+v", len(reports), len(want), reports)
+	}
+	for i, r := range reports {
+		if r != want[i] {
+			t.Errorf("got %+v, want %+v", r, want[i])
+		}
+	}
+}
+
+func TestOutdatedModuleFromCache(t *testing.T) {
+	td := t.TempDir()
+	tools := []tool.Tool{
+		{ImportPath: "example.org/a/minor/cmd/minor", Version: "v1.0.0"},
+		{ImportPath: "example.org/a/patch/cmd/patch", Version: "v1.0.0"},
+	}
+	// Only install one of the tools so the other one has an unknown module
+	s := newLockfileShed(t, td, outdatedTools, tools[1:])
+	installSet, err := s.Install()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	// Recreate the lockfile without modules, like one written by an older version of shed
+	s = newLockfileShed(t, td, outdatedTools, tools)
+	reports, err := s.Outdated(context.Background())
+	var errs lockfile.ErrorList
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("got error %v, want 1 error for the tool that isn't installed", err)
+	}
+	want := client.OutdatedTool{Tool: tools[1], Latest: "v1.0.3", Update: client.UpdatePatch}
+	if len(reports) != 1 || reports[0] != want {
+		t.Errorf("got %+v, want [%+v]", reports, want)
+	}
+}
+
+func TestOutdatedNoCache(t *testing.T) {
+	s, err := client.NewShed(client.WithLockfilePath(filepath.Join(t.TempDir(), "shed.lock")), client.WithNoCache())
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	if _, err := s.Outdated(context.Background()); !errors.Is(err, client.ErrNoCache) {
+		t.Errorf("got error %v, want %v", err, client.ErrNoCache)
+	}
+}
+
+func TestUpdateDetails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/golangci/golangci-lint/releases/tags/v1.33.0":
+			w.Write([]byte(`{"html_url": "https://github.com/golangci/golangci-lint/releases/tag/v1.33.0", "body": "## Changes\n\n* Fix crash"}`)) //nolint:errcheck
+		case "/index/modules.json":
+			w.Write([]byte(`[{"path": "github.com/golangci/golangci-lint", "vulns": [{"id": "GO-2022-0001"}]}]`)) //nolint:errcheck
+		case "/ID/GO-2022-0001.json":
+			w.Write([]byte(`{"id": "GO-2022-0001", "summary": "Crash", "affected": [{"package": {"name": "github.com/golangci/golangci-lint"}, "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.30.0"}]}]}]}`)) //nolint:errcheck
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(t.TempDir(), "shed.lock")),
+		client.WithNoCache(),
+		client.WithVulnDB(vulndb.New(srv.Client(), srv.URL)),
+		client.WithReleaseNotes(releasenotes.NewGitHub(github.New(srv.Client(), srv.URL, ""))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	ot := client.OutdatedTool{
+		Tool:   tool.Tool{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.28.3", ModulePath: "github.com/golangci/golangci-lint"},
+		Latest: "v1.33.0",
+		Update: client.UpdateMinor,
+	}
+	details, err := s.UpdateDetails(context.Background(), ot)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := client.UpdateDetails{
+		ReleaseURL:   "https://github.com/golangci/golangci-lint/releases/tag/v1.33.0",
+		ReleaseNotes: "* Fix crash",
+		Fixes:        []vulndb.Entry{{ID: "GO-2022-0001", Summary: "Crash"}},
+	}
+	if !reflect.DeepEqual(details, want) {
+		t.Errorf("got details %+v, want %+v", details, want)
+	}
+
+	// Modules that aren't on GitHub don't have release notes, which isn't an error
+	ot = client.OutdatedTool{
+		Tool:   tool.Tool{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.1.0", ModulePath: "golang.org/x/tools"},
+		Latest: "v0.1.1",
+		Update: client.UpdatePatch,
+	}
+	details, err = s.UpdateDetails(context.Background(), ot)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if !reflect.DeepEqual(details, client.UpdateDetails{}) {
+		t.Errorf("got details %+v, want none", details)
+	}
+}
+
+func TestSecurityUpdates(t *testing.T) {
+	files := map[string]string{
+		"/index/modules.json": `[
+			{"path": "github.com/golangci/golangci-lint", "vulns": [{"id": "GO-2022-0001"}, {"id": "GO-2022-0002"}]},
+			{"path": "github.com/Shopify/ejson", "vulns": [{"id": "GHSA-0001"}]},
+			{"path": "golang.org/x/tools", "vulns": [{"id": "GO-2022-0003"}]}
+		]`,
+		"/ID/GO-2022-0001.json": `{"id": "GO-2022-0001", "summary": "Crash", "affected": [{"package": {"name": "github.com/golangci/golangci-lint"}, "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.30.0"}]}]}]}`,
+		"/ID/GO-2022-0002.json": `{"id": "GO-2022-0002", "summary": "Leak", "affected": [{"package": {"name": "github.com/golangci/golangci-lint"}, "ranges": [{"type": "SEMVER", "events": [{"introduced": "1.29.0"}, {"fixed": "1.31.0"}]}]}]}`,
+		"/ID/GHSA-0001.json":    `{"id": "GHSA-0001", "summary": "Minor", "database_specific": {"severity": "LOW"}, "affected": [{"package": {"name": "github.com/Shopify/ejson"}, "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}]}]}]}`,
+		"/ID/GO-2022-0003.json": `{"id": "GO-2022-0003", "summary": "Unfixed", "affected": [{"package": {"name": "golang.org/x/tools"}, "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}]}]}]}`,
+		// Proxy
+		"/github.com/golangci/golangci-lint/@v/list": "v1.28.3\nv1.29.0\nv1.30.0\nv1.31.0\nv1.32.0-rc.1\nv1.33.0\n",
+		"/github.com/!shopify/ejson/@v/list":         "v1.1.0\nv1.2.2\n",
+		"/golang.org/x/tools/@v/list":                "v0.1.0\nv0.1.1\n",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(data)) //nolint:errcheck
+	}))
+	defer srv.Close()
+	td := t.TempDir()
+	tools := []tool.Tool{
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.1.0", ModulePath: "github.com/Shopify/ejson"},
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0", ModulePath: "github.com/cszatmary/go-fish"},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.28.3", ModulePath: "github.com/golangci/golangci-lint"},
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.1.0", ModulePath: "golang.org/x/tools"},
+	}
+	createLockfile(t, filepath.Join(td, "shed.lock"), tools)
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithNoCache(),
+		client.WithVulnDB(vulndb.New(srv.Client(), srv.URL)),
+		client.WithGoProxy(srv.URL),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	updates, err := s.SecurityUpdates(context.Background(), vulndb.SeverityHigh)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	// The smallest version without either vulnerability is used, and low severity vulnerabilities are ignored
+	want := []client.SecurityUpdate{
+		{Tool: tools[2], Version: "v1.31.0", Fixes: []vulndb.Entry{{ID: "GO-2022-0001", Summary: "Crash"}}},
+		{Tool: tools[3], Fixes: []vulndb.Entry{{ID: "GO-2022-0003", Summary: "Unfixed"}}},
+	}
+	if !reflect.DeepEqual(updates, want) {
+		t.Errorf("got updates %+v, want %+v", updates, want)
+	}
+
+	updates, err = s.SecurityUpdates(context.Background(), vulndb.SeverityLow)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if len(updates) != 3 || updates[0].Tool != tools[0] || updates[0].Version != "" {
+		t.Errorf("got updates %+v, want ejson to have no fix", updates)
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	td := t.TempDir()
+	tools := []tool.Tool{
+		{ImportPath: "example.org/a/latest", Version: "v1.5.0", ModulePath: "example.org/a/latest"},
+		{ImportPath: "example.org/a/major/cmd/major", Version: "v0.9.0", ModulePath: "example.org/a/major"},
+		{ImportPath: "example.org/a/minor/cmd/minor", Version: "v1.0.0", ModulePath: "example.org/a/minor", Alias: "minor2"},
+		{ImportPath: "example.org/a/patch/cmd/patch", Version: "v1.0.0", ModulePath: "example.org/a/patch"},
+	}
+	s := newLockfileShed(t, td, outdatedTools, tools)
+
+	installSet, err := s.Upgrade(context.Background(), client.UpgradeOptions{Tools: []string{"minor2", "example.org/a/patch/cmd/patch", "latest"}})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if installSet.Len() != 2 {
+		t.Errorf("got %d tools to upgrade, want 2", installSet.Len())
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	lf := readLockfile(t, filepath.Join(td, "shed.lock"))
+	for name, want := range map[string]string{"minor2": "v1.2.0", "patch": "v1.0.3", "major": "v0.9.0"} {
+		if got, err := lf.GetTool(name); err != nil || got.Version != want {
+			t.Errorf("got %s with error %v, want version %s", got, err, want)
+		}
+	}
+
+	_, err = s.Upgrade(context.Background(), client.UpgradeOptions{Tools: []string{"missing"}})
+	var errs lockfile.ErrorList
+	if !errors.As(err, &errs) || len(errs) != 1 || !errors.Is(errs[0], lockfile.ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, lockfile.ErrNotFound)
+	}
+}
+
+func TestUpgradesMaxUpdate(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/example.org/a/@v/list" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("v0.9.0\nv0.9.4\nv0.10.1\nv1.0.0\nv1.1.0-rc.1\n")) //nolint:errcheck
+	}))
+	defer proxy.Close()
+	td := t.TempDir()
+	tl := tool.Tool{ImportPath: "example.org/a/cmd/a", Version: "v0.9.0", ModulePath: "example.org/a"}
+	createLockfile(t, filepath.Join(td, "shed.lock"), []tool.Tool{tl})
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithCacheDir(filepath.Join(td, "cache")),
+		client.WithGoProxy(proxy.URL),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	tests := []struct {
+		maxUpdate client.UpdateKind
+		want      []client.OutdatedTool
+	}{
+		{client.UpdatePatch, []client.OutdatedTool{{Tool: tl, Latest: "v0.9.4", Update: client.UpdatePatch}}},
+		{client.UpdateMinor, []client.OutdatedTool{{Tool: tl, Latest: "v0.10.1", Update: client.UpdateMinor}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.maxUpdate.String(), func(t *testing.T) {
+			got, err := s.Upgrades(context.Background(), client.UpgradeOptions{MaxUpdate: tt.maxUpdate})
+			if err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpgradeBranch(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	newShed := func(head string) *client.Shed {
+		mockGo, err := cache.NewMockGo(map[string]map[string]string{
+			"example.org/a/branch/cmd/b": {"main": head, head: head, "v0.1.0": "v0.1.0"},
+		})
+		if err != nil {
+			t.Fatalf("failed to create mock go %v", err)
+		}
+		s, err := client.NewShed(
+			client.WithLockfilePath(lockfilePath),
+			client.WithCache(cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo))),
+		)
+		if err != nil {
+			t.Fatalf("failed to create shed client %v", err)
+		}
+		return s
+	}
+
+	// The branch is resolved to a pseudo-version and recorded
+	s := newShed("v0.0.0-20201203230243-22d10c9b658d")
+	installSet, err := s.Install("example.org/a/branch/cmd/b@main")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	got, err := readLockfile(t, lockfilePath).GetTool("b")
+	if err != nil || got.Version != "v0.0.0-20201203230243-22d10c9b658d" || got.Branch != "main" {
+		t.Fatalf("got %+v with error %v, want the branch to be pinned to a pseudo-version", got, err)
+	}
+
+	// Upgrading resolves the branch again
+	s = newShed("v0.1.1-0.20210105120000-abcdefabcdef")
+	upgrades, err := s.Upgrades(context.Background(), client.UpgradeOptions{})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := []client.OutdatedTool{{Tool: got, Latest: "v0.1.1-0.20210105120000-abcdefabcdef", Update: client.UpdateMinor}}
+	if !reflect.DeepEqual(upgrades, want) {
+		t.Errorf("got %+v, want %+v", upgrades, want)
+	}
+	if upgrades, err := s.Upgrades(context.Background(), client.UpgradeOptions{MaxUpdate: client.UpdatePatch}); err != nil || len(upgrades) != 0 {
+		t.Errorf("got upgrades %+v with error %v, want none with --patch", upgrades, err)
+	}
+	installSet, err = s.Upgrade(context.Background(), client.UpgradeOptions{Tools: []string{"b"}})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	got, err = readLockfile(t, lockfilePath).GetTool("b")
+	if err != nil || got.Version != "v0.1.1-0.20210105120000-abcdefabcdef" || got.Branch != "main" {
+		t.Errorf("got %+v with error %v, want the latest commit of the branch", got, err)
+	}
+
+	// Installing a version stops tracking the branch
+	installSet, err = s.Install("example.org/a/branch/cmd/b@v0.1.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	got, err = readLockfile(t, lockfilePath).GetTool("b")
+	if err != nil || got.Version != "v0.1.0" || got.Branch != "" {
+		t.Errorf("got %+v with error %v, want v0.1.0 without a branch", got, err)
+	}
+}
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/semver"
+)
+
+// PlanAction is the change that installing a tool makes to the lockfile.
+type PlanAction int
+
+const (
+	// PlanKeep means the tool is already in the lockfile as is.
+	PlanKeep PlanAction = iota + 1
+	// PlanAdd means the tool is not in the lockfile and will be added.
+	PlanAdd
+	// PlanUpgrade means the tool will be changed to a newer version.
+	PlanUpgrade
+	// PlanDowngrade means the tool will be changed to an older version.
+	PlanDowngrade
+	// PlanChange means the version of the tool stays the same, but its build flags or alias change.
+	PlanChange
+	// PlanRemove means the tool will be removed from the lockfile, since its version is 'none'.
+	PlanRemove
+)
+
+func (a PlanAction) String() string {
+	switch a {
+	case PlanKeep:
+		return "keep"
+	case PlanAdd:
+		return "add"
+	case PlanUpgrade:
+		return "upgrade"
+	case PlanDowngrade:
+		return "downgrade"
+	case PlanChange:
+		return "change"
+	case PlanRemove:
+		return "remove"
+	}
+	return fmt.Sprintf("PlanAction(%d)", int(a))
+}
+
+// PlannedTool describes what installing a tool would do.
+type PlannedTool struct {
+	Action PlanAction
+	// Tool is the tool as it would be in the lockfile, with its version resolved.
+	// For PlanRemove, it is the tool in the lockfile.
+	Tool tool.Tool
+	// Current is the tool in the lockfile. It is the zero value for PlanAdd.
+	Current tool.Tool
+	// Cached reports whether the tool is already built in the cache,
+	// otherwise it would be downloaded and built.
+	Cached bool
+}
+
+// InstallPlan describes the changes that InstallSet.Apply would make.
+type InstallPlan struct {
+	// Tools has an entry for each tool that would be installed or removed, in the order of the InstallSet.
+	Tools []PlannedTool
+}
+
+// Changed reports whether applying the plan would change the lockfile.
+func (p *InstallPlan) Changed() bool {
+	for _, pt := range p.Tools {
+		if pt.Action != PlanKeep {
+			return true
+		}
+	}
+	return false
+}
+
+// Plan returns what Apply would do, without changing the cache or the lockfile.
+//
+// Tools without an exact version are resolved with the resolver set with WithResolver.
+// If there is none, the module proxies in GOPROXY are queried directly, so the version may
+// differ from what the go command would resolve if GOPROXY contains 'direct'.
+//
+// A tool is planned for every tool whose version could be resolved. If some couldn't be,
+// the error is a lockfile.ErrorList with a *ToolError for each failure.
+func (is *InstallSet) Plan(ctx context.Context) (*InstallPlan, error) {
+	if is.s.cache == nil {
+		return nil, ErrNoCache
+	}
+	plan := &InstallPlan{}
+	var errs lockfile.ErrorList
+	for _, t := range is.tools {
+		pt := PlannedTool{Tool: t}
+		is.s.mu.RLock()
+		current, err := is.s.lf.GetTool(t.ImportPath)
+		is.s.mu.RUnlock()
+		inLockfile := err == nil
+		if inLockfile {
+			pt.Current = current
+		}
+
+		if t.Version == noneVersion {
+			// Removing a tool that isn't in the lockfile does nothing
+			if inLockfile {
+				plan.Tools = append(plan.Tools, PlannedTool{Action: PlanRemove, Tool: current, Current: current})
+			}
+			continue
+		}
+		if !t.HasSemver() {
+			resolved, err := is.s.resolve(ctx, is.s.listResolver(t), t)
+			if err != nil {
+				errs = append(errs, installError(t, errors.WithMessagef(err, "failed to resolve version of tool %s", t)))
+				continue
+			}
+			pt.Tool = resolved
+		}
+		if _, err := is.s.cache.ToolPath(pt.Tool); err == nil {
+			pt.Cached = true
+		}
+
+		switch c := semver.Compare(pt.Tool.Version, current.Version); {
+		case !inLockfile:
+			pt.Action = PlanAdd
+		case c > 0:
+			pt.Action = PlanUpgrade
+		case c < 0:
+			pt.Action = PlanDowngrade
+		case pt.Tool.BuildFlags != current.BuildFlags || pt.Tool.Alias != current.Alias:
+			pt.Action = PlanChange
+		default:
+			pt.Action = PlanKeep
+		}
+		plan.Tools = append(plan.Tools, pt)
+	}
+	if len(errs) > 0 {
+		return plan, errs
+	}
+	return plan, nil
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/policy"
+	"github.com/getshiphub/shed/tool"
+	"github.com/getshiphub/shed/vulndb"
+	"github.com/pkg/errors"
+)
+
+// checkPolicy checks tools against the policy set with WithPolicy. If resolved is true, the versions of
+// tools have been resolved and the tools are installed, so vulnerabilities are also checked if the policy
+// denies them. The error is a lockfile.ErrorList of *ToolError with the kind ErrPolicyViolation for each
+// rule that is broken, or nil if every tool follows the policy.
+func (s *Shed) checkPolicy(ctx context.Context, tools []tool.Tool, resolved bool) error {
+	if s.policy == nil {
+		return nil
+	}
+	db := s.vulnDB
+	if db == nil {
+		db = vulndb.FromEnv()
+	}
+	dbModules := make(map[string]*vulndb.Module)
+
+	var errs lockfile.ErrorList
+	for _, t := range tools {
+		if t.Version == noneVersion {
+			// Removing a tool can't break the policy
+			continue
+		}
+		for _, v := range s.policy.CheckTool(t) {
+			errs = append(errs, &ToolError{Kind: ErrPolicyViolation, Spec: t.String(), Tool: t, Err: v})
+		}
+		if !resolved || !s.policy.DenyVulnerable {
+			continue
+		}
+		at, auditErrs := s.auditTool(ctx, db, dbModules, t)
+		if len(auditErrs) > 0 {
+			// Fail closed, since the policy can't be enforced
+			err := errors.WithMessagef(lockfile.ErrorList(auditErrs), "failed to check tool %s for vulnerabilities", t)
+			errs = append(errs, &ToolError{Kind: ErrInstallFailed, Spec: t.String(), Tool: t, Err: err})
+			continue
+		}
+		if len(at.Vulns) > 0 {
+			ids := make([]string, len(at.Vulns))
+			for i, v := range at.Vulns {
+				ids[i] = v.ID
+			}
+			v := policy.Violation{Tool: t.String(), Reason: fmt.Sprintf("affected by vulnerabilities %s", strings.Join(ids, ", "))}
+			errs = append(errs, &ToolError{Kind: ErrPolicyViolation, Spec: t.String(), Tool: t, Err: v})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
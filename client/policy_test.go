@@ -0,0 +1,100 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/policy"
+	"github.com/getshiphub/shed/vulndb"
+)
+
+func TestInstallPolicy(t *testing.T) {
+	files := map[string]string{
+		"/index/modules.json":   `[{"path": "github.com/golangci/golangci-lint", "vulns": [{"id": "GO-2022-0001"}]}]`,
+		"/ID/GO-2022-0001.json": `{"id": "GO-2022-0001", "summary": "Crash", "affected": [{"package": {"name": "github.com/golangci/golangci-lint"}, "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.30.0"}]}]}]}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(data)) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	s, err := client.NewShed(
+		client.WithLockfilePath(lockfilePath),
+		client.WithCache(cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo))),
+		client.WithVulnDB(vulndb.New(srv.Client(), srv.URL)),
+		client.WithPolicy(&policy.Policy{
+			AllowedSources:  []string{"github.com/cszatmary", "github.com/golangci"},
+			RequireReleases: true,
+			DenyVulnerable:  true,
+		}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		spec       string
+		wantReason string
+	}{
+		{"source", "github.com/Shopify/ejson/cmd/ejson@v1.2.2", "source is not allowed"},
+		{"pseudo-version", "github.com/cszatmary/go-fish@22d10c9b658df297b17b33c836a60fb943ef5a5f", "pseudo-version is not allowed"},
+		{"vulnerable", "github.com/golangci/golangci-lint/cmd/golangci-lint@v1.28.3", "affected by vulnerabilities GO-2022-0001"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installSet, err := s.Install(tt.spec)
+			if err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+			err = installSet.Apply(context.Background())
+			var errs lockfile.ErrorList
+			if !errors.As(err, &errs) || len(errs) != 1 || !errors.Is(errs[0], client.ErrPolicyViolation) {
+				t.Fatalf("got error %v, want %v", err, client.ErrPolicyViolation)
+			}
+			var v policy.Violation
+			if !errors.As(errs[0], &v) || v.Reason != tt.wantReason {
+				t.Errorf("got violation %+v, want reason %q", v, tt.wantReason)
+			}
+			if tools := s.List(); len(tools) != 0 {
+				t.Errorf("got tools %v, want the lockfile to be unchanged", tools)
+			}
+		})
+	}
+
+	installSet, err := s.Install("github.com/cszatmary/go-fish@v0.1.0", "github.com/golangci/golangci-lint/cmd/golangci-lint@v1.33.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	tools := s.List()
+	var names []string
+	for _, tl := range tools {
+		names = append(names, tl.String())
+	}
+	want := "github.com/cszatmary/go-fish@v0.1.0,github.com/golangci/golangci-lint/cmd/golangci-lint@v1.33.0"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("got tools %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,54 @@
+//go:build !windows
+// +build !windows
+
+package client
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+)
+
+// setProcessGroup configures c to start in a new process group so that
+// any children of the process can be signalled along with it.
+func setProcessGroup(c *exec.Cmd) {
+	if c.SysProcAttr == nil {
+		c.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	c.SysProcAttr.Setpgid = true
+}
+
+// signalProcess sends sig to the process started by c. If the process was
+// started in its own process group or session, the whole group is signalled.
+func signalProcess(c *exec.Cmd, sig syscall.Signal) error {
+	if c.SysProcAttr != nil && (c.SysProcAttr.Setpgid || c.SysProcAttr.Setsid) {
+		// A negative pid signals every process in the group
+		return syscall.Kill(-c.Process.Pid, sig)
+	}
+	return c.Process.Signal(sig)
+}
+
+// terminateProcess asks the process started by c to exit.
+func terminateProcess(c *exec.Cmd) error {
+	return signalProcess(c, syscall.SIGTERM)
+}
+
+// killProcess forcefully kills the process started by c.
+func killProcess(c *exec.Cmd) error {
+	return signalProcess(c, syscall.SIGKILL)
+}
+
+// maxRSS returns the maximum resident set size of the exited process in bytes,
+// or 0 if it is not available.
+func maxRSS(ps *os.ProcessState) int64 {
+	ru, ok := ps.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	// macOS reports bytes, everything else reports kilobytes
+	if runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
+		return int64(ru.Maxrss)
+	}
+	return int64(ru.Maxrss) * 1024
+}
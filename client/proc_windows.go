@@ -0,0 +1,31 @@
+package client
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup configures c to start in a new process group.
+func setProcessGroup(c *exec.Cmd) {
+	if c.SysProcAttr == nil {
+		c.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	c.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
+
+// terminateProcess asks the process started by c to exit. Windows has no
+// equivalent of SIGTERM so the process is killed immediately.
+func terminateProcess(c *exec.Cmd) error {
+	return c.Process.Kill()
+}
+
+// killProcess forcefully kills the process started by c.
+func killProcess(c *exec.Cmd) error {
+	return c.Process.Kill()
+}
+
+// maxRSS returns 0 since the memory usage of a process is not available on Windows.
+func maxRSS(ps *os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,236 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/tool"
+)
+
+// EventKind identifies what happened to a tool during InstallSet.Apply.
+type EventKind int
+
+const (
+	// EventResolving means the version of the tool is being resolved. Unless a resolver was set
+	// with WithResolver, this also downloads it. This only happens for tools that don't have an exact version.
+	EventResolving EventKind = iota + 1
+	// EventDownloading means the tool is being downloaded.
+	EventDownloading
+	// EventBuilding means the tool is being built.
+	EventBuilding
+	// EventDone means the tool was installed successfully, or removed if its version was 'none'.
+	EventDone
+	// EventFailed means the tool could not be installed.
+	EventFailed
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventResolving:
+		return "resolving"
+	case EventDownloading:
+		return "downloading"
+	case EventBuilding:
+		return "building"
+	case EventDone:
+		return "done"
+	case EventFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("EventKind(%d)", int(k))
+}
+
+// Event describes the progress of installing a tool.
+type Event struct {
+	Kind EventKind
+	// Tool is the tool the event is for. For EventDone, it has the version that was installed.
+	Tool tool.Tool
+	// Err is the reason the install failed. Only set for EventFailed.
+	Err error
+	// ETA is the estimated time until every tool being installed is done, based on how long
+	// the tools took to build before. It is 0 if there is no estimate, ex: because none of the
+	// remaining tools have been built with this cache before.
+	ETA time.Duration
+	// Source is where the binary of the tool came from, ex: cache.SourceCache if it was already
+	// in the cache. Only set for EventDone, and 0 if the tool was removed.
+	Source cache.Source
+	// Duration is how long installing the tool took, including resolving its version.
+	// Only set for EventDone and EventFailed.
+	Duration time.Duration
+}
+
+// ApplyOption customizes how InstallSet.Apply installs tools.
+type ApplyOption func(*applyOptions)
+
+type applyOptions struct {
+	progress    func(Event)
+	frozen      bool
+	bestEffort  bool
+	platform    string
+	recordStats bool
+	// enforceToolchain is set by EnforceToolchain
+	enforceToolchain bool
+	// timings records the timings of the tools being installed, nil if they aren't recorded
+	timings *timingRecorder
+}
+
+// Frozen makes Apply fail instead of changing the lockfile. Every tool must already be in the
+// lockfile with the same version, build flags, and alias, and the lockfile is not written.
+// This is useful in CI and containers, where the lockfile should be used exactly as is.
+func Frozen() ApplyOption {
+	return func(o *applyOptions) {
+		o.frozen = true
+	}
+}
+
+// BestEffort makes Apply add the tools that were installed to the lockfile even if other tools failed.
+// By default Apply is all or nothing, the lockfile is only changed if every tool is installed.
+// The error still contains the tools that failed.
+func BestEffort() ApplyOption {
+	return func(o *applyOptions) {
+		o.bestEffort = true
+	}
+}
+
+// RecordStats makes Apply append where each installed tool came from and how long it took to the install
+// history of the project, see InstallHistory. This allows CacheStats to measure how well caching works over time.
+func RecordStats() ApplyOption {
+	return func(o *applyOptions) {
+		o.recordStats = true
+	}
+}
+
+// EnforceToolchain makes Apply refuse to build tools whose modules require a newer version of Go than
+// the go command, see cache.InstallCheckToolchain. The error for each of them is a *cache.ToolchainError.
+// It is always enabled if the project config sets enforceToolchain, see config.Project.EnforceToolchain.
+func EnforceToolchain() ApplyOption {
+	return func(o *applyOptions) {
+		o.enforceToolchain = true
+	}
+}
+
+// TargetPlatform makes Apply install the tools for platform, in the form 'GOOS/GOARCH', ex: 'linux/amd64',
+// instead of the platform shed is running on. This allows building tools for another machine, such as
+// a container image. The binaries are stored separately from the ones for the current platform and can be
+// found with ToolPath and ForPlatform. The lockfile is updated the same way, since it doesn't depend on the
+// platform, except that release tools record the hash of the asset for platform.
+func TargetPlatform(platform string) ApplyOption {
+	return func(o *applyOptions) {
+		o.platform = hostPlatform(platform)
+	}
+}
+
+// WithProgress sets a function that is called with an event each time installing a tool
+// makes progress. Tools that are already in the cache go straight to EventDone.
+// Each event has an estimate of the time remaining, see Event.ETA.
+// Tools are installed concurrently, but calls to fn are serialized so fn does not
+// need to be safe for concurrent use. If Apply is aborted, fn may still be called
+// for tools that were being installed.
+func WithProgress(fn func(Event)) ApplyOption {
+	return func(o *applyOptions) {
+		if fn == nil {
+			o.progress = nil
+			return
+		}
+		var mu sync.Mutex
+		o.progress = func(ev Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			fn(ev)
+		}
+	}
+}
+
+func (o applyOptions) report(ev Event) {
+	if o.progress != nil {
+		o.progress(ev)
+	}
+}
+
+// estimator estimates the time remaining to install a set of tools from the time
+// each tool took to build before.
+type estimator struct {
+	mu sync.Mutex
+	// buildTimes maps import paths to the expected time to build the tool, see cache.Cache.BuildTimes.
+	buildTimes map[string]time.Duration
+	// concurrency is the number of tools installed at once.
+	concurrency int
+	// pending maps the import paths of tools that aren't done to when they started doing work,
+	// or the zero time if they haven't yet. Import paths are used since the version can change
+	// when it is resolved.
+	pending map[string]time.Time
+}
+
+// newEstimator returns an estimator for installing tools, where at most concurrency
+// tools are installed at once. If concurrency is 0, there is no limit.
+func newEstimator(tools []tool.Tool, buildTimes map[string]time.Duration, concurrency int) *estimator {
+	e := &estimator{
+		buildTimes:  buildTimes,
+		concurrency: concurrency,
+		pending:     make(map[string]time.Time),
+	}
+	for _, t := range tools {
+		e.pending[t.ImportPath] = time.Time{}
+	}
+	if e.concurrency <= 0 || e.concurrency > len(e.pending) {
+		e.concurrency = len(e.pending)
+	}
+	return e
+}
+
+// wrap returns a progress function that sets the ETA of each event before passing it to fn.
+func (e *estimator) wrap(fn func(Event)) func(Event) {
+	return func(ev Event) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		ev.ETA = e.observe(ev, time.Now())
+		fn(ev)
+	}
+}
+
+// observe updates the state of the tool ev is for and returns the estimated time remaining at now.
+func (e *estimator) observe(ev Event, now time.Time) time.Duration {
+	switch ev.Kind {
+	case EventDone, EventFailed:
+		delete(e.pending, ev.Tool.ImportPath)
+	default:
+		if started, ok := e.pending[ev.Tool.ImportPath]; ok && started.IsZero() {
+			e.pending[ev.Tool.ImportPath] = now
+		}
+	}
+
+	// Tools installed at the same time are assumed to take as long as the slowest one,
+	// unless there are more tools than can be installed at once
+	var longest, total time.Duration
+	for importPath, started := range e.pending {
+		remaining, ok := e.buildTimes[importPath]
+		if !ok {
+			continue
+		}
+		if !started.IsZero() {
+			remaining -= now.Sub(started)
+		}
+		if remaining <= 0 {
+			continue
+		}
+		total += remaining
+		if remaining > longest {
+			longest = remaining
+		}
+	}
+	if e.concurrency > 0 {
+		if perWorker := total / time.Duration(e.concurrency); perWorker > longest {
+			return perWorker
+		}
+	}
+	return longest
+}
+
+// stageEvents maps the stages of installing a tool in the cache to events.
+var stageEvents = map[cache.Stage]EventKind{
+	cache.StageResolve:  EventResolving,
+	cache.StageDownload: EventDownloading,
+	cache.StageBuild:    EventBuilding,
+}
@@ -0,0 +1,227 @@
+package client
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// InstallProjectsOptions customizes how InstallProjects installs the tools of each project.
+type InstallProjectsOptions struct {
+	// Options are used to create the Shed for each project. WithLockfilePath is added
+	// for each project so it should not be used.
+	Options []Option
+	// Frozen installs the tools of each project as if Frozen was passed to InstallSet.Apply.
+	Frozen bool
+	// Progress is called with an event each time installing a tool makes progress, see WithProgress.
+	// Tools used by multiple projects only have events reported once.
+	Progress func(Event)
+}
+
+// ProjectResult is the result of installing the tools of a project with InstallProjects.
+type ProjectResult struct {
+	// LockfilePath is the path to the lockfile of the project.
+	LockfilePath string
+	// Err is the reason the tools of the project could not be installed, nil if they all were.
+	Err error
+}
+
+// project is a project being installed by InstallProjects.
+type project struct {
+	s     *Shed
+	tools []tool.Tool
+}
+
+// InstallProjects installs the tools in the lockfile of each project in paths. A path can be
+// a lockfile or a directory, in which case the lockfile is found using ResolveLockfilePath.
+//
+// Tools used by multiple projects are only installed once, and projects that use the same cache
+// directory share the same cache, so installing many projects costs about as much as installing
+// the unique tools between them. The tools are installed concurrently, see WithDownloadLimits.
+//
+// A result is returned for each distinct lockfile in the order they were given. If the tools of
+// any project could not be installed, the error is a lockfile.ErrorList with the failures.
+// Failing to find or load a project is returned right away, before anything is installed.
+func InstallProjects(ctx context.Context, paths []string, opts InstallProjectsOptions) ([]ProjectResult, error) {
+	var projects []*project
+	seenLockfiles := make(map[string]bool)
+	caches := make(map[string]*cache.Cache)
+	for _, p := range paths {
+		lockfilePath, err := findProjectLockfile(p)
+		if err != nil {
+			return nil, err
+		}
+		if seenLockfiles[lockfilePath] {
+			continue
+		}
+		seenLockfiles[lockfilePath] = true
+
+		shedOpts := append([]Option{}, opts.Options...)
+		s, err := NewShed(append(shedOpts, WithLockfilePath(lockfilePath))...)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed to load project %s", p)
+		}
+		if s.cache == nil {
+			return nil, ErrNoCache
+		}
+		// Each Shed creates its own cache by default, use the same one for the same directory
+		// so that installs of the same tool are serialized.
+		if c, ok := caches[s.cache.Dir()]; ok {
+			s.cache = c
+		} else {
+			caches[s.cache.Dir()] = s.cache
+		}
+		projects = append(projects, &project{s: s, tools: s.List()})
+	}
+	return installProjects(ctx, projects, caches, opts)
+}
+
+// installProjects installs the tools of projects, see InstallProjects. caches are the caches
+// used by the projects, keyed by directory.
+func installProjects(ctx context.Context, projects []*project, caches map[string]*cache.Cache, opts InstallProjectsOptions) ([]ProjectResult, error) {
+	if len(projects) == 0 {
+		return nil, nil
+	}
+
+	var o applyOptions
+	WithProgress(opts.Progress)(&o)
+	if o.progress != nil {
+		// Each tool is only built in one cache, so the build times of every cache can be combined
+		buildTimes := make(map[string]time.Duration)
+		for _, c := range caches {
+			times, err := c.BuildTimes()
+			if err != nil {
+				projects[0].s.logger.WithError(err).Debugf("failed to read build times of cache %s", c.Dir())
+				continue
+			}
+			for importPath, d := range times {
+				buildTimes[importPath] = d
+			}
+		}
+		var tools []tool.Tool
+		for _, proj := range projects {
+			tools = append(tools, proj.tools...)
+		}
+		o.progress = newEstimator(tools, buildTimes, projects[0].s.downloadConcurrency).wrap(o.progress)
+	}
+	failed := installUniqueTools(ctx, projects, o)
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err, "installation was aborted")
+	}
+
+	// Every tool is now in the cache, so applying each project only updates its lockfile
+	var applyOpts []ApplyOption
+	if opts.Frozen {
+		applyOpts = append(applyOpts, Frozen())
+	}
+	results := make([]ProjectResult, len(projects))
+	var errs lockfile.ErrorList
+	for i, proj := range projects {
+		results[i].LockfilePath = proj.s.lockfilePath
+		var toolErrs lockfile.ErrorList
+		for _, t := range proj.tools {
+			if err, ok := failed[toolKey(proj.s, t)]; ok {
+				toolErrs = append(toolErrs, err)
+			}
+		}
+		if len(toolErrs) > 0 {
+			results[i].Err = toolErrs
+		} else if is, err := proj.s.Install(); err != nil {
+			results[i].Err = err
+		} else {
+			results[i].Err = is.Apply(ctx, applyOpts...)
+		}
+		if results[i].Err != nil {
+			errs = append(errs, errors.WithMessagef(results[i].Err, "failed to install tools for %s", proj.s.lockfilePath))
+		}
+	}
+	if len(errs) > 0 {
+		return results, errs
+	}
+	return results, nil
+}
+
+// installUniqueTools installs each distinct tool used by projects once. The tool is installed
+// using the Shed of the first project that uses it. The errors of the tools that failed are
+// returned, keyed by toolKey.
+func installUniqueTools(ctx context.Context, projects []*project, o applyOptions) map[string]error {
+	type result struct {
+		key string
+		err error
+	}
+	resultCh := make(chan result)
+	// Used to limit the number of tools being installed at once, nil if there is no limit
+	var sem chan struct{}
+	if n := projects[0].s.downloadConcurrency; n > 0 {
+		sem = make(chan struct{}, n)
+	}
+	seen := make(map[string]bool)
+	for _, proj := range projects {
+		for _, tl := range proj.tools {
+			key := toolKey(proj.s, tl)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			go func(s *Shed, t tool.Tool) {
+				if sem != nil {
+					select {
+					case sem <- struct{}{}:
+						defer func() { <-sem }()
+					case <-ctx.Done():
+						resultCh <- result{key, ctx.Err()}
+						return
+					}
+				}
+				_, _, err := s.installTool(ctx, t, o)
+				resultCh <- result{key, err}
+			}(proj.s, tl)
+		}
+	}
+
+	failed := make(map[string]error)
+	for i := 0; i < len(seen); i++ {
+		r := <-resultCh
+		if r.err != nil {
+			failed[r.key] = r.err
+		}
+	}
+	return failed
+}
+
+// toolKey identifies t in the cache of s. Tools with the same key are the same build of the same tool.
+func toolKey(s *Shed, t tool.Tool) string {
+	fp, err := t.Filepath()
+	if err != nil {
+		// Invalid tools will fail to install, just keep them distinct
+		fp = t.String()
+	}
+	return s.cache.Dir() + string(filepath.ListSeparator) + fp
+}
+
+// findProjectLockfile returns the absolute path to the lockfile of the project at p,
+// which is either a lockfile or a directory.
+func findProjectLockfile(p string) (string, error) {
+	fi, err := os.Stat(p)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find project %s", p)
+	}
+	lockfilePath := p
+	if fi.IsDir() {
+		lockfilePath = ResolveLockfilePath(p)
+		if lockfilePath == "" {
+			return "", errors.Errorf("no %s found for project %s", LockfileName, p)
+		}
+	}
+	abs, err := filepath.Abs(lockfilePath)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get absolute path of %s", lockfilePath)
+	}
+	return abs, nil
+}
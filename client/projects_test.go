@@ -0,0 +1,131 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+)
+
+// createProject creates a project in dir with a lockfile containing tools.
+func createProject(t *testing.T, dir string, tools []tool.Tool) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	createLockfile(t, filepath.Join(dir, "shed.lock"), tools)
+}
+
+func TestInstallProjects(t *testing.T) {
+	td := t.TempDir()
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	projectA := filepath.Join(td, "a")
+	projectB := filepath.Join(td, "b")
+	createProject(t, projectA, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.1.0"},
+	})
+	createProject(t, projectB, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
+	})
+
+	builds := make(map[string]int)
+	results, err := client.InstallProjects(
+		context.Background(),
+		// The same project can be given more than once
+		[]string{projectA, projectB, filepath.Join(projectA, "shed.lock")},
+		client.InstallProjectsOptions{
+			Options: []client.Option{client.WithCache(cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo)))},
+			Progress: func(ev client.Event) {
+				if ev.Kind == client.EventBuilding {
+					builds[ev.Tool.ImportPath]++
+				}
+			},
+		},
+	)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	wantResults := []client.ProjectResult{
+		{LockfilePath: filepath.Join(projectA, "shed.lock")},
+		{LockfilePath: filepath.Join(projectB, "shed.lock")},
+	}
+	if !reflect.DeepEqual(results, wantResults) {
+		t.Errorf("got results %+v, want %+v", results, wantResults)
+	}
+	wantBuilds := map[string]int{
+		"github.com/cszatmary/go-fish":                        1,
+		"github.com/Shopify/ejson/cmd/ejson":                  1,
+		"github.com/golangci/golangci-lint/cmd/golangci-lint": 1,
+	}
+	if !reflect.DeepEqual(builds, wantBuilds) {
+		t.Errorf("got builds %v, want %v", builds, wantBuilds)
+	}
+
+	for _, dir := range []string{projectA, projectB} {
+		s, err := client.NewShed(
+			client.WithLockfilePath(filepath.Join(dir, "shed.lock")),
+			client.WithCache(cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo))),
+		)
+		if err != nil {
+			t.Fatalf("failed to create shed client %v", err)
+		}
+		if _, err := s.ToolPath("go-fish"); err != nil {
+			t.Errorf("want go-fish to be installed for %s, got %v", dir, err)
+		}
+	}
+}
+
+func TestInstallProjectsError(t *testing.T) {
+	td := t.TempDir()
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	projectA := filepath.Join(td, "a")
+	projectB := filepath.Join(td, "b")
+	createProject(t, projectA, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+	})
+	createProject(t, projectB, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v9.9.9"},
+	})
+	opts := client.InstallProjectsOptions{
+		Options: []client.Option{client.WithCache(cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo)))},
+	}
+
+	results, err := client.InstallProjects(context.Background(), []string{projectA, projectB}, opts)
+	var errList lockfile.ErrorList
+	if !errors.As(err, &errList) {
+		t.Fatalf("want error to be lockfile.ErrorList, got %T: %v", err, err)
+	}
+	if len(errList) != 1 {
+		t.Errorf("got %d errors, want 1: %v", len(errList), errList)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	if results[0].Err != nil {
+		t.Errorf("want nil error for %s, got %v", results[0].LockfilePath, results[0].Err)
+	}
+	if results[1].Err == nil {
+		t.Errorf("want error for %s, got nil", results[1].LockfilePath)
+	}
+
+	// Projects that can't be found fail before anything is installed
+	_, err = client.InstallProjects(context.Background(), []string{projectA, filepath.Join(td, "missing")}, opts)
+	if err == nil {
+		t.Error("want error for missing project, got nil")
+	}
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// PruneCache removes tools from the cache that aren't used by any known lockfile, according to
+// the limits in opts. See cache.Cache.Prune for details on the limits.
+//
+// Known lockfiles are the lockfile of s and every lockfile that tools have been installed for
+// with this cache. Lockfiles that no longer exist are forgotten, unless opts.DryRun is set.
+// If a known lockfile can't be read, nothing is removed, since the tools it uses are unknown.
+func (s *Shed) PruneCache(ctx context.Context, opts cache.PruneOptions) (*cache.PruneResult, error) {
+	if s.cache == nil {
+		return nil, ErrNoCache
+	}
+	keep, err := s.knownTools(opts.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	return s.cache.Prune(ctx, keep, opts)
+}
+
+// PruneModules removes the modules from the module cache of the cache that aren't needed by the tools
+// of any known lockfile. See PruneCache for which lockfiles are known, and cache.Cache.PruneModules
+// for details. It is an error if the cache doesn't have its own module cache, see WithModuleCache.
+func (s *Shed) PruneModules(ctx context.Context, dryRun bool) (*cache.ModulePruneResult, error) {
+	if s.cache == nil {
+		return nil, ErrNoCache
+	}
+	keep, err := s.knownTools(dryRun)
+	if err != nil {
+		return nil, err
+	}
+	return s.cache.PruneModules(ctx, keep, dryRun)
+}
+
+// knownTools returns the tools in the lockfile of s and every other lockfile known to use the cache.
+// Known lockfiles that no longer exist are forgotten, unless dryRun is set.
+func (s *Shed) knownTools(dryRun bool) ([]tool.Tool, error) {
+	paths, err := s.cache.Lockfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	keep := s.List()
+	var missing []string
+	for _, p := range paths {
+		if same, err := sameFile(p, s.lockfilePath); err == nil && same {
+			continue
+		}
+		tools, err := readLockfileTools(p)
+		if os.IsNotExist(errors.Cause(err)) {
+			missing = append(missing, p)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		keep = append(keep, tools...)
+	}
+	if len(missing) > 0 && !dryRun {
+		s.logger.Debugf("Forgetting lockfiles that no longer exist: %v", missing)
+		if err := s.cache.ForgetLockfiles(missing...); err != nil {
+			return nil, err
+		}
+	}
+	return keep, nil
+}
+
+// recordLockfile records that the tools in the lockfile of s are installed in the cache,
+// so they are kept when the cache is pruned.
+func (s *Shed) recordLockfile() {
+	if err := s.cache.RecordLockfile(s.lockfilePath); err != nil {
+		s.logger.WithError(err).Debugf("failed to record lockfile %s", s.lockfilePath)
+	}
+}
+
+// readLockfileTools returns the tools in the lockfile at p.
+func readLockfileTools(p string) ([]tool.Tool, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open lockfile %s", p)
+	}
+	defer f.Close()
+	lf, err := lockfile.Parse(f)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to parse lockfile %s", p)
+	}
+	var tools []tool.Tool
+	it := lf.Iter()
+	for it.Next() {
+		tools = append(tools, it.Value())
+	}
+	return tools, nil
+}
+
+// sameFile reports whether a and b are the same path once made absolute.
+func sameFile(a, b string) (bool, error) {
+	absA, err := filepath.Abs(a)
+	if err != nil {
+		return false, err
+	}
+	absB, err := filepath.Abs(b)
+	if err != nil {
+		return false, err
+	}
+	return absA == absB, nil
+}
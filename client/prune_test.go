@@ -0,0 +1,344 @@
+package client_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+)
+
+// newPruneShed returns a Shed for a project in dir that uses the cache in cacheDir
+// and installs toolNames. opts are passed to client.NewShed.
+func newPruneShed(t *testing.T, dir, cacheDir string, toolNames []string, opts ...client.Option) *client.Shed {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	opts = append([]client.Option{
+		client.WithLockfilePath(filepath.Join(dir, "shed.lock")),
+		client.WithCache(cache.New(cacheDir, cache.WithGo(mockGo))),
+	}, opts...)
+	s, err := client.NewShed(opts...)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	installSet, err := s.Install(toolNames...)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	return s
+}
+
+func prunedTools(res *cache.PruneResult) []string {
+	var paths []string
+	for _, t := range res.Removed {
+		paths = append(paths, filepath.Base(t.Path))
+	}
+	return paths
+}
+
+func TestPruneCache(t *testing.T) {
+	td := t.TempDir()
+	cacheDir := filepath.Join(td, "cache")
+	s := newPruneShed(t, filepath.Join(td, "a"), cacheDir, []string{"github.com/cszatmary/go-fish@v0.1.0", "github.com/golangci/golangci-lint/cmd/golangci-lint@v1.33.0"})
+	other := filepath.Join(td, "b")
+	newPruneShed(t, other, cacheDir, []string{"github.com/Shopify/ejson/cmd/ejson@v1.1.0"})
+
+	// Everything is in use
+	res, err := s.PruneCache(context.Background(), cache.PruneOptions{})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if len(res.Removed) != 0 || res.Reclaimed != 0 {
+		t.Errorf("got removed %v, want none", prunedTools(res))
+	}
+
+	if err := s.Uninstall(context.Background(), "golangci-lint"); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	res, err = s.PruneCache(context.Background(), cache.PruneOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := []string{"golangci-lint@v1.33.0"}
+	if got := prunedTools(res); !reflect.DeepEqual(got, want) {
+		t.Errorf("got removed %v, want %v", got, want)
+	}
+	if res.Reclaimed <= 0 || res.Removed[0].Size != res.Reclaimed {
+		t.Errorf("got reclaimed %d, want size of removed tool %d", res.Reclaimed, res.Removed[0].Size)
+	}
+	if _, err := os.Stat(res.Removed[0].Path); err != nil {
+		t.Errorf("want dry run to keep %s, got %v", res.Removed[0].Path, err)
+	}
+
+	res, err = s.PruneCache(context.Background(), cache.PruneOptions{})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if got := prunedTools(res); !reflect.DeepEqual(got, want) {
+		t.Errorf("got removed %v, want %v", got, want)
+	}
+	if _, err := os.Stat(res.Removed[0].Path); !os.IsNotExist(err) {
+		t.Errorf("want %s to be removed, got %v", res.Removed[0].Path, err)
+	}
+
+	// Tools of lockfiles that were deleted are no longer used
+	if err := os.RemoveAll(other); err != nil {
+		t.Fatalf("failed to remove dir: %v", err)
+	}
+	res, err = s.PruneCache(context.Background(), cache.PruneOptions{})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want = []string{"ejson@v1.1.0"}
+	if got := prunedTools(res); !reflect.DeepEqual(got, want) {
+		t.Errorf("got removed %v, want %v", got, want)
+	}
+}
+
+func TestPruneCacheBuilds(t *testing.T) {
+	td := t.TempDir()
+	cacheDir := filepath.Join(td, "cache")
+	s := newPruneShed(t, filepath.Join(td, "a"), cacheDir, []string{"github.com/cszatmary/go-fish@v0.1.0"})
+	oldPath, err := s.ToolPath("go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	// Building with a newer version of go replaces the current build
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	c := cache.New(cacheDir, cache.WithGo(mockGo))
+	if _, err := c.Install(context.Background(), s.List()[0], cache.InstallEnv("GOVERSION=go1.99.0")); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	newPath, err := s.ToolPath("go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	// Recent builds are kept when there is a max age
+	res, err := s.PruneCache(context.Background(), cache.PruneOptions{MaxAge: time.Hour})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if len(res.Removed) != 0 {
+		t.Errorf("got removed %v, want none", prunedTools(res))
+	}
+
+	res, err = s.PruneCache(context.Background(), cache.PruneOptions{})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := []string{filepath.Base(filepath.Dir(oldPath))}
+	if got := prunedTools(res); !reflect.DeepEqual(got, want) {
+		t.Errorf("got removed %v, want %v", got, want)
+	}
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Errorf("want %s to be removed, got %v", oldPath, err)
+	}
+	if p, err := s.ToolPath("go-fish"); err != nil || p != newPath {
+		t.Errorf("got tool path %s, %v, want %s", p, err, newPath)
+	}
+}
+
+func TestPruneCacheLimits(t *testing.T) {
+	td := t.TempDir()
+	cacheDir := filepath.Join(td, "cache")
+	newPruneShed(t, filepath.Join(td, "old"), cacheDir, []string{
+		"github.com/cszatmary/go-fish@v0.1.0",
+		"github.com/golangci/golangci-lint/cmd/golangci-lint@v1.28.3",
+		"github.com/Shopify/ejson/cmd/ejson@v1.1.0",
+	})
+	// Make the tools look like they were installed days apart
+	for i, name := range []string{"github.com/cszatmary/go-fish@v0.1.0", "github.com/golangci/golangci-lint/cmd/golangci-lint@v1.28.3", "github.com/!shopify/ejson/cmd/ejson@v1.1.0"} {
+		dir := filepath.Join(cacheDir, "tools", filepath.FromSlash(name))
+		mtime := time.Now().Add(-time.Duration(3-i) * 24 * time.Hour)
+		err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			return os.Chtimes(p, mtime, mtime)
+		})
+		if err != nil {
+			t.Fatalf("failed to set times: %v", err)
+		}
+	}
+	if err := os.RemoveAll(filepath.Join(td, "old")); err != nil {
+		t.Fatalf("failed to remove dir: %v", err)
+	}
+	s := newPruneShed(t, filepath.Join(td, "new"), cacheDir, nil)
+
+	res, err := s.PruneCache(context.Background(), cache.PruneOptions{MaxAge: 36 * time.Hour, DryRun: true})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := []string{"go-fish@v0.1.0", "golangci-lint@v1.28.3"}
+	if got := prunedTools(res); !reflect.DeepEqual(got, want) {
+		t.Errorf("got removed %v, want %v", got, want)
+	}
+
+	// Only the oldest tool needs to be removed to get under the size
+	res, err = s.PruneCache(context.Background(), cache.PruneOptions{MaxSize: res.Size + res.Reclaimed - 1, DryRun: true})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want = []string{"go-fish@v0.1.0"}
+	if got := prunedTools(res); !reflect.DeepEqual(got, want) {
+		t.Errorf("got removed %v, want %v", got, want)
+	}
+}
+
+func TestPruneModules(t *testing.T) {
+	td := t.TempDir()
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	c := cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo), cache.WithModuleCache(true))
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithCache(c),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	installSet, err := s.Install("github.com/cszatmary/go-fish@v0.1.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	// The mock go doesn't download anything, so fill the module cache like the go command would
+	modDir := c.ModuleCacheDir()
+	files := []string{
+		"cache/download/github.com/cszatmary/go-fish/@v/v0.1.0.zip",
+		"cache/download/github.com/cszatmary/go-fish/@v/v0.1.0.mod",
+		"cache/download/github.com/cszatmary/go-fish/@v/list",
+		"cache/download/github.com/cszatmary/go-fish/@v/v0.0.1.zip",
+		"cache/download/example.com/!unused/@v/v1.0.0.zip",
+		"cache/download/sumdb/sum.golang.org/latest",
+		"github.com/cszatmary/go-fish@v0.1.0/main.go",
+		"example.com/!unused@v1.0.0/unused.go",
+	}
+	for _, f := range files {
+		p := filepath.Join(modDir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := ioutil.WriteFile(p, []byte("module"), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	prunedModules := func(res *cache.ModulePruneResult) []string {
+		var mods []string
+		for _, m := range res.Removed {
+			mods = append(mods, m.Module.String())
+		}
+		return mods
+	}
+	want := []string{"example.com/Unused@v1.0.0", "github.com/cszatmary/go-fish@v0.0.1"}
+	res, err := s.PruneModules(context.Background(), true)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if got := prunedModules(res); !reflect.DeepEqual(got, want) {
+		t.Errorf("got removed %v, want %v", got, want)
+	}
+	if res.Reclaimed != 3*int64(len("module")) {
+		t.Errorf("got reclaimed %d, want %d", res.Reclaimed, 3*len("module"))
+	}
+	if _, err := os.Stat(filepath.Join(modDir, "example.com", "!unused@v1.0.0")); err != nil {
+		t.Errorf("want dry run to keep module, got %v", err)
+	}
+
+	res, err = s.PruneModules(context.Background(), false)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if got := prunedModules(res); !reflect.DeepEqual(got, want) {
+		t.Errorf("got removed %v, want %v", got, want)
+	}
+	for i, f := range files {
+		_, err := os.Stat(filepath.Join(modDir, filepath.FromSlash(f)))
+		removed := i == 3 || i == 4 || i == 7
+		if removed && !os.IsNotExist(err) {
+			t.Errorf("want %s to be removed, got %v", f, err)
+		} else if !removed && err != nil {
+			t.Errorf("want %s to be kept, got %v", f, err)
+		}
+	}
+
+	// Only a module cache managed by shed can be pruned
+	other, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithCache(cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	if _, err := other.PruneModules(context.Background(), true); err == nil {
+		t.Error("want error pruning unmanaged module cache, got nil")
+	}
+}
+
+func TestGoCaches(t *testing.T) {
+	td := t.TempDir()
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	shared := filepath.Join(td, "shared")
+	tests := []struct {
+		name           string
+		opts           []cache.Option
+		wantModCache   string
+		wantBuildCache string
+	}{
+		{
+			name:           "shared",
+			opts:           []cache.Option{cache.WithGoModCache(filepath.Join(shared, "mod")), cache.WithGoBuildCache(filepath.Join(shared, "build"))},
+			wantModCache:   filepath.Join(shared, "mod"),
+			wantBuildCache: filepath.Join(shared, "build"),
+		},
+		{
+			name:           "isolated",
+			opts:           []cache.Option{cache.WithIsolated(true), cache.WithGoModCache(filepath.Join(shared, "mod"))},
+			wantModCache:   filepath.Join(td, "isolated", "modules"),
+			wantBuildCache: filepath.Join(td, "isolated", "gocache"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cache.New(filepath.Join(td, tt.name), append([]cache.Option{cache.WithGo(mockGo)}, tt.opts...)...)
+			values, err := c.GoEnv(context.Background(), "GOMODCACHE", "GOCACHE")
+			if err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+			if values[0] != tt.wantModCache || values[1] != tt.wantBuildCache {
+				t.Errorf("got GOMODCACHE %s and GOCACHE %s, want %s and %s", values[0], values[1], tt.wantModCache, tt.wantBuildCache)
+			}
+			// Only a module cache in the cache can be pruned
+			if _, err := c.PruneModules(context.Background(), nil, true); (err == nil) != (tt.name == "isolated") {
+				t.Errorf("got prune error %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// getTool retrieves the tool with the given name from the lockfile.
+// If the tool is not found and name was renamed in the config file,
+// the tool with the new name is returned instead and a deprecation warning is logged.
+func (s *Shed) getTool(name string) (tool.Tool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, err := s.lf.GetTool(name)
+	if !errors.Is(err, lockfile.ErrNotFound) {
+		return t, err
+	}
+	newName, ok := s.config.Renames[name]
+	if !ok {
+		return t, err
+	}
+	// Only warn once per name since a tool can be looked up multiple times, ex: when running tasks
+	if _, warned := s.warnedRenames.LoadOrStore(name, true); !warned {
+		s.logger.Warnf("The tool name %s is deprecated, use %s instead. Run 'shed fix-names' to update references to it.", name, newName)
+	}
+	return s.lf.GetTool(newName)
+}
+
+// renamePatterns returns the patterns used to find references to old tool names.
+// Each pattern has three groups: the text before the name, the name, and the text after it.
+func renamePatterns(oldName string) []*regexp.Regexp {
+	name := regexp.QuoteMeta(oldName)
+	return []*regexp.Regexp{
+		// Commands in scripts, Makefiles, CI config, etc.
+		regexp.MustCompile(`(\bshed\s+run\s+)(` + name + `)([\s"'` + "`" + `;|&)]|$)`),
+		// Tasks in the config file
+		regexp.MustCompile(`("tool"\s*:\s*")(` + name + `)(")`),
+	}
+}
+
+// FixNames updates references to renamed tools in the given files to use the new names.
+// The renamed tools are configured in the config file. A reference is either an invocation
+// of 'shed run' with the tool or the tool of a task in a config file.
+//
+// FixNames returns the paths of the files that were modified.
+func (s *Shed) FixNames(paths ...string) ([]string, error) {
+	oldNames := make([]string, 0, len(s.config.Renames))
+	for name := range s.config.Renames {
+		oldNames = append(oldNames, name)
+	}
+	// Sort so the result is deterministic if renames are chained
+	sort.Strings(oldNames)
+
+	var modified []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return modified, errors.Wrapf(err, "failed to stat file %s", p)
+		}
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return modified, errors.Wrapf(err, "failed to read file %s", p)
+		}
+
+		fixed := data
+		for _, oldName := range oldNames {
+			repl := []byte("${1}" + s.config.Renames[oldName] + "${3}")
+			for _, re := range renamePatterns(oldName) {
+				fixed = re.ReplaceAll(fixed, repl)
+			}
+		}
+		if string(fixed) == string(data) {
+			continue
+		}
+		s.logger.Debugf("Updating tool names in %s", p)
+		if err := ioutil.WriteFile(p, fixed, info.Mode()); err != nil {
+			return modified, errors.Wrapf(err, "failed to write file %s", p)
+		}
+		modified = append(modified, p)
+	}
+	return modified, nil
+}
@@ -0,0 +1,151 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// UninstalledFileName is the name of the file in the project directory that records the tools removed
+// from the lockfile by Uninstall and UninstallGroups, so that they can be brought back with Restore.
+// It uses the same format as the lockfile and only has the most recently uninstalled version of each tool.
+const UninstalledFileName = "uninstalled.lock"
+
+func (s *Shed) uninstalledPath() string {
+	return filepath.Join(s.projectRoot(), ProjectDirName, UninstalledFileName)
+}
+
+// readUninstalled reads the tools that were uninstalled. If none were, an empty lockfile is returned.
+func (s *Shed) readUninstalled() (*lockfile.Lockfile, error) {
+	p := s.uninstalledPath()
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return &lockfile.Lockfile{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open file %s", p)
+	}
+	defer f.Close()
+	lf, err := lockfile.Parse(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", p)
+	}
+	return lf, nil
+}
+
+// writeUninstalled writes the tools that were uninstalled. Like the lockfile, it is written to
+// a temp file first so it is never left partially written.
+func (s *Shed) writeUninstalled(lf *lockfile.Lockfile) error {
+	p := s.uninstalledPath()
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return errors.Wrapf(err, "failed to create directory %s", filepath.Dir(p))
+	}
+	f, err := ioutil.TempFile(filepath.Dir(p), "."+UninstalledFileName+"-")
+	if err != nil {
+		return errors.Wrapf(err, "failed to create temp file for %s", p)
+	}
+	defer os.Remove(f.Name())
+	if _, err = lf.WriteTo(f); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "failed to write %s", p)
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrapf(err, "failed to write %s", p)
+	}
+	if err := os.Rename(f.Name(), p); err != nil {
+		return errors.Wrapf(err, "failed to write %s", p)
+	}
+	return nil
+}
+
+// recordUninstalled records that tools were removed from the lockfile. The lockfile must be locked, see updateLockfile.
+func (s *Shed) recordUninstalled(tools []tool.Tool) error {
+	if len(tools) == 0 {
+		return nil
+	}
+	lf, err := s.readUninstalled()
+	if err != nil {
+		return err
+	}
+	for _, t := range tools {
+		// An older uninstalled tool can have the same name, the newer one wins
+		err := lf.PutTool(t)
+		for errors.Is(err, lockfile.ErrAliasConflict) {
+			old, gerr := lf.GetTool(t.Name())
+			if gerr != nil {
+				break
+			}
+			lf.DeleteTool(old)
+			err = lf.PutTool(t)
+		}
+		if err != nil {
+			// Not being able to restore a tool shouldn't prevent it from being uninstalled
+			s.logger.WithError(err).Debugf("failed to record uninstalled tool %s", t)
+		}
+	}
+	return s.writeUninstalled(lf)
+}
+
+// Uninstalled returns the tools that can be brought back with Restore, sorted by import path.
+func (s *Shed) Uninstalled() ([]tool.Tool, error) {
+	lf, err := s.readUninstalled()
+	if err != nil {
+		return nil, err
+	}
+	var tools []tool.Tool
+	it := lf.Iter()
+	for it.Next() {
+		tools = append(tools, it.Value())
+	}
+	return tools, nil
+}
+
+// Restore adds a tool that was removed by Uninstall or UninstallGroups back to the lockfile, exactly as it was
+// before it was uninstalled. toolName is looked up in the uninstalled tools like it is in the lockfile,
+// see lockfile.Lockfile.GetTool. If no uninstalled tool matches, the error matches lockfile.ErrNotFound.
+// It is an error if a tool with the same import path is in the lockfile.
+//
+// Uninstalling a tool doesn't remove it from the cache, since other projects may use it, so it can usually
+// be used right away. If the cache was pruned since, the tool is brought back from the trash of the cache,
+// see cache.Cache.Untrash. Restore reports whether the tool is installed. If it isn't, it was purged
+// from the trash and must be installed again, ex: with Install.
+func (s *Shed) Restore(ctx context.Context, toolName string) (tool.Tool, bool, error) {
+	var restored tool.Tool
+	err := s.updateLockfile(ctx, func() error {
+		uninstalled, err := s.readUninstalled()
+		if err != nil {
+			return err
+		}
+		t, err := uninstalled.GetTool(toolName)
+		if err != nil {
+			return errors.WithMessagef(err, "failed to find uninstalled tool %s", toolName)
+		}
+		if existing, err := s.lf.GetTool(t.ImportPath); err == nil {
+			return errors.Errorf("failed to restore tool %s, %s is already in the lockfile", t, existing)
+		}
+		if err := s.lf.PutTool(t); err != nil {
+			return errors.WithMessagef(err, "failed to restore tool %s", t)
+		}
+		uninstalled.DeleteTool(t)
+		restored = t
+		return s.writeUninstalled(uninstalled)
+	})
+	if err != nil {
+		return tool.Tool{}, false, err
+	}
+	s.logger.Debugf("Restored tool: %v", restored)
+	if s.cache == nil {
+		return restored, false, nil
+	}
+	s.recordLockfile()
+	installed, err := s.cache.Untrash(ctx, restored)
+	if err != nil {
+		return restored, false, errors.WithMessagef(err, "failed to restore tool %s from the cache trash", restored)
+	}
+	return restored, installed, nil
+}
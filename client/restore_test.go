@@ -0,0 +1,147 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+)
+
+func TestRestore(t *testing.T) {
+	td := t.TempDir()
+	cacheDir := filepath.Join(td, "cache")
+	s := newPruneShed(t, filepath.Join(td, "a"), cacheDir, []string{"github.com/cszatmary/go-fish@v0.1.0", "github.com/golangci/golangci-lint/cmd/golangci-lint@v1.33.0"})
+	lint := s.List()[1]
+	binPath, err := s.ToolPath("golangci-lint")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	_, _, err = s.Restore(context.Background(), "golangci-lint")
+	if !errors.Is(err, lockfile.ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, lockfile.ErrNotFound)
+	}
+
+	uninstall := func() {
+		t.Helper()
+		if err := s.Uninstall(context.Background(), "golangci-lint"); err != nil {
+			t.Fatalf("want nil error, got %v", err)
+		}
+		uninstalled, err := s.Uninstalled()
+		if err != nil {
+			t.Fatalf("want nil error, got %v", err)
+		}
+		if want := []tool.Tool{lint}; !reflect.DeepEqual(uninstalled, want) {
+			t.Errorf("got uninstalled tools %v, want %v", uninstalled, want)
+		}
+	}
+	restore := func(wantInstalled bool) {
+		t.Helper()
+		restored, installed, err := s.Restore(context.Background(), "golangci-lint")
+		if err != nil {
+			t.Fatalf("want nil error, got %v", err)
+		}
+		if !reflect.DeepEqual(restored, lint) {
+			t.Errorf("got restored tool %v, want %v", restored, lint)
+		}
+		if installed != wantInstalled {
+			t.Errorf("got installed %t, want %t", installed, wantInstalled)
+		}
+		if got := s.List(); len(got) != 2 || !reflect.DeepEqual(got[1], lint) {
+			t.Errorf("got tools %v, want %v to be in the lockfile", got, lint)
+		}
+		if uninstalled, err := s.Uninstalled(); err != nil || len(uninstalled) != 0 {
+			t.Errorf("got uninstalled tools %v and error %v, want none", uninstalled, err)
+		}
+	}
+
+	// The binary is still in the cache
+	uninstall()
+	restore(true)
+
+	// The binary was moved to the trash by prune
+	uninstall()
+	res, err := s.PruneCache(context.Background(), cache.PruneOptions{})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if len(res.Removed) != 1 {
+		t.Fatalf("got removed %v, want %s", prunedTools(res), lint)
+	}
+	if _, err := os.Stat(binPath); !os.IsNotExist(err) {
+		t.Fatalf("want %s to be removed, got %v", binPath, err)
+	}
+	restore(true)
+	if _, err := os.Stat(binPath); err != nil {
+		t.Errorf("want %s to be restored, got %v", binPath, err)
+	}
+
+	// The binary was purged from the trash
+	uninstall()
+	if _, err := s.PruneCache(context.Background(), cache.PruneOptions{}); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	trashDir := filepath.Join(cacheDir, "trash")
+	infos, err := ioutil.ReadDir(trashDir)
+	if err != nil || len(infos) != 1 {
+		t.Fatalf("got trash %v and error %v, want one entry", infos, err)
+	}
+	// Make it look like it was pruned a long time ago
+	if err := os.Rename(filepath.Join(trashDir, infos[0].Name()), filepath.Join(trashDir, "1")); err != nil {
+		t.Fatalf("failed to rename trash entry: %v", err)
+	}
+	res, err = s.PruneCache(context.Background(), cache.PruneOptions{})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if res.Purged <= 0 {
+		t.Errorf("got purged %d, want more than 0", res.Purged)
+	}
+	restore(false)
+
+	// A tool can't be restored if it was installed again
+	uninstall()
+	installSet, err := s.Install("github.com/golangci/golangci-lint/cmd/golangci-lint@v1.28.3")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if _, _, err := s.Restore(context.Background(), "golangci-lint"); err == nil {
+		t.Error("want error restoring a tool that is in the lockfile, got nil")
+	}
+}
+
+func TestPruneCacheSkipTrash(t *testing.T) {
+	td := t.TempDir()
+	cacheDir := filepath.Join(td, "cache")
+	s := newPruneShed(t, filepath.Join(td, "a"), cacheDir, []string{"github.com/cszatmary/go-fish@v0.1.0"})
+	if err := s.Uninstall(context.Background(), "go-fish"); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	res, err := s.PruneCache(context.Background(), cache.PruneOptions{SkipTrash: true})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if len(res.Removed) != 1 {
+		t.Errorf("got removed %v, want go-fish", prunedTools(res))
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "trash")); !os.IsNotExist(err) {
+		t.Errorf("want no trash, got %v", err)
+	}
+	_, installed, err := s.Restore(context.Background(), "go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if installed {
+		t.Error("want go-fish to not be installed")
+	}
+}
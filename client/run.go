@@ -0,0 +1,481 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/config"
+	"github.com/getshiphub/shed/internal/pty"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// OriginalDirEnvVar is the environment variable that contains the directory the tool was
+// invoked from. It is set when the tool's working directory is pinned in the config file.
+const OriginalDirEnvVar = "SHED_ORIGINAL_DIR"
+
+// defaultKillAfter is how long to wait after terminating a tool before killing it
+// if no value was provided.
+const defaultKillAfter = 10 * time.Second
+
+// TimeoutError is returned when a tool is stopped because it exceeded its timeout.
+type TimeoutError struct {
+	// Tool is the tool that timed out.
+	Tool tool.Tool
+	// Timeout is the timeout that was exceeded.
+	Timeout time.Duration
+	// Killed reports whether the tool had to be forcefully killed because
+	// it did not exit within the grace period after being terminated.
+	Killed bool
+}
+
+func (e *TimeoutError) Error() string {
+	msg := fmt.Sprintf("tool %s timed out after %s", e.Tool, e.Timeout)
+	if e.Killed {
+		msg += " and was killed"
+	}
+	return msg
+}
+
+// RunOptions allows for customizing how a tool is run.
+type RunOptions struct {
+	// Stdin, Stdout, and Stderr are connected to the tool's process.
+	// If one is nil, the process reads from or writes to the null device.
+	//
+	// Stdin is not replayed between attempts, so if the tool is retried
+	// it will only see whatever is left to read.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// Env specifies the environment of the tool's process, in the form "key=value".
+	// If nil, the tool uses the current process's environment.
+	Env []string
+	// ExtraEnv contains variables added to Env, or to the current process's environment if Env is nil,
+	// in the form "key=value". They take precedence over variables with the same key, so a variable
+	// can be set for a single run without copying the whole environment.
+	ExtraEnv []string
+	// Dir specifies the working directory of the tool. If empty, the tool runs in the
+	// current directory. If the config file pins the directory for the tool, Dir is
+	// ignored and is instead exported to the tool as SHED_ORIGINAL_DIR.
+	Dir string
+	// Retry overrides the retry policy specified in the config file.
+	// If nil, the policy from the config file is used.
+	Retry *config.RetryPolicy
+	// Timeout overrides the timeout specified in the config file.
+	// If 0, the timeout from the config file is used.
+	//
+	// When a timeout is set, or the context passed to Run can be cancelled, the tool is run in its
+	// own process group, so that any processes it starts are also stopped. The exception is a tool
+	// whose stdin is a terminal and has no timeout, which stays in the foreground process group of
+	// the terminal so it can read from it. Interrupting it from the terminal already reaches its children.
+	Timeout time.Duration
+	// KillAfter overrides the grace period specified in the config file.
+	// If 0, the value from the config file is used, or 10s if the config
+	// file does not specify one.
+	KillAfter time.Duration
+	// TTY controls whether the tool is run in a pseudo-terminal. This is useful for
+	// tools that behave differently when not run in a terminal. When run in a pseudo-terminal,
+	// all output is written to Stdout. The default is TTYAuto.
+	TTY TTYMode
+	// CaptureDir is a directory where the output of the tool is captured.
+	// If set, stdout and stderr are also written to timestamped files in CaptureDir
+	// along with a JSON manifest containing the exit code and duration of each attempt.
+	CaptureDir string
+	// Force causes tasks to be run even if their inputs have not changed.
+	// It is only used by RunTask.
+	Force bool
+	// AllowModified runs the tool even if its binary was modified after it was installed.
+	// By default Run fails with an error matching cache.ErrBinaryModified, since a binary
+	// that was changed by hand doesn't behave like the version in the lockfile.
+	AllowModified bool
+	// RecordStats appends the stats of each attempt to the run history of the project,
+	// so they can be compared with later runs. See RunHistory.
+	RecordStats bool
+	// Rebuild rebuilds the tool before running it if its binary is stale, like the Rebuild
+	// option of ToolPath. By default Run fails if the binary does not exist.
+	Rebuild bool
+	// Version runs the given version of the tool instead of the version in the lockfile, like ToolPathVersion.
+	// It is only used by Run.
+	Version string
+}
+
+// RunAttempt contains the results of a single attempt at running a tool.
+type RunAttempt struct {
+	// ExitCode is the exit code of the process, or -1 if the process
+	// did not exit normally.
+	ExitCode int
+	// Duration is how long the attempt took.
+	Duration time.Duration
+	// UserTime and SystemTime are the CPU time used by the process in user and kernel mode.
+	UserTime   time.Duration
+	SystemTime time.Duration
+	// MaxRSS is the maximum resident set size of the process in bytes.
+	// It is 0 if it is not available, such as on Windows.
+	MaxRSS int64
+	// Err is the error that caused the attempt to fail, if any.
+	Err error
+}
+
+// RunReport contains the results of running a tool.
+type RunReport struct {
+	// Tool is the tool that was run.
+	Tool tool.Tool
+	// Args are the arguments the tool was run with.
+	Args []string
+	// Task is the name of the task the tool was run for, if any.
+	Task string
+	// Attempts contains each attempt at running the tool in order.
+	Attempts []RunAttempt
+	// CaptureManifest is the path to the manifest file if output was captured.
+	CaptureManifest string
+}
+
+// ExitCode returns the exit code of the last attempt.
+// If the tool was never run, -1 is returned.
+func (r *RunReport) ExitCode() int {
+	if len(r.Attempts) == 0 {
+		return -1
+	}
+	return r.Attempts[len(r.Attempts)-1].ExitCode
+}
+
+// runSettings are the resolved settings for running a tool, taking into account
+// the config file and the provided RunOptions.
+type runSettings struct {
+	retry     config.RetryPolicy
+	timeout   time.Duration
+	killAfter time.Duration
+	// pinnedDir is the directory from the config, relative to the project root.
+	pinnedDir string
+	// task is the name of the task being run, if any.
+	task string
+	// input proxies stdin to the pseudo-terminal of each attempt, if one is used.
+	input *inputPump
+}
+
+// resolveRunSettings determines the settings for running t. Each config is applied
+// in order, with non-zero values overriding previous ones. opts takes precedence over all configs.
+func resolveRunSettings(opts RunOptions, configs ...config.Tool) runSettings {
+	var rs runSettings
+	for _, c := range configs {
+		if c.RetryPolicy != (config.RetryPolicy{}) {
+			rs.retry = c.RetryPolicy
+		}
+		if c.Timeout != 0 {
+			rs.timeout = time.Duration(c.Timeout)
+		}
+		if c.KillAfter != 0 {
+			rs.killAfter = time.Duration(c.KillAfter)
+		}
+		if c.Dir != "" {
+			rs.pinnedDir = c.Dir
+		}
+	}
+	if opts.Retry != nil {
+		rs.retry = *opts.Retry
+	}
+	if opts.Timeout != 0 {
+		rs.timeout = opts.Timeout
+	}
+	if opts.KillAfter != 0 {
+		rs.killAfter = opts.KillAfter
+	}
+	if rs.killAfter == 0 {
+		rs.killAfter = defaultKillAfter
+	}
+	return rs
+}
+
+// Run runs the tool with the given name passing args to it. The tool must be installed.
+// toolName can either be the name of the binary or the full import path.
+//
+// If the tool fails, it will be retried according to the retry policy for the tool.
+// The returned RunReport contains details on each attempt. If the tool could not be found,
+// the report will be nil. If the last attempt timed out, the error will be a *TimeoutError.
+// If trust on first use is enabled with WithTrust and the tool's module isn't trusted,
+// the report will be nil and the error will match ErrUntrusted.
+//
+// The provided context is used to stop the tool if the context becomes done before
+// the tool exits on its own. The tool is stopped the same way as if it timed out.
+// No further attempts will be made once the context is done.
+func (s *Shed) Run(ctx context.Context, toolName string, args []string, opts RunOptions) (*RunReport, error) {
+	var t tool.Tool
+	var err error
+	if opts.Version != "" {
+		t, err = s.toolVersion(toolName, opts.Version)
+	} else {
+		t, err = s.findTool(toolName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rs := resolveRunSettings(opts, s.config.Tool(t))
+	return s.run(ctx, t, args, rs, opts)
+}
+
+func (s *Shed) run(ctx context.Context, t tool.Tool, args []string, rs runSettings, opts RunOptions) (*RunReport, error) {
+	if s.cache == nil {
+		return nil, ErrNoCache
+	}
+	if opts.Rebuild {
+		if err := s.rebuildStale(ctx, t); err != nil {
+			return nil, err
+		}
+	}
+	binPath, err := s.cache.ToolPath(t)
+	if opts.AllowModified && errors.Is(err, cache.ErrBinaryModified) {
+		s.logger.WithError(err).Warnf("Running %s even though its binary was modified", t)
+		binPath, err = s.cache.BinaryPath(t)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.logger.WithFields(logrus.Fields{
+		"tool": t,
+		"path": binPath,
+	}).Debug("Found path for tool")
+	if err := s.checkTrust(t); err != nil {
+		return nil, err
+	}
+	return s.runBinary(ctx, t, binPath, args, rs, opts)
+}
+
+// runBinary runs binPath, the binary of t, with args, applying the options and running the hooks.
+func (s *Shed) runBinary(ctx context.Context, t tool.Tool, binPath string, args []string, rs runSettings, opts RunOptions) (*RunReport, error) {
+	var err error
+	if len(opts.ExtraEnv) > 0 {
+		env := opts.Env
+		if env == nil {
+			env = os.Environ()
+		}
+		// Later values take precedence when running a command. Copy so we don't modify the caller's slice
+		opts.Env = append(env[:len(env):len(env)], opts.ExtraEnv...)
+	}
+	if rs.pinnedDir != "" {
+		if opts, err = s.pinDir(rs.pinnedDir, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &RunReport{Tool: t, Args: args, Task: rs.task}
+	if opts, err = s.runPreRunHook(ctx, report, opts); err != nil {
+		return report, err
+	}
+	err = s.runCaptured(ctx, report, binPath, rs, opts)
+	if opts.RecordStats {
+		// The run history is only informational, so don't fail the run because of it
+		if herr := s.recordRun(report); herr != nil {
+			s.logger.WithError(herr).Warn("Failed to record run stats")
+		}
+	}
+	if herr := s.runPostRunHook(ctx, report, opts); herr != nil && err == nil {
+		err = herr
+	}
+	return report, err
+}
+
+// runCaptured runs the binary at binPath, capturing its output if opts.CaptureDir is set.
+func (s *Shed) runCaptured(ctx context.Context, report *RunReport, binPath string, rs runSettings, opts RunOptions) error {
+	if opts.CaptureDir == "" {
+		return s.runAttempts(ctx, report, binPath, rs, opts)
+	}
+
+	c, err := newCapture(opts.CaptureDir, report.Tool, s.redaction)
+	if err != nil {
+		return err
+	}
+	opts.Stdout = tee(opts.Stdout, c.stdout)
+	opts.Stderr = tee(opts.Stderr, c.stderr)
+	err = s.runAttempts(ctx, report, binPath, rs, opts)
+	if cerr := c.finish(report); cerr != nil && err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// pinDir returns a copy of opts with the working directory set to dir which is relative
+// to the project root. The original directory is exported to the tool through the environment.
+func (s *Shed) pinDir(dir string, opts RunOptions) (RunOptions, error) {
+	origDir := opts.Dir
+	if origDir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return opts, errors.Wrap(err, "failed to get current working directory")
+		}
+		origDir = wd
+	}
+	origDir, err := filepath.Abs(origDir)
+	if err != nil {
+		return opts, errors.Wrapf(err, "failed to get absolute path of %s", origDir)
+	}
+	root, err := filepath.Abs(s.projectRoot())
+	if err != nil {
+		return opts, errors.Wrapf(err, "failed to get absolute path of %s", s.configPath)
+	}
+
+	env := opts.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	// Copy so we don't modify the caller's slice
+	opts.Env = append(env[:len(env):len(env)], OriginalDirEnvVar+"="+origDir)
+	opts.Dir = filepath.Join(root, filepath.FromSlash(dir))
+	s.logger.WithFields(logrus.Fields{
+		"dir":         opts.Dir,
+		"originalDir": origDir,
+	}).Debug("Using pinned working directory")
+	return opts, nil
+}
+
+// runAttempts runs the binary at binPath until it either succeeds or the retry policy
+// is exhausted. Each attempt is recorded in report.
+func (s *Shed) runAttempts(ctx context.Context, report *RunReport, binPath string, rs runSettings, opts RunOptions) error {
+	t := report.Tool
+	if opts.Stdin != nil && wantsTTY(opts) {
+		// Shared by all attempts, so input isn't lost between them
+		rs.input = newInputPump(opts.Stdin)
+	}
+	for attempt := 1; ; attempt++ {
+		ra := s.runOnce(ctx, t, binPath, report.Args, rs, opts)
+		report.Attempts = append(report.Attempts, ra)
+		err := ra.Err
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return errors.Wrap(ctx.Err(), "run was aborted")
+		}
+
+		// Only retry if the tool actually ran and failed. Any other error
+		// means the binary couldn't be executed, so retrying won't help.
+		var exitErr *exec.ExitError
+		var timeoutErr *TimeoutError
+		retryable := errors.As(err, &exitErr) || errors.As(err, &timeoutErr)
+		if !retryable || attempt > rs.retry.Retries {
+			if timeoutErr != nil {
+				return timeoutErr
+			}
+			return errors.Wrapf(err, "failed to run tool %s", t)
+		}
+
+		backoff := time.Duration(rs.retry.Backoff)
+		s.logger.WithFields(logrus.Fields{
+			"tool":     t,
+			"attempt":  attempt,
+			"exitCode": ra.ExitCode,
+			"backoff":  backoff,
+		}).Debug("Tool failed, retrying")
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Wrap(ctx.Err(), "run was aborted")
+		case <-timer.C:
+		}
+	}
+}
+
+// runOnce runs the binary at binPath a single time. If the timeout is reached
+// or ctx becomes done, the process is terminated, then killed if it does not
+// exit within the grace period.
+func (s *Shed) runOnce(ctx context.Context, t tool.Tool, binPath string, args []string, rs runSettings, opts RunOptions) RunAttempt {
+	c := exec.Command(binPath, args...)
+	c.Stdin = opts.Stdin
+	c.Stdout = opts.Stdout
+	c.Stderr = opts.Stderr
+	c.Env = opts.Env
+	c.Dir = opts.Dir
+
+	start := time.Now()
+	ps, err := startProcess(ctx, c, rs, opts)
+	if err != nil {
+		return RunAttempt{ExitCode: -1, Err: err}
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Wait()
+	}()
+
+	var timeoutC <-chan time.Time
+	if rs.timeout > 0 {
+		timer := time.NewTimer(rs.timeout)
+		defer timer.Stop()
+		timeoutC = timer.C
+	}
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		_, err = s.stopProcess(c, done, rs.killAfter)
+	case <-timeoutC:
+		killed, _ := s.stopProcess(c, done, rs.killAfter)
+		err = &TimeoutError{Tool: t, Timeout: rs.timeout, Killed: killed}
+	}
+	if ps != nil {
+		ps.close()
+	}
+
+	ra := RunAttempt{Duration: time.Since(start), Err: err, ExitCode: -1}
+	if state := c.ProcessState; state != nil {
+		ra.ExitCode = state.ExitCode()
+		ra.UserTime = state.UserTime()
+		ra.SystemTime = state.SystemTime()
+		ra.MaxRSS = maxRSS(state)
+	}
+	return ra
+}
+
+// startProcess starts c, allocating a pseudo-terminal if required by opts.
+// If a pseudo-terminal was allocated, the session is returned. If c can be stopped
+// because of a timeout or ctx, it is started in a new process group, see RunOptions.Timeout.
+func startProcess(ctx context.Context, c *exec.Cmd, rs runSettings, opts RunOptions) (*ptySession, error) {
+	if wantsTTY(opts) {
+		ps, err := startPTY(c, opts, rs.input)
+		// If the user didn't explicitly ask for a tty, fallback to running normally
+		if errors.Is(err, pty.ErrUnsupported) && opts.TTY == TTYAuto {
+			c.Stdin = opts.Stdin
+			c.Stdout = opts.Stdout
+			c.Stderr = opts.Stderr
+		} else {
+			return ps, err
+		}
+	}
+	// A pty already puts the process in a new session so only do this if not using a pty.
+	// A process outside the foreground process group is stopped if it reads from the terminal.
+	_, stdinTTY := isTerminal(opts.Stdin)
+	if rs.timeout > 0 || (ctx.Done() != nil && !stdinTTY) {
+		setProcessGroup(c)
+	}
+	return nil, c.Start()
+}
+
+// stopProcess terminates the process started by c and waits for it to exit.
+// If it does not exit within killAfter, it is killed. done must receive the
+// result of c.Wait. stopProcess returns whether or not the process was killed
+// and the result of c.Wait.
+func (s *Shed) stopProcess(c *exec.Cmd, done <-chan error, killAfter time.Duration) (bool, error) {
+	if err := terminateProcess(c); err != nil {
+		s.logger.WithError(err).Debug("Failed to terminate tool")
+	}
+	timer := time.NewTimer(killAfter)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return false, err
+	case <-timer.C:
+	}
+
+	s.logger.Debugf("Tool did not exit after %s, killing", killAfter)
+	if err := killProcess(c); err != nil {
+		s.logger.WithError(err).Debug("Failed to kill tool")
+	}
+	return true, <-done
+}
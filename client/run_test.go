@@ -0,0 +1,726 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getshiphub/shed/api"
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/config"
+	"github.com/getshiphub/shed/tool"
+)
+
+// newRunShed creates a Shed with go-fish installed. The go-fish binary is replaced
+// with a shell script that fails until it has been run failCount times.
+func newRunShed(t *testing.T, failCount int, cfg string) *client.Shed {
+	counterPath := filepath.Join(t.TempDir(), "counter")
+	script := fmt.Sprintf(`#!/bin/sh
+count=$(cat %[1]q 2>/dev/null || echo 0)
+count=$((count + 1))
+echo "$count" > %[1]q
+echo "$@"
+if [ "$count" -le %[2]d ]; then
+	exit 3
+fi
+`, counterPath, failCount)
+	return newScriptShed(t, script, cfg)
+}
+
+// newScriptShed creates a Shed with go-fish installed. The go-fish binary is replaced
+// with the given shell script.
+func newScriptShed(t *testing.T, script, cfg string, opts ...client.Option) *client.Shed {
+	if runtime.GOOS == "windows" {
+		t.Skip("run tests use shell scripts")
+	}
+
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+	})
+	if cfg != "" {
+		err := ioutil.WriteFile(filepath.Join(td, config.ProjectFileName), []byte(cfg), 0o644)
+		if err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+	}
+
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	opts = append([]client.Option{
+		client.WithLockfilePath(lockfilePath),
+		client.WithCache(cache.New(td, cache.WithGo(mockGo))),
+	}, opts...)
+	s, err := client.NewShed(opts...)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	installSet, err := s.Install()
+	if err != nil {
+		t.Fatalf("failed to create install set: %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("failed to install tools: %v", err)
+	}
+
+	binPath, err := s.ToolPath("go-fish")
+	if err != nil {
+		t.Fatalf("failed to get tool path: %v", err)
+	}
+	if err := ioutil.WriteFile(binPath, []byte(script), 0o755); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	// WriteFile doesn't change the permissions of existing files
+	if err := os.Chmod(binPath, 0o755); err != nil {
+		t.Fatalf("failed to make script executable: %v", err)
+	}
+	recordBinarySum(t, binPath)
+	return s
+}
+
+// recordBinarySum records the hash of the binary at binPath in its tool directory,
+// as if it had been built by shed, so it isn't treated as modified.
+func recordBinarySum(t *testing.T, binPath string) {
+	data, err := ioutil.ReadFile(binPath)
+	if err != nil {
+		t.Fatalf("failed to read binary: %v", err)
+	}
+	sum := fmt.Sprintf("sha256:%x\n", sha256.Sum256(data))
+	if err := ioutil.WriteFile(filepath.Join(filepath.Dir(binPath), "shed.sum"), []byte(sum), 0o644); err != nil {
+		t.Fatalf("failed to write hash of binary: %v", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name         string
+		failCount    int
+		config       string
+		opts         client.RunOptions
+		wantAttempts int
+		wantExitCode int
+		wantErr      bool
+	}{
+		{
+			name:         "success",
+			failCount:    0,
+			wantAttempts: 1,
+			wantExitCode: 0,
+		},
+		{
+			name:         "failure without retries",
+			failCount:    1,
+			wantAttempts: 1,
+			wantExitCode: 3,
+			wantErr:      true,
+		},
+		{
+			name:         "succeeds after retry from config",
+			failCount:    2,
+			config:       `{"tools": {"go-fish": {"retries": 2}}}`,
+			wantAttempts: 3,
+			wantExitCode: 0,
+		},
+		{
+			name:         "retries exhausted",
+			failCount:    5,
+			config:       `{"tools": {"go-fish": {"retries": 2}}}`,
+			wantAttempts: 3,
+			wantExitCode: 3,
+			wantErr:      true,
+		},
+		{
+			name:         "options override config",
+			failCount:    1,
+			config:       `{"tools": {"go-fish": {"retries": 2}}}`,
+			opts:         client.RunOptions{Retry: &config.RetryPolicy{Retries: 0}},
+			wantAttempts: 1,
+			wantExitCode: 3,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newRunShed(t, tt.failCount, tt.config)
+			report, err := s.Run(context.Background(), "go-fish", []string{"install"}, tt.opts)
+			if tt.wantErr && err == nil {
+				t.Error("want non-nil error, got nil")
+			} else if !tt.wantErr && err != nil {
+				t.Errorf("want nil error, got %v", err)
+			}
+			if len(report.Attempts) != tt.wantAttempts {
+				t.Errorf("got %d attempts, want %d", len(report.Attempts), tt.wantAttempts)
+			}
+			if report.ExitCode() != tt.wantExitCode {
+				t.Errorf("got exit code %d, want %d", report.ExitCode(), tt.wantExitCode)
+			}
+		})
+	}
+}
+
+func TestRunModifiedBinary(t *testing.T) {
+	s := newScriptShed(t, "#!/bin/sh\necho fish\n", "")
+	stdout := &bytes.Buffer{}
+	if _, err := s.Run(context.Background(), "go-fish", nil, client.RunOptions{Stdout: stdout}); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	binPath, err := s.ToolPath("go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := ioutil.WriteFile(binPath, []byte("#!/bin/sh\necho shark\n"), 0o755); err != nil {
+		t.Fatalf("failed to write %s: %v", binPath, err)
+	}
+	if _, err := s.ToolPath("go-fish"); !errors.Is(err, cache.ErrBinaryModified) {
+		t.Errorf("got error %v, want %v", err, cache.ErrBinaryModified)
+	}
+	if _, err := s.Run(context.Background(), "go-fish", nil, client.RunOptions{}); !errors.Is(err, cache.ErrBinaryModified) {
+		t.Errorf("got error %v, want %v", err, cache.ErrBinaryModified)
+	}
+
+	stdout.Reset()
+	if _, err := s.Run(context.Background(), "go-fish", nil, client.RunOptions{Stdout: stdout, AllowModified: true}); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if got := stdout.String(); got != "shark\n" {
+		t.Errorf("got output %q, want %q", got, "shark\n")
+	}
+}
+
+func TestRunCapture(t *testing.T) {
+	s := newRunShed(t, 1, `{"tools": {"go-fish": {"retries": 1}}}`)
+	captureDir := filepath.Join(t.TempDir(), "capture")
+
+	stdout := &bytes.Buffer{}
+	report, err := s.Run(context.Background(), "go-fish", []string{"install"}, client.RunOptions{
+		Stdout:     stdout,
+		CaptureDir: captureDir,
+	})
+	if err != nil {
+		t.Errorf("want nil error, got %v", err)
+	}
+	if report.CaptureManifest == "" {
+		t.Fatal("want capture manifest path, got empty string")
+	}
+
+	data, err := ioutil.ReadFile(report.CaptureManifest)
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	var manifest api.CaptureManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("failed to parse manifest: %v", err)
+	}
+	if manifest.SchemaVersion != api.SchemaVersion {
+		t.Errorf("got schema version %d, want %d", manifest.SchemaVersion, api.SchemaVersion)
+	}
+	if manifest.Tool != "github.com/cszatmary/go-fish" {
+		t.Errorf("got tool %s, want %s", manifest.Tool, "github.com/cszatmary/go-fish")
+	}
+	if len(manifest.Attempts) != 2 || manifest.Attempts[0].ExitCode != 3 || manifest.Attempts[1].ExitCode != 0 {
+		t.Errorf("got attempts %+v, want exit codes [3 0]", manifest.Attempts)
+	}
+
+	captured, err := ioutil.ReadFile(filepath.Join(captureDir, manifest.Stdout))
+	if err != nil {
+		t.Fatalf("failed to read captured stdout: %v", err)
+	}
+	wantOut := "install\ninstall\n"
+	if string(captured) != wantOut {
+		t.Errorf("got captured output %q, want %q", captured, wantOut)
+	}
+	if stdout.String() != wantOut {
+		t.Errorf("got output %q, want %q", stdout.String(), wantOut)
+	}
+}
+
+func TestRunCaptureRedacted(t *testing.T) {
+	s := newRunShed(t, 0, `{"redaction": {"aliases": {"github.com/cszatmary": "example.com/fish"}}}`)
+	report, err := s.Run(context.Background(), "go-fish", nil, client.RunOptions{CaptureDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	data, err := ioutil.ReadFile(report.CaptureManifest)
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	var manifest struct {
+		Tool string `json:"tool"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("failed to parse manifest: %v", err)
+	}
+	if manifest.Tool != "example.com/fish/go-fish" {
+		t.Errorf("got tool %s, want %s", manifest.Tool, "example.com/fish/go-fish")
+	}
+}
+
+func TestRunHooks(t *testing.T) {
+	s := newScriptShed(t, "#!/bin/sh\necho \"tool $GREETING\"\n", `{
+  "hooks": {
+    "preRun": ["sh", "-c", "echo GREETING=hello; echo \"pre $SHED_TOOL $SHED_TOOL_VERSION $SHED_TOOL_ARGS\" >&2"],
+    "postRun": ["sh", "-c", "echo \"post $SHED_TOOL_EXIT_CODE\""]
+  }
+}`)
+	var stdout, stderr bytes.Buffer
+	_, err := s.Run(context.Background(), "go-fish", []string{"a", "b"}, client.RunOptions{Stdout: &stdout, Stderr: &stderr})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if got, want := stdout.String(), "tool hello\npost 0\n"; got != want {
+		t.Errorf("got stdout %q, want %q", got, want)
+	}
+	if got, want := stderr.String(), "pre go-fish v0.1.0 [\"a\",\"b\"]\n"; got != want {
+		t.Errorf("got stderr %q, want %q", got, want)
+	}
+}
+
+func TestRunPreRunHookFailed(t *testing.T) {
+	s := newScriptShed(t, "#!/bin/sh\necho tool\n", `{"hooks": {"preRun": ["sh", "-c", "exit 1"]}}`)
+	var stdout bytes.Buffer
+	report, err := s.Run(context.Background(), "go-fish", nil, client.RunOptions{Stdout: &stdout})
+	if err == nil {
+		t.Fatal("want error, got nil")
+	}
+	if len(report.Attempts) != 0 {
+		t.Errorf("got %d attempts, want tool not to be run", len(report.Attempts))
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("got stdout %q, want no output", stdout.String())
+	}
+}
+
+func TestRunRecordStats(t *testing.T) {
+	s := newScriptShed(t, "#!/bin/sh\nexit 3\n", `{"tasks": {"check": {"tool": "go-fish"}}}`)
+	report, err := s.Run(context.Background(), "go-fish", nil, client.RunOptions{RecordStats: true})
+	if err == nil {
+		t.Fatal("want error, got nil")
+	}
+	if runtime.GOOS == "linux" && report.Attempts[0].MaxRSS <= 0 {
+		t.Errorf("got max RSS %d, want > 0", report.Attempts[0].MaxRSS)
+	}
+	if _, err := s.RunTask(context.Background(), "check", nil, client.RunOptions{RecordStats: true}); err == nil {
+		t.Fatal("want error, got nil")
+	}
+	// Runs without RecordStats are not recorded
+	if _, err := s.Run(context.Background(), "go-fish", nil, client.RunOptions{}); err == nil {
+		t.Fatal("want error, got nil")
+	}
+
+	records, err := s.RunHistory()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	for i, wantTask := range []string{"", "check"} {
+		rec := records[i]
+		if rec.ImportPath != "github.com/cszatmary/go-fish" || rec.Version != "v0.1.0" {
+			t.Errorf("got tool %s@%s, want github.com/cszatmary/go-fish@v0.1.0", rec.ImportPath, rec.Version)
+		}
+		if rec.Task != wantTask {
+			t.Errorf("got task %q, want %q", rec.Task, wantTask)
+		}
+		if rec.ExitCode != 3 || rec.Attempt != 1 {
+			t.Errorf("got exit code %d and attempt %d, want 3 and 1", rec.ExitCode, rec.Attempt)
+		}
+		if rec.Duration <= 0 {
+			t.Errorf("got duration %s, want > 0", rec.Duration)
+		}
+	}
+}
+
+func TestCompareRunStats(t *testing.T) {
+	s := newScriptShed(t, "#!/bin/sh\n", "")
+	now := time.Now()
+	records := []client.RunRecord{
+		// Outside of the window
+		{Time: now.Add(-40 * 24 * time.Hour), Version: "v0.0.8", Duration: time.Second},
+		{Time: now.Add(-3 * time.Hour), Version: "v0.0.9", Duration: 100 * time.Millisecond},
+		{Time: now.Add(-3 * time.Hour), Version: "v0.0.9", Duration: 90 * time.Millisecond},
+		{Time: now.Add(-3 * time.Hour), Version: "v0.0.9", Duration: 110 * time.Millisecond},
+		// Failed runs are ignored
+		{Time: now.Add(-2 * time.Hour), Version: "v0.1.0", Duration: time.Millisecond, ExitCode: 1},
+		{Time: now.Add(-time.Hour), Version: "v0.1.0", Duration: 150 * time.Millisecond, Task: "lint"},
+		{Time: now.Add(-time.Hour), Version: "v0.1.0", Duration: 160 * time.Millisecond},
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, rec := range records {
+		if rec.ImportPath == "" {
+			rec.ImportPath = "github.com/cszatmary/go-fish"
+		}
+		if err := enc.Encode(rec); err != nil {
+			t.Fatalf("failed to encode record: %v", err)
+		}
+	}
+	// Also make sure other tools are ignored
+	buf.WriteString(`{"importPath": "golang.org/x/tools/cmd/stringer", "version": "v0.1.0", "duration": 1}` + "\n")
+	historyDir := filepath.Join(s.CacheDir(), client.ProjectDirName)
+	if err := os.MkdirAll(historyDir, 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	writeFile(t, filepath.Join(historyDir, client.HistoryFileName), buf.String())
+
+	c, err := s.CompareRunStats("go-fish", client.CompareOptions{})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if c.Previous == nil || c.Previous.Version != "v0.0.9" || c.Previous.Runs != 3 || c.Previous.MedianDuration != 100*time.Millisecond {
+		t.Errorf("got previous stats %+v, want 3 runs of v0.0.9 with median 100ms", c.Previous)
+	}
+	if c.Current == nil || c.Current.Version != "v0.1.0" || c.Current.Runs != 2 || c.Current.MedianDuration != 155*time.Millisecond {
+		t.Errorf("got current stats %+v, want 2 runs of v0.1.0 with median 155ms", c.Current)
+	}
+	if !c.Regressed || c.Change < 0.54 || c.Change > 0.56 {
+		t.Errorf("got regressed %t with change %f, want regressed with change 0.55", c.Regressed, c.Change)
+	}
+
+	c, err = s.CompareRunStats("go-fish", client.CompareOptions{Threshold: 0.6})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if c.Regressed {
+		t.Error("want not regressed with a higher threshold")
+	}
+
+	c, err = s.CompareRunStats("go-fish", client.CompareOptions{Task: "lint"})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if c.Current == nil || c.Current.Runs != 1 || c.Previous != nil || c.Regressed {
+		t.Errorf("got comparison %+v, want only 1 current run for task", c)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	tests := []struct {
+		name       string
+		script     string
+		wantKilled bool
+	}{
+		{
+			name:       "terminated",
+			script:     "#!/bin/sh\nsleep 10\n",
+			wantKilled: false,
+		},
+		{
+			name:       "killed",
+			script:     "#!/bin/sh\ntrap '' TERM\nsleep 10\n",
+			wantKilled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newScriptShed(t, tt.script, `{"tools": {"go-fish": {"retries": 1}}}`)
+			start := time.Now()
+			report, err := s.Run(context.Background(), "go-fish", nil, client.RunOptions{
+				Timeout:   100 * time.Millisecond,
+				KillAfter: 100 * time.Millisecond,
+			})
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("run took %s, expected tool to be stopped", elapsed)
+			}
+
+			var timeoutErr *client.TimeoutError
+			if !errors.As(err, &timeoutErr) {
+				t.Fatalf("want *client.TimeoutError, got %v", err)
+			}
+			if timeoutErr.Killed != tt.wantKilled {
+				t.Errorf("got killed %t, want %t", timeoutErr.Killed, tt.wantKilled)
+			}
+			// Timeouts should be retried
+			if len(report.Attempts) != 2 {
+				t.Errorf("got %d attempts, want %d", len(report.Attempts), 2)
+			}
+		})
+	}
+}
+
+func TestRunCancelStopsChildren(t *testing.T) {
+	td := t.TempDir()
+	startedPath := filepath.Join(td, "started")
+	markerPath := filepath.Join(td, "marker")
+	script := fmt.Sprintf("#!/bin/sh\n(sleep 1; touch %q) &\ntouch %q\nwait\n", markerPath, startedPath)
+	s := newScriptShed(t, script, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for i := 0; i < 100; i++ {
+			if _, err := os.Stat(startedPath); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+	}()
+	if _, err := s.Run(ctx, "go-fish", nil, client.RunOptions{KillAfter: 100 * time.Millisecond}); err == nil {
+		t.Fatal("want error when run is cancelled, got nil")
+	}
+	// Give the child of the tool time to create the marker if it wasn't stopped
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := os.Stat(markerPath); err == nil {
+		t.Error("want processes started by the tool to be stopped when the run is cancelled")
+	}
+}
+
+func TestRunTTY(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("pseudo-terminals are not supported")
+	}
+
+	tests := []struct {
+		name    string
+		mode    client.TTYMode
+		wantOut string
+	}{
+		{
+			name:    "always",
+			mode:    client.TTYAlways,
+			wantOut: "tty\r\n",
+		},
+		{
+			name:    "never",
+			mode:    client.TTYNever,
+			wantOut: "notty\n",
+		},
+		{
+			// stdin isn't a terminal so a pty shouldn't be allocated
+			name:    "auto",
+			mode:    client.TTYAuto,
+			wantOut: "notty\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newScriptShed(t, "#!/bin/sh\nif [ -t 1 ]; then echo tty; else echo notty; fi\n", "")
+			stdout := &bytes.Buffer{}
+			_, err := s.Run(context.Background(), "go-fish", nil, client.RunOptions{
+				Stdout: stdout,
+				TTY:    tt.mode,
+			})
+			if err != nil {
+				t.Errorf("want nil error, got %v", err)
+			}
+			if stdout.String() != tt.wantOut {
+				t.Errorf("got output %q, want %q", stdout.String(), tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestRunTTYRetryInput(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("pseudo-terminals are not supported")
+	}
+
+	td := t.TempDir()
+	startedPath := filepath.Join(td, "started")
+	// The first attempt fails without reading, the second reads a line
+	script := fmt.Sprintf(`#!/bin/sh
+if [ ! -e %[1]q ]; then
+	touch %[1]q
+	exit 3
+fi
+touch %[1]q.2
+read line
+echo "got $line"
+`, startedPath)
+	s := newScriptShed(t, script, `{"tools": {"go-fish": {"retries": 1}}}`)
+	stdinR, stdinW := io.Pipe()
+	defer stdinW.Close()
+	go func() {
+		// Only write once the second attempt is running, so an earlier attempt could steal the input
+		for i := 0; i < 500; i++ {
+			if _, err := os.Stat(startedPath + ".2"); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		_, _ = stdinW.Write([]byte("second\n"))
+	}()
+	stdout := &bytes.Buffer{}
+	report, err := s.Run(context.Background(), "go-fish", nil, client.RunOptions{
+		Stdin:   stdinR,
+		Stdout:  stdout,
+		TTY:     client.TTYAlways,
+		Timeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if len(report.Attempts) != 2 {
+		t.Errorf("got %d attempts, want 2", len(report.Attempts))
+	}
+	if !strings.Contains(stdout.String(), "got second") {
+		t.Errorf("got output %q, want the second attempt to read the input", stdout.String())
+	}
+}
+
+func TestRunEnv(t *testing.T) {
+	s := newScriptShed(t, "#!/bin/sh\necho \"$SHED_TEST_VAR\"\n", "")
+	stdout := &bytes.Buffer{}
+	_, err := s.Run(context.Background(), "go-fish", nil, client.RunOptions{
+		Stdout: stdout,
+		Env:    []string{"SHED_TEST_VAR=foo"},
+	})
+	if err != nil {
+		t.Errorf("want nil error, got %v", err)
+	}
+	if stdout.String() != "foo\n" {
+		t.Errorf("got output %q, want %q", stdout.String(), "foo\n")
+	}
+}
+
+func TestRunExtraEnv(t *testing.T) {
+	s := newScriptShed(t, "#!/bin/sh\necho \"$SHED_TEST_VAR $SHED_OTHER_VAR\"\n", "")
+	env := []string{"SHED_TEST_VAR=foo", "SHED_OTHER_VAR=bar"}
+	stdout := &bytes.Buffer{}
+	_, err := s.Run(context.Background(), "go-fish", nil, client.RunOptions{
+		Stdout:   stdout,
+		Env:      env,
+		ExtraEnv: []string{"SHED_TEST_VAR=baz"},
+	})
+	if err != nil {
+		t.Errorf("want nil error, got %v", err)
+	}
+	if stdout.String() != "baz bar\n" {
+		t.Errorf("got output %q, want %q", stdout.String(), "baz bar\n")
+	}
+	if env[0] != "SHED_TEST_VAR=foo" || len(env) != 2 {
+		t.Errorf("want Env to be unchanged, got %v", env)
+	}
+}
+
+func TestRunTrust(t *testing.T) {
+	trustPath := filepath.Join(t.TempDir(), client.TrustFileName)
+	var asked []string
+	trusted := false
+	s := newScriptShed(t, "#!/bin/sh\necho ran\n", "", client.WithTrust(trustPath, func(tl tool.Tool, mod string) (bool, error) {
+		asked = append(asked, mod)
+		return trusted, nil
+	}))
+
+	stdout := &bytes.Buffer{}
+	report, err := s.Run(context.Background(), "go-fish", nil, client.RunOptions{Stdout: stdout})
+	if !errors.Is(err, client.ErrUntrusted) {
+		t.Fatalf("got error %v, want %v", err, client.ErrUntrusted)
+	}
+	if report != nil || stdout.Len() != 0 {
+		t.Errorf("want untrusted tool to not be run, got output %q", stdout.String())
+	}
+
+	// Once trusted, the decision is remembered
+	trusted = true
+	for i := 0; i < 2; i++ {
+		if _, err := s.Run(context.Background(), "go-fish", nil, client.RunOptions{Stdout: stdout}); err != nil {
+			t.Fatalf("want nil error, got %v", err)
+		}
+	}
+	if stdout.String() != "ran\nran\n" {
+		t.Errorf("got output %q, want %q", stdout.String(), "ran\nran\n")
+	}
+	want := []string{"github.com/cszatmary/go-fish", "github.com/cszatmary/go-fish"}
+	if !reflect.DeepEqual(asked, want) {
+		t.Errorf("got asked %v, want %v", asked, want)
+	}
+}
+
+func TestRunPinnedDir(t *testing.T) {
+	s := newScriptShed(t, "#!/bin/sh\npwd -P\necho \"$SHED_ORIGINAL_DIR\"\n", `{"tools": {"go-fish": {"dir": "sub"}}}`)
+	// The shed was created with the cache in the same directory as the config file
+	root := s.CacheDir()
+	pinnedDir := filepath.Join(root, "sub")
+	if err := os.Mkdir(pinnedDir, 0o755); err != nil {
+		t.Fatalf("failed to create directory %s: %v", pinnedDir, err)
+	}
+	invokedDir := t.TempDir()
+
+	stdout := &bytes.Buffer{}
+	_, err := s.Run(context.Background(), "go-fish", nil, client.RunOptions{
+		Stdout: stdout,
+		Dir:    invokedDir,
+	})
+	if err != nil {
+		t.Errorf("want nil error, got %v", err)
+	}
+
+	realPinnedDir, err := filepath.EvalSymlinks(pinnedDir)
+	if err != nil {
+		t.Fatalf("failed to resolve %s: %v", pinnedDir, err)
+	}
+	want := realPinnedDir + "\n" + invokedDir + "\n"
+	if stdout.String() != want {
+		t.Errorf("got output %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestRenamedTool(t *testing.T) {
+	s := newScriptShed(t, "#!/bin/sh\necho \"$@\"\n", `{
+  "renames": {"fish": "go-fish"},
+  "tasks": {"swim": {"tool": "fish"}}
+}`)
+	if _, err := s.ToolPath("fish"); err != nil {
+		t.Errorf("want nil error for renamed tool, got %v", err)
+	}
+	var stdout bytes.Buffer
+	_, err := s.Run(context.Background(), "fish", []string{"hello"}, client.RunOptions{Stdout: &stdout})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if got := stdout.String(); got != "hello\n" {
+		t.Errorf("got output %q, want %q", got, "hello\n")
+	}
+
+	makefilePath := filepath.Join(s.CacheDir(), "Makefile")
+	makefile := "lint:\n\tshed run fish run ./...\n\tshed run fisher\n\techo fish\n"
+	if err := ioutil.WriteFile(makefilePath, []byte(makefile), 0o644); err != nil {
+		t.Fatalf("failed to write Makefile: %v", err)
+	}
+	configPath := filepath.Join(s.CacheDir(), config.ProjectFileName)
+	modified, err := s.FixNames(makefilePath, configPath)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if want := []string{makefilePath, configPath}; !reflect.DeepEqual(modified, want) {
+		t.Errorf("got modified files %v, want %v", modified, want)
+	}
+
+	data, err := ioutil.ReadFile(makefilePath)
+	if err != nil {
+		t.Fatalf("failed to read Makefile: %v", err)
+	}
+	wantMakefile := "lint:\n\tshed run go-fish run ./...\n\tshed run fisher\n\techo fish\n"
+	if string(data) != wantMakefile {
+		t.Errorf("got Makefile %q, want %q", data, wantMakefile)
+	}
+	data, err = ioutil.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	if !bytes.Contains(data, []byte(`"swim": {"tool": "go-fish"}`)) {
+		t.Errorf("want task tool to be renamed, got config %s", data)
+	}
+}
@@ -0,0 +1,303 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/module"
+)
+
+// sbomTool is a tool in an SBOM along with the modules used to build it.
+type sbomTool struct {
+	tool.Tool
+	// mods are the modules used to build the tool, see cache.Cache.BuildModules.
+	// They are empty for release tools, since they aren't built.
+	mods []module.Version
+}
+
+// sbomTools returns the tools in the lockfile with the modules used to build them.
+// The tools must be installed, since the modules are read from the cache.
+func (s *Shed) sbomTools() ([]sbomTool, error) {
+	if s.cache == nil {
+		return nil, ErrNoCache
+	}
+	var tools []sbomTool
+	var errs lockfile.ErrorList
+	for _, t := range s.List() {
+		if t.IsRelease() {
+			tools = append(tools, sbomTool{Tool: t})
+			continue
+		}
+		modPath, err := s.cache.ModulePath(t)
+		if err != nil {
+			errs = append(errs, errors.WithMessagef(err, "failed to export tool %s", t))
+			continue
+		}
+		t.ModulePath = modPath
+		mods, err := s.cache.BuildModules(t)
+		if err != nil {
+			errs = append(errs, errors.WithMessagef(err, "failed to find modules of tool %s, it must be installed first", t))
+			continue
+		}
+		tools = append(tools, sbomTool{Tool: t, mods: mods})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return tools, nil
+}
+
+// purl returns the package URL of the module path at version, ex: 'pkg:golang/golang.org/x/tools@v0.1.0'.
+// If subpath is not empty, it is the package in the module.
+func purl(path, version, subpath string) string {
+	var sb strings.Builder
+	sb.WriteString("pkg:golang/")
+	for i, seg := range strings.Split(path, "/") {
+		if i > 0 {
+			sb.WriteByte('/')
+		}
+		sb.WriteString(purlEscape(seg))
+	}
+	if version != "" {
+		sb.WriteString("@" + purlEscape(version))
+	}
+	if subpath != "" {
+		sb.WriteString("#" + subpath)
+	}
+	return sb.String()
+}
+
+// purlEscape escapes s for use in a package URL. Unlike in other URLs, '+' must
+// be escaped, which is used by versions like 'v2.0.0+incompatible'.
+func purlEscape(s string) string {
+	return strings.ReplaceAll(url.PathEscape(s), "+", "%2B")
+}
+
+// toolPURL returns the package URL of t, or an empty string if t is a release tool.
+func toolPURL(t tool.Tool) string {
+	if t.IsRelease() {
+		return ""
+	}
+	// ModulePath is always set by sbomTools, so this can't fail
+	pkgDir, _ := t.PackageDir()
+	return purl(t.ModulePath, t.Version, pkgDir)
+}
+
+// sbomTimestamp returns the time an SBOM is created at, formatted as required by both formats.
+func sbomTimestamp(o exportOptions) string {
+	created := o.time
+	if created.IsZero() {
+		created = time.Now()
+	}
+	return created.UTC().Format(time.RFC3339)
+}
+
+// writeSBOM writes the JSON encoding of doc to w.
+func writeSBOM(w io.Writer, doc interface{}) error {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return errors.Wrap(err, "failed to serialize SBOM")
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// CycloneDX 1.5 JSON, see https://cyclonedx.org/docs/1.5/json.
+type cdxBOM struct {
+	BOMFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	Version      int             `json:"version"`
+	Metadata     cdxMetadata     `json:"metadata"`
+	Components   []cdxComponent  `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies"`
+}
+
+type cdxMetadata struct {
+	Timestamp string   `json:"timestamp"`
+	Tools     cdxTools `json:"tools"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	BOMRef  string `json:"bom-ref,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	PURL    string `json:"purl,omitempty"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+func (s *Shed) exportCycloneDX(w io.Writer, o exportOptions) error {
+	tools, err := s.sbomTools()
+	if err != nil {
+		return err
+	}
+	bom := cdxBOM{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.5",
+		Version:     1,
+		Metadata: cdxMetadata{
+			Timestamp: sbomTimestamp(o),
+			Tools: cdxTools{Components: []cdxComponent{
+				{Type: "application", Name: "shed", Version: o.shedVersion},
+			}},
+		},
+		Components:   []cdxComponent{},
+		Dependencies: []cdxDependency{},
+	}
+	for _, t := range tools {
+		// The package URL of a tool at the root of its module is the same as the module's, so tools use their own refs
+		ref := "tool:" + t.String()
+		bom.Components = append(bom.Components, cdxComponent{
+			BOMRef:  ref,
+			Type:    "application",
+			Name:    t.ImportPath,
+			Version: t.Version,
+			PURL:    toolPURL(t.Tool),
+		})
+		dep := cdxDependency{Ref: ref, DependsOn: []string{}}
+		for _, m := range t.mods {
+			dep.DependsOn = append(dep.DependsOn, purl(m.Path, m.Version, ""))
+		}
+		bom.Dependencies = append(bom.Dependencies, dep)
+	}
+	for _, m := range sbomModules(tools) {
+		p := purl(m.Path, m.Version, "")
+		bom.Components = append(bom.Components, cdxComponent{
+			BOMRef:  p,
+			Type:    "library",
+			Name:    m.Path,
+			Version: m.Version,
+			PURL:    p,
+		})
+	}
+	return writeSBOM(w, bom)
+}
+
+// SPDX 2.3 JSON, see https://spdx.github.io/spdx-spec/v2.3.
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SPDXID           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// spdxNoAssertion is used for required SPDX fields whose value isn't known.
+const spdxNoAssertion = "NOASSERTION"
+
+func (s *Shed) exportSPDX(w io.Writer, o exportOptions) error {
+	tools, err := s.sbomTools()
+	if err != nil {
+		return err
+	}
+	creator := "Tool: shed"
+	if o.shedVersion != "" {
+		creator += "-" + o.shedVersion
+	}
+	doc := spdxDocument{
+		SPDXVersion:   "SPDX-2.3",
+		DataLicense:   "CC0-1.0",
+		SPDXID:        "SPDXRef-DOCUMENT",
+		Name:          "shed-tools",
+		CreationInfo:  spdxCreationInfo{Created: sbomTimestamp(o), Creators: []string{creator}},
+		Packages:      []spdxPackage{},
+		Relationships: []spdxRelationship{},
+	}
+	pkg := func(id, name, version, purl string) spdxPackage {
+		p := spdxPackage{SPDXID: id, Name: name, VersionInfo: version, DownloadLocation: spdxNoAssertion}
+		if purl != "" {
+			p.ExternalRefs = []spdxExternalRef{{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: purl}}
+		}
+		return p
+	}
+	// SPDX IDs can only contain letters, digits, '.', and '-', so use the position of each package
+	modIDs := make(map[module.Version]string)
+	mods := sbomModules(tools)
+	for i, m := range mods {
+		modIDs[m] = fmt.Sprintf("SPDXRef-Module-%d", i+1)
+	}
+	// The namespace must be unique for each document, so use a hash of its contents
+	h := sha256.New()
+	io.WriteString(h, doc.CreationInfo.Created) //nolint:errcheck
+	for i, t := range tools {
+		id := fmt.Sprintf("SPDXRef-Tool-%d", i+1)
+		doc.Packages = append(doc.Packages, pkg(id, t.ImportPath, t.Version, toolPURL(t.Tool)))
+		doc.Relationships = append(doc.Relationships, spdxRelationship{SPDXElementID: doc.SPDXID, RelationshipType: "DESCRIBES", RelatedSPDXElement: id})
+		for _, m := range t.mods {
+			doc.Relationships = append(doc.Relationships, spdxRelationship{SPDXElementID: id, RelationshipType: "DEPENDS_ON", RelatedSPDXElement: modIDs[m]})
+		}
+		fmt.Fprintf(h, "\x00%s", t)
+	}
+	for _, m := range mods {
+		doc.Packages = append(doc.Packages, pkg(modIDs[m], m.Path, m.Version, purl(m.Path, m.Version, "")))
+		fmt.Fprintf(h, "\x00%s@%s", m.Path, m.Version)
+	}
+	doc.DocumentNamespace = "https://spdx.org/spdxdocs/shed-tools-" + hex.EncodeToString(h.Sum(nil)[:16])
+	return writeSBOM(w, doc)
+}
+
+// sbomModules returns the modules used to build any of tools, sorted by path and version.
+func sbomModules(tools []sbomTool) []module.Version {
+	seen := make(map[module.Version]bool)
+	var mods []module.Version
+	for _, t := range tools {
+		for _, m := range t.mods {
+			if !seen[m] {
+				seen[m] = true
+				mods = append(mods, m)
+			}
+		}
+	}
+	module.Sort(mods)
+	return mods
+}
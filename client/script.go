@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// scriptPath returns the path of the script file p relative to the project root, using forward slashes,
+// as used in the lockfile. p can be absolute or relative to the current directory.
+func (s *Shed) scriptPath(p string) (string, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get absolute path of %s", p)
+	}
+	root, err := filepath.Abs(s.projectRoot())
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get absolute path of %s", s.projectRoot())
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.Errorf("script %s is not in the project %s", p, root)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// readScript reads the script at rel, a path relative to the project root.
+func (s *Shed) readScript(rel string) ([]byte, error) {
+	p := filepath.Join(s.projectRoot(), filepath.FromSlash(rel))
+	src, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read script %s", p)
+	}
+	return src, nil
+}
+
+// scriptTool returns the tool used to refer to the script at rel in a RunReport. It has the path
+// of the script as its import path and the name of the file without '.go' as its name.
+func scriptTool(rel string) tool.Tool {
+	return tool.Tool{ImportPath: rel, Alias: strings.TrimSuffix(filepath.Base(rel), ".go")}
+}
+
+// PinScript adds the Go script at p to the lockfile, along with the latest versions of the modules
+// it needs. A script is a single file main package in the project, ex: a release or code generation
+// script, that is run with RunScript. p can be absolute or relative to the current directory.
+// If the script is already in the lockfile, its modules are resolved again, which upgrades them.
+//
+// The provided context is used to terminate the resolution if the context becomes
+// done before it completes on its own.
+func (s *Shed) PinScript(ctx context.Context, p string) (lockfile.Script, error) {
+	if s.cache == nil {
+		return lockfile.Script{}, ErrNoCache
+	}
+	rel, err := s.scriptPath(p)
+	if err != nil {
+		return lockfile.Script{}, err
+	}
+	src, err := s.readScript(rel)
+	if err != nil {
+		return lockfile.Script{}, err
+	}
+	s.logger.Debugf("Resolving modules of script %s", rel)
+	requires, err := s.cache.ResolveScript(ctx, src, cache.InstallEnv(s.installEnv(scriptTool(rel))...))
+	if err != nil {
+		return lockfile.Script{}, errors.WithMessagef(err, "failed to pin script %s", rel)
+	}
+	sc := lockfile.Script{Path: rel, Requires: requires}
+	err = s.updateLockfile(ctx, func() error {
+		return s.lf.PutScript(sc)
+	})
+	if err != nil {
+		return lockfile.Script{}, err
+	}
+	return sc, nil
+}
+
+// UnpinScript removes the Go script at p from the lockfile. p can be absolute or relative to
+// the current directory. If the script is not in the lockfile, the error matches lockfile.ErrNotFound.
+func (s *Shed) UnpinScript(ctx context.Context, p string) error {
+	rel, err := s.scriptPath(p)
+	if err != nil {
+		return err
+	}
+	return s.updateLockfile(ctx, func() error {
+		if _, err := s.lf.GetScript(rel); err != nil {
+			return err
+		}
+		s.lf.DeleteScript(rel)
+		return nil
+	})
+}
+
+// Scripts returns the Go scripts in the lockfile sorted by path.
+func (s *Shed) Scripts() []lockfile.Script {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lf.Scripts()
+}
+
+// RunScript builds the Go script at p with the modules in the lockfile, if it wasn't already built,
+// and runs it passing args to it. p can be absolute or relative to the current directory.
+// The script must have been added to the lockfile with PinScript, otherwise the error matches
+// lockfile.ErrNotFound. If the script now needs other modules, the error matches cache.ErrScriptRequires,
+// and the script must be pinned again.
+//
+// The script is run like a tool with Run, except that no config applies to it and it doesn't need to
+// be trusted, since it is part of the project. The tool in the returned RunReport has the path of
+// the script as its import path and the name of the file without '.go' as its name.
+func (s *Shed) RunScript(ctx context.Context, p string, args []string, opts RunOptions) (*RunReport, error) {
+	if s.cache == nil {
+		return nil, ErrNoCache
+	}
+	rel, err := s.scriptPath(p)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	sc, err := s.lf.GetScript(rel)
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, errors.WithMessage(err, "scripts must be pinned before they are run")
+	}
+	src, err := s.readScript(rel)
+	if err != nil {
+		return nil, err
+	}
+	t := scriptTool(rel)
+	binPath, err := s.cache.BuildScript(ctx, src, sc.Requires, cache.InstallEnv(s.installEnv(t)...))
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to build script %s", rel)
+	}
+	return s.runBinary(ctx, t, binPath, args, resolveRunSettings(opts), opts)
+}
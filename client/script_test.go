@@ -0,0 +1,156 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/lockfile"
+	"golang.org/x/mod/module"
+)
+
+// scriptGo wraps a Go instance and replaces the binaries of scripts with a shell script
+// that echoes its arguments, counting the number of builds.
+type scriptGo struct {
+	cache.Go
+	mu     sync.Mutex
+	builds int
+}
+
+func (g *scriptGo) Build(ctx context.Context, pkg, outPath, dir string, flags, env []string) error {
+	if err := g.Go.Build(ctx, pkg, outPath, dir, flags, env); err != nil || pkg != "." {
+		return err
+	}
+	g.mu.Lock()
+	g.builds++
+	g.mu.Unlock()
+	if err := ioutil.WriteFile(outPath, []byte("#!/bin/sh\necho script \"$@\"\n"), 0o755); err != nil {
+		return err
+	}
+	// WriteFile doesn't change the permissions of existing files
+	return os.Chmod(outPath, 0o755)
+}
+
+func TestScripts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("script tests use shell scripts")
+	}
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	createLockfile(t, lockfilePath, nil)
+	scriptPath := filepath.Join(td, "scripts", "release.go")
+	src := `//go:build ignore
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/cszatmary/go-fish/fish"
+)
+
+func main() {
+	fmt.Println(fish.Version)
+}
+`
+	if err := os.MkdirAll(filepath.Dir(scriptPath), 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := ioutil.WriteFile(scriptPath, []byte(src), 0o644); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	g := &scriptGo{Go: mockGo}
+	s, err := client.NewShed(
+		client.WithLockfilePath(lockfilePath),
+		client.WithCache(cache.New(filepath.Join(td, "cache"), cache.WithGo(g))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	if _, err := s.RunScript(context.Background(), scriptPath, nil, client.RunOptions{}); !errors.Is(err, lockfile.ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, lockfile.ErrNotFound)
+	}
+	sc, err := s.PinScript(context.Background(), scriptPath)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := lockfile.Script{
+		Path:     "scripts/release.go",
+		Requires: []module.Version{{Path: "github.com/cszatmary/go-fish", Version: "v0.1.0"}},
+	}
+	if !reflect.DeepEqual(sc, want) {
+		t.Errorf("got script %+v, want %+v", sc, want)
+	}
+	lf := readLockfile(t, lockfilePath)
+	if got, err := lf.GetScript("scripts/release.go"); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("got script %+v in lockfile with error %v, want %+v", got, err, want)
+	}
+
+	// Built once, then reused
+	for i := 0; i < 2; i++ {
+		var stdout bytes.Buffer
+		report, err := s.RunScript(context.Background(), scriptPath, []string{"v1.0.0"}, client.RunOptions{Stdout: &stdout})
+		if err != nil {
+			t.Fatalf("want nil error, got %v", err)
+		}
+		if got := stdout.String(); got != "script v1.0.0\n" {
+			t.Errorf("got output %q, want %q", got, "script v1.0.0\n")
+		}
+		if name := report.Tool.Name(); name != "release" {
+			t.Errorf("got name %s, want release", name)
+		}
+	}
+	if g.builds != 1 {
+		t.Errorf("got %d builds, want 1", g.builds)
+	}
+
+	// The script needs a module that isn't locked
+	src2 := `package main
+
+import (
+	"github.com/Shopify/ejson"
+	"github.com/cszatmary/go-fish/fish"
+)
+
+func main() {
+	ejson.Encrypt(fish.Version)
+}
+`
+	if err := ioutil.WriteFile(scriptPath, []byte(src2), 0o644); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	if _, err := s.RunScript(context.Background(), scriptPath, nil, client.RunOptions{}); !errors.Is(err, cache.ErrScriptRequires) {
+		t.Errorf("got error %v, want %v", err, cache.ErrScriptRequires)
+	}
+	sc, err = s.PinScript(context.Background(), scriptPath)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if len(sc.Requires) != 2 {
+		t.Errorf("got requires %v, want 2 modules", sc.Requires)
+	}
+	if _, err := s.RunScript(context.Background(), scriptPath, nil, client.RunOptions{}); err != nil {
+		t.Errorf("want nil error, got %v", err)
+	}
+
+	if err := s.UnpinScript(context.Background(), scriptPath); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if scripts := s.Scripts(); len(scripts) != 0 {
+		t.Errorf("got scripts %v, want none", scripts)
+	}
+}
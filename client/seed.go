@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/getshiphub/shed/remote"
+	"github.com/pkg/errors"
+)
+
+// Environment variables containing the credentials used to authenticate with OCI registries.
+const (
+	RegistryUsernameEnvVar = "SHED_REGISTRY_USERNAME"
+	RegistryPasswordEnvVar = "SHED_REGISTRY_PASSWORD"
+)
+
+// CacheImageMediaType is the media type of the config of cache images created by PublishCacheImage.
+// It identifies the image as a shed cache instead of a container image.
+const CacheImageMediaType = "application/vnd.getshiphub.shed.cache.config.v1+json"
+
+// WithOCIClient sets the client used to pull and push cache images.
+// By default, a client that authenticates using the credentials from
+// RegistryUsernameEnvVar and RegistryPasswordEnvVar is used.
+func WithOCIClient(c *remote.OCIClient) Option {
+	return func(s *Shed) {
+		s.ociClient = c
+	}
+}
+
+func (s *Shed) getOCIClient() *remote.OCIClient {
+	if s.ociClient != nil {
+		return s.ociClient
+	}
+	return &remote.OCIClient{
+		Username: os.Getenv(RegistryUsernameEnvVar),
+		Password: os.Getenv(RegistryPasswordEnvVar),
+	}
+}
+
+// SeedCache pulls the OCI image ref, ex: 'ghcr.io/org/toolcache:latest', and adds the prebuilt
+// tools it contains to the cache. Each layer of the image must be an archive created by
+// cache.Cache.Export, such as an image created by PublishCacheImage. Tools already in the cache
+// are left as is. SeedCache returns the number of tools added to the cache.
+func (s *Shed) SeedCache(ctx context.Context, ref string) (int, error) {
+	if s.cache == nil {
+		return 0, ErrNoCache
+	}
+	r, err := remote.ParseOCIReference(ref)
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	err = s.getOCIClient().Pull(ctx, r, func(layer io.Reader) error {
+		n, err := s.cache.Import(ctx, layer)
+		total += n
+		return err
+	})
+	if err != nil {
+		return total, errors.WithMessagef(err, "failed to seed cache from %s", ref)
+	}
+	s.logger.Debugf("Seeded cache with %d tools from %s", total, ref)
+	return total, nil
+}
+
+// PublishCacheImage exports all the tools in the cache and pushes them as the OCI image ref,
+// which can then be used with SeedCache. PublishCacheImage returns the number of tools published.
+func (s *Shed) PublishCacheImage(ctx context.Context, ref string) (int, error) {
+	if s.cache == nil {
+		return 0, ErrNoCache
+	}
+	r, err := remote.ParseOCIReference(ref)
+	if err != nil {
+		return 0, err
+	}
+	// Caches can be large so write to a file instead of keeping it in memory
+	f, err := ioutil.TempFile("", "shed-cache-")
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create temp file")
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	n, err := s.cache.Export(f)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, errors.Errorf("no tools in cache %s to publish", s.cache.Dir())
+	}
+	if err := s.getOCIClient().Push(ctx, r, CacheImageMediaType, f); err != nil {
+		return 0, errors.WithMessagef(err, "failed to publish cache to %s", ref)
+	}
+	s.logger.Debugf("Published %d tools to %s", n, ref)
+	return n, nil
+}
@@ -0,0 +1,122 @@
+package client_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/remote"
+	"github.com/getshiphub/shed/tool"
+)
+
+// newRegistry creates an in memory OCI registry that doesn't require authentication.
+func newRegistry(t *testing.T) *httptest.Server {
+	var mu sync.Mutex
+	store := make(map[string][]byte)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		p := r.URL.Path
+		switch {
+		case r.Method == http.MethodPost:
+			w.Header().Set("Location", p+"upload")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPut:
+			data, _ := ioutil.ReadAll(r.Body)
+			if d := r.URL.Query().Get("digest"); d != "" {
+				// Blobs are served from their digest
+				p = p[:strings.Index(p, "/blobs/")] + "/blobs/" + d
+			}
+			store[p] = data
+			w.WriteHeader(http.StatusCreated)
+		default:
+			data, ok := store[p]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data) //nolint:errcheck
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSeedCache(t *testing.T) {
+	srv := newRegistry(t)
+	ociClient := &remote.OCIClient{Client: srv.Client()}
+	ref := strings.TrimPrefix(srv.URL, "https://") + "/org/toolcache:latest"
+	tools := []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
+	}
+
+	// Publish the tools from one cache
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	createLockfile(t, lockfilePath, tools)
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	s, err := client.NewShed(
+		client.WithLockfilePath(lockfilePath),
+		client.WithCache(cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo))),
+		client.WithOCIClient(ociClient),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	installSet, err := s.Install()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	n, err := s.PublishCacheImage(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if n != len(tools) {
+		t.Errorf("got %d tools published, want %d", n, len(tools))
+	}
+
+	// Seed a new cache with them
+	s, err = client.NewShed(
+		client.WithLockfilePath(lockfilePath),
+		client.WithCacheDir(filepath.Join(td, "seeded")),
+		client.WithOCIClient(ociClient),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	n, err = s.SeedCache(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if n != len(tools) {
+		t.Errorf("got %d tools seeded, want %d", n, len(tools))
+	}
+	for _, tl := range tools {
+		if _, err := s.ToolPath(tl.ImportPath); err != nil {
+			t.Errorf("want nil error, got %v", err)
+		}
+	}
+
+	// Seeding again doesn't replace the tools
+	n, err = s.SeedCache(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if n != 0 {
+		t.Errorf("got %d tools seeded, want 0", n)
+	}
+}
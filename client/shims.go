@@ -0,0 +1,246 @@
+package client
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+
+	"github.com/getshiphub/shed/internal/util"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/semver"
+)
+
+// ShimsDirName is the name of the directory in ProjectDirName where shims are generated by default.
+const ShimsDirName = "bin"
+
+// shimMarker is in every shim, so that shims can be told apart from other files in the directory.
+const shimMarker = "Generated by shed"
+
+// ShimOption customizes GenerateShims.
+type ShimOption func(*shimOptions)
+
+type shimOptions struct {
+	shedPath     string
+	nameTemplate string
+	goos         string
+}
+
+// ShimShedPath sets the path of the shed executable that shims run. By default shims run 'shed',
+// so it must be in PATH. Using an absolute path makes the shims work without shed in PATH.
+func ShimShedPath(p string) ShimOption {
+	return func(o *shimOptions) {
+		o.shedPath = p
+	}
+}
+
+// ShimNameTemplate sets the text/template used to name the shim of each tool, ex: '{{.Name}}-{{.MajorVersion}}'.
+// The template is executed with a ShimNameData for each tool. This allows tools with the same name, like major
+// versions of a tool that moved to a new import path, to have a shim each. It takes precedence over the name
+// template in the project config. By default shims are named after their tool.
+func ShimNameTemplate(tmpl string) ShimOption {
+	return func(o *shimOptions) {
+		o.nameTemplate = tmpl
+	}
+}
+
+// ShimsForOS sets the operating system the shims are created for, using the values of GOOS,
+// ex: 'windows' to create shims in a directory that is shared with a windows machine, or to check
+// the windows shims from CI on another OS. By default shims are created for the running OS.
+func ShimsForOS(goos string) ShimOption {
+	return func(o *shimOptions) {
+		o.goos = goos
+	}
+}
+
+// ShimNameData is the data a shim name template is executed with.
+type ShimNameData struct {
+	// Name is the name of the tool, see tool.Tool.Name.
+	Name string
+	// ImportPath is the import path of the tool.
+	ImportPath string
+	// Version is the version of the tool, ex: 'v2.1.0'.
+	Version string
+	// MajorVersion is the major version of the tool, ex: 'v2'.
+	MajorVersion string
+}
+
+// shimName returns the name of the shim for t using tmpl, or the name of t if tmpl is nil.
+func shimName(tmpl *template.Template, t tool.Tool) (string, error) {
+	name := t.Name()
+	if tmpl != nil {
+		var b strings.Builder
+		err := tmpl.Execute(&b, ShimNameData{
+			Name:         t.Name(),
+			ImportPath:   t.ImportPath,
+			Version:      t.Version,
+			MajorVersion: semver.Major(t.Version),
+		})
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to name shim for %s", t.ImportPath)
+		}
+		name = b.String()
+	}
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", errors.Errorf("invalid shim name %q for %s", name, t.ImportPath)
+	}
+	return name, nil
+}
+
+// caseInsensitiveOS reports whether the file systems of goos are usually case-insensitive,
+// in which case shim names that only differ in case are the same file.
+func caseInsensitiveOS(goos string) bool {
+	return goos == "windows" || goos == "darwin"
+}
+
+// ShimsDir returns the directory where GenerateShims creates shims by default, '.shed/bin' in the project root.
+func (s *Shed) ShimsDir() string {
+	return filepath.Join(s.projectRoot(), ProjectDirName, ShimsDirName)
+}
+
+// GenerateShims creates a shim in dir for each tool in the lockfile, so that dir can be added to PATH
+// to run the tools by name, ex: from editors and scripts. If dir is empty, ShimsDir is used. Each shim is
+// a small script named after the tool that runs the tool with 'shed run'. On windows, each tool has a '.cmd'
+// shim for cmd.exe and a '.ps1' shim for PowerShell, see ShimsForOS. The names can be changed with ShimNameTemplate or the shims name in the project config.
+// Since the version is looked up when the shim runs, shims always run the version in the lockfile
+// and only need to be generated again when tools are added, removed, or renamed. Like 'shed run',
+// shims must be run from inside the project.
+//
+// Shims from a previous call for tools that are no longer in the lockfile are removed. Other files in dir
+// are left as is, unless they have the same name as a shim, in which case they are replaced. Symlinks are
+// replaced rather than followed, and ShimsDir must not contain symlinks. If multiple tools have the same name,
+// ignoring case on windows and macOS, the error is a lockfile.ErrorList and no shims are changed. GenerateShims returns the number of tools shims were created for.
+func (s *Shed) GenerateShims(dir string, opts ...ShimOption) (int, error) {
+	o := shimOptions{shedPath: "shed", goos: runtime.GOOS}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if dir == "" {
+		dir = s.ShimsDir()
+	}
+	if o.nameTemplate == "" && s.config.Shims != nil {
+		o.nameTemplate = s.config.Shims.Name
+	}
+	var tmpl *template.Template
+	if o.nameTemplate != "" {
+		var err error
+		tmpl, err = template.New("name").Option("missingkey=error").Parse(o.nameTemplate)
+		if err != nil {
+			return 0, errors.Wrap(err, "invalid shim name template")
+		}
+	}
+
+	shims := make(map[string][]byte)
+	names := make(map[string]bool)
+	var errs lockfile.ErrorList
+	for _, t := range s.List() {
+		name, err := shimName(tmpl, t)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		key := name
+		if caseInsensitiveOS(o.goos) {
+			key = strings.ToLower(name)
+		}
+		if names[key] {
+			errs = append(errs, errors.Wrapf(lockfile.ErrMultipleTools, "failed to create shim %s for %s", name, t.ImportPath))
+			continue
+		}
+		names[key] = true
+		if o.goos == "windows" {
+			shims[name+".cmd"] = cmdShimScript(o.shedPath, t.ImportPath)
+			shims[name+".ps1"] = ps1ShimScript(o.shedPath, t.ImportPath)
+			continue
+		}
+		shims[name] = shimScript(o.shedPath, t.ImportPath)
+	}
+	if len(errs) > 0 {
+		return 0, errs
+	}
+
+	if dir == s.ShimsDir() {
+		// The project may not be trusted, so make sure shims can't be written outside of it
+		if err := util.CheckNoSymlinks(s.projectRoot(), dir); err != nil {
+			return 0, errors.Wrap(err, "invalid shims directory")
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, errors.Wrapf(err, "failed to create directory %s", dir)
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to read directory %s", dir)
+	}
+	for _, e := range entries {
+		if _, ok := shims[e.Name()]; ok || !e.Mode().IsRegular() {
+			continue
+		}
+		p := filepath.Join(dir, e.Name())
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to read file %s", p)
+		}
+		if bytes.Contains(data, []byte(shimMarker)) {
+			if err := os.Remove(p); err != nil {
+				return 0, errors.Wrapf(err, "failed to remove old shim %s", p)
+			}
+		}
+	}
+	for name, data := range shims {
+		if err := writeShim(filepath.Join(dir, name), data); err != nil {
+			return 0, err
+		}
+	}
+	return len(names), nil
+}
+
+// writeShim writes the shim at p. It is written to a temp file that is renamed to p so that
+// if p is a symlink, it is replaced instead of writing to the file it points to.
+func writeShim(p string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(p), "."+filepath.Base(p)+".tmp*")
+	if err != nil {
+		return errors.Wrapf(err, "failed to write shim %s", p)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to write shim %s", p)
+	}
+	// TempFile creates files that are only accessible by the owner
+	if err := os.Chmod(f.Name(), 0o755); err != nil {
+		return errors.Wrapf(err, "failed to make shim %s executable", p)
+	}
+	if err := os.Rename(f.Name(), p); err != nil {
+		return errors.Wrapf(err, "failed to write shim %s", p)
+	}
+	return nil
+}
+
+// shimScript returns the contents of a shim that runs the tool with the given import path using shed at shedPath.
+func shimScript(shedPath, importPath string) []byte {
+	// Quote the path for the shell, a ' is written as '\''
+	quoted := "'" + strings.ReplaceAll(shedPath, "'", `'\''`) + "'"
+	return []byte("#!/bin/sh\n# " + shimMarker + ", do not edit.\nexec " + quoted + " run " + importPath + " \"$@\"\n")
+}
+
+// cmdShimScript returns the contents of a shim for cmd.exe, see shimScript.
+func cmdShimScript(shedPath, importPath string) []byte {
+	return []byte("@echo off\r\nrem " + shimMarker + ", do not edit.\r\n\"" + shedPath + "\" run " + importPath + " %*\r\n")
+}
+
+// ps1ShimScript returns the contents of a shim for PowerShell, see shimScript.
+// The exit code of the tool is passed on, since PowerShell doesn't do it for scripts.
+func ps1ShimScript(shedPath, importPath string) []byte {
+	// Quote the path for PowerShell, a ' is written as ''
+	quoted := "'" + strings.ReplaceAll(shedPath, "'", "''") + "'"
+	return []byte("# " + shimMarker + ", do not edit.\r\n& " + quoted + " run " + importPath + " @args\r\nexit $LASTEXITCODE\r\n")
+}
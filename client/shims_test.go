@@ -0,0 +1,266 @@
+package client_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/config"
+	"github.com/getshiphub/shed/internal/util"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+)
+
+func TestGenerateShims(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shim tests check shell scripts")
+	}
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58", Alias: "stringer-x"},
+	})
+	s, err := client.NewShed(client.WithLockfilePath(lockfilePath), client.WithNoCache())
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	dir := s.ShimsDir()
+	if want := filepath.Join(td, ".shed", "bin"); dir != want {
+		t.Errorf("got shims dir %s, want %s", dir, want)
+	}
+	// A shim for a tool that was removed, and a file that isn't a shim
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("failed to create shims dir: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "ejson"), []byte("#!/bin/sh\n# Generated by shed, do not edit.\n"), 0o755); err != nil {
+		t.Fatalf("failed to write old shim: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("keep me"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	n, err := s.GenerateShims("", client.ShimShedPath("/opt/it's/shed"))
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if n != 2 {
+		t.Errorf("got %d shims, want 2", n)
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read shims dir: %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	if got, want := strings.Join(names, ","), "go-fish,notes.txt,stringer-x"; got != want {
+		t.Errorf("got files %s, want %s", got, want)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "stringer-x"))
+	if err != nil {
+		t.Fatalf("failed to read shim: %v", err)
+	}
+	want := "#!/bin/sh\n# Generated by shed, do not edit.\nexec '/opt/it'\\''s/shed' run golang.org/x/tools/cmd/stringer \"$@\"\n"
+	if string(data) != want {
+		t.Errorf("got shim %q, want %q", data, want)
+	}
+}
+
+func TestGenerateShimsSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shim tests check shell scripts")
+	}
+	td := t.TempDir()
+	project := filepath.Join(td, "project")
+	if err := os.Mkdir(project, 0o755); err != nil {
+		t.Fatalf("failed to create project: %v", err)
+	}
+	lockfilePath := filepath.Join(project, "shed.lock")
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+	})
+	s, err := client.NewShed(client.WithLockfilePath(lockfilePath), client.WithNoCache())
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	target := filepath.Join(td, "target")
+	if err := ioutil.WriteFile(target, []byte("keep me"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	// A symlink with the name of a shim is replaced instead of being written through
+	dir := s.ShimsDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("failed to create shims dir: %v", err)
+	}
+	if err := os.Symlink(target, filepath.Join(dir, "go-fish")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	if _, err := s.GenerateShims(""); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if data, err := ioutil.ReadFile(target); err != nil || string(data) != "keep me" {
+		t.Errorf("got target %q, %v, want it unchanged", data, err)
+	}
+	info, err := os.Lstat(filepath.Join(dir, "go-fish"))
+	if err != nil {
+		t.Fatalf("failed to stat shim: %v", err)
+	}
+	if !info.Mode().IsRegular() || info.Mode().Perm() != 0o755 {
+		t.Errorf("got shim mode %s, want regular executable file", info.Mode())
+	}
+
+	// The shims dir can't be a symlink out of the project
+	if err := os.RemoveAll(filepath.Join(project, ".shed")); err != nil {
+		t.Fatalf("failed to remove shims dir: %v", err)
+	}
+	if err := os.Symlink(td, filepath.Join(project, ".shed")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	if _, err := s.GenerateShims(""); err == nil {
+		t.Error("want non-nil error, got nil")
+	}
+	if util.FileOrDirExists(filepath.Join(td, "bin")) {
+		t.Error("want shims to not be written outside of the project")
+	}
+}
+
+func TestGenerateShimsSameName(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58"},
+		{ImportPath: "example.org/z/random/stringer/v2/cmd/stringer", Version: "v2.1.0"},
+	})
+	s, err := client.NewShed(client.WithLockfilePath(lockfilePath), client.WithNoCache())
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	_, err = s.GenerateShims("")
+	var errs lockfile.ErrorList
+	if !errors.As(err, &errs) || !errors.Is(errs[0], lockfile.ErrMultipleTools) {
+		t.Errorf("got error %v, want %v", err, lockfile.ErrMultipleTools)
+	}
+	if _, err := os.Stat(s.ShimsDir()); !os.IsNotExist(err) {
+		t.Errorf("want shims dir to not be created, got %v", err)
+	}
+}
+
+func TestGenerateShimsWindows(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58"},
+	})
+	s, err := client.NewShed(client.WithLockfilePath(lockfilePath), client.WithNoCache())
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	dir := filepath.Join(td, "bin")
+	n, err := s.GenerateShims(dir, client.ShimShedPath(`C:\it's\shed.exe`), client.ShimsForOS("windows"))
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if n != 2 {
+		t.Errorf("got %d shims, want 2", n)
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read shims dir: %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if got, want := strings.Join(names, ","), "go-fish.cmd,go-fish.ps1,stringer.cmd,stringer.ps1"; got != want {
+		t.Errorf("got files %s, want %s", got, want)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"stringer.cmd", "@echo off\r\nrem Generated by shed, do not edit.\r\n\"C:\\it's\\shed.exe\" run golang.org/x/tools/cmd/stringer %*\r\n"},
+		{"stringer.ps1", "# Generated by shed, do not edit.\r\n& 'C:\\it''s\\shed.exe' run golang.org/x/tools/cmd/stringer @args\r\nexit $LASTEXITCODE\r\n"},
+	}
+	for _, tt := range tests {
+		data, err := ioutil.ReadFile(filepath.Join(dir, tt.name))
+		if err != nil {
+			t.Fatalf("failed to read shim: %v", err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("got shim %s %q, want %q", tt.name, data, tt.want)
+		}
+	}
+
+	// Names that only differ in case are the same file on windows
+	_, err = s.GenerateShims(filepath.Join(td, "upper"), client.ShimsForOS("windows"), client.ShimNameTemplate(`{{if eq .Name "go-fish"}}Stringer{{else}}{{.Name}}{{end}}`))
+	var errs lockfile.ErrorList
+	if !errors.As(err, &errs) || !errors.Is(errs[0], lockfile.ErrMultipleTools) {
+		t.Errorf("got error %v, want %v", err, lockfile.ErrMultipleTools)
+	}
+}
+
+func TestGenerateShimsNameTemplate(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58"},
+		{ImportPath: "example.org/z/random/stringer/v2/cmd/stringer", Version: "v2.1.0"},
+	})
+	cfg := `{"shims": {"name": "{{.Name}}@{{.Version}}"}}`
+	if err := ioutil.WriteFile(filepath.Join(td, config.ProjectFileName), []byte(cfg), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	s, err := client.NewShed(client.WithLockfilePath(lockfilePath), client.WithNoCache())
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts []client.ShimOption
+		want string
+	}{
+		{"config", nil, "stringer@v0.0.0-20201211185031-d93e913c1a58,stringer@v2.1.0"},
+		{"option", []client.ShimOption{client.ShimNameTemplate("{{.Name}}-{{.MajorVersion}}")}, "stringer-v0,stringer-v2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(td, tt.name)
+			if _, err := s.GenerateShims(dir, tt.opts...); err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+			entries, err := ioutil.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("failed to read shims dir: %v", err)
+			}
+			var names []string
+			for _, e := range entries {
+				if filepath.Ext(e.Name()) != ".ps1" {
+					names = append(names, strings.TrimSuffix(e.Name(), ".cmd"))
+				}
+			}
+			sort.Strings(names)
+			if got := strings.Join(names, ","); got != tt.want {
+				t.Errorf("got shims %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	for _, tmpl := range []string{"{{.Nope}}", "{{.ImportPath}}", "{{if false}}{{end}}"} {
+		if _, err := s.GenerateShims(filepath.Join(td, "invalid"), client.ShimNameTemplate(tmpl)); err == nil {
+			t.Errorf("want non-nil error for template %q, got nil", tmpl)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"bytes"
+
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/releasenotes"
+	"golang.org/x/mod/module"
+)
+
+// VersionChange is a tool whose version was changed by InstallSet.Apply.
+type VersionChange struct {
+	// Kind is whether the tool was added, removed, or changed to another version.
+	Kind lockfile.ChangeKind
+	// ImportPath is the import path of the tool.
+	ImportPath string
+	// OldVersion is the version of the tool before Apply. It is empty for added tools.
+	OldVersion string
+	// NewVersion is the version of the tool after Apply. It is empty for removed tools.
+	NewVersion string
+	// ReleaseURL is the GitHub release of NewVersion. It is empty if the module of the tool isn't hosted
+	// on GitHub or NewVersion is a pseudo-version. It isn't checked, so it may not exist.
+	ReleaseURL string
+	// CompareURL is the GitHub page listing the commits between OldVersion and NewVersion.
+	// It is only set for changed tools whose module is hosted on GitHub.
+	CompareURL string
+}
+
+// InstallSummary is how InstallSet.Apply changed the versions of the tools in the lockfile, see InstallSet.Summary.
+type InstallSummary struct {
+	// Changes are the tools that were added, removed, or changed to another version, sorted by import path.
+	// Tools that were installed again with the same version aren't included.
+	Changes []VersionChange
+}
+
+// Summary returns how the last call to Apply changed the versions of the tools in the lockfile, along with
+// links to their release notes where they can be derived from their modules, ex: to post them on the pull
+// request of an update. It returns nil if Apply hasn't been called or it didn't update the lockfile.
+func (is *InstallSet) Summary() *InstallSummary {
+	return is.summary
+}
+
+// summarizeChanges returns how the tools changed between the lockfiles old and new.
+func summarizeChanges(old, new *lockfile.Lockfile) *InstallSummary {
+	summary := &InstallSummary{}
+	for _, c := range lockfile.Diff(old, new) {
+		if c.Kind == lockfile.ChangeChanged && c.Old.Version == c.New.Version {
+			continue
+		}
+		vc := VersionChange{
+			Kind:       c.Kind,
+			ImportPath: c.ImportPath(),
+			OldVersion: c.Old.Version,
+			NewVersion: c.New.Version,
+		}
+		t := c.New
+		if c.Kind == lockfile.ChangeRemoved {
+			t = c.Old
+		}
+		modPath := t.ModulePath
+		if modPath == "" {
+			modPath = t.ImportPath
+		}
+		if vc.NewVersion != "" {
+			vc.ReleaseURL, _ = releasenotes.ReleaseURL(module.Version{Path: modPath, Version: vc.NewVersion})
+		}
+		if c.Kind == lockfile.ChangeChanged {
+			vc.CompareURL, _ = releasenotes.CompareURL(modPath, vc.OldVersion, vc.NewVersion)
+		}
+		summary.Changes = append(summary.Changes, vc)
+	}
+	return summary
+}
+
+// copyLockfile returns a copy of lf that isn't affected by changes to lf.
+func copyLockfile(lf *lockfile.Lockfile) (*lockfile.Lockfile, error) {
+	var buf bytes.Buffer
+	if _, err := lf.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return lockfile.Parse(&buf)
+}
@@ -0,0 +1,84 @@
+package client_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+)
+
+func TestInstallSummary(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.28.3"},
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.2"},
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58"},
+	})
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	s, err := client.NewShed(
+		client.WithLockfilePath(lockfilePath),
+		client.WithCache(cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	installSet, err := s.Install(
+		"github.com/golangci/golangci-lint/cmd/golangci-lint@v1.33.0",
+		"github.com/Shopify/ejson/cmd/ejson@none",
+		"github.com/cszatmary/go-fish@22d10c9b658df297b17b33c836a60fb943ef5a5f",
+	)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if installSet.Summary() != nil {
+		t.Errorf("got summary %+v before Apply, want nil", installSet.Summary())
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := &client.InstallSummary{Changes: []client.VersionChange{
+		{
+			Kind:       lockfile.ChangeRemoved,
+			ImportPath: "github.com/Shopify/ejson/cmd/ejson",
+			OldVersion: "v1.2.2",
+		},
+		{
+			Kind:       lockfile.ChangeAdded,
+			ImportPath: "github.com/cszatmary/go-fish",
+			NewVersion: "v0.0.0-20201203230243-22d10c9b658d",
+		},
+		{
+			Kind:       lockfile.ChangeChanged,
+			ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint",
+			OldVersion: "v1.28.3",
+			NewVersion: "v1.33.0",
+			ReleaseURL: "https://github.com/golangci/golangci-lint/releases/tag/v1.33.0",
+			CompareURL: "https://github.com/golangci/golangci-lint/compare/v1.28.3...v1.33.0",
+		},
+	}}
+	if got := installSet.Summary(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got summary %+v, want %+v", got, want)
+	}
+
+	// Installing the same versions again changes nothing
+	installSet, err = s.Install()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if got := installSet.Summary(); got == nil || len(got.Changes) != 0 {
+		t.Errorf("got summary %+v, want no changes", got)
+	}
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// Sync installs the tools in the lockfile whose binaries are missing from the cache, ex: after checking
+// out a branch that changed the version of a tool. It returns the tools that were installed, which is empty
+// if every tool was already installed. The lockfile is never changed, Apply is used with Frozen.
+//
+// Sync is meant to be run often, ex: on every make invocation or from a git hook, see InstallGitHooks.
+// Checking whether a tool is installed only looks at the cache, so nothing is resolved and the go command
+// isn't run unless a tool is missing. Because of this, binaries built with an older version of Go are
+// not rebuilt, use Rebuild with ToolPath for that.
+//
+// If the binary of a tool was modified or its module doesn't match the lockfile, the tool is not
+// reinstalled and the error is returned in a lockfile.ErrorList, since this needs to be looked into,
+// see Verify. opts are passed to Apply, ex: WithProgress.
+func (s *Shed) Sync(ctx context.Context, opts ...ApplyOption) ([]tool.Tool, error) {
+	if s.cache == nil {
+		return nil, ErrNoCache
+	}
+	var missing []tool.Tool
+	var errs lockfile.ErrorList
+	for _, t := range s.List() {
+		_, err := s.cache.ToolPath(t)
+		if errors.Is(err, cache.ErrStale) {
+			missing = append(missing, t)
+		} else if err != nil {
+			errs = append(errs, errors.WithMessagef(err, "failed to check tool %s", t))
+		}
+	}
+	if len(missing) == 0 {
+		if len(errs) > 0 {
+			return nil, errs
+		}
+		return nil, nil
+	}
+
+	s.logger.Debugf("Installing %d missing tools", len(missing))
+	is := &InstallSet{s: s, tools: missing}
+	if err := is.Apply(ctx, append(opts, Frozen())...); err != nil {
+		var applyErrs lockfile.ErrorList
+		if !errors.As(err, &applyErrs) {
+			return nil, err
+		}
+		return nil, append(errs, applyErrs...)
+	}
+	if len(errs) > 0 {
+		return missing, errs
+	}
+	return missing, nil
+}
@@ -0,0 +1,160 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/internal/util"
+	"github.com/getshiphub/shed/tool"
+)
+
+func TestSync(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.2"},
+	})
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	newShed := func() *client.Shed {
+		s, err := client.NewShed(
+			client.WithLockfilePath(lockfilePath),
+			client.WithCache(cache.New(td, cache.WithGo(mockGo))),
+		)
+		if err != nil {
+			t.Fatalf("failed to create shed client %v", err)
+		}
+		return s
+	}
+
+	installed, err := newShed().Sync(context.Background())
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if len(installed) != 2 {
+		t.Errorf("got %d installed tools, want 2", len(installed))
+	}
+
+	// Nothing is installed if every tool is installed
+	installed, err = newShed().Sync(context.Background())
+	if err != nil || len(installed) != 0 {
+		t.Errorf("got installed tools %v with error %v, want none", installed, err)
+	}
+
+	// Only the tool that changed is installed, ex: after checking out another branch
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.1.0"},
+	})
+	before, err := ioutil.ReadFile(lockfilePath)
+	if err != nil {
+		t.Fatalf("failed to read lockfile: %v", err)
+	}
+	s := newShed()
+	installed, err = s.Sync(context.Background())
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if len(installed) != 1 || installed[0].String() != "github.com/Shopify/ejson/cmd/ejson@v1.1.0" {
+		t.Errorf("got installed tools %v, want ejson@v1.1.0", installed)
+	}
+	if _, err := s.ToolPath("ejson"); err != nil {
+		t.Errorf("want ejson to be installed, got %v", err)
+	}
+	after, err := ioutil.ReadFile(lockfilePath)
+	if err != nil {
+		t.Fatalf("failed to read lockfile: %v", err)
+	}
+	if !bytes.Equal(after, before) {
+		t.Errorf("got lockfile\n%s\nwant it to be unchanged\n%s", after, before)
+	}
+
+	// A binary that was deleted is installed again
+	binPath, err := s.ToolPath("ejson")
+	if err != nil {
+		t.Fatalf("failed to get tool path %v", err)
+	}
+	if err := os.Remove(binPath); err != nil {
+		t.Fatalf("failed to remove binary %v", err)
+	}
+	installed, err = newShed().Sync(context.Background())
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if len(installed) != 1 || !util.FileOrDirExists(binPath) {
+		t.Errorf("got installed tools %v, want ejson to be installed again", installed)
+	}
+}
+
+func TestInstallGitHooks(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	td := t.TempDir()
+	if out, err := exec.Command("git", "init", td).CombinedOutput(); err != nil {
+		t.Fatalf("failed to create git repository: %v\n%s", err, out)
+	}
+	lockfilePath := filepath.Join(td, "tools", "shed.lock")
+	if err := os.Mkdir(filepath.Dir(lockfilePath), 0o755); err != nil {
+		t.Fatalf("failed to create dir %v", err)
+	}
+	createLockfile(t, lockfilePath, nil)
+	s, err := client.NewShed(client.WithLockfilePath(lockfilePath), client.WithNoCache())
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	postMerge := filepath.Join(td, ".git", "hooks", "post-merge")
+	if err := ioutil.WriteFile(postMerge, []byte("#!/bin/sh\necho merged\n"), 0o755); err != nil {
+		t.Fatalf("failed to write hook %v", err)
+	}
+	if _, err := s.InstallGitHooks(context.Background()); !errors.Is(err, client.ErrGitHookExists) {
+		t.Errorf("got error %v, want %v", err, client.ErrGitHookExists)
+	}
+	if err := os.Remove(postMerge); err != nil {
+		t.Fatalf("failed to remove hook %v", err)
+	}
+
+	paths, err := s.InstallGitHooks(context.Background())
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("got hooks %v, want 2", paths)
+	}
+	for _, p := range paths {
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			t.Fatalf("failed to read hook %v", err)
+		}
+		if !strings.Contains(string(data), "cd 'tools'") || !strings.Contains(string(data), "shed sync") {
+			t.Errorf("got hook %s\n%s\nwant it to run shed sync in tools", p, data)
+		}
+	}
+	// Installing again replaces the hooks
+	if _, err := s.InstallGitHooks(context.Background()); err != nil {
+		t.Errorf("want nil error, got %v", err)
+	}
+
+	removed, err := s.UninstallGitHooks(context.Background())
+	if err != nil || len(removed) != 2 {
+		t.Fatalf("got removed hooks %v with error %v, want 2", removed, err)
+	}
+	for _, p := range removed {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("want hook %s to be removed, got %v", p, err)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/getshiphub/shed/config"
+	"github.com/getshiphub/shed/features"
+	"github.com/getshiphub/shed/internal/ratelimit"
+	"github.com/getshiphub/shed/internal/taskcache"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/remote"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrTaskNotFound is returned when a task does not exist in the project config.
+var ErrTaskNotFound = errors.New("task not found")
+
+// ErrDependencyFailed is the error for a task that was not run because one of its dependencies failed.
+var ErrDependencyFailed = errors.New("dependency failed")
+
+// TaskResult contains the result of running a single task.
+type TaskResult struct {
+	// Name is the name of the task.
+	Name string
+	// Report is the report from running the task's tool. It is nil if
+	// the task has no tool or the task was not run.
+	Report *RunReport
+	// Cached reports whether the task was skipped because its inputs had not changed.
+	// If so, its outputs were restored from the cache.
+	Cached bool
+	// Err is the error that caused the task to fail, if any.
+	// If the task was not run because a dependency failed, Err will match ErrDependencyFailed.
+	Err error
+}
+
+// TaskReport contains the results of running a task and all of its dependencies.
+type TaskReport struct {
+	// Results contains the result of each task. It is ordered so that every task
+	// comes after all of its dependencies.
+	Results []TaskResult
+}
+
+// Failed returns the results of all tasks that failed. Tasks that were not
+// run because a dependency failed are not included.
+func (r *TaskReport) Failed() []TaskResult {
+	var failed []TaskResult
+	for _, res := range r.Results {
+		if res.Err != nil && !errors.Is(res.Err, ErrDependencyFailed) {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// taskOrder returns name and all of its transitive dependencies in an order such that
+// each task comes after all of its dependencies. The config guarantees there are no cycles.
+func (s *Shed) taskOrder(name string) ([]string, error) {
+	if _, ok := s.config.Tasks[name]; !ok {
+		return nil, errors.Wrapf(ErrTaskNotFound, "no task named %s", name)
+	}
+
+	var order []string
+	seen := make(map[string]bool)
+	var visit func(n string)
+	visit = func(n string) {
+		if seen[n] {
+			return
+		}
+		seen[n] = true
+		deps := append([]string(nil), s.config.Tasks[n].DependsOn...)
+		// Sort so the order is deterministic
+		sort.Strings(deps)
+		for _, dep := range deps {
+			visit(dep)
+		}
+		order = append(order, n)
+	}
+	visit(name)
+	return order, nil
+}
+
+// RunTask runs the task with the given name from the project config along with all of its
+// dependencies. Dependencies are always run before the tasks that depend on them. Tasks that
+// do not depend on each other are run in parallel. If a task fails, any tasks that depend on it
+// are not run. args are appended to the arguments of the named task, but not its dependencies.
+//
+// Any settings specified by a task take precedence over the ones for the tool.
+// Otherwise each task is run the same way as Run.
+//
+// The returned TaskReport contains the result of every task, even if an error is returned.
+// If no task exists with the given name, ErrTaskNotFound is returned.
+func (s *Shed) RunTask(ctx context.Context, taskName string, args []string, opts RunOptions) (*TaskReport, error) {
+	order, err := s.taskOrder(taskName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve all tools first so that we fail before running anything
+	// if the tasks reference tools that aren't installed
+	var errs lockfile.ErrorList
+	for _, name := range order {
+		task := s.config.Tasks[name]
+		if task.Tool == "" {
+			continue
+		}
+		if _, err := s.getTool(task.Tool); err != nil {
+			errs = append(errs, errors.WithMessagef(err, "failed to find tool for task %s", name))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	type node struct {
+		result TaskResult
+		done   chan struct{}
+	}
+	nodes := make(map[string]*node, len(order))
+	for _, name := range order {
+		nodes[name] = &node{result: TaskResult{Name: name}, done: make(chan struct{})}
+	}
+	for _, name := range order {
+		go func(name string) {
+			n := nodes[name]
+			defer close(n.done)
+			task := s.config.Tasks[name]
+			for _, dep := range task.DependsOn {
+				d := nodes[dep]
+				<-d.done
+				if d.result.Err != nil {
+					n.result.Err = errors.Wrapf(ErrDependencyFailed, "task %s depends on failed task %s", name, dep)
+					return
+				}
+			}
+			if task.Tool == "" {
+				return
+			}
+
+			var taskArgs []string
+			taskArgs = append(taskArgs, task.Args...)
+			if name == taskName {
+				taskArgs = append(taskArgs, args...)
+			}
+			n.result.Report, n.result.Cached, n.result.Err = s.runTask(ctx, name, task, taskArgs, opts)
+			if n.result.Err != nil {
+				n.result.Err = errors.WithMessagef(n.result.Err, "task %s failed", name)
+			}
+		}(name)
+	}
+
+	report := &TaskReport{}
+	for _, name := range order {
+		n := nodes[name]
+		<-n.done
+		report.Results = append(report.Results, n.result)
+	}
+	for _, res := range report.Failed() {
+		errs = append(errs, res.Err)
+	}
+	if len(errs) == 1 {
+		return report, errs[0]
+	} else if len(errs) > 1 {
+		return report, errs
+	}
+	return report, nil
+}
+
+// runTask runs the tool for task. If the task has inputs, its outputs are restored from
+// the cache instead if the inputs have not changed. runTask reports whether the cache was used.
+func (s *Shed) runTask(ctx context.Context, name string, task config.Task, args []string, opts RunOptions) (*RunReport, bool, error) {
+	t, err := s.getTool(task.Tool)
+	if err != nil {
+		return nil, false, err
+	}
+	taskConfig := config.Tool{RetryPolicy: task.RetryPolicy, Limits: task.Limits, Dir: task.Dir}
+	rs := resolveRunSettings(opts, s.config.Tool(t), taskConfig)
+	rs.task = name
+	if len(task.Inputs) == 0 {
+		report, err := s.run(ctx, t, args, rs, opts)
+		return report, false, err
+	}
+
+	root := s.projectRoot()
+	dir, err := s.taskDir(rs, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	hash, err := taskcache.Hash(root, taskcache.Key{
+		Task:    name,
+		Tool:    t.String(),
+		Args:    args,
+		Dir:     dir,
+		Inputs:  task.Inputs,
+		Outputs: task.Outputs,
+	})
+	if err != nil {
+		return nil, false, errors.WithMessagef(err, "failed to hash inputs of task %s", name)
+	}
+	logger := s.logger.WithFields(logrus.Fields{
+		"task": name,
+		"hash": hash,
+	})
+
+	if !opts.Force {
+		ok, err := s.taskCache.Restore(hash, root)
+		if err != nil {
+			return nil, false, errors.WithMessagef(err, "failed to restore cached outputs of task %s", name)
+		}
+		if !ok && s.remoteTasks() && s.pullTaskOutputs(ctx, hash, logger) {
+			ok, err = s.taskCache.Restore(hash, root)
+			if err != nil {
+				return nil, false, errors.WithMessagef(err, "failed to restore cached outputs of task %s", name)
+			}
+		}
+		if ok {
+			logger.Debug("Task inputs unchanged, restored outputs from cache")
+			return nil, true, nil
+		}
+	}
+
+	report, err := s.run(ctx, t, args, rs, opts)
+	if err != nil {
+		return report, false, err
+	}
+	if err := s.taskCache.Save(hash, name, root, task.Outputs); err != nil {
+		return report, false, errors.WithMessagef(err, "failed to cache outputs of task %s", name)
+	}
+	logger.Debug("Saved task outputs to cache")
+	if s.remoteTasks() && !s.remoteReadOnly {
+		s.pushTaskOutputs(ctx, hash, logger)
+	}
+	return report, false, nil
+}
+
+// remoteTasks reports whether the outputs of tasks are shared through the remote cache.
+// It is an experimental feature, see features.RemoteTasks.
+func (s *Shed) remoteTasks() bool {
+	return s.remote != nil && s.features.Enabled(features.RemoteTasks)
+}
+
+// remoteTaskKey returns the key used to store the outputs of a task in the remote cache.
+func remoteTaskKey(hash string) string {
+	return "tasks/" + hash + ".tar.gz"
+}
+
+// pullTaskOutputs downloads the entry for hash from the remote cache into the local cache.
+// It reports whether the entry was found. The remote cache is only an optimization,
+// so any errors are logged instead of failing the task.
+func (s *Shed) pullTaskOutputs(ctx context.Context, hash string, logger logrus.FieldLogger) bool {
+	rc, err := s.remote.Get(ctx, remoteTaskKey(hash))
+	if errors.Is(err, remote.ErrNotFound) {
+		logger.Debug("Task outputs not found in remote cache")
+		return false
+	}
+	if err != nil {
+		logger.WithError(err).Debug("Failed to get task outputs from remote cache")
+		return false
+	}
+	defer rc.Close()
+	if err := s.taskCache.Import(hash, ratelimit.NewReader(ctx, rc, s.downloadLimiter)); err != nil {
+		logger.WithError(err).Debug("Failed to import task outputs from remote cache")
+		return false
+	}
+	logger.Debug("Pulled task outputs from remote cache")
+	return true
+}
+
+// pushTaskOutputs uploads the entry for hash from the local cache to the remote cache.
+// Like pullTaskOutputs, errors are logged instead of failing the task.
+func (s *Shed) pushTaskOutputs(ctx context.Context, hash string, logger logrus.FieldLogger) {
+	var buf bytes.Buffer
+	if _, err := s.taskCache.Export(hash, &buf); err != nil {
+		logger.WithError(err).Debug("Failed to export task outputs")
+		return
+	}
+	if err := s.remote.Put(ctx, remoteTaskKey(hash), &buf); err != nil {
+		logger.WithError(err).Debug("Failed to push task outputs to remote cache")
+		return
+	}
+	logger.Debug("Pushed task outputs to remote cache")
+}
+
+// taskDir returns the directory a task is run from relative to the project root.
+// Tasks that are run from different directories may produce different outputs.
+func (s *Shed) taskDir(rs runSettings, opts RunOptions) (string, error) {
+	if rs.pinnedDir != "" {
+		return filepath.ToSlash(filepath.Clean(rs.pinnedDir)), nil
+	}
+	dir := opts.Dir
+	if dir == "" {
+		dir = "."
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get absolute path of %s", dir)
+	}
+	root, err := filepath.Abs(s.projectRoot())
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get absolute path of %s", s.projectRoot())
+	}
+	rel, err := filepath.Rel(root, absDir)
+	if err != nil {
+		// Not within the project, so use the absolute path
+		return filepath.ToSlash(absDir), nil
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// WriteTaskGraph writes the dependency graph of the task with the given name to w
+// in the DOT language used by Graphviz. Each edge points from a task to a task it depends on.
+// If no task exists with the given name, ErrTaskNotFound is returned.
+func (s *Shed) WriteTaskGraph(w io.Writer, taskName string) error {
+	order, err := s.taskOrder(taskName)
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "digraph %q {\n", taskName)
+	for _, name := range order {
+		task := s.config.Tasks[name]
+		label := name
+		if task.Tool != "" {
+			label += "\n(" + task.Tool + ")"
+		}
+		fmt.Fprintf(&sb, "\t%q [label=%q];\n", name, label)
+	}
+	for _, name := range order {
+		deps := append([]string(nil), s.config.Tasks[name].DependsOn...)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			fmt.Fprintf(&sb, "\t%q -> %q;\n", name, dep)
+		}
+	}
+	sb.WriteString("}\n")
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
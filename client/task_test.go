@@ -0,0 +1,239 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/features"
+	"github.com/getshiphub/shed/remote"
+)
+
+const taskScript = `#!/bin/sh
+echo "$@"
+if [ "$1" = fail ]; then
+	exit 4
+fi
+`
+
+const taskConfig = `{
+  "tasks": {
+    "protoc": {"tool": "go-fish", "args": ["protoc"]},
+    "fmt": {"tool": "go-fish", "args": ["fmt"], "dependsOn": ["protoc"]},
+    "lint": {"tool": "go-fish", "args": ["lint"], "dependsOn": ["fmt"]},
+    "generate": {"dependsOn": ["lint"]},
+    "broken": {"tool": "go-fish", "args": ["fail"]},
+    "after-broken": {"tool": "go-fish", "args": ["after"], "dependsOn": ["broken", "protoc"]}
+  }
+}`
+
+func TestRunTask(t *testing.T) {
+	s := newScriptShed(t, taskScript, taskConfig)
+
+	stdout := &bytes.Buffer{}
+	report, err := s.RunTask(context.Background(), "generate", []string{"--verbose"}, client.RunOptions{Stdout: stdout})
+	if err != nil {
+		t.Errorf("want nil error, got %v", err)
+	}
+	// Dependencies are in a chain so the order is deterministic
+	wantOut := "protoc\nfmt\nlint\n"
+	if stdout.String() != wantOut {
+		t.Errorf("got output %q, want %q", stdout.String(), wantOut)
+	}
+
+	var names []string
+	for _, res := range report.Results {
+		names = append(names, res.Name)
+	}
+	wantNames := []string{"protoc", "fmt", "lint", "generate"}
+	if len(names) != len(wantNames) {
+		t.Fatalf("got tasks %v, want %v", names, wantNames)
+	}
+	for i := range names {
+		if names[i] != wantNames[i] {
+			t.Errorf("got tasks %v, want %v", names, wantNames)
+			break
+		}
+	}
+
+	// args are only passed to the named task
+	stdout.Reset()
+	_, err = s.RunTask(context.Background(), "fmt", []string{"-l"}, client.RunOptions{Stdout: stdout})
+	if err != nil {
+		t.Errorf("want nil error, got %v", err)
+	}
+	wantOut = "protoc\nfmt -l\n"
+	if stdout.String() != wantOut {
+		t.Errorf("got output %q, want %q", stdout.String(), wantOut)
+	}
+
+	_, err = s.RunTask(context.Background(), "gen", nil, client.RunOptions{})
+	if !errors.Is(err, client.ErrTaskNotFound) {
+		t.Errorf("want ErrTaskNotFound, got %v", err)
+	}
+}
+
+func TestRunTaskDependencyFailed(t *testing.T) {
+	s := newScriptShed(t, taskScript, taskConfig)
+
+	report, err := s.RunTask(context.Background(), "after-broken", nil, client.RunOptions{})
+	if err == nil {
+		t.Fatal("want non-nil error, got nil")
+	}
+
+	results := make(map[string]client.TaskResult)
+	for _, res := range report.Results {
+		results[res.Name] = res
+	}
+	if results["protoc"].Err != nil {
+		t.Errorf("want protoc to succeed, got %v", results["protoc"].Err)
+	}
+	if results["broken"].Report.ExitCode() != 4 {
+		t.Errorf("got exit code %d, want %d", results["broken"].Report.ExitCode(), 4)
+	}
+	if res := results["after-broken"]; !errors.Is(res.Err, client.ErrDependencyFailed) || res.Report != nil {
+		t.Errorf("want after-broken to not be run, got %+v", res)
+	}
+
+	failed := report.Failed()
+	if len(failed) != 1 || failed[0].Name != "broken" {
+		t.Errorf("got failed tasks %+v, want only broken", failed)
+	}
+}
+
+func TestWriteTaskGraph(t *testing.T) {
+	s := newScriptShed(t, taskScript, taskConfig)
+
+	buf := &bytes.Buffer{}
+	if err := s.WriteTaskGraph(buf, "after-broken"); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := `digraph "after-broken" {
+	"broken" [label="broken\n(go-fish)"];
+	"protoc" [label="protoc\n(go-fish)"];
+	"after-broken" [label="after-broken\n(go-fish)"];
+	"after-broken" -> "broken";
+	"after-broken" -> "protoc";
+}
+`
+	if buf.String() != want {
+		t.Errorf("got graph\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestRunTaskCached(t *testing.T) {
+	const script = `#!/bin/sh
+echo run
+mkdir -p gen
+cat in.txt > gen/out.txt
+`
+	const cfg = `{
+  "tasks": {
+    "gen": {"tool": "go-fish", "dir": ".", "inputs": ["*.txt"], "outputs": ["gen/**"]}
+  }
+}`
+	s := newScriptShed(t, script, cfg)
+	root := s.CacheDir()
+	inPath := filepath.Join(root, "in.txt")
+	outPath := filepath.Join(root, "gen", "out.txt")
+	writeFile(t, inPath, "foo")
+
+	runGen := func(force, wantCached bool, wantOut string) {
+		t.Helper()
+		stdout := &bytes.Buffer{}
+		report, err := s.RunTask(context.Background(), "gen", nil, client.RunOptions{Stdout: stdout, Force: force})
+		if err != nil {
+			t.Fatalf("want nil error, got %v", err)
+		}
+		if cached := report.Results[0].Cached; cached != wantCached {
+			t.Errorf("got cached %t, want %t", cached, wantCached)
+		}
+		wantStdout := "run\n"
+		if wantCached {
+			wantStdout = ""
+		}
+		if stdout.String() != wantStdout {
+			t.Errorf("got output %q, want %q", stdout.String(), wantStdout)
+		}
+		data, err := ioutil.ReadFile(outPath)
+		if err != nil {
+			t.Fatalf("failed to read output: %v", err)
+		}
+		if string(data) != wantOut {
+			t.Errorf("got task output %q, want %q", data, wantOut)
+		}
+	}
+
+	runGen(false, false, "foo")
+	runGen(false, true, "foo")
+
+	// Deleted outputs are restored
+	if err := os.RemoveAll(filepath.Join(root, "gen")); err != nil {
+		t.Fatalf("failed to remove outputs: %v", err)
+	}
+	runGen(false, true, "foo")
+
+	runGen(true, false, "foo")
+
+	writeFile(t, inPath, "bar")
+	runGen(false, false, "bar")
+
+	// Going back to previous inputs uses the previous outputs
+	writeFile(t, inPath, "foo")
+	runGen(false, true, "foo")
+}
+
+func writeFile(t *testing.T, p, data string) {
+	t.Helper()
+	if err := ioutil.WriteFile(p, []byte(data), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %v", p, err)
+	}
+}
+
+func TestRunTaskRemoteCache(t *testing.T) {
+	const script = `#!/bin/sh
+echo run
+mkdir -p gen
+cat in.txt > gen/out.txt
+`
+	const cfg = `{
+  "tasks": {
+    "gen": {"tool": "go-fish", "dir": ".", "inputs": ["in.txt"], "outputs": ["gen/*.txt"]}
+  }
+}`
+	backend := remote.NewDirBackend(t.TempDir())
+	runGen := func(s *client.Shed, wantCached bool) {
+		t.Helper()
+		root := s.CacheDir()
+		writeFile(t, filepath.Join(root, "in.txt"), "foo")
+		report, err := s.RunTask(context.Background(), "gen", nil, client.RunOptions{})
+		if err != nil {
+			t.Fatalf("want nil error, got %v", err)
+		}
+		if cached := report.Results[0].Cached; cached != wantCached {
+			t.Errorf("got cached %t, want %t", cached, wantCached)
+		}
+		data, err := ioutil.ReadFile(filepath.Join(root, "gen", "out.txt"))
+		if err != nil {
+			t.Fatalf("failed to read output: %v", err)
+		}
+		if string(data) != "foo" {
+			t.Errorf("got task output %q, want %q", data, "foo")
+		}
+	}
+
+	enabled := client.WithFeatures(features.Layer{Source: "test", Values: map[string]bool{features.RemoteTasks: true}})
+	// A read only client should not push
+	runGen(newScriptShed(t, script, cfg, client.WithRemoteCache(backend, true), enabled), false)
+	// Outputs are only shared when the feature is enabled
+	runGen(newScriptShed(t, script, cfg, client.WithRemoteCache(backend, false)), false)
+	runGen(newScriptShed(t, script, cfg, client.WithRemoteCache(backend, false), enabled), false)
+	// A different project with the same inputs gets the outputs from the remote cache
+	runGen(newScriptShed(t, script, cfg, client.WithRemoteCache(backend, true)), false)
+	runGen(newScriptShed(t, script, cfg, client.WithRemoteCache(backend, true), enabled), true)
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/tool"
+)
+
+// ToolTiming is how long each stage of installing a tool took during InstallSet.Apply.
+type ToolTiming struct {
+	// Tool is the tool that was installed, with the version that was installed,
+	// or the version that was requested if it failed.
+	Tool tool.Tool
+	// Source is where the binary of the tool came from, see Event.Source. It is 0 if the tool failed.
+	Source cache.Source
+	// Failed reports whether the tool could not be installed.
+	Failed bool
+	// Resolve is how long resolving the version of the tool took. For tools that don't have an exact
+	// version, this includes downloading them, unless a resolver was set with WithResolver.
+	Resolve time.Duration
+	// Download is how long downloading the tool took, including pulling it from the remote cache
+	// and downloading release assets and prebuilt binaries.
+	Download time.Duration
+	// Build is how long building the tool took.
+	Build time.Duration
+	// Total is how long installing the tool took. It is more than the sum of the stages, since it also
+	// includes waiting for other processes using the cache, checking the cache, and running install hooks.
+	Total time.Duration
+}
+
+// CacheHit reports whether the binary of the tool was reused from the cache or pulled from the remote cache.
+func (tt ToolTiming) CacheHit() bool {
+	return tt.Source == cache.SourceCache || tt.Source == cache.SourceRemote
+}
+
+// InstallTimings is how long InstallSet.Apply took, and how long each tool took, see InstallSet.Timings.
+type InstallTimings struct {
+	// Total is how long Apply took, including updating the lockfile.
+	Total time.Duration
+	// Tools are the timings of each tool that was installed or failed, sorted by import path.
+	// Tools that were removed with the version 'none' aren't included.
+	Tools []ToolTiming
+}
+
+// CacheHits returns the number of tools whose binaries were reused from the cache or pulled from the remote cache.
+func (it *InstallTimings) CacheHits() int {
+	hits := 0
+	for _, tt := range it.Tools {
+		if tt.CacheHit() {
+			hits++
+		}
+	}
+	return hits
+}
+
+// Timings returns how long the last call to Apply took, and how long each stage of installing each tool took,
+// ex: to find out which tools slow down bootstrapping CI. Timings are recorded even if Apply failed, in which
+// case tools that were still being installed when it was aborted may be missing. It returns nil if Apply
+// hasn't been called.
+func (is *InstallSet) Timings() *InstallTimings {
+	return is.timings
+}
+
+// timingRecorder collects the timings of the tools installed by InstallSet.Apply.
+type timingRecorder struct {
+	mu    sync.Mutex
+	tools []ToolTiming
+}
+
+func (r *timingRecorder) add(tt ToolTiming) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tools = append(r.tools, tt)
+}
+
+// result returns the timings recorded so far, where Apply took total.
+func (r *timingRecorder) result(total time.Duration) *InstallTimings {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tools := make([]ToolTiming, len(r.tools))
+	copy(tools, r.tools)
+	sort.SliceStable(tools, func(i, j int) bool {
+		return strings.ToLower(tools[i].Tool.ImportPath) < strings.ToLower(tools[j].Tool.ImportPath)
+	})
+	return &InstallTimings{Total: total, Tools: tools}
+}
+
+// stageTimer measures how long each stage of installing a single tool takes from its events.
+// Events for a tool are reported from the goroutine installing it, so it isn't safe for concurrent use.
+type stageTimer struct {
+	start   time.Time
+	stage   EventKind
+	entered time.Time
+	timing  ToolTiming
+}
+
+func newStageTimer(start time.Time) *stageTimer {
+	return &stageTimer{start: start}
+}
+
+// observe ends the current stage and starts the stage of ev, if any, at now.
+func (st *stageTimer) observe(ev Event, now time.Time) {
+	switch st.stage {
+	case EventResolving:
+		st.timing.Resolve += now.Sub(st.entered)
+	case EventDownloading:
+		st.timing.Download += now.Sub(st.entered)
+	case EventBuilding:
+		st.timing.Build += now.Sub(st.entered)
+	}
+	st.stage, st.entered = ev.Kind, now
+	switch ev.Kind {
+	case EventDone:
+		st.timing.Tool, st.timing.Source = ev.Tool, ev.Source
+	case EventFailed:
+		st.timing.Tool, st.timing.Failed = ev.Tool, true
+	}
+	st.timing.Total = now.Sub(st.start)
+}
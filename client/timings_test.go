@@ -0,0 +1,63 @@
+package client_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+)
+
+func TestInstallTimings(t *testing.T) {
+	td := t.TempDir()
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithCache(cache.New(td, cache.WithGo(mockGo))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	installSet, err := s.Install("github.com/cszatmary/go-fish@v0.1.0", "github.com/Shopify/ejson/cmd/ejson@v9.0.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if installSet.Timings() != nil {
+		t.Errorf("got timings %+v before Apply, want nil", installSet.Timings())
+	}
+	if err := installSet.Apply(context.Background(), client.BestEffort()); err == nil {
+		t.Fatalf("want error for a version that doesn't exist, got nil")
+	}
+	timings := installSet.Timings()
+	if timings == nil || len(timings.Tools) != 2 {
+		t.Fatalf("got timings %+v, want timings for 2 tools", timings)
+	}
+	goFish, ejson := timings.Tools[0], timings.Tools[1]
+	if ejson.Tool.Name() != "ejson" || !ejson.Failed || ejson.Source != 0 {
+		t.Errorf("got timing %+v, want ejson to have failed", ejson)
+	}
+	if goFish.Tool.Name() != "go-fish" || goFish.Failed || goFish.Source != cache.SourceBuild || goFish.CacheHit() {
+		t.Errorf("got timing %+v, want go-fish to have been built", goFish)
+	}
+	if goFish.Download+goFish.Build > goFish.Total || goFish.Total > timings.Total {
+		t.Errorf("got timing %+v with install total %s, want the stages to add up to at most the total", goFish, timings.Total)
+	}
+
+	// Installing again reuses the binary in the cache
+	installSet, err = s.Install("go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	timings = installSet.Timings()
+	if len(timings.Tools) != 1 || !timings.Tools[0].CacheHit() || timings.Tools[0].Build != 0 || timings.CacheHits() != 1 {
+		t.Errorf("got timings %+v, want a cache hit for go-fish", timings)
+	}
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// ToolGoVersion is the minimum version of Go needed to build a tool.
+type ToolGoVersion struct {
+	// Tool is the tool as specified in the lockfile.
+	Tool tool.Tool
+	// GoVersion is the go directive of the module of the tool, ex: '1.21'.
+	GoVersion string
+}
+
+// Toolchain describes the minimum version of Go needed to build the tools in the lockfile.
+type Toolchain struct {
+	// GoVersion is the highest go directive of the modules of the tools, ex: '1.21'.
+	// It is empty if none of the modules have a go directive.
+	GoVersion string
+	// Tools are the go directives of the modules of each tool that has one, sorted by import path.
+	Tools []ToolGoVersion
+}
+
+// Requiring returns the tools that require a newer version of Go than goVersion,
+// which can be a go directive, ex: '1.21', or a version reported by the go command, ex: 'go1.21.3'.
+func (tc *Toolchain) Requiring(goVersion string) []ToolGoVersion {
+	var tools []ToolGoVersion
+	for _, tgv := range tc.Tools {
+		if cache.CompareGoVersions(tgv.GoVersion, goVersion) > 0 {
+			tools = append(tools, tgv)
+		}
+	}
+	return tools
+}
+
+// RequiredToolchain returns the minimum version of Go needed to build the tools in the lockfile, which is the
+// highest go directive of their modules, see cache.Cache.GoDirective. The go directives are read from the module
+// cache, so the tools must have been downloaded. Release tools aren't built, so they don't need Go.
+//
+// If the go directive of some tools couldn't be found, ex: because they aren't installed, the toolchain of the
+// other tools is returned, along with a lockfile.ErrorList with the failures.
+func (s *Shed) RequiredToolchain(ctx context.Context) (*Toolchain, error) {
+	if s.cache == nil {
+		return nil, ErrNoCache
+	}
+	tc := &Toolchain{}
+	var errs lockfile.ErrorList
+	for _, t := range s.List() {
+		goVersion, err := s.cache.GoDirective(ctx, t)
+		if err != nil {
+			errs = append(errs, errors.WithMessagef(err, "failed to find go version required by tool %s", t))
+			continue
+		}
+		if goVersion == "" {
+			continue
+		}
+		tc.Tools = append(tc.Tools, ToolGoVersion{Tool: t, GoVersion: goVersion})
+		if cache.CompareGoVersions(goVersion, tc.GoVersion) > 0 {
+			tc.GoVersion = goVersion
+		}
+	}
+	if len(errs) > 0 {
+		return tc, errs
+	}
+	return tc, nil
+}
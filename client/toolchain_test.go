@@ -0,0 +1,101 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/lockfile"
+)
+
+// writeModFile writes the go.mod of a module version to the module cache of c, like the go command does when downloading it.
+func writeModFile(t *testing.T, c *cache.Cache, modPath, version, goVersion string) {
+	t.Helper()
+	dir := filepath.Join(c.ModuleCacheDir(), "cache", "download", filepath.FromSlash(modPath), "@v")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	data := "module " + modPath + "\n\ngo " + goVersion + "\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, version+".mod"), []byte(data), 0o644); err != nil {
+		t.Fatalf("failed to write go.mod: %v", err)
+	}
+}
+
+func newToolchainShed(t *testing.T) (*client.Shed, *cache.Cache) {
+	td := t.TempDir()
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	c := cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo), cache.WithModuleCache(true))
+	s, err := client.NewShed(client.WithLockfilePath(filepath.Join(td, "shed.lock")), client.WithCache(c))
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	return s, c
+}
+
+func TestRequiredToolchain(t *testing.T) {
+	s, c := newToolchainShed(t)
+	writeModFile(t, c, "github.com/cszatmary/go-fish", "v0.1.0", "1.16")
+	writeModFile(t, c, "github.com/golangci/golangci-lint", "v1.33.0", "1.21.0")
+	installSet, err := s.Install(
+		"github.com/cszatmary/go-fish@v0.1.0",
+		"github.com/golangci/golangci-lint/cmd/golangci-lint@v1.33.0",
+		"github.com/Shopify/ejson/cmd/ejson@v1.2.2",
+	)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	// The go.mod of ejson isn't in the module cache
+	tc, err := s.RequiredToolchain(context.Background())
+	var errs lockfile.ErrorList
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Errorf("got error %v, want an error for ejson", err)
+	}
+	if tc.GoVersion != "1.21.0" {
+		t.Errorf("got go version %s, want 1.21.0", tc.GoVersion)
+	}
+	if len(tc.Tools) != 2 || tc.Tools[0].Tool.Name() != "go-fish" || tc.Tools[0].GoVersion != "1.16" {
+		t.Errorf("got tools %+v, want go-fish and golangci-lint", tc.Tools)
+	}
+	if requiring := tc.Requiring("go1.20.5"); len(requiring) != 1 || requiring[0].Tool.Name() != "golangci-lint" {
+		t.Errorf("got tools requiring go1.20.5 %+v, want golangci-lint", requiring)
+	}
+}
+
+func TestApplyEnforceToolchain(t *testing.T) {
+	s, c := newToolchainShed(t)
+	// Newer than any version of Go the tests run with
+	writeModFile(t, c, "github.com/golangci/golangci-lint", "v1.33.0", "1.999")
+	installSet, err := s.Install("github.com/cszatmary/go-fish@v0.1.0", "github.com/golangci/golangci-lint/cmd/golangci-lint@v1.33.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	err = installSet.Apply(context.Background(), client.EnforceToolchain())
+	var errs lockfile.ErrorList
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("got error %v, want an error for golangci-lint", err)
+	}
+	var tcErr *cache.ToolchainError
+	if !errors.As(errs[0], &tcErr) || tcErr.Tool.Name() != "golangci-lint" || tcErr.GoVersion != "1.999" {
+		t.Errorf("got error %v, want golangci-lint to require go 1.999", errs[0])
+	}
+	if len(s.List()) != 0 {
+		t.Errorf("got tools %v, want lockfile to be unchanged", s.List())
+	}
+
+	// Without enforcement it is up to the go command
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Errorf("want nil error, got %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ReadToolList reads a list of tools from r that can be passed to Install. Each line contains
+// a single tool in any format accepted by Install. Blank lines are ignored, as is anything
+// after a '#', so lists can contain comments.
+func ReadToolList(r io.Reader) ([]string, error) {
+	var tools []string
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i != -1 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 0:
+			continue
+		case 1:
+			tools = append(tools, fields[0])
+		default:
+			return nil, errors.Errorf("line %d: expected a single tool, got %q", n, strings.TrimSpace(line))
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read tool list")
+	}
+	return tools, nil
+}
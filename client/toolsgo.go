@@ -0,0 +1,116 @@
+package client
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/modfile"
+)
+
+// ImportToolsGo reads the tools from a tools.go file at path, so they can be installed with
+// InstallSpecs. A tools.go file tracks the tools used by a module with blank imports,
+// ex: import _ "golang.org/x/tools/cmd/stringer". Each blank import is a tool.
+//
+// The version of each tool is the version of the module that provides it in the go.mod file
+// of the module that contains path. The module is the longest module path in go.mod that
+// contains the import path. replace directives are ignored, since shed installs tools outside
+// of the module. If a tool is not provided by any module in go.mod, an error is returned.
+//
+// The specs are sorted by import path.
+func ImportToolsGo(path string) ([]ToolSpec, error) {
+	importPaths, err := toolsGoImports(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(importPaths) == 0 {
+		return nil, errors.Errorf("no blank imports found in %s", path)
+	}
+
+	modFile, err := readGoMod(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	return goModToolSpecs(modFile, importPaths)
+}
+
+// toolsGoImports returns the import paths of the blank imports in the Go file at path.
+func toolsGoImports(path string) ([]string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ImportsOnly)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", path)
+	}
+	var importPaths []string
+	for _, imp := range f.Imports {
+		if imp.Name == nil || imp.Name.Name != "_" {
+			continue
+		}
+		importPath, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid import %s in %s", imp.Path.Value, path)
+		}
+		importPaths = append(importPaths, importPath)
+	}
+	return importPaths, nil
+}
+
+// goModToolSpecs returns specs for the tools with the given import paths, using the versions
+// of the modules that provide them in modFile. The specs are sorted by import path.
+func goModToolSpecs(modFile *modfile.File, importPaths []string) ([]ToolSpec, error) {
+	sort.Strings(importPaths)
+	var specs []ToolSpec
+	var errs lockfile.ErrorList
+	for _, importPath := range importPaths {
+		var modPath, version string
+		for _, req := range modFile.Require {
+			p := req.Mod.Path
+			if (importPath == p || strings.HasPrefix(importPath, p+"/")) && len(p) > len(modPath) {
+				modPath = p
+				version = req.Mod.Version
+			}
+		}
+		if modPath == "" {
+			errs = append(errs, errors.Errorf("tool %s is not provided by any module in go.mod", importPath))
+			continue
+		}
+		specs = append(specs, ToolSpec{ImportPath: importPath, Version: version})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return specs, nil
+}
+
+// readGoMod reads the go.mod file in dir or the closest parent directory.
+func readGoMod(dir string) (*modfile.File, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get absolute path of %s", dir)
+	}
+	for {
+		p := filepath.Join(dir, "go.mod")
+		data, err := ioutil.ReadFile(p)
+		if err == nil {
+			modFile, err := modfile.ParseLax(p, data, nil)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse go.mod file %s", p)
+			}
+			return modFile, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, errors.Wrapf(err, "failed to read file %s", p)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, errors.New("no go.mod file found")
+		}
+		dir = parent
+	}
+}
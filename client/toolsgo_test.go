@@ -0,0 +1,131 @@
+package client_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/lockfile"
+)
+
+const testGoMod = `module example.org/project
+
+go 1.15
+
+require (
+	github.com/golangci/golangci-lint v1.33.0
+	golang.org/x/tools v0.0.0-20201211185031-d93e913c1a58
+	golang.org/x/tools/gopls v0.6.0 // indirect
+)
+
+replace golang.org/x/tools => ../tools
+`
+
+func writeToolsGo(t *testing.T, toolsGo string) string {
+	td := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(td, "go.mod"), []byte(testGoMod), 0o644); err != nil {
+		t.Fatalf("failed to write go.mod: %v", err)
+	}
+	// tools.go is often in a subdirectory, so go.mod needs to be found in a parent
+	dir := filepath.Join(td, "tools")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	p := filepath.Join(dir, "tools.go")
+	if err := ioutil.WriteFile(p, []byte(toolsGo), 0o644); err != nil {
+		t.Fatalf("failed to write tools.go: %v", err)
+	}
+	return p
+}
+
+func TestImportToolsGo(t *testing.T) {
+	p := writeToolsGo(t, `// +build tools
+
+package tools
+
+import (
+	"fmt"
+
+	_ "github.com/golangci/golangci-lint/cmd/golangci-lint"
+	_ "golang.org/x/tools/cmd/stringer"
+	_ "golang.org/x/tools/gopls"
+)
+`)
+	specs, err := client.ImportToolsGo(p)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := []client.ToolSpec{
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58"},
+		{ImportPath: "golang.org/x/tools/gopls", Version: "v0.6.0"},
+	}
+	if !reflect.DeepEqual(specs, want) {
+		t.Errorf("got specs %+v, want %+v", specs, want)
+	}
+}
+
+func TestImportToolsGoError(t *testing.T) {
+	tests := []struct {
+		name    string
+		toolsGo string
+	}{
+		{"not in go.mod", "package tools\n\nimport _ \"github.com/cszatmary/go-fish\"\n"},
+		{"no blank imports", "package tools\n\nimport \"fmt\"\n"},
+		{"invalid Go", "package tools\n\nimport _ \n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.ImportToolsGo(writeToolsGo(t, tt.toolsGo)); err == nil {
+				t.Error("want non-nil error, got nil")
+			}
+		})
+	}
+
+	// Every missing tool is reported
+	p := writeToolsGo(t, "package tools\n\nimport (\n\t_ \"github.com/cszatmary/go-fish\"\n\t_ \"mvdan.cc/gofumpt\"\n)\n")
+	_, err := client.ImportToolsGo(p)
+	var errs lockfile.ErrorList
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Errorf("got error %v, want 2 errors", err)
+	}
+}
+
+func TestImportGoModTools(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "go.mod")
+	data := testGoMod + `
+tool golang.org/x/tools/cmd/stringer
+
+tool (
+	"github.com/golangci/golangci-lint/cmd/golangci-lint"
+	golang.org/x/tools/gopls
+)
+`
+	if err := ioutil.WriteFile(p, []byte(data), 0o644); err != nil {
+		t.Fatalf("failed to write go.mod: %v", err)
+	}
+	specs, err := client.ImportGoModTools(p)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := []client.ToolSpec{
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58"},
+		{ImportPath: "golang.org/x/tools/gopls", Version: "v0.6.0"},
+	}
+	if !reflect.DeepEqual(specs, want) {
+		t.Errorf("got specs %+v, want %+v", specs, want)
+	}
+
+	// No tool directives
+	if err := ioutil.WriteFile(p, []byte(testGoMod), 0o644); err != nil {
+		t.Fatalf("failed to write go.mod: %v", err)
+	}
+	if _, err := client.ImportGoModTools(p); err == nil {
+		t.Error("want non-nil error, got nil")
+	}
+}
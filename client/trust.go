@@ -0,0 +1,66 @@
+package client
+
+import (
+	"github.com/getshiphub/shed/internal/trust"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// TrustFileName is the name of the file, usually in xdg.StateDir(), that records the modules
+// that have been trusted when using WithTrust.
+const TrustFileName = trust.FileName
+
+// ErrUntrusted is returned when a tool isn't run because the module that provides it wasn't trusted.
+var ErrUntrusted = errors.New("module is not trusted")
+
+// TrustFunc decides whether to trust the module mod the first time a tool from it, t, is run.
+// It is usually a prompt that asks the user. If it returns true, the decision is recorded
+// and it is not called for the module again.
+type TrustFunc func(t tool.Tool, mod string) (bool, error)
+
+// WithTrust enables trust on first use. Before a tool is run, or asked for completions, shed checks
+// if the module that provides it has been trusted on this machine. If it hasn't, fn is called to
+// decide, and modules that are trusted are recorded in the file at path. If fn doesn't trust the
+// module, the tool isn't run and ErrUntrusted is returned.
+//
+// This gives a safety net against running tools from unfamiliar modules, ex: after cloning a
+// repository that has a shed.lock. By default all tools are trusted.
+func WithTrust(path string, fn TrustFunc) Option {
+	return func(s *Shed) {
+		s.trustStore = trust.New(path)
+		s.trustFunc = fn
+	}
+}
+
+// checkTrust returns an error if the module that provides t is not trusted.
+func (s *Shed) checkTrust(t tool.Tool) error {
+	if s.trustStore == nil {
+		return nil
+	}
+	mod := t.ModulePath
+	if mod == "" && s.cache != nil {
+		// Older lockfiles don't record the module, so find it from the cache
+		if p, err := s.cache.ModulePath(t); err == nil {
+			mod = p
+		}
+	}
+	if mod == "" {
+		mod = t.ImportPath
+	}
+	ok, err := s.trustStore.Trusted(mod)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return nil
+	}
+	ok, err = s.trustFunc(t, mod)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.Wrapf(ErrUntrusted, "not running %s", t)
+	}
+	s.logger.Debugf("Trusted module %s", mod)
+	return s.trustStore.Trust(mod)
+}
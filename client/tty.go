@@ -0,0 +1,177 @@
+package client
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/getshiphub/shed/internal/pty"
+	"github.com/mattn/go-isatty"
+)
+
+// TTYMode controls whether or not a tool is run in a pseudo-terminal.
+type TTYMode int
+
+const (
+	// TTYAuto allocates a pseudo-terminal if stdin is a terminal but the tool's
+	// output is not, for example because it is being captured. This allows tools
+	// to behave as if they were run directly in the terminal.
+	TTYAuto TTYMode = iota
+	// TTYAlways always allocates a pseudo-terminal.
+	TTYAlways
+	// TTYNever never allocates a pseudo-terminal.
+	TTYNever
+)
+
+func isTerminal(v interface{}) (*os.File, bool) {
+	f, ok := v.(*os.File)
+	if !ok {
+		return nil, false
+	}
+	return f, isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// wantsTTY reports whether a pseudo-terminal should be allocated based on opts.
+func wantsTTY(opts RunOptions) bool {
+	switch opts.TTY {
+	case TTYAlways:
+		return true
+	case TTYNever:
+		return false
+	}
+	if _, ok := isTerminal(opts.Stdin); !ok {
+		return false
+	}
+	_, ok := isTerminal(opts.Stdout)
+	return !ok
+}
+
+// ptySession manages a tool that is running in a pseudo-terminal.
+type ptySession struct {
+	ptm        *os.File
+	outputDone chan struct{}
+	// stopInput stops proxying input, inputDone is closed once it has stopped.
+	// Both are nil if there is no input.
+	stopInput  chan struct{}
+	inputDone  chan struct{}
+	restore    func() error
+	stopResize func()
+}
+
+// startPTY starts c in a new pseudo-terminal. Input is proxied from in and output
+// is proxied to opts.Stdout. Since a terminal only has a single output stream,
+// opts.Stderr is not used. in may be nil if there is no input.
+//
+// If opts.Stdin is a terminal, it is put into raw mode for the duration of the session
+// and window size changes are forwarded to the pseudo-terminal.
+func startPTY(c *exec.Cmd, opts RunOptions, in *inputPump) (*ptySession, error) {
+	ptm, err := pty.Start(c)
+	if err != nil {
+		return nil, err
+	}
+
+	ps := &ptySession{ptm: ptm, outputDone: make(chan struct{})}
+	if tty, ok := isTerminal(opts.Stdin); ok {
+		ps.stopResize = pty.ProxyResize(tty, ptm)
+		// If raw mode fails, input still works it will just be line buffered
+		if restore, err := pty.MakeRaw(tty); err == nil {
+			ps.restore = restore
+		}
+	}
+
+	stdout := opts.Stdout
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+	go func() {
+		// Reading will return an error once the tool exits and the pts is closed
+		_, _ = io.Copy(stdout, ptm)
+		close(ps.outputDone)
+	}()
+	if in != nil {
+		ps.stopInput = make(chan struct{})
+		ps.inputDone = make(chan struct{})
+		go func() {
+			in.copyTo(ptm, ps.stopInput)
+			close(ps.inputDone)
+		}()
+	}
+	return ps, nil
+}
+
+// close waits for all output to be written, then cleans up the session.
+// It must only be called once the tool has exited.
+func (ps *ptySession) close() {
+	<-ps.outputDone
+	if ps.stopInput != nil {
+		close(ps.stopInput)
+	}
+	ps.ptm.Close()
+	if ps.inputDone != nil {
+		<-ps.inputDone
+	}
+	if ps.stopResize != nil {
+		ps.stopResize()
+	}
+	if ps.restore != nil {
+		_ = ps.restore()
+	}
+}
+
+// inputPump reads input for pseudo-terminals in the background and hands it to one session at a time.
+// A read from stdin can't be cancelled, so a session that stops while a read is pending leaves the data
+// in the pump for the next session, instead of it being lost when a tool is retried.
+type inputPump struct {
+	r       io.Reader
+	once    sync.Once
+	data    chan []byte
+	pending []byte
+}
+
+func newInputPump(r io.Reader) *inputPump {
+	return &inputPump{r: r, data: make(chan []byte)}
+}
+
+// copyTo writes input to w until stop is closed, the input ends, or writing fails.
+// Only one session may call copyTo at a time.
+func (p *inputPump) copyTo(w io.Writer, stop <-chan struct{}) {
+	p.once.Do(func() {
+		go p.read()
+	})
+	for {
+		if len(p.pending) == 0 {
+			select {
+			case <-stop:
+				return
+			case b, ok := <-p.data:
+				if !ok {
+					return
+				}
+				p.pending = b
+			}
+		}
+		n, err := w.Write(p.pending)
+		p.pending = p.pending[n:]
+		if err != nil {
+			return
+		}
+	}
+}
+
+// read reads the input until it ends. It blocks until a session takes what was read,
+// so only one read is ever pending.
+func (p *inputPump) read() {
+	defer close(p.data)
+	for {
+		buf := make([]byte, 32*1024)
+		n, err := p.r.Read(buf)
+		if n > 0 {
+			p.data <- buf[:n]
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/getshiphub/shed/github"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/releasenotes"
+	"github.com/getshiphub/shed/tool"
+	"github.com/getshiphub/shed/vulndb"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// maxSummaryLines is the number of lines of release notes in UpdateDetails.
+const maxSummaryLines = 5
+
+// releaseNotesMaxAge is how long release notes cached in the cache are used without checking for changes.
+const releaseNotesMaxAge = time.Hour
+
+// WithVulnDB sets the vulnerability database used by UpdateDetails, SecurityUpdates, and Audit.
+// By default, the database in GOVULNDB is used, see vulndb.FromEnv.
+func WithVulnDB(db *vulndb.Client) Option {
+	return func(s *Shed) {
+		s.vulnDB = db
+	}
+}
+
+// WithReleaseNotes sets where UpdateDetails fetches release notes from.
+// By default, the GitHub API is used, authenticated with GITHUB_TOKEN if it is set,
+// and its responses are cached in the cache used for installing tools.
+func WithReleaseNotes(gh *releasenotes.GitHub) Option {
+	return func(s *Shed) {
+		s.releaseNotes = gh
+	}
+}
+
+// UpdateDetails describes what updating a tool to its latest version changes,
+// to help decide whether to update it.
+type UpdateDetails struct {
+	// ReleaseURL is the web page of the release of the latest version, empty if there is none.
+	ReleaseURL string
+	// ReleaseNotes is the start of the release notes of the latest version, empty if there are none.
+	ReleaseNotes string
+	// Fixes are the vulnerabilities that affect the current version but not the latest version.
+	Fixes []vulndb.Entry
+}
+
+// UpdateDetails fetches the release notes and vulnerability fixes of the update in ot.
+// Release notes are only found for modules hosted on GitHub.
+//
+// The details that could be fetched are always returned. If something couldn't be fetched,
+// the error is a lockfile.ErrorList with the failures.
+func (s *Shed) UpdateDetails(ctx context.Context, ot OutdatedTool) (UpdateDetails, error) {
+	var details UpdateDetails
+	if ot.Update == UpdateNone {
+		return details, nil
+	}
+	modPath := ot.Tool.ModulePath
+	if modPath == "" {
+		modPath = ot.Tool.ImportPath
+	}
+
+	var errs lockfile.ErrorList
+	gh := s.releaseNotes
+	if gh == nil {
+		client := github.FromEnv()
+		if s.cache != nil {
+			client = client.WithCache(s.cache.GitHubDir(), releaseNotesMaxAge)
+		}
+		gh = releasenotes.NewGitHub(client)
+	}
+	rel, err := gh.Release(ctx, module.Version{Path: modPath, Version: ot.Latest})
+	if err == nil {
+		details.ReleaseURL = rel.URL
+		details.ReleaseNotes = releasenotes.Summary(rel.Notes, maxSummaryLines)
+	} else if !errors.Is(err, releasenotes.ErrNotFound) {
+		errs = append(errs, err)
+	}
+
+	db := s.vulnDB
+	if db == nil {
+		db = vulndb.FromEnv()
+	}
+	details.Fixes, err = db.Fixed(ctx, modPath, ot.Tool.Version, ot.Latest)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return details, errs
+	}
+	return details, nil
+}
+
+// SecurityUpdate is an update of a tool whose version has known vulnerabilities.
+type SecurityUpdate struct {
+	// Tool is the tool as specified in the lockfile.
+	Tool tool.Tool
+	// Version is the lowest version that isn't affected by any of the vulnerabilities
+	// being fixed. It is empty if there is no such version.
+	Version string
+	// Fixes are the vulnerabilities that affect the version of the tool.
+	Fixes []vulndb.Entry
+}
+
+// SecurityUpdates finds the tools in the lockfile whose versions are affected by vulnerabilities
+// that are at least as severe as minSeverity, and the smallest update that fixes them.
+// Vulnerabilities with an unknown severity are always included, which is all of them in the
+// Go vulnerability database. See WithVulnDB for the database that is used.
+//
+// The update is the lowest release that is newer than the version in the lockfile and isn't affected
+// by any vulnerabilities that are at least as severe as minSeverity. The versions are listed with the
+// resolver set with WithResolver, or the module proxies in GOPROXY if there is none.
+// Only the module of each tool is checked, so new major versions with a different module path aren't used.
+//
+// An update is returned for every vulnerable tool, even if there is no version that fixes it.
+// If some tools couldn't be checked, the error is a lockfile.ErrorList with the failures.
+func (s *Shed) SecurityUpdates(ctx context.Context, minSeverity vulndb.Severity) ([]SecurityUpdate, error) {
+	db := s.vulnDB
+	if db == nil {
+		db = vulndb.FromEnv()
+	}
+	// Vulnerabilities that are severe enough to fix
+	gated := func(entries []vulndb.Entry) []vulndb.Entry {
+		var severe []vulndb.Entry
+		for _, e := range entries {
+			if e.Severity == vulndb.SeverityUnknown || e.Severity >= minSeverity {
+				severe = append(severe, e)
+			}
+		}
+		return severe
+	}
+
+	var updates []SecurityUpdate
+	var errs lockfile.ErrorList
+	for _, t := range s.List() {
+		modPath := t.ModulePath
+		if modPath == "" {
+			modPath = t.ImportPath
+		}
+		m, err := db.Module(ctx, modPath)
+		if err != nil {
+			errs = append(errs, errors.WithMessagef(err, "failed to check tool %s", t))
+			continue
+		}
+		fixes := gated(m.Affecting(t.Version))
+		if len(fixes) == 0 {
+			continue
+		}
+		versions, err := s.listResolver(t).ListVersions(ctx, t.ImportPath)
+		if err != nil {
+			errs = append(errs, errors.WithMessagef(err, "failed to list versions of tool %s", t))
+			continue
+		}
+		update := SecurityUpdate{Tool: t, Fixes: fixes}
+		// Versions are sorted, so the first safe one is the smallest update
+		for _, v := range versions {
+			if semver.Compare(v, t.Version) <= 0 || semver.Prerelease(v) != "" {
+				continue
+			}
+			if len(gated(m.Affecting(v))) == 0 {
+				update.Version = v
+				break
+			}
+		}
+		updates = append(updates, update)
+	}
+	if len(errs) > 0 {
+		return updates, errs
+	}
+	return updates, nil
+}
@@ -0,0 +1,144 @@
+package client
+
+import (
+	"context"
+
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/semver"
+)
+
+// UpgradeOptions customizes Upgrades and Upgrade.
+type UpgradeOptions struct {
+	// MaxUpdate is the largest kind of update that is made. With UpdateMinor tools are only upgraded
+	// to versions with the same major version, and with UpdatePatch to versions with the same minor
+	// version. The zero value, UpdateNone, means there is no limit, the same as UpdateMajor.
+	MaxUpdate UpdateKind
+	// Tools limits the upgrade to the tools with these names or import paths.
+	// If it is empty, every tool in the lockfile is upgraded.
+	Tools []string
+}
+
+// Upgrades finds the version each tool in the lockfile would be upgraded to, without changing anything.
+// Only tools with a newer version are returned, and Latest is the highest version allowed by opts.MaxUpdate.
+//
+// Without a limit, the latest version is found the same way as Outdated. With a limit, the versions of the
+// module are listed with the resolver set with WithResolver, or the module proxies in GOPROXY if there is none,
+// and the highest allowed release is used. Release tools are skipped, since their versions can't be listed.
+// Tools that track a branch are upgraded to the latest commit of the branch, if it is allowed by opts.MaxUpdate.
+//
+// If a tool in opts.Tools isn't in the lockfile, or some tools couldn't be checked,
+// the error is a lockfile.ErrorList with the failures.
+func (s *Shed) Upgrades(ctx context.Context, opts UpgradeOptions) ([]OutdatedTool, error) {
+	if s.cache == nil {
+		return nil, ErrNoCache
+	}
+	tools, err := s.upgradeTools(opts.Tools)
+	if err != nil {
+		return nil, err
+	}
+
+	var upgrades []OutdatedTool
+	var errs lockfile.ErrorList
+	for _, t := range tools {
+		if t.IsRelease() {
+			s.logger.Debugf("Skipping release tool %s since its versions can't be listed", t)
+			continue
+		}
+		var v string
+		var err error
+		if opts.MaxUpdate == UpdateNone || opts.MaxUpdate == UpdateMajor || t.Branch != "" {
+			v, err = s.latestVersion(ctx, t)
+		} else {
+			v, err = s.latestAllowedVersion(ctx, t, opts.MaxUpdate)
+		}
+		if err != nil {
+			errs = append(errs, errors.WithMessagef(err, "failed to check tool %s", t))
+			continue
+		}
+		kind := classifyUpdate(t.Version, v)
+		if t.Branch != "" && opts.MaxUpdate != UpdateNone && kind > opts.MaxUpdate {
+			s.logger.Debugf("Skipping %s since the latest commit of %s is a %s update", t, t.Branch, kind)
+			continue
+		}
+		if kind != UpdateNone {
+			upgrades = append(upgrades, OutdatedTool{Tool: t, Latest: v, Update: kind})
+		}
+	}
+	if len(errs) > 0 {
+		return upgrades, errs
+	}
+	return upgrades, nil
+}
+
+// Upgrade returns an InstallSet that upgrades the tools in the lockfile to the versions found by Upgrades.
+// Apply must be called to install them and write the lockfile. Build flags, aliases, and tracked branches are kept.
+// If every tool is up to date, the InstallSet is empty.
+func (s *Shed) Upgrade(ctx context.Context, opts UpgradeOptions) (*InstallSet, error) {
+	upgrades, err := s.Upgrades(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	tools := make([]tool.Tool, len(upgrades))
+	for i, u := range upgrades {
+		t := u.Tool
+		t.Version = u.Latest
+		// The module sum is for the old version
+		t.Sum = ""
+		tools[i] = t
+	}
+	return &InstallSet{s: s, tools: tools}, nil
+}
+
+// upgradeTools returns the tools in the lockfile with the given names or import paths,
+// or every tool if names is empty.
+func (s *Shed) upgradeTools(names []string) ([]tool.Tool, error) {
+	all := s.List()
+	if len(names) == 0 {
+		return all, nil
+	}
+	selected := make(map[string]bool)
+	var errs lockfile.ErrorList
+	for _, name := range names {
+		found := false
+		for _, t := range all {
+			if t.Name() == name || t.ImportPath == name {
+				selected[t.ImportPath] = true
+				found = true
+			}
+		}
+		if !found {
+			errs = append(errs, errors.Wrapf(lockfile.ErrNotFound, "failed to find tool %s", name))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	var tools []tool.Tool
+	for _, t := range all {
+		if selected[t.ImportPath] {
+			tools = append(tools, t)
+		}
+	}
+	return tools, nil
+}
+
+// latestAllowedVersion returns the highest release of the module that provides t that is at most
+// a maxUpdate update from the version of t. If there is none, the version of t is returned.
+func (s *Shed) latestAllowedVersion(ctx context.Context, t tool.Tool, maxUpdate UpdateKind) (string, error) {
+	versions, err := s.listResolver(t).ListVersions(ctx, t.ImportPath)
+	if err != nil {
+		return "", err
+	}
+	latest := t.Version
+	for _, v := range versions {
+		if semver.Prerelease(v) != "" || semver.Compare(v, latest) <= 0 {
+			continue
+		}
+		if classifyUpdate(t.Version, v) <= maxUpdate {
+			latest = v
+		}
+	}
+	return latest, nil
+}
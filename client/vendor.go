@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// VendorDirName is the name of the directory next to the lockfile that Vendor adds what is needed
+// to install the tools to, and that tools are installed from with WithOffline.
+const VendorDirName = "shed-vendor"
+
+// WithOffline makes shed install tools only from the vendor directory of the project, see Vendor,
+// so that nothing is downloaded, ex: in air-gapped build environments. Installing fails right away
+// if a tool needs anything that isn't vendored, and the error matches ErrNotVendored if shed knows
+// it isn't. Tools must have exact versions, the remote cache isn't used, and prebuilt binaries are
+// never downloaded. Tools that are already in the cache are used as usual.
+func WithOffline(enabled bool) Option {
+	return func(s *Shed) {
+		s.offline = enabled
+	}
+}
+
+// VendorDir returns the path of the vendor directory of the project, see Vendor.
+func (s *Shed) VendorDir() string {
+	return filepath.Join(filepath.Dir(s.lockfilePath), VendorDirName)
+}
+
+// Vendor adds everything needed to install the tools in the lockfile without network access to VendorDir,
+// replacing what was there. It contains the modules used to build each tool, taken from the module cache,
+// and the release assets of release tools, which are downloaded for the platform shed is running on.
+// Tools built from source must already be installed, ex: with InstallSet.Apply. See cache.Vendor for the
+// layout. The vendor directory is only replaced once every tool has been vendored. Vendor returns the
+// number of tools vendored.
+func (s *Shed) Vendor(ctx context.Context) (int, error) {
+	if s.cache == nil {
+		return 0, ErrNoCache
+	}
+	dir := s.VendorDir()
+	// Vendor to a temp dir first so a failure leaves the existing vendor directory as is
+	tmpDir, err := ioutil.TempDir(filepath.Dir(dir), "."+VendorDirName+"-")
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create temp directory")
+	}
+	defer os.RemoveAll(tmpDir)
+	tools := s.List()
+	for _, t := range tools {
+		if err := s.cache.Vendor(ctx, t, tmpDir); err != nil {
+			return 0, errors.WithMessagef(err, "failed to vendor tool %s", t)
+		}
+	}
+	// TempDir uses 0700, but the vendor directory is usually committed or shared with other machines
+	if err := os.Chmod(tmpDir, 0o755); err != nil {
+		return 0, errors.Wrapf(err, "failed to set permissions of %s", tmpDir)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return 0, errors.Wrapf(err, "failed to remove vendor directory %s", dir)
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		return 0, errors.Wrapf(err, "failed to create vendor directory %s", dir)
+	}
+	s.logger.WithField("path", dir).Debugf("vendored %d tools", len(tools))
+	return len(tools), nil
+}
+
+// installOptions returns the options for installing t with the cache, which set the environment of
+// the go command and make it install from the vendor directory if WithOffline is used.
+func (s *Shed) installOptions(t tool.Tool) ([]cache.InstallOption, error) {
+	opts := []cache.InstallOption{cache.InstallEnv(s.installEnv(t)...)}
+	if !s.offline {
+		return opts, nil
+	}
+	// Resolving a version needs the network
+	if !t.HasSemver() {
+		return nil, errors.Wrapf(ErrNotVendored, "tool %s must have an exact version to install offline", t)
+	}
+	dir, err := filepath.Abs(s.VendorDir())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get absolute path of %s", s.VendorDir())
+	}
+	return append(opts, cache.InstallOffline(dir)), nil
+}
@@ -0,0 +1,117 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/internal/util"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+)
+
+func TestVendor(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+	})
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	c := cache.New(filepath.Join(td, "cache"), cache.WithGo(mockGo), cache.WithModuleCache(true))
+	s, err := client.NewShed(client.WithLockfilePath(lockfilePath), client.WithCache(c))
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	installSet, err := s.Install()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	// The mock go doesn't download anything, so fill the module cache like the go command would
+	modFiles := []string{"v0.1.0.info", "v0.1.0.mod", "v0.1.0.zip"}
+	for _, f := range modFiles {
+		p := filepath.Join(c.ModuleCacheDir(), "cache", "download", "github.com", "cszatmary", "go-fish", "@v", f)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := ioutil.WriteFile(p, []byte("module"), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	n, err := s.Vendor(context.Background())
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if n != 1 {
+		t.Errorf("got %d vendored tools, want 1", n)
+	}
+	for _, f := range modFiles {
+		p := filepath.Join(td, client.VendorDirName, "modules", "github.com", "cszatmary", "go-fish", "@v", f)
+		if !util.FileOrDirExists(p) {
+			t.Errorf("want %s to be vendored", p)
+		}
+	}
+
+	// Install with an empty cache, so everything has to come from the vendor directory
+	newOfflineShed := func() *client.Shed {
+		s, err := client.NewShed(
+			client.WithLockfilePath(lockfilePath),
+			client.WithCache(cache.New(filepath.Join(td, "offline-cache"), cache.WithGo(mockGo))),
+			client.WithOffline(true),
+		)
+		if err != nil {
+			t.Fatalf("failed to create shed client %v", err)
+		}
+		return s
+	}
+	offline := newOfflineShed()
+	installSet, err = offline.Install()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if _, err := offline.ToolPath("go-fish"); err != nil {
+		t.Errorf("want go-fish to be installed, got %v", err)
+	}
+
+	// Tools that aren't vendored fail to install
+	installSet, err = newOfflineShed().Install("github.com/Shopify/ejson/cmd/ejson@v1.2.2")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	var errs lockfile.ErrorList
+	if err := installSet.Apply(context.Background()); !errors.As(err, &errs) || len(errs) != 1 || !errors.Is(errs[0], client.ErrDownloadFailed) {
+		t.Errorf("got error %v, want %v", err, client.ErrDownloadFailed)
+	}
+	installSet, err = newOfflineShed().Install("github.com/Shopify/ejson/cmd/ejson")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); !errors.As(err, &errs) || len(errs) != 1 || !errors.Is(errs[0], client.ErrNotVendored) {
+		t.Errorf("got error %v, want %v", err, client.ErrNotVendored)
+	}
+
+	// Vendoring fails if a module is missing, and keeps the vendor directory
+	if err := os.RemoveAll(filepath.Join(c.ModuleCacheDir(), "cache")); err != nil {
+		t.Fatalf("failed to remove module cache %v", err)
+	}
+	if _, err := s.Vendor(context.Background()); err == nil {
+		t.Error("want error vendoring modules that aren't in the module cache, got nil")
+	}
+	if !util.FileOrDirExists(filepath.Join(td, client.VendorDirName, "modules")) {
+		t.Error("want vendor directory to be kept")
+	}
+}
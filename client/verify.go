@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/policy"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// Mismatch describes an installed tool that doesn't match its hash.
+type Mismatch struct {
+	// Tool is the tool as specified in the lockfile.
+	Tool tool.Tool
+	// Err describes the file that doesn't match.
+	Err *cache.ChecksumError
+}
+
+// Verify checks that the installed files of each tool in the lockfile have not been modified,
+// which could mean they were tampered with. The module of each tool is compared against the hash
+// in the lockfile and the binary is compared against the hash recorded when it was built.
+// See cache.Cache.Verify for details.
+//
+// A mismatch is returned for every tool that doesn't match its hash. If some tools couldn't be
+// verified, for example because they aren't installed, the error is a lockfile.ErrorList with the failures.
+func (s *Shed) Verify() ([]Mismatch, error) {
+	if s.cache == nil {
+		return nil, ErrNoCache
+	}
+
+	var mismatches []Mismatch
+	var errs lockfile.ErrorList
+	for _, t := range s.List() {
+		err := s.cache.Verify(t)
+		var cerr *cache.ChecksumError
+		if errors.As(err, &cerr) {
+			mismatches = append(mismatches, Mismatch{Tool: t, Err: cerr})
+			continue
+		}
+		if err != nil {
+			errs = append(errs, errors.WithMessagef(err, "failed to verify tool %s", t))
+		}
+	}
+	if len(errs) > 0 {
+		return mismatches, errs
+	}
+	return mismatches, nil
+}
+
+// VerifyRebuild proves that the tools in the lockfile that are built reproducibly are, see tool.Reproducible.
+// Each of them is built again from scratch, replacing its binary in the cache, and the binary is compared
+// against the hash in the lockfile for the current platform. Other tools are skipped.
+//
+// A mismatch is returned for every tool whose new binary doesn't match its hash. If some tools couldn't be
+// rebuilt, or have no hash for the current platform, the error is a lockfile.ErrorList with the failures.
+//
+// The provided context is used to terminate the builds if the context becomes done before they complete.
+func (s *Shed) VerifyRebuild(ctx context.Context) ([]Mismatch, error) {
+	if s.cache == nil {
+		return nil, ErrNoCache
+	}
+
+	var mismatches []Mismatch
+	var errs lockfile.ErrorList
+	platform := tool.Platform(runtime.GOOS, runtime.GOARCH)
+	for _, t := range s.List() {
+		if t.Reproducible == nil {
+			continue
+		}
+		if t.Reproducible.Sums[platform] == "" {
+			errs = append(errs, errors.Errorf("tool %s has no hash for %s to verify, run 'shed install' to record one", t, platform))
+			continue
+		}
+		s.logger.Infof("Rebuilding %s", t)
+		installOpts, err := s.installOptions(t)
+		if err == nil {
+			_, err = s.cache.Install(ctx, t, append(installOpts, cache.InstallRebuild())...)
+		}
+		var cerr *cache.ChecksumError
+		if errors.As(err, &cerr) {
+			mismatches = append(mismatches, Mismatch{Tool: t, Err: cerr})
+			continue
+		}
+		if err != nil {
+			errs = append(errs, errors.WithMessagef(err, "failed to rebuild tool %s", t))
+		}
+	}
+	if len(errs) > 0 {
+		return mismatches, errs
+	}
+	return mismatches, nil
+}
+
+// CheckPolicy returns the tools in the lockfile that don't follow the policy p.
+// See policy.Policy.Check for details.
+func (s *Shed) CheckPolicy(p *policy.Policy) []policy.Violation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return p.Check(s.lf)
+}
@@ -0,0 +1,264 @@
+package client_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/config"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+)
+
+func TestVerify(t *testing.T) {
+	s := newLockfileShed(t, t.TempDir(), availableTools, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
+	})
+	installSet, err := s.Install()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	mismatches, err := s.Verify()
+	if err != nil || len(mismatches) != 0 {
+		t.Fatalf("got mismatches %+v with error %v, want none", mismatches, err)
+	}
+
+	// Modify the binary
+	binPath, err := s.ToolPath("go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := ioutil.WriteFile(binPath, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("failed to write %s: %v", binPath, err)
+	}
+	mismatches, err = s.Verify()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if len(mismatches) != 1 || mismatches[0].Tool.Name() != "go-fish" || mismatches[0].Err.Path != binPath {
+		t.Errorf("got mismatches %+v, want go-fish binary", mismatches)
+	}
+}
+
+func TestRepairCache(t *testing.T) {
+	s := newLockfileShed(t, t.TempDir(), availableTools, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.2"},
+	})
+	installSet, err := s.Install()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if problems, err := s.CheckCache(); err != nil || len(problems) != 0 {
+		t.Fatalf("got problems %v with error %v, want none", problems, err)
+	}
+
+	// A truncated binary, and a binary that was deleted
+	fishPath, err := s.ToolPath("go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := ioutil.WriteFile(fishPath, []byte("\x7fELF\x02\x01"), 0o755); err != nil {
+		t.Fatalf("failed to write %s: %v", fishPath, err)
+	}
+	lintPath, err := s.ToolPath("golangci-lint")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := os.Remove(lintPath); err != nil {
+		t.Fatalf("failed to remove %s: %v", lintPath, err)
+	}
+	problems, err := s.CheckCache()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	var got []string
+	for _, p := range problems {
+		issue := p.Issue
+		if i := strings.Index(issue, ":"); i != -1 {
+			issue = issue[:i]
+		}
+		got = append(got, filepath.Base(p.Path)+": "+issue)
+	}
+	want := []string{
+		"go-fish: binary has hash sha256",
+		"go-fish: binary is corrupt",
+		"golangci-lint: binary does not exist",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got problems %q, want %q", got, want)
+	}
+
+	repaired, err := s.RepairCache(context.Background())
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if len(repaired) != 2 || repaired[0].Name() != "go-fish" || repaired[1].Name() != "golangci-lint" {
+		t.Errorf("got repaired tools %v, want go-fish and golangci-lint", repaired)
+	}
+	if problems, err := s.CheckCache(); err != nil || len(problems) != 0 {
+		t.Errorf("got problems %v with error %v, want none", problems, err)
+	}
+}
+
+func TestVerifyRebuild(t *testing.T) {
+	platform := tool.Platform(runtime.GOOS, runtime.GOARCH)
+	// The mock go command writes the build flags to the binary
+	sum := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("-trimpath -buildvcs=false")))
+	s := newLockfileShed(t, t.TempDir(), availableTools, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0", Reproducible: &tool.Reproducible{GoVersion: "go1.22.4", Sums: map[string]string{platform: sum}}},
+		// Not reproducible, so it isn't rebuilt
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
+	})
+	installSet, err := s.Install()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	mismatches, err := s.VerifyRebuild(context.Background())
+	if err != nil || len(mismatches) != 0 {
+		t.Fatalf("got mismatches %+v with error %v, want none", mismatches, err)
+	}
+
+	s = newLockfileShed(t, t.TempDir(), availableTools, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0", Reproducible: &tool.Reproducible{GoVersion: "go1.22.4", Sums: map[string]string{platform: "sha256:" + strings.Repeat("00", 32)}}},
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.2", Reproducible: &tool.Reproducible{GoVersion: "go1.22.4"}},
+	})
+	mismatches, err = s.VerifyRebuild(context.Background())
+	if len(mismatches) != 1 || mismatches[0].Tool.Name() != "go-fish" || mismatches[0].Err.Got != sum {
+		t.Errorf("got mismatches %+v, want go-fish binary with hash %s", mismatches, sum)
+	}
+	// ejson has no hash to compare against
+	var errs lockfile.ErrorList
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Errorf("got error %v, want 1 error for ejson", err)
+	}
+}
+
+func TestInstallSumMismatch(t *testing.T) {
+	tl := tool.Tool{
+		ImportPath: "github.com/cszatmary/go-fish",
+		Version:    "v0.1.0",
+		Sum:        "h1:2LQvNcBdwkWytOgG01je44UW/btPPfrcRF0kOTnT318=",
+	}
+	s := newLockfileShed(t, t.TempDir(), availableTools, []tool.Tool{tl})
+	installSet, err := s.Install()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	err = installSet.Apply(context.Background())
+	var errs lockfile.ErrorList
+	if !errors.As(err, &errs) || len(errs) != 1 || !errors.Is(errs[0], cache.ErrChecksumMismatch) {
+		t.Errorf("got error %v, want %v", err, cache.ErrChecksumMismatch)
+	}
+	if _, err := s.ToolPath("go-fish"); err == nil {
+		t.Error("want error for tool with a mismatched hash, got nil")
+	}
+}
+
+func TestVerifyNoCache(t *testing.T) {
+	s, err := client.NewShed(client.WithLockfilePath(filepath.Join(t.TempDir(), "shed.lock")), client.WithNoCache())
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	if _, err := s.Verify(); !errors.Is(err, client.ErrNoCache) {
+		t.Errorf("got error %v, want %v", err, client.ErrNoCache)
+	}
+}
+
+// versionGo wraps a Go instance and replaces each binary with a shell script that prints version.
+type versionGo struct {
+	cache.Go
+	version string
+}
+
+func (g *versionGo) Build(ctx context.Context, pkg, outPath, dir string, flags, env []string) error {
+	if err := g.Go.Build(ctx, pkg, outPath, dir, flags, env); err != nil {
+		return err
+	}
+	script := fmt.Sprintf("#!/bin/sh\n[ \"$1\" = version ] && echo 'tool has version %s'\n", g.version)
+	if err := ioutil.WriteFile(outPath, []byte(script), 0o755); err != nil {
+		return err
+	}
+	return os.Chmod(outPath, 0o755)
+}
+
+func TestVerifyReportedVersions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("version probe tests use shell scripts")
+	}
+	td := t.TempDir()
+	cfg := `{"tools": {"go-fish": {"versionProbe": {"args": ["version"]}}}}`
+	if err := ioutil.WriteFile(filepath.Join(td, config.ProjectFileName), []byte(cfg), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	g := &versionGo{Go: mockGo, version: "0.1.0"}
+	s, err := client.NewShed(
+		client.WithLockfilePath(filepath.Join(td, "shed.lock")),
+		client.WithCache(cache.New(filepath.Join(td, "cache"), cache.WithGo(g))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	install := func(tools ...string) {
+		t.Helper()
+		installSet, err := s.Install(tools...)
+		if err != nil {
+			t.Fatalf("want nil error, got %v", err)
+		}
+		if err := installSet.Apply(context.Background()); err != nil {
+			t.Fatalf("want nil error, got %v", err)
+		}
+	}
+	install("github.com/cszatmary/go-fish@v0.1.0", "github.com/golangci/golangci-lint/cmd/golangci-lint@v1.33.0")
+
+	info, err := s.ToolInfo("go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if info.ReportedVersion == nil || info.ReportedVersion.Output != "tool has version 0.1.0" {
+		t.Errorf("got reported version %+v, want go-fish to report 0.1.0", info.ReportedVersion)
+	}
+	// Tools without a probe are never run
+	if info, err := s.ToolInfo("golangci-lint"); err != nil || info.ReportedVersion != nil {
+		t.Errorf("got reported version %+v with error %v, want none", info.ReportedVersion, err)
+	}
+	mismatches, err := s.VerifyReportedVersions()
+	if err != nil || len(mismatches) != 0 {
+		t.Fatalf("got mismatches %+v with error %v, want none", mismatches, err)
+	}
+
+	// The build embeds the wrong version
+	install("github.com/cszatmary/go-fish@22d10c9b658df297b17b33c836a60fb943ef5a5f")
+	mismatches, err = s.VerifyReportedVersions()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if len(mismatches) != 1 || mismatches[0].Tool.Version != "v0.0.0-20201203230243-22d10c9b658d" || mismatches[0].Reported != "tool has version 0.1.0" {
+		t.Errorf("got mismatches %+v, want go-fish at a pseudo-version", mismatches)
+	}
+}
@@ -0,0 +1,227 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// ReportedVersionsFileName is the name of the file in the project directory containing the versions
+// reported by tools with a version probe, see config.VersionProbe. It is a JSON object that maps
+// the import path of each tool to a ReportedVersion.
+const ReportedVersionsFileName = "versions.json"
+
+// versionProbeTimeout is how long a tool is given to print its version.
+const versionProbeTimeout = 10 * time.Second
+
+// ReportedVersion is the version a tool reported when it was probed after being installed.
+type ReportedVersion struct {
+	// Version is the version of the tool in the lockfile when it was probed.
+	Version string `json:"version"`
+	// Output is the first non-empty line printed by the tool, ex: 'golangci-lint has version 1.33.0'.
+	Output string `json:"output"`
+	// Time is when the tool was probed.
+	Time time.Time `json:"time"`
+}
+
+// VersionMismatch describes a tool that reported a different version than the one in the lockfile.
+type VersionMismatch struct {
+	// Tool is the tool as specified in the lockfile.
+	Tool tool.Tool
+	// Reported is the output of the version probe of the tool.
+	Reported string
+}
+
+func (s *Shed) reportedVersionsPath() string {
+	return filepath.Join(s.projectRoot(), ProjectDirName, ReportedVersionsFileName)
+}
+
+// readReportedVersions reads the recorded versions. If none were recorded, an empty map is returned.
+// s.historyMu must be held.
+func (s *Shed) readReportedVersions() (map[string]ReportedVersion, error) {
+	p := s.reportedVersionsPath()
+	data, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return make(map[string]ReportedVersion), nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read file %s", p)
+	}
+	versions := make(map[string]ReportedVersion)
+	if err := json.Unmarshal(data, &versions); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", p)
+	}
+	return versions, nil
+}
+
+// writeReportedVersions writes the recorded versions to a temp file first, like writeUninstalled.
+// s.historyMu must be held.
+func (s *Shed) writeReportedVersions(versions map[string]ReportedVersion) error {
+	p := s.reportedVersionsPath()
+	data, err := json.MarshalIndent(versions, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to serialize reported versions")
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return errors.Wrapf(err, "failed to create directory %s", filepath.Dir(p))
+	}
+	f, err := ioutil.TempFile(filepath.Dir(p), "."+ReportedVersionsFileName+"-")
+	if err != nil {
+		return errors.Wrapf(err, "failed to create temp file for %s", p)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "failed to write %s", p)
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrapf(err, "failed to write %s", p)
+	}
+	if err := os.Rename(f.Name(), p); err != nil {
+		return errors.Wrapf(err, "failed to write %s", p)
+	}
+	return nil
+}
+
+// probeVersions runs the version probe of each tool in tools that has one and records the version it reports.
+// Tools that were already probed at the same version are skipped. A tool that can't be probed doesn't
+// make the install fail, since the binary is still usable, so errors are logged instead of being returned.
+func (s *Shed) probeVersions(ctx context.Context, tools []tool.Tool) {
+	var probed []tool.Tool
+	for _, t := range tools {
+		if t.Version != noneVersion && s.config.Tool(t).VersionProbe != nil {
+			probed = append(probed, t)
+		}
+	}
+	if len(probed) == 0 {
+		return
+	}
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	versions, err := s.readReportedVersions()
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to read reported versions")
+		return
+	}
+	changed := false
+	for _, t := range probed {
+		if rv, ok := versions[t.ImportPath]; ok && rv.Version == t.Version {
+			continue
+		}
+		output, err := s.probeVersion(ctx, t)
+		if err != nil {
+			s.logger.WithError(err).Warnf("Failed to record the version reported by %s", t)
+			continue
+		}
+		s.logger.Debugf("%s reported version: %s", t, output)
+		versions[t.ImportPath] = ReportedVersion{Version: t.Version, Output: output, Time: time.Now().UTC()}
+		changed = true
+	}
+	if !changed {
+		return
+	}
+	if err := s.writeReportedVersions(versions); err != nil {
+		s.logger.WithError(err).Warn("Failed to record reported versions")
+	}
+}
+
+// probeVersion runs the installed binary of t with the arguments of its version probe
+// and returns the first non-empty line it prints to stdout or stderr.
+func (s *Shed) probeVersion(ctx context.Context, t tool.Tool) (string, error) {
+	// The tool is run like it is with Run, so it must be trusted
+	if err := s.checkTrust(t); err != nil {
+		return "", err
+	}
+	binPath, err := s.cache.ToolPath(t)
+	if err != nil {
+		return "", err
+	}
+	args := s.config.Tool(t).VersionProbe.Args
+	if len(args) == 0 {
+		args = []string{"--version"}
+	}
+	ctx, cancel := context.WithTimeout(ctx, versionProbeTimeout)
+	defer cancel()
+	var out bytes.Buffer
+	c := exec.CommandContext(ctx, binPath, args...)
+	c.Stdout = &out
+	c.Stderr = &out
+	if err := c.Run(); err != nil {
+		return "", errors.Wrapf(err, "failed to run %s %s", t.Name(), strings.Join(args, " "))
+	}
+	sc := bufio.NewScanner(&out)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			return line, nil
+		}
+	}
+	return "", errors.Errorf("%s %s printed nothing", t.Name(), strings.Join(args, " "))
+}
+
+// reportedVersion returns the version reported by t from the lockfile, or nil if it wasn't probed at its version.
+func (s *Shed) reportedVersion(t tool.Tool) (*ReportedVersion, error) {
+	s.historyMu.Lock()
+	versions, err := s.readReportedVersions()
+	s.historyMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	rv, ok := versions[t.ImportPath]
+	if !ok || rv.Version != t.Version {
+		return nil, nil
+	}
+	return &rv, nil
+}
+
+// VerifyReportedVersions checks that each tool in the lockfile that was probed at its version reported
+// that version, see config.VersionProbe. A tool whose build embeds the wrong version, or none, is returned
+// as a mismatch. Tools without a recorded version are skipped.
+//
+// A tool matches if its output contains its version, with or without the 'v' prefix. Tools at
+// a pseudo-version also match if the output contains the revision of the pseudo-version, since many
+// tools print the commit they were built from instead.
+func (s *Shed) VerifyReportedVersions() ([]VersionMismatch, error) {
+	s.historyMu.Lock()
+	versions, err := s.readReportedVersions()
+	s.historyMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var mismatches []VersionMismatch
+	for _, t := range s.List() {
+		rv, ok := versions[t.ImportPath]
+		if !ok || rv.Version != t.Version {
+			continue
+		}
+		if !reportsVersion(rv.Output, t.Version) {
+			mismatches = append(mismatches, VersionMismatch{Tool: t, Reported: rv.Output})
+		}
+	}
+	return mismatches, nil
+}
+
+// pseudoRevRE matches the revision at the end of a pseudo-version, see https://golang.org/ref/mod#pseudo-versions.
+var pseudoRevRE = regexp.MustCompile(`[-.]\d{14}-([0-9a-f]{12})$`)
+
+// reportsVersion reports whether output, printed by a tool, contains version.
+func reportsVersion(output, version string) bool {
+	v := strings.TrimSuffix(version, "+incompatible")
+	if strings.Contains(output, strings.TrimPrefix(v, "v")) {
+		return true
+	}
+	if m := pseudoRevRE.FindStringSubmatch(v); m != nil {
+		return strings.Contains(output, m[1])
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"strings"
+
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/semver"
+)
+
+// ErrReleaseTool is returned when an operation that needs the versions of the module of a tool
+// is used with a release tool, which is downloaded from a release instead of being built.
+var ErrReleaseTool = errors.New("release tools have no module versions")
+
+// VersionsOption customizes which versions Versions returns.
+type VersionsOption func(*versionsOptions)
+
+type versionsOptions struct {
+	prereleases bool
+}
+
+// IncludePrereleases makes Versions also return pre-release versions, ex: 'v1.2.0-rc.1'.
+func IncludePrereleases() VersionsOption {
+	return func(o *versionsOptions) {
+		o.prereleases = true
+	}
+}
+
+// Versions returns the published versions of the module that provides a tool, sorted from lowest to highest,
+// ex: to choose which version to install. name is either the name or import path of a tool in the lockfile,
+// or the import path of any tool. A version in name, ex: '@latest', is ignored.
+//
+// The versions are listed using the module proxies in GOPROXY, or the ones in the install settings of the tool in
+// the config file. If a resolver was set with WithResolver, it is used instead, so only versions that can be installed
+// are returned. Pseudo-versions are never included, and pre-release versions are only included with IncludePrereleases.
+// The returned tool is the tool the versions are for. If it is a release tool, ErrReleaseTool is returned.
+func (s *Shed) Versions(ctx context.Context, name string, opts ...VersionsOption) (tool.Tool, []string, error) {
+	var o versionsOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if i := strings.LastIndexByte(name, '@'); i != -1 {
+		name = name[:i]
+	}
+	t, err := s.findTool(name)
+	if errors.Is(err, lockfile.ErrNotFound) && strings.Contains(name, "/") {
+		t, err = tool.ParseLax(name)
+		if err != nil {
+			return t, nil, errors.WithMessagef(err, "invalid tool %s", name)
+		}
+	} else if err != nil {
+		return t, nil, err
+	}
+	if t.IsRelease() {
+		return t, nil, errors.Wrapf(ErrReleaseTool, "failed to list versions of %s", t.ImportPath)
+	}
+	versions, err := s.listResolver(t).ListVersions(ctx, t.ImportPath)
+	if err != nil {
+		return t, nil, errors.WithMessagef(err, "failed to list versions of %s", t.ImportPath)
+	}
+	if o.prereleases {
+		return t, versions, nil
+	}
+	var releases []string
+	for _, v := range versions {
+		if semver.Prerelease(v) == "" {
+			releases = append(releases, v)
+		}
+	}
+	return t, releases, nil
+}
@@ -0,0 +1,55 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/shedtest"
+	"github.com/getshiphub/shed/tool"
+)
+
+func TestVersions(t *testing.T) {
+	lockfilePath := filepath.Join(t.TempDir(), "shed.lock")
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "example.org/tool/cmd/tool", Version: "v1.0.0", Release: &tool.Release{URL: "https://example.org/tool-{{.Version}}.tar.gz"}},
+	})
+	proxy := shedtest.NewProxy(t, map[string][]string{
+		"github.com/cszatmary/go-fish": {"v0.2.0-rc.1", "v0.1.0", "v0.0.1"},
+		"github.com/Shopify/ejson":     {"v1.1.0", "v1.2.2"},
+	})
+	s, err := client.NewShed(client.WithLockfilePath(lockfilePath), client.WithNoCache(), client.WithResolver(proxy.Resolver()))
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	tl, versions, err := s.Versions(context.Background(), "go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if want := []string{"v0.0.1", "v0.1.0"}; tl.Version != "v0.1.0" || !reflect.DeepEqual(versions, want) {
+		t.Errorf("got %s with versions %v, want the tool in the lockfile with versions %v", tl, versions, want)
+	}
+	_, versions, err = s.Versions(context.Background(), "go-fish", client.IncludePrereleases())
+	if want := []string{"v0.0.1", "v0.1.0", "v0.2.0-rc.1"}; err != nil || !reflect.DeepEqual(versions, want) {
+		t.Errorf("got versions %v with error %v, want %v", versions, err, want)
+	}
+
+	// Tools that aren't in the lockfile can be listed by import path
+	tl, versions, err = s.Versions(context.Background(), "github.com/Shopify/ejson/cmd/ejson@latest")
+	if want := []string{"v1.1.0", "v1.2.2"}; err != nil || tl.ImportPath != "github.com/Shopify/ejson/cmd/ejson" || !reflect.DeepEqual(versions, want) {
+		t.Errorf("got %s with versions %v and error %v, want ejson with versions %v", tl, versions, err, want)
+	}
+
+	if _, _, err := s.Versions(context.Background(), "tool"); !errors.Is(err, client.ErrReleaseTool) {
+		t.Errorf("got error %v, want %v", err, client.ErrReleaseTool)
+	}
+	if _, _, err := s.Versions(context.Background(), "ejson"); !errors.Is(err, lockfile.ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, lockfile.ErrNotFound)
+	}
+}
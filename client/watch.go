@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultWatchInterval = 500 * time.Millisecond
+	defaultWatchDebounce = 200 * time.Millisecond
+)
+
+// WatchOptions allows for customizing how the lockfile is watched.
+type WatchOptions struct {
+	// Interval is how often the lockfile is checked for changes. If 0, 500ms is used.
+	Interval time.Duration
+	// Debounce is how long the lockfile must go without changing before it is reloaded.
+	// This prevents reading a partially written lockfile, or reloading multiple times
+	// when a lockfile is changed in quick succession. If 0, 200ms is used.
+	Debounce time.Duration
+	// OnError is called if the changed lockfile could not be read or parsed.
+	// The previous lockfile continues to be used until the next successful reload.
+	// If nil, errors are logged.
+	OnError func(error)
+}
+
+// lockfileState is used to detect changes to the lockfile.
+type lockfileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func statLockfile(path string) (lockfileState, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return lockfileState{}, nil
+	}
+	if err != nil {
+		return lockfileState{}, errors.Wrapf(err, "failed to stat lockfile %s", path)
+	}
+	return lockfileState{exists: true, size: info.Size(), modTime: info.ModTime()}, nil
+}
+
+// WatchLockfile watches the lockfile for changes and reloads it, so that long-running
+// programs pick up changes without needing to create a new Shed instance. After each
+// successful reload, onReload is called with the new lockfile, which must not be modified.
+// A lockfile that is removed is treated the same as an empty lockfile, just like in NewShed.
+//
+// Changes are detected by polling, so onReload is also called for changes made through
+// s, such as by Install. WatchLockfile blocks until ctx is done and then returns ctx.Err().
+func (s *Shed) WatchLockfile(ctx context.Context, onReload func(*lockfile.Lockfile), opts WatchOptions) error {
+	if opts.Interval == 0 {
+		opts.Interval = defaultWatchInterval
+	}
+	if opts.Debounce == 0 {
+		opts.Debounce = defaultWatchDebounce
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(err error) {
+			s.logger.WithError(err).Warn("Failed to reload lockfile")
+		}
+	}
+
+	last, err := statLockfile(s.lockfilePath)
+	if err != nil {
+		return err
+	}
+	var changedAt time.Time
+	pending := false
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		cur, err := statLockfile(s.lockfilePath)
+		if err != nil {
+			opts.OnError(err)
+			continue
+		}
+		if cur != last {
+			last = cur
+			changedAt = time.Now()
+			pending = true
+			continue
+		}
+		if !pending || time.Since(changedAt) < opts.Debounce {
+			continue
+		}
+		pending = false
+
+		lf, err := s.reloadLockfile()
+		if err != nil {
+			opts.OnError(err)
+			continue
+		}
+		s.logger.Debugf("Reloaded lockfile %s", s.lockfilePath)
+		onReload(lf)
+	}
+}
+
+// reloadLockfile reads the lockfile from disk and replaces the current lockfile with it.
+func (s *Shed) reloadLockfile() (*lockfile.Lockfile, error) {
+	lf := &lockfile.Lockfile{}
+	f, err := os.Open(s.lockfilePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "failed to open file %s", s.lockfilePath)
+	}
+	if err == nil {
+		defer f.Close()
+		lf, err = lockfile.Parse(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse lockfile %s", s.lockfilePath)
+		}
+	}
+
+	s.mu.Lock()
+	s.lf = lf
+	s.mu.Unlock()
+	return lf, nil
+}
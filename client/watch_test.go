@@ -0,0 +1,90 @@
+package client_test
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+)
+
+func TestWatchLockfile(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+	})
+	s, err := client.NewShed(
+		client.WithLockfilePath(lockfilePath),
+		client.WithCache(cache.New(td, cache.WithGo(mockGo))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloadCh := make(chan *lockfile.Lockfile, 1)
+	errCh := make(chan error, 1)
+	done := make(chan error)
+	go func() {
+		done <- s.WatchLockfile(ctx, func(lf *lockfile.Lockfile) {
+			reloadCh <- lf
+		}, client.WatchOptions{
+			Interval: 5 * time.Millisecond,
+			Debounce: 10 * time.Millisecond,
+			OnError: func(err error) {
+				errCh <- err
+			},
+		})
+	}()
+	// Make sure the watcher has seen the initial lockfile before changing it
+	time.Sleep(20 * time.Millisecond)
+
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.1.0"},
+	})
+	select {
+	case lf := <-reloadCh:
+		if _, err := lf.GetTool("ejson"); err != nil {
+			t.Errorf("want ejson in reloaded lockfile, got %v", err)
+		}
+	case err := <-errCh:
+		t.Fatalf("want nil error, got %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for lockfile to be reloaded")
+	}
+	if got := len(s.List()); got != 2 {
+		t.Errorf("got %d tools after reload, want 2", got)
+	}
+
+	if err := ioutil.WriteFile(lockfilePath, []byte("{"), 0o644); err != nil {
+		t.Fatalf("failed to write lockfile: %v", err)
+	}
+	select {
+	case <-reloadCh:
+		t.Fatal("want invalid lockfile to not be reloaded")
+	case <-errCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for reload error")
+	}
+	// The previous lockfile should still be used
+	if got := len(s.List()); got != 2 {
+		t.Errorf("got %d tools after failed reload, want 2", got)
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/getshiphub/shed/tool"
+	"github.com/pkg/errors"
+)
+
+// WhyInfo explains why a tool is in the lockfile.
+type WhyInfo struct {
+	// Tool is the tool in the lockfile. Its Why and Groups fields are the notes saved for it.
+	Tool tool.Tool
+	// Sources are the files in the project that refer to the tool, found the same way as
+	// DetectTools. They are relative to the project root, sorted, and use forward slashes.
+	Sources []string
+}
+
+// Why returns the notes saved for the tool with the given name in the lockfile, along with the files
+// in the project that use it. A file uses a tool if it refers to its import path or, since references
+// usually run tools by name, to the name of its binary.
+func (s *Shed) Why(name string) (WhyInfo, error) {
+	t, err := s.getTool(name)
+	if err != nil {
+		return WhyInfo{}, errors.WithMessagef(err, "failed to find tool %s", name)
+	}
+	detected, err := DetectTools(s.projectRoot())
+	if err != nil {
+		return WhyInfo{}, err
+	}
+	info := WhyInfo{Tool: t}
+	seen := make(map[string]bool)
+	for _, dt := range detected {
+		if !strings.EqualFold(dt.ImportPath, t.ImportPath) && binaryName(dt.ImportPath) != binaryName(t.ImportPath) {
+			continue
+		}
+		for _, src := range dt.Sources {
+			if !seen[src] {
+				seen[src] = true
+				info.Sources = append(info.Sources, src)
+			}
+		}
+	}
+	sort.Strings(info.Sources)
+	return info, nil
+}
@@ -0,0 +1,83 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+)
+
+func TestWhy(t *testing.T) {
+	td := t.TempDir()
+	lockfilePath := filepath.Join(td, "shed.lock")
+	createLockfile(t, lockfilePath, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0", Groups: "dev"},
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58"},
+	})
+	makefile := "hooks:\n\tgo run github.com/cszatmary/go-fish@v0.1.0 install\n\ngenerate:\n\tgo generate ./...\n"
+	if err := ioutil.WriteFile(filepath.Join(td, "Makefile"), []byte(makefile), 0o644); err != nil {
+		t.Fatalf("failed to write Makefile: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(td, "gen.go"), []byte("package gen\n\n//go:generate stringer -type=Kind\n"), 0o644); err != nil {
+		t.Fatalf("failed to write gen.go: %v", err)
+	}
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	s, err := client.NewShed(
+		client.WithLockfilePath(lockfilePath),
+		client.WithCache(cache.New(t.TempDir(), cache.WithGo(mockGo))),
+	)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+
+	installSet, err := s.InstallSpecs([]client.ToolSpec{{Name: "go-fish", Why: "installs the git hooks"}})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	// The note is kept when the tool is updated without one
+	installSet, err = s.InstallSpecs([]client.ToolSpec{{ImportPath: "github.com/cszatmary/go-fish", Version: "22d10c9b658df297b17b33c836a60fb943ef5a5f"}})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	lf := readLockfile(t, lockfilePath)
+	if tl, _ := lf.GetTool("go-fish"); tl.Version != "v0.0.0-20201203230243-22d10c9b658d" || tl.Why != "installs the git hooks" {
+		t.Errorf("got go-fish %s with note %q, want the new version with note %q", tl.Version, tl.Why, "installs the git hooks")
+	}
+
+	info, err := s.Why("go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if info.Tool.Why != "installs the git hooks" || info.Tool.Groups != "dev" {
+		t.Errorf("got tool %+v, want the note and groups from the lockfile", info.Tool)
+	}
+	if want := []string{"Makefile"}; !reflect.DeepEqual(info.Sources, want) {
+		t.Errorf("got sources %v, want %v", info.Sources, want)
+	}
+	info, err = s.Why("stringer")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if want := []string{"gen.go"}; info.Tool.Why != "" || !reflect.DeepEqual(info.Sources, want) {
+		t.Errorf("got note %q and sources %v, want no note and sources %v", info.Tool.Why, info.Sources, want)
+	}
+	if _, err := s.Why("ejson"); !errors.Is(err, lockfile.ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, lockfile.ErrNotFound)
+	}
+}
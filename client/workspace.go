@@ -0,0 +1,292 @@
+package client
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/config"
+	"github.com/getshiphub/shed/internal/util"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/semver"
+)
+
+// ResolveWorkspacePath finds the workspace file, see config.WorkspaceFileName, for the directory dir.
+// Like ResolveLockfilePath, it searches dir and each of its parents, and only regular files are considered.
+// If no workspace file is found, an empty string is returned.
+//
+// A project in a workspace still finds its own lockfile with ResolveLockfilePath, the workspace
+// only groups the projects so their tools can be installed and reconciled together.
+func ResolveWorkspacePath(dir string) string {
+	if dir == "" {
+		dir = "."
+	}
+	var prev string
+	for dir != prev {
+		p := filepath.Join(dir, config.WorkspaceFileName)
+		if util.IsRegularFile(p) {
+			return p
+		}
+		prev = dir
+		dir = filepath.Dir(dir)
+	}
+	return ""
+}
+
+// WorkspaceProject is a project in a workspace.
+type WorkspaceProject struct {
+	// Dir is the directory of the project relative to the workspace root, using '/' as the separator.
+	// It is '.' for a project in the workspace root.
+	Dir string
+	// Shed is the client for the project. Every project in the workspace uses the same cache.
+	Shed *Shed
+}
+
+// Workspace is a group of projects that each have their own lockfile, ex: the services of a monorepo.
+// The projects share a single cache, so tools used by many of them are only installed once.
+type Workspace struct {
+	root     string
+	config   *config.Workspace
+	projects []*WorkspaceProject
+	cache    *cache.Cache
+}
+
+// OpenWorkspace reads the workspace file at path and loads each of its projects. opts are used to create
+// the Shed of each project, WithLockfilePath is added for each project so it should not be used.
+//
+// Every project uses the cache of the workspace root, which is the cache a project in the root
+// directory would use, so a project can't use a different cache with the cache setting of its config.
+// Projects given as patterns only include the matching directories that have a lockfile, while it is
+// an error for a project given as a directory not to have one.
+func OpenWorkspace(path string, opts ...Option) (*Workspace, error) {
+	cfg, err := config.ReadWorkspace(path)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to read workspace %s", path)
+	}
+	root, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get absolute path of %s", filepath.Dir(path))
+	}
+	w := &Workspace{root: root, config: cfg}
+	rootShed, err := NewShed(append(append([]Option{}, opts...), WithLockfilePath(filepath.Join(root, LockfileName)))...)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to load workspace root %s", root)
+	}
+	w.cache = rootShed.cache
+
+	dirs, err := w.projectDirs()
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		projectOpts := append([]Option{}, opts...)
+		if w.cache != nil {
+			projectOpts = append(projectOpts, WithCache(w.cache))
+		}
+		lockfilePath := filepath.Join(root, filepath.FromSlash(dir), LockfileName)
+		s, err := NewShed(append(projectOpts, WithLockfilePath(lockfilePath))...)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed to load workspace project %s", dir)
+		}
+		w.projects = append(w.projects, &WorkspaceProject{Dir: dir, Shed: s})
+	}
+	return w, nil
+}
+
+// projectDirs returns the directories of the projects in the workspace relative to the root, sorted.
+func (w *Workspace) projectDirs() ([]string, error) {
+	seen := make(map[string]bool)
+	var dirs []string
+	add := func(abs string) error {
+		rel, err := filepath.Rel(w.root, abs)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get path of %s relative to the workspace root", abs)
+		}
+		dir := filepath.ToSlash(rel)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+		return nil
+	}
+	for _, pattern := range w.config.Projects {
+		p := filepath.Join(w.root, filepath.FromSlash(pattern))
+		if !hasGlobMeta(pattern) {
+			if !util.IsRegularFile(filepath.Join(p, LockfileName)) {
+				return nil, errors.Errorf("no %s found for workspace project %s", LockfileName, pattern)
+			}
+			if err := add(p); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid workspace project pattern %s", pattern)
+		}
+		for _, m := range matches {
+			if !util.IsRegularFile(filepath.Join(m, LockfileName)) {
+				continue
+			}
+			if err := add(m); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// hasGlobMeta reports whether pattern contains any of the special characters of path.Match.
+func hasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+// Root returns the absolute path of the directory containing the workspace file.
+func (w *Workspace) Root() string {
+	return w.root
+}
+
+// Projects returns the projects in the workspace, sorted by directory.
+func (w *Workspace) Projects() []*WorkspaceProject {
+	return w.projects
+}
+
+// Install installs the union of the tools of every project in the workspace. Each project installs the versions
+// in its own lockfile, so a project that overrides the version of a tool uses it, while tools with the same
+// version are only installed once. See InstallProjects for details. opts.Options is ignored, since the
+// projects were already loaded by OpenWorkspace.
+func (w *Workspace) Install(ctx context.Context, opts InstallProjectsOptions) ([]ProjectResult, error) {
+	if w.cache == nil {
+		return nil, ErrNoCache
+	}
+	projects := make([]*project, len(w.projects))
+	for i, proj := range w.projects {
+		projects[i] = &project{s: proj.Shed, tools: proj.Shed.List()}
+	}
+	caches := map[string]*cache.Cache{w.cache.Dir(): w.cache}
+	return installProjects(ctx, projects, caches, opts)
+}
+
+// ToolDrift describes a tool whose version differs between the projects of a workspace.
+type ToolDrift struct {
+	// ImportPath is the import path of the tool.
+	ImportPath string
+	// Versions maps the directory of each project that uses the tool to the version in its lockfile.
+	Versions map[string]string
+	// Overridden are the directories of the projects that pin their own version of the tool on purpose,
+	// see config.Workspace.Overrides. They are sorted and aren't changed by Reconcile.
+	Overridden []string
+	// Target is the version Reconcile changes the other projects to, which is the highest
+	// version used by a project that doesn't override the tool.
+	Target string
+}
+
+// Drift returns the tools whose version differs between the projects of the workspace, sorted by import path.
+// Projects that override the version of a tool, see config.Workspace.Overrides, aren't compared with the
+// others, so a tool only drifts if the projects that don't override it use different versions.
+func (w *Workspace) Drift() []ToolDrift {
+	drifts := make(map[string]*ToolDrift)
+	for _, proj := range w.projects {
+		for _, t := range proj.Shed.List() {
+			d, ok := drifts[t.ImportPath]
+			if !ok {
+				d = &ToolDrift{ImportPath: t.ImportPath, Versions: make(map[string]string)}
+				drifts[t.ImportPath] = d
+			}
+			d.Versions[proj.Dir] = t.Version
+			if w.config.Overridden(proj.Dir, t.Name(), t.ImportPath) {
+				d.Overridden = append(d.Overridden, proj.Dir)
+			}
+		}
+	}
+
+	var out []ToolDrift
+	for _, d := range drifts {
+		distinct := make(map[string]bool)
+		for dir, v := range d.Versions {
+			if contains(d.Overridden, dir) {
+				continue
+			}
+			distinct[v] = true
+			if d.Target == "" || semver.Compare(v, d.Target) > 0 {
+				d.Target = v
+			}
+		}
+		if len(distinct) > 1 {
+			sort.Strings(d.Overridden)
+			out = append(out, *d)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ImportPath < out[j].ImportPath
+	})
+	return out
+}
+
+// Reconcile changes the lockfile of each project so that every tool that drifts, see Drift, uses its target version,
+// except in the projects that override it. The tool is copied from a project that uses the target version, keeping
+// the alias, groups, and build flags it has in the project being changed. It returns the drift that was reconciled.
+//
+// Only the lockfiles are changed, use Install to install the new versions. If some lockfiles couldn't be
+// changed, the error is a lockfile.ErrorList with the failures, and the other lockfiles are still changed.
+func (w *Workspace) Reconcile(ctx context.Context) ([]ToolDrift, error) {
+	drifts := w.Drift()
+	var errs lockfile.ErrorList
+	for _, d := range drifts {
+		// Find the tool at the target version to copy it
+		var src *WorkspaceProject
+		for _, proj := range w.projects {
+			if d.Versions[proj.Dir] == d.Target && !contains(d.Overridden, proj.Dir) {
+				src = proj
+				break
+			}
+		}
+		target, err := src.Shed.getTool(d.ImportPath)
+		if err != nil {
+			errs = append(errs, errors.WithMessagef(err, "failed to find %s in %s", d.ImportPath, src.Dir))
+			continue
+		}
+		for _, proj := range w.projects {
+			v, ok := d.Versions[proj.Dir]
+			if !ok || v == d.Target || contains(d.Overridden, proj.Dir) {
+				continue
+			}
+			s := proj.Shed
+			err := s.updateLockfile(ctx, func() error {
+				existing, err := s.lf.GetTool(d.ImportPath)
+				if err != nil {
+					return err
+				}
+				t := target
+				t.Alias = existing.Alias
+				t.Groups = existing.Groups
+				t.Why = existing.Why
+				t.BuildFlags = existing.BuildFlags
+				return s.lf.PutTool(t)
+			})
+			if err != nil {
+				errs = append(errs, errors.WithMessagef(err, "failed to reconcile %s in %s", d.ImportPath, proj.Dir))
+				continue
+			}
+			s.logger.Debugf("Changed %s from %s to %s in %s", d.ImportPath, v, d.Target, proj.Dir)
+		}
+	}
+	if len(errs) > 0 {
+		return drifts, errs
+	}
+	return drifts, nil
+}
+
+// contains reports whether s contains v.
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package client_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+)
+
+// newWorkspace creates a workspace in a temp dir with the projects services/a, services/b, and services/c,
+// where services/c overrides golangci-lint, and returns the path to the workspace file and the cache dir.
+func newWorkspace(t *testing.T) (string, string) {
+	td := t.TempDir()
+	cacheDir := filepath.Join(td, "cache")
+	root := filepath.Join(td, "repo")
+	newPruneShed(t, filepath.Join(root, "services", "a"), cacheDir, []string{
+		"github.com/cszatmary/go-fish@v0.1.0",
+		"github.com/golangci/golangci-lint/cmd/golangci-lint@v1.33.0",
+	})
+	newPruneShed(t, filepath.Join(root, "services", "b"), cacheDir, []string{
+		"github.com/golangci/golangci-lint/cmd/golangci-lint@v1.28.3",
+		"github.com/Shopify/ejson/cmd/ejson@v1.2.2",
+	})
+	newPruneShed(t, filepath.Join(root, "services", "c"), cacheDir, []string{
+		"github.com/golangci/golangci-lint/cmd/golangci-lint@v1.28.3",
+	})
+	// Matches the pattern but isn't a project since it has no lockfile
+	if err := os.MkdirAll(filepath.Join(root, "services", "docs"), 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	p := filepath.Join(root, "shed.work.json")
+	data := `{"projects": ["services/*"], "overrides": {"services/c": ["golangci-lint"]}}`
+	if err := ioutil.WriteFile(p, []byte(data), 0o644); err != nil {
+		t.Fatalf("failed to write workspace file: %v", err)
+	}
+	return p, cacheDir
+}
+
+func openWorkspace(t *testing.T, path, cacheDir string) *client.Workspace {
+	mockGo, err := cache.NewMockGo(availableTools)
+	if err != nil {
+		t.Fatalf("failed to create mock go %v", err)
+	}
+	ws, err := client.OpenWorkspace(path, client.WithCache(cache.New(cacheDir, cache.WithGo(mockGo))))
+	if err != nil {
+		t.Fatalf("failed to open workspace %v", err)
+	}
+	return ws
+}
+
+func TestOpenWorkspace(t *testing.T) {
+	p, cacheDir := newWorkspace(t)
+	if got := client.ResolveWorkspacePath(filepath.Join(filepath.Dir(p), "services", "a")); got != p {
+		t.Errorf("got workspace path %s, want %s", got, p)
+	}
+	ws := openWorkspace(t, p, cacheDir)
+	var dirs []string
+	for _, proj := range ws.Projects() {
+		dirs = append(dirs, proj.Dir)
+	}
+	wantDirs := []string{"services/a", "services/b", "services/c"}
+	if !reflect.DeepEqual(dirs, wantDirs) {
+		t.Errorf("got projects %v, want %v", dirs, wantDirs)
+	}
+
+	results, err := ws.Install(context.Background(), client.InstallProjectsOptions{Frozen: true})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if len(results) != 3 {
+		t.Errorf("got %d results, want 3", len(results))
+	}
+}
+
+func TestWorkspaceReconcile(t *testing.T) {
+	p, cacheDir := newWorkspace(t)
+	ws := openWorkspace(t, p, cacheDir)
+	drifts := ws.Drift()
+	if len(drifts) != 1 {
+		t.Fatalf("got drift %+v, want golangci-lint to drift", drifts)
+	}
+	want := client.ToolDrift{
+		ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint",
+		Versions:   map[string]string{"services/a": "v1.33.0", "services/b": "v1.28.3", "services/c": "v1.28.3"},
+		Overridden: []string{"services/c"},
+		Target:     "v1.33.0",
+	}
+	if !reflect.DeepEqual(drifts[0], want) {
+		t.Errorf("got drift %+v, want %+v", drifts[0], want)
+	}
+
+	if _, err := ws.Reconcile(context.Background()); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	root := filepath.Dir(p)
+	lfB := readLockfile(t, filepath.Join(root, "services", "b", "shed.lock"))
+	if tl, err := lfB.GetTool("golangci-lint"); err != nil || tl.Version != "v1.33.0" {
+		t.Errorf("got golangci-lint %v in services/b, want v1.33.0", tl)
+	}
+	lfC := readLockfile(t, filepath.Join(root, "services", "c", "shed.lock"))
+	if tl, err := lfC.GetTool("golangci-lint"); err != nil || tl.Version != "v1.28.3" {
+		t.Errorf("got golangci-lint %v in services/c, want the overridden v1.28.3", tl)
+	}
+
+	ws = openWorkspace(t, p, cacheDir)
+	if drifts := ws.Drift(); len(drifts) != 0 {
+		t.Errorf("got drift %+v after reconciling, want none", drifts)
+	}
+}
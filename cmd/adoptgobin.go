@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+)
+
+var adoptGOBINCmd = &cobra.Command{
+	Use:   "adopt-gobin",
+	Args:  cobra.NoArgs,
+	Short: "Remove or shadow tools from shed.lock that were installed with 'go install'.",
+	Long: `shed adopt-gobin finds binaries in GOBIN, where 'go install' puts binaries, with the same names as the
+tools in shed.lock. When GOBIN is in PATH, running one of these tools by name runs the globally installed
+version instead of the one in shed.lock, which can be a different version.
+
+By default adopt-gobin asks whether to remove each binary, so that the tool is only run with shed.
+Use --yes to remove them all without asking.
+
+Use --shadow to keep the binaries and create shims instead, like 'shed shims'. Adding the shims to PATH
+before GOBIN makes running the tools by name use the versions in shed.lock:
+
+	shed adopt-gobin --shadow
+	eval "$(shed env --path)"
+
+Binaries in other directories in PATH are reported but never removed, since they weren't installed by
+'go install'. shed itself never installs tools in GOBIN.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		setwd(logger)
+		shed := mustShed(client.WithLogger(logger))
+		ctx := context.Background()
+		gobin, err := shed.GOBIN(ctx)
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to find GOBIN")
+		}
+		globalTools, err := shed.GlobalTools(ctx)
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to find globally installed tools")
+		}
+		var inGOBIN []client.GlobalTool
+		for _, gt := range globalTools {
+			if gt.InGOBIN {
+				inGOBIN = append(inGOBIN, gt)
+			} else {
+				logger.Warnf("%s in PATH has the same name as %s, it wasn't installed by 'go install' so it is left as is", gt.Path, gt.Tool)
+			}
+		}
+		if len(inGOBIN) == 0 {
+			logger.Infof("No tools from %s found in GOBIN %s", client.LockfileName, gobin)
+			return
+		}
+
+		if adoptGOBINOpts.shadow {
+			exe, err := os.Executable()
+			if err != nil {
+				fatal.ExitErrf(err, "Failed to find the shed executable")
+			}
+			if _, err := shed.GenerateShims("", client.ShimShedPath(exe)); err != nil {
+				fatal.ExitErrf(err, "Failed to create shims")
+			}
+			for _, gt := range inGOBIN {
+				logger.Infof("Shadowing %s with a shim for %s", gt.Path, gt.Tool)
+			}
+			logger.Infof("Add the shims in %s to PATH before %s, ex: eval \"$(shed env --path)\"", shed.ShimsDir(), gobin)
+			return
+		}
+
+		if !adoptGOBINOpts.yes && !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+			fatal.Exitf("Found %d tools from %s in GOBIN %s, use --yes to remove them or --shadow to keep them", len(inGOBIN), client.LockfileName, gobin)
+		}
+		in := bufio.NewReader(os.Stdin)
+		removed := 0
+		for _, gt := range inGOBIN {
+			if !adoptGOBINOpts.yes {
+				fmt.Fprintf(os.Stderr, translate("%s has the same name as %s from %s. Remove it? [y/N] "), gt.Path, gt.Tool, client.LockfileName)
+				// No input is treated as no
+				answer, _ := in.ReadString('\n')
+				switch strings.ToLower(strings.TrimSpace(answer)) {
+				case "y", "yes":
+				default:
+					continue
+				}
+			}
+			if err := os.Remove(gt.Path); err != nil {
+				fatal.ExitErrf(err, "Failed to remove %s", gt.Path)
+			}
+			logger.Infof("Removed %s", gt.Path)
+			removed++
+		}
+		logger.Infof("Removed %d of %d tools from GOBIN %s", removed, len(inGOBIN), gobin)
+	},
+}
+
+type adoptGOBINOptions struct {
+	yes    bool
+	shadow bool
+}
+
+var adoptGOBINOpts adoptGOBINOptions
+
+func init() {
+	adoptGOBINCmd.Flags().BoolVarP(&adoptGOBINOpts.yes, "yes", "y", false, "remove the binaries without asking")
+	adoptGOBINCmd.Flags().BoolVar(&adoptGOBINOpts.shadow, "shadow", false, "create shims that shadow the binaries instead of removing them")
+	rootCmd.AddCommand(adoptGOBINCmd)
+}
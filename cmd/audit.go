@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Args:  cobra.NoArgs,
+	Short: "Check tools for known vulnerabilities.",
+	Long: `shed audit checks the tools in shed.lock against the Go vulnerability database.
+Nothing is installed and shed.lock is not modified.
+
+Each module used to build a tool is checked, including its dependencies. The dependencies are only
+known once a tool is installed, so tools that aren't installed only have their own module checked
+and a warning is printed. Run 'shed install' first to check everything.
+
+Each vulnerability that affects a tool is printed with the module it affects and the version that
+fixes it, and shed exits with a non-zero status if any tool is affected:
+
+	golangci-lint v1.28.3
+	  GO-2022-0001 in github.com/golangci/golangci-lint@v1.28.3: Crash, fixed in v1.30.0
+
+Unlike govulncheck, the code of the tools is not analyzed, so a tool is reported even if it never
+calls the vulnerable code. Set GOVULNDB to use another database, like with govulncheck.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		setwd(logger)
+		shed := mustShed(client.WithLogger(logger))
+		report, err := shed.Audit(context.Background())
+
+		for _, at := range report.Tools {
+			if !at.DepsChecked {
+				logger.Warnf("%s is not installed, only the vulnerabilities of its module were checked", at.Tool)
+			}
+		}
+		vulnerable := report.Vulnerable()
+		for _, at := range vulnerable {
+			fmt.Printf("%s %s\n", at.Tool.Name(), at.Tool.Version)
+			for _, v := range at.Vulns {
+				fix := "no fix available"
+				if v.Fixed != "" {
+					fix = "fixed in " + v.Fixed
+				}
+				fmt.Printf("  %s in %s: %s, %s\n", v.ID, v.Module, v.Summary, fix)
+			}
+		}
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to audit tools")
+		}
+		if len(vulnerable) > 0 {
+			fatal.Exitf("%d tools are affected by vulnerabilities", len(vulnerable))
+		}
+		logger.Info("No known vulnerabilities found")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"os"
+	"runtime"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/tool"
+	"github.com/spf13/cobra"
+)
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Args:  cobra.NoArgs,
+	Short: "Bundle shed and the tools in shed.lock into a directory.",
+	Long: `shed bundle creates a directory containing shed and every tool in shed.lock, built for a platform,
+so the tools can be run on machines that have neither the go command nor network access, ex: locked-down
+build farms. The tools are installed for the platform first if needed, without changing shed.lock.
+
+The bundle is a project with its own shed.lock, and a shed.config.json that uses the cache in the bundle.
+Copy the directory to the target machine and run tools from it like any other project:
+
+	cd tools-bundle
+	./shed run golangci-lint run ../...
+
+By default the bundle is for the current platform and contains the running shed executable. Use --platform
+to create a bundle for another platform, given as GOOS/GOARCH. The running shed can't be used on another
+platform, so use --shed to provide a shed executable built for it, otherwise the bundle only contains the tools.
+
+Examples:
+
+	shed bundle --output ./tools-bundle
+	shed bundle --output ./tools-bundle --platform linux/amd64 --shed ./dist/shed_linux_amd64/shed`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		origDir := setwd(logger)
+		if bundleOpts.output == "" {
+			fatal.Exitf("--output is required")
+		}
+		hostPlatform := tool.Platform(runtime.GOOS, runtime.GOARCH)
+		platform := bundleOpts.platform
+		if platform == "" {
+			platform = hostPlatform
+		}
+		if _, _, err := tool.ParsePlatform(platform); err != nil {
+			fatal.ExitErrf(err, "Invalid --platform")
+		}
+		opts := client.BundleOptions{Platform: platform}
+		if bundleOpts.shed != "" {
+			opts.ShedBinary = resolvePath(origDir, bundleOpts.shed)
+		} else if platform == hostPlatform {
+			exe, err := os.Executable()
+			if err != nil {
+				fatal.ExitErrf(err, "Failed to find the shed executable, use --shed to provide it")
+			}
+			opts.ShedBinary = exe
+		} else {
+			logger.Warnf("Not adding shed to the bundle since it isn't built for %s, use --shed to provide one that is", platform)
+		}
+
+		shed := mustShed(client.WithLogger(logger))
+		installSet, err := shed.Install()
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to install tools")
+		}
+		applyInstall(logger, installSet, client.Frozen(), client.TargetPlatform(platform))
+
+		output := resolvePath(origDir, bundleOpts.output)
+		n, err := shed.Bundle(output, opts)
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to create bundle")
+		}
+		logger.Infof("Bundled %d tools for %s in %s", n, platform, output)
+	},
+}
+
+type bundleOptions struct {
+	output   string
+	platform string
+	shed     string
+}
+
+var bundleOpts bundleOptions
+
+func init() {
+	bundleCmd.Flags().StringVarP(&bundleOpts.output, "output", "o", "", "directory to create the bundle in, must not exist or be empty")
+	bundleCmd.Flags().StringVar(&bundleOpts.platform, "platform", "", "platform to bundle the tools for, given as GOOS/GOARCH, ex: linux/amd64")
+	bundleCmd.Flags().StringVar(&bundleOpts.shed, "shed", "", "path to a shed executable built for the platform to add to the bundle")
+	rootCmd.AddCommand(bundleCmd)
+}
@@ -1,8 +1,15 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
@@ -12,7 +19,12 @@ var cacheCmd = &cobra.Command{
 	Long: `shed cache manages the cache that contains installed tools.
 
 'shed cache dir' can be used to print the path to the shed cache.
-'shed cache clean' can be used to clean the cache and remove all tools.`,
+'shed cache clean' can be used to clean the cache and remove all tools.
+'shed cache doctor' can be used to check the cache for problems.
+'shed cache prune' can be used to remove tools that are no longer used.
+'shed cache prune-modules' can be used to remove modules that are no longer used.
+'shed cache seed' can be used to add prebuilt tools to the cache from an OCI image.
+'shed cache publish-image' can be used to publish the cache as an OCI image.`,
 }
 
 var cacheCleanCmd = &cobra.Command{
@@ -22,7 +34,7 @@ var cacheCleanCmd = &cobra.Command{
 This is useful for removing any stale tools that are no longer needed.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		shed := mustShed()
-		if err := shed.CleanCache(); err != nil {
+		if err := shed.CleanCache(context.Background()); err != nil {
 			fatal.ExitErrf(err, "Failed to clean cache directory")
 		}
 	},
@@ -38,8 +50,289 @@ var cacheDirCmd = &cobra.Command{
 	},
 }
 
+var cacheDoctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Checks the shed cache for problems.",
+	Long: `Checks the shed cache for problems that would prevent tools from being installed or run.
+
+This is mainly useful for caches shared between multiple users, which can be enabled by setting
+"cache": {"shared": true} in the user config file. In shared mode the cache is checked for files
+that are not accessible by the group, files owned by the wrong group, and installed tools that
+are still writable. Each problem found is printed and shed exits with a non-zero status.
+
+In a project, the binary of each tool in shed.lock is also checked. It must exist, match the hash
+recorded when it was built, be executable, and be built for the right platform. Use --repair to
+download and build the broken tools again.
+
+In a project, doctor also warns about binaries in GOBIN or PATH with the same names as the tools
+in shed.lock, since running a tool by name could run one of them instead of the version in shed.lock.
+Use 'shed adopt-gobin' to remove or shadow the ones in GOBIN.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		shed := mustShed(client.WithLogger(logger))
+		if cacheDoctorOpts.repair {
+			repaired, err := shed.RepairCache(context.Background())
+			for _, t := range repaired {
+				logger.Infof("Reinstalled %s", t)
+			}
+			if err != nil {
+				fatal.ExitErrf(err, "Failed to repair cache")
+			}
+		}
+		problems, err := shed.CheckCache()
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to check cache directory")
+		}
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+		// Not being able to run the go command shouldn't hide the problems with the cache
+		globalTools, err := shed.GlobalTools(context.Background())
+		if err != nil {
+			logger.WithError(err).Warn("Failed to check for globally installed tools")
+		}
+		for _, gt := range globalTools {
+			where := "PATH"
+			if gt.InGOBIN {
+				where = "GOBIN"
+			}
+			logger.Warnf("%s in %s has the same name as %s from %s, see 'shed adopt-gobin'", gt.Path, where, gt.Tool, client.LockfileName)
+		}
+		if len(problems) > 0 {
+			fatal.Exitf("Found %d problems in cache %s", len(problems), shed.CacheDir())
+		}
+	},
+}
+
+var cacheDoctorOpts struct {
+	repair bool
+}
+
+var cacheSeedOpts struct {
+	fromImage string
+}
+
+var cacheSeedCmd = &cobra.Command{
+	Use:   "seed --from-image <image>",
+	Short: "Adds prebuilt tools to the shed cache from an OCI image.",
+	Long: `Pulls an OCI image containing prebuilt tools, such as one created with 'shed cache publish-image',
+and adds the tools to the shed cache. Tools that are already in the cache are left as is.
+This allows installs in fresh environments like CI to skip downloading and building tools.
+
+The image is pulled anonymously unless SHED_REGISTRY_USERNAME and SHED_REGISTRY_PASSWORD are set.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if cacheSeedOpts.fromImage == "" {
+			fatal.Exitf("--from-image is required")
+		}
+		logger := newLogger()
+		setwd(logger)
+		shed := mustShed(client.WithLogger(logger))
+		n, err := shed.SeedCache(context.Background(), cacheSeedOpts.fromImage)
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to seed cache")
+		}
+		logger.Infof("Added %d tools to the cache", n)
+	},
+}
+
+var cachePublishImageCmd = &cobra.Command{
+	Use:   "publish-image <image>",
+	Short: "Publishes the shed cache as an OCI image.",
+	Long: `Pushes all the tools in the shed cache as an OCI image, ex: ghcr.io/org/toolcache:latest.
+The image can be used with 'shed cache seed' to add the tools to another cache.
+
+Run 'shed install' first so the cache contains the tools in shed.lock. The registry credentials
+are read from SHED_REGISTRY_USERNAME and SHED_REGISTRY_PASSWORD.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		setwd(logger)
+		shed := mustShed(client.WithLogger(logger))
+		n, err := shed.PublishCacheImage(context.Background(), args[0])
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to publish cache")
+		}
+		logger.Infof("Published %d tools to %s", n, args[0])
+	},
+}
+
+var cachePruneOpts struct {
+	maxAge    time.Duration
+	maxSize   string
+	dryRun    bool
+	trashTTL  time.Duration
+	skipTrash bool
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Removes unused tools from the shed cache.",
+	Long: `Removes tools from the shed cache that aren't used by any known lockfile, and prints how much space was reclaimed.
+
+Known lockfiles are the shed.lock for the current directory and every shed.lock that tools have been
+installed for with the cache. Lockfiles that no longer exist are forgotten. Tools that are used by a
+known lockfile are never removed.
+
+By default all unused tools are removed. Use --max-age to only remove tools installed longer ago than
+the given duration, and --max-size to remove the oldest tools until the cache is at most the given size.
+If both are given, tools that match either are removed. Sizes are in bytes, or use a suffix like 500M or 2G.
+
+Removed tools are moved to the trash of the cache, so that 'shed restore' can bring them back without
+installing them again. Each prune purges tools that have been in the trash for longer than --trash-ttl,
+7 days by default. Use --skip-trash to delete removed tools right away.
+
+The module cache of the go command is not changed, use 'go clean -modcache' to clean it.
+If the cache has its own module cache, use 'shed cache prune-modules' to prune it.
+
+Examples:
+
+Remove unused tools installed more than 30 days ago:
+
+	shed cache prune --max-age 720h
+
+Keep the cache under 1 GiB:
+
+	shed cache prune --max-size 1G`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := cache.PruneOptions{
+			MaxAge:    cachePruneOpts.maxAge,
+			DryRun:    cachePruneOpts.dryRun,
+			TrashTTL:  cachePruneOpts.trashTTL,
+			SkipTrash: cachePruneOpts.skipTrash,
+		}
+		if cachePruneOpts.maxSize != "" {
+			var err error
+			opts.MaxSize, err = parseSize(cachePruneOpts.maxSize)
+			if err != nil {
+				fatal.ExitErrf(err, "Invalid --max-size")
+			}
+		}
+		logger := newLogger()
+		setwd(logger)
+		shed := mustShed(client.WithLogger(logger))
+		res, err := shed.PruneCache(context.Background(), opts)
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to prune cache")
+		}
+		for _, t := range res.Removed {
+			fmt.Printf("%s\t%s\n", formatSize(t.Size), t.Path)
+		}
+		verb := "Reclaimed"
+		if opts.DryRun {
+			verb = "Would reclaim"
+		}
+		logger.Infof("%s %s from %d tools, the cache is now %s", verb, formatSize(res.Reclaimed), len(res.Removed), formatSize(res.Size))
+		if res.Purged > 0 {
+			verb = "Purged"
+			if opts.DryRun {
+				verb = "Would purge"
+			}
+			logger.Infof("%s %s of expired tools from the trash", verb, formatSize(res.Purged))
+		}
+	},
+}
+
+var cachePruneModulesOpts struct {
+	dryRun bool
+}
+
+var cachePruneModulesCmd = &cobra.Command{
+	Use:   "prune-modules",
+	Short: "Removes unused modules from the module cache of the shed cache.",
+	Long: `Removes module versions from the module cache of the shed cache that aren't needed to build the tools
+of any known lockfile, and prints how much space was reclaimed. See 'shed cache prune' for which lockfiles are known.
+
+The module cache grows much faster than the tools in the cache, since every dependency of every version of
+every tool is downloaded. The modules needed by a tool are the ones recorded in its go.sum when it was built.
+
+This only works if the shed cache has its own module cache, which is enabled by setting
+"cache": {"modules": true} in the user config file. Otherwise the module cache of the go command
+is used, which is shared with everything else and can't be pruned by shed.
+
+Examples:
+
+See which modules would be removed:
+
+	shed cache prune-modules --dry-run`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		setwd(logger)
+		shed := mustShed(client.WithLogger(logger))
+		if !userContext.Cache.Modules && !userContext.Cache.Isolated {
+			fatal.Exitf(`The shed cache doesn't have its own module cache, set "cache": {"modules": true} in the user config to enable it`)
+		}
+		res, err := shed.PruneModules(context.Background(), cachePruneModulesOpts.dryRun)
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to prune module cache")
+		}
+		for _, m := range res.Removed {
+			fmt.Printf("%s\t%s@%s\n", formatSize(m.Size), m.Module.Path, m.Module.Version)
+		}
+		verb := "Reclaimed"
+		if cachePruneModulesOpts.dryRun {
+			verb = "Would reclaim"
+		}
+		logger.Infof("%s %s from %d modules", verb, formatSize(res.Reclaimed), len(res.Removed))
+	},
+}
+
+// sizeUnits are the suffixes accepted by parseSize, largest first.
+var sizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+}
+
+// parseSize parses a size in bytes with an optional binary suffix, ex: 500M or 2GiB.
+func parseSize(s string) (int64, error) {
+	num := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(s), "B"), "I")
+	mult := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(num, u.suffix) {
+			num = strings.TrimSuffix(num, u.suffix)
+			mult = u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
+
+// formatSize formats a size in bytes for display, ex: 1.5M.
+func formatSize(n int64) string {
+	for _, u := range sizeUnits {
+		if n >= u.size {
+			return strconv.FormatFloat(float64(n)/float64(u.size), 'f', 1, 64) + u.suffix
+		}
+	}
+	return strconv.FormatInt(n, 10) + "B"
+}
+
 func init() {
+	cachePruneCmd.Flags().DurationVar(&cachePruneOpts.maxAge, "max-age", 0, "only remove tools installed longer ago than this duration")
+	cachePruneCmd.Flags().StringVar(&cachePruneOpts.maxSize, "max-size", "", "remove the oldest tools until the cache is at most this size")
+	cachePruneCmd.Flags().BoolVar(&cachePruneOpts.dryRun, "dry-run", false, "print the tools that would be removed without removing them")
+	cachePruneCmd.Flags().DurationVar(&cachePruneOpts.trashTTL, "trash-ttl", cache.DefaultTrashTTL, "how long removed tools are kept in the trash before they are purged")
+	cachePruneCmd.Flags().BoolVar(&cachePruneOpts.skipTrash, "skip-trash", false, "delete removed tools right away instead of moving them to the trash")
+	cacheCmd.AddCommand(cachePruneCmd)
+	cachePruneModulesCmd.Flags().BoolVar(&cachePruneModulesOpts.dryRun, "dry-run", false, "print the modules that would be removed without removing them")
+	cacheCmd.AddCommand(cachePruneModulesCmd)
+	cacheSeedCmd.Flags().StringVar(&cacheSeedOpts.fromImage, "from-image", "", "OCI image to seed the cache from")
+	cacheCmd.AddCommand(cacheSeedCmd)
+	cacheCmd.AddCommand(cachePublishImageCmd)
 	cacheCmd.AddCommand(cacheCleanCmd)
+	cacheDoctorCmd.Flags().BoolVar(&cacheDoctorOpts.repair, "repair", false, "reinstall the tools in shed.lock whose binaries are broken")
+	cacheCmd.AddCommand(cacheDoctorCmd)
 	cacheCmd.AddCommand(cacheDirCmd)
 	rootCmd.AddCommand(cacheCmd)
 }
@@ -10,15 +10,25 @@ import (
 var completionsCmd = &cobra.Command{
 	Use:       "completions <shell>",
 	Args:      cobra.ExactArgs(1),
-	ValidArgs: []string{"bash", "zsh"},
+	ValidArgs: []string{"bash", "zsh", "fish"},
 	Short:     "Generate shell completions.",
 	Long: `shed completions generates a shell completion script and outputs it to standard output.
-Supported shells are: bash, zsh.
+Supported shells are: bash, zsh, fish.
+
+Besides shed's own commands and flags, the completions complete the names of the tools in shed.lock
+for commands like 'shed run' and 'shed uninstall', and the arguments of tools run with 'shed run'.
+'shed run' also completes the tools in the global lockfile, see 'shed install --global'.
+Tool names are read from shed.lock each time, so the completions don't need to be generated again
+when tools change.
 
 For example to generate and use bash completions:
 
 	shed completions bash > /usr/local/etc/bash_completion.d/shed.bash
-	source /usr/local/etc/bash_completion.d/shed.bash`,
+	source /usr/local/etc/bash_completion.d/shed.bash
+
+To use fish completions:
+
+	shed completions fish > ~/.config/fish/completions/shed.fish`,
 	Run: func(cmd *cobra.Command, args []string) {
 		shell := args[0]
 		var err error
@@ -27,6 +37,8 @@ For example to generate and use bash completions:
 			err = rootCmd.GenBashCompletion(os.Stdout)
 		case "zsh":
 			err = rootCmd.GenZshCompletion(os.Stdout)
+		case "fish":
+			err = rootCmd.GenFishCompletion(os.Stdout, true)
 		default:
 			err = fmt.Errorf("invalid shell value %q, run 'shed completions --help' to see supported shells", shell)
 		}
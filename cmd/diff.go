@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/getshiphub/shed/api"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [old] [new]",
+	Args:  cobra.MaximumNArgs(2),
+	Short: "Show which tools changed between two versions of shed.lock.",
+	Long: `shed diff compares two versions of shed.lock and prints the tools that were added, removed, or changed.
+A tool is changed if its version, module hash, build flags, alias, release, or groups differ.
+
+With no arguments, shed.lock in the git index is compared with shed.lock in the working tree, like 'git diff'.
+Use --rev to compare with shed.lock at a git revision instead, ex: the base branch of a pull request.
+A shed.lock that doesn't exist at the revision is treated as empty, so every tool is added.
+Given one file, it is compared with shed.lock. Given two files, the first is compared with the second.
+
+Use --exit-code to exit with status 1 if any tools changed, ex: in a pre-commit hook.
+Use --format=json to print the changes as JSON for bots and scripts. The output is an object with a
+changes array, each with change, importPath, oldVersion, newVersion, and the fields that changed.
+The schema is defined by LockfileDiff in the github.com/getshiphub/shed/api package.
+
+Examples:
+
+Summarize the tool changes of a pull request:
+
+	shed diff --rev origin/main
+
+Compare two lockfiles:
+
+	shed diff old/shed.lock new/shed.lock`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		cwd := setwd(logger)
+		if diffOpts.rev != "" && len(args) > 0 {
+			fatal.Exitf("--rev can't be used when comparing files")
+		}
+
+		newPath := client.LockfileName
+		if len(args) == 2 {
+			newPath = resolvePath(cwd, args[1])
+		} else if client.ResolveLockfilePath(cwd) == "" {
+			fatal.Exitf("No %s found in %s or any parent directory", client.LockfileName, cwd)
+		}
+		var oldLf *lockfile.Lockfile
+		var oldName string
+		var err error
+		if len(args) > 0 {
+			oldName = resolvePath(cwd, args[0])
+			oldLf, err = readLockfileFile(oldName)
+		} else {
+			oldName = diffOpts.rev + ":" + client.LockfileName
+			oldLf, err = readGitLockfile(diffOpts.rev)
+		}
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to read file %s", oldName)
+		}
+		newLf, err := readLockfileFile(newPath)
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to read file %s", newPath)
+		}
+
+		changes := lockfile.Diff(oldLf, newLf)
+		switch diffOpts.format {
+		case "text":
+			printChanges(changes)
+		case "json":
+			// Use an empty array instead of null when nothing changed to make it easier for scripts
+			diff := api.LockfileDiff{SchemaVersion: api.SchemaVersion, Changes: make([]api.ToolChange, 0, len(changes))}
+			for _, c := range changes {
+				diff.Changes = append(diff.Changes, api.ToolChange{
+					Change:     c.Kind.String(),
+					ImportPath: c.ImportPath(),
+					OldVersion: c.Old.Version,
+					NewVersion: c.New.Version,
+					Fields:     c.Fields,
+				})
+			}
+			data, err := json.MarshalIndent(diff, "", "  ")
+			if err != nil {
+				fatal.ExitErrf(err, "Failed to serialize changes as JSON")
+			}
+			fmt.Println(string(data))
+		default:
+			fatal.Exitf("Invalid format %q, must be one of: text, json", diffOpts.format)
+		}
+		if diffOpts.exitCode && len(changes) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// printChanges prints a line for each change, with the versions of changed tools.
+func printChanges(changes []lockfile.Change) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, c := range changes {
+		switch c.Kind {
+		case lockfile.ChangeAdded:
+			fmt.Fprintf(w, "%s\t%s\t%s\n", c.Kind, c.ImportPath(), c.New.Version)
+		case lockfile.ChangeRemoved:
+			fmt.Fprintf(w, "%s\t%s\t%s\n", c.Kind, c.ImportPath(), c.Old.Version)
+		default:
+			version := c.New.Version
+			if c.Old.Version != c.New.Version {
+				version = c.Old.Version + " -> " + c.New.Version
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.Kind, c.ImportPath(), version, strings.Join(c.Fields, ", "))
+		}
+	}
+	w.Flush()
+}
+
+func readLockfileFile(p string) (*lockfile.Lockfile, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return lockfile.Parse(f)
+}
+
+// readGitLockfile reads shed.lock in the current directory at the git revision rev,
+// or in the git index if rev is empty. If shed.lock doesn't exist at rev, an empty lockfile is returned.
+func readGitLockfile(rev string) (*lockfile.Lockfile, error) {
+	// Check the revision separately, so that a bad revision isn't mistaken for a missing lockfile
+	if rev != "" {
+		if _, err := git("rev-parse", "--verify", "--quiet", rev+"^{commit}"); err != nil {
+			return nil, errors.Errorf("unknown git revision %s", rev)
+		}
+	} else if _, err := git("rev-parse", "--git-dir"); err != nil {
+		return nil, err
+	}
+	object := rev + ":./" + client.LockfileName
+	if _, err := git("cat-file", "-e", object); err != nil {
+		return &lockfile.Lockfile{}, nil
+	}
+	data, err := git("show", object)
+	if err != nil {
+		return nil, err
+	}
+	return lockfile.Parse(bytes.NewReader(data))
+}
+
+// git runs git with args in the current directory and returns its output.
+func git(args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	c := exec.Command("git", args...)
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		return nil, errors.Wrapf(err, "failed to run 'git %s', stderr: %s", strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+type diffOptions struct {
+	rev      string
+	format   string
+	exitCode bool
+}
+
+var diffOpts diffOptions
+
+func init() {
+	diffCmd.Flags().StringVar(&diffOpts.rev, "rev", "", "git revision to compare shed.lock with instead of the git index")
+	diffCmd.Flags().StringVar(&diffOpts.format, "format", "text", "format to print the changes in, text or json")
+	diffCmd.Flags().BoolVar(&diffOpts.exitCode, "exit-code", false, "exit with status 1 if any tools changed")
+	rootCmd.AddCommand(diffCmd)
+}
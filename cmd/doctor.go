@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/spf13/cobra"
+)
+
+var doctorOpts struct {
+	offline bool
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Args:  cobra.NoArgs,
+	Short: "Check the environment for problems.",
+	Long: `shed doctor checks the environment for problems that would prevent tools from being installed or run,
+and prints each one found with its severity and how to fix it:
+
+	go        the go command can be run and is new enough to build the tools in shed.lock
+	cache     the cache directory is writable and the tools in it are intact, see 'shed cache doctor'
+	lockfile  shed.lock can be parsed, uses the current format, and records the hash of each tool
+	goproxy   the module proxies in GOPROXY are reachable
+	orphans   tools in the cache that aren't used by any known lockfile, see 'shed cache prune'
+
+Use --offline to skip the checks that use the network.
+
+shed exits with a non-zero status if any errors are found. Warnings and info don't change the exit status.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		setwd(logger)
+		shed, err := client.NewShed(mustShedOptions(client.WithLogger(logger))...)
+		if err != nil {
+			// Nothing else can be checked, but it is still a finding rather than a failure to run doctor
+			printFinding(client.Finding{
+				Check:    client.CheckLockfile,
+				Severity: client.SeverityError,
+				Message:  err.Error(),
+				Fix:      "Fix the errors in shed.lock or shed.config.json",
+			})
+			fatal.Exitf("Found %d errors", 1)
+		}
+		findings := shed.Diagnose(context.Background(), client.DiagnoseOptions{Offline: doctorOpts.offline})
+		errCount := 0
+		for _, f := range findings {
+			printFinding(f)
+			if f.Severity == client.SeverityError {
+				errCount++
+			}
+		}
+		if errCount > 0 {
+			fatal.Exitf("Found %d errors", errCount)
+		}
+		if len(findings) == 0 {
+			logger.Info("No problems found")
+		}
+	},
+}
+
+func printFinding(f client.Finding) {
+	fmt.Printf("%s\t%s: %s\n", f.Severity, f.Check, f.Message)
+	if f.Fix != "" {
+		fmt.Printf("\tfix: %s\n", f.Fix)
+	}
+}
+
+func init() {
+	doctorCmd.Flags().BoolVar(&doctorOpts.offline, "offline", false, "skip the checks that use the network")
+	rootCmd.AddCommand(doctorCmd)
+}
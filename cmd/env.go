@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/getshiphub/shed/api"
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/config"
+	"github.com/getshiphub/shed/xdg"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var envCmd = &cobra.Command{
+	Use:   "env [var...]",
+	Args:  cobra.ArbitraryArgs,
+	Short: "Print shed environment information.",
+	Long: `shed env prints information about the directories and files used by shed.
+
+By default env prints the information as a shell script (on Windows, a batch file).
+If one or more variable names are given as arguments, env prints the value of each
+named variable on its own line.
+
+The directories are determined by the XDG environment variables if they are set,
+and the conventional directories for the platform otherwise. SHED_CACHE_DIR can also be set
+to use a different cache directory, which takes precedence over the user config.
+
+Variables:
+
+	SHED_CACHE_DIR        directory where tools are installed
+	SHED_CONFIG_DIR       directory containing the user config file
+	SHED_CONFIG_FILE      path to the user config file
+	SHED_STATE_DIR        directory where shed stores state between runs
+	SHED_BIN_DIR          directory where shed places executables
+	SHED_LOCKFILE         path to the shed.lock file for the current directory, if one exists
+	SHED_GLOBAL_LOCKFILE  path to the global shed.lock file used by 'shed install --global'
+	SHED_PROJECT_DIR      directory containing the project shed.lock file, if one exists
+	SHED_SHIMS_DIR        directory containing the shims for the project, if one exists
+	SHED_CONTEXT          name of the selected context in the user config, if any
+
+Use --json to print the variables as a JSON object, which also has the version of its schema
+in schemaVersion, see the documentation of the github.com/getshiphub/shed/api package.
+
+Use --path to print the command that adds the shims for the project, created with 'shed shims',
+to PATH, ex: in a shell profile or .envrc:
+
+	eval "$(shed env --path)"`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		env := shedEnv(logger)
+		if len(args) > 0 {
+			for _, name := range args {
+				v, ok := env.lookup(name)
+				if !ok {
+					fatal.Exitf("Unknown variable %s, run 'shed env --help' to see all variables", name)
+				}
+				fmt.Println(v)
+			}
+			return
+		}
+
+		if envOpts.path {
+			dir, _ := env.lookup("SHED_SHIMS_DIR")
+			if dir == "" {
+				fatal.Exitf("No %s found, shims can only be used in a project", client.LockfileName)
+			}
+			if runtime.GOOS == "windows" {
+				fmt.Printf("set PATH=%s;%%PATH%%\n", dir)
+			} else {
+				fmt.Printf("export PATH=%q:\"$PATH\"\n", dir)
+			}
+			return
+		}
+		if envOpts.json {
+			data, err := json.MarshalIndent(env.toAPI(), "", "  ")
+			if err != nil {
+				fatal.ExitErrf(err, "Failed to serialize environment as JSON")
+			}
+			fmt.Println(string(data))
+			return
+		}
+		for _, v := range env {
+			if runtime.GOOS == "windows" {
+				fmt.Printf("set %s=%s\n", v.name, v.value)
+			} else {
+				fmt.Printf("%s=%q\n", v.name, v.value)
+			}
+		}
+	},
+}
+
+type envVar struct {
+	name  string
+	value string
+}
+
+type envVars []envVar
+
+func (e envVars) lookup(name string) (string, bool) {
+	for _, v := range e {
+		if v.name == name {
+			return v.value, true
+		}
+	}
+	return "", false
+}
+
+// toAPI returns the variables in the form printed by shed env --json.
+func (e envVars) toAPI() api.Env {
+	get := func(name string) string {
+		v, _ := e.lookup(name)
+		return v
+	}
+	return api.Env{
+		SchemaVersion:  api.SchemaVersion,
+		CacheDir:       get("SHED_CACHE_DIR"),
+		ConfigDir:      get("SHED_CONFIG_DIR"),
+		ConfigFile:     get("SHED_CONFIG_FILE"),
+		StateDir:       get("SHED_STATE_DIR"),
+		BinDir:         get("SHED_BIN_DIR"),
+		Lockfile:       get("SHED_LOCKFILE"),
+		GlobalLockfile: get("SHED_GLOBAL_LOCKFILE"),
+		ProjectDir:     get("SHED_PROJECT_DIR"),
+		ShimsDir:       get("SHED_SHIMS_DIR"),
+		Context:        get("SHED_CONTEXT"),
+	}
+}
+
+// shedEnv computes the variables printed by shed env.
+func shedEnv(logger *logrus.Logger) envVars {
+	dirs, err := xdg.All()
+	if err != nil {
+		fatal.ExitErrf(err, "Failed to determine shed directories")
+	}
+	userConfigPath, err := config.UserPath()
+	if err != nil {
+		fatal.ExitErrf(err, "Failed to find user config")
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		fatal.ExitErrf(err, "Failed to get current working directory")
+	}
+	lockfilePath := client.ResolveLockfilePath(cwd)
+	projectDir := ""
+	shimsDir := ""
+	if lockfilePath != "" {
+		logger.Debugf("Found lockfile: %s", lockfilePath)
+		projectDir = filepath.Dir(lockfilePath)
+		shimsDir = filepath.Join(projectDir, client.ProjectDirName, client.ShimsDirName)
+	}
+
+	// The cache dir can be overridden in the user config or with SHED_CACHE_DIR
+	cacheDir := userCacheDir(userContext)
+	if cacheDir == "" {
+		cacheDir, err = cache.DefaultDir()
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to determine shed directories")
+		}
+	}
+	return envVars{
+		{"SHED_CACHE_DIR", cacheDir},
+		{"SHED_CONFIG_DIR", dirs.Config},
+		{"SHED_CONFIG_FILE", userConfigPath},
+		{"SHED_STATE_DIR", dirs.State},
+		{"SHED_BIN_DIR", dirs.Bin},
+		{"SHED_LOCKFILE", lockfilePath},
+		{"SHED_GLOBAL_LOCKFILE", filepath.Join(dirs.Config, client.LockfileName)},
+		{"SHED_PROJECT_DIR", projectDir},
+		{"SHED_SHIMS_DIR", shimsDir},
+		{"SHED_CONTEXT", userContextName},
+	}
+}
+
+type envOptions struct {
+	json bool
+	path bool
+}
+
+var envOpts envOptions
+
+func init() {
+	envCmd.Flags().BoolVar(&envOpts.json, "json", false, "print the environment in JSON format")
+	envCmd.Flags().BoolVar(&envOpts.path, "path", false, "print the command that adds the shims for the project to PATH")
+	rootCmd.AddCommand(envCmd)
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/spf13/cobra"
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec <import-path>[@version] [--] [args...]",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Run a tool without adding it to shed.lock.",
+	Long: `shed exec runs a tool one-off, without adding it to shed.lock, ex: to try a tool before
+committing to it. The tool is built in the shed cache, so running it again is fast, but shed.lock
+is never changed.
+
+The version can be any version 'go install' accepts, ex: a version, a branch, or a commit.
+If it is omitted, the latest version is used. Versions that aren't exact, like 'latest', are
+resolved again each time. The tool can also be the name of a tool in shed.lock, in which case
+the version defaults to the one in shed.lock, ex: to try a newer version of it.
+
+All arguments after the tool are passed to it as is. A '--' right after the tool is skipped.
+For example:
+
+	shed exec golang.org/x/tools/cmd/stringer@v0.12.0 -- -type=Pill
+	shed exec golangci-lint@v1.55.0 run ./...
+
+The flags are the same as for 'shed run', and modules are trusted the same way.
+Use 'shed install' to add the tool to shed.lock once you decide to keep it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		toolArgs := args[1:]
+		if len(toolArgs) > 0 && toolArgs[0] == "--" {
+			toolArgs = toolArgs[1:]
+		}
+		logger := newLogger()
+		origDir := setwd(logger)
+		shed := mustShed(client.WithLogger(logger), trustOption())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		opts := newRunOptions(ctx, cancel, cmd, origDir)
+		t, report, err := shed.Exec(ctx, name, toolArgs, opts)
+		if errors.Is(err, lockfile.ErrNotFound) {
+			exitToolName(err, "No tool named %s in shed.lock. Use the full import path of the tool to run it without shed.lock.", name)
+		} else if errors.Is(err, lockfile.ErrMultipleTools) {
+			exitToolName(err, "Multiple tools named %s found. Specify the full import path of the tool in order to run it.", name)
+		} else if errors.Is(err, client.ErrUntrusted) {
+			fatal.Exitf("Not running %s since its module was not trusted.", name)
+		} else if errors.Is(err, cache.ErrVersionNotFound) {
+			fatal.ExitErrf(err, "No version of %s matches %s", t.ImportPath, t.Version)
+		} else if report == nil && err != nil {
+			fatal.ExitErrf(err, "Failed to install %s", name)
+		}
+		if runOpts.stats {
+			printStats(report, name)
+		}
+		exitRun(logger, report, err, name)
+	},
+}
+
+func init() {
+	execCmd.Flags().SetInterspersed(false)
+	execCmd.ValidArgsFunction = completeToolNames
+	addRunFlags(execCmd)
+	rootCmd.AddCommand(execCmd)
+}
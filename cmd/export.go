@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export --format <format>",
+	Args:  cobra.NoArgs,
+	Short: "Export the tools in shed.lock for other build systems.",
+	Long: `shed export writes the tools in shed.lock in a format that other build systems can use,
+so that they use the exact same versions of the tools as shed. The export is written to stdout,
+or to the file given by --output.
+
+The supported formats are:
+
+	bazel  A Starlark file that declares a go_repository rule for the module of each tool,
+	       pinned to the version in shed.lock and the module hash. Load it in the WORKSPACE
+	       file and call shed_tools(). SHED_TOOLS maps each tool name to the label of its binary.
+	       Tools without a module hash in shed.lock use the hash from the shed cache,
+	       so run 'shed install' first.
+
+	mise   A mise.toml config that pins each tool using the go backend of mise.
+	       Use 'shed import mise' to go the other way.
+
+	devcontainer-feature
+	       A dev container feature that installs shed and runs 'shed install --frozen' when the
+	       container is created. It is a directory, so --output is required. The feature installs
+	       the version of shed that exported it. It doesn't change when shed.lock does, since the
+	       tools are read from shed.lock in the container.
+
+	gomod  The tool and require directives of a go.mod file, for running the tools with 'go tool'
+	       in Go 1.24 and later. Merge them into go.mod and run 'go mod tidy'. Aliases and build
+	       flags can't be expressed in go.mod, so they are left out. Use 'shed import gomod' to
+	       go the other way.
+
+	cyclonedx
+	       A CycloneDX JSON software bill of materials (SBOM) that lists each tool and the modules
+	       used to build it, identified by their package URLs. The modules are read from the shed
+	       cache, so run 'shed install' first.
+
+	spdx   Like cyclonedx, but an SPDX JSON document.
+
+SBOMs record when they were created. Set SOURCE_DATE_EPOCH to the number of seconds since the Unix epoch
+to use that time instead of the current time, which is required for --check to work with them.
+
+Use --check with --output to make sure an export is up to date with shed.lock, ex: in CI.
+Nothing is written and shed exits with a non-zero status if the file is out of date.
+
+Examples:
+
+Generate the Bazel tool repositories:
+
+	shed export --format=bazel --output=shed_tools.bzl
+
+Check that the Bazel tool repositories are up to date:
+
+	shed export --format=bazel --output=shed_tools.bzl --check
+
+Generate a mise config:
+
+	shed export --format=mise --output=mise.toml
+
+Print the go.mod tool directives:
+
+	shed export --format=gomod
+
+Generate an SBOM of the tools:
+
+	shed export --format=cyclonedx --output=tools.cdx.json
+
+Generate a dev container feature:
+
+	shed export --format=devcontainer-feature --output=.devcontainer/shed`,
+	Run: func(cmd *cobra.Command, args []string) {
+		format, err := client.ParseExportFormat(exportOpts.format)
+		if err != nil {
+			var formats []string
+			for _, f := range client.ExportFormats() {
+				formats = append(formats, string(f))
+			}
+			fatal.ExitErrf(err, "Invalid format, must be one of: %s", strings.Join(formats, ", "))
+		}
+		if exportOpts.check && exportOpts.output == "" {
+			fatal.Exitf("--check requires --output")
+		}
+		if format.IsDir() && exportOpts.output == "" {
+			fatal.Exitf("--output is required for format %s since it is a directory", format)
+		}
+
+		logger := newLogger()
+		origDir := setwd(logger)
+		shed := mustShed(client.WithLogger(logger))
+		if format.IsDir() {
+			exportDir(shed, format, resolvePath(origDir, exportOpts.output))
+			return
+		}
+		opts := []client.ExportOption{client.ExportShedVersion(version)}
+		if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+			sec, err := strconv.ParseInt(epoch, 10, 64)
+			if err != nil {
+				fatal.ExitErrf(err, "Invalid SOURCE_DATE_EPOCH")
+			}
+			opts = append(opts, client.ExportTime(time.Unix(sec, 0)))
+		}
+		buf := &bytes.Buffer{}
+		if err := shed.Export(buf, format, opts...); err != nil {
+			fatal.ExitErrf(err, "Failed to export tools")
+		}
+
+		if exportOpts.output == "" {
+			if _, err := buf.WriteTo(os.Stdout); err != nil {
+				fatal.ExitErrf(err, "Failed to write export")
+			}
+			return
+		}
+		outPath := resolvePath(origDir, exportOpts.output)
+		if exportOpts.check {
+			data, err := ioutil.ReadFile(outPath)
+			if err != nil && !os.IsNotExist(err) {
+				fatal.ExitErrf(err, "Failed to read file %s", outPath)
+			}
+			if !bytes.Equal(data, buf.Bytes()) {
+				fatal.Exitf("%s is out of date with shed.lock, run 'shed export --format=%s --output=%s' to update it", exportOpts.output, format, exportOpts.output)
+			}
+			return
+		}
+		if err := ioutil.WriteFile(outPath, buf.Bytes(), 0o644); err != nil {
+			fatal.ExitErrf(err, "Failed to write file %s", outPath)
+		}
+	},
+}
+
+// exportDir exports a format that is a directory to dir, or checks that dir is up to date if --check was used.
+func exportDir(shed *client.Shed, format client.ExportFormat, dir string) {
+	files, err := shed.ExportFiles(format, client.ExportShedVersion(version))
+	if err != nil {
+		fatal.ExitErrf(err, "Failed to export tools")
+	}
+	if exportOpts.check {
+		for _, f := range files {
+			p := filepath.Join(dir, filepath.FromSlash(f.Name))
+			data, err := ioutil.ReadFile(p)
+			if err != nil && !os.IsNotExist(err) {
+				fatal.ExitErrf(err, "Failed to read file %s", p)
+			}
+			if !bytes.Equal(data, f.Data) {
+				fatal.Exitf("%s is out of date, run 'shed export --format=%s --output=%s' to update it", p, format, exportOpts.output)
+			}
+		}
+		return
+	}
+	for _, f := range files {
+		p := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			fatal.ExitErrf(err, "Failed to create directory %s", filepath.Dir(p))
+		}
+		var perm os.FileMode = 0o644
+		if f.Executable {
+			perm = 0o755
+		}
+		if err := ioutil.WriteFile(p, f.Data, perm); err != nil {
+			fatal.ExitErrf(err, "Failed to write file %s", p)
+		}
+		// WriteFile only sets the permissions of new files
+		if err := os.Chmod(p, perm); err != nil {
+			fatal.ExitErrf(err, "Failed to set permissions of file %s", p)
+		}
+	}
+}
+
+type exportOptions struct {
+	format string
+	output string
+	check  bool
+}
+
+var exportOpts exportOptions
+
+func init() {
+	exportCmd.Flags().StringVar(&exportOpts.format, "format", "", "format to export the tools in")
+	exportCmd.Flags().StringVarP(&exportOpts.output, "output", "o", "", "file to write the export to instead of stdout")
+	exportCmd.Flags().BoolVar(&exportOpts.check, "check", false, "fail if the output file is out of date instead of writing it")
+	rootCmd.AddCommand(exportCmd)
+}
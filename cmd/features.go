@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/spf13/cobra"
+)
+
+var featuresCmd = &cobra.Command{
+	Use:   "features",
+	Short: "Manage experimental features.",
+	Long: `shed features manages experimental features, which are disabled by default until they are stable.
+
+A feature can be enabled or disabled for a project with "features": {"<name>": true} in shed.config.json,
+or for all projects in the user config file. SHED_FEATURES takes precedence over both. It is a
+comma-separated list of features to enable, where a name prefixed with '-' disables the feature.
+
+'shed features list' can be used to list features and whether they are enabled.`,
+}
+
+var featuresListCmd = &cobra.Command{
+	Use:   "list",
+	Args:  cobra.NoArgs,
+	Short: "List experimental features.",
+	Long: `Lists every experimental feature, whether it is enabled, and where that was decided:
+default, project, user, or env.
+
+Features that aren't known to this version of shed are listed as unknown and are ignored.
+
+Examples:
+
+	shed features list
+	SHED_FEATURES=remote-tools shed features list`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		setwd(logger)
+		shed := mustShed(client.WithLogger(logger), client.WithNoCache())
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "FEATURE\tENABLED\tSOURCE\tDESCRIPTION")
+		for _, st := range shed.Features().List() {
+			enabled := fmt.Sprint(st.Enabled)
+			desc := st.Description
+			if st.Unknown {
+				enabled = "unknown"
+				desc = "not known to this version of shed, ignored"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", st.Name, enabled, st.Source, desc)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	featuresCmd.AddCommand(featuresListCmd)
+	rootCmd.AddCommand(featuresCmd)
+}
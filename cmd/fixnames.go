@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"path/filepath"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/spf13/cobra"
+)
+
+var fixNamesCmd = &cobra.Command{
+	Use:   "fix-names <file> [files...]",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Update references to renamed tools.",
+	Long: `shed fix-names updates references to renamed tools in the given files to use the new tool names.
+
+Tools can be renamed by adding the old name to "renames" in shed.config.json. For example:
+
+	{"renames": {"golint": "revive"}}
+
+The old name will keep working with a deprecation warning. Once all references have been updated
+using shed fix-names, the rename can be removed from the config.
+
+References are invocations of 'shed run' with the tool, as found in Makefiles and scripts,
+and the tool of tasks in shed.config.json.
+
+Examples:
+
+	shed fix-names Makefile scripts/*.sh shed.config.json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		cwd := setwd(logger)
+		shed := mustShed(client.WithLogger(logger), client.WithNoCache())
+
+		// Paths are relative to where shed was run from, not the lockfile directory
+		paths := make([]string, len(args))
+		for i, p := range args {
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(cwd, p)
+			}
+			paths[i] = p
+		}
+		modified, err := shed.FixNames(paths...)
+		for _, p := range modified {
+			logger.Infof("Updated %s", p)
+		}
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to update tool names")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fixNamesCmd)
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/spf13/cobra"
+)
+
+var hooksCmd = &cobra.Command{
+	Use:   "hooks",
+	Short: "Manage git hooks that keep tools installed.",
+	Long: `shed hooks contains commands to manage the git hooks that run 'shed sync' after the checked out commit
+changes, so the tools in shed.lock are always installed after switching branches or pulling.
+
+'shed hooks install' installs the hooks, and 'shed hooks uninstall' removes them.`,
+}
+
+var hooksInstallCmd = &cobra.Command{
+	Use:   "install",
+	Args:  cobra.NoArgs,
+	Short: "Install git hooks that run shed sync.",
+	Long: `shed hooks install installs post-checkout and post-merge git hooks that run 'shed sync' in the directory
+of shed.lock. The hooks are installed in the hooks directory git uses, so core.hooksPath is respected.
+They do nothing if shed isn't installed, so they never get in the way of people who don't use it.
+
+Existing hooks are never overwritten, unless they were installed by shed hooks install. If the repository
+already has one of the hooks, add 'shed sync' to it instead.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		setwd(logger)
+		shed := mustShed(client.WithLogger(logger), client.WithNoCache())
+		paths, err := shed.InstallGitHooks(context.Background())
+		if errors.Is(err, client.ErrGitHookExists) {
+			fatal.ExitErrf(err, "The repository already has git hooks. Add 'shed sync' to them instead")
+		} else if err != nil {
+			fatal.ExitErrf(err, "Failed to install git hooks")
+		}
+		for _, p := range paths {
+			fmt.Println(p)
+		}
+	},
+}
+
+var hooksUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Args:  cobra.NoArgs,
+	Short: "Remove the git hooks installed by shed.",
+	Long: `shed hooks uninstall removes the git hooks installed by 'shed hooks install'.
+Hooks that weren't installed by shed are left as is.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		setwd(logger)
+		shed := mustShed(client.WithLogger(logger), client.WithNoCache())
+		paths, err := shed.UninstallGitHooks(context.Background())
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to uninstall git hooks")
+		}
+		if len(paths) == 0 {
+			logger.Info("No git hooks installed by shed found")
+			return
+		}
+		for _, p := range paths {
+			fmt.Println(p)
+		}
+	},
+}
+
+func init() {
+	hooksCmd.AddCommand(hooksInstallCmd)
+	hooksCmd.AddCommand(hooksUninstallCmd)
+	rootCmd.AddCommand(hooksCmd)
+}
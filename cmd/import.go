@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/spf13/cobra"
+)
+
+// miseConfigFiles are the names of mise config files in a project, in the order mise looks for them.
+var miseConfigFiles = []string{"mise.toml", ".mise.toml"}
+
+var importCmd = &cobra.Command{
+	Use:   "import <mise|gomod> [file]",
+	Args:  cobra.RangeArgs(1, 2),
+	Short: "Import tools from the config of another tool manager.",
+	Long: `shed import installs the tools from the config of another tool manager and adds them to shed.lock.
+This makes it easy to move a project to shed. The supported sources are:
+
+	mise   Tools in the [tools] table of the mise config that use the go backend,
+	       ex: "go:golang.org/x/tools/cmd/stringer", are installed. All other tools are ignored.
+	       If no file is provided, mise.toml or .mise.toml in the current directory is used.
+	       To go the other way, use 'shed export --format=mise'.
+
+	gomod  Tools in the tool directives of a go.mod file, which are supported by Go 1.24 and later,
+	       are installed using the version of the module that provides them in go.mod.
+	       If no file is provided, go.mod in the current directory is used.
+	       To go the other way, use 'shed export --format=gomod'.
+
+Examples:
+
+Import the Go tools from mise.toml:
+
+	shed import mise
+
+Import the Go tools from a specific file:
+
+	shed import mise config/mise.toml
+
+Import the tools from go.mod:
+
+	shed import gomod`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		origDir := setwd(logger)
+		var path string
+		if len(args) > 1 {
+			path = resolvePath(origDir, args[1])
+		}
+
+		var specs []client.ToolSpec
+		switch args[0] {
+		case "mise":
+			specs = readMiseConfig(origDir, path)
+		case "gomod":
+			if path == "" {
+				path = resolvePath(origDir, "go.mod")
+			}
+			var err error
+			specs, err = client.ImportGoModTools(path)
+			if err != nil {
+				fatal.ExitErrf(err, "Failed to read tools from %s", path)
+			}
+		default:
+			fatal.Exitf("Unsupported source %q, must be one of: mise, gomod", args[0])
+		}
+
+		shed := mustShed(client.WithLogger(logger))
+		installSet, err := shed.InstallSpecs(specs)
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to determine list of tools to install")
+		}
+		applyInstall(logger, installSet)
+	},
+}
+
+// readMiseConfig reads the Go tools from the mise config at path.
+// If path is empty, the mise config in origDir is used.
+func readMiseConfig(origDir, path string) []client.ToolSpec {
+	if path == "" {
+		for _, name := range miseConfigFiles {
+			p := resolvePath(origDir, name)
+			if _, err := os.Stat(p); err == nil {
+				path = p
+				break
+			}
+		}
+		if path == "" {
+			fatal.Exitf("No mise config found, looked for: mise.toml, .mise.toml")
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		fatal.ExitErrf(err, "Failed to open file %s", path)
+	}
+	specs, err := client.ReadMiseTools(f)
+	f.Close()
+	if err != nil {
+		fatal.ExitErrf(err, "Failed to read mise config %s", path)
+	}
+	if len(specs) == 0 {
+		fatal.Exitf("No Go tools found in %s", path)
+	}
+	return specs
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+}
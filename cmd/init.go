@@ -1,11 +1,16 @@
 package cmd
 
 import (
+	"bufio"
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/getshiphub/shed/client"
 	"github.com/getshiphub/shed/internal/util"
 	"github.com/getshiphub/shed/lockfile"
+	"github.com/mattn/go-isatty"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
@@ -19,27 +24,131 @@ In most cases this isn't necessary as shed will automatically create a lockfile
 In some situations however, it may be desirable to explicitly create the lockfile. One reason for this is to
 setup shed in a subdirectory of a project. shed will automatically check parent directories for lockfiles.
 If you wish to have shed install update a lockfile in a subdirectory instead of a parent directory,
-you can use shed init to create a new lockfile.`,
+you can use shed init to create a new lockfile.
+
+Use --from to install the tools tracked by a tools.go file, which is a file with a blank import for each tool.
+Each tool is installed at the version of its module in go.mod. This makes it easy to move a module that uses
+the tools.go convention to shed. If shed.lock already exists, the tools are added to it.
+
+Use --detect to scan the project for tools it already uses and suggest adding them. Tools are detected from
+the blank imports of tools.go files, 'go install' and 'go run' commands and the names of common tools in
+Makefiles, shell scripts, Taskfiles, justfiles, GitHub Actions workflows, and go:generate directives,
+and the config files of common tools, ex: .golangci.yml. shed init asks whether to add each tool,
+use --yes to add them all without asking. Detected tools are installed at their latest versions.
+
+Examples:
+
+Create shed.lock with the tools from tools.go:
+
+	shed init --from tools.go
+
+Create shed.lock with the tools the project uses:
+
+	shed init --detect`,
 	Run: func(cmd *cobra.Command, args []string) {
 		logger := newLogger()
+		// Read tools.go first so that nothing is created if it is invalid
+		var specs []client.ToolSpec
+		if initOpts.from != "" {
+			var err error
+			specs, err = client.ImportToolsGo(initOpts.from)
+			if err != nil {
+				fatal.ExitErrf(err, "Failed to read tools from %s", initOpts.from)
+			}
+		}
+
 		if util.FileOrDirExists(client.LockfileName) {
 			logger.Infof("%s already exists", client.LockfileName)
+		} else {
+			createLockfile()
+			logger.Infof("Created %s", client.LockfileName)
+		}
+		shed := mustShed(client.WithLogger(logger))
+		if initOpts.detect {
+			specs = append(specs, detectTools(logger, shed, specs)...)
+		}
+		if len(specs) == 0 {
 			return
 		}
 
-		f, err := os.OpenFile(client.LockfileName, os.O_WRONLY|os.O_CREATE, 0o644)
+		installSet, err := shed.InstallSpecs(specs)
 		if err != nil {
-			fatal.ExitErrf(err, "Failed to create file %s", client.LockfileName)
+			fatal.ExitErrf(err, "Failed to determine list of tools to install")
 		}
-		defer f.Close()
-		var lf lockfile.Lockfile
-		if _, err = lf.WriteTo(f); err != nil {
-			fatal.ExitErrf(err, "Failed to write lockfile")
-		}
-		logger.Infof("Created %s", client.LockfileName)
+		applyInstall(logger, installSet)
 	},
 }
 
+// createLockfile creates an empty lockfile in the current directory.
+func createLockfile() {
+	f, err := os.OpenFile(client.LockfileName, os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		fatal.ExitErrf(err, "Failed to create file %s", client.LockfileName)
+	}
+	defer f.Close()
+	var lf lockfile.Lockfile
+	if _, err = lf.WriteTo(f); err != nil {
+		fatal.ExitErrf(err, "Failed to write lockfile")
+	}
+}
+
+// detectTools returns specs for the tools detected in the current directory that the user chose to add.
+// Tools that are already in the lockfile or in specs are skipped.
+func detectTools(logger *logrus.Logger, shed *client.Shed, specs []client.ToolSpec) []client.ToolSpec {
+	detected, err := client.DetectTools(".")
+	if err != nil {
+		fatal.ExitErrf(err, "Failed to detect tools")
+	}
+	known := make(map[string]bool)
+	for _, t := range shed.List() {
+		known[strings.ToLower(t.ImportPath)] = true
+	}
+	for _, spec := range specs {
+		known[strings.ToLower(spec.ImportPath)] = true
+	}
+	var newTools []client.DetectedTool
+	for _, dt := range detected {
+		if !known[strings.ToLower(dt.ImportPath)] {
+			newTools = append(newTools, dt)
+		}
+	}
+	if len(newTools) == 0 {
+		logger.Info("No new tools detected")
+		return nil
+	}
+
+	if !initOpts.yes && !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+		fatal.Exitf("Detected %d tools, use --yes to add them", len(newTools))
+	}
+	in := bufio.NewReader(os.Stdin)
+	var detectedSpecs []client.ToolSpec
+	for _, dt := range newTools {
+		if !initOpts.yes {
+			fmt.Fprintf(os.Stderr, translate("Add %s, found in %s? [Y/n] "), dt.ImportPath, strings.Join(dt.Sources, ", "))
+			// No input is treated as yes, since the tool is used by the project
+			answer, _ := in.ReadString('\n')
+			switch strings.ToLower(strings.TrimSpace(answer)) {
+			case "", "y", "yes":
+			default:
+				continue
+			}
+		}
+		detectedSpecs = append(detectedSpecs, client.ToolSpec{ImportPath: dt.ImportPath})
+	}
+	return detectedSpecs
+}
+
+type initOptions struct {
+	from   string
+	detect bool
+	yes    bool
+}
+
+var initOpts initOptions
+
 func init() {
+	initCmd.Flags().StringVar(&initOpts.from, "from", "", "tools.go file to install the tools from")
+	initCmd.Flags().BoolVar(&initOpts.detect, "detect", false, "scan the project for tools it uses and suggest adding them")
+	initCmd.Flags().BoolVarP(&initOpts.yes, "yes", "y", false, "add the detected tools without asking")
 	rootCmd.AddCommand(initCmd)
 }
@@ -2,13 +2,27 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
+	"github.com/getshiphub/shed/api"
+	"github.com/getshiphub/shed/cache"
 	"github.com/getshiphub/shed/client"
 	"github.com/getshiphub/shed/internal/spinner"
+	"github.com/getshiphub/shed/lockfile"
 	"github.com/getshiphub/shed/tool"
+	"github.com/mattn/go-isatty"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
@@ -25,8 +39,96 @@ Each tool provided must be the full import path to the package containing the ma
 The format is identical to what would be passed to 'go get'. Tools may specify a version by prefixing it with
 an '@', just like with 'go get' in module-aware mode. If no version is provided, the latest version will be installed.
 
+The version can also be a caret or tilde range, like '^1.50' or '~1.33.2', which are resolved to the highest
+matching version using GOPROXY. '^' allows any version with the same major version, or the same minor version
+for v0, and '~' allows any version with the same minor version. The resolved version is saved in shed.lock.
+
+The version can also be a branch, like '@master'. The branch is resolved to the pseudo-version of its latest
+commit, which is installed and saved in shed.lock along with the branch, so everyone gets the same commit.
+'shed update' resolves the branch again to move to its latest commit. Installing the tool with a version
+instead of a branch stops tracking the branch.
+
+Tools that are already in the shed.lock file can also be referred to by name (i.e. the name of the binary).
+In this case the version in shed.lock is installed. If any tools are provided by name, only the given tools
+are installed instead of all tools in the lockfile. This includes import paths provided along with the names.
+
 If no tools are provided, then shed will simply install all tools in the lockfile.
 
+Build flags can be set with --tags, --ldflags, --trimpath, and --build-env. They apply to the tools provided by import path
+and are saved in shed.lock, so the tools are always built the same way. If no build flags are provided, tools that
+are already in shed.lock keep their build flags.
+
+Use --build-env for tools that need environment variables to build, ex: --build-env CGO_ENABLED=1 --build-env CC=clang
+for tools that need cgo. Before building such tools, shed checks that the compilers they need are installed, and fails
+with an error that says which tool needs what if they aren't. Variables that shed sets itself, like GOOS and GOFLAGS,
+can't be set this way.
+
+Use --as to give a tool a different name, ex: when two tools have the same binary name. The name is saved
+in shed.lock and is used to refer to the tool in all shed commands. It can only be used with a single tool.
+The name must not be the name of another tool.
+
+Tools that aren't written in Go, or are distributed as prebuilt binaries, can be downloaded from a release
+instead of being built. Use --asset with the name of an asset of the GitHub release for the version, in which
+case the import path must be the repository, or --url with the URL of the asset. The version must be exact.
+The asset can be the binary itself, or a .tar.gz, .tgz, or .zip archive containing a file named after the
+last element of the import path. Use --binary to give the path of the binary in the archive instead.
+The values are templates that can use {{.Version}}, {{.Number}} (the version without the 'v'), {{.OS}},
+and {{.Arch}}, where the OS and architecture are the same as GOOS and GOARCH. They are saved in shed.lock,
+along with the hash of the asset of each platform the tool is installed on. If the asset changes, shed fails
+to install the tool.
+
+Use --platform to install the tools for another platform, given as GOOS/GOARCH, ex: to build linux/amd64
+binaries on a Mac for a container image. The binaries are stored separately from the ones for the current
+platform, and their paths can be found with 'shed list --format=json --platform'. shed.lock is updated the
+same way as without --platform.
+
+Use --group to install the tools in one or more groups, ex: only the tools needed in CI. Groups are saved in
+shed.lock for each tool. When tools are provided, they are added to the groups in addition to any groups they
+already belong to, and are installed. When no tools are provided, only the tools in the groups are installed.
+
+Use --why to save a note on why the project uses the tools or who owns them, ex: --why "lints the protobuf files".
+The note is saved in shed.lock and shown by 'shed why'. It replaces the previous note of the tools.
+
+Use --reproducible with an exact version of Go, ex: --reproducible go1.22.4, to build the tools reproducibly,
+so that every machine builds byte-identical binaries. The tools are built with that version of Go, which the go
+command downloads if needed (this needs go1.21.0 or newer), with -trimpath, without VCS information or cgo, and
+without GOFLAGS. The version of Go and the hash of the binary of each platform are saved in shed.lock, and
+installing the tool fails if it builds a different binary. Use 'shed verify --rebuild' to check it.
+
+Use --frozen to install the tools in shed.lock exactly as they are, ex: in CI or a container.
+shed.lock is not modified, and shed fails if any tool would be added to or changed in shed.lock.
+
+If any tool fails to install, shed.lock is not changed at all. The tools that were installed are kept in the cache
+so they don't need to be built again. Use --best-effort to add the tools that were installed to shed.lock anyway.
+
+Use --dry-run to see what would be installed without changing the cache or shed.lock. The tools that would
+be added, upgraded, downgraded, changed, or removed are printed with their resolved versions, along with
+the tools that would be downloaded and built.
+
+Use --stats to print whether each tool was reused from the cache, pulled from the remote cache, built, or
+downloaded from a release or as a prebuilt binary, and how long it took. The results are recorded in the install
+history of the project, so 'shed stats cache' can show how often tools come from the cache, ex: to check that
+caching works in CI.
+
+Use --timings to print how long resolving, downloading, and building each tool took, slowest first, followed by
+the total time and how many tools came from the cache, ex: to find out what slows down bootstrapping CI.
+
+Use --enforce-toolchain to refuse to build tools whose modules have a go directive newer than the installed version
+of Go. The tools are listed along with the version of Go they need, instead of failing to build or having the go
+command download a newer toolchain. It is always enabled if shed.config.json sets enforceToolchain.
+See 'shed toolchain required' for the version of Go needed by all tools in shed.lock.
+
+Once the tools are installed, the tools that were added, removed, or changed to another version are printed
+along with links to their changes on GitHub. Use --summary-file to also write them to a file as JSON, ex: for
+a bot to post them on a pull request. Links are only derived for modules hosted on GitHub and aren't checked.
+
+Use --global (-g) to install the tools in the global lockfile instead of shed.lock, like 'go install' but
+reproducible. The global lockfile is shed.lock in the shed config directory, see 'shed env'. Tools in it can be
+run with 'shed run' from any directory, unless a project has a tool with the same name.
+
+If '-' is provided, the list of tools is read from stdin with one tool per line.
+Blank lines and comments starting with '#' are ignored.
+
 Examples:
 
 Install the latest version of a tool:
@@ -37,59 +139,501 @@ Install a specific version of a tool:
 
 	shed install github.com/golangci/golangci-lint/cmd/golangci-lint@v1.33.0
 
+Install only some of the tools specified in shed.lock:
+
+	shed install golangci-lint stringer
+
+Install the latest v1 version of a tool that is at least v1.50.0:
+
+	shed install github.com/golangci/golangci-lint/cmd/golangci-lint@^1.50
+
+Install a tool with a version string embedded by the linker:
+
+	shed install --ldflags='-X main.version=v1.0.0' example.org/tool/cmd/tool@v1.0.0
+
+Install a tool that has the same name as another tool:
+
+	shed install --as stringer2 example.org/z/random/stringer/v2/cmd/stringer
+
+Download a tool from a GitHub release:
+
+	shed install --asset 'shellcheck-{{.Version}}.{{.OS}}.x86_64.tar.gz' github.com/koalaman/shellcheck@v0.9.0
+
+Download a tool from a URL:
+
+	shed install --url 'https://releases.hashicorp.com/terraform/{{.Number}}/terraform_{{.Number}}_{{.OS}}_{{.Arch}}.zip' releases.hashicorp.com/terraform@v1.5.0
+
+Build the tools in shed.lock for linux/amd64:
+
+	shed install --platform linux/amd64
+
+Add a tool to the ci group, then install only the tools in that group:
+
+	shed install --group ci golangci-lint
+	shed install --group ci
+
+Install a list of tools generated by a script:
+
+	cat tools.txt | shed install -
+
+Preview upgrading a tool to the latest version:
+
+	shed install --dry-run github.com/golangci/golangci-lint/cmd/golangci-lint
+
 Install all tools specified in shed.lock:
 
 	shed install`,
 	Run: func(cmd *cobra.Command, args []string) {
 		logger := newLogger()
-		setwd(logger)
-		shed := mustShed(client.WithLogger(logger))
-		installSet, err := shed.Install(args...)
+		lfOpts := setwdGlobal(logger, installOpts.global)
+		shed := mustShed(append(lfOpts, client.WithLogger(logger))...)
+		installSet, err := installTools(shed, readStdinTools(args))
 		if err != nil {
 			fatal.ExitErrf(err, "Failed to determine list of tools to install")
 		}
-
-		s := spinner.NewTTY(spinner.Options{
-			Message:         "Installing tools",
-			Count:           installSet.Len(),
-			PersistMessages: rootOpts.verbose,
-		})
-		logger.Out = s
-		ch := make(chan tool.Tool, installSet.Len())
-		installSet.Notify(ch)
-		go func() {
-			for range ch {
-				s.Inc()
+		if installOpts.dryRun {
+			plan, err := installSet.Plan(context.Background())
+			printInstallPlan(os.Stdout, plan)
+			if err != nil {
+				fatal.ExitErrf(err, "Failed to plan install")
 			}
-		}()
-
-		// Listen of SIGINT to do a graceful abort
-		ctx, cancel := context.WithCancel(context.Background())
-		defer cancel()
-		abort := make(chan os.Signal, 1)
-		signal.Notify(abort, os.Interrupt)
-		go func() {
-			<-abort
-			cancel()
-		}()
-
-		s.Start()
-		err = installSet.Apply(ctx)
-		s.Stop()
-		close(ch)
-		logger.Out = os.Stderr
-
-		if errors.Is(err, context.Canceled) {
-			logger.Info("Install aborted")
 			return
 		}
-		if err != nil {
-			fatal.ExitErrf(err, "Failed to install tools")
+		var opts []client.ApplyOption
+		if installOpts.frozen {
+			opts = append(opts, client.Frozen())
+		}
+		if installOpts.bestEffort {
+			opts = append(opts, client.BestEffort())
+		}
+		if installOpts.platform != "" {
+			if _, _, err := tool.ParsePlatform(installOpts.platform); err != nil {
+				fatal.ExitErrf(err, "Invalid --platform")
+			}
+			opts = append(opts, client.TargetPlatform(installOpts.platform))
+		}
+		if installOpts.stats {
+			opts = append(opts, client.RecordStats())
+		}
+		if installOpts.enforceToolchain {
+			opts = append(opts, client.EnforceToolchain())
+		}
+		done := applyInstall(logger, installSet, opts...)
+		if installOpts.stats {
+			printInstallStats(os.Stderr, done)
+		}
+		if installOpts.timings {
+			printInstallTimings(os.Stderr, installSet.Timings())
+		}
+		printInstallSummary(os.Stderr, installSet.Summary())
+		if installOpts.summaryFile != "" {
+			writeInstallSummary(installOpts.summaryFile, installSet.Summary())
 		}
-		logger.Info("Finished installing tools")
 	},
 }
 
+// applyInstall installs the tools in installSet while showing the progress.
+// It returns the EventDone events of the tools that were installed.
+func applyInstall(logger *logrus.Logger, installSet *client.InstallSet, opts ...client.ApplyOption) []client.Event {
+	s := spinner.NewTTY(spinner.Options{
+		Message:         "Installing tools",
+		Count:           installSet.Len(),
+		PersistMessages: rootOpts.verbose,
+		Plain:           plainOutput,
+	})
+	logger.Out = s
+	ch := make(chan tool.Tool, installSet.Len())
+	installSet.Notify(ch)
+	go func() {
+		for range ch {
+			s.Inc()
+		}
+	}()
+
+	// Listen of SIGINT to do a graceful abort
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	abort := make(chan os.Signal, 1)
+	signal.Notify(abort, os.Interrupt)
+	go func() {
+		<-abort
+		cancel()
+	}()
+
+	s.Start()
+	eta := newETAMessage(s, "Installing tools")
+	var done []client.Event
+	opts = append(opts, client.WithProgress(func(ev client.Event) {
+		switch {
+		case ev.Kind == client.EventDone && ev.Source != 0:
+			logger.Debugf("%s %s from %s in %s", ev.Kind, ev.Tool, ev.Source, ev.Duration.Round(time.Millisecond))
+			done = append(done, ev)
+		case ev.Kind != client.EventDone && ev.Kind != client.EventFailed:
+			logger.Debugf("%s %s", ev.Kind, ev.Tool)
+		}
+		eta.update(ev.ETA)
+	}))
+	err := installSet.Apply(ctx, opts...)
+	eta.stop()
+	s.Stop()
+	close(ch)
+	logger.Out = os.Stderr
+
+	if errors.Is(err, context.Canceled) {
+		logger.Info("Install aborted")
+		// Tools being installed may still report progress
+		return nil
+	}
+	if tcErrs := toolchainErrors(err); len(tcErrs) > 0 {
+		required := tcErrs[0].GoVersion
+		var names []string
+		for _, e := range tcErrs {
+			names = append(names, fmt.Sprintf("%s (go %s)", e.Tool, e.GoVersion))
+			if cache.CompareGoVersions(e.GoVersion, required) > 0 {
+				required = e.GoVersion
+			}
+		}
+		fatal.Exitf("%s is too old to build %s. Install go %s or newer", tcErrs[0].LocalVersion, strings.Join(names, ", "), required)
+	}
+	if err != nil {
+		fatal.ExitErrf(err, "Failed to install tools")
+	}
+	logger.Info("Finished installing tools")
+	return done
+}
+
+// toolchainErrors returns the errors in err for tools that need a newer version of Go, see client.EnforceToolchain.
+func toolchainErrors(err error) []*cache.ToolchainError {
+	var errs lockfile.ErrorList
+	if !errors.As(err, &errs) {
+		return nil
+	}
+	var tcErrs []*cache.ToolchainError
+	for _, e := range errs {
+		var tcErr *cache.ToolchainError
+		if errors.As(e, &tcErr) {
+			tcErrs = append(tcErrs, tcErr)
+		}
+	}
+	return tcErrs
+}
+
+// printInstallStats prints where each tool in done came from and how long it took to w,
+// followed by how many tools came from the cache.
+func printInstallStats(w io.Writer, done []client.Event) {
+	if len(done) == 0 {
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	hits := 0
+	for _, ev := range done {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", ev.Source, ev.Duration.Round(time.Millisecond), ev.Tool)
+		if ev.Source == cache.SourceCache || ev.Source == cache.SourceRemote {
+			hits++
+		}
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "%d of %d tools came from the cache or remote cache\n", hits, len(done))
+}
+
+// printInstallTimings prints how long each stage of installing each tool took to w, slowest tool first,
+// followed by how long the install took and how many tools came from the cache.
+func printInstallTimings(w io.Writer, timings *client.InstallTimings) {
+	if timings == nil || len(timings.Tools) == 0 {
+		return
+	}
+	tools := make([]client.ToolTiming, len(timings.Tools))
+	copy(tools, timings.Tools)
+	sort.SliceStable(tools, func(i, j int) bool {
+		return tools[i].Total > tools[j].Total
+	})
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOOL\tSOURCE\tRESOLVE\tDOWNLOAD\tBUILD\tTOTAL")
+	for _, tt := range tools {
+		source := tt.Source.String()
+		if tt.Failed {
+			source = "failed"
+		}
+		fmt.Fprintf(
+			tw,
+			"%s\t%s\t%s\t%s\t%s\t%s\n",
+			tt.Tool,
+			source,
+			tt.Resolve.Round(time.Millisecond),
+			tt.Download.Round(time.Millisecond),
+			tt.Build.Round(time.Millisecond),
+			tt.Total.Round(time.Millisecond),
+		)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "Installed %d tools in %s, %d of them from the cache or remote cache\n", len(tools), timings.Total.Round(time.Millisecond), timings.CacheHits())
+}
+
+// printInstallSummary prints the tools whose versions changed to w, with a link to the changes
+// of each one if it can be derived from its module.
+func printInstallSummary(w io.Writer, summary *client.InstallSummary) {
+	if summary == nil || len(summary.Changes) == 0 {
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, c := range summary.Changes {
+		var version, link string
+		switch c.Kind {
+		case lockfile.ChangeAdded:
+			version, link = c.NewVersion, c.ReleaseURL
+		case lockfile.ChangeRemoved:
+			version = c.OldVersion
+		default:
+			version, link = c.OldVersion+" -> "+c.NewVersion, c.CompareURL
+			if link == "" {
+				link = c.ReleaseURL
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.Kind, c.ImportPath, version, link)
+	}
+	tw.Flush()
+}
+
+// writeInstallSummary writes summary to the file p as an api.InstallSummary.
+// A nil summary is written as no changes, ex: when all tools were up to date.
+func writeInstallSummary(p string, summary *client.InstallSummary) {
+	doc := api.InstallSummary{SchemaVersion: api.SchemaVersion, Changes: []api.VersionChange{}}
+	if summary != nil {
+		for _, c := range summary.Changes {
+			doc.Changes = append(doc.Changes, api.VersionChange{
+				Change:     c.Kind.String(),
+				ImportPath: c.ImportPath,
+				OldVersion: c.OldVersion,
+				NewVersion: c.NewVersion,
+				ReleaseURL: c.ReleaseURL,
+				CompareURL: c.CompareURL,
+			})
+		}
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		fatal.ExitErrf(err, "Failed to serialize install summary as JSON")
+	}
+	if err := ioutil.WriteFile(p, append(data, '\n'), 0o644); err != nil {
+		fatal.ExitErrf(err, "Failed to write install summary to %s", p)
+	}
+}
+
+// etaMessage counts down the estimated time remaining in the message of a spinner.
+// The message is only changed when stderr is a terminal and verbose logging and plain output are off,
+// otherwise every update would be written as a new line.
+type etaMessage struct {
+	s        *spinner.TTYSpinner
+	msg      string
+	mu       sync.Mutex
+	deadline time.Time
+	done     chan struct{}
+}
+
+func newETAMessage(s *spinner.TTYSpinner, msg string) *etaMessage {
+	e := &etaMessage{s: s, msg: msg, done: make(chan struct{})}
+	if rootOpts.verbose || plainOutput || !isatty.IsTerminal(os.Stderr.Fd()) {
+		return e
+	}
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				e.render()
+			case <-e.done:
+				return
+			}
+		}
+	}()
+	return e
+}
+
+// update sets the estimated time remaining, 0 means there is no estimate.
+func (e *etaMessage) update(eta time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.deadline = time.Time{}
+	if eta > 0 {
+		e.deadline = time.Now().Add(eta)
+	}
+}
+
+func (e *etaMessage) render() {
+	e.mu.Lock()
+	deadline := e.deadline
+	e.mu.Unlock()
+	remaining := time.Until(deadline).Round(time.Second)
+	if deadline.IsZero() || remaining <= 0 {
+		e.s.UpdateMessage(e.msg)
+		return
+	}
+	e.s.UpdateMessage(fmt.Sprintf("%s, about %s left", e.msg, remaining))
+}
+
+func (e *etaMessage) stop() {
+	close(e.done)
+}
+
+// printInstallPlan prints the changes to shed.lock in plan and the tools that need to be built.
+func printInstallPlan(out io.Writer, plan *client.InstallPlan) {
+	if plan == nil {
+		return
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	var build []string
+	for _, pt := range plan.Tools {
+		if !pt.Cached && pt.Action != client.PlanRemove {
+			build = append(build, pt.Tool.String())
+		}
+		switch pt.Action {
+		case client.PlanKeep:
+			continue
+		case client.PlanUpgrade, client.PlanDowngrade:
+			fmt.Fprintf(w, "%s\t%s\t%s -> %s\n", pt.Action, pt.Tool.ImportPath, pt.Current.Version, pt.Tool.Version)
+		default:
+			fmt.Fprintf(w, "%s\t%s\t%s\n", pt.Action, pt.Tool.ImportPath, pt.Tool.Version)
+		}
+	}
+	w.Flush()
+	if !plan.Changed() {
+		fmt.Fprintln(out, "shed.lock would not be changed.")
+	}
+	if len(build) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "\nTools that would be downloaded and built:\n")
+	for _, t := range build {
+		fmt.Fprintf(out, "  %s\n", t)
+	}
+}
+
+// installTools creates the install set for the given tools, using the build flags, alias, release, groups, and note from the command line.
+func installTools(shed *client.Shed, toolNames []string) (*client.InstallSet, error) {
+	buildFlags := tool.BuildFlags{
+		Tags:     installOpts.tags,
+		Ldflags:  installOpts.ldflags,
+		Trimpath: installOpts.trimpath,
+	}
+	env, err := tool.JoinBuildEnv(installOpts.buildEnv)
+	if err != nil {
+		return nil, err
+	}
+	buildFlags.Env = env
+	var release *tool.Release
+	if installOpts.url != "" || installOpts.asset != "" || installOpts.binary != "" {
+		release = &tool.Release{URL: installOpts.url, Asset: installOpts.asset, Binary: installOpts.binary}
+	}
+	if buildFlags.IsZero() && installOpts.alias == "" && release == nil && installOpts.why == "" && installOpts.reproducible == "" {
+		if len(installOpts.groups) == 0 {
+			return shed.Install(toolNames...)
+		}
+		if len(toolNames) == 0 {
+			return shed.InstallGroups(installOpts.groups...)
+		}
+	}
+	if len(toolNames) == 0 {
+		fatal.Exitf("Build flags, --as, --url, --asset, --binary, --why, and --reproducible can only be used when installing tools")
+	}
+	if installOpts.alias != "" && len(toolNames) != 1 {
+		fatal.Exitf("--as can only be used when installing a single tool")
+	}
+	if release != nil && len(toolNames) != 1 {
+		fatal.Exitf("--url, --asset, and --binary can only be used when installing a single tool")
+	}
+	specs := make([]client.ToolSpec, len(toolNames))
+	for i, toolName := range toolNames {
+		spec, err := client.ParseToolSpec(toolName)
+		if err != nil {
+			return nil, err
+		}
+		spec.BuildFlags = buildFlags
+		spec.Alias = installOpts.alias
+		spec.Release = release
+		spec.Groups = installOpts.groups
+		spec.Why = installOpts.why
+		spec.Reproducible = installOpts.reproducible
+		specs[i] = spec
+	}
+	return shed.InstallSpecs(specs)
+}
+
+// readStdinTools replaces '-' in args with the list of tools read from stdin.
+func readStdinTools(args []string) []string {
+	var tools []string
+	readStdin := false
+	for _, arg := range args {
+		if arg != "-" {
+			tools = append(tools, arg)
+			continue
+		}
+		if readStdin {
+			fatal.Exitf("'-' can only be provided once")
+		}
+		readStdin = true
+		stdinTools, err := client.ReadToolList(os.Stdin)
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to read tools from stdin")
+		}
+		tools = append(tools, stdinTools...)
+	}
+	// Otherwise all tools in the lockfile would be installed, which is unlikely to be what was intended
+	if readStdin && len(tools) == 0 {
+		fatal.Exitf("No tools were provided on stdin")
+	}
+	return tools
+}
+
+type installOptions struct {
+	tags       string
+	ldflags    string
+	trimpath   bool
+	buildEnv   []string
+	alias      string
+	url        string
+	asset      string
+	binary     string
+	frozen     bool
+	bestEffort bool
+	dryRun     bool
+	platform   string
+	groups     []string
+	why        string
+	stats      bool
+	timings    bool
+	global     bool
+	// summaryFile is the file to write the versions that changed to as JSON
+	summaryFile string
+	// enforceToolchain is also enabled by enforceToolchain in shed.config.json
+	enforceToolchain bool
+	// reproducible is the version of Go to build the tools reproducibly with
+	reproducible string
+}
+
+var installOpts installOptions
+
 func init() {
+	installCmd.Flags().StringVar(&installOpts.tags, "tags", "", "comma-separated list of build tags to build the tools with")
+	installCmd.Flags().StringVar(&installOpts.ldflags, "ldflags", "", "flags to pass to the linker when building the tools")
+	installCmd.Flags().BoolVar(&installOpts.trimpath, "trimpath", false, "remove file system paths from the built tools")
+	installCmd.Flags().StringArrayVar(&installOpts.buildEnv, "build-env", nil, "environment variable to build the tools with, in the form KEY=VALUE, can be repeated")
+	installCmd.Flags().StringVar(&installOpts.alias, "as", "", "name to give the tool instead of the name of its binary")
+	installCmd.Flags().StringVar(&installOpts.url, "url", "", "template of the URL to download the tool from instead of building it")
+	installCmd.Flags().StringVar(&installOpts.asset, "asset", "", "template of the name of the GitHub release asset to download the tool from instead of building it")
+	installCmd.Flags().StringVar(&installOpts.binary, "binary", "", "template of the path of the binary in the downloaded archive")
+	installCmd.Flags().BoolVar(&installOpts.frozen, "frozen", false, "fail instead of changing shed.lock")
+	installCmd.Flags().BoolVar(&installOpts.bestEffort, "best-effort", false, "add the tools that were installed to shed.lock even if others failed")
+	installCmd.Flags().BoolVar(&installOpts.dryRun, "dry-run", false, "print what would be installed without changing anything")
+	installCmd.Flags().StringVar(&installOpts.platform, "platform", "", "GOOS/GOARCH to install the tools for instead of the current platform")
+	installCmd.Flags().StringSliceVar(&installOpts.groups, "group", nil, "groups to add the tools to, or to install the tools of if no tools are provided")
+	installCmd.Flags().StringVar(&installOpts.why, "why", "", "note on why the project uses the tools or who owns them, shown by 'shed why'")
+	installCmd.Flags().StringVar(&installOpts.reproducible, "reproducible", "", "build the tools reproducibly with the given version of Go, ex: go1.22.4")
+	installCmd.Flags().BoolVarP(&installOpts.global, "global", "g", false, "install the tools in the global lockfile instead of shed.lock")
+	installCmd.Flags().BoolVar(&installOpts.stats, "stats", false, "print where each tool came from and how long it took, and record them in the install history")
+	installCmd.Flags().BoolVar(&installOpts.timings, "timings", false, "print how long each stage of installing each tool took")
+	installCmd.Flags().StringVar(&installOpts.summaryFile, "summary-file", "", "file to write the tools whose versions changed to as JSON, with links to their release notes")
+	installCmd.Flags().BoolVar(&installOpts.enforceToolchain, "enforce-toolchain", false, "refuse to build tools that require a newer version of Go")
+	installCmd.ValidArgsFunction = completeLockfileToolNames
 	rootCmd.AddCommand(installCmd)
 }
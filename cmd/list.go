@@ -1,9 +1,18 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/getshiphub/shed/api"
+	"github.com/getshiphub/shed/cache"
 	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/render"
+	"github.com/getshiphub/shed/tool"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
@@ -11,18 +20,254 @@ var listCmd = &cobra.Command{
 	Use:   "list",
 	Args:  cobra.NoArgs,
 	Short: "List Go tools specified in shed.lock.",
-	Long:  `shed list prints a list of tools specified in shed.lock. Each tool will consist of the import path and the version.`,
+	Long: `shed list prints a list of tools specified in shed.lock. Each tool will consist of the import path and the version.
+
+Use --format=json to print the tools as JSON for scripts and Makefiles. The output is an object with
+the version of its schema in schemaVersion, and the tools in tools. Each tool is an object with the fields:
+
+	name       name of the tool, used with commands like 'shed run'
+	importPath import path of the tool
+	version    version of the tool
+	module     module that provides the tool, if known
+	sum        hash of the module in the format used by go.sum, if known
+	path       path to the binary of the tool, whether or not it is installed
+	installed  whether the binary of the tool exists
+	stale      whether the installed files don't match their hashes, see 'shed verify'
+	groups     groups the tool belongs to, if any
+	provenance how the installed binary was built, if it was recorded: goVersion, goos, goarch,
+	           revision (VCS revision of the module, if known), buildFlags, and time
+	reportedVersion the version the tool printed when it was installed, if it has a versionProbe
+	           in shed.config.json
+
+--format can also be a Go template, which is executed for each tool to print it in any format, ex: Makefile
+variables, tables for docs, or Dockerfile COPY lines. The fields are Name, ImportPath, Version, Module, Sum,
+BinaryPath, Installed, Stale, Groups, Why, Branch, and ReportedVersion. Tools that print nothing are skipped.
+Templates can use the functions join, upper, lower, replace, base, quote, and json, see the documentation of
+the github.com/getshiphub/shed/render package. For example:
+
+	shed list --format '{{.ImportPath}} {{.Version}} {{.BinaryPath}}'
+	shed list --format '{{.Name | replace "-" "_" | upper}} := {{.BinaryPath}}'
+	shed list --format 'COPY {{.BinaryPath}} /usr/local/bin/{{.Name}}'
+
+Use --verbose (-v) to also print how the binary of each installed tool was built: the version of Go,
+the platform, the VCS revision of the module if known, the build flags, and when it was built.
+The version each tool reported when it was installed is also printed for tools with a versionProbe.
+This helps find out why a tool behaves differently on different machines.
+
+Use --group to only list the tools in one or more groups.
+
+Use --global (-g) to list the tools in the global lockfile instead, see 'shed install --global'.
+
+Use --platform with --format=json or a template to get the state of the tools installed for another platform
+with 'shed install --platform'.
+
+Use --redact to hide private import paths according to the redaction policy in shed.config.json.
+This is useful when sharing the list of tools publicly. With --format=json, path is omitted since it
+contains the import path, and with a template BinaryPath is empty.
+
+The JSON output follows the compatibility rules of the schema version, see the documentation of
+the github.com/getshiphub/shed/api package.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		logger := newLogger()
-		setwd(logger)
-		shed := mustShed(client.WithLogger(logger))
-		tools := shed.List()
-		for _, t := range tools {
-			fmt.Println(t)
+		lfOpts := setwdGlobal(logger, listOpts.global)
+		switch listOpts.format {
+		case "text":
+			if rootOpts.verbose {
+				listVerbose(logger, lfOpts)
+				return
+			}
+			shed := mustShed(append(lfOpts, client.WithLogger(logger), client.WithNoCache())...)
+			tools := shed.List(client.InGroups(listOpts.groups...))
+			for _, t := range tools {
+				if listOpts.redact {
+					t = shed.Redact(t)
+				}
+				fmt.Println(t)
+			}
+		case "json":
+			shed := mustShed(append(lfOpts, client.WithLogger(logger))...)
+			var opts []client.PathOption
+			if listOpts.platform != "" {
+				opts = append(opts, client.ForPlatform(listOpts.platform))
+			}
+			infos, err := shed.ListInfo(opts...)
+			if err != nil {
+				fatal.ExitErrf(err, "Failed to get the state of tools")
+			}
+			// Use an empty array instead of null when there are no tools to make it easier for scripts
+			list := api.ToolList{SchemaVersion: api.SchemaVersion, Tools: make([]api.Tool, 0, len(infos))}
+			for _, info := range infos {
+				if len(listOpts.groups) > 0 && !inAnyGroup(info.Tool, listOpts.groups) {
+					continue
+				}
+				t := info.Tool
+				path := info.Path
+				if listOpts.redact {
+					t = shed.Redact(t)
+					path = ""
+				}
+				var reported string
+				if info.ReportedVersion != nil {
+					reported = info.ReportedVersion.Output
+				}
+				list.Tools = append(list.Tools, api.Tool{
+					Name:            t.Name(),
+					ImportPath:      t.ImportPath,
+					Version:         t.Version,
+					Module:          t.ModulePath,
+					Sum:             info.Sum,
+					Path:            path,
+					Installed:       info.Installed,
+					Stale:           info.Stale,
+					Groups:          t.GroupList(),
+					Why:             t.Why,
+					Branch:          t.Branch,
+					Provenance:      apiProvenance(info.Provenance),
+					ReportedVersion: reported,
+				})
+			}
+			data, err := json.MarshalIndent(list, "", "  ")
+			if err != nil {
+				fatal.ExitErrf(err, "Failed to serialize tools as JSON")
+			}
+			fmt.Println(string(data))
+		default:
+			if !strings.Contains(listOpts.format, "{{") {
+				fatal.Exitf("Invalid format %q, must be one of: text, json, or a template", listOpts.format)
+			}
+			listTemplate(logger, lfOpts)
 		}
 	},
 }
 
+// listTemplate prints each tool in the lockfile with the template in --format.
+func listTemplate(logger *logrus.Logger, lfOpts []client.Option) {
+	tmpl, err := render.Parse(listOpts.format)
+	if err != nil {
+		fatal.ExitErrf(err, "Invalid --format")
+	}
+	shed := mustShed(append(lfOpts, client.WithLogger(logger))...)
+	var opts []client.PathOption
+	if listOpts.platform != "" {
+		opts = append(opts, client.ForPlatform(listOpts.platform))
+	}
+	infos, err := shed.ListInfo(opts...)
+	if err != nil {
+		fatal.ExitErrf(err, "Failed to get the state of tools")
+	}
+	var tools []render.Tool
+	for _, info := range infos {
+		if len(listOpts.groups) > 0 && !inAnyGroup(info.Tool, listOpts.groups) {
+			continue
+		}
+		t := info.Tool
+		path := info.Path
+		if listOpts.redact {
+			t = shed.Redact(t)
+			path = ""
+		}
+		var reported string
+		if info.ReportedVersion != nil {
+			reported = info.ReportedVersion.Output
+		}
+		tools = append(tools, render.Tool{
+			Name:            t.Name(),
+			ImportPath:      t.ImportPath,
+			Version:         t.Version,
+			Module:          t.ModulePath,
+			Sum:             info.Sum,
+			BinaryPath:      path,
+			Installed:       info.Installed,
+			Stale:           info.Stale,
+			Groups:          t.GroupList(),
+			Why:             t.Why,
+			Branch:          t.Branch,
+			ReportedVersion: reported,
+		})
+	}
+	if err := tmpl.Execute(os.Stdout, tools); err != nil {
+		fatal.ExitErrf(err, "Failed to print tools")
+	}
+}
+
+// listVerbose prints each tool in the lockfile followed by how its binary was built, if it is installed.
+func listVerbose(logger *logrus.Logger, lfOpts []client.Option) {
+	shed := mustShed(append(lfOpts, client.WithLogger(logger))...)
+	infos, err := shed.ListInfo()
+	if err != nil {
+		fatal.ExitErrf(err, "Failed to get the state of tools")
+	}
+	for _, info := range infos {
+		if len(listOpts.groups) > 0 && !inAnyGroup(info.Tool, listOpts.groups) {
+			continue
+		}
+		t := info.Tool
+		if listOpts.redact {
+			t = shed.Redact(t)
+		}
+		fmt.Println(t)
+		if !info.Installed {
+			fmt.Println("  not installed")
+			continue
+		}
+		if rv := info.ReportedVersion; rv != nil {
+			fmt.Printf("  reports version %q\n", rv.Output)
+		}
+		p := info.Provenance
+		if p == nil {
+			fmt.Println("  no build provenance recorded")
+			continue
+		}
+		fmt.Printf("  built with %s for %s/%s at %s\n", p.GoVersion, p.GOOS, p.GOARCH, p.Time.Format(time.RFC3339))
+		if p.Revision != "" {
+			fmt.Printf("  revision %s\n", p.Revision)
+		}
+		if len(p.BuildFlags) > 0 {
+			fmt.Printf("  build flags %s\n", strings.Join(p.BuildFlags, " "))
+		}
+	}
+}
+
+// apiProvenance converts p to the type printed by 'shed list --format=json'.
+func apiProvenance(p *cache.Provenance) *api.Provenance {
+	if p == nil {
+		return nil
+	}
+	return &api.Provenance{
+		GoVersion:  p.GoVersion,
+		GOOS:       p.GOOS,
+		GOARCH:     p.GOARCH,
+		Revision:   p.Revision,
+		BuildFlags: p.BuildFlags,
+		Time:       p.Time,
+	}
+}
+
+// inAnyGroup reports whether t belongs to at least one of groups.
+func inAnyGroup(t tool.Tool, groups []string) bool {
+	for _, g := range groups {
+		if t.InGroup(g) {
+			return true
+		}
+	}
+	return false
+}
+
+type listOptions struct {
+	redact   bool
+	format   string
+	platform string
+	groups   []string
+	global   bool
+}
+
+var listOpts listOptions
+
 func init() {
+	listCmd.Flags().BoolVar(&listOpts.redact, "redact", false, "hide private import paths using the redaction policy")
+	listCmd.Flags().StringVar(&listOpts.format, "format", "text", "format to print the tools in: text, json, or a Go template")
+	listCmd.Flags().StringVar(&listOpts.platform, "platform", "", "GOOS/GOARCH of the tools to show the state of with --format=json or a template")
+	listCmd.Flags().StringSliceVar(&listOpts.groups, "group", nil, "only list the tools in these groups")
+	listCmd.Flags().BoolVarP(&listOpts.global, "global", "g", false, "list the tools in the global lockfile instead of shed.lock")
 	rootCmd.AddCommand(listCmd)
 }
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/spf13/cobra"
+)
+
+var lockfileCmd = &cobra.Command{
+	Use:   "lockfile",
+	Short: "Work with shed.lock files.",
+	Long: `shed lockfile provides commands for working with shed.lock files directly.
+
+'shed lockfile merge' can be used to merge changes to shed.lock, ex: as a git merge driver.`,
+}
+
+var lockfileMergeCmd = &cobra.Command{
+	Use:   "merge <base> <ours> <theirs>",
+	Args:  cobra.ExactArgs(3),
+	Short: "Merge changes to shed.lock.",
+	Long: `Does a three-way merge of the lockfiles ours and theirs, which were both changed from base,
+and writes the result to ours. The arguments are in the order git passes them to a merge driver.
+
+Tools that were added, removed, or changed in only one of the lockfiles are merged automatically.
+If a tool was changed differently in both, --strategy decides which change is kept:
+
+	higher  keep the higher version, this is the default
+	ours    keep the change in ours
+	theirs  keep the change in theirs
+	none    don't resolve conflicts
+
+A conflict that can't be resolved, ex: a tool upgraded in one lockfile and removed in the other,
+is printed, and merge exits with status 1 without changing ours.
+
+To use shed as the merge driver for shed.lock, add it to the git config:
+
+	git config merge.shed.name "shed lockfile merge"
+	git config merge.shed.driver "shed lockfile merge %O %A %B"
+
+and to .gitattributes:
+
+	shed.lock merge=shed`,
+	Run: func(cmd *cobra.Command, args []string) {
+		strategy, err := lockfile.ParseMergeStrategy(lockfileMergeOpts.strategy)
+		if err != nil {
+			fatal.ExitErrf(err, "Invalid merge strategy")
+		}
+		lfs := make([]*lockfile.Lockfile, len(args))
+		for i, p := range args {
+			data, err := ioutil.ReadFile(p)
+			if err != nil {
+				fatal.ExitErrf(err, "Failed to read file %s", p)
+			}
+			// git passes an empty base if the lockfile was added on both sides
+			if len(bytes.TrimSpace(data)) == 0 {
+				continue
+			}
+			lfs[i], err = lockfile.Parse(bytes.NewReader(data))
+			if err != nil {
+				fatal.ExitErrf(err, "Failed to read file %s", p)
+			}
+		}
+
+		merged, err := lockfile.Merge(lfs[0], lfs[1], lfs[2], strategy)
+		var mergeErr *lockfile.MergeError
+		if errors.As(err, &mergeErr) {
+			w := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+			for _, c := range mergeErr.Conflicts {
+				fmt.Fprintf(w, "%s\t%s\n", c.Name, c.Reason)
+			}
+			w.Flush()
+			fatal.Exitf("%d conflicts must be resolved manually", len(mergeErr.Conflicts))
+		} else if err != nil {
+			fatal.ExitErrf(err, "Failed to merge lockfiles")
+		}
+
+		var buf bytes.Buffer
+		if _, err := merged.WriteTo(&buf); err != nil {
+			fatal.ExitErrf(err, "Failed to write lockfile")
+		}
+		if err := ioutil.WriteFile(args[1], buf.Bytes(), 0o644); err != nil {
+			fatal.ExitErrf(err, "Failed to write file %s", args[1])
+		}
+	},
+}
+
+type lockfileMergeOptions struct {
+	strategy string
+}
+
+var lockfileMergeOpts lockfileMergeOptions
+
+func init() {
+	lockfileMergeCmd.Flags().StringVar(&lockfileMergeOpts.strategy, "strategy", lockfile.MergeHigher.String(), "how to resolve tools changed in both lockfiles, one of: higher, ours, theirs, none")
+	lockfileCmd.AddCommand(lockfileMergeCmd)
+	rootCmd.AddCommand(lockfileCmd)
+}
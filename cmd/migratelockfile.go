@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/spf13/cobra"
+)
+
+var migrateLockfileCmd = &cobra.Command{
+	Use:   "migrate-lockfile",
+	Args:  cobra.NoArgs,
+	Short: "Upgrade shed.lock to the current format.",
+	Long: `shed migrate-lockfile rewrites shed.lock using the newest format this version of shed supports.
+
+shed.lock records the version of its format. Older formats are read and upgraded automatically,
+and any command that changes shed.lock writes it in the newest format. Use migrate-lockfile to
+upgrade it without changing any tools, ex: to commit the upgrade separately.
+
+A lockfile written by a newer version of shed can't be read. Upgrade shed to use it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		setwd(logger)
+		shed := mustShed(client.WithLogger(logger), client.WithNoCache())
+
+		version, err := shed.MigrateLockfile(context.Background())
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to migrate lockfile")
+		}
+		if version == lockfile.FormatVersion {
+			logger.Infof("shed.lock already uses format version %d", version)
+			return
+		}
+		logger.Infof("Migrated shed.lock from format version %d to %d", version, lockfile.FormatVersion)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateLockfileCmd)
+}
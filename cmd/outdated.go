@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/spf13/cobra"
+)
+
+var outdatedCmd = &cobra.Command{
+	Use:   "outdated",
+	Args:  cobra.NoArgs,
+	Short: "List tools that have newer versions available.",
+	Long: `shed outdated checks if there is a newer version of each tool in shed.lock.
+Nothing is installed and shed.lock is not modified.
+
+For each tool with a newer version, the current version, the latest version, and whether
+the update is a major, minor, or patch update is printed. Use --all to include tools
+that are already up to date.
+
+The latest version is resolved by the go command using the module of each tool, so
+new major versions that have a different module path, like /v2, are not found.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		setwd(logger)
+		shed := mustShed(client.WithLogger(logger))
+		reports, err := shed.Outdated(context.Background())
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		n := 0
+		for _, r := range reports {
+			if r.Update == client.UpdateNone && !outdatedOpts.all {
+				continue
+			}
+			if n == 0 {
+				fmt.Fprintln(w, "TOOL\tCURRENT\tLATEST\tUPDATE")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Tool.ImportPath, r.Tool.Version, r.Latest, r.Update)
+			n++
+		}
+		w.Flush()
+
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to check for newer versions of tools")
+		}
+		if n == 0 {
+			fmt.Println("All tools are up to date.")
+		}
+	},
+}
+
+type outdatedOptions struct {
+	all bool
+}
+
+var outdatedOpts outdatedOptions
+
+func init() {
+	outdatedCmd.Flags().BoolVar(&outdatedOpts.all, "all", false, "include tools that are up to date")
+	rootCmd.AddCommand(outdatedCmd)
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// parsePlainOutput parses the value of --output and reports whether it is plain.
+func parsePlainOutput(s string) (bool, error) {
+	switch s {
+	case "", "default":
+		return false, nil
+	case "plain":
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid output %q, must be one of default, plain", s)
+}
+
+// plainFormatter formats logs as a single line that starts with a label for the level, ex: 'Info: Finished installing tools'.
+// Unlike logrus.TextFormatter, it doesn't quote the message or use 'key=value' for the level and message, so the logs
+// are easy to follow with a screen reader.
+type plainFormatter struct{}
+
+func (plainFormatter) Format(e *logrus.Entry) ([]byte, error) {
+	var b bytes.Buffer
+	level := e.Level.String()
+	b.WriteString(translate(strings.ToUpper(level[:1]) + level[1:]))
+	b.WriteString(": ")
+	b.WriteString(strings.TrimSuffix(e.Message, "\n"))
+	keys := make([]string, 0, len(e.Data))
+	for k := range e.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, ", %s: %v", k, e.Data[k])
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/spf13/cobra"
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore [tool]",
+	Args:  cobra.MaximumNArgs(1),
+	Short: "Restore an uninstalled Go tool.",
+	Long: `shed restore adds a tool that was removed with 'shed uninstall' back to shed.lock,
+at the same version it was uninstalled at.
+
+Uninstalling a tool doesn't remove it from the shed cache, so it can usually be used right away.
+If the tool was removed by 'shed cache prune' since, it is brought back from the trash of the cache.
+If it was purged from the trash, run 'shed install' to install it again.
+
+The tool name can either be the full import path or the binary name if it is unique.
+Without a tool, the tools that can be restored are listed.
+
+For example to restore the 'golang.org/x/tools/cmd/stringer' tool:
+
+	shed restore stringer`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		setwd(logger)
+		if len(args) == 0 {
+			shed := mustShed(client.WithLogger(logger), client.WithNoCache())
+			tools, err := shed.Uninstalled()
+			if err != nil {
+				fatal.ExitErrf(err, "Failed to list uninstalled tools")
+			}
+			for _, t := range tools {
+				fmt.Println(t)
+			}
+			return
+		}
+
+		shed := mustShed(client.WithLogger(logger))
+		t, installed, err := shed.Restore(context.Background(), args[0])
+		if errors.Is(err, lockfile.ErrNotFound) {
+			fatal.Exitf("No uninstalled tool named %s", args[0])
+		} else if err != nil {
+			fatal.ExitErrf(err, "Failed to restore tool %s", args[0])
+		}
+		if !installed {
+			logger.Infof("Restored %s, run 'shed install' to install it again", t)
+			return
+		}
+		logger.Infof("Restored %s", t)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+}
@@ -1,26 +1,56 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/getshiphub/shed/cache"
 	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/config"
+	"github.com/getshiphub/shed/features"
+	"github.com/getshiphub/shed/internal/color"
+	"github.com/getshiphub/shed/internal/i18n"
 	"github.com/getshiphub/shed/internal/util"
-	"github.com/mattn/go-isatty"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/remote"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"golang.org/x/mod/semver"
 )
 
 // Set by goreleaser when release build is created.
 var version string
 
 type rootOptions struct {
-	verbose bool
+	verbose     bool
+	color       string
+	output      string
+	context     string
+	lockTimeout time.Duration
+	offline     bool
 }
 
 var (
 	rootOpts rootOptions
-	fatal    = util.Fatal{}
+	fatal    = util.Fatal{Translate: translate}
+	// language is the language messages are shown in, from the user config or the locale.
+	language = i18n.FromEnv()
+	// colorMode is the resolved colour policy from the flag and user config.
+	colorMode color.Mode
+	// plainOutput is whether spinners, progress, and colour are replaced with labeled lines for screen readers.
+	plainOutput bool
+	// userConfig is the user config file, it is read before any command is run.
+	userConfig = &config.User{}
+	// userContext is the settings of the selected context in the user config.
+	userContext = config.UserContext{Cache: &config.UserCache{}}
+	// userContextName is the name of the selected context, empty if there is none.
+	userContextName string
 )
 
 var rootCmd = &cobra.Command{
@@ -29,41 +59,232 @@ var rootCmd = &cobra.Command{
 	Short:   "shed is a CLI for easily managing Go tool dependencies.",
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		fatal.ShowErrorDetail = rootOpts.verbose
+		userConfig = mustUserConfig()
+		if userConfig.Language != "" {
+			lang, err := i18n.ParseLanguage(userConfig.Language)
+			if err != nil {
+				fatal.ExitErrf(err, "Invalid language")
+			}
+			language = lang
+		}
+
+		// The flag takes precedence over the environment, which takes precedence over the config
+		name := os.Getenv(config.ContextEnvVar)
+		if cmd.Flags().Changed("context") {
+			name = rootOpts.context
+		}
+		var err error
+		userContext, err = userConfig.ResolveContext(name)
+		if err != nil {
+			fatal.ExitErrf(err, "Invalid context")
+		}
+		userContextName = name
+		if userContextName == "" {
+			userContextName = userConfig.Context
+		}
+
+		// The flag takes precedence over the config
+		mode := userConfig.Color
+		if cmd.Flags().Changed("color") {
+			mode = rootOpts.color
+		}
+		colorMode, err = color.ParseMode(mode)
+		if err != nil {
+			fatal.ExitErrf(err, "Invalid color option")
+		}
+
+		output := userConfig.Output
+		if cmd.Flags().Changed("output") {
+			output = rootOpts.output
+		}
+		plainOutput, err = parsePlainOutput(output)
+		if err != nil {
+			fatal.ExitErrf(err, "Invalid output option")
+		}
+		if plainOutput {
+			colorMode = color.Never
+		}
 	},
 }
 
 func init() {
-	rootCmd.PersistentFlags().BoolVar(&rootOpts.verbose, "verbose", false, "enable verbose logging")
+	rootCmd.PersistentFlags().BoolVarP(&rootOpts.verbose, "verbose", "v", false, "enable verbose logging")
+	rootCmd.PersistentFlags().StringVar(&rootOpts.color, "color", "auto", "when to use colour in output: auto, always, or never")
+	rootCmd.PersistentFlags().StringVar(&rootOpts.output, "output", "default", "how to show progress and logs: default, or plain for screen readers")
+	rootCmd.PersistentFlags().StringVar(&rootOpts.context, "context", "", "name of the context in the user config to use, overrides "+config.ContextEnvVar)
+	rootCmd.PersistentFlags().DurationVar(&rootOpts.lockTimeout, "lock-timeout", 0, "how long to wait for another shed process using the lockfile or cache, 0 means no limit")
+	rootCmd.PersistentFlags().BoolVar(&rootOpts.offline, "offline", false, "install tools only from the directory created by 'shed vendor', overrides "+offlineEnvVar)
 }
 
 // Execute runs the shed CLI.
 func Execute() {
+	if completeToolArgs(os.Args[1:], os.Stdout) {
+		return
+	}
 	if err := rootCmd.Execute(); err != nil {
 		fatal.ExitErrf(err, "Failed executing command.")
 	}
 }
 
 func mustShed(opts ...client.Option) *client.Shed {
-	shed, err := client.NewShed(opts...)
+	shed, err := client.NewShed(mustShedOptions(opts...)...)
+	var verr *config.ShedVersionError
+	if errors.As(err, &verr) {
+		selfUpdate(verr)
+	}
+	if errors.Is(err, lockfile.ErrUnsupportedFormat) {
+		fatal.ExitErrf(err, "shed.lock was written by a newer version of shed")
+	}
 	if err != nil {
 		fatal.ExitErrf(err, "Failed to setup shed")
 	}
 	return shed
 }
 
+// mustShedOptions returns the options used to create a Shed, which are the options of the selected
+// context and the global flags followed by opts.
+func mustShedOptions(opts ...client.Option) []client.Option {
+	ctxOpts, err := contextOptions(userContext)
+	if err != nil {
+		fatal.ExitErrf(err, "Failed to setup context")
+	}
+	ctxOpts = append(ctxOpts, client.WithLockTimeout(rootOpts.lockTimeout), client.WithShedVersion(shedVersion()), client.WithFeatures(mustFeatureLayers()...))
+	if offline, err := strconv.ParseBool(os.Getenv(offlineEnvVar)); rootOpts.offline || (err == nil && offline) {
+		ctxOpts = append(ctxOpts, client.WithOffline(true))
+	}
+	// Without a global lockfile, only the tools in the project can be used, so this isn't fatal
+	if p, err := client.GlobalLockfilePath(); err == nil {
+		ctxOpts = append(ctxOpts, client.WithGlobalLockfile(p))
+	}
+	// Prepend so the given options take precedence
+	return append(ctxOpts, opts...)
+}
+
+// shedVersion returns the version of shed that is running, or an empty string if it is
+// a development build, in which case the version required by the project isn't checked.
+func shedVersion() string {
+	if version != "" {
+		// goreleaser strips the 'v' prefix
+		return "v" + strings.TrimPrefix(version, "v")
+	}
+	// Use the module version if shed was installed with 'go install'
+	if info, ok := debug.ReadBuildInfo(); ok && semver.IsValid(info.Main.Version) {
+		return info.Main.Version
+	}
+	return ""
+}
+
+// userCacheDir returns the cache directory set in the context c, or an empty string if the default
+// should be used. SHED_CACHE_DIR takes precedence over the user config, like other environment variables.
+func userCacheDir(c config.UserContext) string {
+	if os.Getenv(cache.DirEnvVar) != "" {
+		return ""
+	}
+	return c.Cache.Dir
+}
+
+// contextOptions returns the options to create a Shed with the settings of the context c.
+func contextOptions(c config.UserContext) ([]client.Option, error) {
+	opts := []client.Option{
+		client.WithCacheDir(userCacheDir(c)),
+		client.WithSharedCache(c.Cache.Shared),
+		client.WithModuleCache(c.Cache.Modules),
+		client.WithIsolatedCache(c.Cache.Isolated),
+		client.WithGoCaches(c.Cache.GoModCache, c.Cache.GoBuildCache),
+	}
+	if c.GoProxy != "" {
+		opts = append(opts, client.WithGoProxy(c.GoProxy))
+	}
+	if rc := c.RemoteCache; rc != nil {
+		b, err := remote.New(rc.URL, os.Getenv(client.RemoteCacheTokenEnvVar))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to setup remote cache")
+		}
+		opts = append(opts, client.WithRemoteCache(b, rc.ReadOnly))
+	}
+	policyPath, err := userPolicyPath(c)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find user config")
+	}
+	if policyPath != "" {
+		p, err := readPolicy(policyPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read policy file %s", policyPath)
+		}
+		opts = append(opts, client.WithPolicy(p))
+	}
+	return opts, nil
+}
+
+// mustFeatureLayers returns the settings for features from the user config and the environment.
+func mustFeatureLayers() []features.Layer {
+	env, err := features.ParseEnv(os.Getenv(features.EnvVar))
+	if err != nil {
+		fatal.ExitErrf(err, "Invalid %s", features.EnvVar)
+	}
+	return []features.Layer{
+		{Source: "user", Values: userConfig.Features},
+		{Source: "env", Values: env},
+	}
+}
+
+// translate returns the translation of msg in the language messages are shown in.
+func translate(msg string) string {
+	return i18n.Translate(language, msg)
+}
+
+// exitToolName exits with the message format for err, which is the error for a tool name that isn't found
+// or refers to more than one tool, followed by the tools the name may have meant.
+func exitToolName(err error, format string, a ...interface{}) {
+	msg := fmt.Sprintf(translate(format), a...)
+	var nfErr *lockfile.NotFoundError
+	var nameErr *lockfile.NameError
+	if errors.As(err, &nfErr) && len(nfErr.Suggestions) > 0 {
+		msg += "\n" + fmt.Sprintf(translate("Did you mean %s?"), strings.Join(nfErr.Suggestions, ", "))
+	} else if errors.As(err, &nameErr) && errors.Is(err, lockfile.ErrMultipleTools) {
+		msg += "\n" + translate("It could be any of:")
+		for _, t := range nameErr.Tools {
+			msg += "\n  " + t.ImportPath
+		}
+	}
+	fatal.Exitf("%s", msg)
+}
+
+func mustUserConfig() *config.User {
+	p, err := config.UserPath()
+	if err != nil {
+		fatal.ExitErrf(err, "Failed to find user config")
+	}
+	u, err := config.ReadUser(p)
+	if err != nil {
+		fatal.ExitErrf(err, "Failed to read user config %s", p)
+	}
+	return u
+}
+
 func newLogger() *logrus.Logger {
 	level := logrus.InfoLevel
 	if rootOpts.verbose {
 		level = logrus.DebugLevel
 	}
+	if plainOutput {
+		return &logrus.Logger{
+			Out:       os.Stderr,
+			Formatter: plainFormatter{},
+			Hooks:     make(logrus.LevelHooks),
+			Level:     level,
+		}
+	}
+	useColor := color.Enabled(colorMode, os.Stderr)
 	return &logrus.Logger{
 		Out: os.Stderr,
 		Formatter: &logrus.TextFormatter{
 			DisableTimestamp: true,
-			// Need to force colours since the decision of whether or not to use colour
+			// Need to explicitly set colours since the decision of whether or not to use colour
 			// is made lazily the first time a log is written, and Out may be changed
 			// to a spinner before then.
-			ForceColors: isatty.IsTerminal(os.Stderr.Fd()),
+			ForceColors:   useColor,
+			DisableColors: !useColor,
 		},
 		Hooks: make(logrus.LevelHooks),
 		Level: level,
@@ -72,14 +293,15 @@ func newLogger() *logrus.Logger {
 
 // setwd finds the nearest shed lockfile in either the current directory
 // or parent directories and changes the current working directory.
-func setwd(logger *logrus.Logger) {
+// It returns the original working directory.
+func setwd(logger *logrus.Logger) string {
 	cwd, err := os.Getwd()
 	if err != nil {
 		fatal.ExitErrf(err, "Failed to get current working directory")
 	}
 	lfp := client.ResolveLockfilePath(cwd)
 	if lfp == "" {
-		return
+		return cwd
 	}
 
 	logger.Debugf("Found lockfile: %s", lfp)
@@ -88,4 +310,33 @@ func setwd(logger *logrus.Logger) {
 		fatal.ExitErrf(err, "Failed to change current working directory to %s", dir)
 	}
 	logger.Debugf("Changed current working directory to %s", dir)
+	return cwd
+}
+
+// setwdGlobal is like setwd, but if global is true the current directory isn't changed, and the returned
+// options make shed use the global lockfile instead of the lockfile of the project.
+func setwdGlobal(logger *logrus.Logger, global bool) []client.Option {
+	if !global {
+		setwd(logger)
+		return nil
+	}
+	p, err := client.GlobalLockfilePath()
+	if err != nil {
+		fatal.ExitErrf(err, "Failed to find global lockfile")
+	}
+	// The lockfile is written to a temp file in the same directory, so it must exist
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		fatal.ExitErrf(err, "Failed to create directory %s", filepath.Dir(p))
+	}
+	logger.Debugf("Using global lockfile: %s", p)
+	return []client.Option{client.WithLockfilePath(p)}
+}
+
+// resolvePath returns p relative to dir if it is not absolute. This is used for paths given
+// as flags, since they are relative to the directory shed was run from, not the one setwd changed to.
+func resolvePath(dir, p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
 }
@@ -1,18 +1,31 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
 	"errors"
+	"fmt"
 	"os"
-	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/getshiphub/shed/cache"
 	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/config"
+	"github.com/getshiphub/shed/internal/color"
 	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+	"github.com/getshiphub/shed/xdg"
+	"github.com/mattn/go-isatty"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 var runCmd = &cobra.Command{
-	Use:   "run <tool> [args...]",
+	Use:   "run <tool>[@version] [args...]",
 	Args:  cobra.MinimumNArgs(1),
 	Short: "Run installed tools.",
 	Long: `shed run runs an installed tool passing all arguments to it.
@@ -23,49 +36,245 @@ That is, 'shed run --verbose foo' is treated differently than 'shed run foo --ve
 The first treats the '--verbose' flag as belonging to shed, the second treats it as belonging
 to the 'foo' tool.
 
+If the tool isn't in shed.lock, or there is no shed.lock, the global lockfile is used,
+see 'shed install --global'. shed.lock always takes precedence.
+
 For example to run the stringer tool you can either run:
 
 	shed run stringer -type=Pill
 
 Or:
 
-	shed run golang.org/x/tools/cmd/stringer -type=Pill`,
+	shed run golang.org/x/tools/cmd/stringer -type=Pill
+
+The first time a tool from a module is run on this machine, shed asks whether to trust the module.
+The decision is recorded, so shed only asks once per module. This protects against running tools
+from unfamiliar repositories by accident. If stdin is not a terminal, untrusted modules are not run.
+Use --trust-all, or set SHED_TRUST_ALL=1, to skip the check, ex: in CI.
+
+Use --env to set environment variables for the tool and --dir to run it in another directory:
+
+	shed run --env GOFLAGS=-mod=mod --dir ./api golangci-lint run
+
+shed refuses to run a tool whose binary was changed after it was installed, since it no longer
+matches the version in shed.lock. Reinstall the tool with 'shed install' to fix it, or use --force
+to run the modified binary anyway.
+
+Use --rebuild to install the tool before running it if its binary is missing, for example because the cache
+was cleaned, or if it was built with a different version of Go than the go command, for example after Go
+was upgraded.
+
+To run a version of a tool other than the one in shed.lock, add the version to the tool name. The version must be
+exact and already in the cache, for example because another branch installed it, or use --rebuild to add it to the
+cache. shed.lock is not changed, so many versions of a tool can be used side by side:
+
+	shed run golangci-lint@v1.50.0 run ./...`,
 	Run: func(cmd *cobra.Command, args []string) {
 		toolName := args[0]
 		logger := newLogger()
-		setwd(logger)
-		shed := mustShed(client.WithLogger(logger))
-		binPath, err := shed.ToolPath(toolName)
+		origDir := setwd(logger)
+		shed := mustShed(client.WithLogger(logger), trustOption())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		opts := newRunOptions(ctx, cancel, cmd, origDir)
+		name := toolName
+		if i := strings.LastIndexByte(toolName, '@'); i != -1 {
+			name, opts.Version = toolName[:i], toolName[i+1:]
+		}
+		report, err := shed.Run(ctx, name, args[1:], opts)
 		if errors.Is(err, lockfile.ErrNotFound) {
-			fatal.Exitf("No tool named %s installed. Run 'shed install' first to install the tool.", toolName)
+			exitToolName(err, "No tool named %s installed. Run 'shed install' first to install the tool.", toolName)
 		} else if errors.Is(err, lockfile.ErrMultipleTools) {
-			fatal.Exitf("Multiple tools named %s found. Specify the full import path of the tool in order to run it.", toolName)
-		} else if err != nil {
-			fatal.ExitErrf(err, "Failed to run tool %s", toolName)
+			exitToolName(err, "Multiple tools named %s found. Specify the full import path of the tool in order to run it.", toolName)
+		} else if errors.Is(err, client.ErrUntrusted) {
+			fatal.Exitf("Not running %s since its module was not trusted.", toolName)
+		} else if errors.Is(err, cache.ErrBinaryModified) {
+			fatal.ExitErrf(err, "Not running %s since its binary was modified after it was installed. Run 'shed install' to reinstall it, or use --force to run it anyway", toolName)
+		} else if errors.Is(err, cache.ErrStale) && opts.Version != "" {
+			fatal.ExitErrf(err, "Not running %s since it is not in the cache. Use --rebuild to add it to the cache without changing shed.lock", toolName)
+		} else if errors.Is(err, cache.ErrStale) {
+			fatal.ExitErrf(err, "Not running %s since it is not installed. Run 'shed install' to install it, or use --rebuild to install it automatically", toolName)
 		}
-
-		logger.WithFields(logrus.Fields{
-			"tool": toolName,
-			"path": binPath,
-		}).Debugf("Found path for tool")
-
-		c := exec.Command(binPath, args[1:]...)
-		c.Stdout = os.Stdout
-		c.Stderr = os.Stderr
-		c.Stdin = os.Stdin
-		if err := c.Run(); err != nil {
-			code := c.ProcessState.ExitCode()
-			if code != -1 {
-				os.Exit(code)
-			}
-			os.Exit(1)
+		if runOpts.stats {
+			printStats(report, toolName)
 		}
+		exitRun(logger, report, err, toolName)
 	},
 }
 
+// trustAllEnvVar is the environment variable that disables the trust check when set to a true value.
+const trustAllEnvVar = "SHED_TRUST_ALL"
+
+type runOptions struct {
+	trustAll   bool
+	retries    int
+	backoff    time.Duration
+	timeout    time.Duration
+	killAfter  time.Duration
+	tty        bool
+	captureDir string
+	stats      bool
+	force      bool
+	rebuild    bool
+	env        []string
+	dir        string
+}
+
+var runOpts runOptions
+
+// newRunOptions creates the options used to run a tool from dir. It also sets up
+// a listener for SIGINT which calls cancel so that no further attempts are made.
+func newRunOptions(ctx context.Context, cancel context.CancelFunc, cmd *cobra.Command, dir string) client.RunOptions {
+	opts := client.RunOptions{
+		Dir:           dir,
+		Stdin:         os.Stdin,
+		Stdout:        os.Stdout,
+		Stderr:        os.Stderr,
+		Env:           color.Environ(os.Environ(), color.Enabled(colorMode, os.Stdout)),
+		Timeout:       runOpts.timeout,
+		KillAfter:     runOpts.killAfter,
+		CaptureDir:    runOpts.captureDir,
+		RecordStats:   runOpts.stats,
+		AllowModified: runOpts.force,
+		Rebuild:       runOpts.rebuild,
+	}
+	for _, kv := range runOpts.env {
+		if strings.Index(kv, "=") <= 0 {
+			fatal.Exitf("Invalid --env %q, must be in the form KEY=VALUE", kv)
+		}
+		opts.ExtraEnv = append(opts.ExtraEnv, kv)
+	}
+	if runOpts.dir != "" {
+		opts.Dir = resolvePath(dir, runOpts.dir)
+	}
+	if cmd.Flags().Changed("tty") {
+		opts.TTY = client.TTYNever
+		if runOpts.tty {
+			opts.TTY = client.TTYAlways
+		}
+	}
+	if cmd.Flags().Changed("retries") || cmd.Flags().Changed("backoff") {
+		opts.Retry = &config.RetryPolicy{
+			Retries: runOpts.retries,
+			Backoff: config.Duration(runOpts.backoff),
+		}
+	}
+
+	abort := make(chan os.Signal, 1)
+	signal.Notify(abort, os.Interrupt)
+	go func() {
+		select {
+		case <-abort:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return opts
+}
+
+// trustOption returns the option that enables trust on first use, unless it was disabled with --trust-all.
+func trustOption() client.Option {
+	if v, err := strconv.ParseBool(os.Getenv(trustAllEnvVar)); runOpts.trustAll || (err == nil && v) {
+		return func(*client.Shed) {}
+	}
+	stateDir, err := xdg.StateDir()
+	if err != nil {
+		fatal.ExitErrf(err, "Failed to find state directory")
+	}
+	return client.WithTrust(filepath.Join(stateDir, client.TrustFileName), promptTrust)
+}
+
+// promptTrust asks the user whether to trust mod. If stdin is not a terminal, mod is not trusted.
+func promptTrust(t tool.Tool, mod string) (bool, error) {
+	if !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+		return false, errors.New("stdin is not a terminal, so the module can't be trusted interactively; use --trust-all or set " + trustAllEnvVar + "=1 to run it")
+	}
+	fmt.Fprintf(os.Stderr, translate("%s is provided by %s, which has not been run on this machine before.\nTrust %s and run it? [y/N] "), t, mod, mod)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return false, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// exitRun handles the result of running a tool. If the tool failed, the process
+// will exit with the same exit code as the tool.
+func exitRun(logger *logrus.Logger, report *client.RunReport, err error, name string) {
+	if report == nil {
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to run %s", name)
+		}
+		return
+	}
+
+	for i, a := range report.Attempts {
+		logger.WithFields(logrus.Fields{
+			"attempt":  i + 1,
+			"exitCode": a.ExitCode,
+			"duration": a.Duration,
+		}).Debugf("Ran %s", report.Tool)
+	}
+	if report.CaptureManifest != "" {
+		logger.Debugf("Wrote capture manifest: %s", report.CaptureManifest)
+	}
+	if err == nil {
+		return
+	}
+	var timeoutErr *client.TimeoutError
+	if errors.As(err, &timeoutErr) {
+		fatal.Exitf("%s", timeoutErr.Error())
+	}
+	if code := report.ExitCode(); code > 0 {
+		os.Exit(code)
+	}
+	fatal.ExitErrf(err, "Failed to run %s", name)
+}
+
+// printStats prints the stats of the last attempt in report to stderr.
+func printStats(report *client.RunReport, name string) {
+	if report == nil || len(report.Attempts) == 0 {
+		return
+	}
+	a := report.Attempts[len(report.Attempts)-1]
+	cpu := a.UserTime + a.SystemTime
+	msg := fmt.Sprintf("%s: exit code %d, wall time %s, CPU time %s (user %s, system %s)",
+		name, a.ExitCode, a.Duration.Round(time.Millisecond), cpu.Round(time.Millisecond),
+		a.UserTime.Round(time.Millisecond), a.SystemTime.Round(time.Millisecond))
+	if a.MaxRSS > 0 {
+		msg += fmt.Sprintf(", max RSS %.1f MB", float64(a.MaxRSS)/(1<<20))
+	}
+	if n := len(report.Attempts); n > 1 {
+		msg += fmt.Sprintf(", %d attempts", n)
+	}
+	fmt.Fprintln(os.Stderr, msg)
+}
+
+func addRunFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&runOpts.retries, "retries", 0, "number of times to retry the tool if it fails, overrides the config file")
+	cmd.Flags().DurationVar(&runOpts.backoff, "backoff", 0, "amount of time to wait between retries, overrides the config file")
+	cmd.Flags().DurationVar(&runOpts.timeout, "timeout", 0, "maximum amount of time the tool can run before it is terminated, overrides the config file")
+	cmd.Flags().DurationVar(&runOpts.killAfter, "kill-after", 0, "amount of time to wait after terminating the tool before killing it, overrides the config file")
+	cmd.Flags().BoolVar(&runOpts.tty, "tty", false, "run the tool in a pseudo-terminal, by default one is allocated if stdin is a terminal but output is not")
+	cmd.Flags().StringVar(&runOpts.captureDir, "capture", "", "directory to save the output of the tool and a manifest of the run to")
+	cmd.Flags().BoolVar(&runOpts.trustAll, "trust-all", false, "run tools from modules that have not been trusted on this machine without asking")
+	cmd.Flags().BoolVar(&runOpts.rebuild, "rebuild", false, "rebuild the tool first if its binary is missing or was built with a different version of Go")
+	cmd.Flags().BoolVar(&runOpts.stats, "stats", false, "print the wall time, CPU time, and memory usage of the tool and record them in the run history")
+	cmd.Flags().StringArrayVar(&runOpts.env, "env", nil, "set an environment variable for the tool in the form KEY=VALUE, can be repeated")
+	cmd.Flags().StringVar(&runOpts.dir, "dir", "", "directory to run the tool in, by default the current directory unless the config file pins one")
+}
+
 func init() {
 	// Stop parsing flags after first non-flag arg
 	// so we can pass them to the command being run
 	runCmd.Flags().SetInterspersed(false)
+	runCmd.ValidArgsFunction = completeToolNames
+	addRunFlags(runCmd)
+	// Only for run since --force means something else for tasks
+	runCmd.Flags().BoolVar(&runOpts.force, "force", false, "run the tool even if its binary was modified after it was installed")
 	rootCmd.AddCommand(runCmd)
 }
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/spf13/cobra"
+)
+
+var scriptCmd = &cobra.Command{
+	Use:   "script",
+	Short: "Pin and run Go scripts.",
+	Long: `shed script manages Go scripts in the project.
+
+A script is a single file main package in the project, ex: a release or code generation script.
+Scripts are pinned in shed.lock along with the versions of the modules they import, so they are
+as reproducible as tools. Scripts usually have a '//go:build ignore' constraint so that they are
+not part of any package in the project.
+
+'shed script pin' can be used to add a script to shed.lock or upgrade its modules.
+'shed script run' can be used to run a pinned script.
+'shed script list' can be used to list the pinned scripts.
+'shed script unpin' can be used to remove a script from shed.lock.`,
+}
+
+var scriptPinCmd = &cobra.Command{
+	Use:   "pin <file>...",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Add Go scripts to shed.lock.",
+	Long: `Adds the Go scripts to shed.lock along with the latest versions of the modules they import.
+If a script is already pinned, its modules are resolved again, which upgrades them.
+A script must be pinned again whenever it imports new modules.
+
+	shed script pin scripts/release.go`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		origDir := setwd(logger)
+		shed := mustShed(client.WithLogger(logger))
+
+		for _, p := range args {
+			sc, err := shed.PinScript(context.Background(), resolvePath(origDir, p))
+			if err != nil {
+				fatal.ExitErrf(err, "Failed to pin script %s", p)
+			}
+			logger.Infof("Pinned %s with %d modules", sc.Path, len(sc.Requires))
+		}
+	},
+}
+
+var scriptRunCmd = &cobra.Command{
+	Use:   "run <file> [args...]",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Run pinned Go scripts.",
+	Long: `Builds a pinned Go script with the modules in shed.lock and runs it passing all arguments to it.
+Scripts are only built again when they or their modules change.
+
+All arguments after the script will be passed to the script as is, even if they are flags.
+
+	shed script run scripts/release.go --version v1.2.0
+
+Scripts are run like tools with 'shed run', except that the config file doesn't apply to them,
+and they don't need to be trusted since they are part of the project.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		logger := newLogger()
+		origDir := setwd(logger)
+		shed := mustShed(client.WithLogger(logger))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		report, err := shed.RunScript(ctx, resolvePath(origDir, name), args[1:], newRunOptions(ctx, cancel, cmd, origDir))
+		if errors.Is(err, lockfile.ErrNotFound) {
+			fatal.Exitf("Script %s is not pinned. Run 'shed script pin %s' first to pin it.", name, name)
+		} else if errors.Is(err, cache.ErrScriptRequires) {
+			fatal.ExitErrf(err, "Script %s imports modules that are not pinned. Run 'shed script pin %s' to pin them", name, name)
+		}
+		if runOpts.stats {
+			printStats(report, name)
+		}
+		exitRun(logger, report, err, name)
+	},
+}
+
+var scriptListCmd = &cobra.Command{
+	Use:   "list",
+	Args:  cobra.NoArgs,
+	Short: "List pinned Go scripts.",
+	Long:  `Lists the Go scripts in shed.lock. Use --modules to also list the modules of each script.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		setwd(logger)
+		shed := mustShed(client.WithLogger(logger), client.WithNoCache())
+
+		for _, sc := range shed.Scripts() {
+			fmt.Println(sc.Path)
+			if !scriptListOpts.modules {
+				continue
+			}
+			for _, mod := range sc.Requires {
+				fmt.Printf("\t%s %s\n", mod.Path, mod.Version)
+			}
+		}
+	},
+}
+
+var scriptListOpts struct {
+	modules bool
+}
+
+var scriptUnpinCmd = &cobra.Command{
+	Use:   "unpin <file>...",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Remove Go scripts from shed.lock.",
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		origDir := setwd(logger)
+		shed := mustShed(client.WithLogger(logger), client.WithNoCache())
+
+		for _, p := range args {
+			err := shed.UnpinScript(context.Background(), resolvePath(origDir, p))
+			if errors.Is(err, lockfile.ErrNotFound) {
+				fatal.Exitf("Script %s is not pinned.", p)
+			} else if err != nil {
+				fatal.ExitErrf(err, "Failed to unpin script %s", p)
+			}
+		}
+	},
+}
+
+func init() {
+	// Stop parsing flags after the script so we can pass them to it
+	scriptRunCmd.Flags().SetInterspersed(false)
+	addRunFlags(scriptRunCmd)
+	scriptListCmd.Flags().BoolVar(&scriptListOpts.modules, "modules", false, "list the modules of each script")
+	scriptCmd.AddCommand(scriptPinCmd)
+	scriptCmd.AddCommand(scriptRunCmd)
+	scriptCmd.AddCommand(scriptListCmd)
+	scriptCmd.AddCommand(scriptUnpinCmd)
+	rootCmd.AddCommand(scriptCmd)
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/getshiphub/shed/config"
+	"github.com/getshiphub/shed/resolver"
+	"github.com/getshiphub/shed/selfupdate"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+	"golang.org/x/mod/semver"
+)
+
+// selfUpdateEnvVar is the environment variable that allows shed to update itself without asking
+// when the project requires a newer version.
+const selfUpdateEnvVar = "SHED_SELF_UPDATE"
+
+// shedModule is the module path used to install shed.
+const shedModule = "github.com/getshiphub/shed"
+
+// selfUpdate handles verr, which means the running version of shed isn't allowed by the project.
+// If the user agrees, either by setting SHED_SELF_UPDATE or when prompted, a version that is allowed
+// is installed with 'go install'. shed always exits, since the command must be run again with the new version.
+func selfUpdate(verr *config.ShedVersionError) {
+	allowed, err := strconv.ParseBool(os.Getenv(selfUpdateEnvVar))
+	if err != nil || !allowed {
+		if !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+			fatal.Exitf("This project requires shed %s, but this is shed %s. Install a version of shed it allows, or set %s=1 to install one automatically.", verr.Required, verr.Version, selfUpdateEnvVar)
+		}
+		fmt.Fprintf(os.Stderr, translate("This project requires shed %s, but this is shed %s.\nInstall a version of shed it allows with 'go install'? [y/N] "), verr.Required, verr.Version)
+		// No input is treated as no
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+		default:
+			fatal.Exitf("Not running with shed %s since this project requires shed %s.", verr.Version, verr.Required)
+		}
+	}
+
+	// A minimum version is satisfied by the latest version, otherwise find the highest allowed version
+	target := "latest"
+	if !semver.IsValid(verr.Required) {
+		mv, err := resolver.ProxyFromEnv().ResolveConstraint(context.Background(), shedModule, verr.Required)
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to find a version of shed that matches %s", verr.Required)
+		}
+		target = mv.Version
+	}
+	cmd := exec.Command("go", "install", shedModule+"@"+target)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fatal.ExitErrf(err, "Failed to install shed %s", target)
+	}
+	fatal.Exitf("Installed shed %s with 'go install'. Run the command again to use it, making sure the directory 'go install' uses is in your PATH.", target)
+}
+
+var selfCmd = &cobra.Command{
+	Use:   "self",
+	Short: "Manage the shed binary.",
+	Long: `shed self manages the shed binary itself.
+
+'shed self update' can be used to update shed to the latest release.`,
+}
+
+var selfUpdateOpts struct {
+	check bool
+}
+
+var selfUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Args:  cobra.NoArgs,
+	Short: "Update shed to the latest release.",
+	Long: `Updates shed to the latest release on GitHub.
+
+The archive for the current platform is downloaded and its SHA-256 hash is checked against the
+checksums file published with the release before the running binary is replaced. Releases are not
+signed, so the checksums file is trusted as much as GitHub is. The binary is replaced atomically,
+so an interrupted update leaves the previous version in place. On Windows the previous binary is
+kept next to the new one with '.old' added, and removed by the next update.
+
+Use --check to only report whether a newer release is available. shed exits with a non-zero status
+if there is one, which is useful in scripts.
+
+Only release builds can update themselves. If shed was installed with 'go install', update it with
+'go install github.com/getshiphub/shed@latest' instead, since it would otherwise be replaced with a
+binary that wasn't built by Go on this machine. Development builds can't be updated.
+
+Set GITHUB_TOKEN to avoid the rate limit of the GitHub API.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		current := shedVersion()
+		if current == "" {
+			fatal.Exitf("This is a development build of shed, which can't be updated. Build it again from source instead.")
+		}
+		updater := selfupdate.New(nil)
+		ctx := context.Background()
+		release, err := updater.Latest(ctx)
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to find the latest release of shed")
+		}
+		if !release.Newer(current) {
+			fmt.Printf("shed %s is the latest release.\n", current)
+			return
+		}
+		if selfUpdateOpts.check {
+			fmt.Printf("shed %s is available, this is shed %s. Run 'shed self update' to update.\n%s\n", release.Version, current, release.URL)
+			os.Exit(1)
+		}
+		if version == "" {
+			fatal.Exitf("shed %s was installed with 'go install', update it with 'go install %s@latest' instead.", current, shedModule)
+		}
+
+		exePath, err := os.Executable()
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to find the path of the shed binary")
+		}
+		if exePath, err = filepath.EvalSymlinks(exePath); err != nil {
+			fatal.ExitErrf(err, "Failed to find the path of the shed binary")
+		}
+		logger.Debugf("Updating %s from %s to %s", exePath, current, release.Version)
+		if err := updater.Install(ctx, release, exePath); err != nil {
+			fatal.ExitErrf(err, "Failed to update shed to %s", release.Version)
+		}
+		logger.Infof("Updated shed from %s to %s", current, release.Version)
+		fmt.Println(release.URL)
+	},
+}
+
+func init() {
+	selfUpdateCmd.Flags().BoolVar(&selfUpdateOpts.check, "check", false, "only report whether a newer release is available")
+	selfCmd.AddCommand(selfUpdateCmd)
+	rootCmd.AddCommand(selfCmd)
+}
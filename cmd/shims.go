@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/spf13/cobra"
+)
+
+var shimsCmd = &cobra.Command{
+	Use:   "shims",
+	Args:  cobra.NoArgs,
+	Short: "Create shims to run tools from PATH.",
+	Long: `shed shims creates a shim for each tool in shed.lock in .shed/bin in the project root.
+A shim is a small script named after the tool that runs it with 'shed run', so adding the directory
+to PATH lets editors and scripts run the tools by name and always get the versions in shed.lock.
+
+Shims look up the version when they are run, so they only need to be created again when tools are
+added, removed, or renamed. Shims for tools that are no longer in shed.lock are removed.
+
+Use 'shed env --path' to print the command that adds the directory to PATH:
+
+	shed shims
+	eval "$(shed env --path)"
+	golangci-lint run
+
+Shims run the shed executable that created them. Use --shed to run another one instead, ex: 'shed'
+to use whichever one is in PATH.
+
+Shims are named after their tool. Use --name, or shims.name in the project config, to name them with
+a Go template instead. The template can use .Name, .ImportPath, .Version, and .MajorVersion, ex: to
+have a shim for each major version of tools with the same name:
+
+	shed shims --name '{{.Name}}-{{.MajorVersion}}'
+
+On windows, each tool gets a .cmd shim for cmd.exe and a .ps1 shim for PowerShell. Shim names that
+only differ in case are an error on windows and macOS, since their file systems ignore case. Use --os
+to create the shims for another OS, ex: to check the windows shims from CI on linux.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		origDir := setwd(logger)
+		shed := mustShed(client.WithLogger(logger), client.WithNoCache())
+
+		shedPath := shimsOpts.shed
+		if shedPath == "" {
+			exe, err := os.Executable()
+			if err != nil {
+				fatal.ExitErrf(err, "Failed to find the shed executable, use --shed to provide it")
+			}
+			shedPath = exe
+		}
+		dir := ""
+		if shimsOpts.dir != "" {
+			dir = resolvePath(origDir, shimsOpts.dir)
+		}
+		opts := []client.ShimOption{client.ShimShedPath(shedPath)}
+		if shimsOpts.name != "" {
+			opts = append(opts, client.ShimNameTemplate(shimsOpts.name))
+		}
+		if shimsOpts.os != "" {
+			opts = append(opts, client.ShimsForOS(shimsOpts.os))
+		}
+		n, err := shed.GenerateShims(dir, opts...)
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to create shims")
+		}
+		if dir == "" {
+			dir = shed.ShimsDir()
+		}
+		logger.Infof("Created %d shims in %s", n, dir)
+	},
+}
+
+type shimsOptions struct {
+	dir  string
+	shed string
+	name string
+	os   string
+}
+
+var shimsOpts shimsOptions
+
+func init() {
+	shimsCmd.Flags().StringVar(&shimsOpts.dir, "dir", "", "directory to create the shims in instead of .shed/bin")
+	shimsCmd.Flags().StringVar(&shimsOpts.shed, "shed", "", "path to the shed executable that shims run, by default the one running")
+	shimsCmd.Flags().StringVar(&shimsOpts.name, "name", "", "template used to name the shims, ex: '{{.Name}}-{{.MajorVersion}}'")
+	shimsCmd.Flags().StringVar(&shimsOpts.os, "os", "", "OS to create the shims for, using the values of GOOS, by default the one running")
+	rootCmd.AddCommand(shimsCmd)
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/spf13/cobra"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Analyze the run history of tools.",
+	Long: `shed stats analyzes the stats recorded in the run history by 'shed run --stats'
+and 'shed task --stats', and in the install history by 'shed install --stats'.
+
+'shed stats compare' can be used to check if a tool got slower after its version was changed.
+'shed stats cache' can be used to check how often installed tools came from the cache.`,
+}
+
+var statsCompareCmd = &cobra.Command{
+	Use:   "compare",
+	Args:  cobra.NoArgs,
+	Short: "Compare the runtime of a tool with its previous version.",
+	Long: `shed stats compare compares the successful runs of a tool at the version in shed.lock
+with the runs of the version that was used before it. If the median wall time increased by
+more than the threshold, the regression is reported and shed exits with a non-zero status.
+
+For example, to check if golangci-lint is more than 20% slower using the last 30 days of runs:
+
+	shed stats compare --tool=golangci-lint --window=30d --threshold=20`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if statsOpts.tool == "" {
+			fatal.Exitf("--tool must be provided")
+		}
+		window, err := parseWindow(statsOpts.window)
+		if err != nil {
+			fatal.ExitErrf(err, "Invalid window %q", statsOpts.window)
+		}
+		logger := newLogger()
+		setwd(logger)
+		shed := mustShed(client.WithLogger(logger), client.WithNoCache())
+		c, err := shed.CompareRunStats(statsOpts.tool, client.CompareOptions{
+			Window:    window,
+			Threshold: statsOpts.threshold / 100,
+			Task:      statsOpts.task,
+		})
+		if errors.Is(err, lockfile.ErrNotFound) {
+			exitToolName(err, "No tool named %s installed.", statsOpts.tool)
+		} else if errors.Is(err, lockfile.ErrMultipleTools) {
+			exitToolName(err, "Multiple tools named %s found. Specify the full import path of the tool.", statsOpts.tool)
+		} else if err != nil {
+			fatal.ExitErrf(err, "Failed to compare stats for %s", statsOpts.tool)
+		}
+
+		fmt.Printf("%s (last %s)\n", c.Tool.ImportPath, statsOpts.window)
+		for _, vs := range []*client.VersionStats{c.Previous, c.Current} {
+			if vs == nil {
+				continue
+			}
+			fmt.Printf("  %s: %d runs, median wall time %s, median CPU time %s\n",
+				vs.Version, vs.Runs, vs.MedianDuration.Round(time.Millisecond), vs.MedianCPUTime.Round(time.Millisecond))
+		}
+		switch {
+		case c.Current == nil:
+			fmt.Printf("No successful runs of %s recorded. Use --stats with 'shed run' or 'shed task' to record runs.\n", c.Tool)
+		case c.Previous == nil:
+			fmt.Printf("No runs of a previous version recorded.\n")
+		case c.Regressed:
+			fmt.Printf("Wall time regressed by %.1f%% (threshold %g%%)\n", c.Change*100, c.Threshold*100)
+			os.Exit(1)
+		default:
+			fmt.Printf("Wall time changed by %+.1f%% (threshold %g%%)\n", c.Change*100, c.Threshold*100)
+		}
+	},
+}
+
+var statsCacheCmd = &cobra.Command{
+	Use:   "cache",
+	Args:  cobra.NoArgs,
+	Short: "Show how often installed tools came from the cache.",
+	Long: `shed stats cache aggregates the install history recorded by 'shed install --stats' and prints how many
+tools were reused from the shed cache, pulled from the remote cache, built from source, or downloaded from a
+release, along with how long they took. This helps check that caching works, ex: in CI, where most tools
+should come from the cache or the remote cache.
+
+The hit rate is the percentage of tools that came from the cache or the remote cache. The remote hit rate
+is the percentage of tools that weren't in the cache and were pulled from the remote cache instead of being built.
+
+For example, to see the hit rate of the last 7 days of installs:
+
+	shed stats cache --window=7d`,
+	Run: func(cmd *cobra.Command, args []string) {
+		window, err := parseWindow(statsCacheOpts.window)
+		if err != nil {
+			fatal.ExitErrf(err, "Invalid window %q", statsCacheOpts.window)
+		}
+		logger := newLogger()
+		setwd(logger)
+		shed := mustShed(client.WithLogger(logger), client.WithNoCache())
+		stats, err := shed.CacheStats(window)
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to read install history")
+		}
+		if stats.Tools == 0 {
+			fmt.Printf("No installs recorded in the last %s. Use 'shed install --stats' to record installs.\n", statsCacheOpts.window)
+			return
+		}
+		fmt.Printf("%d tools installed by %d installs (last %s)\n", stats.Tools, stats.Installs, statsCacheOpts.window)
+		for _, ss := range stats.Sources {
+			fmt.Printf("  %s: %d tools (%.1f%%), median time %s, total time %s\n",
+				ss.Source, ss.Tools, ss.Rate*100, ss.MedianDuration.Round(time.Millisecond), ss.TotalDuration.Round(time.Millisecond))
+		}
+		fmt.Printf("Hit rate %.1f%%, remote hit rate %.1f%%\n", stats.HitRate*100, stats.RemoteHitRate*100)
+	},
+}
+
+// parseWindow parses a duration that can also be given in days, ex: '30d'.
+func parseWindow(s string) (time.Duration, error) {
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("must be a positive number of days")
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("must be positive")
+	}
+	return d, nil
+}
+
+type statsOptions struct {
+	tool      string
+	task      string
+	window    string
+	threshold float64
+}
+
+var statsOpts statsOptions
+
+var statsCacheOpts struct {
+	window string
+}
+
+func init() {
+	statsCompareCmd.Flags().StringVar(&statsOpts.tool, "tool", "", "name of the tool to compare")
+	statsCompareCmd.Flags().StringVar(&statsOpts.task, "task", "", "only compare runs of the given task")
+	statsCompareCmd.Flags().StringVar(&statsOpts.window, "window", "30d", "how far back to look in the run history, ex: 30d or 12h")
+	statsCompareCmd.Flags().Float64Var(&statsOpts.threshold, "threshold", 20, "percentage increase in the median wall time that is considered a regression")
+	statsCmd.AddCommand(statsCompareCmd)
+	statsCacheCmd.Flags().StringVar(&statsCacheOpts.window, "window", "30d", "how far back to look in the install history, ex: 30d or 12h")
+	statsCmd.AddCommand(statsCacheCmd)
+	rootCmd.AddCommand(statsCmd)
+}
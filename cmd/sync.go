@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/spf13/cobra"
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Args:  cobra.NoArgs,
+	Short: "Install the tools in shed.lock that are missing.",
+	Long: `shed sync installs the tools in shed.lock that aren't installed, ex: after checking out a branch
+that changed the version of a tool. Unlike 'shed install', shed.lock is never modified, and nothing is printed
+if every tool is already installed.
+
+Checking whether the tools are installed only looks at the shed cache, so shed sync is fast enough to run
+on every make invocation, ex:
+
+	tools:
+		@shed sync
+
+Use 'shed hooks install' to run shed sync from git hooks after switching branches or pulling.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		setwd(logger)
+		shed := mustShed(client.WithLogger(logger))
+		installed, err := shed.Sync(context.Background(), client.WithProgress(func(ev client.Event) {
+			if ev.Kind == client.EventDone {
+				logger.Debugf("Installed %s", ev.Tool)
+			}
+		}))
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to install missing tools")
+		}
+		if len(installed) > 0 {
+			logger.Infof("Installed %d missing tools", len(installed))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/config"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var taskCmd = &cobra.Command{
+	Use:   "task <task> [args...]",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Run tasks defined in shed.config.json.",
+	Long: `shed task runs a task defined in the shed.config.json file.
+
+A task is a named invocation of an installed tool with a predefined set of arguments.
+Any arguments after the task name are appended to the task's arguments.
+
+Tasks can depend on other tasks using dependsOn. Dependencies are always run before the
+tasks that depend on them, and tasks that don't depend on each other are run in parallel.
+A task with no tool can be used to group other tasks.
+
+For example, given the following shed.config.json:
+
+	{
+	  "tasks": {
+	    "protoc": {
+	      "tool": "buf",
+	      "args": ["generate"]
+	    },
+	    "fmt": {
+	      "tool": "goimports",
+	      "args": ["-w", "."],
+	      "dependsOn": ["protoc"]
+	    },
+	    "lint": {
+	      "tool": "golangci-lint",
+	      "args": ["run", "./..."],
+	      "dependsOn": ["fmt"],
+	      "retries": 2,
+	      "backoff": "5s"
+	    },
+	    "generate": {
+	      "dependsOn": ["lint"]
+	    }
+	  }
+	}
+
+All the tasks can be run with:
+
+	shed task generate
+
+Tasks can declare the files they read with inputs and the files they write with outputs.
+If the inputs of a task have not changed since it last succeeded, the task is skipped and its
+outputs are restored from the cache in the .shed directory. Use --force to always run tasks.
+
+	{
+	  "tasks": {
+	    "protoc": {
+	      "tool": "buf",
+	      "args": ["generate"],
+	      "inputs": ["proto/**/*.proto", "buf.gen.yaml"],
+	      "outputs": ["gen/**/*.pb.go"]
+	    }
+	  }
+	}
+
+Use --graph to print the dependency graph of a task in the DOT language instead of running it:
+
+	shed task --graph generate | dot -Tsvg > graph.svg`,
+	Run: func(cmd *cobra.Command, args []string) {
+		taskName := args[0]
+		logger := newLogger()
+		origDir := setwd(logger)
+		shed := mustShed(client.WithLogger(logger), trustOption())
+
+		if taskOpts.graph {
+			err := shed.WriteTaskGraph(os.Stdout, taskName)
+			if errors.Is(err, client.ErrTaskNotFound) {
+				fatal.Exitf("No task named %s found in %s.", taskName, config.ProjectFileName)
+			} else if err != nil {
+				fatal.ExitErrf(err, "Failed to write graph for task %s", taskName)
+			}
+			return
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		opts := newRunOptions(ctx, cancel, cmd, origDir)
+		opts.Force = taskOpts.force
+		report, err := shed.RunTask(ctx, taskName, args[1:], opts)
+		if errors.Is(err, client.ErrTaskNotFound) {
+			fatal.Exitf("No task named %s found in %s.", taskName, config.ProjectFileName)
+		}
+		if runOpts.stats && report != nil {
+			for _, res := range report.Results {
+				printStats(res.Report, res.Name)
+			}
+		}
+		exitTask(logger, report, err, taskName)
+	},
+}
+
+type taskOptions struct {
+	graph bool
+	force bool
+}
+
+var taskOpts taskOptions
+
+// exitTask handles the result of running a task. If a task failed, the process will exit
+// with the same exit code as the tool for that task if possible.
+func exitTask(logger *logrus.Logger, report *client.TaskReport, err error, name string) {
+	if report == nil {
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to run task %s", name)
+		}
+		return
+	}
+
+	for _, res := range report.Results {
+		if res.Cached {
+			logger.Infof("Task %s is up to date, restored outputs from cache", res.Name)
+		}
+		logger.WithFields(logrus.Fields{
+			"task":   res.Name,
+			"failed": res.Err != nil,
+		}).Debug("Finished task")
+	}
+	if err == nil {
+		return
+	}
+	failed := report.Failed()
+	if len(failed) == 1 && failed[0].Report != nil {
+		// Delegate to exitRun so the exit code of the tool is used
+		exitRun(logger, failed[0].Report, failed[0].Err, failed[0].Name)
+	}
+	fatal.ExitErrf(err, "Failed to run task %s", name)
+}
+
+func init() {
+	// Stop parsing flags after first non-flag arg
+	// so we can pass them to the task being run
+	taskCmd.Flags().SetInterspersed(false)
+	taskCmd.Flags().BoolVar(&taskOpts.graph, "graph", false, "print the dependency graph of the task in DOT format instead of running it")
+	taskCmd.Flags().BoolVar(&taskOpts.force, "force", false, "run tasks even if their inputs have not changed")
+	addRunFlags(taskCmd)
+	rootCmd.AddCommand(taskCmd)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/spf13/cobra"
+)
+
+var toolchainCmd = &cobra.Command{
+	Use:   "toolchain",
+	Short: "Inspect the version of Go needed by tools.",
+	Long: `shed toolchain contains commands to inspect the version of Go needed to build the tools in shed.lock.
+
+'shed toolchain required' prints the minimum version of Go needed to build all tools.`,
+}
+
+var toolchainRequiredCmd = &cobra.Command{
+	Use:   "required",
+	Args:  cobra.NoArgs,
+	Short: "Print the minimum version of Go needed to build the tools.",
+	Long: `shed toolchain required prints the minimum version of Go needed to build the tools in shed.lock,
+which is the highest go directive of the modules of the tools. Release tools aren't built, so they don't need Go.
+
+The go directives are read from the module cache, so the tools must be installed. Tools that aren't are skipped
+with a warning. Use --verbose (-v) to also print the go directive of each tool.
+
+Use 'shed install --enforce-toolchain', or enforceToolchain in shed.config.json, to refuse to build tools
+that need a newer version of Go than the one installed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		setwd(logger)
+		shed := mustShed(client.WithLogger(logger))
+		tc, err := shed.RequiredToolchain(context.Background())
+		if tc == nil {
+			fatal.ExitErrf(err, "Failed to find the required version of Go")
+		}
+		if err != nil {
+			logger.WithError(err).Warn("Some tools were skipped")
+		}
+		if rootOpts.verbose {
+			for _, tgv := range tc.Tools {
+				fmt.Printf("%s\t%s\n", tgv.GoVersion, tgv.Tool)
+			}
+		}
+		if tc.GoVersion == "" {
+			logger.Info("No tools require a version of Go")
+			return
+		}
+		fmt.Println(tc.GoVersion)
+	},
+}
+
+func init() {
+	toolchainCmd.AddCommand(toolchainRequiredCmd)
+	rootCmd.AddCommand(toolchainCmd)
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/config"
+	"github.com/getshiphub/shed/internal/util"
+	"github.com/getshiphub/shed/tool"
+	"github.com/getshiphub/shed/xdg"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// completionShed creates a shed client for providing completions. Completions must not
+// write anything besides the completions to stdout, so errors are returned instead of
+// exiting and nothing is logged.
+func completionShed() (*client.Shed, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	// Outside of a project, only the global tools can be completed
+	globalPath, globalErr := client.GlobalLockfilePath()
+	lfp := client.ResolveLockfilePath(cwd)
+	if lfp == "" {
+		if globalErr != nil || !util.IsRegularFile(globalPath) {
+			return nil, fmt.Errorf("no lockfile found")
+		}
+		lfp = globalPath
+	}
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	opts := []client.Option{client.WithLockfilePath(lfp), client.WithLogger(logger)}
+	if globalErr == nil {
+		opts = append(opts, client.WithGlobalLockfile(globalPath))
+	}
+	if p, err := config.UserPath(); err == nil {
+		if u, err := config.ReadUser(p); err == nil {
+			// Flags aren't parsed when completing, so the context can only be selected with the environment
+			if c, err := u.ResolveContext(os.Getenv(config.ContextEnvVar)); err == nil {
+				if ctxOpts, err := contextOptions(c); err == nil {
+					opts = append(opts, ctxOpts...)
+				}
+			}
+		}
+	}
+	// Completions can run the tool, but can't prompt, so only trusted modules are run
+	if v, err := strconv.ParseBool(os.Getenv(trustAllEnvVar)); err != nil || !v {
+		stateDir, err := xdg.StateDir()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, client.WithTrust(filepath.Join(stateDir, client.TrustFileName), func(tool.Tool, string) (bool, error) {
+			return false, nil
+		}))
+	}
+	return client.NewShed(opts...)
+}
+
+// completeToolNames completes the name of a single tool, including the tools in the global lockfile,
+// since they can be run. The binary name is used if it is unique, otherwise the full import path is used.
+func completeToolNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	return toolNameCompletions(nil, toComplete, client.IncludeGlobal()), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeLockfileToolNames completes the names of the tools in the lockfile for commands that take
+// any number of them, ex: 'shed uninstall'. Tools that were already given are skipped.
+func completeLockfileToolNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return toolNameCompletions(args, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// toolNameCompletions returns the names of the tools listed with opts that start with toComplete,
+// except for the ones in given. The binary name is used if it is unique, otherwise the full import path is used.
+func toolNameCompletions(given []string, toComplete string, opts ...client.ListOption) []string {
+	shed, err := completionShed()
+	if err != nil {
+		return nil
+	}
+	tools := shed.List(opts...)
+	counts := make(map[string]int)
+	for _, t := range tools {
+		counts[t.Name()]++
+	}
+	skip := make(map[string]bool)
+	for _, name := range given {
+		skip[name] = true
+	}
+	var names []string
+	for _, t := range tools {
+		name := t.Name()
+		if counts[name] > 1 {
+			name = t.ImportPath
+		}
+		if strings.HasPrefix(name, toComplete) && !skip[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// toolArgsIndex returns the index of the tool name in the arguments passed to 'shed run',
+// or -1 if the arguments only contain flags for shed.
+func toolArgsIndex(args []string) int {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			if i+1 < len(args) {
+				return i + 1
+			}
+			return -1
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			return i
+		}
+		if strings.Contains(arg, "=") {
+			continue
+		}
+		// Skip the value of the flag if it is the next argument
+		if runFlagTakesValue(arg) {
+			i++
+		}
+	}
+	return -1
+}
+
+// runFlagTakesValue reports whether the 'shed run' flag arg requires a value.
+func runFlagTakesValue(arg string) bool {
+	flags := []interface {
+		Lookup(name string) *pflag.Flag
+		ShorthandLookup(name string) *pflag.Flag
+	}{runCmd.Flags(), rootCmd.PersistentFlags()}
+	for _, fs := range flags {
+		var f *pflag.Flag
+		if strings.HasPrefix(arg, "--") {
+			f = fs.Lookup(arg[2:])
+		} else if len(arg) == 2 {
+			f = fs.ShorthandLookup(arg[1:])
+		}
+		if f != nil {
+			// Flags with a default value when no option is given, like bools, don't need a value
+			return f.NoOptDefVal == ""
+		}
+	}
+	return false
+}
+
+// completeToolArgs handles a completion request for the arguments of a tool run with 'shed run'.
+// Cobra doesn't know that the arguments after the tool name belong to the tool, so it would
+// complete shed's flags instead. It returns false if args are not a request to complete
+// the arguments of a tool, in which case cobra should handle the request.
+//
+// The completions are written to w in the same format cobra uses, so the completion
+// scripts generated by cobra work with them.
+func completeToolArgs(args []string, w io.Writer) bool {
+	if len(args) < 3 || args[1] != runCmd.Name() {
+		return false
+	}
+	noDesc := args[0] == cobra.ShellCompNoDescRequestCmd
+	if args[0] != cobra.ShellCompRequestCmd && !noDesc {
+		return false
+	}
+	runArgs := args[2 : len(args)-1]
+	toComplete := args[len(args)-1]
+	i := toolArgsIndex(runArgs)
+	if i == -1 {
+		return false
+	}
+
+	var completions []string
+	if shed, err := completionShed(); err == nil {
+		completions, _ = shed.CompleteTool(context.Background(), runArgs[i], runArgs[i+1:], toComplete)
+	}
+	directive := cobra.ShellCompDirectiveDefault
+	if completions != nil {
+		directive = cobra.ShellCompDirectiveNoFileComp
+	}
+	for _, c := range completions {
+		if noDesc {
+			c = strings.SplitN(c, "\t", 2)[0]
+		}
+		fmt.Fprintln(w, c)
+	}
+	fmt.Fprintf(w, ":%d\n", directive)
+	return true
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"os"
 
 	"github.com/getshiphub/shed/client"
@@ -10,12 +11,13 @@ import (
 
 var uninstallCmd = &cobra.Command{
 	Use:   "uninstall <tool> [tools...]",
-	Args:  cobra.MinimumNArgs(1),
+	Args:  cobra.ArbitraryArgs,
 	Short: "Uninstall Go tools.",
 	Long: `shed uninstall removes the given tools from the shed.lock file.
 This does not remove the actual tool binaries. This shed uses a single shared cache
 to install tool binaries and therefore, other projects could be using the same tool.
 If you wish to remove tool binaries see 'shed cache clean'.
+Uninstalled tools can be added back with 'shed restore'.
 
 The tool name can either be the full import path or the binary name if it is unique.
 
@@ -25,19 +27,38 @@ For example to uninstall the 'golang.org/x/tools/cmd/stringer' tool:
 
 Or:
 
-	shed uninstall golang.org/x/tools/cmd/stringer`,
+	shed uninstall golang.org/x/tools/cmd/stringer
+
+Use --group instead of tool names to remove a group from shed.lock. Tools that are only in the group
+are uninstalled, and tools that are also in other groups are removed from the group:
+
+	shed uninstall --group release
+
+Use --global (-g) to remove tools from the global lockfile instead, see 'shed install --global'.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 && len(uninstallOpts.groups) == 0 {
+			fatal.Exitf("No tools provided, provide the tools to uninstall or use --group")
+		}
+		if len(args) > 0 && len(uninstallOpts.groups) > 0 {
+			fatal.Exitf("--group cannot be used with tool names")
+		}
 		s := spinner.NewTTY(spinner.Options{
 			Message:         "Uninstalling tools",
 			PersistMessages: rootOpts.verbose,
+			Plain:           plainOutput,
 		})
 		logger := newLogger()
 		logger.Out = s
-		setwd(logger)
-		shed := mustShed(client.WithLogger(logger))
+		lfOpts := setwdGlobal(logger, uninstallOpts.global)
+		shed := mustShed(append(lfOpts, client.WithLogger(logger), client.WithNoCache())...)
 		s.Start()
 
-		err := shed.Uninstall(args...)
+		var err error
+		if len(uninstallOpts.groups) > 0 {
+			err = shed.UninstallGroups(context.Background(), uninstallOpts.groups...)
+		} else {
+			err = shed.Uninstall(context.Background(), args...)
+		}
 		s.Stop()
 		if err != nil {
 			fatal.ExitErrf(err, "Failed to uninstall tools")
@@ -47,6 +68,16 @@ Or:
 	},
 }
 
+type uninstallOptions struct {
+	groups []string
+	global bool
+}
+
+var uninstallOpts uninstallOptions
+
 func init() {
+	uninstallCmd.Flags().StringSliceVar(&uninstallOpts.groups, "group", nil, "groups to remove instead of tools")
+	uninstallCmd.Flags().BoolVarP(&uninstallOpts.global, "global", "g", false, "remove the tools from the global lockfile instead of shed.lock")
+	uninstallCmd.ValidArgsFunction = completeLockfileToolNames
 	rootCmd.AddCommand(uninstallCmd)
 }
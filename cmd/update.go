@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/vulndb"
+	"github.com/mattn/go-isatty"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var updateCmd = &cobra.Command{
+	Use:     "update [tools...]",
+	Aliases: []string{"upgrade"},
+	Args:    cobra.ArbitraryArgs,
+	Short:   "Update tools to their latest versions.",
+	Long: `shed update installs the latest version of each tool in shed.lock that has a newer version
+and updates shed.lock. Only the tools that changed are rebuilt. If tools are provided, only they are updated.
+Tools can be referred to by name or import path. Build flags and names given with --as are kept.
+'shed upgrade' is an alias.
+
+The latest versions are found the same way as 'shed outdated', so new major versions that have a
+different module path, like /v2, are not found.
+
+Tools that were installed from a branch, ex: 'shed install golang.org/x/tools/cmd/stringer@master', are
+updated to the latest commit of the branch instead of the latest version. The branch is kept in shed.lock.
+
+Use --minor to only update to versions with the same major version, or --patch to only update
+to versions with the same minor version. The versions of each module are listed using GOPROXY.
+
+Use --interactive to review each update before it is made. For each tool the current and latest versions
+are shown along with the start of the release notes of the latest version and the vulnerabilities it fixes.
+Each update can then be accepted or skipped, or the remaining ones can be skipped by quitting.
+Nothing is installed until every update has been reviewed.
+
+Use --security-only to only update tools whose versions have known vulnerabilities. Each tool is updated
+to the smallest newer release that isn't affected by any known vulnerability, instead of the latest version,
+which keeps the change as small as possible. This is intended for scheduled automation. Tools that have no
+release without vulnerabilities are reported and left as is. --min-severity ignores vulnerabilities that are
+less severe. The Go vulnerability database doesn't record severities, so its vulnerabilities are never ignored.
+
+Once the tools are updated, the old and new version of each tool are printed along with a link to the changes
+between them on GitHub. Use --summary-file to also write them to a file as JSON, ex: for a bot to post them on
+the pull request of the update. The file lists no changes if all tools were up to date.
+
+Release notes are fetched from GitHub releases, so they are only shown for modules hosted on GitHub.
+Set GITHUB_TOKEN to avoid the rate limit of the GitHub API. Responses are cached in the shed cache directory
+for an hour. Vulnerabilities are found using the Go vulnerability database, or the database in GOVULNDB
+if it is set.
+
+Examples:
+
+Update all tools:
+
+	shed update
+
+Review the updates of all tools:
+
+	shed update --interactive
+
+Update only some tools:
+
+	shed update golangci-lint stringer
+
+Only make patch updates:
+
+	shed update --patch
+
+Fix vulnerable tools with the smallest updates:
+
+	shed update --security-only`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		setwd(logger)
+		if updateOpts.interactive && !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+			fatal.Exitf("--interactive requires stdin to be a terminal")
+		}
+		if updateOpts.interactive && updateOpts.securityOnly {
+			fatal.Exitf("--interactive can't be used with --security-only")
+		}
+		if updateOpts.patch && updateOpts.minor {
+			fatal.Exitf("--patch can't be used with --minor")
+		}
+		shed := mustShed(client.WithLogger(logger))
+		if updateOpts.securityOnly {
+			selected := make(map[string]bool)
+			for _, name := range args {
+				found := false
+				for _, t := range shed.List() {
+					if t.Name() == name || t.ImportPath == name {
+						selected[t.ImportPath] = true
+						found = true
+					}
+				}
+				if !found {
+					fatal.Exitf("No tool named %s in shed.lock", name)
+				}
+			}
+			updateSecurity(logger, shed, selected)
+			return
+		}
+
+		ctx := context.Background()
+		opts := client.UpgradeOptions{MaxUpdate: client.UpdateMajor, Tools: args}
+		if updateOpts.minor {
+			opts.MaxUpdate = client.UpdateMinor
+		}
+		if updateOpts.patch {
+			opts.MaxUpdate = client.UpdatePatch
+		}
+		if !updateOpts.interactive {
+			installSet, err := shed.Upgrade(ctx, opts)
+			if err != nil {
+				fatal.ExitErrf(err, "Failed to check for newer versions of tools")
+			}
+			if installSet.Len() == 0 {
+				fmt.Println("All tools are up to date.")
+				reportUpdate(nil)
+				return
+			}
+			applyInstall(logger, installSet)
+			reportUpdate(installSet.Summary())
+			return
+		}
+
+		upgrades, err := shed.Upgrades(ctx, opts)
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to check for newer versions of tools")
+		}
+		if len(upgrades) == 0 {
+			fmt.Println("All tools are up to date.")
+			reportUpdate(nil)
+			return
+		}
+		var toolNames []string
+		in := bufio.NewReader(os.Stdin)
+	review:
+		for _, r := range upgrades {
+			details, err := shed.UpdateDetails(ctx, r)
+			if err != nil {
+				logger.WithError(err).Warnf("Failed to get some details of the update of %s", r.Tool.Name())
+			}
+			printUpdate(os.Stderr, r, details)
+			switch promptUpdate(in, r) {
+			case "y":
+				toolNames = append(toolNames, r.Tool.ImportPath+"@"+r.Latest)
+			case "q":
+				break review
+			}
+		}
+		if len(toolNames) == 0 {
+			fmt.Println("No tools were updated.")
+			reportUpdate(nil)
+			return
+		}
+		installSet, err := shed.Install(toolNames...)
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to determine list of tools to install")
+		}
+		applyInstall(logger, installSet)
+		reportUpdate(installSet.Summary())
+	},
+}
+
+// updateSecurity updates the vulnerable tools in shed to the smallest versions that fix them.
+// If selected is not empty, only the tools with those import paths are updated.
+func updateSecurity(logger *logrus.Logger, shed *client.Shed, selected map[string]bool) {
+	minSeverity, err := vulndb.ParseSeverity(updateOpts.minSeverity)
+	if err != nil {
+		fatal.ExitErrf(err, "Invalid --min-severity")
+	}
+	updates, err := shed.SecurityUpdates(context.Background(), minSeverity)
+	if err != nil {
+		fatal.ExitErrf(err, "Failed to check tools for vulnerabilities")
+	}
+	var toolNames []string
+	for _, u := range updates {
+		if len(selected) > 0 && !selected[u.Tool.ImportPath] {
+			continue
+		}
+		var ids []string
+		for _, e := range u.Fixes {
+			ids = append(ids, e.ID)
+		}
+		if u.Version == "" {
+			logger.Warnf("%s is affected by %s, but there is no newer version that fixes it", u.Tool, strings.Join(ids, ", "))
+			continue
+		}
+		logger.Infof("Updating %s to %s to fix %s", u.Tool, u.Version, strings.Join(ids, ", "))
+		toolNames = append(toolNames, u.Tool.ImportPath+"@"+u.Version)
+	}
+	if len(toolNames) == 0 {
+		fmt.Println("No tools need security updates.")
+		reportUpdate(nil)
+		return
+	}
+	installSet, err := shed.Install(toolNames...)
+	if err != nil {
+		fatal.ExitErrf(err, "Failed to determine list of tools to install")
+	}
+	applyInstall(logger, installSet)
+	reportUpdate(installSet.Summary())
+}
+
+// reportUpdate prints the tools whose versions were changed by the update, and writes them to --summary-file if set.
+func reportUpdate(summary *client.InstallSummary) {
+	printInstallSummary(os.Stderr, summary)
+	if updateOpts.summaryFile != "" {
+		writeInstallSummary(updateOpts.summaryFile, summary)
+	}
+}
+
+// printUpdate prints the update r of a tool along with its details for reviewing it.
+func printUpdate(out io.Writer, r client.OutdatedTool, details client.UpdateDetails) {
+	fmt.Fprintf(out, "\n%s %s -> %s (%s)\n", r.Tool.ImportPath, r.Tool.Version, r.Latest, r.Update)
+	if details.ReleaseURL != "" {
+		fmt.Fprintf(out, "  Release notes: %s\n", details.ReleaseURL)
+		for _, l := range strings.Split(details.ReleaseNotes, "\n") {
+			if l != "" {
+				fmt.Fprintf(out, "    %s\n", l)
+			}
+		}
+	}
+	if len(details.Fixes) > 0 {
+		fmt.Fprintln(out, "  Vulnerabilities fixed:")
+		for _, e := range details.Fixes {
+			id := e.ID
+			if len(e.Aliases) > 0 {
+				id += " (" + strings.Join(e.Aliases, ", ") + ")"
+			}
+			fmt.Fprintf(out, "    %s: %s\n", id, e.Summary)
+		}
+	}
+}
+
+// promptUpdate asks the user whether to update the tool in r. It returns "y" to update it,
+// "n" to skip it, or "q" to skip it and all remaining updates.
+func promptUpdate(in *bufio.Reader, r client.OutdatedTool) string {
+	for {
+		fmt.Fprintf(os.Stderr, translate("Update %s? [y/N/q] "), r.Tool.Name())
+		answer, err := in.ReadString('\n')
+		if err != nil && answer == "" {
+			// No more input, so skip everything else
+			return "q"
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return "y"
+		case "", "n", "no":
+			return "n"
+		case "q", "quit":
+			return "q"
+		}
+	}
+}
+
+type updateOptions struct {
+	interactive  bool
+	patch        bool
+	minor        bool
+	securityOnly bool
+	minSeverity  string
+	summaryFile  string
+}
+
+var updateOpts updateOptions
+
+func init() {
+	updateCmd.Flags().BoolVarP(&updateOpts.interactive, "interactive", "i", false, "review each update before installing it")
+	updateCmd.Flags().BoolVar(&updateOpts.patch, "patch", false, "only update to versions with the same minor version")
+	updateCmd.Flags().BoolVar(&updateOpts.minor, "minor", false, "only update to versions with the same major version")
+	updateCmd.Flags().BoolVar(&updateOpts.securityOnly, "security-only", false, "only update tools with known vulnerabilities, to the smallest version that fixes them")
+	updateCmd.Flags().StringVar(&updateOpts.minSeverity, "min-severity", "low", "ignore vulnerabilities less severe than this: low, moderate, high, or critical")
+	updateCmd.Flags().StringVar(&updateOpts.summaryFile, "summary-file", "", "file to write the tools whose versions changed to as JSON, with links to their release notes")
+	updateCmd.ValidArgsFunction = completeLockfileToolNames
+	rootCmd.AddCommand(updateCmd)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/spf13/cobra"
+)
+
+// offlineEnvVar is the environment variable that makes shed install tools only from the vendor directory
+// when set to a true value, like --offline.
+const offlineEnvVar = "SHED_OFFLINE"
+
+var vendorCmd = &cobra.Command{
+	Use:   "vendor",
+	Args:  cobra.NoArgs,
+	Short: "Vendor what is needed to install the tools in shed.lock offline.",
+	Long: `shed vendor adds everything needed to install the tools in shed.lock without network access to
+the shed-vendor directory next to shed.lock, so they can be installed in air-gapped build environments.
+The tools are installed first if needed, without changing shed.lock.
+
+shed-vendor contains the modules used to build each tool, in the layout of a module proxy, and the release
+assets of release tools for the current platform. Run 'shed vendor' again after changing shed.lock to
+update it. The directory is replaced, so modules that are no longer needed are removed.
+
+Use --offline, or set SHED_OFFLINE=1, to install tools only from shed-vendor. Installing fails right away
+if a tool needs anything that isn't vendored. Tools must have exact versions in shed.lock, and the remote
+cache and prebuilt binaries aren't used.
+
+Examples:
+
+	shed vendor
+	shed install --offline`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		setwd(logger)
+		shed := mustShed(client.WithLogger(logger))
+		installSet, err := shed.Install()
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to install tools")
+		}
+		applyInstall(logger, installSet, client.Frozen())
+
+		n, err := shed.Vendor(context.Background())
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to vendor tools")
+		}
+		logger.Infof("Vendored %d tools in %s", n, shed.VendorDir())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(vendorCmd)
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/config"
+	"github.com/getshiphub/shed/policy"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Args:  cobra.NoArgs,
+	Short: "Check that installed tools have not been modified.",
+	Long: `shed verify checks that the installed files of each tool in shed.lock match their hashes.
+The source of the module that provides each tool is compared against the hash in shed.lock,
+and each binary is compared against the hash recorded when it was built.
+
+Each tool that doesn't match is printed and shed exits with a non-zero status. A mismatch means
+the cache was modified after the tool was installed, or the module was served with different
+contents than when it was added to shed.lock. Run 'shed cache clean' and 'shed install' to
+reinstall the tools if the change is expected.
+
+Tools with a versionProbe in shed.config.json are run with --version, or the args of the probe,
+when they are installed. shed verify also checks that the version each of them reported matches
+the version in shed.lock, which catches tools whose builds embed the wrong version.
+
+Use --rebuild to also prove that the tools built with 'shed install --reproducible' are reproducible.
+Each of them is built again from scratch and its binary is compared against the hash in shed.lock.
+A tool whose binary doesn't match is printed, and its binary is removed from the cache. Other tools
+are not rebuilt.
+
+Use --server to instead run an HTTP server that checks lockfiles sent to it against a policy,
+so that checks across many repositories can call a central service instead of running shed
+in each one. Send a lockfile as the body of a POST request and the server responds with JSON:
+
+	{"ok": false, "violations": [{"tool": "example.org/tool@v1.0.0", "reason": "source is not allowed"}]}
+
+The status is 200 if the lockfile was checked and 400 if it is invalid, in which case errors
+lists the problems. The server never installs or modifies anything.
+
+The policy is a JSON file given by --policy, or the policy of the selected context in the user config.
+Without one, lockfiles only need to be valid. If there is a policy, shed verify without --server also
+checks shed.lock against it.
+
+	{
+	  "allowedSources": ["github.com/acme/*", "golang.org/x/*"],
+	  "requireSums": true
+	}
+
+	allowedSources  glob patterns of import path prefixes that tools must match, like GOPRIVATE
+	requireSums     require every tool to have a module hash in shed.lock
+
+Examples:
+
+Run a verification server:
+
+	shed verify --server :8080 --policy policy.json
+
+Check a lockfile with the server:
+
+	curl --data-binary @shed.lock http://localhost:8080`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		if verifyOpts.server != "" {
+			serveVerify(logger)
+			return
+		}
+		setwd(logger)
+		shed := mustShed(client.WithLogger(logger))
+		if p := policyPath(); p != "" {
+			violations := shed.CheckPolicy(mustPolicy(p))
+			for _, v := range violations {
+				fmt.Printf("%s: %s\n", v.Tool, v.Reason)
+			}
+			if len(violations) > 0 {
+				fatal.Exitf("%d tools do not follow the policy %s", len(violations), p)
+			}
+		}
+		mismatches, err := shed.Verify()
+		for _, m := range mismatches {
+			fmt.Println(m.Err)
+		}
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to verify tools")
+		}
+		if len(mismatches) > 0 {
+			fatal.Exitf("%d tools do not match their hashes", len(mismatches))
+		}
+		versionMismatches, err := shed.VerifyReportedVersions()
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to verify tools")
+		}
+		for _, m := range versionMismatches {
+			fmt.Printf("%s: reported version %q\n", m.Tool, m.Reported)
+		}
+		if len(versionMismatches) > 0 {
+			fatal.Exitf("%d tools do not report their version in shed.lock", len(versionMismatches))
+		}
+		if verifyOpts.rebuild {
+			mismatches, err := shed.VerifyRebuild(context.Background())
+			for _, m := range mismatches {
+				fmt.Println(m.Err)
+			}
+			if err != nil {
+				fatal.ExitErrf(err, "Failed to rebuild tools")
+			}
+			if len(mismatches) > 0 {
+				fatal.Exitf("%d tools are not reproducible", len(mismatches))
+			}
+		}
+		logger.Info("All tools verified")
+	},
+}
+
+// serveVerify runs the verification server until it is interrupted.
+func serveVerify(logger *logrus.Logger) {
+	p := &policy.Policy{}
+	if path := policyPath(); path != "" {
+		p = mustPolicy(path)
+	}
+
+	handler := policy.Handler(p)
+	srv := &http.Server{
+		Addr: verifyOpts.server,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger.Debugf("%s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+			handler.ServeHTTP(w, r)
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// Listen for SIGINT to do a graceful shutdown
+	abort := make(chan os.Signal, 1)
+	signal.Notify(abort, os.Interrupt)
+	go func() {
+		<-abort
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx) //nolint:errcheck
+	}()
+
+	logger.Infof("Verification server listening on %s", verifyOpts.server)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		fatal.ExitErrf(err, "Verification server failed")
+	}
+}
+
+// policyPath returns the path to the policy file given by --policy, or the policy of the selected context.
+// It returns an empty string if there is no policy.
+func policyPath() string {
+	if verifyOpts.policy != "" {
+		return verifyOpts.policy
+	}
+	p, err := userPolicyPath(userContext)
+	if err != nil {
+		fatal.ExitErrf(err, "Failed to find user config")
+	}
+	return p
+}
+
+// userPolicyPath returns the path to the policy file of the context c, or an empty string if there is none.
+func userPolicyPath(c config.UserContext) (string, error) {
+	p := c.Policy
+	if p == "" || filepath.IsAbs(p) {
+		return p, nil
+	}
+	// Relative paths in the user config are relative to the config file
+	userConfigPath, err := config.UserPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(userConfigPath), p), nil
+}
+
+func mustPolicy(path string) *policy.Policy {
+	p, err := readPolicy(path)
+	if err != nil {
+		fatal.ExitErrf(err, "Failed to read policy file %s", path)
+	}
+	return p
+}
+
+// readPolicy reads the policy file at path.
+func readPolicy(path string) (*policy.Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open policy file")
+	}
+	defer f.Close()
+	return policy.Parse(f)
+}
+
+type verifyOptions struct {
+	server  string
+	policy  string
+	rebuild bool
+}
+
+var verifyOpts verifyOptions
+
+func init() {
+	verifyCmd.Flags().StringVar(&verifyOpts.server, "server", "", "run a server at the given address that checks lockfiles against a policy")
+	verifyCmd.Flags().StringVar(&verifyOpts.policy, "policy", "", "path to the policy file that lockfiles are checked against")
+	verifyCmd.Flags().BoolVar(&verifyOpts.rebuild, "rebuild", false, "rebuild the tools built reproducibly and check that their binaries match shed.lock")
+	rootCmd.AddCommand(verifyCmd)
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/spf13/cobra"
+)
+
+var versionsCmd = &cobra.Command{
+	Use:   "versions <tool>",
+	Args:  cobra.ExactArgs(1),
+	Short: "List the published versions of a tool.",
+	Long: `shed versions lists the published versions of the module that provides a tool, from oldest to newest,
+ex: to choose which version to install. Nothing is installed and shed.lock is not modified.
+
+The tool can either be a tool in shed.lock, by its name or import path, or the import path of any tool.
+The version in shed.lock is marked with '(installed)'. Versions are listed using the module proxies in GOPROXY,
+or the ones in the install settings of shed.config.json. Pseudo-versions are never listed, and pre-release
+versions are only listed with --prerelease.
+
+Use --format=json to print the versions as a JSON array for scripts.
+
+For example, to list the versions of golangci-lint and install one of them:
+
+	shed versions github.com/golangci/golangci-lint/cmd/golangci-lint
+	shed install github.com/golangci/golangci-lint/cmd/golangci-lint@v1.55.2`,
+	ValidArgsFunction: completeToolNames,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		setwd(logger)
+		shed := mustShed(client.WithLogger(logger), client.WithNoCache())
+		var opts []client.VersionsOption
+		if versionsOpts.prerelease {
+			opts = append(opts, client.IncludePrereleases())
+		}
+		t, versions, err := shed.Versions(context.Background(), args[0], opts...)
+		if errors.Is(err, lockfile.ErrNotFound) {
+			exitToolName(err, "No tool named %s in shed.lock. Use the full import path of the tool to list its versions.", args[0])
+		} else if errors.Is(err, lockfile.ErrMultipleTools) {
+			exitToolName(err, "Multiple tools named %s found. Specify the full import path of the tool.", args[0])
+		} else if errors.Is(err, client.ErrReleaseTool) {
+			fatal.Exitf("%s is a release tool, so it has no module versions to list.", args[0])
+		} else if err != nil {
+			fatal.ExitErrf(err, "Failed to list versions of %s", args[0])
+		}
+
+		switch versionsOpts.format {
+		case "text":
+			if len(versions) == 0 {
+				fmt.Printf("No versions of %s found.\n", t.ImportPath)
+				return
+			}
+			for _, v := range versions {
+				if v == t.Version {
+					fmt.Printf("%s (installed)\n", v)
+					continue
+				}
+				fmt.Println(v)
+			}
+		case "json":
+			if versions == nil {
+				versions = []string{}
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(versions); err != nil {
+				fatal.ExitErrf(err, "Failed to write versions as JSON")
+			}
+		default:
+			fatal.Exitf("Invalid format %q, must be one of: text, json", versionsOpts.format)
+		}
+	},
+}
+
+type versionsOptions struct {
+	prerelease bool
+	format     string
+}
+
+var versionsOpts versionsOptions
+
+func init() {
+	versionsCmd.Flags().BoolVar(&versionsOpts.prerelease, "prerelease", false, "include pre-release versions")
+	versionsCmd.Flags().StringVar(&versionsOpts.format, "format", "text", "format to print the versions in, text or json")
+	rootCmd.AddCommand(versionsCmd)
+}
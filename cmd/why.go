@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/spf13/cobra"
+)
+
+var whyCmd = &cobra.Command{
+	Use:   "why <tool>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Show why a tool is in shed.lock.",
+	Long: `shed why shows the note saved for a tool with 'shed install --why', the groups it belongs to,
+and the files in the project that use it, ex: Makefiles, scripts, and go:generate directives.
+This helps find out whether a tool is still needed and who to ask about it.
+
+The tool name can either be the full import path or the binary name if it is unique.
+
+For example, to save a note for golangci-lint and show it:
+
+	shed install golangci-lint --why "lints the project in CI, owned by the platform team"
+	shed why golangci-lint`,
+	ValidArgsFunction: completeLockfileToolNames,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		setwd(logger)
+		shed := mustShed(client.WithLogger(logger), client.WithNoCache())
+		info, err := shed.Why(args[0])
+		if errors.Is(err, lockfile.ErrNotFound) {
+			exitToolName(err, "No tool named %s in shed.lock", args[0])
+		} else if errors.Is(err, lockfile.ErrMultipleTools) {
+			exitToolName(err, "Multiple tools named %s found. Specify the full import path of the tool.", args[0])
+		} else if err != nil {
+			fatal.ExitErrf(err, "Failed to find why %s is used", args[0])
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Tool:\t%s\n", info.Tool)
+		why := info.Tool.Why
+		if why == "" {
+			why = fmt.Sprintf("(none, add one with 'shed install %s --why')", info.Tool.Name())
+		}
+		fmt.Fprintf(w, "Why:\t%s\n", why)
+		if groups := info.Tool.GroupList(); len(groups) > 0 {
+			fmt.Fprintf(w, "Groups:\t%s\n", strings.Join(groups, ", "))
+		}
+		w.Flush()
+		if len(info.Sources) == 0 {
+			fmt.Println("\nNo files in the project use it.")
+			return
+		}
+		fmt.Println("\nUsed by:")
+		for _, src := range info.Sources {
+			fmt.Printf("  %s\n", src)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(whyCmd)
+}
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/config"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var workspaceCmd = &cobra.Command{
+	Use:   "workspace",
+	Short: "Manage the projects of a workspace.",
+	Long: `shed workspace contains commands to manage a workspace, which groups projects that each have their
+own shed.lock, ex: the services of a monorepo. The workspace is defined by a shed.work.json file in the root
+directory, which is found by searching the current directory and its parents.
+
+	{
+	  "projects": ["tools", "services/*"],
+	  "overrides": {"services/legacy": ["golangci-lint"]}
+	}
+
+	projects   directories of the projects, relative to shed.work.json. Patterns only match directories
+	           that have a shed.lock
+	overrides  tools that a project pins at its own version on purpose, by directory of the project
+
+Every project in the workspace uses the cache of the workspace root.`,
+}
+
+var workspaceListCmd = &cobra.Command{
+	Use:   "list",
+	Args:  cobra.NoArgs,
+	Short: "List the projects in the workspace.",
+	Long: `shed workspace list prints the directory of each project in the workspace,
+relative to the workspace root, followed by the number of tools in its shed.lock.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		ws := mustWorkspace(logger, client.WithNoCache())
+		for _, proj := range ws.Projects() {
+			fmt.Printf("%s\t%d tools\n", proj.Dir, len(proj.Shed.List()))
+		}
+	},
+}
+
+var workspaceInstallCmd = &cobra.Command{
+	Use:   "install",
+	Args:  cobra.NoArgs,
+	Short: "Install the tools of every project in the workspace.",
+	Long: `shed workspace install installs the tools in the shed.lock of every project in the workspace,
+like running 'shed install' in each of them. Each project uses the versions in its own shed.lock, and
+tools used by many projects at the same version are only installed once.
+
+Use --frozen to install the tools exactly as they are in each shed.lock, ex: in CI.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		ws := mustWorkspace(logger)
+
+		// Listen of SIGINT to do a graceful abort
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		abort := make(chan os.Signal, 1)
+		signal.Notify(abort, os.Interrupt)
+		go func() {
+			<-abort
+			cancel()
+		}()
+
+		results, err := ws.Install(ctx, client.InstallProjectsOptions{
+			Frozen: workspaceOpts.frozen,
+			Progress: func(ev client.Event) {
+				logger.Debugf("%s %s", ev.Kind, ev.Tool)
+			},
+		})
+		if errors.Is(err, context.Canceled) {
+			logger.Info("Install aborted")
+			return
+		}
+		for _, r := range results {
+			dir := filepath.Dir(r.LockfilePath)
+			if rel, err := filepath.Rel(ws.Root(), dir); err == nil {
+				dir = filepath.ToSlash(rel)
+			}
+			if r.Err != nil {
+				logger.WithError(r.Err).Errorf("Failed to install tools for %s", dir)
+				continue
+			}
+			logger.Infof("Installed tools for %s", dir)
+		}
+		if err != nil {
+			fatal.ExitErrf(err, "Failed to install tools")
+		}
+	},
+}
+
+var workspaceReconcileCmd = &cobra.Command{
+	Use:   "reconcile",
+	Args:  cobra.NoArgs,
+	Short: "Use the same version of each tool in every project.",
+	Long: `shed workspace reconcile finds the tools that the projects of the workspace use at different versions,
+and changes the shed.lock of each project to use the highest of them. Projects that override the version of
+a tool in shed.work.json keep their version and aren't compared with the other projects.
+
+Only the lockfiles are changed. Run 'shed workspace install' afterwards to install the new versions.
+
+Use --dry-run to print the tools that differ without changing anything, ex: to check for drift in CI,
+in which case shed exits with a non-zero status if any tool differs.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger := newLogger()
+		ws := mustWorkspace(logger, client.WithNoCache())
+		var drifts []client.ToolDrift
+		if workspaceOpts.dryRun {
+			drifts = ws.Drift()
+		} else {
+			var err error
+			drifts, err = ws.Reconcile(context.Background())
+			if err != nil {
+				fatal.ExitErrf(err, "Failed to reconcile workspace")
+			}
+		}
+		for _, d := range drifts {
+			fmt.Printf("%s -> %s\n", d.ImportPath, d.Target)
+			dirs := make([]string, 0, len(d.Versions))
+			for dir := range d.Versions {
+				dirs = append(dirs, dir)
+			}
+			sort.Strings(dirs)
+			for _, dir := range dirs {
+				line := fmt.Sprintf("  %s\t%s", dir, d.Versions[dir])
+				for _, o := range d.Overridden {
+					if o == dir {
+						line += "\t(overridden)"
+					}
+				}
+				fmt.Println(line)
+			}
+		}
+		if len(drifts) == 0 {
+			logger.Info("All projects use the same version of each tool")
+			return
+		}
+		if workspaceOpts.dryRun {
+			fatal.Exitf("%d tools have different versions across projects", len(drifts))
+		}
+		logger.Infof("Reconciled %d tools, run 'shed workspace install' to install them", len(drifts))
+	},
+}
+
+// mustWorkspace opens the workspace containing the current directory, exiting if there is none.
+func mustWorkspace(logger *logrus.Logger, opts ...client.Option) *client.Workspace {
+	cwd, err := os.Getwd()
+	if err != nil {
+		fatal.ExitErrf(err, "Failed to get current working directory")
+	}
+	p := client.ResolveWorkspacePath(cwd)
+	if p == "" {
+		fatal.Exitf("No %s found in %s or any parent directory", config.WorkspaceFileName, cwd)
+	}
+	logger.Debugf("Found workspace: %s", p)
+	ws, err := client.OpenWorkspace(p, mustShedOptions(append([]client.Option{client.WithLogger(logger)}, opts...)...)...)
+	if err != nil {
+		fatal.ExitErrf(err, "Failed to open workspace")
+	}
+	return ws
+}
+
+type workspaceOptions struct {
+	frozen bool
+	dryRun bool
+}
+
+var workspaceOpts workspaceOptions
+
+func init() {
+	workspaceInstallCmd.Flags().BoolVar(&workspaceOpts.frozen, "frozen", false, "fail instead of changing the shed.lock of any project")
+	workspaceReconcileCmd.Flags().BoolVar(&workspaceOpts.dryRun, "dry-run", false, "print the tools with different versions without changing anything")
+	workspaceCmd.AddCommand(workspaceListCmd, workspaceInstallCmd, workspaceReconcileCmd)
+	rootCmd.AddCommand(workspaceCmd)
+}
@@ -0,0 +1,681 @@
+// Package config provides support for reading shed configuration files.
+//
+// A project config file lives alongside the shed lockfile and allows for
+// customizing how shed runs tools as well as defining tasks. Unlike the lockfile,
+// the config file is written by hand and is only modified by shed when explicitly
+// requested, such as by 'shed fix-names'.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/getshiphub/shed/resolver"
+	"github.com/getshiphub/shed/tool"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// ProjectFileName is the name of the project config file.
+const ProjectFileName = "shed.config.json"
+
+// Duration is a time.Duration that is represented as a string in JSON
+// using the format accepted by time.ParseDuration (ex: '5s', '1m30s').
+type Duration time.Duration
+
+// MarshalJSON implements the json.Marshaler interface.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("config: duration must be a string: %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("config: invalid duration %q: %w", s, err)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// RetryPolicy controls how many times a tool is retried if it fails.
+type RetryPolicy struct {
+	// Retries is the number of times to retry after the first failed attempt.
+	// A value of 0 means the tool will only be run once.
+	Retries int `json:"retries,omitempty"`
+	// Backoff is the amount of time to wait between attempts.
+	Backoff Duration `json:"backoff,omitempty"`
+}
+
+// Limits controls how long a tool is allowed to run.
+type Limits struct {
+	// Timeout is the maximum amount of time a single attempt is allowed to run for.
+	// Once the timeout is reached, the tool is sent SIGTERM. A value of 0 means no timeout.
+	Timeout Duration `json:"timeout,omitempty"`
+	// KillAfter is the amount of time to wait after sending SIGTERM before
+	// forcefully killing the tool with SIGKILL.
+	KillAfter Duration `json:"killAfter,omitempty"`
+}
+
+// Install contains settings for the go command used when installing tools. They are only
+// set for the go command that installs the tool and do not affect the user's environment.
+type Install struct {
+	// GoInsecure is a list of glob patterns of module paths that can be fetched insecurely.
+	// It is added to GOINSECURE. This is useful for hosts with self-signed certificates.
+	GoInsecure []string `json:"goinsecure,omitempty"`
+	// GoVCS controls which version control systems may be used to fetch modules,
+	// using the same syntax as GOVCS. It takes precedence over GOVCS.
+	GoVCS string `json:"govcs,omitempty"`
+	// GitConfig contains git config options, like 'http.sslCAInfo', that are set for
+	// the git commands run by the go command. This requires git 2.31 or newer.
+	GitConfig map[string]string `json:"gitConfig,omitempty"`
+	// Credentials controls which credentials are available to the go and git commands.
+	// If nil, all credentials in the environment are available.
+	Credentials *Credentials `json:"credentials,omitempty"`
+	// GoProxy is the value of GOPROXY used to fetch modules, ex: an internal proxy for private tools.
+	// It takes precedence over GOPROXY and the goproxy in the user config.
+	GoProxy string `json:"goproxy,omitempty"`
+	// Proxies sets GOPROXY for the tools with matching import paths. The first matching proxy is used.
+	// It can only be set for the project, and the goproxy of a tool takes precedence over it.
+	Proxies []Proxy `json:"proxies,omitempty"`
+	// GoPrivate is a list of glob patterns of module paths that are private. It is added to GOPRIVATE,
+	// so matching modules are fetched directly instead of through a proxy, and aren't checked with the checksum database.
+	GoPrivate []string `json:"goprivate,omitempty"`
+	// GoNoSumDB is a list of glob patterns of module paths that aren't checked with the checksum database.
+	// It is added to GONOSUMDB. This is needed for private modules fetched through a proxy.
+	GoNoSumDB []string `json:"gonosumdb,omitempty"`
+	// Retry controls how downloads and version lookups are retried when they fail because of a problem
+	// with the network, ex: a dropped connection or a proxy responding with 503. It can only be set for
+	// the project. If nil, shed retries a few times with a short delay.
+	Retry *NetworkRetry `json:"retry,omitempty"`
+}
+
+// NetworkRetry controls how operations that use the network are retried when they fail with a transient error.
+type NetworkRetry struct {
+	// Attempts is the maximum number of times an operation is attempted, including the first.
+	// A value of 1 means operations are never retried.
+	Attempts int `json:"attempts"`
+	// BaseDelay is the amount of time to wait before the second attempt. It doubles after each attempt.
+	BaseDelay Duration `json:"baseDelay,omitempty"`
+	// Jitter is the fraction of each delay that is randomized, between 0 and 1.
+	Jitter float64 `json:"jitter,omitempty"`
+}
+
+// Proxy sets the module proxy used to install the tools matching a pattern.
+type Proxy struct {
+	// Match is a comma separated list of glob patterns of import path prefixes,
+	// with the same syntax as GOPRIVATE, ex: 'git.corp.example.com,github.com/acme/*'.
+	Match string `json:"match"`
+	// GoProxy is the value of GOPROXY used to install the matching tools.
+	GoProxy string `json:"goproxy"`
+}
+
+// Credentials controls which credentials are forwarded to the commands used to install
+// tools. Any credential that is not enabled is hidden from the commands, which makes it
+// explicit what can be used to fetch private modules.
+type Credentials struct {
+	// SSHAgent forwards the SSH agent socket from SSH_AUTH_SOCK.
+	SSHAgent bool `json:"sshAgent,omitempty"`
+	// AskPass forwards the askpass programs from GIT_ASKPASS and SSH_ASKPASS.
+	AskPass bool `json:"askPass,omitempty"`
+	// CredentialHelper forwards the git credential helpers from the git config.
+	CredentialHelper bool `json:"credentialHelper,omitempty"`
+	// Netrc is the path to a netrc file used for HTTPS credentials. A relative path is
+	// relative to the project root. If empty, no netrc file is used.
+	Netrc string `json:"netrc,omitempty"`
+}
+
+// Tool contains configuration for running a specific tool.
+type Tool struct {
+	RetryPolicy
+	Limits
+	// Dir pins the working directory the tool is run from, regardless of where
+	// it was invoked from. It is relative to the directory containing the config file,
+	// so '.' means the project root. If empty, the tool is run from the current directory.
+	Dir string `json:"dir,omitempty"`
+	// Install contains settings for installing the tool. They are combined with the
+	// install settings for the project, with the tool's settings taking precedence.
+	Install Install `json:"install,omitempty"`
+	// Completion configures shell completions for the arguments of the tool.
+	// If nil, the completion spec bundled with shed for the tool is used, if there is one.
+	Completion *Completion `json:"completion,omitempty"`
+	// VersionProbe runs the tool after it is installed to record the version it reports.
+	// If nil, the tool isn't run when it is installed.
+	VersionProbe *VersionProbe `json:"versionProbe,omitempty"`
+	// Prebuilt is where prebuilt binaries of the tool are published, ex: its GitHub releases.
+	// If a prebuilt binary matches the version and platform being installed, it is downloaded
+	// instead of building the tool. If nil, the tool is always built from source.
+	Prebuilt *Prebuilt `json:"prebuilt,omitempty"`
+	// InstallHooks are commands that are run when the tool is installed, ex: to clear a cache the tool keeps.
+	InstallHooks *InstallHooks `json:"installHooks,omitempty"`
+}
+
+// InstallHooks are commands that are run around installing a tool. Like Hooks, each command is a list
+// containing the program and its arguments, and is run from the project root with details about the tool
+// in environment variables. They are run each time the tool is installed, even if it was already in the cache,
+// but not when it is installed for another platform.
+type InstallHooks struct {
+	// PreInstall is run before the tool is installed. If it fails, the tool is not installed.
+	PreInstall []string `json:"preInstall,omitempty"`
+	// PostInstall is run after the tool is installed, with the path of its binary. If it fails,
+	// the install of the tool fails and it is not added to the lockfile.
+	PostInstall []string `json:"postInstall,omitempty"`
+}
+
+// Prebuilt configures where prebuilt binaries of a tool are downloaded from. The fields are templates
+// that are executed with tool.ReleaseData, ex: 'golangci-lint-{{.Number}}-{{.OS}}-{{.Arch}}.tar.gz'.
+// Exactly one of URL and Asset must be set.
+type Prebuilt struct {
+	// URL is the URL of the asset containing the binary.
+	URL string `json:"url,omitempty"`
+	// Asset is the name of an asset of the GitHub release for the tag of the version being installed.
+	// The repository is the first three elements of the import path, which must be on github.com.
+	Asset string `json:"asset,omitempty"`
+	// Binary is the path of the binary in the asset if it is an archive. If empty, it is the file in the
+	// archive named after the tool.
+	Binary string `json:"binary,omitempty"`
+	// Checksums is the URL, or the name of the asset if Asset is set, of a file containing the SHA-256 hashes
+	// of the assets in the format written by sha256sum. It is required, since a prebuilt binary can't be checked
+	// against the module sum in the lockfile like one built from source.
+	Checksums string `json:"checksums"`
+}
+
+// VersionProbe configures how a tool is asked for its version after it is installed.
+// The version it reports is checked by 'shed verify' against the version in the lockfile,
+// which catches tools whose builds embed the wrong version.
+type VersionProbe struct {
+	// Args are the arguments the tool is run with to print its version. If empty, '--version' is used.
+	Args []string `json:"args,omitempty"`
+}
+
+// Completion configures how the arguments of a tool are completed.
+// Exactly one of Spec or Cobra must be set.
+type Completion struct {
+	// Spec is the path to a JSON completion spec file, relative to the project root.
+	Spec string `json:"spec,omitempty"`
+	// Cobra requests completions from the tool itself. It can be used with tools
+	// built with cobra, which provide completions through a hidden command.
+	Cobra bool `json:"cobra,omitempty"`
+}
+
+// Task is a named invocation of a tool with a predefined set of arguments.
+type Task struct {
+	// Tool is the name of the tool to run. It can either be the
+	// name of the binary or the full import path. Tool can only be
+	// omitted if DependsOn is set, in which case the task is used to group other tasks.
+	Tool string `json:"tool,omitempty"`
+	// DependsOn is a list of tasks that must complete successfully
+	// before this task is run.
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// Args are the arguments passed to the tool.
+	Args []string `json:"args,omitempty"`
+	// Inputs are glob patterns matching the files the task reads, relative to the
+	// project root. '**' can be used to match any number of directories.
+	// If set, the task is only run if the inputs have changed since the last successful run.
+	Inputs []string `json:"inputs,omitempty"`
+	// Outputs are glob patterns matching the files the task writes, relative to the project root.
+	// They are saved after a successful run and restored when the task is skipped.
+	// Outputs can only be set if Inputs is set.
+	Outputs []string `json:"outputs,omitempty"`
+	RetryPolicy
+	Limits
+	// Dir pins the working directory the task is run from. See Tool.Dir for details.
+	Dir string `json:"dir,omitempty"`
+}
+
+// Project represents a project config file.
+//
+// A zero value Project is a valid empty config ready for use.
+type Project struct {
+	// Tools maps tool names to config for that tool. The key can either be the
+	// name of the binary or the full import path. If both are present the
+	// import path takes precedence.
+	Tools map[string]Tool `json:"tools,omitempty"`
+	// Tasks maps task names to tasks.
+	Tasks map[string]Task `json:"tasks,omitempty"`
+	// Install contains settings for installing all tools.
+	Install Install `json:"install,omitempty"`
+	// Cache configures a tool cache inside the project. If nil, the user's cache is used.
+	Cache *ProjectCache `json:"cache,omitempty"`
+	// RemoteCache configures a remote cache used to share the outputs of tasks and built tools.
+	RemoteCache *RemoteCache `json:"remoteCache,omitempty"`
+	// Redaction configures how private import paths are hidden in data exported by shed.
+	Redaction *Redaction `json:"redaction,omitempty"`
+	// Hooks are commands that are run before and after every tool.
+	Hooks *Hooks `json:"hooks,omitempty"`
+	// Shims configures the shims created by 'shed shims'.
+	Shims *Shims `json:"shims,omitempty"`
+	// Renames maps old tool names to the names that replaced them, for example after
+	// a binary was renamed. Old names keep resolving to the new tool with a deprecation
+	// warning until they are removed from the config.
+	Renames map[string]string `json:"renames,omitempty"`
+	// ShedVersion is the versions of shed that can be used with the project. It is either a minimum
+	// version, ex: 'v0.8.0', or a constraint, ex: '^v0.8' or '<v1.0.0', using the same syntax as
+	// install specs. This allows projects to rely on features added in newer versions of shed.
+	ShedVersion string `json:"shedVersion,omitempty"`
+	// EnforceToolchain makes 'shed install' refuse to build tools whose modules require a newer version of Go
+	// than the one installed, instead of letting the build fail or the go command download a newer toolchain.
+	EnforceToolchain bool `json:"enforceToolchain,omitempty"`
+	// Features enables or disables experimental features for the project, see package features.
+	// The user config and SHED_FEATURES take precedence.
+	Features map[string]bool `json:"features,omitempty"`
+}
+
+// ShedVersionError is returned by CheckShedVersion when the running version of shed
+// isn't allowed by the project.
+type ShedVersionError struct {
+	// Version is the running version of shed.
+	Version string
+	// Required is the ShedVersion of the project.
+	Required string
+}
+
+func (e *ShedVersionError) Error() string {
+	return fmt.Sprintf("config: project requires shed %s, but this is shed %s", e.Required, e.Version)
+}
+
+// CheckShedVersion checks that version, the running version of shed, is allowed by ShedVersion.
+// If it isn't, a *ShedVersionError is returned. If ShedVersion is empty, any version is allowed.
+func (p *Project) CheckShedVersion(version string) error {
+	if p.ShedVersion == "" {
+		return nil
+	}
+	ok, err := matchShedVersion(p.ShedVersion, version)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &ShedVersionError{Version: version, Required: p.ShedVersion}
+	}
+	return nil
+}
+
+// matchShedVersion reports whether version is allowed by constraint. A version on its own
+// is a minimum, unlike in install specs where it is the exact version.
+func matchShedVersion(constraint, version string) (bool, error) {
+	if semver.IsValid(constraint) {
+		return semver.Compare(version, constraint) >= 0, nil
+	}
+	v, ok, err := resolver.Match([]string{version}, constraint)
+	if err != nil {
+		return false, fmt.Errorf("config: invalid shedVersion: %w", err)
+	}
+	if !ok {
+		return false, fmt.Errorf("config: invalid shedVersion %q, must be a version or a version constraint", constraint)
+	}
+	return v == version, nil
+}
+
+// Redaction is a policy for hiding private import paths in exported data like reports.
+// It does not affect the lockfile.
+type Redaction struct {
+	// Private is a list of glob patterns matching private import paths, using the same
+	// syntax as GOPRIVATE. Matching import paths are replaced by a hash.
+	Private []string `json:"private,omitempty"`
+	// Aliases maps import path prefixes to public aliases. An import path starting with
+	// one of the prefixes has the prefix replaced by the alias. Aliases take precedence over Private.
+	Aliases map[string]string `json:"aliases,omitempty"`
+}
+
+// Hooks are commands that are run around every tool that is run, including tools run by tasks.
+// Each command is a list containing the program and its arguments. A program containing a slash
+// is relative to the project root. Hooks are run from the project root and are passed details
+// about the tool in environment variables.
+type Hooks struct {
+	// PreRun is run before the tool. If it fails, the tool is not run. Each line the command
+	// writes to stdout must be in the form 'KEY=VALUE' and is added to the tool's environment.
+	PreRun []string `json:"preRun,omitempty"`
+	// PostRun is run after the tool, even if the tool failed.
+	PostRun []string `json:"postRun,omitempty"`
+}
+
+// Shims configures the shims that run tools by name from PATH.
+type Shims struct {
+	// Name is a text/template that names the shim of each tool, ex: '{{.Name}}-{{.MajorVersion}}'
+	// to allow multiple major versions of a tool with the same name. See client.ShimNameData
+	// for the fields it can use. If empty, each shim is named after its tool.
+	Name string `json:"name,omitempty"`
+}
+
+// ProjectCache configures a tool cache that lives inside the project instead of the user's
+// cache directory. This allows tools to be restored with the rest of the project, for example
+// by a CI cache step, without needing to download or build them.
+type ProjectCache struct {
+	// Dir is the directory where tools are cached, relative to the project root, ex: '.shed/cache'.
+	// It takes precedence over the cache directory in the user config.
+	Dir string `json:"dir"`
+}
+
+// RemoteCache configures a cache that is shared between machines.
+type RemoteCache struct {
+	// URL is the location of the cache. Supported schemes are http, https, file, and oci.
+	URL string `json:"url"`
+	// ReadOnly prevents shed from pushing to the cache. This is useful to only
+	// allow CI to populate the cache.
+	ReadOnly bool `json:"readOnly,omitempty"`
+}
+
+// Tool returns the config for t. If no config exists for t, a zero value is returned.
+func (p *Project) Tool(t tool.Tool) Tool {
+	if tc, ok := p.Tools[t.ImportPath]; ok {
+		return tc
+	}
+	return p.Tools[t.Name()]
+}
+
+// InstallSettings returns the settings for installing t. These are the project's
+// install settings combined with the install settings for t. If t has credentials,
+// they replace the project's credentials.
+//
+// GoProxy is the goproxy of t if it is set, otherwise the first of the project's proxies
+// that matches t, otherwise the project's goproxy. Proxies is always empty.
+func (p *Project) InstallSettings(t tool.Tool) Install {
+	ti := p.Tool(t).Install
+	install := Install{
+		GoInsecure: appendPatterns(p.Install.GoInsecure, ti.GoInsecure),
+		GoVCS:      p.Install.GoVCS,
+		GoPrivate:  appendPatterns(p.Install.GoPrivate, ti.GoPrivate),
+		GoNoSumDB:  appendPatterns(p.Install.GoNoSumDB, ti.GoNoSumDB),
+		GoProxy:    ti.GoProxy,
+	}
+	if ti.GoVCS != "" {
+		install.GoVCS = ti.GoVCS
+	}
+	if install.GoProxy == "" {
+		install.GoProxy = p.Install.GoProxy
+		for _, proxy := range p.Install.Proxies {
+			if module.MatchPrefixPatterns(proxy.Match, t.ImportPath) {
+				install.GoProxy = proxy.GoProxy
+				break
+			}
+		}
+	}
+	install.Credentials = p.Install.Credentials
+	if ti.Credentials != nil {
+		install.Credentials = ti.Credentials
+	}
+	if len(p.Install.GitConfig) > 0 || len(ti.GitConfig) > 0 {
+		install.GitConfig = make(map[string]string)
+		for k, v := range p.Install.GitConfig {
+			install.GitConfig[k] = v
+		}
+		for k, v := range ti.GitConfig {
+			install.GitConfig[k] = v
+		}
+	}
+	return install
+}
+
+// appendPatterns returns the patterns of the project followed by the patterns of a tool
+// without modifying either.
+func appendPatterns(project, tl []string) []string {
+	return append(project[:len(project):len(project)], tl...)
+}
+
+// Parse reads from r and parses the data into a Project.
+func Parse(r io.Reader) (*Project, error) {
+	var p Project
+	dec := json.NewDecoder(r)
+	// Error on unknown fields since the config is written by hand,
+	// so this likely means there is a typo
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("config: failed to deserialize JSON: %w", err)
+	}
+
+	for name, task := range p.Tasks {
+		if task.Tool == "" && len(task.DependsOn) == 0 {
+			return nil, fmt.Errorf("config: task %q is missing a tool", name)
+		}
+		for _, dep := range task.DependsOn {
+			if _, ok := p.Tasks[dep]; !ok {
+				return nil, fmt.Errorf("config: task %q depends on unknown task %q", name, dep)
+			}
+		}
+		if len(task.Outputs) > 0 && len(task.Inputs) == 0 {
+			return nil, fmt.Errorf("config: task %q has outputs but no inputs", name)
+		}
+		if err := validate(task.RetryPolicy, task.Limits, task.Dir); err != nil {
+			return nil, fmt.Errorf("config: task %q %w", name, err)
+		}
+		for _, pattern := range append(task.Inputs[:len(task.Inputs):len(task.Inputs)], task.Outputs...) {
+			if err := validatePattern(pattern); err != nil {
+				return nil, fmt.Errorf("config: task %q has invalid pattern %q: %w", name, pattern, err)
+			}
+		}
+	}
+	for name, tc := range p.Tools {
+		if err := validate(tc.RetryPolicy, tc.Limits, tc.Dir); err != nil {
+			return nil, fmt.Errorf("config: tool %q %w", name, err)
+		}
+		if err := validateInstall(tc.Install); err != nil {
+			return nil, fmt.Errorf("config: tool %q %w", name, err)
+		}
+		if len(tc.Install.Proxies) > 0 {
+			return nil, fmt.Errorf("config: tool %q install proxies can only be set for the project, use goproxy instead", name)
+		}
+		if tc.Install.Retry != nil {
+			return nil, fmt.Errorf("config: tool %q install retry can only be set for the project", name)
+		}
+		if c := tc.Completion; c != nil && (c.Spec == "") == !c.Cobra {
+			return nil, fmt.Errorf("config: tool %q completion must set exactly one of spec or cobra", name)
+		}
+		if pb := tc.Prebuilt; pb != nil {
+			if err := validatePrebuilt(pb); err != nil {
+				return nil, fmt.Errorf("config: tool %q prebuilt %w", name, err)
+			}
+		}
+		if h := tc.InstallHooks; h != nil {
+			if err := validateHook(h.PreInstall); err != nil {
+				return nil, fmt.Errorf("config: tool %q preInstall hook %w", name, err)
+			}
+			if err := validateHook(h.PostInstall); err != nil {
+				return nil, fmt.Errorf("config: tool %q postInstall hook %w", name, err)
+			}
+		}
+	}
+	if err := validateInstall(p.Install); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	if p.Cache != nil {
+		if p.Cache.Dir == "" {
+			return nil, errors.New("config: cache is missing a dir")
+		}
+		if err := validatePattern(p.Cache.Dir); err != nil {
+			return nil, fmt.Errorf("config: cache dir %w", err)
+		}
+	}
+	if p.RemoteCache != nil && p.RemoteCache.URL == "" {
+		return nil, errors.New("config: remoteCache is missing a url")
+	}
+	if p.Redaction != nil {
+		for prefix, alias := range p.Redaction.Aliases {
+			if prefix == "" || alias == "" {
+				return nil, fmt.Errorf("config: redaction alias %q -> %q must not be empty", prefix, alias)
+			}
+		}
+	}
+	if p.Hooks != nil {
+		if err := validateHook(p.Hooks.PreRun); err != nil {
+			return nil, fmt.Errorf("config: preRun hook %w", err)
+		}
+		if err := validateHook(p.Hooks.PostRun); err != nil {
+			return nil, fmt.Errorf("config: postRun hook %w", err)
+		}
+	}
+	if p.Shims != nil && p.Shims.Name != "" {
+		if _, err := template.New("name").Parse(p.Shims.Name); err != nil {
+			return nil, fmt.Errorf("config: invalid shims name: %w", err)
+		}
+	}
+	for oldName, newName := range p.Renames {
+		if oldName == "" || newName == "" {
+			return nil, fmt.Errorf("config: rename %q -> %q must not be empty", oldName, newName)
+		}
+		if oldName == newName {
+			return nil, fmt.Errorf("config: tool %q cannot be renamed to itself", oldName)
+		}
+	}
+	if p.ShedVersion != "" {
+		// Any valid version works to check the constraint
+		if _, err := matchShedVersion(p.ShedVersion, "v0.0.0"); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkCycles(p.Tasks); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// checkCycles makes sure there are no cycles in the dependencies between tasks.
+func checkCycles(tasks map[string]Task) error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("config: dependency cycle between tasks: %s", strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range tasks[name].DependsOn {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+
+	// Sort so errors are deterministic
+	names := make([]string, 0, len(tasks))
+	for name := range tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validate(rp RetryPolicy, l Limits, dir string) error {
+	if rp.Retries < 0 {
+		return errors.New("has negative retries")
+	}
+	if rp.Backoff < 0 {
+		return errors.New("has negative backoff")
+	}
+	if l.Timeout < 0 {
+		return errors.New("has negative timeout")
+	}
+	if l.KillAfter < 0 {
+		return errors.New("has negative killAfter")
+	}
+	// Check both since a path with a leading slash isn't absolute on windows
+	if filepath.IsAbs(dir) || path.IsAbs(dir) {
+		return errors.New("dir must be relative to the project root")
+	}
+	return nil
+}
+
+func validateInstall(install Install) error {
+	patterns := []struct {
+		name     string
+		patterns []string
+	}{
+		{"goinsecure", install.GoInsecure},
+		{"goprivate", install.GoPrivate},
+		{"gonosumdb", install.GoNoSumDB},
+	}
+	for _, p := range patterns {
+		for _, pattern := range p.patterns {
+			if pattern == "" || strings.Contains(pattern, ",") {
+				return fmt.Errorf("install has invalid %s pattern %q", p.name, pattern)
+			}
+		}
+	}
+	for i, proxy := range install.Proxies {
+		if proxy.Match == "" || proxy.GoProxy == "" {
+			return fmt.Errorf("install proxy %d must set both match and goproxy", i)
+		}
+	}
+	if r := install.Retry; r != nil {
+		if r.Attempts < 1 {
+			return errors.New("install retry must have at least 1 attempt")
+		}
+		if r.BaseDelay < 0 {
+			return errors.New("install retry has negative baseDelay")
+		}
+		if r.Jitter < 0 || r.Jitter > 1 {
+			return errors.New("install retry jitter must be between 0 and 1")
+		}
+	}
+	for key := range install.GitConfig {
+		// Git config keys must contain a section and a name
+		if i := strings.IndexByte(key, '.'); i <= 0 || i == len(key)-1 {
+			return fmt.Errorf("install has invalid git config key %q, must be in the form 'section.name'", key)
+		}
+	}
+	return nil
+}
+
+func validateHook(command []string) error {
+	// A nil command means the hook wasn't set
+	if command != nil && (len(command) == 0 || command[0] == "") {
+		return errors.New("is missing a command")
+	}
+	return nil
+}
+
+func validatePrebuilt(pb *Prebuilt) error {
+	switch {
+	case (pb.URL == "") == (pb.Asset == ""):
+		return errors.New("must set exactly one of url or asset")
+	case pb.Checksums == "":
+		return errors.New("is missing checksums")
+	}
+	for _, tmpl := range []struct{ name, text string }{{"url", pb.URL}, {"asset", pb.Asset}, {"binary", pb.Binary}, {"checksums", pb.Checksums}} {
+		if _, err := template.New(tmpl.name).Parse(tmpl.text); err != nil {
+			return fmt.Errorf("has invalid %s template: %w", tmpl.name, err)
+		}
+	}
+	return nil
+}
+
+func validatePattern(pattern string) error {
+	if filepath.IsAbs(pattern) || path.IsAbs(pattern) {
+		return errors.New("must be relative to the project root")
+	}
+	for _, seg := range strings.Split(pattern, "/") {
+		if seg == ".." {
+			return errors.New("must not refer to parent directories")
+		}
+		// Use the error from Match to validate the syntax
+		if _, err := path.Match(seg, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
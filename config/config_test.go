@@ -0,0 +1,495 @@
+package config_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getshiphub/shed/config"
+	"github.com/getshiphub/shed/tool"
+)
+
+func TestParse(t *testing.T) {
+	r := strings.NewReader(`{
+  "tools": {
+    "golangci-lint": {"retries": 2, "backoff": "5s", "timeout": "10m", "killAfter": "10s"}
+  },
+  "remoteCache": {"url": "https://cache.example.com", "readOnly": true},
+  "tasks": {
+    "lint": {"tool": "golangci-lint", "args": ["run", "./..."], "retries": 1},
+    "check": {"dependsOn": ["lint"]},
+    "gen": {"tool": "stringer", "inputs": ["**/*.go"], "outputs": ["**/*_string.go"]}
+  }
+}`)
+	got, err := config.Parse(r)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	want := &config.Project{
+		Tools: map[string]config.Tool{
+			"golangci-lint": {
+				RetryPolicy: config.RetryPolicy{Retries: 2, Backoff: config.Duration(5 * time.Second)},
+				Limits:      config.Limits{Timeout: config.Duration(10 * time.Minute), KillAfter: config.Duration(10 * time.Second)},
+			},
+		},
+		Tasks: map[string]config.Task{
+			"lint":  {Tool: "golangci-lint", Args: []string{"run", "./..."}, RetryPolicy: config.RetryPolicy{Retries: 1}},
+			"check": {DependsOn: []string{"lint"}},
+			"gen":   {Tool: "stringer", Inputs: []string{"**/*.go"}, Outputs: []string{"**/*_string.go"}},
+		},
+		RemoteCache: &config.RemoteCache{URL: "https://cache.example.com", ReadOnly: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{
+			name: "invalid JSON",
+			data: `{"tools": `,
+		},
+		{
+			name: "unknown field",
+			data: `{"tols": {}}`,
+		},
+		{
+			name: "invalid duration",
+			data: `{"tools": {"stringer": {"backoff": "5 seconds"}}}`,
+		},
+		{
+			name: "task missing tool",
+			data: `{"tasks": {"gen": {"args": ["./..."]}}}`,
+		},
+		{
+			name: "negative retries",
+			data: `{"tools": {"stringer": {"retries": -1}}}`,
+		},
+		{
+			name: "unknown dependency",
+			data: `{"tasks": {"gen": {"tool": "stringer", "dependsOn": ["fmt"]}}}`,
+		},
+		{
+			name: "dependency cycle",
+			data: `{"tasks": {
+  "a": {"tool": "stringer", "dependsOn": ["b"]},
+  "b": {"tool": "stringer", "dependsOn": ["c"]},
+  "c": {"tool": "stringer", "dependsOn": ["a"]}
+}}`,
+		},
+		{
+			name: "absolute dir",
+			data: `{"tools": {"stringer": {"dir": "/tmp"}}}`,
+		},
+		{
+			name: "negative timeout",
+			data: `{"tasks": {"gen": {"tool": "stringer", "timeout": "-1m"}}}`,
+		},
+		{
+			name: "remote cache missing url",
+			data: `{"remoteCache": {"readOnly": true}}`,
+		},
+		{
+			name: "empty redaction alias",
+			data: `{"redaction": {"aliases": {"github.com/acme": ""}}}`,
+		},
+		{
+			name: "cache missing dir",
+			data: `{"cache": {}}`,
+		},
+		{
+			name: "cache dir outside project",
+			data: `{"cache": {"dir": "../cache"}}`,
+		},
+		{
+			name: "empty completion",
+			data: `{"tools": {"golangci-lint": {"completion": {}}}}`,
+		},
+		{
+			name: "completion with spec and cobra",
+			data: `{"tools": {"golangci-lint": {"completion": {"spec": "lint.json", "cobra": true}}}}`,
+		},
+		{
+			name: "prebuilt without checksums",
+			data: `{"tools": {"golangci-lint": {"prebuilt": {"asset": "golangci-lint-{{.Number}}-{{.OS}}-{{.Arch}}.tar.gz"}}}}`,
+		},
+		{
+			name: "prebuilt with url and asset",
+			data: `{"tools": {"golangci-lint": {"prebuilt": {"url": "https://example.com/lint.tar.gz", "asset": "lint.tar.gz", "checksums": "sums.txt"}}}}`,
+		},
+		{
+			name: "invalid prebuilt template",
+			data: `{"tools": {"golangci-lint": {"prebuilt": {"asset": "lint-{{.OS", "checksums": "sums.txt"}}}}`,
+		},
+		{
+			name: "empty postInstall hook",
+			data: `{"tools": {"golangci-lint": {"installHooks": {"postInstall": [""]}}}}`,
+		},
+		{
+			name: "invalid goinsecure pattern",
+			data: `{"install": {"goinsecure": ["a.com,b.com"]}}`,
+		},
+		{
+			name: "invalid goprivate pattern",
+			data: `{"install": {"goprivate": [""]}}`,
+		},
+		{
+			name: "proxy missing goproxy",
+			data: `{"install": {"proxies": [{"match": "git.corp.example.com"}]}}`,
+		},
+		{
+			name: "tool proxies",
+			data: `{"tools": {"stringer": {"install": {"proxies": [{"match": "golang.org", "goproxy": "direct"}]}}}}`,
+		},
+		{
+			name: "invalid git config key",
+			data: `{"tools": {"stringer": {"install": {"gitConfig": {"sslVerify": "false"}}}}}`,
+		},
+		{
+			name: "empty hook",
+			data: `{"hooks": {"preRun": []}}`,
+		},
+		{
+			name: "hook missing program",
+			data: `{"hooks": {"postRun": [""]}}`,
+		},
+		{
+			name: "invalid shims name",
+			data: `{"shims": {"name": "{{.Name"}}`,
+		},
+		{
+			name: "empty rename",
+			data: `{"renames": {"golint": ""}}`,
+		},
+		{
+			name: "rename to itself",
+			data: `{"renames": {"golint": "golint"}}`,
+		},
+		{
+			name: "invalid shed version",
+			data: `{"shedVersion": "main"}`,
+		},
+		{
+			name: "invalid shed version constraint",
+			data: `{"shedVersion": ">=0.8"}`,
+		},
+		{
+			name: "outputs without inputs",
+			data: `{"tasks": {"gen": {"tool": "stringer", "outputs": ["*_string.go"]}}}`,
+		},
+		{
+			name: "invalid pattern",
+			data: `{"tasks": {"gen": {"tool": "stringer", "inputs": ["[a-"]}}}`,
+		},
+		{
+			name: "pattern outside project",
+			data: `{"tasks": {"gen": {"tool": "stringer", "inputs": ["../*.go"]}}}`,
+		},
+		{
+			name: "install retry without attempts",
+			data: `{"install": {"retry": {"baseDelay": "1s"}}}`,
+		},
+		{
+			name: "install retry invalid jitter",
+			data: `{"install": {"retry": {"attempts": 3, "jitter": 2}}}`,
+		},
+		{
+			name: "tool install retry",
+			data: `{"tools": {"stringer": {"install": {"retry": {"attempts": 3}}}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := config.Parse(strings.NewReader(tt.data))
+			if err == nil {
+				t.Error("want non-nil error, got nil")
+			}
+		})
+	}
+}
+
+func TestCheckShedVersion(t *testing.T) {
+	tests := []struct {
+		required string
+		version  string
+		wantErr  bool
+	}{
+		{"", "v0.1.0", false},
+		{"v0.8.0", "v0.8.0", false},
+		{"v0.8.0", "v1.2.0", false},
+		{"v0.8.0", "v0.7.9", true},
+		{"v0.8", "v0.8.4", false},
+		{"^v0.8.1", "v0.8.3", false},
+		{"^v0.8.1", "v0.9.0", true},
+		{"<v1.0.0", "v1.0.0", true},
+		{">=v0.8.0-rc.1", "v0.8.0-rc.2", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.required+" "+tt.version, func(t *testing.T) {
+			p := &config.Project{ShedVersion: tt.required}
+			err := p.CheckShedVersion(tt.version)
+			var verr *config.ShedVersionError
+			if tt.wantErr && !errors.As(err, &verr) {
+				t.Errorf("got error %v, want *config.ShedVersionError", err)
+			} else if !tt.wantErr && err != nil {
+				t.Errorf("want nil error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestProjectTool(t *testing.T) {
+	p := &config.Project{
+		Tools: map[string]config.Tool{
+			"stringer":                        {RetryPolicy: config.RetryPolicy{Retries: 1}},
+			"golang.org/x/tools/cmd/stringer": {RetryPolicy: config.RetryPolicy{Retries: 2}},
+			"ejson":                           {RetryPolicy: config.RetryPolicy{Retries: 3}},
+		},
+	}
+
+	tests := []struct {
+		name string
+		tool tool.Tool
+		want int
+	}{
+		{
+			name: "import path takes precedence",
+			tool: tool.Tool{ImportPath: "golang.org/x/tools/cmd/stringer"},
+			want: 2,
+		},
+		{
+			name: "short name",
+			tool: tool.Tool{ImportPath: "example.org/z/random/stringer/v2/cmd/stringer"},
+			want: 1,
+		},
+		{
+			name: "no config",
+			tool: tool.Tool{ImportPath: "github.com/cszatmary/go-fish"},
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := p.Tool(tt.tool)
+			if got.Retries != tt.want {
+				t.Errorf("got %d retries, want %d", got.Retries, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstallSettingsGoProxy(t *testing.T) {
+	p := &config.Project{
+		Install: config.Install{
+			GoProxy:   "https://proxy.golang.org",
+			GoNoSumDB: []string{"git.corp.example.com"},
+			Proxies: []config.Proxy{
+				{Match: "git.corp.example.com,github.com/acme/*", GoProxy: "https://proxy.corp.example.com"},
+			},
+		},
+		Tools: map[string]config.Tool{
+			"lint": {Install: config.Install{GoProxy: "direct", GoNoSumDB: []string{"github.com/acme"}}},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		tool      tool.Tool
+		want      string
+		wantNoSum []string
+	}{
+		{
+			name:      "project goproxy",
+			tool:      tool.Tool{ImportPath: "golang.org/x/tools/cmd/stringer"},
+			want:      "https://proxy.golang.org",
+			wantNoSum: []string{"git.corp.example.com"},
+		},
+		{
+			name:      "matching proxy",
+			tool:      tool.Tool{ImportPath: "github.com/acme/tools/cmd/gen"},
+			want:      "https://proxy.corp.example.com",
+			wantNoSum: []string{"git.corp.example.com"},
+		},
+		{
+			name:      "tool goproxy takes precedence",
+			tool:      tool.Tool{ImportPath: "git.corp.example.com/tools/cmd/lint"},
+			want:      "direct",
+			wantNoSum: []string{"git.corp.example.com", "github.com/acme"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := p.InstallSettings(tt.tool)
+			if got.GoProxy != tt.want {
+				t.Errorf("got goproxy %q, want %q", got.GoProxy, tt.want)
+			}
+			if !reflect.DeepEqual(got.GoNoSumDB, tt.wantNoSum) {
+				t.Errorf("got gonosumdb %v, want %v", got.GoNoSumDB, tt.wantNoSum)
+			}
+			if len(got.Proxies) != 0 {
+				t.Errorf("got proxies %v, want none", got.Proxies)
+			}
+		})
+	}
+}
+
+func TestParseUser(t *testing.T) {
+	got, err := config.ParseUser(strings.NewReader(`{"color": "never", "output": "plain", "cache": {"dir": "/var/cache/shed", "shared": true}}`))
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := &config.User{Color: "never", Output: "plain", Cache: config.UserCache{Dir: "/var/cache/shed", Shared: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	_, err = config.ParseUser(strings.NewReader(`{"color": "sometimes"}`))
+	if err == nil {
+		t.Error("want non-nil error, got nil")
+	}
+	_, err = config.ParseUser(strings.NewReader(`{"output": "fancy"}`))
+	if err == nil {
+		t.Error("want non-nil error, got nil")
+	}
+	_, err = config.ParseUser(strings.NewReader(`{"contexts": {"ci": {"cache": {"goModCache": "relative/mod"}}}}`))
+	if err == nil {
+		t.Error("want non-nil error, got nil")
+	}
+}
+
+func TestUserContext(t *testing.T) {
+	u, err := config.ParseUser(strings.NewReader(`{
+  "cache": {"dir": "/var/cache/shed"},
+  "context": "personal",
+  "contexts": {
+    "personal": {},
+    "work": {
+      "cache": {"dir": "/work/cache", "shared": true},
+      "goproxy": "https://proxy.acme.dev",
+      "remoteCache": {"url": "https://cache.acme.dev", "readOnly": true},
+      "policy": "policy.json"
+    }
+  }
+}`))
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	// The default context uses the top level settings
+	got, err := u.ResolveContext("")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := config.UserContext{Cache: &config.UserCache{Dir: "/var/cache/shed"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	got, err = u.ResolveContext("work")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want = config.UserContext{
+		Cache:       &config.UserCache{Dir: "/work/cache", Shared: true},
+		GoProxy:     "https://proxy.acme.dev",
+		RemoteCache: &config.RemoteCache{URL: "https://cache.acme.dev", ReadOnly: true},
+		Policy:      "policy.json",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if _, err := u.ResolveContext("school"); err == nil {
+		t.Error("want non-nil error, got nil")
+	}
+}
+
+func TestUserContextError(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"unknown default context", `{"context": "work"}`},
+		{"empty name", `{"contexts": {"": {}}}`},
+		{"remote cache without url", `{"contexts": {"work": {"remoteCache": {}}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := config.ParseUser(strings.NewReader(tt.data)); err == nil {
+				t.Error("want non-nil error, got nil")
+			}
+		})
+	}
+}
+
+func TestParseWorkspace(t *testing.T) {
+	data := `{"projects": ["tools", "services/*"], "overrides": {"services/legacy": ["golangci-lint"]}}`
+	got, err := config.ParseWorkspace(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := &config.Workspace{
+		Projects:  []string{"tools", "services/*"},
+		Overrides: map[string][]string{"services/legacy": {"golangci-lint"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if !got.Overridden("services/legacy", "golangci-lint", "github.com/golangci/golangci-lint/cmd/golangci-lint") {
+		t.Error("want golangci-lint to be overridden in services/legacy")
+	}
+	if got.Overridden("tools", "golangci-lint", "github.com/golangci/golangci-lint/cmd/golangci-lint") {
+		t.Error("want golangci-lint not to be overridden in tools")
+	}
+}
+
+func TestParseWorkspaceError(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{
+			name: "no projects",
+			data: `{"projects": []}`,
+		},
+		{
+			name: "unknown field",
+			data: `{"projects": ["tools"], "overides": {}}`,
+		},
+		{
+			name: "absolute project",
+			data: `{"projects": ["/tools"]}`,
+		},
+		{
+			name: "project outside workspace",
+			data: `{"projects": ["../tools"]}`,
+		},
+		{
+			name: "invalid pattern",
+			data: `{"projects": ["services/[a-"]}`,
+		},
+		{
+			name: "override outside workspace",
+			data: `{"projects": ["tools"], "overrides": {"../tools": ["golint"]}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := config.ParseWorkspace(strings.NewReader(tt.data))
+			if err == nil {
+				t.Error("want non-nil error, got nil")
+			}
+		})
+	}
+}
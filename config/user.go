@@ -0,0 +1,181 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/getshiphub/shed/xdg"
+)
+
+// UserFileName is the name of the user config file.
+const UserFileName = "config.json"
+
+// ContextEnvVar is the environment variable that selects the context from the user config to use.
+const ContextEnvVar = "SHED_CONTEXT"
+
+// User represents the user config file. It contains settings that apply
+// to all projects for the current user.
+//
+// A zero value User is a valid empty config ready for use.
+type User struct {
+	// Color controls whether or not colour is used in output.
+	// It must be one of auto, always, or never. If empty, auto is used.
+	Color string `json:"color,omitempty"`
+	// Output controls how interactive output, like progress, is shown. It must be one of
+	// default or plain. plain is meant for screen readers. If empty, default is used.
+	Output string `json:"output,omitempty"`
+	// Language is the language messages are shown in, ex: 'ja'. If empty,
+	// the language of the locale from LC_ALL, LC_MESSAGES, or LANG is used.
+	Language string `json:"language,omitempty"`
+	// Cache contains settings for the tool cache.
+	Cache UserCache `json:"cache,omitempty"`
+	// Context is the name of the context that is used if none is selected.
+	// If empty, only the settings at the top level are used.
+	Context string `json:"context,omitempty"`
+	// Contexts are named groups of settings that can be switched between, ex: to
+	// separate personal projects from work projects that use a private proxy.
+	Contexts map[string]UserContext `json:"contexts,omitempty"`
+	// Features enables or disables experimental features for all projects, see package features.
+	// It takes precedence over the project config, and SHED_FEATURES takes precedence over it.
+	Features map[string]bool `json:"features,omitempty"`
+}
+
+// UserContext is a named group of settings in the user config.
+type UserContext struct {
+	// Cache contains settings for the tool cache. If nil, the top level cache settings are used.
+	Cache *UserCache `json:"cache,omitempty"`
+	// GoProxy is the value of GOPROXY used when installing tools. If empty, GOPROXY is not changed.
+	GoProxy string `json:"goproxy,omitempty"`
+	// RemoteCache configures the remote cache used to share the outputs of tasks and built tools.
+	// It takes precedence over the remote cache in the project config.
+	RemoteCache *RemoteCache `json:"remoteCache,omitempty"`
+	// Policy is the path to a policy file that lockfiles are checked against by 'shed verify',
+	// and that tools must follow to be installed, see client.WithPolicy.
+	// A relative path is relative to the directory of the user config file.
+	Policy string `json:"policy,omitempty"`
+}
+
+// UserCache contains user settings for the tool cache.
+type UserCache struct {
+	// Dir is the directory where tools are cached. If empty, the default directory is used.
+	Dir string `json:"dir,omitempty"`
+	// Shared enables shared mode which allows the cache to be used by multiple users.
+	// See cache.WithShared for details.
+	Shared bool `json:"shared,omitempty"`
+	// Modules makes the cache use its own module cache for the go command,
+	// so that it can be pruned. See cache.WithModuleCache for details.
+	Modules bool `json:"modules,omitempty"`
+	// Isolated makes the cache use its own module cache and build cache for the go command,
+	// for hermetic builds. See cache.WithIsolated for details.
+	Isolated bool `json:"isolated,omitempty"`
+	// GoModCache is the module cache the go command uses to install tools. It must be an absolute path.
+	// If empty, GOMODCACHE is not changed. See cache.WithGoModCache for details.
+	GoModCache string `json:"goModCache,omitempty"`
+	// GoBuildCache is the build cache the go command uses to install tools. It must be an absolute path.
+	// If empty, GOCACHE is not changed. See cache.WithGoBuildCache for details.
+	GoBuildCache string `json:"goBuildCache,omitempty"`
+}
+
+// UserPath returns the path to the user config file. This is
+// 'xdg.ConfigDir()/config.json'.
+func UserPath() (string, error) {
+	dir, err := xdg.ConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("config: failed to find user config directory: %w", err)
+	}
+	return filepath.Join(dir, UserFileName), nil
+}
+
+// ParseUser reads from r and parses the data into a User.
+func ParseUser(r io.Reader) (*User, error) {
+	var u User
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&u); err != nil {
+		return nil, fmt.Errorf("config: failed to deserialize JSON: %w", err)
+	}
+	switch u.Color {
+	case "", "auto", "always", "never":
+	default:
+		return nil, fmt.Errorf("config: invalid color %q, must be one of auto, always, never", u.Color)
+	}
+	switch u.Output {
+	case "", "default", "plain":
+	default:
+		return nil, fmt.Errorf("config: invalid output %q, must be one of default, plain", u.Output)
+	}
+	if err := validateUserCache(&u.Cache); err != nil {
+		return nil, err
+	}
+	for name, c := range u.Contexts {
+		if name == "" {
+			return nil, errors.New("config: context name must not be empty")
+		}
+		if err := validateUserCache(c.Cache); err != nil {
+			return nil, fmt.Errorf("config: context %q: %w", name, err)
+		}
+		if c.RemoteCache != nil && c.RemoteCache.URL == "" {
+			return nil, fmt.Errorf("config: context %q: remoteCache is missing a url", name)
+		}
+	}
+	if _, ok := u.Contexts[u.Context]; u.Context != "" && !ok {
+		return nil, fmt.Errorf("config: unknown context %q", u.Context)
+	}
+	return &u, nil
+}
+
+// validateUserCache checks the cache settings c, which can be nil.
+func validateUserCache(c *UserCache) error {
+	if c == nil {
+		return nil
+	}
+	for _, f := range []struct{ name, dir string }{{"goModCache", c.GoModCache}, {"goBuildCache", c.GoBuildCache}} {
+		if f.dir != "" && !filepath.IsAbs(f.dir) {
+			return fmt.Errorf("config: cache %s %q must be an absolute path", f.name, f.dir)
+		}
+	}
+	return nil
+}
+
+// ResolveContext returns the settings of the context with the given name. If name is empty,
+// the context in the Context field is used. If there is no context, the returned settings
+// only contain the top level settings.
+//
+// Settings not set by the context are taken from the top level, so the returned context
+// always has Cache set.
+func (u *User) ResolveContext(name string) (UserContext, error) {
+	if name == "" {
+		name = u.Context
+	}
+	var c UserContext
+	if name != "" {
+		var ok bool
+		c, ok = u.Contexts[name]
+		if !ok {
+			return UserContext{}, fmt.Errorf("config: unknown context %q", name)
+		}
+	}
+	if c.Cache == nil {
+		cache := u.Cache
+		c.Cache = &cache
+	}
+	return c, nil
+}
+
+// ReadUser reads the user config file at path. If the file does not exist,
+// an empty config is returned.
+func ReadUser(path string) (*User, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return &User{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: failed to open file %q: %w", path, err)
+	}
+	defer f.Close()
+	return ParseUser(f)
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// WorkspaceFileName is the name of the workspace file. It lives in the root directory of a monorepo
+// and lists the projects in it, each of which has its own shed lockfile.
+const WorkspaceFileName = "shed.work.json"
+
+// Workspace represents a workspace file.
+type Workspace struct {
+	// Projects are the directories of the projects in the workspace, relative to the directory containing
+	// the workspace file, using '/' as the separator. They can be glob patterns with the syntax of path.Match,
+	// ex: 'services/*', in which case every matching directory with a lockfile is a project.
+	Projects []string `json:"projects"`
+	// Overrides maps the directory of a project, as it appears in Projects or matched by a pattern,
+	// to the tools that the project pins at its own version on purpose. The tools can either be the
+	// name of the binary or the full import path. Reconciling the workspace leaves them as they are.
+	Overrides map[string][]string `json:"overrides,omitempty"`
+}
+
+// Overridden reports whether the project in the directory dir, relative to the workspace root,
+// pins the tool with the given name and import path at its own version.
+func (w *Workspace) Overridden(dir, name, importPath string) bool {
+	for _, tn := range w.Overrides[dir] {
+		if tn == name || tn == importPath {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseWorkspace reads from r and parses the data into a Workspace.
+func ParseWorkspace(r io.Reader) (*Workspace, error) {
+	var w Workspace
+	dec := json.NewDecoder(r)
+	// Like the project config, the workspace file is written by hand
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&w); err != nil {
+		return nil, fmt.Errorf("config: failed to deserialize JSON: %w", err)
+	}
+	if len(w.Projects) == 0 {
+		return nil, errors.New("config: workspace has no projects")
+	}
+	for _, p := range w.Projects {
+		if err := validateWorkspaceDir(p); err != nil {
+			return nil, fmt.Errorf("config: workspace project %q %w", p, err)
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("config: workspace project %q is an invalid pattern: %w", p, err)
+		}
+	}
+	for dir := range w.Overrides {
+		if err := validateWorkspaceDir(dir); err != nil {
+			return nil, fmt.Errorf("config: workspace override %q %w", dir, err)
+		}
+	}
+	return &w, nil
+}
+
+// validateWorkspaceDir checks that dir is a directory inside the workspace.
+func validateWorkspaceDir(dir string) error {
+	if dir == "" {
+		return errors.New("must not be empty")
+	}
+	if path.IsAbs(dir) || filepath.IsAbs(dir) {
+		return errors.New("must be relative to the workspace root")
+	}
+	if c := path.Clean(dir); c == ".." || strings.HasPrefix(c, "../") {
+		return errors.New("must be inside the workspace root")
+	}
+	return nil
+}
+
+// ReadWorkspace reads the workspace file at path.
+func ReadWorkspace(path string) (*Workspace, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: failed to open workspace file: %w", err)
+	}
+	defer f.Close()
+	return ParseWorkspace(f)
+}
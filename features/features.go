@@ -0,0 +1,155 @@
+// Package features provides flags that gate experimental parts of shed, so that they can be
+// tried out on some projects before they are enabled for everyone.
+//
+// Each feature has a default, which can be changed by the project config, the user config,
+// and the SHED_FEATURES environment variable, in increasing order of precedence.
+package features
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EnvVar is the environment variable that enables or disables features. It is a comma-separated
+// list of feature names, where a name prefixed with '-' disables the feature, ex: 'remote-tools,-other'.
+const EnvVar = "SHED_FEATURES"
+
+// Prebuilt downloads the prebuilt binaries of tools that publish them instead of building them, see cache.InstallPrebuilt.
+const Prebuilt = "prebuilt"
+
+// RemoteTasks shares the outputs of tasks through the remote cache.
+const RemoteTasks = "remote-tasks"
+
+// RemoteTools shares built tools through the remote cache, see cache.WithRemote.
+const RemoteTools = "remote-tools"
+
+// Feature describes a feature that can be enabled or disabled.
+type Feature struct {
+	// Name is the name used to refer to the feature, ex: in config files.
+	Name string
+	// Description is a short description of what the feature does.
+	Description string
+	// Default reports whether the feature is enabled if nothing changes it.
+	Default bool
+}
+
+// All is the list of known features, sorted by name.
+var All = []Feature{
+	{
+		Name:        Prebuilt,
+		Description: "download prebuilt binaries of tools instead of building them",
+		Default:     false,
+	},
+	{
+		Name:        RemoteTasks,
+		Description: "share the outputs of tasks through the remote cache",
+		Default:     false,
+	},
+	{
+		Name:        RemoteTools,
+		Description: "share built tools through the remote cache",
+		Default:     false,
+	},
+}
+
+func find(name string) (Feature, bool) {
+	for _, f := range All {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Feature{}, false
+}
+
+// Layer is a source of settings for features, ex: a config file.
+type Layer struct {
+	// Source is the name of where the settings come from, ex: 'user'.
+	Source string
+	// Values maps feature names to whether they are enabled.
+	Values map[string]bool
+}
+
+// ParseEnv parses v, the value of EnvVar, into a map of feature names to whether they are enabled.
+func ParseEnv(v string) (map[string]bool, error) {
+	values := make(map[string]bool)
+	for _, name := range strings.Split(v, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		enabled := !strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+		if name == "" {
+			return nil, fmt.Errorf("features: invalid %s %q, '-' must be followed by a feature name", EnvVar, v)
+		}
+		values[name] = enabled
+	}
+	return values, nil
+}
+
+// Status is the state of a feature in a Set.
+type Status struct {
+	Feature
+	// Enabled reports whether the feature is enabled.
+	Enabled bool
+	// Source is the source of the layer that decided whether the feature is enabled,
+	// or 'default' if no layer set it.
+	Source string
+	// Unknown reports whether the feature isn't known to this version of shed. Unknown features
+	// are ignored, so that settings for features added in newer versions don't cause errors.
+	Unknown bool
+}
+
+// Set is the resolved state of every feature.
+// A nil *Set is valid and uses the default of every feature.
+type Set struct {
+	statuses map[string]Status
+}
+
+// Resolve returns the state of every feature given layers of settings.
+// Later layers take precedence over earlier ones.
+func Resolve(layers ...Layer) *Set {
+	s := &Set{statuses: make(map[string]Status)}
+	for _, f := range All {
+		s.statuses[f.Name] = Status{Feature: f, Enabled: f.Default, Source: "default"}
+	}
+	for _, l := range layers {
+		for name, enabled := range l.Values {
+			st, ok := s.statuses[name]
+			if !ok {
+				st = Status{Feature: Feature{Name: name}, Unknown: true}
+			}
+			st.Enabled = enabled
+			st.Source = l.Source
+			s.statuses[name] = st
+		}
+	}
+	return s
+}
+
+// Enabled reports whether the feature with the given name is enabled.
+// Unknown features are never enabled.
+func (s *Set) Enabled(name string) bool {
+	if s == nil {
+		f, ok := find(name)
+		return ok && f.Default
+	}
+	st := s.statuses[name]
+	return !st.Unknown && st.Enabled
+}
+
+// List returns the state of every feature, including unknown features that were set, sorted by name.
+func (s *Set) List() []Status {
+	if s == nil {
+		s = Resolve()
+	}
+	statuses := make([]Status, 0, len(s.statuses))
+	for _, st := range s.statuses {
+		statuses = append(statuses, st)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
@@ -0,0 +1,63 @@
+package features_test
+
+import (
+	"testing"
+
+	"github.com/getshiphub/shed/features"
+)
+
+func TestResolve(t *testing.T) {
+	s := features.Resolve(
+		features.Layer{Source: "project", Values: map[string]bool{features.RemoteTools: true, "teleport": true}},
+		features.Layer{Source: "env", Values: map[string]bool{"teleport": false}},
+	)
+	if !s.Enabled(features.RemoteTools) {
+		t.Errorf("want %s to be enabled by the project", features.RemoteTools)
+	}
+	if s.Enabled("teleport") {
+		t.Error("want unknown feature to be disabled")
+	}
+
+	statuses := make(map[string]features.Status)
+	for _, st := range s.List() {
+		statuses[st.Name] = st
+	}
+	if len(statuses) != len(features.All)+1 {
+		t.Fatalf("got %d features, want %d", len(statuses), len(features.All)+1)
+	}
+	if st := statuses[features.RemoteTools]; !st.Enabled || st.Source != "project" || st.Unknown {
+		t.Errorf("got %+v, want %s enabled by project", st, features.RemoteTools)
+	}
+	if st := statuses["teleport"]; st.Enabled || st.Source != "env" || !st.Unknown {
+		t.Errorf("got %+v, want unknown teleport disabled by env", st)
+	}
+}
+
+func TestResolveDefaults(t *testing.T) {
+	var nilSet *features.Set
+	for _, s := range []*features.Set{features.Resolve(), nilSet} {
+		for _, f := range features.All {
+			if got := s.Enabled(f.Name); got != f.Default {
+				t.Errorf("%s: got enabled %t, want %t", f.Name, got, f.Default)
+			}
+		}
+		for _, st := range s.List() {
+			if st.Source != "default" {
+				t.Errorf("%s: got source %q, want default", st.Name, st.Source)
+			}
+		}
+	}
+}
+
+func TestParseEnv(t *testing.T) {
+	got, err := features.ParseEnv(" remote-tools, -teleport,,")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if len(got) != 2 || !got["remote-tools"] || got["teleport"] {
+		t.Errorf("got %v, want remote-tools enabled and teleport disabled", got)
+	}
+	if _, err := features.ParseEnv("remote-tools,-"); err == nil {
+		t.Error("want non-nil error, got nil")
+	}
+}
@@ -0,0 +1,284 @@
+// Package github is a client for the GitHub API, shared by the features of shed that use it,
+// such as fetching release notes and updating shed itself.
+//
+// Unauthenticated requests to the API are limited to 60 an hour, so the client authenticates with
+// a token when one is set, reports when the rate limit is exceeded with a *RateLimitError, and can
+// cache responses on disk so the same requests aren't made over and over.
+package github
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when the API responds with 404 Not Found.
+var ErrNotFound = errors.New("github: not found")
+
+// DefaultURL is the URL of the GitHub API.
+const DefaultURL = "https://api.github.com"
+
+// TokenEnvVar is the environment variable FromEnv reads the token to authenticate with from.
+const TokenEnvVar = "GITHUB_TOKEN"
+
+// maxResponseSize is the maximum number of bytes read from an API response.
+const maxResponseSize = 1 << 20
+
+// RateLimitError is returned when GitHub refuses a request because the rate limit was exceeded.
+type RateLimitError struct {
+	// URL is the URL of the request.
+	URL string
+	// Reset is when the rate limit resets, from the X-RateLimit-Reset or Retry-After header.
+	// It is the zero time if GitHub didn't say.
+	Reset time.Time
+	// Authenticated reports whether the request used a token.
+	Authenticated bool
+}
+
+func (e *RateLimitError) Error() string {
+	msg := fmt.Sprintf("github: rate limit exceeded for %s", e.URL)
+	if !e.Reset.IsZero() {
+		msg += fmt.Sprintf(", it resets at %s", e.Reset.Local().Format(time.RFC3339))
+	}
+	if !e.Authenticated {
+		msg += fmt.Sprintf(", set %s to raise it", TokenEnvVar)
+	}
+	return msg
+}
+
+// Client makes requests to the GitHub API.
+type Client struct {
+	url    string
+	token  string
+	client *http.Client
+	// cacheDir is where responses are cached, responses aren't cached if it is empty.
+	cacheDir string
+	maxAge   time.Duration
+}
+
+// New returns a Client that uses the API at url. If token is not empty, it is used to
+// authenticate, which raises the rate limit. If client is nil, http.DefaultClient is used.
+func New(client *http.Client, url, token string) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Client{url: strings.TrimSuffix(url, "/"), token: token, client: client}
+}
+
+// FromEnv returns a Client that uses DefaultURL, authenticated with the token in GITHUB_TOKEN if it is set.
+func FromEnv() *Client {
+	return New(nil, DefaultURL, os.Getenv(TokenEnvVar))
+}
+
+// WithCache returns a copy of c that caches the responses of Get in dir. Cached responses
+// younger than maxAge are used without making a request. Older ones are revalidated with their
+// ETag, which doesn't count against the rate limit if they haven't changed. If the rate limit is
+// exceeded, cached responses are used regardless of their age.
+func (c *Client) WithCache(dir string, maxAge time.Duration) *Client {
+	cc := *c
+	cc.cacheDir = dir
+	cc.maxAge = maxAge
+	return &cc
+}
+
+// cachedResponse is a response of the API stored in the cache dir.
+type cachedResponse struct {
+	URL       string    `json:"url"`
+	ETag      string    `json:"etag,omitempty"`
+	Status    int       `json:"status"`
+	Body      []byte    `json:"body,omitempty"`
+	FetchedAt time.Time `json:"fetchedAt"`
+}
+
+// Get fetches the API endpoint p, ex: '/repos/OWNER/REPO/releases/latest', and decodes the JSON response into v.
+// If the endpoint doesn't exist, the error is ErrNotFound. If the rate limit was exceeded, it is a *RateLimitError.
+func (c *Client) Get(ctx context.Context, p string, v interface{}) error {
+	url := c.url + p
+	cached := c.readCache(url)
+	if cached != nil && time.Since(cached.FetchedAt) < c.maxAge {
+		return decodeResponse(cached, v)
+	}
+
+	req, err := c.newRequest(ctx, url, "application/vnd.github+json")
+	if err != nil {
+		return err
+	}
+	if cached != nil && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("github: failed to fetch %s: %w", url, err)
+	}
+	defer res.Body.Close()
+	if err := c.checkRateLimit(res); err != nil {
+		if cached != nil {
+			return decodeResponse(cached, v)
+		}
+		return err
+	}
+
+	switch {
+	case res.StatusCode == http.StatusNotModified && cached != nil:
+	case res.StatusCode == http.StatusOK || res.StatusCode == http.StatusNotFound:
+		data, err := ioutil.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+		if err != nil {
+			return fmt.Errorf("github: failed to read response from %s: %w", url, err)
+		}
+		cached = &cachedResponse{URL: url, ETag: res.Header.Get("ETag"), Status: res.StatusCode, Body: data}
+	default:
+		return fmt.Errorf("github: failed to fetch %s: %s", url, res.Status)
+	}
+	cached.FetchedAt = time.Now()
+	c.writeCache(cached)
+	return decodeResponse(cached, v)
+}
+
+func decodeResponse(r *cachedResponse, v interface{}) error {
+	if r.Status == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrNotFound, r.URL)
+	}
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		return fmt.Errorf("github: invalid response from %s: %w", r.URL, err)
+	}
+	return nil
+}
+
+// Download writes the file at url to w, ex: the asset of a release. Downloads aren't cached. The token
+// is only sent to the API, since asset URLs redirect to other hosts.
+func (c *Client) Download(ctx context.Context, url string, w io.Writer) error {
+	req, err := c.newRequest(ctx, url, "application/octet-stream")
+	if err != nil {
+		return err
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("github: failed to download %s: %w", url, err)
+	}
+	defer res.Body.Close()
+	if err := c.checkRateLimit(res); err != nil {
+		return err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrNotFound, url)
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("github: failed to download %s: %s", url, res.Status)
+	}
+	if _, err := io.Copy(w, res.Body); err != nil {
+		return fmt.Errorf("github: failed to download %s: %w", url, err)
+	}
+	return nil
+}
+
+func (c *Client) newRequest(ctx context.Context, url, accept string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("github: failed to create request for %s: %w", url, err)
+	}
+	req.Header.Set("Accept", accept)
+	if c.token != "" && strings.HasPrefix(url, c.url+"/") {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return req, nil
+}
+
+// checkRateLimit returns a *RateLimitError if res was refused because the rate limit was exceeded.
+// GitHub responds with 403 Forbidden and no remaining requests, or 429 Too Many Requests.
+func (c *Client) checkRateLimit(res *http.Response) error {
+	limited := res.StatusCode == http.StatusTooManyRequests ||
+		(res.StatusCode == http.StatusForbidden && res.Header.Get("X-RateLimit-Remaining") == "0")
+	if !limited {
+		return nil
+	}
+	err := &RateLimitError{URL: res.Request.URL.String(), Authenticated: res.Request.Header.Get("Authorization") != ""}
+	if reset, perr := strconv.ParseInt(res.Header.Get("X-RateLimit-Reset"), 10, 64); perr == nil {
+		err.Reset = time.Unix(reset, 0)
+	} else if secs, perr := strconv.Atoi(res.Header.Get("Retry-After")); perr == nil {
+		err.Reset = time.Now().Add(time.Duration(secs) * time.Second)
+	}
+	return err
+}
+
+// cachePath returns the path of the cached response for url.
+func (c *Client) cachePath(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.cacheDir, hex.EncodeToString(sum[:])+".json")
+}
+
+// readCache returns the cached response for url, or nil if there is none.
+func (c *Client) readCache(url string) *cachedResponse {
+	if c.cacheDir == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(c.cachePath(url))
+	if err != nil {
+		return nil
+	}
+	var r cachedResponse
+	// A corrupt entry is treated as missing and replaced by the next response
+	if err := json.Unmarshal(data, &r); err != nil || r.URL != url {
+		return nil
+	}
+	return &r
+}
+
+// writeCache stores r in the cache. The cache is only an optimization, so errors are ignored.
+func (c *Client) writeCache(r *cachedResponse) {
+	if c.cacheDir == "" {
+		return
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(c.cacheDir, 0o755); err != nil {
+		return
+	}
+	// Write to a temp file and rename so concurrent readers never see a partial entry
+	f, err := ioutil.TempFile(c.cacheDir, "tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), c.cachePath(r.URL))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+// FindChecksum returns the hex encoded SHA-256 hash of the file name in sums, the checksums file published
+// with a release in the format written by sha256sum. It returns false if sums has no valid hash for name.
+func FindChecksum(sums []byte, name string) (string, bool) {
+	s := bufio.NewScanner(bytes.NewReader(sums))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		// A '*' before the name means the file was read in binary mode
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != name {
+			continue
+		}
+		if b, err := hex.DecodeString(fields[0]); err != nil || len(b) != sha256.Size {
+			continue
+		}
+		return strings.ToLower(fields[0]), true
+	}
+	return "", false
+}
@@ -0,0 +1,151 @@
+package github_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/getshiphub/shed/github"
+)
+
+func TestGet(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if r.URL.Path != "/repos/foo/bar/releases/latest" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"tag_name": "v1.0.0"}`)) //nolint:errcheck
+	}))
+	defer srv.Close()
+	gh := github.New(srv.Client(), srv.URL, "token")
+
+	var body struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := gh.Get(context.Background(), "/repos/foo/bar/releases/latest", &body); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if body.TagName != "v1.0.0" {
+		t.Errorf("got tag %q, want %q", body.TagName, "v1.0.0")
+	}
+	if auth != "Bearer token" {
+		t.Errorf("got Authorization header %q, want %q", auth, "Bearer token")
+	}
+	if err := gh.Get(context.Background(), "/repos/foo/baz/releases/latest", &body); !errors.Is(err, github.ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, github.ErrNotFound)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	reset := time.Now().Add(30 * time.Minute).Truncate(time.Second)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+	gh := github.New(srv.Client(), srv.URL, "")
+
+	var body struct{}
+	err := gh.Get(context.Background(), "/repos/foo/bar/releases/latest", &body)
+	var rlErr *github.RateLimitError
+	if !errors.As(err, &rlErr) {
+		t.Fatalf("got error %v, want *github.RateLimitError", err)
+	}
+	if !rlErr.Reset.Equal(reset) {
+		t.Errorf("got reset %s, want %s", rlErr.Reset, reset)
+	}
+	if rlErr.Authenticated {
+		t.Error("want request without a token to not be authenticated")
+	}
+	err = gh.Download(context.Background(), srv.URL+"/download/asset.tar.gz", &bytes.Buffer{})
+	if !errors.As(err, &rlErr) {
+		t.Errorf("got error %v, want *github.RateLimitError", err)
+	}
+}
+
+func TestCache(t *testing.T) {
+	requests := 0
+	rateLimited := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if rateLimited {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"tag_name": "v1.0.0"}`)) //nolint:errcheck
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+
+	get := func(gh *github.Client) string {
+		t.Helper()
+		var body struct {
+			TagName string `json:"tag_name"`
+		}
+		if err := gh.Get(context.Background(), "/repos/foo/bar/releases/latest", &body); err != nil {
+			t.Fatalf("want nil error, got %v", err)
+		}
+		return body.TagName
+	}
+
+	// Fresh responses are used without a request
+	gh := github.New(srv.Client(), srv.URL, "").WithCache(dir, time.Hour)
+	for i := 0; i < 2; i++ {
+		if tag := get(gh); tag != "v1.0.0" {
+			t.Errorf("got tag %q, want %q", tag, "v1.0.0")
+		}
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests, want 1", requests)
+	}
+
+	// Old responses are revalidated
+	gh = github.New(srv.Client(), srv.URL, "").WithCache(dir, 0)
+	if tag := get(gh); tag != "v1.0.0" {
+		t.Errorf("got tag %q, want %q", tag, "v1.0.0")
+	}
+	if requests != 2 {
+		t.Errorf("got %d requests, want 2", requests)
+	}
+
+	// Cached responses are used when rate limited
+	rateLimited = true
+	if tag := get(gh); tag != "v1.0.0" {
+		t.Errorf("got tag %q, want %q", tag, "v1.0.0")
+	}
+}
+
+func TestFindChecksum(t *testing.T) {
+	const sum = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	sums := []byte("not a hash  shed_linux_amd64.tar.gz\n" +
+		"9F86D081884C7D659A2FEAA0C55AD015A3BF4F1B2B0B822CD15D6C15B0F00A08 *shed_linux_amd64.tar.gz\n" +
+		sum + "  shed_darwin_amd64.tar.gz\n")
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{"shed_linux_amd64.tar.gz", sum, true},
+		{"shed_darwin_amd64.tar.gz", sum, true},
+		{"shed_windows_amd64.zip", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := github.FindChecksum(sums, tt.name)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("FindChecksum(%q) = %q, %t, want %q, %t", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Package color determines whether or not coloured output should be used.
+//
+// It supports the NO_COLOR (https://no-color.org) and FORCE_COLOR conventions
+// and allows the same decision to be passed on to child processes.
+package color
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+// Mode is the policy for using colour.
+type Mode int
+
+const (
+	// Auto uses colour if the environment allows it and the output is a terminal.
+	Auto Mode = iota
+	// Always always uses colour.
+	Always
+	// Never never uses colour.
+	Never
+)
+
+func (m Mode) String() string {
+	switch m {
+	case Always:
+		return "always"
+	case Never:
+		return "never"
+	default:
+		return "auto"
+	}
+}
+
+// ParseMode parses s into a Mode. s must be one of auto, always, or never.
+// An empty string is treated as auto.
+func ParseMode(s string) (Mode, error) {
+	switch s {
+	case "", "auto":
+		return Auto, nil
+	case "always":
+		return Always, nil
+	case "never":
+		return Never, nil
+	}
+	return Auto, fmt.Errorf("color: invalid mode %q, must be one of auto, always, never", s)
+}
+
+// Enabled reports whether colour should be used when writing to f according to mode.
+//
+// In Auto mode, colour is disabled if NO_COLOR is set to a non-empty value or TERM is 'dumb',
+// and enabled if FORCE_COLOR is set to a non-empty value other than '0'. Otherwise colour is
+// enabled only if f is a terminal.
+func Enabled(mode Mode, f *os.File) bool {
+	switch mode {
+	case Always:
+		return true
+	case Never:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if v := os.Getenv("FORCE_COLOR"); v != "" {
+		return v != "0"
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// colorVars are environment variables that are commonly used by tools to control colour.
+var colorVars = []string{"NO_COLOR", "FORCE_COLOR", "CLICOLOR", "CLICOLOR_FORCE"}
+
+// Environ returns a copy of env with the variables that control colour set so that
+// child processes will follow the decision of enabled. env has the same format as os.Environ.
+func Environ(env []string, enabled bool) []string {
+	out := make([]string, 0, len(env)+2)
+	for _, kv := range env {
+		isColorVar := false
+		for _, v := range colorVars {
+			if strings.HasPrefix(kv, v+"=") {
+				isColorVar = true
+				break
+			}
+		}
+		if !isColorVar {
+			out = append(out, kv)
+		}
+	}
+	if enabled {
+		return append(out, "FORCE_COLOR=1", "CLICOLOR_FORCE=1")
+	}
+	return append(out, "NO_COLOR=1", "CLICOLOR=0")
+}
@@ -0,0 +1,93 @@
+package color_test
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/getshiphub/shed/internal/color"
+)
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		s    string
+		want color.Mode
+	}{
+		{"", color.Auto},
+		{"auto", color.Auto},
+		{"always", color.Always},
+		{"never", color.Never},
+	}
+	for _, tt := range tests {
+		got, err := color.ParseMode(tt.s)
+		if err != nil {
+			t.Errorf("want nil error, got %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("got %s, want %s", got, tt.want)
+		}
+	}
+
+	if _, err := color.ParseMode("sometimes"); err == nil {
+		t.Error("want non-nil error, got nil")
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	// Make sure the process environment doesn't affect the test
+	for _, k := range []string{"NO_COLOR", "FORCE_COLOR", "TERM"} {
+		if v, ok := os.LookupEnv(k); ok {
+			defer os.Setenv(k, v)
+		} else {
+			defer os.Unsetenv(k)
+		}
+		os.Unsetenv(k)
+	}
+
+	// Use a file that definitely isn't a terminal
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", os.DevNull, err)
+	}
+	defer f.Close()
+
+	tests := []struct {
+		name string
+		mode color.Mode
+		env  map[string]string
+		want bool
+	}{
+		{"always", color.Always, map[string]string{"NO_COLOR": "1"}, true},
+		{"never", color.Never, map[string]string{"FORCE_COLOR": "1"}, false},
+		{"auto not a terminal", color.Auto, nil, false},
+		{"auto NO_COLOR", color.Auto, map[string]string{"NO_COLOR": "1", "FORCE_COLOR": "1"}, false},
+		{"auto FORCE_COLOR", color.Auto, map[string]string{"FORCE_COLOR": "1"}, true},
+		{"auto FORCE_COLOR=0", color.Auto, map[string]string{"FORCE_COLOR": "0"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+			if got := color.Enabled(tt.mode, f); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnviron(t *testing.T) {
+	env := []string{"HOME=/home/shed", "NO_COLOR=1", "FORCE_COLOR=", "NO_COLORS=1"}
+	got := color.Environ(env, true)
+	want := []string{"HOME=/home/shed", "NO_COLORS=1", "FORCE_COLOR=1", "CLICOLOR_FORCE=1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	got = color.Environ(env, false)
+	want = []string{"HOME=/home/shed", "NO_COLORS=1", "NO_COLOR=1", "CLICOLOR=0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,77 @@
+package complete
+
+// bundled contains specs for common tools keyed by import path.
+var bundled = map[string]*Spec{
+	"github.com/golangci/golangci-lint/cmd/golangci-lint": {
+		Flags: []Flag{
+			{Name: "--config", Description: "Read config from file path", TakesValue: true},
+			{Name: "-c", Description: "Read config from file path", TakesValue: true},
+			{Name: "--no-config", Description: "Don't read config"},
+			{Name: "--verbose", Description: "Verbose output"},
+			{Name: "-v", Description: "Verbose output"},
+			{Name: "--color", Description: "Use color when printing: always, auto or never", TakesValue: true},
+			{Name: "--help", Description: "Help for golangci-lint"},
+			{Name: "-h", Description: "Help for golangci-lint"},
+		},
+		Commands: []Spec{
+			{
+				Name:        "run",
+				Description: "Run the linters",
+				Flags: []Flag{
+					{Name: "--enable", Description: "Enable specific linter", TakesValue: true},
+					{Name: "-E", Description: "Enable specific linter", TakesValue: true},
+					{Name: "--disable", Description: "Disable specific linter", TakesValue: true},
+					{Name: "-D", Description: "Disable specific linter", TakesValue: true},
+					{Name: "--enable-all", Description: "Enable all linters"},
+					{Name: "--disable-all", Description: "Disable all linters"},
+					{Name: "--fast", Description: "Run only fast linters from enabled linters set"},
+					{Name: "--fix", Description: "Fix found issues (if it's supported by the linter)"},
+					{Name: "--new", Description: "Show only new issues"},
+					{Name: "--new-from-rev", Description: "Show only new issues created after git revision", TakesValue: true},
+					{Name: "--out-format", Description: "Format of output: colored-line-number, line-number, json, tab, checkstyle, code-climate, junit-xml, github-actions", TakesValue: true},
+					{Name: "--timeout", Description: "Timeout for total work", TakesValue: true},
+					{Name: "--tests", Description: "Analyze tests (*_test.go)"},
+					{Name: "--build-tags", Description: "Build tags", TakesValue: true},
+					{Name: "--skip-dirs", Description: "Regexps of directories to skip", TakesValue: true},
+					{Name: "--skip-files", Description: "Regexps of files to skip", TakesValue: true},
+					{Name: "--concurrency", Description: "Concurrency", TakesValue: true},
+					{Name: "-j", Description: "Concurrency", TakesValue: true},
+				},
+			},
+			{Name: "linters", Description: "List current linters configuration"},
+			{
+				Name:        "cache",
+				Description: "Cache control and information",
+				Commands: []Spec{
+					{Name: "clean", Description: "Clean cache"},
+					{Name: "status", Description: "Show cache status"},
+				},
+			},
+			{
+				Name:        "config",
+				Description: "Config",
+				Commands: []Spec{
+					{Name: "path", Description: "Print used config path"},
+				},
+			},
+			{Name: "completion", Description: "Output completion script"},
+			{Name: "help", Description: "Help"},
+			{Name: "version", Description: "Version"},
+		},
+	},
+	"golang.org/x/tools/cmd/stringer": {
+		Flags: []Flag{
+			{Name: "-type", Description: "Comma-separated list of type names", TakesValue: true},
+			{Name: "-output", Description: "Output file name", TakesValue: true},
+			{Name: "-trimprefix", Description: "Trim the prefix from the generated constant names", TakesValue: true},
+			{Name: "-linecomment", Description: "Use line comment text as printed text when present"},
+			{Name: "-tags", Description: "Comma-separated list of build tags to apply", TakesValue: true},
+		},
+	},
+}
+
+// Bundled returns the spec bundled with shed for the tool with the given import path.
+func Bundled(importPath string) (*Spec, bool) {
+	s, ok := bundled[importPath]
+	return s, ok
+}
@@ -0,0 +1,192 @@
+// Package complete provides shell completions for the arguments of tools.
+//
+// Completions are described by a Spec, which lists the flags and subcommands of a tool.
+// Specs can be read from JSON files, and specs for some common tools are bundled with shed.
+// Tools built with cobra can also provide their own completions, which can be parsed
+// with ParseCobra.
+package complete
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Flag is a flag accepted by a tool.
+type Flag struct {
+	// Name is the name of the flag including the leading dashes, ex: '--fix' or '-type'.
+	Name string `json:"name"`
+	// Description is a short description of the flag shown in shells that support it.
+	Description string `json:"description,omitempty"`
+	// TakesValue is true if the flag requires a value. If the value is not provided
+	// using '=' the next argument is the value.
+	TakesValue bool `json:"takesValue,omitempty"`
+}
+
+// Spec describes the arguments accepted by a tool or one of its subcommands.
+// The flags of a command are also accepted by all its subcommands.
+type Spec struct {
+	// Name is the name of the subcommand. It is empty for the tool itself.
+	Name string `json:"name,omitempty"`
+	// Description is a short description of the subcommand shown in shells that support it.
+	Description string `json:"description,omitempty"`
+	// Flags are the flags accepted by the command.
+	Flags []Flag `json:"flags,omitempty"`
+	// Commands are the subcommands of the command.
+	Commands []Spec `json:"commands,omitempty"`
+}
+
+// ParseSpec reads a JSON spec from r and validates it.
+func ParseSpec(r io.Reader) (*Spec, error) {
+	var s Spec
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("complete: failed to parse spec: %w", err)
+	}
+	if err := s.validate(); err != nil {
+		return nil, fmt.Errorf("complete: %w", err)
+	}
+	return &s, nil
+}
+
+func (s *Spec) validate() error {
+	for _, f := range s.Flags {
+		if !strings.HasPrefix(f.Name, "-") || strings.Trim(f.Name, "-") == "" {
+			return fmt.Errorf("invalid flag name %q, must start with '-'", f.Name)
+		}
+	}
+	for i := range s.Commands {
+		c := &s.Commands[i]
+		if c.Name == "" || strings.HasPrefix(c.Name, "-") {
+			return fmt.Errorf("invalid command name %q", c.Name)
+		}
+		if err := c.validate(); err != nil {
+			return fmt.Errorf("command %q: %w", c.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s *Spec) command(name string) *Spec {
+	for i := range s.Commands {
+		if s.Commands[i].Name == name {
+			return &s.Commands[i]
+		}
+	}
+	return nil
+}
+
+// lookupFlag finds the flag with the given name in the commands,
+// starting with the last command since it is the most specific one.
+func lookupFlag(cmds []*Spec, name string) *Flag {
+	for i := len(cmds) - 1; i >= 0; i-- {
+		for j := range cmds[i].Flags {
+			if cmds[i].Flags[j].Name == name {
+				return &cmds[i].Flags[j]
+			}
+		}
+	}
+	return nil
+}
+
+func candidate(name, description string) string {
+	if description == "" {
+		return name
+	}
+	return name + "\t" + description
+}
+
+// Complete returns the completions for toComplete given the arguments before it.
+// Each completion is the value, optionally followed by a tab and a description.
+//
+// If toComplete is the value of a flag, Complete returns nil since the spec
+// does not describe flag values, so the shell should fall back to completing files.
+func (s *Spec) Complete(args []string, toComplete string) []string {
+	cmds := []*Spec{s}
+	cur := s
+	expectValue := false
+	for _, arg := range args {
+		if expectValue {
+			expectValue = false
+			continue
+		}
+		if arg == "--" {
+			// Everything after is a positional argument
+			return nil
+		}
+		if strings.HasPrefix(arg, "-") {
+			if !strings.Contains(arg, "=") {
+				if f := lookupFlag(cmds, arg); f != nil && f.TakesValue {
+					expectValue = true
+				}
+			}
+			continue
+		}
+		if sub := cur.command(arg); sub != nil {
+			cur = sub
+			cmds = append(cmds, sub)
+		}
+	}
+	if expectValue {
+		return nil
+	}
+
+	var completions []string
+	if strings.HasPrefix(toComplete, "-") {
+		if strings.Contains(toComplete, "=") {
+			return nil
+		}
+		seen := make(map[string]bool)
+		for i := len(cmds) - 1; i >= 0; i-- {
+			for _, f := range cmds[i].Flags {
+				if seen[f.Name] || !strings.HasPrefix(f.Name, toComplete) {
+					continue
+				}
+				seen[f.Name] = true
+				completions = append(completions, candidate(f.Name, f.Description))
+			}
+		}
+		return completions
+	}
+	for _, c := range cur.Commands {
+		if strings.HasPrefix(c.Name, toComplete) {
+			completions = append(completions, candidate(c.Name, c.Description))
+		}
+	}
+	return completions
+}
+
+// ParseCobra parses the output of the hidden '__complete' command of a tool built
+// with cobra. It returns the completions and whether the tool requested that files
+// should not be completed.
+func ParseCobra(r io.Reader) (completions []string, noFiles bool, err error) {
+	// cobra's ShellCompDirectiveNoFileComp
+	const noFileComp = 4
+
+	var lines []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		return nil, false, fmt.Errorf("complete: failed to read cobra completions: %w", err)
+	}
+	// The last line is the directive in the form ':<int>'
+	if len(lines) == 0 || !strings.HasPrefix(lines[len(lines)-1], ":") {
+		return nil, false, errors.New("complete: cobra completions are missing a directive")
+	}
+	var directive int
+	if _, err := fmt.Sscanf(lines[len(lines)-1], ":%d", &directive); err != nil {
+		return nil, false, fmt.Errorf("complete: invalid cobra completion directive %q", lines[len(lines)-1])
+	}
+	for _, l := range lines[:len(lines)-1] {
+		if l != "" {
+			completions = append(completions, l)
+		}
+	}
+	return completions, directive&noFileComp != 0, nil
+}
@@ -0,0 +1,108 @@
+package complete_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/getshiphub/shed/internal/complete"
+)
+
+func TestComplete(t *testing.T) {
+	spec, err := complete.ParseSpec(strings.NewReader(`{
+  "flags": [
+    {"name": "--config", "description": "Config file", "takesValue": true},
+    {"name": "--verbose"}
+  ],
+  "commands": [
+    {
+      "name": "run",
+      "description": "Run the linters",
+      "flags": [{"name": "--fix", "description": "Fix issues"}, {"name": "--fast"}]
+    },
+    {"name": "linters"}
+  ]
+}`))
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		args       []string
+		toComplete string
+		want       []string
+	}{
+		{"commands", nil, "", []string{"run\tRun the linters", "linters"}},
+		{"command prefix", nil, "li", []string{"linters"}},
+		{"root flags", nil, "--", []string{"--config\tConfig file", "--verbose"}},
+		{"subcommand flags", []string{"run"}, "--f", []string{"--fix\tFix issues", "--fast"}},
+		{"inherited flags", []string{"run"}, "--v", []string{"--verbose"}},
+		{"skips flag values", []string{"--config", "run"}, "", []string{"run\tRun the linters", "linters"}},
+		{"flag value", []string{"--config"}, "", nil},
+		{"flag with equals", []string{"--config=run"}, "--f", nil},
+		{"after double dash", []string{"--", "run"}, "--f", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := spec.Complete(tt.args, tt.toComplete)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSpecError(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{"invalid json", `{`},
+		{"unknown field", `{"flagz": []}`},
+		{"flag without dash", `{"flags": [{"name": "fix"}]}`},
+		{"command without name", `{"commands": [{"description": "nope"}]}`},
+		{"nested invalid flag", `{"commands": [{"name": "run", "flags": [{"name": "-"}]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := complete.ParseSpec(strings.NewReader(tt.spec)); err == nil {
+				t.Error("want error, got nil")
+			}
+		})
+	}
+}
+
+func TestParseCobra(t *testing.T) {
+	out := "run\tRun the linters\nlinters\n:4\n"
+	completions, noFiles, err := complete.ParseCobra(strings.NewReader(out))
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := []string{"run\tRun the linters", "linters"}
+	if !reflect.DeepEqual(completions, want) {
+		t.Errorf("got %q, want %q", completions, want)
+	}
+	if !noFiles {
+		t.Error("want noFiles to be true")
+	}
+
+	if _, _, err := complete.ParseCobra(strings.NewReader("run\n")); err == nil {
+		t.Error("want error for missing directive, got nil")
+	}
+}
+
+func TestBundled(t *testing.T) {
+	spec, ok := complete.Bundled("github.com/golangci/golangci-lint/cmd/golangci-lint")
+	if !ok {
+		t.Fatal("want bundled spec for golangci-lint")
+	}
+	got := spec.Complete([]string{"run"}, "--fi")
+	want := []string{"--fix\tFix found issues (if it's supported by the linter)"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, ok := complete.Bundled("github.com/cszatmary/go-fish"); ok {
+		t.Error("want no bundled spec for go-fish")
+	}
+}
@@ -0,0 +1,149 @@
+// Package filelock provides advisory file locks that coordinate multiple shed processes,
+// ex: two installs sharing the same cache. Locks are held on a lock file for as long
+// as it is open. They are not reentrant, even within the same process.
+package filelock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrLocked is returned when a lock is held by another process and
+// waiting for it was stopped before it was released.
+var ErrLocked = errors.New("another shed process is running")
+
+// waitError is returned when waiting for a lock is stopped because the context is done.
+// It matches both ErrLocked and the context error with errors.Is.
+type waitError struct {
+	path string
+	err  error
+}
+
+func (e *waitError) Error() string {
+	return fmt.Sprintf("%v: gave up waiting for lock %q: %v", ErrLocked, e.path, e.err)
+}
+
+func (e *waitError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+func (e *waitError) Unwrap() error {
+	return e.err
+}
+
+// errWouldBlock is returned by tryLock when the lock is held.
+var errWouldBlock = errors.New("filelock: lock is held")
+
+// Polling intervals while waiting for a lock. The OS calls that block until a lock
+// is released can't be cancelled, so the lock is tried periodically instead.
+const (
+	minPollInterval = 10 * time.Millisecond
+	maxPollInterval = 500 * time.Millisecond
+)
+
+// Lock is a held lock. Unlock must be called to release it.
+type Lock struct {
+	f *os.File
+}
+
+// Options customizes how a lock is acquired.
+type Options struct {
+	// Shared acquires a shared lock, which can be held by many processes at once,
+	// instead of an exclusive one.
+	Shared bool
+	// Perm is the permissions used if the lock file is created. Defaults to 0o644.
+	Perm os.FileMode
+	// OnWait is called once if the lock is held by another process, before waiting for it.
+	OnWait func()
+}
+
+// Acquire locks the file at path, creating it and its directory if they don't exist.
+// If the lock is held by another process, Acquire waits until it is released or ctx is done,
+// in which case the error matches both ErrLocked and the context error with errors.Is.
+func Acquire(ctx context.Context, path string, opts Options) (*Lock, error) {
+	if opts.Perm == 0 {
+		opts.Perm = 0o644
+	}
+	waited := false
+	interval := minPollInterval
+	for {
+		l, err := tryAcquire(path, opts)
+		if err == nil {
+			return l, nil
+		}
+		if !errors.Is(err, errWouldBlock) {
+			return nil, err
+		}
+		if !waited {
+			waited = true
+			if opts.OnWait != nil {
+				opts.OnWait()
+			}
+		}
+		t := time.NewTimer(interval)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, &waitError{path: path, err: ctx.Err()}
+		}
+		if interval *= 2; interval > maxPollInterval {
+			interval = maxPollInterval
+		}
+	}
+}
+
+// tryAcquire locks the file at path without waiting. It returns errWouldBlock if the lock is held.
+func tryAcquire(path string, opts Options) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("filelock: failed to create directory %q: %w", filepath.Dir(path), err)
+	}
+	// Only read access is needed to lock, so users that can't write the file can still lock it
+	f, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, opts.Perm)
+	if err != nil {
+		return nil, fmt.Errorf("filelock: failed to open file %q: %w", path, err)
+	}
+	if err := lockFile(f, opts.Shared); err != nil {
+		f.Close()
+		if errors.Is(err, errWouldBlock) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("filelock: failed to lock file %q: %w", path, err)
+	}
+
+	// The lock file may have been removed by the previous holder after this process opened it,
+	// in which case the lock is on a file no one else will find, so try again.
+	fi, err := f.Stat()
+	if err != nil {
+		unlockFile(f)
+		f.Close()
+		return nil, fmt.Errorf("filelock: failed to stat file %q: %w", path, err)
+	}
+	if cur, err := os.Stat(path); err != nil || !os.SameFile(fi, cur) {
+		unlockFile(f)
+		f.Close()
+		return tryAcquire(path, opts)
+	}
+	return &Lock{f: f}, nil
+}
+
+// Path returns the path of the lock file.
+func (l *Lock) Path() string {
+	return l.f.Name()
+}
+
+// Unlock releases the lock.
+func (l *Lock) Unlock() error {
+	err := unlockFile(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("filelock: failed to unlock file %q: %w", l.f.Name(), err)
+	}
+	return nil
+}
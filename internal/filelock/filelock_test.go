@@ -0,0 +1,77 @@
+package filelock_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/getshiphub/shed/internal/filelock"
+)
+
+func acquire(t *testing.T, path string, opts filelock.Options) *filelock.Lock {
+	t.Helper()
+	l, err := filelock.Acquire(context.Background(), path, opts)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	return l
+}
+
+func TestAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "locks", "lock")
+	l := acquire(t, path, filelock.Options{})
+
+	waited := 0
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := filelock.Acquire(ctx, path, filelock.Options{Shared: true, OnWait: func() { waited++ }})
+	if !errors.Is(err, filelock.ErrLocked) {
+		t.Errorf("got error %v, want %v", err, filelock.ErrLocked)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want error to wrap %v, got %v", context.DeadlineExceeded, err)
+	}
+	if waited != 1 {
+		t.Errorf("got OnWait called %d times, want 1", waited)
+	}
+
+	// The lock is acquired as soon as it is released
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		l.Unlock()
+	}()
+	l2 := acquire(t, path, filelock.Options{})
+	if err := l2.Unlock(); err != nil {
+		t.Errorf("want nil error, got %v", err)
+	}
+}
+
+func TestAcquireShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	l1 := acquire(t, path, filelock.Options{Shared: true})
+	l2 := acquire(t, path, filelock.Options{Shared: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := filelock.Acquire(ctx, path, filelock.Options{}); !errors.Is(err, filelock.ErrLocked) {
+		t.Errorf("got error %v, want %v", err, filelock.ErrLocked)
+	}
+	l1.Unlock()
+	l2.Unlock()
+	acquire(t, path, filelock.Options{}).Unlock()
+}
+
+func TestAcquireRemoved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	l := acquire(t, path, filelock.Options{})
+	if err := os.Remove(path); err != nil {
+		t.Skipf("lock file can't be removed while locked: %v", err)
+	}
+	// A new lock file is created, so this doesn't wait for the lock on the removed one
+	l2 := acquire(t, path, filelock.Options{})
+	l2.Unlock()
+	l.Unlock()
+}
@@ -0,0 +1,101 @@
+package i18n
+
+// japanese contains the Japanese translations of messages.
+// Keep entries sorted by the English message.
+var japanese = map[string]string{
+	"%d conflicts must be resolved manually":                                                            "%d 件の競合を手動で解決する必要があります",
+	"%d tools are affected by vulnerabilities":                                                          "%d 個のツールが脆弱性の影響を受けています",
+	"%d tools are not reproducible":                                                                     "%d 個のツールは再現可能ではありません",
+	"%d tools have different versions across projects":                                                  "%d 個のツールのバージョンがプロジェクト間で異なります",
+	"%s has the same name as %s from %s. Remove it? [y/N] ":                                             "%s は %s の %s と同じ名前です。削除しますか? [y/N] ",
+	"%s is a release tool, so it has no module versions to list.":                                       "%s はリリースツールのため、一覧表示できるモジュールのバージョンがありません。",
+	"%s is provided by %s, which has not been run on this machine before.\nTrust %s and run it? [y/N] ": "%[1]s は %[2]s が提供していますが、%[2]s はこのマシンでまだ実行されたことがありません。\n%[3]s を信頼して実行しますか? [y/N] ",
+	"%s is too old to build %s. Install go %s or newer":                                                 "%s は古いため %s をビルドできません。go %s 以降をインストールしてください",
+	"--rev can't be used when comparing files":                                                          "ファイルを比較するときは --rev を使用できません",
+	"Add %s, found in %s? [Y/n] ":                                                                       "%s が %s で見つかりました。追加しますか? [Y/n] ",
+	"Debug":                                                                                             "デバッグ",
+	"Detected %d tools, use --yes to add them":                                                          "%d 個のツールを検出しました。追加するには --yes を使用してください",
+	"Did you mean %s?":                                                                                  "%s のことですか？",
+	"Error":                                                                                             "エラー",
+	"Failed executing command.":                                                                         "コマンドの実行に失敗しました。",
+	"Failed to audit tools":                                                                             "ツールを監査できませんでした",
+	"Failed to check for newer versions of tools":                                                       "ツールの新しいバージョンを確認できませんでした",
+	"Failed to clean cache directory":                                                                   "キャッシュディレクトリを削除できませんでした",
+	"Failed to create directory %s":                                                                     "ディレクトリ %s を作成できませんでした",
+	"Failed to create shims":                                                                            "シムを作成できませんでした",
+	"Failed to detect tools":                                                                            "ツールを検出できませんでした",
+	"Failed to determine list of tools to install":                                                      "インストールするツールの一覧を決定できませんでした",
+	"Failed to export tools":                                                                            "ツールをエクスポートできませんでした",
+	"Failed to find global lockfile":                                                                    "グローバルロックファイルが見つかりませんでした",
+	"Failed to find the latest release of shed":                                                         "shed の最新リリースの検索に失敗しました",
+	"Failed to find the path of the shed binary":                                                        "shed バイナリのパスの検索に失敗しました",
+	"Failed to find the required version of Go":                                                         "必要な Go のバージョンを特定できませんでした",
+	"Failed to find user config":                                                                        "ユーザー設定が見つかりませんでした",
+	"Failed to find why %s is used":                                                                     "%s が使用されている理由を特定できませんでした",
+	"Failed to get current working directory":                                                           "現在の作業ディレクトリを取得できませんでした",
+	"Failed to install %s":                                                                              "%s をインストールできませんでした",
+	"Failed to install git hooks":                                                                       "git フックのインストールに失敗しました",
+	"Failed to install missing tools":                                                                   "不足しているツールのインストールに失敗しました",
+	"Failed to install tools":                                                                           "ツールをインストールできませんでした",
+	"Failed to list versions of %s":                                                                     "%s のバージョンの一覧表示に失敗しました",
+	"Failed to merge lockfiles":                                                                         "ロックファイルをマージできませんでした",
+	"Failed to migrate lockfile":                                                                        "ロックファイルを移行できませんでした",
+	"Failed to open workspace":                                                                          "ワークスペースを開けませんでした",
+	"Failed to pin script %s":                                                                           "スクリプト %s を固定できませんでした",
+	"Failed to print tools":                                                                             "ツールの出力に失敗しました",
+	"Failed to prune cache":                                                                             "キャッシュを整理できませんでした",
+	"Failed to read file %s":                                                                            "ファイル %s を読み込めませんでした",
+	"Failed to read user config %s":                                                                     "ユーザー設定 %s を読み込めませんでした",
+	"Failed to rebuild tools":                                                                           "ツールを再ビルドできませんでした",
+	"Failed to reconcile workspace":                                                                     "ワークスペースを調整できませんでした",
+	"Failed to restore tool %s":                                                                         "ツール %s を復元できませんでした",
+	"Failed to run %s":                                                                                  "%s を実行できませんでした",
+	"Failed to run task %s":                                                                             "タスク %s を実行できませんでした",
+	"Failed to serialize changes as JSON":                                                               "変更を JSON にシリアライズできませんでした",
+	"Failed to serialize install summary as JSON":                                                       "インストールの概要を JSON にシリアライズできませんでした",
+	"Failed to setup shed":                                                                              "shed を初期化できませんでした",
+	"Failed to uninstall git hooks":                                                                     "git フックのアンインストールに失敗しました",
+	"Failed to uninstall tools":                                                                         "ツールをアンインストールできませんでした",
+	"Failed to update shed to %s":                                                                       "shed の %s への更新に失敗しました",
+	"Failed to vendor tools":                                                                            "ツールのベンダリングに失敗しました",
+	"Failed to verify tools":                                                                            "ツールを検証できませんでした",
+	"Failed to write file %s":                                                                           "ファイル %s を書き込めませんでした",
+	"Failed to write install summary to %s":                                                             "インストールの概要を %s に書き込めませんでした",
+	"Failed to write lockfile":                                                                          "ロックファイルを書き込めませんでした",
+	"Found %d errors":                                                                                   "%d 個のエラーが見つかりました",
+	"Info":                                                                                              "情報",
+	"Invalid --format":                                                                                  "無効な --format です",
+	"Invalid --platform":                                                                                "--platform が無効です",
+	"Invalid SOURCE_DATE_EPOCH":                                                                         "SOURCE_DATE_EPOCH が無効です",
+	"Invalid color option":                                                                              "color オプションが無効です",
+	"Invalid context":                                                                                   "コンテキストが無効です",
+	"Invalid format %q, must be one of: text, json, or a template":                                      "無効な形式 %q です。text、json、またはテンプレートのいずれかを指定してください",
+	"Invalid language":                                                                                  "言語の設定が無効です",
+	"Invalid merge strategy":                                                                            "マージ戦略が無効です",
+	"Invalid output option":                                                                             "output オプションが無効です",
+	"It could be any of:":                                                                               "次のいずれかの可能性があります:",
+	"Multiple tools named %s found. Specify the full import path of the tool in order to run it.":                                              "%s という名前のツールが複数見つかりました。実行するには、ツールの完全なインポートパスを指定してください。",
+	"Multiple tools named %s found. Specify the full import path of the tool.":                                                                 "%s という名前のツールが複数見つかりました。ツールの完全なインポートパスを指定してください。",
+	"No %s found in %s or any parent directory":                                                                                                "%[2]s またはその親ディレクトリに %[1]s が見つかりません",
+	"No task named %s found in %s.":                                                                                                            "%[2]s に %[1]s という名前のタスクが見つかりません。",
+	"No tool named %s in shed.lock":                                                                                                            "shed.lock に %s という名前のツールはありません",
+	"No tool named %s in shed.lock. Use the full import path of the tool to list its versions.":                                                "shed.lock に %s という名前のツールはありません。バージョンを一覧表示するにはツールの完全なインポートパスを使用してください。",
+	"No tool named %s in shed.lock. Use the full import path of the tool to run it without shed.lock.":                                         "shed.lock に %s という名前のツールはありません。shed.lock なしで実行するにはツールの完全なインポートパスを使用してください。",
+	"No tool named %s installed.":                                                                                                              "%s という名前のツールはインストールされていません。",
+	"No tool named %s installed. Run 'shed install' first to install the tool.":                                                                "%s という名前のツールはインストールされていません。先に 'shed install' を実行してツールをインストールしてください。",
+	"No uninstalled tool named %s":                                                                                                             "%s という名前のアンインストールされたツールはありません",
+	"No version of %s matches %s":                                                                                                              "%s に %s と一致するバージョンはありません",
+	"Not running %s since it is not in the cache. Use --rebuild to add it to the cache without changing shed.lock":                             "%s はキャッシュにないため、実行しません。--rebuild を使用すると shed.lock を変更せずにキャッシュに追加できます",
+	"Not running %s since it is not installed. Run 'shed install' to install it, or use --rebuild to install it automatically":                 "%s はインストールされていないため、実行しません。'shed install' でインストールするか、--rebuild を使用して自動的にインストールしてください",
+	"Not running %s since its binary was modified after it was installed. Run 'shed install' to reinstall it, or use --force to run it anyway": "%s のバイナリがインストール後に変更されたため、実行しません。'shed install' で再インストールするか、--force を使用してそのまま実行してください",
+	"Not running %s since its module was not trusted.":                                                                                         "%s のモジュールが信頼されていないため、実行しません。",
+	"Script %s is not pinned. Run 'shed script pin %s' first to pin it.":                                                                       "スクリプト %[1]s は固定されていません。先に 'shed script pin %[2]s' を実行して固定してください。",
+	"The repository already has git hooks. Add 'shed sync' to them instead":                                                                    "リポジトリには既に git フックがあります。代わりにそれらに 'shed sync' を追加してください",
+	"This is a development build of shed, which can't be updated. Build it again from source instead.":                                         "これは shed の開発ビルドのため、更新できません。代わりにソースから再度ビルドしてください。",
+	"This project requires shed %s, but this is shed %s.\nInstall a version of shed it allows with 'go install'? [y/N] ":                       "このプロジェクトには shed %s が必要ですが、実行中の shed は %s です。\n'go install' で対応するバージョンの shed をインストールしますか? [y/N] ",
+	"Unknown variable %s, run 'shed env --help' to see all variables":                                                                          "不明な変数 %s です。'shed env --help' を実行してすべての変数を確認してください",
+	"Update %s? [y/N/q] ": "%s を更新しますか? [y/N/q] ",
+	"Warning":             "警告",
+	"shed %s was installed with 'go install', update it with 'go install %s@latest' instead.": "shed %s は 'go install' でインストールされました。代わりに 'go install %s@latest' で更新してください。",
+	"shed.lock was written by a newer version of shed":                                        "shed.lock はより新しいバージョンの shed で書き込まれています",
+}
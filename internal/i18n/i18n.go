@@ -0,0 +1,88 @@
+// Package i18n translates the messages shed shows to users.
+//
+// Messages are looked up in a catalog by their English text, which is also used when a message
+// has no translation. This means messages are written in English as usual, and only the catalogs
+// need to change to translate them. Format strings are translated before they are formatted, so
+// translations can use explicit argument indexes, ex: '%[2]s', to reorder the arguments.
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Language is a language messages can be shown in.
+type Language int
+
+const (
+	// English is the language messages are written in. It is the default.
+	English Language = iota
+	// Japanese translates messages to Japanese.
+	Japanese
+)
+
+func (l Language) String() string {
+	switch l {
+	case Japanese:
+		return "ja"
+	default:
+		return "en"
+	}
+}
+
+// catalogs contains the translations of messages for each language other than English.
+var catalogs = map[Language]map[string]string{
+	Japanese: japanese,
+}
+
+// ParseLanguage parses s into a Language. s must be either a language code, ex: 'ja', or a locale, ex: 'ja_JP.UTF-8'.
+// An empty string is treated as English.
+func ParseLanguage(s string) (Language, error) {
+	switch languageCode(s) {
+	case "", "en", "c", "posix":
+		return English, nil
+	case "ja":
+		return Japanese, nil
+	}
+	return English, fmt.Errorf("i18n: unsupported language %q, must be one of en, ja", s)
+}
+
+// languageCode returns the language code of the locale s in lower case, ex: 'ja' for 'ja_JP.UTF-8'.
+func languageCode(s string) string {
+	if i := strings.IndexAny(s, "_-.@"); i >= 0 {
+		s = s[:i]
+	}
+	return strings.ToLower(s)
+}
+
+// FromEnv returns the language of the locale set in the environment. Like gettext, LC_ALL takes
+// precedence over LC_MESSAGES, which takes precedence over LANG. If the language is unsupported,
+// English is used.
+func FromEnv() Language {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		v := os.Getenv(name)
+		if v == "" {
+			continue
+		}
+		lang, err := ParseLanguage(v)
+		if err != nil {
+			return English
+		}
+		return lang
+	}
+	return English
+}
+
+// Translate returns the translation of msg in lang. If there is none, msg is returned.
+func Translate(lang Language, msg string) string {
+	if t, ok := catalogs[lang][msg]; ok {
+		return t
+	}
+	return msg
+}
+
+// Sprintf translates format to lang and then formats it according to fmt.Sprintf.
+func Sprintf(lang Language, format string, a ...interface{}) string {
+	return fmt.Sprintf(Translate(lang, format), a...)
+}
@@ -0,0 +1,104 @@
+package i18n
+
+import (
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestParseLanguage(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Language
+	}{
+		{"", English},
+		{"en", English},
+		{"en_US.UTF-8", English},
+		{"C", English},
+		{"ja", Japanese},
+		{"ja_JP.UTF-8", Japanese},
+		{"ja-JP", Japanese},
+	}
+	for _, tt := range tests {
+		got, err := ParseLanguage(tt.s)
+		if err != nil {
+			t.Errorf("want nil error, got %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("got %s for %q, want %s", got, tt.s, tt.want)
+		}
+	}
+
+	if _, err := ParseLanguage("fr_FR.UTF-8"); err == nil {
+		t.Error("want non-nil error, got nil")
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	// Make sure the process environment doesn't affect the test
+	for _, k := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v, ok := os.LookupEnv(k); ok {
+			defer os.Setenv(k, v)
+		} else {
+			defer os.Unsetenv(k)
+		}
+		os.Unsetenv(k)
+	}
+
+	if got := FromEnv(); got != English {
+		t.Errorf("got %s with no locale, want %s", got, English)
+	}
+	os.Setenv("LANG", "ja_JP.UTF-8")
+	if got := FromEnv(); got != Japanese {
+		t.Errorf("got %s, want %s", got, Japanese)
+	}
+	// LC_ALL takes precedence
+	os.Setenv("LC_ALL", "fr_FR.UTF-8")
+	if got := FromEnv(); got != English {
+		t.Errorf("got %s with unsupported LC_ALL, want %s", got, English)
+	}
+}
+
+func TestSprintf(t *testing.T) {
+	if got, want := Sprintf(Japanese, "No task named %s found in %s.", "lint", "shed.config.json"), "shed.config.json に lint という名前のタスクが見つかりません。"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := Sprintf(English, "No task named %s found in %s.", "lint", "shed.config.json"), "No task named lint found in shed.config.json."; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := Sprintf(Japanese, "Untranslated %s", "message"), "Untranslated message"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// verbRE matches the verbs in a format string, capturing the explicit argument index if there is one.
+var verbRE = regexp.MustCompile(`%(?:\[(\d+)\])?[-+# 0]*\d*(?:\.\d+)?([a-zA-Z%])`)
+
+// formatArgs returns the arguments used by format, as 1-based indexes.
+func formatArgs(format string) map[int]bool {
+	args := make(map[int]bool)
+	next := 1
+	for _, m := range verbRE.FindAllStringSubmatch(format, -1) {
+		if m[2] == "%" {
+			continue
+		}
+		if m[1] != "" {
+			next, _ = strconv.Atoi(m[1])
+		}
+		args[next] = true
+		next++
+	}
+	return args
+}
+
+func TestCatalogArgs(t *testing.T) {
+	for lang, catalog := range catalogs {
+		for msg, translation := range catalog {
+			if got, want := formatArgs(translation), formatArgs(msg); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s translation of %q uses arguments %v, want %v", lang, msg, got, want)
+			}
+		}
+	}
+}
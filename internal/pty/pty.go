@@ -0,0 +1,44 @@
+// Package pty provides support for running commands in a pseudo-terminal.
+//
+// Pseudo-terminals are only supported on Linux and macOS. On other platforms
+// all functions in this package return ErrUnsupported.
+package pty
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// ErrUnsupported is returned when pseudo-terminals are not supported on the current platform.
+var ErrUnsupported = errors.New("pty: unsupported on this platform")
+
+// Start starts c with its stdin, stdout, and stderr connected to a new pseudo-terminal.
+// The returned file is the controlling side of the pseudo-terminal. Reading from it returns
+// the output of c, and writing to it sends input to c. The caller is responsible
+// for closing it once c has exited.
+//
+// c is started in a new session with the pseudo-terminal as its controlling terminal.
+// This means c will receive signals generated by the terminal, such as SIGINT from Ctrl-C.
+func Start(c *exec.Cmd) (*os.File, error) {
+	ptm, pts, err := open()
+	if err != nil {
+		return nil, err
+	}
+	// The child has its own copy of pts after starting so we no longer need it
+	defer pts.Close()
+
+	c.Stdin = pts
+	c.Stdout = pts
+	c.Stderr = pts
+	if c.SysProcAttr == nil {
+		c.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	setControllingTerminal(c.SysProcAttr)
+	if err := c.Start(); err != nil {
+		ptm.Close()
+		return nil, err
+	}
+	return ptm, nil
+}
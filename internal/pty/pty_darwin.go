@@ -0,0 +1,47 @@
+package pty
+
+import (
+	"bytes"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	ioctlReadTermios  = unix.TIOCGETA
+	ioctlWriteTermios = unix.TIOCSETA
+)
+
+func open() (ptm, pts *os.File, err error) {
+	ptm, err = os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fd := ptm.Fd()
+	// grantpt(3) and unlockpt(3)
+	for _, req := range []uintptr{unix.TIOCPTYGRANT, unix.TIOCPTYUNLK} {
+		if _, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, req, 0); errno != 0 {
+			ptm.Close()
+			return nil, nil, errno
+		}
+	}
+	// ptsname(3), the buffer size is defined by the TIOCPTYGNAME ioctl
+	buf := make([]byte, 128)
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, unix.TIOCPTYGNAME, uintptr(unsafe.Pointer(&buf[0])))
+	if errno != 0 {
+		ptm.Close()
+		return nil, nil, errno
+	}
+	if i := bytes.IndexByte(buf, 0); i != -1 {
+		buf = buf[:i]
+	}
+
+	pts, err = os.OpenFile(string(buf), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		ptm.Close()
+		return nil, nil, err
+	}
+	return ptm, pts, nil
+}
@@ -0,0 +1,32 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package pty
+
+import (
+	"os"
+	"syscall"
+)
+
+func open() (ptm, pts *os.File, err error) {
+	return nil, nil, ErrUnsupported
+}
+
+func setControllingTerminal(attr *syscall.SysProcAttr) {}
+
+// InheritSize sets the window size of the pseudo-terminal ptm to be the same as the terminal tty.
+func InheritSize(tty, ptm *os.File) error {
+	return ErrUnsupported
+}
+
+// ProxyResize sets the window size of ptm to the size of tty and keeps it updated
+// whenever tty is resized. The returned function stops proxying window size changes.
+func ProxyResize(tty, ptm *os.File) (stop func()) {
+	return func() {}
+}
+
+// MakeRaw puts the terminal tty in raw mode so that all input is passed through as is.
+// The returned function restores tty to its original state.
+func MakeRaw(tty *os.File) (restore func() error, err error) {
+	return nil, ErrUnsupported
+}
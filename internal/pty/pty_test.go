@@ -0,0 +1,40 @@
+package pty_test
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"github.com/getshiphub/shed/internal/pty"
+)
+
+func TestStart(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("pseudo-terminals are not supported")
+	}
+
+	c := exec.Command("sh", "-c", "if [ -t 0 ] && [ -t 1 ]; then echo tty; fi")
+	ptm, err := pty.Start(c)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	defer ptm.Close()
+
+	buf := &bytes.Buffer{}
+	done := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(buf, ptm)
+		close(done)
+	}()
+	if err := c.Wait(); err != nil {
+		t.Errorf("want nil error, got %v", err)
+	}
+	<-done
+
+	want := "tty\r\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
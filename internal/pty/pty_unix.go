@@ -0,0 +1,81 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package pty
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func setControllingTerminal(attr *syscall.SysProcAttr) {
+	attr.Setsid = true
+	attr.Setctty = true
+	// Ctty is the fd in the child, which is stdin since it is the pts
+	attr.Ctty = 0
+}
+
+// InheritSize sets the window size of the pseudo-terminal ptm to be the same as the terminal tty.
+func InheritSize(tty, ptm *os.File) error {
+	ws, err := unix.IoctlGetWinsize(int(tty.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return err
+	}
+	return unix.IoctlSetWinsize(int(ptm.Fd()), unix.TIOCSWINSZ, ws)
+}
+
+// ProxyResize sets the window size of ptm to the size of tty and keeps it updated
+// whenever tty is resized. The returned function stops proxying window size changes.
+func ProxyResize(tty, ptm *os.File) (stop func()) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGWINCH)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ch:
+				// Ignore error since there's nothing useful that can be done,
+				// the tool will just have the wrong size
+				_ = InheritSize(tty, ptm)
+			case <-done:
+				return
+			}
+		}
+	}()
+	// Make sure the initial size is set
+	ch <- syscall.SIGWINCH
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
+
+// MakeRaw puts the terminal tty in raw mode so that all input is passed through as is.
+// This allows keys like Ctrl-C to be handled by the pseudo-terminal instead of tty.
+// The returned function restores tty to its original state.
+func MakeRaw(tty *os.File) (restore func() error, err error) {
+	fd := int(tty.Fd())
+	orig, err := unix.IoctlGetTermios(fd, ioctlReadTermios)
+	if err != nil {
+		return nil, err
+	}
+
+	// Same as cfmakeraw(3)
+	raw := *orig
+	raw.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	raw.Oflag &^= unix.OPOST
+	raw.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Cflag &^= unix.CSIZE | unix.PARENB
+	raw.Cflag |= unix.CS8
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, ioctlWriteTermios, &raw); err != nil {
+		return nil, err
+	}
+	return func() error {
+		return unix.IoctlSetTermios(fd, ioctlWriteTermios, orig)
+	}, nil
+}
@@ -0,0 +1,94 @@
+// Package ratelimit limits the rate that data is transferred.
+package ratelimit
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Limiter limits the rate of data transferred by all readers that share it.
+// A nil *Limiter is valid and does not limit anything.
+type Limiter struct {
+	bytesPerSec int64
+	mu          sync.Mutex
+	// next is the time when the next transfer is allowed to start.
+	next time.Time
+}
+
+// New creates a Limiter that allows bytesPerSec bytes to be transferred per second.
+// If bytesPerSec is not positive, nil is returned which means there is no limit.
+func New(bytesPerSec int64) *Limiter {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	return &Limiter{bytesPerSec: bytesPerSec}
+}
+
+// chunkSize returns the maximum number of bytes a single read can transfer.
+// This keeps the pauses between reads short, so the rate is smooth.
+func (l *Limiter) chunkSize() int {
+	// Allow up to a tenth of the rate per read
+	n := l.bytesPerSec / 10
+	if n < 1 {
+		n = 1
+	}
+	if n > 1<<20 {
+		n = 1 << 20
+	}
+	return int(n)
+}
+
+// wait reserves n bytes and blocks until they are allowed to be transferred, or ctx is done.
+func (l *Limiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(n) * time.Second / time.Duration(l.bytesPerSec))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type reader struct {
+	ctx context.Context
+	r   io.Reader
+	l   *Limiter
+}
+
+// NewReader returns a reader that reads from r no faster than allowed by l.
+// Reads are aborted with ctx.Err() if ctx becomes done while waiting.
+// If l is nil, r is returned.
+func NewReader(ctx context.Context, r io.Reader, l *Limiter) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &reader{ctx: ctx, r: r, l: l}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if max := r.l.chunkSize(); len(p) > max {
+		p = p[:max]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.l.wait(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
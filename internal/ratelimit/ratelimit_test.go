@@ -0,0 +1,49 @@
+package ratelimit_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getshiphub/shed/internal/ratelimit"
+)
+
+func TestReader(t *testing.T) {
+	data := strings.Repeat("a", 1000)
+	// Reads are at most 400 bytes and the first one isn't delayed,
+	// so the last read must wait for at least 600 bytes, which is 150ms
+	l := ratelimit.New(4000)
+	start := time.Now()
+	got, err := ioutil.ReadAll(ratelimit.NewReader(context.Background(), strings.NewReader(data), l))
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if string(got) != data {
+		t.Errorf("got %d bytes, want %d", len(got), len(data))
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("read took %s, want at least 150ms", elapsed)
+	}
+}
+
+func TestReaderNoLimit(t *testing.T) {
+	r := bytes.NewReader([]byte("data"))
+	if got := ratelimit.NewReader(context.Background(), r, ratelimit.New(0)); got != r {
+		t.Errorf("got %T, want the original reader", got)
+	}
+}
+
+func TestReaderCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// The first read is never delayed, the second must wait for the first
+	r := ratelimit.NewReader(ctx, strings.NewReader(strings.Repeat("a", 100)), ratelimit.New(10))
+	_, err := ioutil.ReadAll(r)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,92 @@
+// Package redact hides private import paths in data that is exported from shed,
+// such as reports, so that it can be shared publicly.
+//
+// Redaction never applies to the lockfile itself, which must always contain the real
+// import paths in order to install tools.
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/getshiphub/shed/config"
+	"github.com/getshiphub/shed/tool"
+	"golang.org/x/mod/module"
+)
+
+// HashPrefix is the prefix of import paths that have been replaced by a hash.
+const HashPrefix = "redacted.invalid/"
+
+type alias struct {
+	prefix string
+	alias  string
+}
+
+// Policy determines how import paths are redacted.
+//
+// A nil *Policy is valid and does not redact anything.
+type Policy struct {
+	private string
+	// aliases sorted from longest to shortest prefix so the most specific one is used
+	aliases []alias
+}
+
+// New creates a Policy from the redaction config. If c is nil, New returns nil.
+func New(c *config.Redaction) *Policy {
+	if c == nil {
+		return nil
+	}
+	p := &Policy{private: strings.Join(c.Private, ",")}
+	for prefix, a := range c.Aliases {
+		p.aliases = append(p.aliases, alias{prefix: strings.TrimSuffix(prefix, "/"), alias: strings.TrimSuffix(a, "/")})
+	}
+	sort.Slice(p.aliases, func(i, j int) bool {
+		return len(p.aliases[i].prefix) > len(p.aliases[j].prefix)
+	})
+	return p
+}
+
+// ImportPath returns the redacted form of importPath.
+//
+// If importPath starts with one of the aliased prefixes, the prefix is replaced by the alias.
+// Otherwise, if importPath matches one of the private patterns, it is replaced by a hash of the
+// import path prefixed by HashPrefix. Any other import path is returned unchanged.
+func (p *Policy) ImportPath(importPath string) string {
+	if p == nil {
+		return importPath
+	}
+	for _, a := range p.aliases {
+		if importPath == a.prefix {
+			return a.alias
+		}
+		if strings.HasPrefix(importPath, a.prefix+"/") {
+			return a.alias + importPath[len(a.prefix):]
+		}
+	}
+	if p.private != "" && module.MatchPrefixPatterns(p.private, importPath) {
+		sum := sha256.Sum256([]byte(importPath))
+		return HashPrefix + hex.EncodeToString(sum[:])[:16]
+	}
+	return importPath
+}
+
+// Tool returns a copy of t with its import path and module path redacted.
+func (p *Policy) Tool(t tool.Tool) tool.Tool {
+	t.ImportPath = p.ImportPath(t.ImportPath)
+	if t.ModulePath != "" {
+		t.ModulePath = p.ImportPath(t.ModulePath)
+	}
+	return t
+}
+
+// String replaces every occurrence of the import path of t in s with the redacted form.
+// This is useful for redacting messages like errors that may contain the import path.
+func (p *Policy) String(t tool.Tool, s string) string {
+	redacted := p.ImportPath(t.ImportPath)
+	if redacted == t.ImportPath {
+		return s
+	}
+	return strings.ReplaceAll(s, t.ImportPath, redacted)
+}
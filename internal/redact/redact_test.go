@@ -0,0 +1,73 @@
+package redact_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/getshiphub/shed/config"
+	"github.com/getshiphub/shed/internal/redact"
+	"github.com/getshiphub/shed/tool"
+)
+
+func TestImportPath(t *testing.T) {
+	p := redact.New(&config.Redaction{
+		Private: []string{"github.com/acme", "*.corp.example.com"},
+		Aliases: map[string]string{
+			"github.com/acme/tools":          "acme-tools",
+			"github.com/acme/tools/internal": "acme-internal/",
+		},
+	})
+	tests := []struct {
+		importPath string
+		want       string
+	}{
+		{"golang.org/x/tools/cmd/stringer", "golang.org/x/tools/cmd/stringer"},
+		{"github.com/acme/tools/cmd/gen", "acme-tools/cmd/gen"},
+		{"github.com/acme/tools/internal/cmd/deploy", "acme-internal/cmd/deploy"},
+		{"github.com/acme/toolsmith/cmd/gen", ""},
+		{"git.corp.example.com/platform/cmd/lint", ""},
+	}
+	for _, tt := range tests {
+		got := p.ImportPath(tt.importPath)
+		if tt.want == "" {
+			// Hashed paths shouldn't contain any of the original path
+			if !strings.HasPrefix(got, redact.HashPrefix) || strings.Contains(got, "acme") || strings.Contains(got, "corp") {
+				t.Errorf("got %q for %s, want hashed import path", got, tt.importPath)
+			}
+			if again := p.ImportPath(tt.importPath); again != got {
+				t.Errorf("got different hashes %q and %q for %s", got, again, tt.importPath)
+			}
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("got %q for %s, want %q", got, tt.importPath, tt.want)
+		}
+	}
+}
+
+func TestNilPolicy(t *testing.T) {
+	p := redact.New(nil)
+	tl := tool.Tool{ImportPath: "github.com/acme/tools/cmd/gen", Version: "v1.0.0"}
+	if got := p.Tool(tl); got != tl {
+		t.Errorf("got %v, want %v", got, tl)
+	}
+}
+
+func TestToolModulePath(t *testing.T) {
+	p := redact.New(&config.Redaction{Aliases: map[string]string{"github.com/acme": "acme"}})
+	tl := tool.Tool{ImportPath: "github.com/acme/tools/cmd/gen", Version: "v1.0.0", ModulePath: "github.com/acme/tools"}
+	want := tool.Tool{ImportPath: "acme/tools/cmd/gen", Version: "v1.0.0", ModulePath: "acme/tools"}
+	if got := p.Tool(tl); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestString(t *testing.T) {
+	p := redact.New(&config.Redaction{Aliases: map[string]string{"github.com/acme": "acme"}})
+	tl := tool.Tool{ImportPath: "github.com/acme/cmd/gen", Version: "v1.0.0"}
+	got := p.String(tl, "failed to run tool github.com/acme/cmd/gen@v1.0.0: exit status 1")
+	want := "failed to run tool acme/cmd/gen@v1.0.0: exit status 1"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,155 @@
+// Package retry retries operations that use the network when they fail with transient errors.
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Policy controls how many times an operation is attempted and how long to wait between attempts.
+// The zero value attempts operations once.
+type Policy struct {
+	// Attempts is the maximum number of times an operation is attempted, including the first.
+	// Values less than 1 are the same as 1.
+	Attempts int
+	// BaseDelay is how long to wait before the second attempt. It doubles for each attempt after that.
+	BaseDelay time.Duration
+	// Jitter is the fraction of each delay that is randomized, between 0 and 1, so that many processes
+	// retrying at once don't all hit the server at the same time. 0.5 means a delay of 1s is between 0.5s and 1.5s.
+	Jitter float64
+}
+
+// Delay returns how long to wait after the given attempt fails, before the next attempt.
+// attempt starts at 1.
+func (p Policy) Delay(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < time.Hour; i++ {
+		d *= 2
+	}
+	if p.Jitter > 0 {
+		j := p.Jitter
+		if j > 1 {
+			j = 1
+		}
+		d = time.Duration(float64(d) * (1 - j + 2*j*rand.Float64()))
+	}
+	return d
+}
+
+// RetryFunc is called before an operation is retried with the attempt that failed, starting at 1,
+// the delay before the next attempt, and the error of the attempt that failed.
+type RetryFunc func(attempt int, delay time.Duration, err error)
+
+// Do calls fn until it succeeds, returns an error that isn't transient, see IsTransient,
+// or the attempts of p are used up. If onRetry is not nil, it is called before each retry.
+// If ctx becomes done while waiting, the error of the last attempt is returned.
+func (p Policy) Do(ctx context.Context, fn func() error, onRetry RetryFunc) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.Attempts || !IsTransient(err) || ctx.Err() != nil {
+			return err
+		}
+		delay := p.Delay(attempt)
+		if onRetry != nil {
+			onRetry(attempt, delay, err)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// transientError marks an error as transient.
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transientError) Unwrap() error {
+	return e.err
+}
+
+// Transient marks err as transient, so that IsTransient returns true for it and errors that wrap it.
+// It is used for errors that can't be recognized otherwise, ex: an HTTP response with status 503.
+// If err is nil, nil is returned.
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &transientError{err}
+}
+
+// transientMessages are parts of error messages that mean the error was caused by a problem with
+// the network or a server that may go away, ex: in the output of the go command, which only has text.
+var transientMessages = []string{
+	"connection refused",
+	"connection reset by peer",
+	"broken pipe",
+	"i/o timeout",
+	"TLS handshake timeout",
+	"temporary failure in name resolution",
+	"server misbehaving",
+	"unexpected EOF",
+	"429 Too Many Requests",
+	"500 Internal Server Error",
+	"502 Bad Gateway",
+	"503 Service Unavailable",
+	"504 Gateway Timeout",
+}
+
+// fatalMessages are parts of error messages that mean retrying won't help, even if the message
+// also contains a transient message, ex: the go command reporting a bad checksum of a download.
+var fatalMessages = []string{
+	"SECURITY ERROR",
+	"checksum mismatch",
+	"404 Not Found",
+	"410 Gone",
+	"unknown revision",
+	"invalid version",
+}
+
+// IsTransient reports whether err was caused by a problem that may go away if the operation is retried,
+// like a dropped connection or a server that is temporarily unavailable. Errors are recognized by their
+// type, or by their message for errors that are only text, like the output of the go command.
+// Context errors are never transient, since the operation was canceled.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var te *transientError
+	if errors.As(err, &te) {
+		return true
+	}
+	msg := err.Error()
+	for _, m := range fatalMessages {
+		if strings.Contains(msg, m) {
+			return false
+		}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	for _, m := range transientMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
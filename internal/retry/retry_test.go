@@ -0,0 +1,108 @@
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/getshiphub/shed/internal/retry"
+)
+
+func TestDo(t *testing.T) {
+	p := retry.Policy{Attempts: 3, BaseDelay: time.Millisecond}
+	calls := 0
+	var retried []int
+	err := p.Do(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return retry.Transient(errors.New("503 Service Unavailable"))
+		}
+		return nil
+	}, func(attempt int, delay time.Duration, err error) {
+		retried = append(retried, attempt)
+	})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if calls != 3 || len(retried) != 2 {
+		t.Errorf("got %d calls and retries %v, want 3 calls and 2 retries", calls, retried)
+	}
+}
+
+func TestDoFatal(t *testing.T) {
+	p := retry.Policy{Attempts: 3, BaseDelay: time.Millisecond}
+	calls := 0
+	fatal := errors.New("unknown revision v9.9.9")
+	err := p.Do(context.Background(), func() error {
+		calls++
+		return fatal
+	}, nil)
+	if err != fatal {
+		t.Errorf("got error %v, want %v", err, fatal)
+	}
+	if calls != 1 {
+		t.Errorf("got %d calls, want 1", calls)
+	}
+}
+
+func TestDoAttemptsUsedUp(t *testing.T) {
+	p := retry.Policy{Attempts: 2, BaseDelay: time.Millisecond}
+	calls := 0
+	err := p.Do(context.Background(), func() error {
+		calls++
+		return fmt.Errorf("read: %w", errors.New("connection reset by peer"))
+	}, nil)
+	if err == nil || calls != 2 {
+		t.Errorf("got error %v after %d calls, want an error after 2 calls", err, calls)
+	}
+}
+
+func TestDoCanceled(t *testing.T) {
+	p := retry.Policy{Attempts: 3, BaseDelay: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := p.Do(ctx, func() error {
+		calls++
+		return retry.Transient(errors.New("i/o timeout"))
+	}, func(attempt int, delay time.Duration, err error) {
+		cancel()
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("got error %v after %d calls, want an error after 1 call", err, calls)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("failed to run 'go get', stderr: dial tcp: lookup proxy.golang.org: i/o timeout"), true},
+		{errors.New("failed to run 'go get', stderr: reading https://proxy.golang.org/x/@v/list: 502 Bad Gateway"), true},
+		{errors.New("failed to run 'go get', stderr: reading https://proxy.golang.org/x/@v/v9.mod: 404 Not Found"), false},
+		{errors.New("verifying module: checksum mismatch"), false},
+		{retry.Transient(errors.New("429 Too Many Requests")), true},
+		{fmt.Errorf("aborted: %w", context.Canceled), false},
+		{errors.New("cannot find package"), false},
+	}
+	for _, tt := range tests {
+		if got := retry.IsTransient(tt.err); got != tt.want {
+			t.Errorf("IsTransient(%q) = %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestDelay(t *testing.T) {
+	p := retry.Policy{BaseDelay: time.Second}
+	if got := p.Delay(3); got != 4*time.Second {
+		t.Errorf("got delay %s, want 4s", got)
+	}
+	p.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		if got := p.Delay(1); got < 500*time.Millisecond || got > 1500*time.Millisecond {
+			t.Errorf("got delay %s, want between 0.5s and 1.5s", got)
+		}
+	}
+}
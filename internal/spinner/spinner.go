@@ -56,6 +56,11 @@ type Options struct {
 	// PersistMessages is whether or not messages should be persisted to Out when the message
 	// is updated. By default messages are not persisted and are replaced.
 	PersistMessages bool
+	// Plain makes a TTYSpinner write labeled lines instead of animating, even if Out is a tty.
+	// Each message is written as 'Status: <message>' and each increment of the progress as
+	// 'Progress: <completed> of <count>'. It is meant for screen readers, which can't follow
+	// output that is erased and rewritten. It has no effect on a Spinner.
+	Plain bool
 }
 
 // New creates a new spinner instance using the given options.
@@ -13,6 +13,7 @@ import (
 type TTYSpinner struct {
 	*Spinner
 	isaTTY bool
+	plain  bool
 }
 
 type fder interface {
@@ -21,8 +22,8 @@ type fder interface {
 
 // NewTTY creates a new TTYSpinner instance.
 func NewTTY(opts Options) *TTYSpinner {
-	s := &TTYSpinner{Spinner: New(opts)}
-	if f, ok := s.out.(fder); ok {
+	s := &TTYSpinner{Spinner: New(opts), plain: opts.Plain}
+	if f, ok := s.out.(fder); ok && !s.plain {
 		s.isaTTY = isatty.IsTerminal(f.Fd())
 	}
 	if !s.isaTTY {
@@ -57,6 +58,24 @@ func (s *TTYSpinner) UpdateMessage(m string) {
 	s.writeMsg()
 }
 
+// Inc increments the progress of the spinner. In plain mode, the progress is written to out
+// if there is more than one item, otherwise it is only shown by the spinner animation.
+func (s *TTYSpinner) Inc() {
+	if !s.plain {
+		s.Spinner.Inc()
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.completed >= s.count {
+		return
+	}
+	s.completed++
+	if s.count > 1 {
+		fmt.Fprintf(s.out, "Progress: %d of %d\n", s.completed, s.count)
+	}
+}
+
 func (s *TTYSpinner) Write(p []byte) (int, error) {
 	if s.isaTTY {
 		return s.Spinner.Write(p)
@@ -79,5 +98,9 @@ func (s *TTYSpinner) writeMsg() {
 		return
 	}
 	// First char is always a space
+	if s.plain {
+		fmt.Fprintf(s.out, "Status: %s\n", s.msg[1:])
+		return
+	}
 	fmt.Fprintln(s.out, s.msg[1:])
 }
@@ -25,3 +25,25 @@ func TestTTYSpinner(t *testing.T) {
 		t.Errorf("got %q, want %q", got, want)
 	}
 }
+
+func TestTTYSpinnerPlain(t *testing.T) {
+	out := &syncBuffer{}
+	s := spinner.NewTTY(spinner.Options{
+		Out:     out,
+		Message: "Installing tools",
+		Count:   2,
+		Plain:   true,
+	})
+	s.Start()
+	s.Inc()
+	fmt.Fprint(s, "Some debug info")
+	s.Inc()
+	s.Inc()
+	s.Stop()
+
+	got := out.String()
+	want := "Status: Installing tools\nProgress: 1 of 2\nSome debug info\nProgress: 2 of 2\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
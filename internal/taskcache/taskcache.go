@@ -0,0 +1,439 @@
+// Package taskcache provides a cache for the outputs of tasks.
+// Entries are keyed by a hash of everything that can affect the outputs of a task,
+// including the contents of its input files. This allows tasks to be skipped
+// if nothing has changed since the last time they were run.
+package taskcache
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// hashVersion is included in every hash so that changes to the way
+// hashes are computed invalidate existing entries.
+const hashVersion = "v1"
+
+// manifestName is the name of the file describing a cache entry.
+const manifestName = "manifest.json"
+
+// Key contains everything that determines the outputs of a task,
+// except for the contents of its inputs.
+type Key struct {
+	// Task is the name of the task.
+	Task string
+	// Tool is the tool run by the task, including its version.
+	Tool string
+	// Args are the arguments passed to the tool.
+	Args []string
+	// Dir is the directory the tool is run from.
+	Dir string
+	// Inputs are glob patterns matching the files read by the task.
+	Inputs []string
+	// Outputs are glob patterns matching the files written by the task.
+	Outputs []string
+}
+
+// Hash computes the hash for key using the contents of the files under root
+// that match key.Inputs.
+func Hash(root string, key Key) (string, error) {
+	files, err := Glob(root, key.Inputs)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	// Use NUL as a separator since it can't appear in any of the values
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00", hashVersion, key.Task, key.Tool, key.Dir)
+	for _, section := range [][]string{key.Args, key.Inputs, key.Outputs} {
+		fmt.Fprintf(h, "%d\x00%s\x00", len(section), strings.Join(section, "\x00"))
+	}
+	for _, f := range files {
+		sum, err := hashFile(filepath.Join(root, filepath.FromSlash(f)))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%s\x00", f, sum)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", fmt.Errorf("taskcache: failed to open %s: %w", p, err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("taskcache: failed to read %s: %w", p, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Glob returns the paths of all files under root that match at least one of patterns.
+// Patterns use the syntax of path.Match with the addition of '**' which matches zero or
+// more directories. The returned paths are relative to root, use forward slashes, and are sorted.
+//
+// The .git and .shed directories are never matched.
+func Glob(root string, patterns []string) ([]string, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	var matches []string
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if info.IsDir() {
+			if name := info.Name(); rel != "." && (name == ".git" || name == ".shed") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		for _, pattern := range patterns {
+			ok, err := Match(pattern, rel)
+			if err != nil {
+				return err
+			}
+			if ok {
+				matches = append(matches, rel)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("taskcache: failed to find files in %s: %w", root, err)
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// Match reports whether name matches pattern. Both must use forward slashes.
+// See Glob for details on the pattern syntax.
+func Match(pattern, name string) (bool, error) {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Try matching the rest of the pattern against every suffix of name
+			for i := 0; i <= len(name); i++ {
+				if ok, err := matchSegments(pattern[1:], name[i:]); ok || err != nil {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+		if len(name) == 0 {
+			return false, nil
+		}
+		ok, err := path.Match(pattern[0], name[0])
+		if err != nil || !ok {
+			return false, err
+		}
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+	return len(name) == 0, nil
+}
+
+// File is a file stored in a cache entry.
+type File struct {
+	// Path is the path of the file relative to the project root using forward slashes.
+	Path string `json:"path"`
+	// Mode is the permission bits of the file.
+	Mode os.FileMode `json:"mode"`
+}
+
+// Manifest describes the contents of a cache entry.
+type Manifest struct {
+	Task      string    `json:"task"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"createdAt"`
+	Outputs   []File    `json:"outputs"`
+}
+
+// Cache stores the outputs of tasks in an OS filesystem directory.
+type Cache struct {
+	dir string
+}
+
+// New creates a new Cache instance that uses the directory dir.
+func New(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// Dir returns the OS filesystem directory used by this Cache.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+func (c *Cache) entryDir(hash string) string {
+	return filepath.Join(c.dir, hash)
+}
+
+// Restore copies the outputs stored in the entry for hash to root.
+// It returns false if no entry exists for hash.
+func (c *Cache) Restore(hash, root string) (bool, error) {
+	dir := c.entryDir(hash)
+	m, err := readManifest(dir, hash)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, f := range m.Outputs {
+		src := filepath.Join(dir, "outputs", filepath.FromSlash(f.Path))
+		dst := filepath.Join(root, filepath.FromSlash(f.Path))
+		if err := copyFile(src, dst, f.Mode); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// readManifest reads the manifest of the entry for hash in dir. Entries may be imported from an
+// untrusted source, so it fails if an output would be restored outside of the project root.
+// If the manifest doesn't exist, the error satisfies os.IsNotExist.
+func readManifest(dir, hash string) (Manifest, error) {
+	var m Manifest
+	data, err := ioutil.ReadFile(filepath.Join(dir, manifestName))
+	if os.IsNotExist(err) {
+		return m, err
+	}
+	if err != nil {
+		return m, fmt.Errorf("taskcache: failed to read manifest for %s: %w", hash, err)
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("taskcache: invalid manifest for %s: %w", hash, err)
+	}
+	for _, f := range m.Outputs {
+		if !isLocalPath(f.Path) {
+			return m, fmt.Errorf("taskcache: manifest for %s contains invalid path %q", hash, f.Path)
+		}
+	}
+	return m, nil
+}
+
+// isLocalPath reports whether the slash-separated path name stays within the directory it is relative to.
+func isLocalPath(name string) bool {
+	name = path.Clean(name)
+	if path.IsAbs(name) || name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return false
+	}
+	// Check the OS path too, since it may be absolute or contain '..' separated by '\' on Windows
+	p := filepath.Clean(filepath.FromSlash(name))
+	return !filepath.IsAbs(p) && filepath.VolumeName(p) == "" && p != ".." && !strings.HasPrefix(p, ".."+string(filepath.Separator))
+}
+
+// Save creates an entry for hash containing the files under root that match outputs.
+// If an entry already exists it is replaced.
+func (c *Cache) Save(hash, task, root string, outputs []string) error {
+	files, err := Glob(root, outputs)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("taskcache: failed to create directory %s: %w", c.dir, err)
+	}
+	// Write to a temp dir and rename so that a partially written entry is never used
+	tmpDir, err := ioutil.TempDir(c.dir, "tmp-")
+	if err != nil {
+		return fmt.Errorf("taskcache: failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	m := Manifest{Task: task, Hash: hash, CreatedAt: time.Now().UTC(), Outputs: []File{}}
+	for _, f := range files {
+		src := filepath.Join(root, filepath.FromSlash(f))
+		info, err := os.Stat(src)
+		if err != nil {
+			return fmt.Errorf("taskcache: failed to stat %s: %w", src, err)
+		}
+		if err := copyFile(src, filepath.Join(tmpDir, "outputs", filepath.FromSlash(f)), info.Mode().Perm()); err != nil {
+			return err
+		}
+		m.Outputs = append(m.Outputs, File{Path: f, Mode: info.Mode().Perm()})
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("taskcache: failed to serialize manifest: %w", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, manifestName), data, 0o644); err != nil {
+		return fmt.Errorf("taskcache: failed to write manifest: %w", err)
+	}
+
+	dst := c.entryDir(hash)
+	if err := os.RemoveAll(dst); err != nil {
+		return fmt.Errorf("taskcache: failed to remove existing entry %s: %w", hash, err)
+	}
+	if err := os.Rename(tmpDir, dst); err != nil {
+		return fmt.Errorf("taskcache: failed to create entry %s: %w", hash, err)
+	}
+	return nil
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("taskcache: failed to open %s: %w", src, err)
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("taskcache: failed to create directory for %s: %w", dst, err)
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return fmt.Errorf("taskcache: failed to create %s: %w", dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("taskcache: failed to copy %s to %s: %w", src, dst, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("taskcache: failed to write %s: %w", dst, err)
+	}
+	return nil
+}
+
+// Export writes the entry for hash to w as a gzipped tar archive so that it can be shared.
+// It returns false if no entry exists for hash.
+func (c *Cache) Export(hash string, w io.Writer) (bool, error) {
+	dir := c.entryDir(hash)
+	if _, err := os.Stat(filepath.Join(dir, manifestName)); os.IsNotExist(err) {
+		return false, nil
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{
+			Name: filepath.ToSlash(rel),
+			Mode: int64(info.Mode().Perm()),
+			Size: info.Size(),
+			// Use a fixed time so archives of the same entry are identical
+			ModTime:  time.Unix(0, 0),
+			Typeflag: tar.TypeReg,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("taskcache: failed to export entry %s: %w", hash, err)
+	}
+	if err := tw.Close(); err != nil {
+		return false, fmt.Errorf("taskcache: failed to export entry %s: %w", hash, err)
+	}
+	if err := gw.Close(); err != nil {
+		return false, fmt.Errorf("taskcache: failed to export entry %s: %w", hash, err)
+	}
+	return true, nil
+}
+
+// Import reads an archive created by Export from r and stores it as the entry for hash.
+// If an entry already exists it is replaced.
+func (c *Cache) Import(hash string, r io.Reader) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("taskcache: failed to create directory %s: %w", c.dir, err)
+	}
+	tmpDir, err := ioutil.TempDir(c.dir, "tmp-")
+	if err != nil {
+		return fmt.Errorf("taskcache: failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("taskcache: invalid archive for entry %s: %w", hash, err)
+	}
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("taskcache: invalid archive for entry %s: %w", hash, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		// The archive may come from an untrusted source so make sure
+		// it can't write outside of the entry
+		if !isLocalPath(hdr.Name) {
+			return fmt.Errorf("taskcache: archive for entry %s contains invalid path %q", hash, hdr.Name)
+		}
+		name := path.Clean(hdr.Name)
+		p := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return fmt.Errorf("taskcache: failed to create directory for %s: %w", p, err)
+		}
+		f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(hdr.Mode).Perm())
+		if err != nil {
+			return fmt.Errorf("taskcache: failed to create %s: %w", p, err)
+		}
+		if _, err := io.Copy(f, tr); err != nil {
+			f.Close()
+			return fmt.Errorf("taskcache: failed to write %s: %w", p, err)
+		}
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("taskcache: failed to write %s: %w", p, err)
+		}
+	}
+	if _, err := readManifest(tmpDir, hash); os.IsNotExist(err) {
+		return fmt.Errorf("taskcache: archive for entry %s is missing a manifest", hash)
+	} else if err != nil {
+		return err
+	}
+
+	dst := c.entryDir(hash)
+	if err := os.RemoveAll(dst); err != nil {
+		return fmt.Errorf("taskcache: failed to remove existing entry %s: %w", hash, err)
+	}
+	if err := os.Rename(tmpDir, dst); err != nil {
+		return fmt.Errorf("taskcache: failed to create entry %s: %w", hash, err)
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package taskcache_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/getshiphub/shed/internal/taskcache"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "cmd/shed/main.go", true},
+		{"proto/**", "proto/a/b.proto", true},
+		{"proto/**", "api/b.proto", false},
+		{"a/**/b/*.txt", "a/x/y/b/c.txt", true},
+		{"a/**/b/*.txt", "a/b/c.txt", true},
+		{"a/**/b/*.txt", "a/b/c/d.txt", false},
+	}
+	for _, tt := range tests {
+		got, err := taskcache.Match(tt.pattern, tt.name)
+		if err != nil {
+			t.Errorf("Match(%q, %q): want nil error, got %v", tt.pattern, tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("Match(%q, %q): got %t, want %t", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := ioutil.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+}
+
+func TestGlob(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"main.go":          "",
+		"pill.go":          "",
+		"pill_string.go":   "",
+		"internal/a/a.go":  "",
+		"README.md":        "",
+		".shed/tasks/x.go": "",
+		".git/objects.go":  "",
+	})
+
+	got, err := taskcache.Glob(root, []string{"**/*.go", "README.md"})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := []string{"README.md", "internal/a/a.go", "main.go", "pill.go", "pill_string.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestHash(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"pill.go": "package pill"})
+	key := taskcache.Key{
+		Task:   "gen",
+		Tool:   "golang.org/x/tools/cmd/stringer@v0.1.0",
+		Args:   []string{"-type=Pill"},
+		Inputs: []string{"*.go"},
+	}
+
+	h1, err := taskcache.Hash(root, key)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	h2, err := taskcache.Hash(root, key)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if h1 != h2 {
+		t.Errorf("got different hashes for same inputs: %s, %s", h1, h2)
+	}
+
+	changedKey := key
+	changedKey.Tool = "golang.org/x/tools/cmd/stringer@v0.2.0"
+	h3, err := taskcache.Hash(root, changedKey)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if h3 == h1 {
+		t.Error("want hash to change when tool version changes")
+	}
+
+	writeFiles(t, root, map[string]string{"pill.go": "package pills"})
+	h4, err := taskcache.Hash(root, key)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if h4 == h1 {
+		t.Error("want hash to change when input contents change")
+	}
+}
+
+func TestSaveRestore(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"gen/a.pb.go":   "a",
+		"gen/b/b.pb.go": "b",
+		"main.go":       "main",
+	})
+	c := taskcache.New(filepath.Join(root, ".shed", "tasks"))
+
+	ok, err := c.Restore("abc", root)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if ok {
+		t.Error("want no entry before saving")
+	}
+
+	if err := c.Save("abc", "protoc", root, []string{"gen/**/*.pb.go"}); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := os.RemoveAll(filepath.Join(root, "gen")); err != nil {
+		t.Fatalf("failed to remove outputs: %v", err)
+	}
+
+	ok, err = c.Restore("abc", root)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if !ok {
+		t.Fatal("want entry to exist after saving")
+	}
+	for name, want := range map[string]string{"gen/a.pb.go": "a", "gen/b/b.pb.go": "b"} {
+		data, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("failed to read restored file %s: %v", name, err)
+			continue
+		}
+		if string(data) != want {
+			t.Errorf("got %q for %s, want %q", data, name, want)
+		}
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"gen/a.pb.go": "a"})
+	src := taskcache.New(filepath.Join(root, ".shed", "tasks"))
+	if err := src.Save("abc", "protoc", root, []string{"gen/*.go"}); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	ok, err := src.Export("abc", &buf)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if !ok {
+		t.Fatal("want entry to be exported")
+	}
+	if ok, _ := src.Export("def", ioutil.Discard); ok {
+		t.Error("want missing entry to not be exported")
+	}
+
+	otherRoot := t.TempDir()
+	dst := taskcache.New(filepath.Join(otherRoot, ".shed", "tasks"))
+	if err := dst.Import("abc", &buf); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	ok, err = dst.Restore("abc", otherRoot)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if !ok {
+		t.Fatal("want imported entry to exist")
+	}
+	data, err := ioutil.ReadFile(filepath.Join(otherRoot, "gen", "a.pb.go"))
+	if err != nil {
+		t.Fatalf("failed to read restored file: %v", err)
+	}
+	if string(data) != "a" {
+		t.Errorf("got %q, want %q", data, "a")
+	}
+}
+
+func TestImportMaliciousManifest(t *testing.T) {
+	// The manifest points outside the project root at a file stored next to it in the entry
+	manifest := `{"task": "protoc", "hash": "abc", "outputs": [{"path": "../evil", "mode": 420}]}`
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range map[string]string{"manifest.json": manifest, "evil": "evil"} {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("failed to write header: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar writer: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("failed to close gzip writer: %v", err)
+	}
+
+	td := t.TempDir()
+	root := filepath.Join(td, "project")
+	c := taskcache.New(filepath.Join(root, ".shed", "tasks"))
+	if err := c.Import("abc", &buf); err == nil {
+		t.Error("want error importing a manifest with a path outside the root, got nil")
+	}
+	if _, err := os.Stat(filepath.Join(c.Dir(), "abc")); !os.IsNotExist(err) {
+		t.Errorf("want entry to not be created, got %v", err)
+	}
+
+	// Entries that are already in the cache are checked when restoring
+	writeFiles(t, filepath.Join(c.Dir(), "abc"), map[string]string{"manifest.json": manifest, "evil": "evil"})
+	if _, err := c.Restore("abc", root); err == nil {
+		t.Error("want error restoring a manifest with a path outside the root, got nil")
+	}
+	if _, err := os.Stat(filepath.Join(td, "evil")); !os.IsNotExist(err) {
+		t.Errorf("want no file to be written outside the root, got %v", err)
+	}
+}
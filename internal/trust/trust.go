@@ -0,0 +1,97 @@
+// Package trust records which module origins the user has trusted to run tools from,
+// so that shed can ask before running a tool from a module it has never run before.
+package trust
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileName is the name of the file in the state directory that records trusted modules.
+const FileName = "trusted.json"
+
+// Store records the modules that have been trusted. It is backed by a JSON file.
+// A Store is safe for concurrent use by multiple goroutines.
+type Store struct {
+	path string
+	mu   sync.Mutex
+}
+
+// Entry is a trusted module.
+type Entry struct {
+	// Time is when the module was trusted.
+	Time time.Time `json:"time"`
+}
+
+type storeSchema struct {
+	Modules map[string]Entry `json:"modules"`
+}
+
+// New returns a Store backed by the file at path. The file is created when the first module is trusted.
+func New(path string) *Store {
+	return &Store{path: path}
+}
+
+func (s *Store) read() (storeSchema, error) {
+	schema := storeSchema{Modules: make(map[string]Entry)}
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return schema, nil
+	} else if err != nil {
+		return schema, fmt.Errorf("trust: failed to read file %q: %w", s.path, err)
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return schema, fmt.Errorf("trust: failed to parse file %q: %w", s.path, err)
+	}
+	if schema.Modules == nil {
+		schema.Modules = make(map[string]Entry)
+	}
+	return schema, nil
+}
+
+// Trusted reports whether mod has been trusted.
+func (s *Store) Trusted(mod string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schema, err := s.read()
+	if err != nil {
+		return false, err
+	}
+	_, ok := schema.Modules[mod]
+	return ok, nil
+}
+
+// Trust records that mod is trusted.
+func (s *Store) Trust(mod string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schema, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := schema.Modules[mod]; ok {
+		return nil
+	}
+	schema.Modules[mod] = Entry{Time: time.Now().UTC()}
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return fmt.Errorf("trust: failed to serialize trusted modules: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("trust: failed to create directory %q: %w", filepath.Dir(s.path), err)
+	}
+	// Write to a temp file and rename so the file is never partially written
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("trust: failed to write file %q: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("trust: failed to write file %q: %w", s.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package trust_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/getshiphub/shed/internal/trust"
+)
+
+func TestStore(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "state", trust.FileName)
+	s := trust.New(p)
+	ok, err := s.Trusted("golang.org/x/tools")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if ok {
+		t.Error("want module to not be trusted before it is recorded")
+	}
+
+	if err := s.Trust("golang.org/x/tools"); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	// Decisions are persisted, so a new store sees them
+	ok, err = trust.New(p).Trusted("golang.org/x/tools")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if !ok {
+		t.Error("want module to be trusted after it is recorded")
+	}
+	ok, err = trust.New(p).Trusted("github.com/cszatmary/go-fish")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if ok {
+		t.Error("want other modules to not be trusted")
+	}
+}
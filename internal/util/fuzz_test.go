@@ -0,0 +1,33 @@
+//go:build go1.18
+// +build go1.18
+
+package util_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/getshiphub/shed/internal/util"
+)
+
+// FuzzIsLocalPath checks that joining a path accepted by IsLocalPath to a directory never
+// results in a path outside of it. Run with:
+//
+//	go test ./internal/util -run '^$' -fuzz FuzzIsLocalPath
+func FuzzIsLocalPath(f *testing.F) {
+	for _, seed := range []string{"a/b", "../a", "a/../../b", "/a", `..\a`, "C:/a", "C:a", "a/./b/", "."} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, p string) {
+		if !util.IsLocalPath(p) {
+			return
+		}
+		root := filepath.Join("root", "dir")
+		rel, err := filepath.Rel(root, filepath.Join(root, filepath.FromSlash(p)))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			t.Fatalf("got relative path %q, %v for local path %q", rel, err, p)
+		}
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
+	"path/filepath"
 	"strings"
 )
 
@@ -15,6 +17,57 @@ func FileOrDirExists(path string) bool {
 	return true
 }
 
+// IsRegularFile returns true if the given path is a regular file. Unlike FileOrDirExists,
+// symlinks are not followed, so a symlink to a file is not a regular file.
+func IsRegularFile(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// IsLocalPath reports whether the slash separated path p stays within the directory it is relative to.
+// p must not be empty or absolute, and must not contain '..' elements, backslashes, or a volume name.
+// Backslashes are rejected since they are separators on Windows but not on other platforms.
+func IsLocalPath(p string) bool {
+	if p == "" || strings.ContainsAny(p, "\\\x00") || path.IsAbs(p) || filepath.IsAbs(p) || filepath.VolumeName(p) != "" {
+		return false
+	}
+	for _, elem := range strings.Split(p, "/") {
+		if elem == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckNoSymlinks returns an error if p is not within the directory root, or if a file or
+// directory in p below root is a symlink. root itself may be a symlink. Parts of p that
+// don't exist are ignored, so this can be used before creating p to make sure writes to
+// it can't be redirected outside of root.
+func CheckNoSymlinks(root, p string) error {
+	rel, err := filepath.Rel(root, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is not within %s", p, root)
+	}
+	if rel == "." {
+		return nil
+	}
+	cur := root
+	for _, elem := range strings.Split(rel, string(filepath.Separator)) {
+		cur = filepath.Join(cur, elem)
+		info, err := os.Lstat(cur)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%s is a symlink", cur)
+		}
+	}
+	return nil
+}
+
 // Fatal allows for handling fatal conditions in a program.
 // It allows printing error details and then terminating the process.
 type Fatal struct {
@@ -27,6 +80,9 @@ type Fatal struct {
 	// ExitFunc is the function called to terminate the program.
 	// This defaults to os.Exit and generally should not be set directly.
 	ExitFunc func(code int)
+	// Translate translates messages before they are printed. The format string
+	// is translated before it is formatted. If nil, messages are printed as is.
+	Translate func(msg string) string
 }
 
 // ExitErrf prints the given message and error to stderr then exits the program.
@@ -40,17 +96,22 @@ func (f Fatal) ExitErrf(err error, format string, a ...interface{}) {
 	if f.ExitFunc == nil {
 		f.ExitFunc = os.Exit
 	}
+	if f.Translate == nil {
+		f.Translate = func(msg string) string { return msg }
+	}
 
+	format = f.Translate(format)
 	fmt.Fprintf(f.ErrWriter, format, a...)
 	if !strings.HasSuffix(format, "\n") {
 		fmt.Fprintln(f.ErrWriter)
 	}
 
 	if err != nil {
+		label := f.Translate("Error")
 		if f.ShowErrorDetail {
-			fmt.Fprintf(f.ErrWriter, "Error: %+v\n", err)
+			fmt.Fprintf(f.ErrWriter, "%s: %+v\n", label, err)
 		} else {
-			fmt.Fprintf(f.ErrWriter, "Error: %s\n", err)
+			fmt.Fprintf(f.ErrWriter, "%s: %s\n", label, err)
 		}
 	}
 
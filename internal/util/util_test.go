@@ -3,6 +3,8 @@ package util_test
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -35,6 +37,70 @@ func TestFileOrDirExists(t *testing.T) {
 	}
 }
 
+func TestIsLocalPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"a", true},
+		{"a/b/c", true},
+		{"a/./b", true},
+		{"a..b/..c", true},
+		{"", false},
+		{"..", false},
+		{"../a", false},
+		{"a/../b", false},
+		{"a/..", false},
+		{"/a", false},
+		{`a\b`, false},
+		{`..\a`, false},
+		{"a\x00b", false},
+	}
+	for _, tt := range tests {
+		if got := util.IsLocalPath(tt.path); got != tt.want {
+			t.Errorf("got %v for %q, want %v", got, tt.path, tt.want)
+		}
+	}
+}
+
+func TestCheckNoSymlinks(t *testing.T) {
+	td := t.TempDir()
+	root := filepath.Join(td, "root")
+	if err := os.MkdirAll(filepath.Join(root, "a", "b"), 0o755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "a", "file"), nil, 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	for _, p := range []string{root, filepath.Join(root, "a", "b"), filepath.Join(root, "a", "file"), filepath.Join(root, "a", "new", "dir")} {
+		if err := util.CheckNoSymlinks(root, p); err != nil {
+			t.Errorf("want nil error for %s, got %v", p, err)
+		}
+	}
+	for _, p := range []string{td, filepath.Join(td, "other"), filepath.Join(root, "..", "other")} {
+		if err := util.CheckNoSymlinks(root, p); err == nil {
+			t.Errorf("want non-nil error for %s, got nil", p)
+		}
+	}
+
+	if err := os.Symlink(td, filepath.Join(root, "a", "link")); err != nil {
+		t.Skipf("failed to create symlink: %v", err)
+	}
+	for _, p := range []string{filepath.Join(root, "a", "link"), filepath.Join(root, "a", "link", "root", "new")} {
+		if err := util.CheckNoSymlinks(root, p); err == nil {
+			t.Errorf("want non-nil error for %s, got nil", p)
+		}
+	}
+	// The root itself can be a symlink
+	rootLink := filepath.Join(td, "root-link")
+	if err := os.Symlink(root, rootLink); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	if err := util.CheckNoSymlinks(rootLink, filepath.Join(rootLink, "a", "b")); err != nil {
+		t.Errorf("want nil error, got %v", err)
+	}
+}
+
 type mockExit struct {
 	code int
 }
@@ -74,6 +140,24 @@ func TestExitErrf(t *testing.T) {
 	}
 }
 
+func TestExitErrfTranslate(t *testing.T) {
+	buf := &bytes.Buffer{}
+	me := mockExit{}
+	translations := map[string]string{
+		"%d failures": "échecs : %d",
+		"Error":       "Erreur",
+	}
+	fatal := util.Fatal{ErrWriter: buf, ExitFunc: me.Exit, Translate: func(msg string) string {
+		return translations[msg]
+	}}
+
+	fatal.ExitErrf(errors.New("err everything broke"), "%d failures", 3)
+	out := buf.String()
+	if out != "échecs : 3\nErreur: err everything broke\n" {
+		t.Errorf("got output '%s', expected 'échecs : 3\nErreur: err everything broke\n'", out)
+	}
+}
+
 func TestExitErrStackf(t *testing.T) {
 	buf := &bytes.Buffer{}
 	me := mockExit{}
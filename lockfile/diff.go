@@ -0,0 +1,180 @@
+package lockfile
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/getshiphub/shed/tool"
+)
+
+// ChangeKind is how a tool differs between two lockfiles, see Diff.
+type ChangeKind int
+
+const (
+	// ChangeAdded means the tool is only in the new lockfile.
+	ChangeAdded ChangeKind = iota + 1
+	// ChangeRemoved means the tool is only in the old lockfile.
+	ChangeRemoved
+	// ChangeChanged means the tool is in both lockfiles, but its version or other fields differ.
+	ChangeChanged
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeChanged:
+		return "changed"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// Fields of a tool that are compared by Diff, see Change.Fields.
+const (
+	FieldVersion      = "version"
+	FieldSum          = "sum"
+	FieldBuildFlags   = "buildFlags"
+	FieldAlias        = "alias"
+	FieldRelease      = "release"
+	FieldGroups       = "groups"
+	FieldWhy          = "why"
+	FieldBranch       = "branch"
+	FieldReproducible = "reproducible"
+)
+
+// Change describes a tool that differs between two lockfiles.
+type Change struct {
+	Kind ChangeKind
+	// Old is the tool in the old lockfile. It is the zero value for ChangeAdded.
+	Old tool.Tool
+	// New is the tool in the new lockfile. It is the zero value for ChangeRemoved.
+	New tool.Tool
+	// Fields are the fields that differ for ChangeChanged, in the order of the Field constants.
+	Fields []string
+}
+
+// ImportPath returns the import path of the tool that changed.
+func (c Change) ImportPath() string {
+	if c.Kind == ChangeRemoved {
+		return c.Old.ImportPath
+	}
+	return c.New.ImportPath
+}
+
+// Diff returns the tools that differ between the lockfiles old and new, sorted by import path.
+// Tools are matched by import path, ignoring case, since the case of an import path can be corrected
+// when a tool is installed again. A nil lockfile is treated as empty. Scripts are not compared.
+func Diff(old, new *Lockfile) []Change {
+	oldTools := toolsByImportPath(old)
+	newTools := toolsByImportPath(new)
+	var changes []Change
+	for key, ot := range oldTools {
+		nt, ok := newTools[key]
+		if !ok {
+			changes = append(changes, Change{Kind: ChangeRemoved, Old: ot})
+			continue
+		}
+		if fields := changedFields(ot, nt); len(fields) > 0 {
+			changes = append(changes, Change{Kind: ChangeChanged, Old: ot, New: nt, Fields: fields})
+		}
+	}
+	for key, nt := range newTools {
+		if _, ok := oldTools[key]; !ok {
+			changes = append(changes, Change{Kind: ChangeAdded, New: nt})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].ImportPath() < changes[j].ImportPath()
+	})
+	return changes
+}
+
+// toolsByImportPath returns the tools in lf keyed by their lowercase import path.
+func toolsByImportPath(lf *Lockfile) map[string]tool.Tool {
+	tools := make(map[string]tool.Tool)
+	if lf == nil {
+		return tools
+	}
+	it := lf.Iter()
+	for it.Next() {
+		t := it.Value()
+		tools[strings.ToLower(t.ImportPath)] = t
+	}
+	return tools
+}
+
+// changedFields returns the fields of old and new that differ.
+func changedFields(old, new tool.Tool) []string {
+	var fields []string
+	if old.Version != new.Version {
+		fields = append(fields, FieldVersion)
+	}
+	if old.Sum != new.Sum {
+		fields = append(fields, FieldSum)
+	}
+	if old.BuildFlags != new.BuildFlags {
+		fields = append(fields, FieldBuildFlags)
+	}
+	if old.Alias != new.Alias {
+		fields = append(fields, FieldAlias)
+	}
+	if !releaseEqual(old.Release, new.Release) {
+		fields = append(fields, FieldRelease)
+	}
+	if !groupsEqual(old, new) {
+		fields = append(fields, FieldGroups)
+	}
+	if old.Why != new.Why {
+		fields = append(fields, FieldWhy)
+	}
+	if old.Branch != new.Branch {
+		fields = append(fields, FieldBranch)
+	}
+	if !reproducibleEqual(old.Reproducible, new.Reproducible) {
+		fields = append(fields, FieldReproducible)
+	}
+	return fields
+}
+
+func releaseEqual(a, b *tool.Release) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.URL == b.URL && a.Asset == b.Asset && a.Binary == b.Binary && sumsEqual(a.Sums, b.Sums)
+}
+
+func reproducibleEqual(a, b *tool.Reproducible) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.GoVersion == b.GoVersion && sumsEqual(a.Sums, b.Sums)
+}
+
+func sumsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for p, sum := range a {
+		if b[p] != sum {
+			return false
+		}
+	}
+	return true
+}
+
+// groupsEqual reports whether a and b are in the same groups, regardless of order.
+func groupsEqual(a, b tool.Tool) bool {
+	ag, bg := a.GroupList(), b.GroupList()
+	if len(ag) != len(bg) {
+		return false
+	}
+	for _, g := range ag {
+		if !b.InGroup(g) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+//go:build go1.18
+// +build go1.18
+
+package lockfile_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/getshiphub/shed/lockfile"
+)
+
+// FuzzParse checks that Parse never panics and that any lockfile it accepts
+// can be written and parsed again without changes.
+//
+// The golden and invalid lockfiles in testdata are used as the seed corpus,
+// along with anything in testdata/fuzz/FuzzParse. Run with:
+//
+//	go test ./lockfile -run '^$' -fuzz FuzzParse
+func FuzzParse(f *testing.F) {
+	for _, pattern := range []string{"golden/*.lock", "invalid/*.lock"} {
+		paths, err := filepath.Glob(filepath.Join("testdata", filepath.FromSlash(pattern)))
+		if err != nil {
+			f.Fatalf("failed to find seed files: %v", err)
+		}
+		for _, p := range paths {
+			data, err := ioutil.ReadFile(p)
+			if err != nil {
+				f.Fatalf("failed to read %s: %v", p, err)
+			}
+			f.Add(data)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		lf, err := lockfile.Parse(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		checkRoundTrip(t, lf)
+
+		// Every tool must be retrievable by its import path
+		for _, tl := range sortedTools(lf) {
+			got, err := lf.GetTool(tl.ImportPath)
+			if err != nil {
+				t.Fatalf("failed to get tool %s: %v", tl.ImportPath, err)
+			}
+			if got != tl {
+				t.Fatalf("got tool %+v, want %+v", got, tl)
+			}
+		}
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/getshiphub/shed/tool"
+	"golang.org/x/mod/module"
 )
 
 // ErrNotFound is returned when a tool is not found in a lockfile.
@@ -28,6 +29,11 @@ var ErrMultipleTools = errors.New("lockfile: multiple tools found with the same
 // a module query (ex: branch name or commit SHA) or a shorthand version.
 var ErrInvalidVersion = errors.New("lockfile: tool has invalid version")
 
+// ErrAliasConflict is returned when the name of a tool clashes with the alias of another tool.
+// Tools without aliases can share a name, but an alias must be unique, since it was chosen
+// to tell tools apart.
+var ErrAliasConflict = errors.New("lockfile: alias conflicts with another tool")
+
 // Lockfile represents a shed lockfile. The lockfile is responsible for keeping
 // track of installed tools as well as their versions so shed can always
 // re-install the same version of each tool.
@@ -42,105 +48,207 @@ type Lockfile struct {
 	// with the same name. In this case the full import path is required
 	// to determine which tool to grab from the bucket.
 	tools map[string][]tool.Tool
+	// scripts stores the Go scripts managed by this lockfile by path.
+	scripts map[string]Script
+	// version is the format version of the data the lockfile was parsed from.
+	version int
 }
 
 // GetTool retrieves the tool with the given name from the lockfile.
 // Name can either be the name of the tool itself (i.e. the name of the binary)
 // or it can be the full import path.
 //
-// If no tool is found, ErrNotFound is returned. If the name is a full import path
+// If no tool is found, a *NotFoundError matching ErrNotFound is returned, which suggests
+// similar names, see Suggest. If more than one tool has the name, a *NameError matching
+// ErrMultipleTools is returned, see Candidates to find all of them. If the name is a full import path
 // and it contains a version, then the version will be checked against the tool found.
 // If the versions do not match, then ErrIncorrectVersion will be returned along with
 // the found version of the tool.
+//
+// If a tool has an alias, the alias is its name, see tool.Tool.Name.
+// It can still be retrieved by its full import path.
+//
+// Names and import paths are matched case-insensitively if there is no exact match,
+// as long as only a single tool matches. This allows for import paths to be typed
+// with the wrong case, since most code hosts treat them case-insensitively.
+//
+// The name can also be the last elements of an import path that isn't a valid import path
+// on its own, ex: 'tools/cmd/stringer', as long as only a single tool's import path ends with it.
 func (lf *Lockfile) GetTool(name string) (tool.Tool, error) {
 	// Fast way, assume the name is just the tool name and see if we get a match
-	bucket, ok := lf.tools[name]
-	if ok {
+	bucket := lf.lookup(name)
+	if len(bucket) > 0 {
 		// Tool names must be unique to use the shorthand, otherwise we have no idea
 		// which tool was intended
 		if len(bucket) > 1 {
-			err := fmt.Errorf("%w: %d tools named %s found", ErrMultipleTools, len(bucket), name)
-			return tool.Tool{}, err
+			tools := append([]tool.Tool(nil), bucket...)
+			sortByImportPath(tools)
+			reason := fmt.Sprintf("%d tools named %s found", len(tools), name)
+			return tool.Tool{}, &NameError{Kind: ErrMultipleTools, Name: name, Tools: tools, Reason: reason}
 		}
 		return bucket[0], nil
 	}
 
 	// Check if it was short name so we can report not found instead of trying to parse
 	if path.Base(name) == name {
-		return tool.Tool{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+		return tool.Tool{}, lf.notFound(name)
 	}
 
 	// Long way, parse the tool name which should be an import path
 	tl, err := tool.ParseLax(name)
 	if err != nil {
-		return tool.Tool{}, err
+		// It could be the end of an import path instead, ex: tools/cmd/stringer, otherwise it can't be in the lockfile
+		if t, ok, serr := lf.getBySuffix(name); ok {
+			return t, serr
+		}
+		return tool.Tool{}, lf.notFound(name)
 	}
 
+	// The tool could have an alias, so all tools need to be checked
 	toolName := tl.Name()
-	bucket, ok = lf.tools[toolName]
-	if !ok {
-		return tool.Tool{}, fmt.Errorf("%w: %s", ErrNotFound, toolName)
+	var found []tool.Tool
+	for _, t := range lf.findImportPath(tl.ImportPath) {
+		if t.ImportPath == tl.ImportPath {
+			found = []tool.Tool{t}
+			break
+		}
+		found = append(found, t)
+	}
+	switch {
+	case len(found) == 0:
+		return tool.Tool{}, lf.notFound(toolName)
+	case len(found) > 1:
+		sortByImportPath(found)
+		reason := fmt.Sprintf("%d tools matching %s found", len(found), tl.ImportPath)
+		return tool.Tool{}, &NameError{Kind: ErrMultipleTools, Name: tl.ImportPath, Tools: found, Reason: reason}
 	}
 
-	for _, t := range bucket {
-		if t.ImportPath != tl.ImportPath {
-			continue
-		}
+	t := found[0]
+	if tl.Version != "" && tl.Version != t.Version {
+		return t, fmt.Errorf("%w: wanted %s", ErrIncorrectVersion, tl.Version)
+	}
+	return t, nil
+}
 
-		if tl.Version != "" && tl.Version != t.Version {
-			return t, fmt.Errorf("%w: wanted %s", ErrIncorrectVersion, tl.Version)
+// lookup returns all tools with the given name. If there are no tools with
+// exactly the given name, tools with names that differ only in case are returned.
+func (lf *Lockfile) lookup(name string) []tool.Tool {
+	if bucket, ok := lf.tools[name]; ok {
+		return bucket
+	}
+	var tools []tool.Tool
+	for toolName, bucket := range lf.tools {
+		if strings.EqualFold(toolName, name) {
+			tools = append(tools, bucket...)
 		}
+	}
+	return tools
+}
 
-		return t, nil
+// findImportPath returns all tools with an import path that matches importPath case-insensitively.
+func (lf *Lockfile) findImportPath(importPath string) []tool.Tool {
+	var tools []tool.Tool
+	for _, bucket := range lf.tools {
+		for _, t := range bucket {
+			if strings.EqualFold(t.ImportPath, importPath) {
+				tools = append(tools, t)
+			}
+		}
 	}
+	return tools
+}
 
-	return tool.Tool{}, fmt.Errorf("%w: %s", ErrNotFound, toolName)
+// CheckAlias checks that the name of t doesn't conflict with the alias of another tool,
+// or if t has an alias, that it doesn't conflict with the name of another tool.
+// Tools with the same import path as t are ignored, since t replaces them.
+// If there is a conflict, a *NameError matching ErrAliasConflict is returned.
+func (lf *Lockfile) CheckAlias(t tool.Tool) error {
+	for _, tl := range lf.lookup(t.Name()) {
+		if strings.EqualFold(tl.ImportPath, t.ImportPath) || (t.Alias == "" && tl.Alias == "") {
+			continue
+		}
+		return &NameError{
+			Kind:   ErrAliasConflict,
+			Name:   t.Name(),
+			Tools:  []tool.Tool{tl, t},
+			Reason: fmt.Sprintf("%s is the name of both %s and %s", t.Name(), tl.ImportPath, t.ImportPath),
+		}
+	}
+	return nil
 }
 
 // PutTool adds or replaces the given tool in the lockfile.
+// An existing tool with an import path that only differs in case is also replaced,
+// so that the lockfile doesn't end up with multiple entries for the same tool.
+//
+// t.Version must be an exact version, see CheckVersion for details. If it is not, a *VersionError
+// is returned, which matches ErrInvalidVersion. For compatibility with lockfiles written before
+// versions were fully validated, a tool that is already in the lockfile with the same version
+// is accepted even if its version is invalid.
 //
-// t.Version must be a valid SemVer, that is t.HasSemver() must return true.
-// If t.Version is not a valid SemVer, ErrInvalidVersion will be returned.
+// If t.Alias is set, it must be a valid alias, see tool.CheckAlias. If the name of t conflicts with
+// another tool, an error matching ErrAliasConflict is returned, see CheckAlias.
+// Each group of t must be a valid group name, see tool.CheckGroup.
 func (lf *Lockfile) PutTool(t tool.Tool) error {
 	if lf.tools == nil {
 		lf.tools = make(map[string][]tool.Tool)
 	}
 
 	// Invariant check: A tool inserted into the lockfile must have Version set to
-	// a valid SemVer otherwise it defeats the purpose of a lockfile.
-	if !t.HasSemver() {
-		return fmt.Errorf("%w: %v", ErrInvalidVersion, t)
+	// an exact version otherwise it defeats the purpose of a lockfile.
+	if err := CheckVersion(t); err != nil && !lf.contains(t) {
+		return err
+	}
+	if t.Alias != "" {
+		if err := tool.CheckAlias(t.Alias); err != nil {
+			return err
+		}
+	}
+	for _, g := range t.GroupList() {
+		if err := tool.CheckGroup(g); err != nil {
+			return err
+		}
+	}
+	if err := lf.CheckAlias(t); err != nil {
+		return err
+	}
+
+	// Remove the existing tool, including any that only differ in case.
+	// The name can differ in case or the alias could have changed, so these could be in a different bucket.
+	for _, tl := range lf.findImportPath(t.ImportPath) {
+		lf.DeleteTool(tl)
 	}
 
-	toolName := t.Name()
 	// Don't need to check whether or not the bucket exists. If it doesn't we will get
 	// back a nil slice which we can append to
-	bucket := lf.tools[toolName]
+	toolName := t.Name()
+	lf.tools[toolName] = append(lf.tools[toolName], t)
+	return nil
+}
 
-	// Check if the tool already exists and update it
-	foundIndex := -1
-	for i, tl := range bucket {
-		if tl.ImportPath == t.ImportPath {
-			bucket[i] = t
-			foundIndex = i
-			break
+// contains reports whether the lockfile contains t with the same version.
+func (lf *Lockfile) contains(t tool.Tool) bool {
+	for _, tl := range lf.findImportPath(t.ImportPath) {
+		if tl.ImportPath == t.ImportPath && tl.Version == t.Version {
+			return true
 		}
 	}
-
-	// No existing one found, add new one
-	if foundIndex == -1 {
-		bucket = append(bucket, t)
-	}
-	lf.tools[toolName] = bucket
-	return nil
+	return false
 }
 
 // DeleteTool removes the given tool from the lockfile if it exists.
 // If t.Version is not empty, the tool will only be deleted from the lockfile
 // if it has the same version. If t.Version is empty, it will be deleted from the
-// lockfile regardless of version.
+// lockfile regardless of version. t.Alias is ignored, the tool is deleted whatever its alias is.
 func (lf *Lockfile) DeleteTool(t tool.Tool) {
-	toolName := t.Name()
+	// Find the bucket the tool is in, it depends on the alias in the lockfile
+	toolName := ""
+	for _, tl := range lf.findImportPath(t.ImportPath) {
+		if tl.ImportPath == t.ImportPath {
+			toolName = tl.Name()
+			break
+		}
+	}
 	bucket, ok := lf.tools[toolName]
 	if !ok {
 		return
@@ -250,13 +358,44 @@ func (it *Iterator) Value() tool.Tool {
 // number of bytes written and any error that occurred.
 func (lf *Lockfile) WriteTo(w io.Writer) (int64, error) {
 	// Convert lockfile to format that can be serialized into JSON
-	lfSchema := lockfileSchema{Tools: make(map[string]toolSchema)}
+	lfSchema := lockfileSchema{Version: FormatVersion, Tools: make(map[string]toolSchema)}
 	for _, bucket := range lf.tools {
 		for _, t := range bucket {
-			lfSchema.Tools[t.ImportPath] = toolSchema{Version: t.Version}
+			ts := toolSchema{Version: t.Version, Module: t.ModulePath, Sum: t.Sum, Alias: t.Alias, Groups: t.GroupList(), Why: t.Why, Branch: t.Branch}
+			if !t.BuildFlags.IsZero() {
+				ts.Build = &buildSchema{Tags: t.BuildFlags.Tags, Ldflags: t.BuildFlags.Ldflags, Trimpath: t.BuildFlags.Trimpath}
+				if env := t.BuildFlags.EnvList(); len(env) > 0 {
+					ts.Build.Env = make(map[string]string, len(env))
+					for _, kv := range env {
+						i := strings.IndexByte(kv, '=')
+						ts.Build.Env[kv[:i]] = kv[i+1:]
+					}
+				}
+			}
+			if r := t.Release; r != nil {
+				ts.Release = &releaseSchema{URL: r.URL, Asset: r.Asset, Binary: r.Binary, Sums: r.Sums}
+			}
+			if r := t.Reproducible; r != nil {
+				ts.Reproducible = &reproducibleSchema{GoVersion: r.GoVersion, Sums: r.Sums}
+			}
+			lfSchema.Tools[t.ImportPath] = ts
 		}
 	}
 
+	for _, sc := range lf.scripts {
+		ss := scriptSchema{}
+		if len(sc.Requires) > 0 {
+			ss.Requires = make(map[string]string, len(sc.Requires))
+			for _, mod := range sc.Requires {
+				ss.Requires[mod.Path] = mod.Version
+			}
+		}
+		if lfSchema.Scripts == nil {
+			lfSchema.Scripts = make(map[string]scriptSchema)
+		}
+		lfSchema.Scripts[sc.Path] = ss
+	}
+
 	data, err := json.MarshalIndent(lfSchema, "", "  ")
 	if err != nil {
 		return 0, fmt.Errorf("lockfile: failed to serialize as JSON: %w", err)
@@ -278,13 +417,74 @@ func (lf *Lockfile) WriteTo(w io.Writer) (int64, error) {
 
 type toolSchema struct {
 	Version string `json:"version"`
+	// Module is omitted if unknown, since older lockfiles don't have it
+	Module string `json:"module,omitempty"`
+	// Sum is omitted if unknown for the same reason
+	Sum          string              `json:"sum,omitempty"`
+	Build        *buildSchema        `json:"build,omitempty"`
+	Alias        string              `json:"alias,omitempty"`
+	Groups       []string            `json:"groups,omitempty"`
+	Why          string              `json:"why,omitempty"`
+	Branch       string              `json:"branch,omitempty"`
+	Release      *releaseSchema      `json:"release,omitempty"`
+	Reproducible *reproducibleSchema `json:"reproducible,omitempty"`
+}
+
+type buildSchema struct {
+	Tags     string            `json:"tags,omitempty"`
+	Ldflags  string            `json:"ldflags,omitempty"`
+	Trimpath bool              `json:"trimpath,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+}
+
+type reproducibleSchema struct {
+	GoVersion string            `json:"goVersion"`
+	Sums      map[string]string `json:"sums,omitempty"`
+}
+
+type releaseSchema struct {
+	URL    string            `json:"url,omitempty"`
+	Asset  string            `json:"asset,omitempty"`
+	Binary string            `json:"binary,omitempty"`
+	Sums   map[string]string `json:"sums,omitempty"`
+}
+
+type scriptSchema struct {
+	// Requires maps module paths to versions
+	Requires map[string]string `json:"requires,omitempty"`
 }
 
 type lockfileSchema struct {
-	Tools map[string]toolSchema `json:"tools"`
+	Version int                     `json:"lockfileVersion"`
+	Tools   map[string]toolSchema   `json:"tools"`
+	Scripts map[string]scriptSchema `json:"scripts,omitempty"`
+}
+
+// NameError describes a name that refers to more than one tool. It is returned by GetTool if the name
+// is ambiguous, and by CheckAlias if the name of a tool clashes with the alias of another tool.
+// It matches Kind when using errors.Is.
+type NameError struct {
+	// Kind is ErrMultipleTools or ErrAliasConflict.
+	Kind error
+	// Name is the name or import path that refers to more than one tool.
+	Name string
+	// Tools are the tools that share the name.
+	Tools []tool.Tool
+	// Reason describes the conflict.
+	Reason string
+}
+
+func (e *NameError) Error() string {
+	return fmt.Sprintf("%v: %s", e.Kind, e.Reason)
+}
+
+func (e *NameError) Unwrap() error {
+	return e.Kind
 }
 
-// ErrorList is a list of errors encountered during parsing.
+// ErrorList is a list of errors. It is returned when parsing a lockfile fails, and by
+// operations that continue past errors for individual tools, in which case each error
+// describes a single tool.
 type ErrorList []error
 
 func (e ErrorList) Error() string {
@@ -296,14 +496,32 @@ func (e ErrorList) Error() string {
 }
 
 // Parse reads from r and parses the data into a Lockfile struct.
+//
+// Lockfiles with an older format version are migrated to FormatVersion. If the lockfile
+// has a newer format version, the error matches ErrUnsupportedFormat.
 func Parse(r io.Reader) (*Lockfile, error) {
-	lfSchema := lockfileSchema{}
-	err := json.NewDecoder(r).Decode(&lfSchema)
+	var raw json.RawMessage
+	dec := json.NewDecoder(r)
+	err := dec.Decode(&raw)
 	if err != nil {
 		return nil, fmt.Errorf("lockfile: failed to deserialize JSON: %w", err)
 	}
+	// Decode stops after the first value, so make sure there is nothing else.
+	// Otherwise a badly merged lockfile containing multiple objects would be silently truncated.
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("lockfile: failed to deserialize JSON: unexpected data after top-level value")
+	}
+	// Check the version before anything else, a newer format might not deserialize
+	data, version, err := migrate(raw, FormatVersion)
+	if err != nil {
+		return nil, err
+	}
+	lfSchema := lockfileSchema{}
+	if err := json.Unmarshal(data, &lfSchema); err != nil {
+		return nil, fmt.Errorf("lockfile: failed to deserialize JSON: %w", err)
+	}
 
-	lf := &Lockfile{tools: make(map[string][]tool.Tool)}
+	lf := &Lockfile{tools: make(map[string][]tool.Tool), version: version}
 	// Parse all the tools in the lockfile. If errors are encountered, save
 	// them and continue. This way multiple errors can be reported at once.
 	var errs ErrorList
@@ -313,6 +531,78 @@ func Parse(r io.Reader) (*Lockfile, error) {
 			errs = append(errs, err)
 			continue
 		}
+		if tlSchema.Module != "" {
+			if t.ImportPath != tlSchema.Module && !strings.HasPrefix(t.ImportPath, tlSchema.Module+"/") {
+				errs = append(errs, fmt.Errorf("lockfile: import path %q is not in module %q", t.ImportPath, tlSchema.Module))
+				continue
+			}
+			t.ModulePath = tlSchema.Module
+		}
+		if tlSchema.Sum != "" {
+			if !strings.HasPrefix(tlSchema.Sum, "h1:") {
+				errs = append(errs, fmt.Errorf("lockfile: tool %q has invalid sum %q, must be a go.sum hash starting with 'h1:'", t.ImportPath, tlSchema.Sum))
+				continue
+			}
+			t.Sum = tlSchema.Sum
+		}
+		if b := tlSchema.Build; b != nil {
+			t.BuildFlags = tool.BuildFlags{Tags: b.Tags, Ldflags: b.Ldflags, Trimpath: b.Trimpath}
+			if len(b.Env) > 0 {
+				env := make([]string, 0, len(b.Env))
+				for k, v := range b.Env {
+					env = append(env, k+"="+v)
+				}
+				joined, err := tool.JoinBuildEnv(env)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("lockfile: tool %q has invalid build environment: %w", t.ImportPath, err))
+					continue
+				}
+				t.BuildFlags.Env = joined
+			}
+		}
+		if tlSchema.Alias != "" {
+			if err := tool.CheckAlias(tlSchema.Alias); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			t.Alias = tlSchema.Alias
+		}
+		if len(tlSchema.Groups) > 0 {
+			var groupErr error
+			for _, g := range tlSchema.Groups {
+				if err := tool.CheckGroup(g); err != nil {
+					groupErr = err
+					break
+				}
+			}
+			if groupErr != nil {
+				errs = append(errs, groupErr)
+				continue
+			}
+			t = t.WithGroups(tlSchema.Groups...)
+		}
+		t.Why = tlSchema.Why
+		if tlSchema.Branch != "" {
+			if !tool.IsBranch(tlSchema.Branch) {
+				errs = append(errs, fmt.Errorf("lockfile: tool %q has invalid branch %q", t.ImportPath, tlSchema.Branch))
+				continue
+			}
+			t.Branch = tlSchema.Branch
+		}
+		if r := tlSchema.Release; r != nil {
+			t.Release = &tool.Release{URL: r.URL, Asset: r.Asset, Binary: r.Binary, Sums: r.Sums}
+			if err := tool.CheckRelease(t); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		if r := tlSchema.Reproducible; r != nil {
+			t.Reproducible = &tool.Reproducible{GoVersion: r.GoVersion, Sums: r.Sums}
+			if err := tool.CheckReproducible(t); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
 
 		toolName := t.Name()
 		bucket := lf.tools[toolName]
@@ -320,6 +610,31 @@ func Parse(r io.Reader) (*Lockfile, error) {
 		lf.tools[toolName] = bucket
 	}
 
+	for p, ss := range lfSchema.Scripts {
+		sc := Script{Path: p}
+		for modPath, version := range ss.Requires {
+			sc.Requires = append(sc.Requires, module.Version{Path: modPath, Version: version})
+		}
+		if err := lf.PutScript(sc); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// Check aliases once all tools are known, since a conflict can be with any other tool
+	// Only report each conflicting name once, it would be reported for every tool with the name otherwise.
+	conflicts := make(map[string]bool)
+	it := lf.Iter()
+	for it.Next() {
+		t := it.Value()
+		if t.Alias == "" || conflicts[strings.ToLower(t.Alias)] {
+			continue
+		}
+		if err := lf.CheckAlias(t); err != nil {
+			conflicts[strings.ToLower(t.Alias)] = true
+			errs = append(errs, err)
+		}
+	}
+
 	if len(errs) > 0 {
 		return nil, errs
 	}
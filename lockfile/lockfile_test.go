@@ -11,6 +11,7 @@ import (
 
 	"github.com/getshiphub/shed/lockfile"
 	"github.com/getshiphub/shed/tool"
+	"golang.org/x/mod/module"
 )
 
 func newLockfile(t *testing.T, tools []tool.Tool) *lockfile.Lockfile {
@@ -61,7 +62,43 @@ func TestLockfileGet(t *testing.T) {
 			wantTool: tool.Tool{ImportPath: "example.org/z/random/stringer/v2/cmd/stringer", Version: "v2.1.0"},
 			wantErr:  nil,
 		},
+		{
+			name:     "short name different case",
+			toolName: "Go-Fish",
+			wantTool: tool.Tool{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+			wantErr:  nil,
+		},
+		{
+			name:     "import path different case",
+			toolName: "github.com/CSzatmary/go-fish",
+			wantTool: tool.Tool{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+			wantErr:  nil,
+		},
+		{
+			name:     "import path suffix",
+			toolName: "x/tools/cmd/stringer",
+			wantTool: tool.Tool{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58"},
+			wantErr:  nil,
+		},
+		{
+			name:     "import path suffix different case",
+			toolName: "golangci-lint/cmd/GolangCI-Lint",
+			wantTool: tool.Tool{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
+			wantErr:  nil,
+		},
 		// Errors
+		{
+			name:     "import path suffix multiple found",
+			toolName: "cmd/stringer",
+			wantTool: tool.Tool{},
+			wantErr:  lockfile.ErrMultipleTools,
+		},
+		{
+			name:     "import path suffix not found",
+			toolName: "tools/cmd/stress",
+			wantTool: tool.Tool{},
+			wantErr:  lockfile.ErrNotFound,
+		},
 		{
 			name:     "short name multiple found",
 			toolName: "stringer",
@@ -114,6 +151,53 @@ func TestLockfileGet(t *testing.T) {
 	}
 }
 
+func TestLockfileCandidates(t *testing.T) {
+	lf := newLockfile(t, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58"},
+		{ImportPath: "example.org/z/random/stringer/v2/cmd/stringer", Version: "v2.1.0"},
+	})
+
+	var got []string
+	for _, tl := range lf.Candidates("stringer") {
+		got = append(got, tl.ImportPath)
+	}
+	want := []string{"example.org/z/random/stringer/v2/cmd/stringer", "golang.org/x/tools/cmd/stringer"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got candidates %v, want %v", got, want)
+	}
+	if got := lf.Candidates("tools/cmd/stringer@latest"); len(got) != 1 || got[0].ImportPath != "golang.org/x/tools/cmd/stringer" {
+		t.Errorf("got candidates %v, want golang.org/x/tools/cmd/stringer", got)
+	}
+	if got := lf.Candidates("stress"); len(got) != 0 {
+		t.Errorf("got candidates %v, want none", got)
+	}
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"golangci-lnt", []string{"golangci-lint"}},
+		{"gofish", []string{"go-fish"}},
+		{"golangci", []string{"golangci-lint"}},
+		{"github.com/cszatmary/go-fsh@v0.1.0", []string{"go-fish"}},
+		{"strnger", []string{"example.org/z/random/stringer/v2/cmd/stringer", "golang.org/x/tools/cmd/stringer"}},
+		{"gofmt", nil},
+	}
+	for _, tt := range tests {
+		if got := lf.Suggest(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got suggestions %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	_, err := lf.GetTool("golangci-lnt")
+	var nfErr *lockfile.NotFoundError
+	if !errors.As(err, &nfErr) || !reflect.DeepEqual(nfErr.Suggestions, []string{"golangci-lint"}) {
+		t.Errorf("got error %v, want suggestion golangci-lint", err)
+	}
+}
+
 func TestLockfilePutReplace(t *testing.T) {
 	lf := &lockfile.Lockfile{}
 	want := tool.Tool{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"}
@@ -145,6 +229,25 @@ func TestLockfilePutReplace(t *testing.T) {
 	}
 }
 
+func TestLockfilePutReplaceDifferentCase(t *testing.T) {
+	lf := newLockfile(t, []tool.Tool{
+		{ImportPath: "github.com/Shopify/ejson/cmd/EJSON", Version: "v1.1.0"},
+	})
+	want := tool.Tool{ImportPath: "github.com/shopify/ejson/cmd/ejson", Version: "v1.2.2"}
+	if err := lf.PutTool(want); err != nil {
+		t.Fatalf("failed to add tool %v to lockfile: %v", want, err)
+	}
+
+	var got []tool.Tool
+	it := lf.Iter()
+	for it.Next() {
+		got = append(got, it.Value())
+	}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("got tools %+v, want only %+v", got, want)
+	}
+}
+
 func TestLockfilePutError(t *testing.T) {
 	tests := []struct {
 		name string
@@ -166,6 +269,26 @@ func TestLockfilePutError(t *testing.T) {
 			name: "shorthand semver",
 			tool: tool.Tool{ImportPath: "github.com/cszatmary/go-fish", Version: "v1.2"},
 		},
+		{
+			name: "build metadata",
+			tool: tool.Tool{ImportPath: "github.com/cszatmary/go-fish", Version: "v1.2.0+build.5"},
+		},
+		{
+			name: "incompatible v1",
+			tool: tool.Tool{ImportPath: "github.com/cszatmary/go-fish", Version: "v1.2.0+incompatible"},
+		},
+		{
+			name: "pseudo-version with invalid base",
+			tool: tool.Tool{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.1.0-20201211185031-d93e913c1a58"},
+		},
+		{
+			name: "pseudo-version with invalid timestamp",
+			tool: tool.Tool{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201311185031-d93e913c1a58"},
+		},
+		{
+			name: "pseudo-version with short revision",
+			tool: tool.Tool{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -175,10 +298,53 @@ func TestLockfilePutError(t *testing.T) {
 			if !errors.Is(err, lockfile.ErrInvalidVersion) {
 				t.Errorf("got error %v, want %v", err, lockfile.ErrInvalidVersion)
 			}
+			var versionErr *lockfile.VersionError
+			if !errors.As(err, &versionErr) {
+				t.Fatalf("got error %T, want *lockfile.VersionError", err)
+			}
+			if versionErr.Tool != tt.tool || versionErr.Reason == "" {
+				t.Errorf("got tool %v with reason %q, want %v with a reason", versionErr.Tool, versionErr.Reason, tt.tool)
+			}
 		})
 	}
 }
 
+func TestLockfilePutValidVersion(t *testing.T) {
+	tools := []tool.Tool{
+		{ImportPath: "github.com/example/tool", Version: "v2.1.0+incompatible"},
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58"},
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.1-0.20201211185031-d93e913c1a58"},
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.0-rc.1.0.20201211185031-d93e913c1a58"},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.34.0-rc.1"},
+	}
+	lf := &lockfile.Lockfile{}
+	for _, tl := range tools {
+		if err := lf.PutTool(tl); err != nil {
+			t.Errorf("want nil error for %v, got %v", tl, err)
+		}
+	}
+}
+
+func TestLockfilePutLegacyVersion(t *testing.T) {
+	// Parse doesn't check pseudo-versions, so older lockfiles can contain invalid ones
+	lf, err := lockfile.Parse(strings.NewReader(`{
+		"tools": {
+		  "golang.org/x/tools/cmd/stringer": {"version": "v0.0.0-20201211185031-D93E913C1A58"}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	legacy := tool.Tool{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-D93E913C1A58"}
+	if err := lf.PutTool(legacy); err != nil {
+		t.Errorf("want nil error for existing legacy tool, got %v", err)
+	}
+	legacy.Version = "v0.0.0-20201212185031-D93E913C1A58"
+	if err := lf.PutTool(legacy); !errors.Is(err, lockfile.ErrInvalidVersion) {
+		t.Errorf("got error %v, want %v", err, lockfile.ErrInvalidVersion)
+	}
+}
+
 func TestLockfileDelete(t *testing.T) {
 	lf := newLockfile(t, []tool.Tool{
 		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
@@ -230,6 +396,97 @@ func TestLockfileDelete(t *testing.T) {
 	}
 }
 
+func TestLockfileAlias(t *testing.T) {
+	lf := newLockfile(t, []tool.Tool{
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58"},
+		{ImportPath: "example.org/z/random/stringer/v2/cmd/stringer", Version: "v2.1.0", Alias: "stringer2"},
+	})
+
+	tests := []struct {
+		name       string
+		toolName   string
+		wantImport string
+	}{
+		{"alias", "stringer2", "example.org/z/random/stringer/v2/cmd/stringer"},
+		{"alias different case", "Stringer2", "example.org/z/random/stringer/v2/cmd/stringer"},
+		{"import path of aliased tool", "example.org/z/random/stringer/v2/cmd/stringer", "example.org/z/random/stringer/v2/cmd/stringer"},
+		{"name without alias is unique", "stringer", "golang.org/x/tools/cmd/stringer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tl, err := lf.GetTool(tt.toolName)
+			if err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+			if tl.ImportPath != tt.wantImport {
+				t.Errorf("got tool %v, want %s", tl, tt.wantImport)
+			}
+		})
+	}
+
+	// Changing the alias replaces the tool
+	want := tool.Tool{ImportPath: "example.org/z/random/stringer/v2/cmd/stringer", Version: "v2.1.0", Alias: "stringer-v2"}
+	if err := lf.PutTool(want); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if _, err := lf.GetTool("stringer2"); !errors.Is(err, lockfile.ErrNotFound) {
+		t.Errorf("want err to match %v, got %v", lockfile.ErrNotFound, err)
+	}
+	if tl, err := lf.GetTool("stringer-v2"); err != nil || tl != want {
+		t.Errorf("got tool %+v and error %v, want %+v", tl, err, want)
+	}
+
+	// Deleting doesn't require the alias
+	lf.DeleteTool(tool.Tool{ImportPath: want.ImportPath})
+	if _, err := lf.GetTool(want.ImportPath); !errors.Is(err, lockfile.ErrNotFound) {
+		t.Errorf("want err to match %v, got %v", lockfile.ErrNotFound, err)
+	}
+}
+
+func TestLockfilePutAliasError(t *testing.T) {
+	tests := []struct {
+		name    string
+		tool    tool.Tool
+		wantErr error
+	}{
+		{
+			name:    "alias conflicts with name",
+			tool:    tool.Tool{ImportPath: "example.org/z/random/stringer/v2/cmd/stringer", Version: "v2.1.0", Alias: "go-fish"},
+			wantErr: lockfile.ErrAliasConflict,
+		},
+		{
+			name:    "alias conflicts with alias",
+			tool:    tool.Tool{ImportPath: "example.org/z/random/stringer/v2/cmd/stringer", Version: "v2.1.0", Alias: "Lint"},
+			wantErr: lockfile.ErrAliasConflict,
+		},
+		{
+			name:    "name conflicts with alias",
+			tool:    tool.Tool{ImportPath: "example.org/z/random/lint/cmd/lint", Version: "v2.1.0"},
+			wantErr: lockfile.ErrAliasConflict,
+		},
+		{
+			name: "invalid alias",
+			tool: tool.Tool{ImportPath: "example.org/z/random/stringer/v2/cmd/stringer", Version: "v2.1.0", Alias: "cmd/stringer"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lf := newLockfile(t, []tool.Tool{
+				{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+				{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0", Alias: "lint"},
+			})
+			err := lf.PutTool(tt.tool)
+			if err == nil {
+				t.Fatal("want non-nil error, got nil")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestLockfileIter(t *testing.T) {
 	lf := newLockfile(t, []tool.Tool{
 		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
@@ -261,8 +518,8 @@ func TestLockfileIter(t *testing.T) {
 func TestLockfileWriteTo(t *testing.T) {
 	lf := newLockfile(t, []tool.Tool{
 		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
-		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
-		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58"},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0", Why: "lints in CI"},
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58", ModulePath: "golang.org/x/tools"},
 		{ImportPath: "example.org/z/random/stringer/v2/cmd/stringer", Version: "v2.1.0"},
 	})
 
@@ -278,15 +535,18 @@ func TestLockfileWriteTo(t *testing.T) {
 	}
 
 	want := map[string]interface{}{
+		"lockfileVersion": float64(lockfile.FormatVersion),
 		"tools": map[string]interface{}{
 			"github.com/cszatmary/go-fish": map[string]interface{}{
 				"version": "v0.1.0",
 			},
 			"github.com/golangci/golangci-lint/cmd/golangci-lint": map[string]interface{}{
 				"version": "v1.33.0",
+				"why":     "lints in CI",
 			},
 			"golang.org/x/tools/cmd/stringer": map[string]interface{}{
 				"version": "v0.0.0-20201211185031-d93e913c1a58",
+				"module":  "golang.org/x/tools",
 			},
 			"example.org/z/random/stringer/v2/cmd/stringer": map[string]interface{}{
 				"version": "v2.1.0",
@@ -311,10 +571,12 @@ func TestParse(t *testing.T) {
 			"version": "v0.1.0"
 		  },
 		  "github.com/golangci/golangci-lint/cmd/golangci-lint": {
-			"version": "v1.33.0"
+			"version": "v1.33.0",
+			"why": "lints in CI"
 		  },
 		  "golang.org/x/tools/cmd/stringer": {
-			"version": "v0.0.0-20201211185031-d93e913c1a58"
+			"version": "v0.0.0-20201211185031-d93e913c1a58",
+			"module": "golang.org/x/tools"
 		  },
 		  "example.org/z/random/stringer/v2/cmd/stringer": {
 			"version": "v2.1.0"
@@ -339,7 +601,7 @@ func TestParse(t *testing.T) {
 	if err != nil {
 		t.Errorf("want nil error, got %v", err)
 	}
-	want = tool.Tool{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"}
+	want = tool.Tool{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0", Why: "lints in CI"}
 	if tl != want {
 		t.Errorf("got %+v, want %+v", tl, want)
 	}
@@ -348,7 +610,7 @@ func TestParse(t *testing.T) {
 	if err != nil {
 		t.Errorf("want nil error, got %v", err)
 	}
-	want = tool.Tool{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58"}
+	want = tool.Tool{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58", ModulePath: "golang.org/x/tools"}
 	if tl != want {
 		t.Errorf("got %+v, want %+v", tl, want)
 	}
@@ -362,3 +624,269 @@ func TestParse(t *testing.T) {
 		t.Errorf("got %+v, want %+v", tl, want)
 	}
 }
+
+func TestParseModuleMismatch(t *testing.T) {
+	r := strings.NewReader(`{
+		"tools": {
+		  "golang.org/x/tools/cmd/stringer": {
+			"version": "v0.0.0-20201211185031-d93e913c1a58",
+			"module": "golang.org/x/tool"
+		  }
+		}
+	  }`)
+	if _, err := lockfile.Parse(r); err == nil {
+		t.Error("want non-nil error, got nil")
+	}
+}
+
+func TestParseFormatVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantVersion int
+		wantErr     error
+	}{
+		{
+			name:        "no version",
+			data:        `{"tools": {"github.com/cszatmary/go-fish": {"version": "v0.1.0"}}}`,
+			wantVersion: 1,
+		},
+		{
+			name:        "current version",
+			data:        `{"lockfileVersion": 2, "tools": {"github.com/cszatmary/go-fish": {"version": "v0.1.0"}}}`,
+			wantVersion: 2,
+		},
+		{
+			// The newer format must not be deserialized before the version is checked
+			name:    "newer version",
+			data:    `{"lockfileVersion": 3, "tools": ["github.com/cszatmary/go-fish@v0.1.0"]}`,
+			wantErr: lockfile.ErrUnsupportedFormat,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lf, err := lockfile.Parse(strings.NewReader(tt.data))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if v := lf.FileVersion(); v != tt.wantVersion {
+				t.Errorf("got version %d, want %d", v, tt.wantVersion)
+			}
+			if _, err := lf.GetTool("go-fish"); err != nil {
+				t.Errorf("want nil error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	r := strings.NewReader(`{"tools": {"github.com/cszatmary/go-fish": {"version": "v0.1.0"}}}`)
+	var buf bytes.Buffer
+	version, err := lockfile.Migrate(r, &buf)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if version != 1 {
+		t.Errorf("got version %d, want 1", version)
+	}
+	lf, err := lockfile.Parse(&buf)
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if v := lf.FileVersion(); v != lockfile.FormatVersion {
+		t.Errorf("got version %d, want %d", v, lockfile.FormatVersion)
+	}
+}
+
+func TestLockfileScripts(t *testing.T) {
+	lf := &lockfile.Lockfile{}
+	sc := lockfile.Script{
+		Path: "scripts/release.go",
+		Requires: []module.Version{
+			{Path: "golang.org/x/mod", Version: "v0.4.0"},
+			{Path: "github.com/spf13/cobra", Version: "v1.1.1"},
+		},
+	}
+	if err := lf.PutScript(sc); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := lf.PutScript(lockfile.Script{Path: "gen.go"}); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	got, err := lf.GetScript("scripts/release.go")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := lockfile.Script{
+		Path: "scripts/release.go",
+		Requires: []module.Version{
+			{Path: "github.com/spf13/cobra", Version: "v1.1.1"},
+			{Path: "golang.org/x/mod", Version: "v0.4.0"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	var paths []string
+	for _, s := range lf.Scripts() {
+		paths = append(paths, s.Path)
+	}
+	if got, want := strings.Join(paths, ","), "gen.go,scripts/release.go"; got != want {
+		t.Errorf("got scripts %s, want %s", got, want)
+	}
+
+	lf.DeleteScript("gen.go")
+	if _, err := lf.GetScript("gen.go"); !errors.Is(err, lockfile.ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, lockfile.ErrNotFound)
+	}
+}
+
+func TestLockfilePutScriptError(t *testing.T) {
+	tests := []struct {
+		name   string
+		script lockfile.Script
+	}{
+		{"empty path", lockfile.Script{}},
+		{"absolute path", lockfile.Script{Path: "/scripts/release.go"}},
+		{"unclean path", lockfile.Script{Path: "scripts/../release.go"}},
+		{"outside project", lockfile.Script{Path: "../release.go"}},
+		{"backslash", lockfile.Script{Path: `scripts\release.go`}},
+		{"not go file", lockfile.Script{Path: "scripts/release.sh"}},
+		{"invalid module", lockfile.Script{Path: "release.go", Requires: []module.Version{{Path: "not a module", Version: "v1.0.0"}}}},
+		{"query version", lockfile.Script{Path: "release.go", Requires: []module.Version{{Path: "golang.org/x/mod", Version: "master"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lf := &lockfile.Lockfile{}
+			if err := lf.PutScript(tt.script); !errors.Is(err, lockfile.ErrInvalidScript) {
+				t.Errorf("got error %v, want %v", err, lockfile.ErrInvalidScript)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := newLockfile(t, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0", Sum: "h1:fish="},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0", Groups: "ci,dev"},
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.2"},
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.1.0"},
+	})
+	new := newLockfile(t, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.2.0", Sum: "h1:fish2="},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0", Groups: "dev,ci", Why: "lints in CI"},
+		{ImportPath: "github.com/shopify/ejson/cmd/ejson", Version: "v1.2.2", Alias: "ej"},
+		{ImportPath: "github.com/koalaman/shellcheck", Version: "v0.9.0", Release: &tool.Release{Asset: "shellcheck.tar.gz"}},
+	})
+	changes := lockfile.Diff(old, new)
+	type change struct {
+		kind       lockfile.ChangeKind
+		importPath string
+		fields     []string
+	}
+	var got []change
+	for _, c := range changes {
+		got = append(got, change{c.Kind, c.ImportPath(), c.Fields})
+	}
+	want := []change{
+		{lockfile.ChangeChanged, "github.com/cszatmary/go-fish", []string{lockfile.FieldVersion, lockfile.FieldSum}},
+		{lockfile.ChangeChanged, "github.com/golangci/golangci-lint/cmd/golangci-lint", []string{lockfile.FieldWhy}},
+		{lockfile.ChangeAdded, "github.com/koalaman/shellcheck", nil},
+		{lockfile.ChangeChanged, "github.com/shopify/ejson/cmd/ejson", []string{lockfile.FieldAlias}},
+		{lockfile.ChangeRemoved, "golang.org/x/tools/cmd/stringer", nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got changes %+v, want %+v", got, want)
+	}
+
+	if changes := lockfile.Diff(nil, old); len(changes) != 4 || changes[0].Kind != lockfile.ChangeAdded {
+		t.Errorf("got changes %+v, want every tool to be added", changes)
+	}
+	if changes := lockfile.Diff(old, old); len(changes) != 0 {
+		t.Errorf("got changes %+v, want none", changes)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := newLockfile(t, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.2"},
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.1.0"},
+	})
+	ours := newLockfile(t, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.2.0"},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.34.0"},
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.2"},
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.1.0"},
+		{ImportPath: "github.com/koalaman/shellcheck", Version: "v0.9.0"},
+	})
+	theirs := newLockfile(t, []tool.Tool{
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.35.0"},
+		{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.2", Alias: "ej"},
+	})
+
+	tests := []struct {
+		strategy lockfile.MergeStrategy
+		want     []string
+	}{
+		{lockfile.MergeHigher, []string{
+			"github.com/cszatmary/go-fish@v0.2.0",
+			"github.com/golangci/golangci-lint/cmd/golangci-lint@v1.35.0",
+			"github.com/koalaman/shellcheck@v0.9.0",
+			"github.com/Shopify/ejson/cmd/ejson@v1.2.2",
+		}},
+		{lockfile.MergeOurs, []string{
+			"github.com/cszatmary/go-fish@v0.2.0",
+			"github.com/golangci/golangci-lint/cmd/golangci-lint@v1.34.0",
+			"github.com/koalaman/shellcheck@v0.9.0",
+			"github.com/Shopify/ejson/cmd/ejson@v1.2.2",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy.String(), func(t *testing.T) {
+			merged, err := lockfile.Merge(base, ours, theirs, tt.strategy)
+			if err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+			var got []string
+			it := merged.Iter()
+			for it.Next() {
+				got = append(got, it.Value().String())
+			}
+			sort.Strings(got)
+			sort.Strings(tt.want)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got tools %v, want %v", got, tt.want)
+			}
+			ej, err := merged.GetTool("ej")
+			if err != nil || ej.ImportPath != "github.com/Shopify/ejson/cmd/ejson" {
+				t.Errorf("got %v, %v, want alias from theirs to be kept", ej, err)
+			}
+		})
+	}
+
+	_, err := lockfile.Merge(base, ours, theirs, lockfile.MergeNone)
+	var mergeErr *lockfile.MergeError
+	if !errors.As(err, &mergeErr) || !errors.Is(err, lockfile.ErrMergeConflict) {
+		t.Fatalf("got error %v, want *lockfile.MergeError", err)
+	}
+	if len(mergeErr.Conflicts) != 1 || mergeErr.Conflicts[0].Name != "github.com/golangci/golangci-lint/cmd/golangci-lint" {
+		t.Errorf("got conflicts %+v, want golangci-lint", mergeErr.Conflicts)
+	}
+
+	// A tool upgraded on one side and removed on the other can't be resolved by version
+	removed := newLockfile(t, []tool.Tool{
+		{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1.33.0"},
+	})
+	if _, err := lockfile.Merge(base, ours, removed, lockfile.MergeHigher); !errors.Is(err, lockfile.ErrMergeConflict) {
+		t.Errorf("got error %v, want %v", err, lockfile.ErrMergeConflict)
+	}
+	if _, err := lockfile.ParseMergeStrategy("lower"); err == nil {
+		t.Error("want error for invalid strategy, got nil")
+	}
+}
@@ -0,0 +1,213 @@
+package lockfile
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/getshiphub/shed/tool"
+	"golang.org/x/mod/semver"
+)
+
+// ErrMergeConflict is returned by Merge when a tool was changed differently in both lockfiles
+// and the strategy couldn't pick one of the changes.
+var ErrMergeConflict = errors.New("lockfile: merge conflict")
+
+// MergeStrategy decides how Merge resolves a tool or script that was changed differently in both lockfiles.
+type MergeStrategy int
+
+const (
+	// MergeHigher keeps the tool with the higher version. If the versions are the same,
+	// or the tool was removed in one of the lockfiles, it is a conflict.
+	// Scripts can't be compared by version, so they are always a conflict.
+	MergeHigher MergeStrategy = iota
+	// MergeOurs keeps the change in our lockfile.
+	MergeOurs
+	// MergeTheirs keeps the change in their lockfile.
+	MergeTheirs
+	// MergeNone never resolves conflicts.
+	MergeNone
+)
+
+var mergeStrategyNames = map[MergeStrategy]string{
+	MergeHigher: "higher",
+	MergeOurs:   "ours",
+	MergeTheirs: "theirs",
+	MergeNone:   "none",
+}
+
+func (s MergeStrategy) String() string {
+	if name, ok := mergeStrategyNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("MergeStrategy(%d)", int(s))
+}
+
+// ParseMergeStrategy returns the MergeStrategy with the given name, ex: 'higher'.
+func ParseMergeStrategy(name string) (MergeStrategy, error) {
+	for s, n := range mergeStrategyNames {
+		if n == name {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("lockfile: invalid merge strategy %q, must be one of: higher, ours, theirs, none", name)
+}
+
+// Conflict is a tool or script that was changed differently in both lockfiles being merged.
+type Conflict struct {
+	// Name is the import path of the tool or the path of the script.
+	Name string
+	// Reason describes the changes, ex: 'changed to v1.2.0 in ours and v1.3.0 in theirs'.
+	Reason string
+}
+
+// MergeError lists the conflicts that Merge couldn't resolve.
+// It matches ErrMergeConflict when using errors.Is.
+type MergeError struct {
+	// Conflicts are sorted by name.
+	Conflicts []Conflict
+}
+
+func (e *MergeError) Error() string {
+	lines := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		lines[i] = fmt.Sprintf("%s: %s", c.Name, c.Reason)
+	}
+	return fmt.Sprintf("%v: %s", ErrMergeConflict, strings.Join(lines, "; "))
+}
+
+func (e *MergeError) Unwrap() error {
+	return ErrMergeConflict
+}
+
+// Merge does a three-way merge of the lockfiles ours and theirs, which were both changed from base,
+// ex: on two git branches. Tools and scripts that were only changed in one of them, or were changed
+// the same way in both, are merged automatically. Tools are matched by import path, ignoring case,
+// like Diff. If a tool or script was changed differently in both, strategy decides which change
+// is kept. If any conflicts remain, a *MergeError is returned.
+//
+// A nil lockfile is treated as empty, ex: for a base that didn't have a lockfile yet.
+func Merge(base, ours, theirs *Lockfile, strategy MergeStrategy) (*Lockfile, error) {
+	baseTools, ourTools, theirTools := toolsByImportPath(base), toolsByImportPath(ours), toolsByImportPath(theirs)
+	keys := make(map[string]bool)
+	for _, tools := range []map[string]tool.Tool{baseTools, ourTools, theirTools} {
+		for k := range tools {
+			keys[k] = true
+		}
+	}
+
+	merged := &Lockfile{}
+	var conflicts []Conflict
+	for k := range keys {
+		bt, inBase := baseTools[k]
+		ot, inOurs := ourTools[k]
+		tt, inTheirs := theirTools[k]
+		sameTool := func(a tool.Tool, inA bool, b tool.Tool, inB bool) bool {
+			return inA == inB && (!inA || len(changedFields(a, b)) == 0)
+		}
+
+		var t tool.Tool
+		var keep bool
+		switch {
+		case sameTool(ot, inOurs, tt, inTheirs) || sameTool(bt, inBase, tt, inTheirs):
+			t, keep = ot, inOurs
+		case sameTool(bt, inBase, ot, inOurs):
+			t, keep = tt, inTheirs
+		case strategy == MergeOurs:
+			t, keep = ot, inOurs
+		case strategy == MergeTheirs:
+			t, keep = tt, inTheirs
+		case strategy == MergeHigher && inOurs && inTheirs && semver.Compare(ot.Version, tt.Version) > 0:
+			t, keep = ot, true
+		case strategy == MergeHigher && inOurs && inTheirs && semver.Compare(ot.Version, tt.Version) < 0:
+			t, keep = tt, true
+		default:
+			name := ot.ImportPath
+			if !inOurs {
+				name = tt.ImportPath
+			}
+			conflicts = append(conflicts, Conflict{Name: name, Reason: toolConflict(ot, inOurs, tt, inTheirs)})
+			continue
+		}
+		if !keep {
+			continue
+		}
+		if err := merged.PutTool(t); err != nil {
+			return nil, fmt.Errorf("lockfile: failed to merge %s: %w", t.ImportPath, err)
+		}
+	}
+
+	baseScripts, ourScripts, theirScripts := scriptsByPath(base), scriptsByPath(ours), scriptsByPath(theirs)
+	paths := make(map[string]bool)
+	for _, scripts := range []map[string]Script{baseScripts, ourScripts, theirScripts} {
+		for p := range scripts {
+			paths[p] = true
+		}
+	}
+	for p := range paths {
+		bsc, inBase := baseScripts[p]
+		osc, inOurs := ourScripts[p]
+		tsc, inTheirs := theirScripts[p]
+		sameScript := func(a Script, inA bool, b Script, inB bool) bool {
+			return inA == inB && (!inA || reflect.DeepEqual(a.Requires, b.Requires))
+		}
+
+		var s Script
+		var keep bool
+		switch {
+		case sameScript(osc, inOurs, tsc, inTheirs) || sameScript(bsc, inBase, tsc, inTheirs):
+			s, keep = osc, inOurs
+		case sameScript(bsc, inBase, osc, inOurs):
+			s, keep = tsc, inTheirs
+		case strategy == MergeOurs:
+			s, keep = osc, inOurs
+		case strategy == MergeTheirs:
+			s, keep = tsc, inTheirs
+		default:
+			conflicts = append(conflicts, Conflict{Name: p, Reason: "script changed in both"})
+			continue
+		}
+		if !keep {
+			continue
+		}
+		if err := merged.PutScript(s); err != nil {
+			return nil, fmt.Errorf("lockfile: failed to merge %s: %w", p, err)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		sort.Slice(conflicts, func(i, j int) bool {
+			return conflicts[i].Name < conflicts[j].Name
+		})
+		return nil, &MergeError{Conflicts: conflicts}
+	}
+	return merged, nil
+}
+
+// toolConflict describes how a tool was changed differently in ours and theirs.
+func toolConflict(ours tool.Tool, inOurs bool, theirs tool.Tool, inTheirs bool) string {
+	describe := func(t tool.Tool, in bool) string {
+		if !in {
+			return "removed"
+		}
+		return "changed to " + t.Version
+	}
+	if inOurs && inTheirs && ours.Version == theirs.Version {
+		return fmt.Sprintf("changed to %s in both with different %s", ours.Version, strings.Join(changedFields(ours, theirs), ", "))
+	}
+	return fmt.Sprintf("%s in ours and %s in theirs", describe(ours, inOurs), describe(theirs, inTheirs))
+}
+
+// scriptsByPath returns the scripts in lf keyed by their path.
+func scriptsByPath(lf *Lockfile) map[string]Script {
+	scripts := make(map[string]Script)
+	if lf == nil {
+		return scripts
+	}
+	for _, s := range lf.Scripts() {
+		scripts[s.Path] = s
+	}
+	return scripts
+}
@@ -0,0 +1,96 @@
+package lockfile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// FormatVersion is the version of the lockfile format written by this package.
+// It is increased whenever the format changes in a way that older versions of shed
+// can't read correctly. Lockfiles without a version are version 1.
+const FormatVersion = 2
+
+// ErrUnsupportedFormat is returned when parsing a lockfile with a format version that is
+// newer than FormatVersion, which means it was written by a newer version of shed.
+var ErrUnsupportedFormat = errors.New("lockfile: unsupported format version")
+
+// migrations upgrade the lockfile format. migrations[i] upgrades a lockfile from version i+1
+// to version i+2. Each migration is given the top level fields of the lockfile and modifies them in place.
+var migrations = []func(fields map[string]json.RawMessage) error{
+	// Version 2 added the version itself, along with the why, reproducible, build.env and branch fields
+	// of tools. The fields are optional, so version 1 lockfiles need no changes.
+	func(fields map[string]json.RawMessage) error { return nil },
+}
+
+// FileVersion returns the format version of the data lf was parsed from, before it was migrated.
+// If lf was not parsed, FormatVersion is returned. Writing lf always uses FormatVersion.
+func (lf *Lockfile) FileVersion() int {
+	if lf.version == 0 {
+		return FormatVersion
+	}
+	return lf.version
+}
+
+// Migrate reads a lockfile from r, upgrades it to FormatVersion, and writes it to w.
+// It returns the format version of the lockfile read from r. If the lockfile already
+// uses FormatVersion, it is written as is after being parsed, see Parse for details.
+//
+// Parse migrates older lockfiles automatically, so Migrate is only needed to rewrite
+// lockfiles without modifying them otherwise, ex: before committing them.
+func Migrate(r io.Reader, w io.Writer) (int, error) {
+	lf, err := Parse(r)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := lf.WriteTo(w); err != nil {
+		return lf.version, fmt.Errorf("lockfile: failed to write migrated lockfile: %w", err)
+	}
+	return lf.version, nil
+}
+
+// migrate upgrades data, the JSON of a lockfile, to target, the newest format version supported.
+// Parse uses FormatVersion, older versions are only used to check how older versions of shed behave.
+// It returns the upgraded data and the version data had. If data has a newer version than target,
+// the error matches ErrUnsupportedFormat.
+func migrate(data []byte, target int) ([]byte, int, error) {
+	var header struct {
+		Version *int `json:"lockfileVersion"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, 0, fmt.Errorf("lockfile: failed to deserialize JSON: %w", err)
+	}
+	version := 1
+	if header.Version != nil {
+		version = *header.Version
+	}
+	switch {
+	case version < 1:
+		return nil, 0, fmt.Errorf("lockfile: invalid format version %d", version)
+	case version > target:
+		err := fmt.Errorf("%w: lockfile has format version %d, but this version of shed only supports up to version %d; upgrade shed to use this lockfile", ErrUnsupportedFormat, version, target)
+		return nil, version, err
+	case version == target:
+		return data, version, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, version, fmt.Errorf("lockfile: failed to deserialize JSON: %w", err)
+	}
+	if fields == nil {
+		// The lockfile is null, which is the same as an empty lockfile
+		fields = make(map[string]json.RawMessage)
+	}
+	for v := version; v < target; v++ {
+		if err := migrations[v-1](fields); err != nil {
+			return nil, version, fmt.Errorf("lockfile: failed to migrate from version %d to %d: %w", v, v+1, err)
+		}
+	}
+	migrated, err := json.Marshal(fields)
+	if err != nil {
+		return nil, version, fmt.Errorf("lockfile: failed to serialize migrated lockfile: %w", err)
+	}
+	return migrated, version, nil
+}
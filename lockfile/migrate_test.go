@@ -0,0 +1,76 @@
+package lockfile
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/getshiphub/shed/tool"
+)
+
+// TestOlderVersionsRejectNewFields checks that lockfiles using fields added in a format version
+// can't be read by versions of shed that only support older format versions, since they would
+// drop the fields when writing the lockfile.
+func TestOlderVersionsRejectNewFields(t *testing.T) {
+	tests := []struct {
+		name string
+		tool tool.Tool
+		// version is the format version that added the field
+		version int
+	}{
+		{
+			name: "why",
+			tool: tool.Tool{
+				ImportPath: "github.com/cszatmary/go-fish",
+				Version:    "v0.1.0",
+				Why:        "formats the fish",
+			},
+			version: 2,
+		},
+		{
+			name: "reproducible",
+			tool: tool.Tool{
+				ImportPath:   "github.com/cszatmary/go-fish",
+				Version:      "v0.1.0",
+				Reproducible: &tool.Reproducible{GoVersion: "go1.22.4"},
+			},
+			version: 2,
+		},
+		{
+			name: "build env",
+			tool: tool.Tool{
+				ImportPath: "github.com/cszatmary/go-fish",
+				Version:    "v0.1.0",
+				BuildFlags: tool.BuildFlags{Env: "CGO_ENABLED=1"},
+			},
+			version: 2,
+		},
+		{
+			name: "branch",
+			tool: tool.Tool{
+				ImportPath: "github.com/cszatmary/go-fish",
+				Version:    "v0.1.1-0.20210101000000-0123456789ab",
+				Branch:     "main",
+			},
+			version: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lf := &Lockfile{}
+			if err := lf.PutTool(tt.tool); err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+			var buf bytes.Buffer
+			if _, err := lf.WriteTo(&buf); err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+			if _, _, err := migrate(buf.Bytes(), tt.version-1); !errors.Is(err, ErrUnsupportedFormat) {
+				t.Errorf("got error %v reading with format version %d, want %v", err, tt.version-1, ErrUnsupportedFormat)
+			}
+			if _, _, err := migrate(buf.Bytes(), tt.version); err != nil {
+				t.Errorf("want nil error reading with format version %d, got %v", tt.version, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package lockfile
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/getshiphub/shed/tool"
+)
+
+// maxSuggestions is the maximum number of suggestions in a *NotFoundError.
+const maxSuggestions = 3
+
+// NotFoundError describes a name that doesn't refer to any tool. It is returned by GetTool
+// and matches ErrNotFound when using errors.Is.
+type NotFoundError struct {
+	// Name is the name or import path that wasn't found.
+	Name string
+	// Suggestions are the names of the tools that Name may have meant, ex: because of a typo,
+	// most similar first. The import path is used for tools whose name isn't unique.
+	Suggestions []string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrNotFound, e.Name)
+}
+
+func (e *NotFoundError) Unwrap() error {
+	return ErrNotFound
+}
+
+// notFound returns a *NotFoundError for name with suggestions of tools that are similar to it.
+func (lf *Lockfile) notFound(name string) error {
+	return &NotFoundError{Name: name, Suggestions: lf.Suggest(name)}
+}
+
+// Candidates returns all the tools that name could refer to, sorted by import path. These are the tools
+// whose name or import path is name, and the tools whose import path ends with name, ex: 'tools/cmd/stringer'
+// for golang.org/x/tools/cmd/stringer. Names are matched case-insensitively and a version in name is ignored.
+//
+// Unlike GetTool, which only returns a tool if name refers to exactly one of them, Candidates returns every
+// match, ex: to let the user choose between tools that share a name.
+func (lf *Lockfile) Candidates(name string) []tool.Tool {
+	if i := strings.LastIndexByte(name, '@'); i != -1 {
+		name = name[:i]
+	}
+	seen := make(map[string]bool)
+	var tools []tool.Tool
+	add := func(found []tool.Tool) {
+		for _, t := range found {
+			if !seen[t.ImportPath] {
+				seen[t.ImportPath] = true
+				tools = append(tools, t)
+			}
+		}
+	}
+	add(lf.lookup(name))
+	add(lf.findImportPath(name))
+	add(lf.findSuffix(name))
+	sortByImportPath(tools)
+	return tools
+}
+
+// getBySuffix returns the tool whose import path ends with the path elements in name, which isn't
+// a valid import path, ex: 'tools/cmd/stringer'. ok is false if no tool matches. If more than one tool
+// matches, a *NameError is returned. If name has a version, it must match the version of the tool.
+func (lf *Lockfile) getBySuffix(name string) (t tool.Tool, ok bool, err error) {
+	suffix, version := name, ""
+	if i := strings.LastIndexByte(name, '@'); i != -1 {
+		suffix, version = name[:i], name[i+1:]
+	}
+	found := lf.findSuffix(suffix)
+	switch {
+	case len(found) == 0:
+		return tool.Tool{}, false, nil
+	case len(found) > 1:
+		sortByImportPath(found)
+		reason := fmt.Sprintf("%d tools with import paths ending in %s found", len(found), suffix)
+		return tool.Tool{}, true, &NameError{Kind: ErrMultipleTools, Name: suffix, Tools: found, Reason: reason}
+	}
+	t = found[0]
+	if version != "" && version != t.Version {
+		return t, true, fmt.Errorf("%w: wanted %s", ErrIncorrectVersion, version)
+	}
+	return t, true, nil
+}
+
+// findSuffix returns all tools with an import path that ends with the path elements in suffix,
+// matched case-insensitively.
+func (lf *Lockfile) findSuffix(suffix string) []tool.Tool {
+	suffix = "/" + strings.ToLower(strings.Trim(suffix, "/"))
+	if suffix == "/" {
+		return nil
+	}
+	var tools []tool.Tool
+	for _, bucket := range lf.tools {
+		for _, t := range bucket {
+			if strings.HasSuffix(strings.ToLower(t.ImportPath), suffix) {
+				tools = append(tools, t)
+			}
+		}
+	}
+	return tools
+}
+
+// Suggest returns the names of the tools that name may have meant, most similar first, ex: 'golangci-lint'
+// for 'golangci-lnt'. Only the last element of name is compared if it is an import path, and a version is ignored.
+// Tools are suggested if their name is a small number of edits away from name, or contains it. The import path
+// is returned for tools whose name isn't unique. At most three tools are suggested.
+func (lf *Lockfile) Suggest(name string) []string {
+	if i := strings.LastIndexByte(name, '@'); i != -1 {
+		name = name[:i]
+	}
+	want := strings.ToLower(path.Base(name))
+	if want == "" || want == "." || want == "/" {
+		return nil
+	}
+	maxDist := len(want) / 3
+	if maxDist < 1 {
+		maxDist = 1
+	}
+
+	type suggestion struct {
+		label string
+		dist  int
+	}
+	var suggestions []suggestion
+	for toolName, bucket := range lf.tools {
+		lower := strings.ToLower(toolName)
+		dist := editDistance(want, lower)
+		if dist > maxDist && (len(want) < 3 || !strings.Contains(lower, want)) {
+			continue
+		}
+		for _, t := range bucket {
+			label := toolName
+			if len(bucket) > 1 {
+				label = t.ImportPath
+			}
+			suggestions = append(suggestions, suggestion{label, dist})
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].dist != suggestions[j].dist {
+			return suggestions[i].dist < suggestions[j].dist
+		}
+		return suggestions[i].label < suggestions[j].label
+	})
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+	var labels []string
+	for _, s := range suggestions {
+		labels = append(labels, s.label)
+	}
+	return labels
+}
+
+// editDistance returns the Levenshtein distance between a and b, which is the number of
+// single byte insertions, deletions, or substitutions needed to change a into b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// sortByImportPath sorts tools by import path.
+func sortByImportPath(tools []tool.Tool) {
+	sort.Slice(tools, func(i, j int) bool {
+		return tools[i].ImportPath < tools[j].ImportPath
+	})
+}
@@ -0,0 +1,151 @@
+package lockfile_test
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// sortedTools returns all tools in lf sorted by import path.
+func sortedTools(lf *lockfile.Lockfile) []tool.Tool {
+	var tools []tool.Tool
+	it := lf.Iter()
+	for it.Next() {
+		tools = append(tools, it.Value())
+	}
+	sort.Slice(tools, func(i, j int) bool {
+		return tools[i].ImportPath < tools[j].ImportPath
+	})
+	return tools
+}
+
+// checkRoundTrip checks that writing lf and parsing the result produces the same lockfile.
+// It returns the written data.
+func checkRoundTrip(t *testing.T, lf *lockfile.Lockfile) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if _, err := lf.WriteTo(buf); err != nil {
+		t.Fatalf("failed to write lockfile: %v", err)
+	}
+	data := buf.Bytes()
+	parsed, err := lockfile.Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse written lockfile: %v\n%s", err, data)
+	}
+	if got, want := sortedTools(parsed), sortedTools(lf); !reflect.DeepEqual(got, want) {
+		t.Fatalf("round trip mismatch: got %+v, want %+v", got, want)
+	}
+
+	// Writing should be deterministic
+	buf.Reset()
+	if _, err := parsed.WriteTo(buf); err != nil {
+		t.Fatalf("failed to write lockfile: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatalf("writing lockfile is not deterministic: got\n%s\nwant\n%s", buf.Bytes(), data)
+	}
+	return data
+}
+
+func TestParseGolden(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "golden", "*.lock"))
+	if err != nil {
+		t.Fatalf("failed to find golden files: %v", err)
+	}
+	for _, p := range paths {
+		p := p
+		t.Run(filepath.Base(p), func(t *testing.T) {
+			data, err := ioutil.ReadFile(p)
+			if err != nil {
+				t.Fatalf("failed to read %s: %v", p, err)
+			}
+			lf, err := lockfile.Parse(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+			got := checkRoundTrip(t, lf)
+
+			goldenPath := strings.TrimSuffix(p, ".lock") + ".golden"
+			if *update {
+				if err := ioutil.WriteFile(goldenPath, got, 0o644); err != nil {
+					t.Fatalf("failed to update golden file: %v", err)
+				}
+				return
+			}
+			want, err := ioutil.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("failed to read golden file, run with -update to create it: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "invalid", "*.lock"))
+	if err != nil {
+		t.Fatalf("failed to find invalid files: %v", err)
+	}
+	for _, p := range paths {
+		p := p
+		t.Run(filepath.Base(p), func(t *testing.T) {
+			f, err := os.Open(p)
+			if err != nil {
+				t.Fatalf("failed to open %s: %v", p, err)
+			}
+			defer f.Close()
+			if lf, err := lockfile.Parse(f); err == nil {
+				t.Errorf("want non-nil error, got lockfile with tools %+v", sortedTools(lf))
+			}
+		})
+	}
+}
+
+// randomLockfile is used to generate lockfiles for property tests.
+type randomLockfile struct {
+	tools []tool.Tool
+}
+
+func (randomLockfile) Generate(r *rand.Rand, size int) reflect.Value {
+	hosts := []string{"github.com", "golang.org/x", "example.org", "go.uber.org"}
+	names := []string{"stringer", "golangci-lint", "go-fish", "mockgen", "goimports", "protoc-gen-go"}
+	versions := []string{"v0.1.0", "v1.33.0", "v2.1.0", "v0.0.0-20201211185031-d93e913c1a58", "v1.2.3-rc.1"}
+
+	var rl randomLockfile
+	n := r.Intn(size + 1)
+	for i := 0; i < n; i++ {
+		importPath := hosts[r.Intn(len(hosts))] + "/" + names[r.Intn(len(names))]
+		// Add some extra path elements so tools with the same name but different import paths are generated
+		for j := r.Intn(3); j > 0; j-- {
+			importPath += "/" + names[r.Intn(len(names))]
+		}
+		rl.tools = append(rl.tools, tool.Tool{ImportPath: importPath, Version: versions[r.Intn(len(versions))]})
+	}
+	return reflect.ValueOf(rl)
+}
+
+func TestParseWriteToRoundTrip(t *testing.T) {
+	f := func(rl randomLockfile) bool {
+		lf := newLockfile(t, rl.tools)
+		checkRoundTrip(t, lf)
+		return true
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,95 @@
+package lockfile
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// ErrInvalidScript is returned when adding a script with an invalid path or dependency to a lockfile.
+var ErrInvalidScript = errors.New("lockfile: invalid script")
+
+// Script is a Go script in the lockfile. A script is a single file main package in the project,
+// ex: a release or code generation script, that is run with its dependencies at the versions
+// in the lockfile, so that scripts are as reproducible as tools.
+type Script struct {
+	// Path is the path of the script file relative to the project root, using forward slashes.
+	Path string
+	// Requires are the modules the script needs, including indirect dependencies, sorted by path.
+	// Each version must be exact, see CheckVersion.
+	Requires []module.Version
+}
+
+// CheckScript checks that s can be stored in a lockfile. The path must be a clean relative path
+// to a .go file inside the project, and each module must have a valid path and an exact version.
+// If s is invalid, the error matches ErrInvalidScript.
+func CheckScript(s Script) error {
+	p := s.Path
+	switch {
+	case p == "" || path.IsAbs(p) || strings.Contains(p, `\`) || path.Clean(p) != p:
+		return fmt.Errorf("%w: path %q must be a clean relative path using forward slashes", ErrInvalidScript, p)
+	case p == ".." || strings.HasPrefix(p, "../"):
+		return fmt.Errorf("%w: path %q must be inside the project", ErrInvalidScript, p)
+	case path.Ext(p) != ".go":
+		return fmt.Errorf("%w: path %q must be a .go file", ErrInvalidScript, p)
+	}
+	for _, mod := range s.Requires {
+		if err := module.CheckPath(mod.Path); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidScript, p, err)
+		}
+		if reason := checkVersion(mod.Version); reason != "" {
+			return fmt.Errorf("%w: %s: module %s has invalid version %q: %s", ErrInvalidScript, p, mod.Path, mod.Version, reason)
+		}
+	}
+	return nil
+}
+
+// GetScript retrieves the script with the given path from the lockfile.
+// If there is no script with the path, ErrNotFound is returned.
+func (lf *Lockfile) GetScript(p string) (Script, error) {
+	s, ok := lf.scripts[p]
+	if !ok {
+		return Script{}, fmt.Errorf("%w: script %s", ErrNotFound, p)
+	}
+	return s, nil
+}
+
+// PutScript adds or replaces the script with the same path in the lockfile.
+// s must be valid, see CheckScript.
+func (lf *Lockfile) PutScript(s Script) error {
+	if err := CheckScript(s); err != nil {
+		return err
+	}
+	if lf.scripts == nil {
+		lf.scripts = make(map[string]Script)
+	}
+	requires := make([]module.Version, len(s.Requires))
+	copy(requires, s.Requires)
+	sort.Slice(requires, func(i, j int) bool {
+		return requires[i].Path < requires[j].Path
+	})
+	s.Requires = requires
+	lf.scripts[s.Path] = s
+	return nil
+}
+
+// DeleteScript removes the script with the given path from the lockfile if it exists.
+func (lf *Lockfile) DeleteScript(p string) {
+	delete(lf.scripts, p)
+}
+
+// Scripts returns all the scripts in the lockfile sorted by path.
+func (lf *Lockfile) Scripts() []Script {
+	scripts := make([]Script, 0, len(lf.scripts))
+	for _, s := range lf.scripts {
+		scripts = append(scripts, s)
+	}
+	sort.Slice(scripts, func(i, j int) bool {
+		return scripts[i].Path < scripts[j].Path
+	})
+	return scripts
+}
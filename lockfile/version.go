@@ -0,0 +1,89 @@
+package lockfile
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/getshiphub/shed/tool"
+	"golang.org/x/mod/semver"
+)
+
+// VersionError is returned when adding a tool with an invalid version to a lockfile.
+// It matches ErrInvalidVersion when using errors.Is.
+type VersionError struct {
+	// Tool is the tool that has an invalid version.
+	Tool tool.Tool
+	// Reason explains why the version is invalid.
+	Reason string
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("%v: %v: %s", ErrInvalidVersion, e.Tool, e.Reason)
+}
+
+func (e *VersionError) Unwrap() error {
+	return ErrInvalidVersion
+}
+
+const incompatibleSuffix = "+incompatible"
+
+// pseudoVersionRE matches pseudo-versions, see https://golang.org/ref/mod#pseudo-versions.
+var pseudoVersionRE = regexp.MustCompile(`^v[0-9]+\.(0\.0-|\d+\.\d+-([^+]*\.)?0\.)\d{14}-[A-Za-z0-9]+$`)
+
+// pseudoTimestampRE matches the timestamp and revision at the end of a pseudo-version.
+var pseudoTimestampRE = regexp.MustCompile(`(^|[-.])(\d{14})-([A-Za-z0-9]+)$`)
+
+// IsPseudoVersion reports whether version is a pseudo-version, which refers to a commit
+// that wasn't tagged, ex: 'v0.0.0-20201211185031-d93e913c1a58'.
+func IsPseudoVersion(version string) bool {
+	return pseudoVersionRE.MatchString(strings.TrimSuffix(version, incompatibleSuffix))
+}
+
+// CheckVersion checks that the version of t can be stored in a lockfile. It must be either a
+// canonical semantic version, ex: 'v1.2.3', or a valid pseudo-version. The '+incompatible' suffix
+// is allowed for major versions 2 and above. If the version is invalid, a *VersionError is returned.
+func CheckVersion(t tool.Tool) error {
+	if reason := checkVersion(t.Version); reason != "" {
+		return &VersionError{Tool: t, Reason: reason}
+	}
+	return nil
+}
+
+// checkVersion returns the reason version is invalid, or an empty string if it is valid.
+func checkVersion(version string) string {
+	if !semver.IsValid(version) {
+		return "not a semantic version, module queries like branches or commits must be resolved to a version"
+	}
+	v := version
+	if strings.HasSuffix(v, incompatibleSuffix) {
+		v = strings.TrimSuffix(v, incompatibleSuffix)
+		if semver.Major(v) == "v0" || semver.Major(v) == "v1" {
+			return fmt.Sprintf("%s can only be used with major version v2 or higher", incompatibleSuffix)
+		}
+	}
+	if c := semver.Canonical(v); v != c {
+		if semver.Build(v) != "" {
+			return "build metadata is not allowed"
+		}
+		return fmt.Sprintf("shorthand version, use the full version %s", c)
+	}
+
+	// Anything that looks like it is meant to be a pseudo-version must be a valid one,
+	// otherwise it will fail later when the go command tries to resolve it
+	m := pseudoTimestampRE.FindStringSubmatch(semver.Prerelease(v))
+	if m == nil {
+		return ""
+	}
+	if !pseudoVersionRE.MatchString(v) {
+		return "invalid pseudo-version, must be in the form vX.0.0-yyyymmddhhmmss-abcdefabcdef, vX.Y.(Z+1)-0.yyyymmddhhmmss-abcdefabcdef, or vX.Y.Z-pre.0.yyyymmddhhmmss-abcdefabcdef"
+	}
+	if _, err := time.Parse("20060102150405", m[2]); err != nil {
+		return fmt.Sprintf("invalid pseudo-version, %s is not a valid timestamp", m[2])
+	}
+	if rev := m[3]; len(rev) != 12 || strings.Trim(rev, "0123456789abcdef") != "" {
+		return fmt.Sprintf("invalid pseudo-version, %s is not a 12 character lowercase commit hash", rev)
+	}
+	return ""
+}
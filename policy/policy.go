@@ -0,0 +1,149 @@
+// Package policy checks shed lockfiles against rules for which tools can be used,
+// so that an organization can enforce the same rules across all of its repositories.
+//
+// A Policy can be checked directly with Check, or served over HTTP with Handler so
+// that checks, like CI jobs, can call a central service instead of running shed themselves.
+// It can also be enforced when tools are installed, see client.WithPolicy.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/tool"
+	"golang.org/x/mod/module"
+)
+
+// maxLockfileSize is the maximum size of a lockfile accepted by Handler.
+const maxLockfileSize = 1 << 20
+
+// Policy is a set of rules that the tools in a lockfile must follow.
+// The zero value allows any valid lockfile.
+type Policy struct {
+	// AllowedSources is a list of glob patterns of import path prefixes that tools must match,
+	// with the same syntax as GOPRIVATE, ex: 'github.com/acme/*'. If empty, all sources are allowed.
+	AllowedSources []string `json:"allowedSources,omitempty"`
+	// RequireSums requires every tool to have a module hash in the lockfile,
+	// so that the module can't be changed without it being detected.
+	RequireSums bool `json:"requireSums,omitempty"`
+	// RequireReleases requires every tool to be at a released version. Pseudo-versions, which refer to
+	// commits that weren't tagged, ex: from installing a branch, aren't allowed.
+	RequireReleases bool `json:"requireReleases,omitempty"`
+	// DenyVulnerable denies tools that are affected by known vulnerabilities. Looking up vulnerabilities
+	// requires the network, so Check and Handler don't enforce it, it is enforced by client.WithPolicy.
+	DenyVulnerable bool `json:"denyVulnerable,omitempty"`
+}
+
+// Parse reads a policy in JSON format from r.
+func Parse(r io.Reader) (*Policy, error) {
+	var p Policy
+	dec := json.NewDecoder(r)
+	// Catch typos, since an ignored rule would silently allow tools
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("policy: failed to deserialize JSON: %w", err)
+	}
+	for _, pattern := range p.AllowedSources {
+		if pattern == "" || strings.Contains(pattern, ",") {
+			return nil, fmt.Errorf("policy: invalid allowed source %q", pattern)
+		}
+	}
+	return &p, nil
+}
+
+// Violation describes a tool that doesn't follow a policy.
+type Violation struct {
+	// Tool is the import path and version of the tool.
+	Tool string `json:"tool"`
+	// Reason describes the rule that the tool broke.
+	Reason string `json:"reason"`
+}
+
+func (v Violation) Error() string {
+	return fmt.Sprintf("tool %s does not follow the policy: %s", v.Tool, v.Reason)
+}
+
+// Check returns the tools in lf that don't follow the policy, sorted by tool.
+func (p *Policy) Check(lf *lockfile.Lockfile) []Violation {
+	var violations []Violation
+	it := lf.Iter()
+	for it.Next() {
+		violations = append(violations, p.CheckTool(it.Value())...)
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Tool != violations[j].Tool {
+			return violations[i].Tool < violations[j].Tool
+		}
+		return violations[i].Reason < violations[j].Reason
+	})
+	return violations
+}
+
+// CheckTool returns the rules of the policy that t breaks. The version of t can be a query,
+// ex: 'latest', in which case only the rules that don't depend on the version are checked.
+func (p *Policy) CheckTool(t tool.Tool) []Violation {
+	var violations []Violation
+	if len(p.AllowedSources) > 0 && !module.MatchPrefixPatterns(strings.Join(p.AllowedSources, ","), t.ImportPath) {
+		violations = append(violations, Violation{Tool: t.String(), Reason: "source is not allowed"})
+	}
+	if p.RequireSums && t.Sum == "" && t.HasSemver() {
+		violations = append(violations, Violation{Tool: t.String(), Reason: "module hash is missing"})
+	}
+	if p.RequireReleases && lockfile.IsPseudoVersion(t.Version) {
+		violations = append(violations, Violation{Tool: t.String(), Reason: "pseudo-version is not allowed"})
+	}
+	return violations
+}
+
+// Result is the response of Handler.
+type Result struct {
+	// OK reports whether the lockfile is valid and follows the policy.
+	OK bool `json:"ok"`
+	// Errors are the problems found parsing the lockfile.
+	Errors []string `json:"errors,omitempty"`
+	// Violations are the tools that don't follow the policy.
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+// Handler returns an HTTP handler that checks lockfiles against p. A lockfile is checked
+// by sending it as the body of a POST request. The response is a Result in JSON format.
+// The status is 200 if the lockfile was checked, even if it doesn't follow the policy,
+// and 400 if the lockfile is invalid. The handler never modifies anything, so it is safe
+// to expose to untrusted clients.
+func Handler(p *Policy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed, send the lockfile with POST", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var res Result
+		status := http.StatusOK
+		lf, err := lockfile.Parse(http.MaxBytesReader(w, r.Body, maxLockfileSize))
+		if err != nil {
+			status = http.StatusBadRequest
+			errs, ok := err.(lockfile.ErrorList)
+			if !ok {
+				errs = lockfile.ErrorList{err}
+			}
+			for _, e := range errs {
+				res.Errors = append(res.Errors, e.Error())
+			}
+			sort.Strings(res.Errors)
+		} else {
+			res.Violations = p.Check(lf)
+			res.OK = len(res.Violations) == 0
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		// The status has already been written so there is nothing that can be done if this fails
+		json.NewEncoder(w).Encode(res) //nolint:errcheck
+	})
+}
@@ -0,0 +1,161 @@
+package policy_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/policy"
+	"github.com/getshiphub/shed/tool"
+)
+
+func TestParse(t *testing.T) {
+	p, err := policy.Parse(strings.NewReader(`{"allowedSources": ["github.com/acme/*"], "requireSums": true, "requireReleases": true, "denyVulnerable": true}`))
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := &policy.Policy{AllowedSources: []string{"github.com/acme/*"}, RequireSums: true, RequireReleases: true, DenyVulnerable: true}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("got %+v, want %+v", p, want)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"unknown field", `{"allowedSource": ["github.com/acme/*"]}`},
+		{"empty source", `{"allowedSources": [""]}`},
+		{"multiple sources in one", `{"allowedSources": ["github.com/acme/*,golang.org/x/*"]}`},
+		{"invalid JSON", `{"allowedSources": }`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := policy.Parse(strings.NewReader(tt.data)); err == nil {
+				t.Error("want non-nil error, got nil")
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	lf := &lockfile.Lockfile{}
+	for _, tl := range []tool.Tool{
+		{ImportPath: "github.com/acme/tools/cmd/gen", Version: "v1.0.0", Sum: "h1:2LQvNcBdwkWytOgG01je44UW/btPPfrcRF0kOTnT318="},
+		{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.1.0"},
+		{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
+	} {
+		if err := lf.PutTool(tl); err != nil {
+			t.Fatalf("failed to add tool %v to lockfile: %v", tl, err)
+		}
+	}
+
+	p := &policy.Policy{AllowedSources: []string{"github.com/acme", "golang.org/x/*"}, RequireSums: true}
+	got := p.Check(lf)
+	want := []policy.Violation{
+		{Tool: "github.com/cszatmary/go-fish@v0.1.0", Reason: "module hash is missing"},
+		{Tool: "github.com/cszatmary/go-fish@v0.1.0", Reason: "source is not allowed"},
+		{Tool: "golang.org/x/tools/cmd/stringer@v0.1.0", Reason: "module hash is missing"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// Only released versions
+	p = &policy.Policy{RequireReleases: true}
+	pseudo := tool.Tool{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58"}
+	if err := lf.PutTool(pseudo); err != nil {
+		t.Fatalf("failed to add tool %v to lockfile: %v", pseudo, err)
+	}
+	got = p.Check(lf)
+	want = []policy.Violation{
+		{Tool: "golang.org/x/tools/cmd/stringer@v0.0.0-20201211185031-d93e913c1a58", Reason: "pseudo-version is not allowed"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// The zero value allows everything
+	if got := (&policy.Policy{}).Check(lf); len(got) != 0 {
+		t.Errorf("got %+v, want no violations", got)
+	}
+}
+
+func TestCheckTool(t *testing.T) {
+	p := &policy.Policy{AllowedSources: []string{"github.com/acme"}, RequireSums: true, RequireReleases: true}
+	// Only the source can be checked before the version is resolved
+	got := p.CheckTool(tool.Tool{ImportPath: "github.com/cszatmary/go-fish", Version: "latest"})
+	want := []policy.Violation{{Tool: "github.com/cszatmary/go-fish@latest", Reason: "source is not allowed"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got := p.CheckTool(tool.Tool{ImportPath: "github.com/acme/tools/cmd/gen", Version: "master"}); len(got) != 0 {
+		t.Errorf("got %+v, want no violations", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	srv := httptest.NewServer(policy.Handler(&policy.Policy{AllowedSources: []string{"github.com/acme"}}))
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		lockfile   string
+		wantStatus int
+		wantResult policy.Result
+	}{
+		{
+			name:       "allowed",
+			lockfile:   `{"tools": {"github.com/acme/tools/cmd/gen": {"version": "v1.0.0"}}}`,
+			wantStatus: http.StatusOK,
+			wantResult: policy.Result{OK: true},
+		},
+		{
+			name:       "violation",
+			lockfile:   `{"tools": {"github.com/cszatmary/go-fish": {"version": "v0.1.0"}}}`,
+			wantStatus: http.StatusOK,
+			wantResult: policy.Result{Violations: []policy.Violation{
+				{Tool: "github.com/cszatmary/go-fish@v0.1.0", Reason: "source is not allowed"},
+			}},
+		},
+		{
+			name:       "invalid lockfile",
+			lockfile:   `{"tools": {"github.com/cszatmary/go-fish": {"version": "master"}}}`,
+			wantStatus: http.StatusBadRequest,
+			wantResult: policy.Result{Errors: []string{`tool: invalid version "master": not a semantic version`}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := http.Post(srv.URL, "application/json", strings.NewReader(tt.lockfile))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			var got policy.Result
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.wantResult) {
+				t.Errorf("got %+v, want %+v", got, tt.wantResult)
+			}
+		})
+	}
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("got status %d, want %d", res.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,143 @@
+// Package releasenotes fetches the release notes of versions of modules.
+//
+// Release notes aren't part of the module system, so they are found on the code host.
+// Only GitHub is supported, where the notes are the body of the release for the tag of the version.
+package releasenotes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/getshiphub/shed/github"
+	"golang.org/x/mod/module"
+)
+
+// ErrNotFound is returned when a version has no release notes, or the module isn't hosted on GitHub.
+var ErrNotFound = errors.New("releasenotes: not found")
+
+// Release is a release of a version of a module.
+type Release struct {
+	// URL is the web page of the release.
+	URL string
+	// Notes are the release notes, usually in markdown.
+	Notes string
+}
+
+// GitHub fetches release notes from GitHub releases.
+type GitHub struct {
+	client *github.Client
+}
+
+// NewGitHub returns a GitHub that fetches releases with client. If client is nil, github.FromEnv is used.
+func NewGitHub(client *github.Client) *GitHub {
+	if client == nil {
+		client = github.FromEnv()
+	}
+	return &GitHub{client: client}
+}
+
+// Release returns the release of mod. The release is the one for the tag of the version,
+// which is prefixed with the directory of the module in the repository for nested modules.
+// If mod isn't hosted on GitHub or the tag has no release, the error is ErrNotFound.
+// If the rate limit of the GitHub API was exceeded, the error is a *github.RateLimitError.
+func (g *GitHub) Release(ctx context.Context, mod module.Version) (Release, error) {
+	repo, dir, err := githubRepo(mod.Path)
+	if err != nil {
+		return Release{}, err
+	}
+	tag := tagName(dir, mod.Version)
+
+	var body struct {
+		HTMLURL string `json:"html_url"`
+		Body    string `json:"body"`
+	}
+	err = g.client.Get(ctx, fmt.Sprintf("/repos/%s/releases/tags/%s", repo, url.PathEscape(tag)), &body)
+	if errors.Is(err, github.ErrNotFound) {
+		return Release{}, fmt.Errorf("%w: no release for %s", ErrNotFound, mod)
+	}
+	if err != nil {
+		return Release{}, fmt.Errorf("releasenotes: failed to fetch release of %s: %w", mod, err)
+	}
+	return Release{URL: body.HTMLURL, Notes: body.Body}, nil
+}
+
+// Summary returns the start of notes, up to maxLines non-empty lines.
+// Lines are trimmed, and markdown headings and horizontal rules are skipped
+// since they rarely say anything about the changes.
+func Summary(notes string, maxLines int) string {
+	var lines []string
+	for _, l := range strings.Split(notes, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") || strings.Trim(l, "-*_") == "" {
+			continue
+		}
+		if len(lines) == maxLines {
+			lines = append(lines, "...")
+			break
+		}
+		lines = append(lines, l)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// pseudoRevRE matches the revision at the end of a pseudo-version, ex: 'v0.0.0-20201211185031-d93e913c1a58'.
+var pseudoRevRE = regexp.MustCompile(`[-.]\d{14}-([0-9a-f]{12})(\+incompatible)?$`)
+
+// githubRepo returns the repository of the module modPath on GitHub, in the form 'OWNER/REPO', and the directory
+// of the module in the repository, which prefixes its tags. If it isn't hosted on GitHub, the error is ErrNotFound.
+func githubRepo(modPath string) (repo, dir string, err error) {
+	prefix, _, ok := module.SplitPathVersion(modPath)
+	if !ok {
+		return "", "", fmt.Errorf("releasenotes: invalid module path %q", modPath)
+	}
+	parts := strings.SplitN(prefix, "/", 4)
+	if len(parts) < 3 || parts[0] != "github.com" {
+		return "", "", fmt.Errorf("%w: %s is not hosted on GitHub", ErrNotFound, modPath)
+	}
+	if len(parts) == 4 {
+		dir = parts[3]
+	}
+	return parts[1] + "/" + parts[2], dir, nil
+}
+
+// tagName returns the git tag of version for a module in the directory dir of its repository.
+func tagName(dir, version string) string {
+	version = strings.TrimSuffix(version, "+incompatible")
+	if dir == "" {
+		return version
+	}
+	return dir + "/" + version
+}
+
+// gitRef returns the git revision of version, which is the commit of a pseudo-version or the tag of any other version.
+func gitRef(dir, version string) string {
+	if m := pseudoRevRE.FindStringSubmatch(version); m != nil {
+		return m[1]
+	}
+	return tagName(dir, version)
+}
+
+// ReleaseURL returns the web page of the GitHub release of mod. It doesn't check that the release exists, see
+// GitHub.Release. It returns false if mod isn't hosted on GitHub or has a pseudo-version, which has no release.
+func ReleaseURL(mod module.Version) (string, bool) {
+	repo, dir, err := githubRepo(mod.Path)
+	if err != nil || pseudoRevRE.MatchString(mod.Version) {
+		return "", false
+	}
+	return fmt.Sprintf("https://github.com/%s/releases/tag/%s", repo, tagName(dir, mod.Version)), true
+}
+
+// CompareURL returns the web page on GitHub that compares the versions oldVersion and newVersion of the module
+// modPath, which lists the commits between them. Pseudo-versions are compared by their commit. It returns false
+// if the module isn't hosted on GitHub.
+func CompareURL(modPath, oldVersion, newVersion string) (string, bool) {
+	repo, dir, err := githubRepo(modPath)
+	if err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("https://github.com/%s/compare/%s...%s", repo, gitRef(dir, oldVersion), gitRef(dir, newVersion)), true
+}
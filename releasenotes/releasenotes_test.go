@@ -0,0 +1,126 @@
+package releasenotes_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/getshiphub/shed/github"
+	"github.com/getshiphub/shed/releasenotes"
+	"golang.org/x/mod/module"
+)
+
+func TestGitHubRelease(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		switch r.URL.EscapedPath() {
+		case "/repos/golangci/golangci-lint/releases/tags/v1.33.0":
+			w.Write([]byte(`{"html_url": "https://github.com/golangci/golangci-lint/releases/tag/v1.33.0", "body": "Fixes"}`)) //nolint:errcheck
+		case "/repos/golang/tools/releases/tags/gopls%2Fv0.6.0":
+			w.Write([]byte(`{"html_url": "https://github.com/golang/tools/releases/tag/gopls/v0.6.0", "body": "gopls"}`)) //nolint:errcheck
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	gh := releasenotes.NewGitHub(github.New(srv.Client(), srv.URL, "token"))
+
+	tests := []struct {
+		name string
+		mod  module.Version
+		want releasenotes.Release
+	}{
+		{
+			"root module",
+			module.Version{Path: "github.com/golangci/golangci-lint", Version: "v1.33.0"},
+			releasenotes.Release{URL: "https://github.com/golangci/golangci-lint/releases/tag/v1.33.0", Notes: "Fixes"},
+		},
+		{
+			"nested module with major version",
+			module.Version{Path: "github.com/golang/tools/gopls/v2", Version: "v0.6.0"},
+			releasenotes.Release{URL: "https://github.com/golang/tools/releases/tag/gopls/v0.6.0", Notes: "gopls"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := gh.Release(context.Background(), tt.mod)
+			if err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	if auth != "Bearer token" {
+		t.Errorf("got Authorization header %q, want %q", auth, "Bearer token")
+	}
+
+	for _, mod := range []module.Version{
+		{Path: "github.com/golangci/golangci-lint", Version: "v1.32.0"},
+		{Path: "golang.org/x/tools", Version: "v0.1.0"},
+	} {
+		if _, err := gh.Release(context.Background(), mod); !errors.Is(err, releasenotes.ErrNotFound) {
+			t.Errorf("got error %v for %s, want %v", err, mod, releasenotes.ErrNotFound)
+		}
+	}
+}
+
+func TestReleaseURL(t *testing.T) {
+	tests := []struct {
+		name string
+		mod  module.Version
+		want string
+	}{
+		{"tag", module.Version{Path: "github.com/golangci/golangci-lint", Version: "v1.33.0"}, "https://github.com/golangci/golangci-lint/releases/tag/v1.33.0"},
+		{"nested module", module.Version{Path: "github.com/foo/bar/tools/v2", Version: "v2.1.0"}, "https://github.com/foo/bar/releases/tag/tools/v2.1.0"},
+		{"incompatible", module.Version{Path: "github.com/foo/bar", Version: "v3.0.0+incompatible"}, "https://github.com/foo/bar/releases/tag/v3.0.0"},
+		{"pseudo-version", module.Version{Path: "github.com/foo/bar", Version: "v0.0.0-20201203230243-22d10c9b658d"}, ""},
+		{"not github", module.Version{Path: "golang.org/x/tools", Version: "v0.1.0"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := releasenotes.ReleaseURL(tt.mod)
+			if got != tt.want || ok != (tt.want != "") {
+				t.Errorf("got %q, %t, want %q", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		modPath string
+		old     string
+		new     string
+		want    string
+	}{
+		{"tags", "github.com/golangci/golangci-lint", "v1.28.3", "v1.33.0", "https://github.com/golangci/golangci-lint/compare/v1.28.3...v1.33.0"},
+		{"nested module", "github.com/foo/bar/tools", "v0.1.0", "v0.2.0", "https://github.com/foo/bar/compare/tools/v0.1.0...tools/v0.2.0"},
+		{"pseudo-versions", "github.com/foo/bar", "v0.0.0-20201203230243-22d10c9b658d", "v0.1.1-0.20201211185031-d93e913c1a58", "https://github.com/foo/bar/compare/22d10c9b658d...d93e913c1a58"},
+		{"not github", "golang.org/x/tools", "v0.1.0", "v0.2.0", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := releasenotes.CompareURL(tt.modPath, tt.old, tt.new)
+			if got != tt.want || ok != (tt.want != "") {
+				t.Errorf("got %q, %t, want %q", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummary(t *testing.T) {
+	notes := "## Changelog\r\n\r\n* Fix crash\r\n* Add flag\r\n---\r\n* Update docs\r\n"
+	want := "* Fix crash\n* Add flag\n..."
+	if got := releasenotes.Summary(notes, 2); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := releasenotes.Summary(notes, 5); got != "* Fix crash\n* Add flag\n* Update docs" {
+		t.Errorf("got %q, want all lines", got)
+	}
+}
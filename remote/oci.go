@@ -0,0 +1,564 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Media types used by OCI artifacts.
+const (
+	// OCILayerMediaType is the media type of layers that are a gzipped tar archive.
+	OCILayerMediaType       = "application/vnd.oci.image.layer.v1.tar+gzip"
+	dockerLayerMediaType    = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+	ociManifestMediaType    = "application/vnd.oci.image.manifest.v1+json"
+	dockerManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+var (
+	// See https://github.com/opencontainers/distribution-spec/blob/main/spec.md#pulling-manifests
+	ociRepositoryRE = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+	ociTagRE        = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+	ociDigestRE     = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+)
+
+// OCIReference identifies an artifact in an OCI registry, ex: 'ghcr.io/org/toolcache:latest'.
+type OCIReference struct {
+	// Registry is the host of the registry, optionally with a port.
+	Registry string
+	// Repository is the name of the repository within the registry, ex: 'org/toolcache'.
+	Repository string
+	// Reference is either a tag or a digest.
+	Reference string
+}
+
+// ParseOCIReference parses s into an OCIReference. s has the form 'REGISTRY/REPOSITORY[:TAG|@DIGEST]'.
+// The registry must be given explicitly, there is no default registry. If neither a tag nor a
+// digest is provided, the tag 'latest' is used.
+func ParseOCIReference(s string) (OCIReference, error) {
+	i := strings.IndexByte(s, '/')
+	if i == -1 {
+		return OCIReference{}, fmt.Errorf("remote: invalid OCI reference %q: missing registry", s)
+	}
+	ref := OCIReference{Registry: s[:i], Repository: s[i+1:], Reference: "latest"}
+	if !strings.ContainsAny(ref.Registry, ".:") && ref.Registry != "localhost" {
+		return OCIReference{}, fmt.Errorf("remote: invalid OCI reference %q: %q is not a registry host", s, ref.Registry)
+	}
+	if j := strings.IndexByte(ref.Repository, '@'); j != -1 {
+		ref.Reference = ref.Repository[j+1:]
+		ref.Repository = ref.Repository[:j]
+		if !ociDigestRE.MatchString(ref.Reference) {
+			return OCIReference{}, fmt.Errorf("remote: invalid OCI reference %q: invalid digest %q", s, ref.Reference)
+		}
+	} else if j := strings.LastIndexByte(ref.Repository, ':'); j != -1 {
+		ref.Reference = ref.Repository[j+1:]
+		ref.Repository = ref.Repository[:j]
+		if !ociTagRE.MatchString(ref.Reference) {
+			return OCIReference{}, fmt.Errorf("remote: invalid OCI reference %q: invalid tag %q", s, ref.Reference)
+		}
+	}
+	if !ociRepositoryRE.MatchString(ref.Repository) {
+		return OCIReference{}, fmt.Errorf("remote: invalid OCI reference %q: invalid repository %q", s, ref.Repository)
+	}
+	return ref, nil
+}
+
+func (r OCIReference) String() string {
+	if ociDigestRE.MatchString(r.Reference) {
+		return r.Registry + "/" + r.Repository + "@" + r.Reference
+	}
+	return r.Registry + "/" + r.Repository + ":" + r.Reference
+}
+
+// ociDescriptor describes a blob referenced by a manifest.
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+type ociManifest struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType,omitempty"`
+	Config        ociDescriptor   `json:"config"`
+	Layers        []ociDescriptor `json:"layers"`
+}
+
+// OCIClient pushes and pulls artifacts using the OCI distribution API.
+// Registries that require authentication are supported using the token flow
+// from the Docker registry API as well as basic auth.
+//
+// An OCIClient is safe for concurrent use by multiple goroutines.
+type OCIClient struct {
+	// Client is the HTTP client used to make requests.
+	// If nil, http.DefaultClient is used.
+	Client *http.Client
+	// Username and Password are used to authenticate with the registry, if set.
+	// Without them, anonymous access is requested.
+	Username string
+	Password string
+	// PlainHTTP makes requests using HTTP instead of HTTPS.
+	// This is only meant for local registries, such as when testing.
+	PlainHTTP bool
+
+	mu sync.Mutex
+	// Maps registry/repository to the last bearer token used for it.
+	tokens map[string]string
+}
+
+func (c *OCIClient) httpClient() *http.Client {
+	if c.Client == nil {
+		return http.DefaultClient
+	}
+	return c.Client
+}
+
+// do makes a request to the registry of ref. If the registry responds that authorization
+// is required, a token is requested and the request is retried. body may be nil, it is
+// rewound before retrying.
+func (c *OCIClient) do(ctx context.Context, ref OCIReference, method, u string, header http.Header, body io.ReadSeeker) (*http.Response, error) {
+	key := ref.Registry + "/" + ref.Repository
+	send := func() (*http.Response, error) {
+		var r io.Reader
+		var size int64
+		if body != nil {
+			var err error
+			// Registries need the length of uploads, which isn't known for all readers
+			if size, err = body.Seek(0, io.SeekEnd); err == nil {
+				_, err = body.Seek(0, io.SeekStart)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("remote: failed to rewind request body: %w", err)
+			}
+			r = body
+		}
+		req, err := http.NewRequest(method, u, r)
+		if err != nil {
+			return nil, fmt.Errorf("remote: failed to create request: %w", err)
+		}
+		req.ContentLength = size
+		req = req.WithContext(ctx)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		c.mu.Lock()
+		token := c.tokens[key]
+		c.mu.Unlock()
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		} else if c.Username != "" {
+			req.SetBasicAuth(c.Username, c.Password)
+		}
+		resp, err := c.httpClient().Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("remote: %s %s failed: %w", method, u, err)
+		}
+		return resp, nil
+	}
+
+	resp, err := send()
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	challenge := resp.Header.Get("Www-Authenticate")
+	resp.Body.Close()
+	scheme, params := parseChallenge(challenge)
+	if !strings.EqualFold(scheme, "bearer") {
+		return nil, fmt.Errorf("remote: %s %s failed: registry %s requires authentication", method, u, ref.Registry)
+	}
+	token, err := c.fetchToken(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.tokens == nil {
+		c.tokens = make(map[string]string)
+	}
+	c.tokens[key] = token
+	c.mu.Unlock()
+
+	resp, err = send()
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		resp.Body.Close()
+		return nil, fmt.Errorf("remote: %s %s failed: not authorized to access %s", method, u, ref.Repository)
+	}
+	return resp, nil
+}
+
+// fetchToken requests a bearer token using the parameters of a WWW-Authenticate challenge.
+func (c *OCIClient) fetchToken(ctx context.Context, params map[string]string) (string, error) {
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("remote: registry auth challenge is missing realm")
+	}
+	u, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("remote: invalid registry auth realm %q: %w", realm, err)
+	}
+	q := u.Query()
+	if s := params["service"]; s != "" {
+		q.Set("service", s)
+	}
+	if s := params["scope"]; s != "" {
+		q.Set("scope", s)
+	}
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("remote: failed to create request: %w", err)
+	}
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	resp, err := c.httpClient().Do(req.WithContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("remote: failed to get registry token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("remote: failed to get registry token: server responded with %s", resp.Status)
+	}
+	var tr struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return "", fmt.Errorf("remote: failed to parse registry token: %w", err)
+	}
+	if tr.Token != "" {
+		return tr.Token, nil
+	}
+	if tr.AccessToken != "" {
+		return tr.AccessToken, nil
+	}
+	return "", fmt.Errorf("remote: registry token response did not contain a token")
+}
+
+// parseChallenge parses a WWW-Authenticate header like 'Bearer realm="...",service="..."'.
+func parseChallenge(s string) (string, map[string]string) {
+	params := make(map[string]string)
+	i := strings.IndexByte(s, ' ')
+	if i == -1 {
+		return s, params
+	}
+	scheme, rest := s[:i], s[i+1:]
+	for rest != "" {
+		rest = strings.TrimLeft(rest, " ,")
+		eq := strings.IndexByte(rest, '=')
+		if eq == -1 {
+			break
+		}
+		name := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end == -1 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end == -1 {
+				end = len(rest)
+			}
+			value, rest = rest[:end], rest[end:]
+		}
+		params[name] = value
+	}
+	return scheme, params
+}
+
+func (c *OCIClient) url(ref OCIReference, kind, name string) string {
+	scheme := "https://"
+	if c.PlainHTTP {
+		scheme = "http://"
+	}
+	return scheme + ref.Registry + "/v2/" + ref.Repository + "/" + kind + "/" + name
+}
+
+// Pull pulls the artifact referenced by ref and calls fn with the contents of each layer that
+// is a gzipped tar archive, in order. Each layer is downloaded and its digest is verified
+// before fn is called, so fn never sees corrupted or partial data.
+func (c *OCIClient) Pull(ctx context.Context, ref OCIReference, fn func(layer io.Reader) error) error {
+	layers, err := c.layers(ctx, ref)
+	if err != nil {
+		return err
+	}
+	for _, layer := range layers {
+		f, err := c.fetchBlob(ctx, ref, layer)
+		if err != nil {
+			return err
+		}
+		err = fn(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// layers returns the layers of the artifact referenced by ref that are gzipped tar archives.
+func (c *OCIClient) layers(ctx context.Context, ref OCIReference) ([]ociDescriptor, error) {
+	header := http.Header{"Accept": {ociManifestMediaType + ", " + dockerManifestMediaType}}
+	resp, err := c.do(ctx, ref, http.MethodGet, c.url(ref, "manifests", ref.Reference), header, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, ref)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote: failed to get manifest of %s: server responded with %s", ref, resp.Status)
+	}
+	var m ociManifest
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return nil, fmt.Errorf("remote: failed to parse manifest of %s: %w", ref, err)
+	}
+
+	var layers []ociDescriptor
+	for _, layer := range m.Layers {
+		if layer.MediaType == OCILayerMediaType || layer.MediaType == dockerLayerMediaType {
+			layers = append(layers, layer)
+		}
+	}
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("remote: %s does not contain any gzipped tar layers", ref)
+	}
+	return layers, nil
+}
+
+// tempFile is a temporary file that is removed when it is closed.
+type tempFile struct {
+	*os.File
+}
+
+func (f tempFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
+
+// fetchBlob downloads the blob described by desc to a temp file and verifies it.
+// The returned file is positioned at the start and is removed when closed.
+func (c *OCIClient) fetchBlob(ctx context.Context, ref OCIReference, desc ociDescriptor) (tempFile, error) {
+	if !ociDigestRE.MatchString(desc.Digest) {
+		return tempFile{}, fmt.Errorf("remote: manifest of %s contains unsupported digest %q", ref, desc.Digest)
+	}
+	resp, err := c.do(ctx, ref, http.MethodGet, c.url(ref, "blobs", desc.Digest), nil, nil)
+	if err != nil {
+		return tempFile{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return tempFile{}, fmt.Errorf("remote: failed to get blob %s of %s: server responded with %s", desc.Digest, ref, resp.Status)
+	}
+
+	osf, err := ioutil.TempFile("", "shed-oci-")
+	if err != nil {
+		return tempFile{}, fmt.Errorf("remote: failed to create temp file: %w", err)
+	}
+	f := tempFile{osf}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), resp.Body)
+	if err != nil {
+		f.Close()
+		return tempFile{}, fmt.Errorf("remote: failed to download blob %s of %s: %w", desc.Digest, ref, err)
+	}
+	if got := "sha256:" + hex.EncodeToString(h.Sum(nil)); got != desc.Digest || n != desc.Size {
+		f.Close()
+		return tempFile{}, fmt.Errorf("remote: blob %s of %s is corrupt: got digest %s and size %d, want size %d", desc.Digest, ref, got, n, desc.Size)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return tempFile{}, fmt.Errorf("remote: failed to read blob %s: %w", desc.Digest, err)
+	}
+	return f, nil
+}
+
+// Push pushes an artifact with a single layer, read from layer, to ref. The layer must be a
+// gzipped tar archive. configMediaType identifies the type of the artifact, it is used as the
+// media type of the config blob, which is an empty JSON object.
+func (c *OCIClient) Push(ctx context.Context, ref OCIReference, configMediaType string, layer io.ReadSeeker) error {
+	layerDesc, err := describeBlob(OCILayerMediaType, layer)
+	if err != nil {
+		return err
+	}
+	config := bytes.NewReader([]byte("{}"))
+	configDesc, err := describeBlob(configMediaType, config)
+	if err != nil {
+		return err
+	}
+	if err := c.pushBlob(ctx, ref, configDesc, config); err != nil {
+		return err
+	}
+	if err := c.pushBlob(ctx, ref, layerDesc, layer); err != nil {
+		return err
+	}
+
+	m := ociManifest{
+		SchemaVersion: 2,
+		MediaType:     ociManifestMediaType,
+		Config:        configDesc,
+		Layers:        []ociDescriptor{layerDesc},
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("remote: failed to serialize manifest: %w", err)
+	}
+	header := http.Header{"Content-Type": {ociManifestMediaType}}
+	resp, err := c.do(ctx, ref, http.MethodPut, c.url(ref, "manifests", ref.Reference), header, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("remote: failed to push manifest of %s: server responded with %s", ref, resp.Status)
+	}
+	return nil
+}
+
+// describeBlob computes the descriptor of the blob read from r.
+func describeBlob(mediaType string, r io.ReadSeeker) (ociDescriptor, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return ociDescriptor{}, fmt.Errorf("remote: failed to read blob: %w", err)
+	}
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return ociDescriptor{}, fmt.Errorf("remote: failed to read blob: %w", err)
+	}
+	return ociDescriptor{MediaType: mediaType, Digest: "sha256:" + hex.EncodeToString(h.Sum(nil)), Size: n}, nil
+}
+
+// pushBlob uploads the blob described by desc, unless the registry already has it.
+func (c *OCIClient) pushBlob(ctx context.Context, ref OCIReference, desc ociDescriptor, r io.ReadSeeker) error {
+	resp, err := c.do(ctx, ref, http.MethodHead, c.url(ref, "blobs", desc.Digest), nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	resp, err = c.do(ctx, ref, http.MethodPost, c.url(ref, "blobs", "uploads/"), nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("remote: failed to start upload to %s: server responded with %s", ref, resp.Status)
+	}
+	loc, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.Header.Get("Location") == "" {
+		return fmt.Errorf("remote: registry %s returned an invalid upload location", ref.Registry)
+	}
+	q := loc.Query()
+	q.Set("digest", desc.Digest)
+	loc.RawQuery = q.Encode()
+
+	header := http.Header{"Content-Type": {"application/octet-stream"}}
+	resp, err = c.do(ctx, ref, http.MethodPut, loc.String(), header, r)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("remote: failed to upload blob %s to %s: server responded with %s", desc.Digest, ref, resp.Status)
+	}
+	return nil
+}
+
+// OCIArtifactMediaType is the media type of the config of artifacts stored by an OCIBackend.
+const OCIArtifactMediaType = "application/vnd.getshiphub.shed.artifact.config.v1+json"
+
+// OCIBackend stores artifacts in a repository of an OCI registry. Each artifact is stored as
+// an OCI artifact with a single layer, tagged with the key. Since tags can't contain slashes,
+// they are replaced with underscores, ex: the key 'tasks/<hash>.tar.gz' has the tag
+// 'tasks_<hash>.tar.gz'. This allows artifacts to be distributed with the same registry
+// used for container images.
+type OCIBackend struct {
+	// Registry is the host of the registry, optionally with a port.
+	Registry string
+	// Repository is the repository in the registry that artifacts are stored in.
+	Repository string
+	// Client is used to make requests to the registry.
+	// If nil, a client without credentials is used.
+	Client *OCIClient
+}
+
+func (b *OCIBackend) ref(key string) (OCIReference, error) {
+	if err := validateKey(key); err != nil {
+		return OCIReference{}, err
+	}
+	tag := strings.ReplaceAll(key, "/", "_")
+	if !ociTagRE.MatchString(tag) {
+		return OCIReference{}, fmt.Errorf("remote: key %q cannot be used as an OCI tag", key)
+	}
+	return OCIReference{Registry: b.Registry, Repository: b.Repository, Reference: tag}, nil
+}
+
+func (b *OCIBackend) client() *OCIClient {
+	if b.Client == nil {
+		return &OCIClient{}
+	}
+	return b.Client
+}
+
+// Get implements Backend.
+func (b *OCIBackend) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	ref, err := b.ref(key)
+	if err != nil {
+		return nil, err
+	}
+	c := b.client()
+	layers, err := c.layers(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if len(layers) != 1 {
+		return nil, fmt.Errorf("remote: %s must contain a single layer, found %d", ref, len(layers))
+	}
+	return c.fetchBlob(ctx, ref, layers[0])
+}
+
+// Put implements Backend. The contents of r are buffered in a temp file, since
+// the digest must be known before uploading.
+func (b *OCIBackend) Put(ctx context.Context, key string, r io.Reader) error {
+	ref, err := b.ref(key)
+	if err != nil {
+		return err
+	}
+	rs, ok := r.(io.ReadSeeker)
+	if !ok {
+		osf, err := ioutil.TempFile("", "shed-oci-")
+		if err != nil {
+			return fmt.Errorf("remote: failed to create temp file: %w", err)
+		}
+		f := tempFile{osf}
+		defer f.Close()
+		if _, err := io.Copy(f, r); err != nil {
+			return fmt.Errorf("remote: failed to buffer %s: %w", key, err)
+		}
+		rs = f
+	}
+	return b.client().Push(ctx, ref, OCIArtifactMediaType, rs)
+}
@@ -0,0 +1,250 @@
+package remote_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/getshiphub/shed/remote"
+)
+
+// registry is an in memory OCI registry that requires a bearer token.
+type registry struct {
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	// If set, corrupts blobs when they are served.
+	corrupt bool
+}
+
+func newRegistry(t *testing.T) (*registry, *httptest.Server) {
+	reg := &registry{blobs: make(map[string][]byte), manifests: make(map[string][]byte)}
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if u, p, ok := r.BasicAuth(); !ok || u != "user" || p != "pass" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"token": "secret"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("Www-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="repository:org/cache:pull"`, srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		reg.serve(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return reg, srv
+}
+
+func (reg *registry) serve(w http.ResponseWriter, r *http.Request) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	p := strings.TrimPrefix(r.URL.Path, "/v2/")
+	switch {
+	case strings.Contains(p, "/blobs/uploads/"):
+		if r.Method == http.MethodPost {
+			w.Header().Set("Location", "/v2/"+p+"upload-1")
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		sum := sha256.Sum256(data)
+		digest := "sha256:" + hex.EncodeToString(sum[:])
+		if r.URL.Query().Get("digest") != digest || r.ContentLength != int64(len(data)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		reg.blobs[digest] = data
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(p, "/blobs/"):
+		digest := p[strings.LastIndex(p, "/")+1:]
+		data, ok := reg.blobs[digest]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if reg.corrupt {
+			data = append([]byte("x"), data[1:]...)
+		}
+		w.Write(data) //nolint:errcheck
+	case strings.Contains(p, "/manifests/"):
+		if r.Method == http.MethodPut {
+			data, _ := ioutil.ReadAll(r.Body)
+			reg.manifests[p] = data
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		data, ok := reg.manifests[p]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data) //nolint:errcheck
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestParseOCIReference(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want remote.OCIReference
+	}{
+		{"ghcr.io/org/toolcache:latest", remote.OCIReference{Registry: "ghcr.io", Repository: "org/toolcache", Reference: "latest"}},
+		{"ghcr.io/org/toolcache", remote.OCIReference{Registry: "ghcr.io", Repository: "org/toolcache", Reference: "latest"}},
+		{"localhost:5000/cache:v1.2", remote.OCIReference{Registry: "localhost:5000", Repository: "cache", Reference: "v1.2"}},
+		{
+			"ghcr.io/org/cache@sha256:" + strings.Repeat("a", 64),
+			remote.OCIReference{Registry: "ghcr.io", Repository: "org/cache", Reference: "sha256:" + strings.Repeat("a", 64)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := remote.ParseOCIReference(tt.ref)
+			if err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOCIReferenceError(t *testing.T) {
+	tests := []string{
+		"toolcache",
+		"org/toolcache:latest",
+		"ghcr.io/Org/toolcache",
+		"ghcr.io/org/toolcache:",
+		"ghcr.io/org/toolcache@sha256:abc",
+	}
+	for _, ref := range tests {
+		t.Run(ref, func(t *testing.T) {
+			if _, err := remote.ParseOCIReference(ref); err == nil {
+				t.Error("want non-nil error, got nil")
+			}
+		})
+	}
+}
+
+func TestOCIClientPushPull(t *testing.T) {
+	_, srv := newRegistry(t)
+	ref, err := remote.ParseOCIReference(strings.TrimPrefix(srv.URL, "https://") + "/org/cache:v1")
+	if err != nil {
+		t.Fatalf("failed to parse reference: %v", err)
+	}
+	c := &remote.OCIClient{Client: srv.Client(), Username: "user", Password: "pass"}
+	layer := []byte("not really a tar archive")
+	if err := c.Push(context.Background(), ref, "application/vnd.test.config.v1+json", bytes.NewReader(layer)); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	var got [][]byte
+	err = c.Pull(context.Background(), ref, func(r io.Reader) error {
+		data, err := ioutil.ReadAll(r)
+		got = append(got, data)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if len(got) != 1 || !bytes.Equal(got[0], layer) {
+		t.Errorf("got layers %q, want %q", got, layer)
+	}
+}
+
+func TestOCIClientPullNotFound(t *testing.T) {
+	_, srv := newRegistry(t)
+	ref, err := remote.ParseOCIReference(strings.TrimPrefix(srv.URL, "https://") + "/org/cache:v1")
+	if err != nil {
+		t.Fatalf("failed to parse reference: %v", err)
+	}
+	c := &remote.OCIClient{Client: srv.Client(), Username: "user", Password: "pass"}
+	err = c.Pull(context.Background(), ref, func(r io.Reader) error { return nil })
+	if !errors.Is(err, remote.ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, remote.ErrNotFound)
+	}
+}
+
+func TestOCIClientPullCorrupt(t *testing.T) {
+	reg, srv := newRegistry(t)
+	ref, err := remote.ParseOCIReference(strings.TrimPrefix(srv.URL, "https://") + "/org/cache:v1")
+	if err != nil {
+		t.Fatalf("failed to parse reference: %v", err)
+	}
+	c := &remote.OCIClient{Client: srv.Client(), Username: "user", Password: "pass"}
+	if err := c.Push(context.Background(), ref, "application/vnd.test.config.v1+json", bytes.NewReader([]byte("layer"))); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	reg.corrupt = true
+	called := false
+	err = c.Pull(context.Background(), ref, func(r io.Reader) error {
+		called = true
+		return nil
+	})
+	if err == nil {
+		t.Error("want non-nil error, got nil")
+	}
+	if called {
+		t.Error("corrupt layer was passed to fn")
+	}
+}
+
+func TestOCIClientUnauthorized(t *testing.T) {
+	_, srv := newRegistry(t)
+	ref, err := remote.ParseOCIReference(strings.TrimPrefix(srv.URL, "https://") + "/org/cache:v1")
+	if err != nil {
+		t.Fatalf("failed to parse reference: %v", err)
+	}
+	c := &remote.OCIClient{Client: srv.Client()}
+	err = c.Push(context.Background(), ref, "application/vnd.test.config.v1+json", bytes.NewReader([]byte("layer")))
+	if err == nil {
+		t.Error("want non-nil error, got nil")
+	}
+}
+
+func TestOCIBackend(t *testing.T) {
+	_, srv := newRegistry(t)
+	b := &remote.OCIBackend{
+		Registry:   strings.TrimPrefix(srv.URL, "https://"),
+		Repository: "org/cache",
+		Client:     &remote.OCIClient{Client: srv.Client(), Username: "user", Password: "pass"},
+	}
+	testBackend(t, b)
+}
+
+func TestNewOCIBackend(t *testing.T) {
+	b, err := remote.New("oci://ghcr.io/org/cache", "secret")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	ob, ok := b.(*remote.OCIBackend)
+	if !ok {
+		t.Fatalf("got %T, want *remote.OCIBackend", b)
+	}
+	if ob.Registry != "ghcr.io" || ob.Repository != "org/cache" {
+		t.Errorf("got registry %q and repository %q, want ghcr.io and org/cache", ob.Registry, ob.Repository)
+	}
+	if ob.Client.Username != "shed" || ob.Client.Password != "secret" {
+		t.Errorf("got credentials %q:%q, want shed:secret", ob.Client.Username, ob.Client.Password)
+	}
+
+	if _, err := remote.New("oci://ghcr.io", ""); err == nil {
+		t.Error("want error for missing repository, got nil")
+	}
+}
@@ -0,0 +1,199 @@
+// Package remote provides backends for sharing cached artifacts between machines.
+// This allows expensive work done on one machine, such as in CI, to be reused by others.
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound is returned when an artifact does not exist in a backend.
+var ErrNotFound = errors.New("remote: artifact not found")
+
+// Backend is a store of artifacts identified by a key.
+// Keys are slash separated paths, for example 'tasks/<hash>.tar.gz'.
+//
+// Implementations must be safe for concurrent use.
+type Backend interface {
+	// Get returns the contents of the artifact with the given key.
+	// The caller must close the returned reader. If the artifact does
+	// not exist, an error matching ErrNotFound is returned.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Put stores the contents of r as the artifact with the given key,
+	// replacing any existing artifact.
+	Put(ctx context.Context, key string, r io.Reader) error
+}
+
+// New creates a Backend from rawURL. The scheme of rawURL determines the backend used:
+// 'http' and 'https' URLs use an HTTPBackend, 'file' URLs use a DirBackend, and 'oci' URLs,
+// ex: 'oci://ghcr.io/org/cache', use an OCIBackend. token is used to authenticate with HTTP
+// backends. For OCI backends it is used as the registry password, with the username from
+// the URL or 'shed' if there isn't one. It is ignored otherwise.
+func New(rawURL, token string) (Backend, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("remote: invalid URL %q: %w", rawURL, err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return &HTTPBackend{URL: strings.TrimSuffix(rawURL, "/"), Token: token}, nil
+	case "file":
+		return NewDirBackend(filepath.FromSlash(u.Path)), nil
+	case "oci":
+		repo := strings.Trim(u.Path, "/")
+		if u.Host == "" || !ociRepositoryRE.MatchString(repo) {
+			return nil, fmt.Errorf("remote: invalid OCI URL %q, must be oci://REGISTRY/REPOSITORY", rawURL)
+		}
+		c := &OCIClient{Username: u.User.Username(), Password: token}
+		if c.Username == "" && token != "" {
+			c.Username = "shed"
+		}
+		return &OCIBackend{Registry: u.Host, Repository: repo, Client: c}, nil
+	default:
+		return nil, fmt.Errorf("remote: unsupported URL scheme %q", u.Scheme)
+	}
+}
+
+// validateKey makes sure key cannot be used to reference things outside the backend.
+func validateKey(key string) error {
+	if key == "" || path.IsAbs(key) || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." {
+		return fmt.Errorf("remote: invalid key %q", key)
+	}
+	return nil
+}
+
+// HTTPBackend stores artifacts on an HTTP server. Artifacts are retrieved with
+// GET requests and stored with PUT requests to URL/key. A 404 response means the
+// artifact does not exist. This is compatible with common cache servers like bazel-remote
+// as well as most object stores.
+type HTTPBackend struct {
+	// URL is the base URL of the server.
+	URL string
+	// Token is sent as a bearer token in the Authorization header, if set.
+	Token string
+	// Client is the HTTP client used to make requests.
+	// If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+func (b *HTTPBackend) do(ctx context.Context, method, key string, body io.Reader) (*http.Response, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, b.URL+"/"+key, body)
+	if err != nil {
+		return nil, fmt.Errorf("remote: failed to create request: %w", err)
+	}
+	req = req.WithContext(ctx)
+	if b.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.Token)
+	}
+	client := b.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("remote: %s %s failed: %w", method, key, err)
+	}
+	return resp, nil
+}
+
+// Get implements Backend.
+func (b *HTTPBackend) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := b.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	case resp.StatusCode != http.StatusOK:
+		resp.Body.Close()
+		return nil, fmt.Errorf("remote: GET %s failed with status %s", key, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// Put implements Backend.
+func (b *HTTPBackend) Put(ctx context.Context, key string, r io.Reader) error {
+	resp, err := b.do(ctx, http.MethodPut, key, r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("remote: PUT %s failed with status %s", key, resp.Status)
+	}
+	return nil
+}
+
+// DirBackend stores artifacts in an OS filesystem directory.
+// This is useful for sharing artifacts using a network file system.
+type DirBackend struct {
+	dir string
+}
+
+// NewDirBackend creates a DirBackend that stores artifacts in dir.
+func NewDirBackend(dir string) *DirBackend {
+	return &DirBackend{dir: dir}
+}
+
+// Get implements Backend.
+func (b *DirBackend) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(b.dir, filepath.FromSlash(key)))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("remote: failed to open %s: %w", key, err)
+	}
+	return f, nil
+}
+
+// Put implements Backend.
+func (b *DirBackend) Put(ctx context.Context, key string, r io.Reader) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	p := filepath.Join(b.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("remote: failed to create directory for %s: %w", key, err)
+	}
+	// Write to a temp file and rename so readers never see a partial artifact
+	f, err := ioutil.TempFile(filepath.Dir(p), ".tmp-")
+	if err != nil {
+		return fmt.Errorf("remote: failed to create temp file for %s: %w", key, err)
+	}
+	defer os.Remove(f.Name())
+	// TempFile creates files that are only readable by the owner, which
+	// defeats the purpose of sharing them
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		return fmt.Errorf("remote: failed to set permissions of %s: %w", key, err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("remote: failed to write %s: %w", key, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("remote: failed to write %s: %w", key, err)
+	}
+	if err := os.Rename(f.Name(), p); err != nil {
+		return fmt.Errorf("remote: failed to write %s: %w", key, err)
+	}
+	return nil
+}
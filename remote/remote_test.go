@@ -0,0 +1,102 @@
+package remote_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/getshiphub/shed/remote"
+)
+
+// newServer creates a test HTTP server that stores artifacts in memory.
+func newServer(t *testing.T, token string) *httptest.Server {
+	var mu sync.Mutex
+	artifacts := make(map[string][]byte)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodGet:
+			data, ok := artifacts[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data) //nolint:errcheck
+		case http.MethodPut:
+			data, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			artifacts[r.URL.Path] = data
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testBackend(t *testing.T, b remote.Backend) {
+	ctx := context.Background()
+	_, err := b.Get(ctx, "tasks/abc.tar.gz")
+	if !errors.Is(err, remote.ErrNotFound) {
+		t.Errorf("want ErrNotFound, got %v", err)
+	}
+
+	if err := b.Put(ctx, "tasks/abc.tar.gz", strings.NewReader("foo")); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	rc, err := b.Get(ctx, "tasks/abc.tar.gz")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	defer rc.Close()
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("failed to read artifact: %v", err)
+	}
+	if string(data) != "foo" {
+		t.Errorf("got %q, want %q", data, "foo")
+	}
+
+	if err := b.Put(ctx, "../abc.tar.gz", strings.NewReader("foo")); err == nil {
+		t.Error("want error for key outside of backend, got nil")
+	}
+}
+
+func TestHTTPBackend(t *testing.T) {
+	srv := newServer(t, "secret")
+	b, err := remote.New(srv.URL+"/", "secret")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	testBackend(t, b)
+
+	unauthorized := &remote.HTTPBackend{URL: srv.URL}
+	_, err = unauthorized.Get(context.Background(), "tasks/abc.tar.gz")
+	if err == nil || errors.Is(err, remote.ErrNotFound) {
+		t.Errorf("want unauthorized error, got %v", err)
+	}
+}
+
+func TestDirBackend(t *testing.T) {
+	testBackend(t, remote.NewDirBackend(t.TempDir()))
+}
+
+func TestNewUnsupportedScheme(t *testing.T) {
+	if _, err := remote.New("s3://bucket", ""); err == nil {
+		t.Error("want error, got nil")
+	}
+}
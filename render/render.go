@@ -0,0 +1,108 @@
+// Package render prints tools with Go templates, such as the output of 'shed list --format'.
+// It lets users generate text from the lockfile, like Makefile variables, tables for docs,
+// or Dockerfile COPY lines, without parsing the JSON output.
+//
+// Templates use the syntax of text/template and are executed once for each tool, with a Tool
+// as the data. For example, the template
+//
+//	{{.Name}} := {{.BinaryPath}}
+//
+// prints a Makefile variable with the path to the binary of each tool.
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Tool is the data a template is executed with.
+type Tool struct {
+	// Name is the name of the tool, used with commands like 'shed run'.
+	Name string
+	// ImportPath is the import path of the tool.
+	ImportPath string
+	// Version is the version of the tool.
+	Version string
+	// Module is the module that provides the tool, if known.
+	Module string
+	// Sum is the hash of the module in the format used by go.sum, if known.
+	Sum string
+	// BinaryPath is the path to the binary of the tool, whether or not it is installed.
+	// It is empty if it is unknown, ex: if the tool is redacted.
+	BinaryPath string
+	// Installed is whether the binary of the tool exists.
+	Installed bool
+	// Stale is whether the installed files don't match their hashes.
+	Stale bool
+	// Groups are the groups the tool belongs to, if any.
+	Groups []string
+	// Why is the reason the tool is used, if one was given.
+	Why string
+	// Branch is the branch the tool tracks, if it was installed from one.
+	Branch string
+	// ReportedVersion is the version the tool printed when it was installed, if it has a version probe.
+	ReportedVersion string
+}
+
+// funcs are the functions available to templates in addition to the ones of text/template.
+var funcs = template.FuncMap{
+	"join":    func(elems []string, sep string) string { return strings.Join(elems, sep) },
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"replace": func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"base":    path.Base,
+	"quote":   strconv.Quote,
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// Template renders tools with a text/template.
+type Template struct {
+	tmpl *template.Template
+}
+
+// Parse parses text as a template. In addition to the functions of text/template, templates can use:
+//
+//	join ELEMS SEP       the elements of a list joined by SEP, ex: {{join .Groups ","}}
+//	upper S, lower S     S in upper or lower case
+//	replace OLD NEW S    S with every OLD replaced by NEW, ex: {{.Name | replace "-" "_"}}
+//	base P               the last element of the path P, ex: {{base .ImportPath}}
+//	quote S              S as a double-quoted Go string
+//	json V               V encoded as JSON
+//
+// Escape sequences like '\n' and '\t' in text are not interpreted, use {{"\n"}} instead.
+func Parse(text string) (*Template, error) {
+	tmpl, err := template.New("format").Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("render: invalid template: %w", err)
+	}
+	return &Template{tmpl: tmpl}, nil
+}
+
+// Execute renders each of tools with t and writes them to w, each followed by a newline unless
+// it already ends with one. Tools that render to nothing are skipped, so templates can filter tools,
+// ex: {{if .Installed}}{{.Name}}{{end}}. Nothing is written if any tool fails to render.
+func (t *Template) Execute(w io.Writer, tools []Tool) error {
+	var buf bytes.Buffer
+	for _, tool := range tools {
+		start := buf.Len()
+		if err := t.tmpl.Execute(&buf, tool); err != nil {
+			return fmt.Errorf("render: failed to render %s: %w", tool.ImportPath, err)
+		}
+		if buf.Len() > start && buf.Bytes()[buf.Len()-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+	}
+	if _, err := buf.WriteTo(w); err != nil {
+		return fmt.Errorf("render: failed to write output: %w", err)
+	}
+	return nil
+}
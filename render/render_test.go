@@ -0,0 +1,90 @@
+package render_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/getshiphub/shed/render"
+)
+
+func TestTemplate(t *testing.T) {
+	tools := []render.Tool{
+		{
+			Name:       "golangci-lint",
+			ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint",
+			Version:    "v1.33.0",
+			BinaryPath: "/cache/golangci-lint",
+			Installed:  true,
+			Groups:     []string{"ci", "lint"},
+		},
+		{
+			Name:       "stringer",
+			ImportPath: "golang.org/x/tools/cmd/stringer",
+			Version:    "v0.1.0",
+			BinaryPath: "/cache/stringer",
+		},
+	}
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{
+			"fields",
+			"{{.ImportPath}} {{.Version}} {{.BinaryPath}}",
+			"github.com/golangci/golangci-lint/cmd/golangci-lint v1.33.0 /cache/golangci-lint\ngolang.org/x/tools/cmd/stringer v0.1.0 /cache/stringer\n",
+		},
+		{
+			"makefile variables",
+			`{{.Name | replace "-" "_" | upper}} := {{.BinaryPath}}`,
+			"GOLANGCI_LINT := /cache/golangci-lint\nSTRINGER := /cache/stringer\n",
+		},
+		{
+			"filter",
+			`{{if .Installed}}{{join .Groups ","}}{{end}}`,
+			"ci,lint\n",
+		},
+		{
+			"trailing newline",
+			"{{base .ImportPath}}{{\"\\n\"}}",
+			"golangci-lint\nstringer\n",
+		},
+		{
+			"json",
+			"{{json .Groups}}",
+			"[\"ci\",\"lint\"]\nnull\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := render.Parse(tt.format)
+			if err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, tools); err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	if _, err := render.Parse("{{.Name"); err == nil {
+		t.Error("want error parsing invalid template, got nil")
+	}
+	tmpl, err := render.Parse("{{.Unknown}}")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, []render.Tool{{Name: "stringer"}}); err == nil {
+		t.Error("want error rendering unknown field, got nil")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("got output %q, want none when rendering fails", buf.String())
+	}
+}
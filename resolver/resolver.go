@@ -0,0 +1,399 @@
+// Package resolver defines how versions of tools are resolved, so that the versions that
+// can be installed can be controlled, ex: to only allow versions from an internal catalog.
+//
+// Proxy resolves versions using the GOPROXY protocol, the same way the go command does.
+// Other implementations can wrap it to restrict the versions it returns.
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getshiphub/shed/internal/retry"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// ErrNotFound is returned when a module or version does not exist.
+var ErrNotFound = errors.New("resolver: not found")
+
+// Resolver resolves versions of the modules that provide tools.
+//
+// Each method takes path, which is the import path of a tool or the path of the module that provides it.
+// If path is an import path, the module is the longest module path that is a prefix of it.
+// The returned module.Version has the path of the module that was found.
+type Resolver interface {
+	// ResolveLatest returns the latest version of the module, which is the latest release
+	// version, or the latest pre-release version if there are no releases.
+	ResolveLatest(ctx context.Context, path string) (module.Version, error)
+	// ResolveConstraint returns the version of the module that matches the constraint.
+	// The constraint is a module query, as supported by 'go get', ex: 'v1.2', '<v1.5.0',
+	// a branch, or a commit.
+	ResolveConstraint(ctx context.Context, path, constraint string) (module.Version, error)
+	// ListVersions returns the versions of the module, sorted from lowest to highest.
+	// Pseudo-versions are not included.
+	ListVersions(ctx context.Context, path string) ([]string, error)
+}
+
+// DefaultProxyURL is the proxy used by the go command if GOPROXY is not set.
+const DefaultProxyURL = "https://proxy.golang.org"
+
+// maxResponseSize is the maximum number of bytes read from a proxy response.
+const maxResponseSize = 1 << 20
+
+// Proxy is a Resolver that uses the GOPROXY protocol.
+// See https://golang.org/ref/mod#goproxy-protocol for details.
+type Proxy struct {
+	urls   []string
+	client *http.Client
+	retry  retry.Policy
+}
+
+// NewProxy returns a Proxy that uses the proxies at urls. If a module is not found in a proxy,
+// the next one is tried, like a comma separated GOPROXY. If client is nil, http.DefaultClient is used.
+func NewProxy(client *http.Client, urls ...string) *Proxy {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	trimmed := make([]string, len(urls))
+	for i, u := range urls {
+		trimmed[i] = strings.TrimSuffix(u, "/")
+	}
+	return &Proxy{urls: trimmed, client: client}
+}
+
+// ProxyFromEnv returns a Proxy that uses the proxies in the GOPROXY environment variable.
+// See ProxyFromList for details.
+func ProxyFromEnv() *Proxy {
+	return ProxyFromList(os.Getenv("GOPROXY"))
+}
+
+// ProxyFromList returns a Proxy that uses the proxies in list, which has the same format as GOPROXY.
+// Since the proxy protocol is all that is supported, the list of proxies stops at 'direct' or 'off'.
+// If list is empty, DefaultProxyURL is used.
+func ProxyFromList(list string) *Proxy {
+	var urls []string
+	for _, u := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == '|' }) {
+		if u == "direct" || u == "off" {
+			break
+		}
+		urls = append(urls, u)
+	}
+	if len(urls) == 0 && list == "" {
+		urls = []string{DefaultProxyURL}
+	}
+	return NewProxy(nil, urls...)
+}
+
+// WithRetry sets how requests to the proxies are retried when they fail with a transient error,
+// ex: a dropped connection or a proxy responding with 503. See cache.WithRetry for details.
+// By default requests are not retried. It returns p to allow chaining.
+func (p *Proxy) WithRetry(attempts int, baseDelay time.Duration, jitter float64) *Proxy {
+	p.retry = retry.Policy{Attempts: attempts, BaseDelay: baseDelay, Jitter: jitter}
+	return p
+}
+
+// get fetches the file at p for the module modPath. It returns ErrNotFound if no proxy has it.
+func (p *Proxy) get(ctx context.Context, modPath, file string) ([]byte, error) {
+	escaped, err := module.EscapePath(modPath)
+	if err != nil {
+		return nil, fmt.Errorf("resolver: invalid module path %q: %w", modPath, err)
+	}
+	for _, u := range p.urls {
+		data, err := p.fetch(ctx, u+"/"+escaped+"/@"+file)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		return data, err
+	}
+	return nil, fmt.Errorf("%w: %s/@%s", ErrNotFound, modPath, file)
+}
+
+// fetch fetches url, retrying transient failures according to the retry policy of p.
+func (p *Proxy) fetch(ctx context.Context, url string) ([]byte, error) {
+	var data []byte
+	err := p.retry.Do(ctx, func() error {
+		var err error
+		data, err = p.fetchOnce(ctx, url)
+		return err
+	}, nil)
+	return data, err
+}
+
+func (p *Proxy) fetchOnce(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("resolver: failed to create request for %s: %w", url, err)
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("resolver: failed to fetch %s: %w", url, err)
+	}
+	defer res.Body.Close()
+	// The protocol uses 404 and 410 to mean the module or version doesn't exist
+	if res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone {
+		return nil, ErrNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		err := fmt.Errorf("resolver: failed to fetch %s: %s", url, res.Status)
+		if res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500 {
+			err = retry.Transient(err)
+		}
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("resolver: failed to read response from %s: %w", url, err)
+	}
+	return data, nil
+}
+
+// info fetches the version info of query for the module modPath.
+func (p *Proxy) info(ctx context.Context, modPath, query string) (module.Version, error) {
+	file := "latest"
+	if query != "latest" {
+		escaped, err := module.EscapeVersion(query)
+		if err != nil {
+			return module.Version{}, fmt.Errorf("resolver: invalid version %q: %w", query, err)
+		}
+		file = "v/" + escaped + ".info"
+	}
+	data, err := p.get(ctx, modPath, file)
+	if err != nil {
+		return module.Version{}, err
+	}
+	var info struct{ Version string }
+	if err := json.Unmarshal(data, &info); err != nil {
+		return module.Version{}, fmt.Errorf("resolver: invalid version info for %s@%s: %w", modPath, query, err)
+	}
+	return module.Version{Path: modPath, Version: info.Version}, nil
+}
+
+// findModule returns the module that provides path along with its versions.
+func (p *Proxy) findModule(ctx context.Context, importPath string) (string, []string, error) {
+	for modPath := importPath; ; modPath = path.Dir(modPath) {
+		if !strings.Contains(modPath, "/") && modPath != importPath {
+			return "", nil, fmt.Errorf("%w: no module provides %s", ErrNotFound, importPath)
+		}
+		data, err := p.get(ctx, modPath, "v/list")
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return "", nil, err
+		}
+		var versions []string
+		for _, v := range strings.Fields(string(data)) {
+			if semver.IsValid(v) {
+				versions = append(versions, v)
+			}
+		}
+		sort.Slice(versions, func(i, j int) bool {
+			return semver.Compare(versions[i], versions[j]) < 0
+		})
+		return modPath, versions, nil
+	}
+}
+
+// ListVersions implements Resolver.
+func (p *Proxy) ListVersions(ctx context.Context, path string) ([]string, error) {
+	_, versions, err := p.findModule(ctx, path)
+	return versions, err
+}
+
+// ResolveLatest implements Resolver.
+func (p *Proxy) ResolveLatest(ctx context.Context, path string) (module.Version, error) {
+	modPath, versions, err := p.findModule(ctx, path)
+	if err != nil {
+		return module.Version{}, err
+	}
+	if v := Latest(versions); v != "" {
+		return module.Version{Path: modPath, Version: v}, nil
+	}
+	// Modules without any tags only have pseudo-versions, which only the proxy can determine
+	return p.info(ctx, modPath, "latest")
+}
+
+// ResolveConstraint implements Resolver.
+func (p *Proxy) ResolveConstraint(ctx context.Context, path, constraint string) (module.Version, error) {
+	if constraint == "latest" {
+		return p.ResolveLatest(ctx, path)
+	}
+	modPath, versions, err := p.findModule(ctx, path)
+	if err != nil {
+		return module.Version{}, err
+	}
+	v, ok, err := Match(versions, constraint)
+	if err != nil {
+		return module.Version{}, err
+	}
+	if ok {
+		if v == "" {
+			return module.Version{}, fmt.Errorf("%w: no version of %s matches %s", ErrNotFound, modPath, constraint)
+		}
+		return module.Version{Path: modPath, Version: v}, nil
+	}
+	// Not a version constraint, so it is a branch or commit that the proxy resolves
+	return p.info(ctx, modPath, constraint)
+}
+
+// Latest returns the latest release in versions, or the latest pre-release if there
+// are no releases. It returns an empty string if versions is empty.
+func Latest(versions []string) string {
+	var latest, latestPre string
+	for _, v := range versions {
+		if semver.Prerelease(v) == "" {
+			if latest == "" || semver.Compare(v, latest) > 0 {
+				latest = v
+			}
+		} else if latestPre == "" || semver.Compare(v, latestPre) > 0 {
+			latestPre = v
+		}
+	}
+	if latest != "" {
+		return latest
+	}
+	return latestPre
+}
+
+// Match returns the version in versions that matches the constraint, using the same rules as
+// module queries in 'go get'. The constraint is either a version, a version prefix like 'v1.2',
+// or a comparison like '<v1.5.0' or '>=v1.2.0'. Comparisons with '<' and '<=' and prefixes match the
+// highest version, and '>' and '>=' match the lowest version. Releases are preferred over pre-releases.
+//
+// Caret and tilde ranges, which the go command doesn't support, are also allowed and match the
+// highest version. '^v1.2.3' matches versions compatible with v1.2.3, which are at least v1.2.3 with the
+// same major version, or the same minor version for v0. '~v1.2.3' matches versions that are at least
+// v1.2.3 with the same minor version. The 'v' can be omitted in ranges, ex: '^1.50'. See IsRange.
+//
+// ok is false if the constraint isn't one of these, ex: it is a branch name. If ok is true but
+// no version matches, the returned version is empty.
+func Match(versions []string, constraint string) (version string, ok bool, err error) {
+	if IsRange(constraint) {
+		match, err := matchRange(constraint)
+		if err != nil {
+			return "", false, err
+		}
+		return bestMatch(versions, match, true), true, nil
+	}
+
+	var op, v string
+	for _, o := range []string{"<=", ">=", "<", ">"} {
+		if strings.HasPrefix(constraint, o) {
+			op, v = o, constraint[len(o):]
+			break
+		}
+	}
+	if op == "" {
+		v = constraint
+	}
+	if !semver.IsValid(v) {
+		if op != "" {
+			return "", false, fmt.Errorf("resolver: invalid version %q in constraint %q", v, constraint)
+		}
+		return "", false, nil
+	}
+
+	var match func(string) bool
+	highest := true
+	switch {
+	case op == "<":
+		match = func(x string) bool { return semver.Compare(x, v) < 0 }
+	case op == "<=":
+		match = func(x string) bool { return semver.Compare(x, v) <= 0 }
+	case op == ">":
+		match = func(x string) bool { return semver.Compare(x, v) > 0 }
+		highest = false
+	case op == ">=":
+		match = func(x string) bool { return semver.Compare(x, v) >= 0 }
+		highest = false
+	case v == semver.Canonical(v) || strings.HasSuffix(v, "+incompatible"):
+		// Exact versions don't need to be listed, since pseudo-versions never are
+		return v, true, nil
+	default:
+		// Prefix like v1 or v1.2, which can't match v1.20 so compare the components
+		match = func(x string) bool { return x == v || strings.HasPrefix(x, v+".") }
+	}
+
+	return bestMatch(versions, match, highest), true, nil
+}
+
+// bestMatch returns the highest or lowest version in versions that match,
+// or an empty string if none do.
+func bestMatch(versions []string, match func(string) bool, highest bool) string {
+	// Prefer releases, but fall back to pre-releases like the go command
+	for _, pre := range []bool{false, true} {
+		found := ""
+		for _, x := range versions {
+			if (semver.Prerelease(x) != "") != pre || !match(x) {
+				continue
+			}
+			if found == "" || (highest && semver.Compare(x, found) > 0) || (!highest && semver.Compare(x, found) < 0) {
+				found = x
+			}
+		}
+		if found != "" {
+			return found
+		}
+	}
+	return ""
+}
+
+// IsRange reports whether constraint is a caret or tilde range, ex: '^v1.2' or '~1.2.3'.
+// The go command doesn't support them, so they can only be resolved by a Resolver.
+func IsRange(constraint string) bool {
+	return strings.HasPrefix(constraint, "^") || strings.HasPrefix(constraint, "~")
+}
+
+// matchRange returns a function that reports whether a version is in the caret or tilde range.
+func matchRange(constraint string) (func(string) bool, error) {
+	op, v := constraint[:1], constraint[1:]
+	if !strings.HasPrefix(v, "v") {
+		v = "v" + v
+	}
+	if !semver.IsValid(v) || semver.Build(v) != "" {
+		return nil, fmt.Errorf("resolver: invalid version %q in constraint %q", v, constraint)
+	}
+	// The number of components that were given, ex: 2 for v1.2, which decides what can change
+	n := strings.Count(strings.TrimSuffix(v, semver.Prerelease(v)), ".") + 1
+	lower := semver.Canonical(v)
+	var parts [3]int
+	for i, p := range strings.SplitN(strings.TrimPrefix(strings.TrimSuffix(lower, semver.Prerelease(lower)), "v"), ".", 3) {
+		x, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("resolver: invalid version %q in constraint %q", v, constraint)
+		}
+		parts[i] = x
+	}
+
+	// Index of the component that is increased to get the exclusive upper bound
+	i := 0
+	if op == "~" {
+		if n > 1 {
+			i = 1
+		}
+	} else {
+		// The first non-zero component can't change, unless it wasn't given
+		for i < n-1 && parts[i] == 0 {
+			i++
+		}
+	}
+	var upper [3]int
+	copy(upper[:i], parts[:i])
+	upper[i] = parts[i] + 1
+	// Pre-releases of the upper bound are excluded too
+	upperVersion := fmt.Sprintf("v%d.%d.%d-0", upper[0], upper[1], upper[2])
+	return func(x string) bool {
+		return semver.Compare(x, lower) >= 0 && semver.Compare(x, upperVersion) < 0
+	}, nil
+}
@@ -0,0 +1,192 @@
+package resolver_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/getshiphub/shed/resolver"
+	"golang.org/x/mod/module"
+)
+
+// newProxy returns a proxy server that serves the given files, which are paths relative to the proxy URL.
+func newProxy(t *testing.T, files map[string]string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(data)) //nolint:errcheck
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+var proxyFiles = map[string]string{
+	"/golang.org/x/tools/@v/list":           "v0.1.0\nv0.1.1\nv0.2.0-pre.1\nv0.1.10\n",
+	"/golang.org/x/tools/@v/master.info":    `{"Version":"v0.2.0-pre.1.0.20210101000000-abcdefabcdef"}`,
+	"/github.com/!shopify/ejson/@v/list":    "v1.0.0-rc.1\n",
+	"/github.com/cszatmary/go-fish/@v/list": "",
+	"/github.com/cszatmary/go-fish/@latest": `{"Version":"v0.0.0-20210101000000-abcdefabcdef"}`,
+}
+
+func TestProxyResolve(t *testing.T) {
+	srv := newProxy(t, proxyFiles)
+	// The first proxy doesn't have anything so the second one is used
+	empty := newProxy(t, nil)
+	p := resolver.NewProxy(srv.Client(), empty.URL, srv.URL+"/")
+
+	tests := []struct {
+		name       string
+		path       string
+		constraint string
+		want       module.Version
+	}{
+		{"latest release", "golang.org/x/tools/cmd/stringer", "", module.Version{Path: "golang.org/x/tools", Version: "v0.1.10"}},
+		{"latest pre-release", "github.com/Shopify/ejson/cmd/ejson", "latest", module.Version{Path: "github.com/Shopify/ejson", Version: "v1.0.0-rc.1"}},
+		{"latest pseudo-version", "github.com/cszatmary/go-fish", "", module.Version{Path: "github.com/cszatmary/go-fish", Version: "v0.0.0-20210101000000-abcdefabcdef"}},
+		{"exact", "golang.org/x/tools/cmd/stringer", "v0.1.1", module.Version{Path: "golang.org/x/tools", Version: "v0.1.1"}},
+		{"prefix", "golang.org/x/tools/cmd/stringer", "v0.1", module.Version{Path: "golang.org/x/tools", Version: "v0.1.10"}},
+		{"less than", "golang.org/x/tools/cmd/stringer", "<v0.1.10", module.Version{Path: "golang.org/x/tools", Version: "v0.1.1"}},
+		{"greater than", "golang.org/x/tools/cmd/stringer", ">v0.1.0", module.Version{Path: "golang.org/x/tools", Version: "v0.1.1"}},
+		{"greater than pre-release", "golang.org/x/tools/cmd/stringer", ">=v0.1.11", module.Version{Path: "golang.org/x/tools", Version: "v0.2.0-pre.1"}},
+		{"branch", "golang.org/x/tools/cmd/stringer", "master", module.Version{Path: "golang.org/x/tools", Version: "v0.2.0-pre.1.0.20210101000000-abcdefabcdef"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got module.Version
+			var err error
+			if tt.constraint == "" {
+				got, err = p.ResolveLatest(context.Background(), tt.path)
+			} else {
+				got, err = p.ResolveConstraint(context.Background(), tt.path, tt.constraint)
+			}
+			if err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxyResolveError(t *testing.T) {
+	srv := newProxy(t, proxyFiles)
+	p := resolver.NewProxy(srv.Client(), srv.URL)
+
+	tests := []struct {
+		name       string
+		path       string
+		constraint string
+		wantErr    error
+	}{
+		{"unknown module", "example.org/foo/bar", "latest", resolver.ErrNotFound},
+		{"no matching version", "golang.org/x/tools/cmd/stringer", "v2", resolver.ErrNotFound},
+		{"unknown branch", "golang.org/x/tools/cmd/stringer", "dev", resolver.ErrNotFound},
+		{"invalid constraint", "golang.org/x/tools/cmd/stringer", "<foo", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.ResolveConstraint(context.Background(), tt.path, tt.constraint)
+			if err == nil {
+				t.Fatal("want non-nil error, got nil")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProxyListVersions(t *testing.T) {
+	srv := newProxy(t, proxyFiles)
+	p := resolver.NewProxy(srv.Client(), srv.URL)
+	got, err := p.ListVersions(context.Background(), "golang.org/x/tools/cmd/stringer")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := []string{"v0.1.0", "v0.1.1", "v0.1.10", "v0.2.0-pre.1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestProxyRetry(t *testing.T) {
+	proxy := newProxy(t, proxyFiles)
+	requests := 0
+	// Unavailable for the first request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		proxy.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	p := resolver.NewProxy(srv.Client(), srv.URL).WithRetry(2, time.Millisecond, 0)
+	got, err := p.ListVersions(context.Background(), "golang.org/x/tools")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if len(got) != 4 || requests != 2 {
+		t.Errorf("got versions %v after %d requests, want 4 versions after 2 requests", got, requests)
+	}
+
+	// Missing modules aren't retried
+	requests = 1
+	_, err = p.ListVersions(context.Background(), "example.com/missing")
+	if !errors.Is(err, resolver.ErrNotFound) || requests != 2 {
+		t.Errorf("got error %v after %d requests, want %v after 1 request", err, requests-1, resolver.ErrNotFound)
+	}
+}
+
+func TestMatchRange(t *testing.T) {
+	versions := []string{"v0.0.3", "v0.0.4", "v0.3.1", "v0.3.5", "v0.4.0", "v1.33.1", "v1.33.2", "v1.33.9", "v1.34.0", "v1.50.0", "v1.52.2", "v2.0.0-rc.1", "v2.0.0"}
+	tests := []struct {
+		constraint string
+		want       string
+	}{
+		{"^1.50", "v1.52.2"},
+		{"^v1.33.2", "v1.52.2"},
+		{"^1.60", ""},
+		{"^0.3.2", "v0.3.5"},
+		{"^0.0.3", "v0.0.3"},
+		{"^0", "v0.4.0"},
+		{"^2", "v2.0.0"},
+		{"~1.33.2", "v1.33.9"},
+		{"~1.33", "v1.33.9"},
+		{"~1", "v1.52.2"},
+		{"~v0.3", "v0.3.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			got, ok, err := resolver.Match(versions, tt.constraint)
+			if err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+			if !ok {
+				t.Fatal("want ok to be true, got false")
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Pre-releases are only used if no release matches, and never those of the next major version
+	got, _, err := resolver.Match([]string{"v1.0.0-rc.1", "v1.0.0-rc.2", "v2.0.0-rc.1"}, "^1.0.0-rc.1")
+	if err != nil || got != "v1.0.0-rc.2" {
+		t.Errorf("got %q with error %v, want %q", got, err, "v1.0.0-rc.2")
+	}
+	if _, _, err := resolver.Match(versions, "^1.x"); err == nil {
+		t.Error("want error for invalid range, got nil")
+	}
+}
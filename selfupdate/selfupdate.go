@@ -0,0 +1,217 @@
+// Package selfupdate updates the shed binary to the latest release.
+//
+// Releases are published on GitHub by goreleaser, with an archive for each platform and a
+// file with the SHA-256 hash of each archive. The archive is checked against its hash before
+// the binary is extracted, and the running binary is replaced atomically, so an interrupted
+// update never leaves a partial binary behind.
+package selfupdate
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/getshiphub/shed/github"
+	"golang.org/x/mod/semver"
+)
+
+// ErrNoAsset is returned when a release has no archive for the platform.
+var ErrNoAsset = errors.New("selfupdate: no release asset for platform")
+
+// ErrChecksumMismatch is returned when a downloaded archive doesn't match the hash published with the release.
+var ErrChecksumMismatch = errors.New("selfupdate: checksum mismatch")
+
+// Repo is the GitHub repository shed is released from.
+const Repo = "getshiphub/shed"
+
+// Release is a release of shed.
+type Release struct {
+	// Version is the version of the release, ex: 'v1.2.0'.
+	Version string
+	// URL is the web page of the release.
+	URL string
+	// Assets maps the names of the files of the release to their download URLs.
+	Assets map[string]string
+}
+
+// Newer reports whether r is newer than version. Development builds, which have an empty
+// or invalid version, are never updated, so r is never newer than them.
+func (r *Release) Newer(version string) bool {
+	return semver.IsValid(version) && semver.Compare(r.Version, version) > 0
+}
+
+// Updater finds and installs releases of shed.
+type Updater struct {
+	gh *github.Client
+}
+
+// New returns an Updater that uses gh to find and download releases. If gh is nil, github.FromEnv is used.
+func New(gh *github.Client) *Updater {
+	if gh == nil {
+		gh = github.FromEnv()
+	}
+	return &Updater{gh: gh}
+}
+
+// Latest returns the latest release of shed. Pre-releases and drafts are never the latest release.
+// If the rate limit of the GitHub API was exceeded, the error is a *github.RateLimitError.
+func (u *Updater) Latest(ctx context.Context) (*Release, error) {
+	var body struct {
+		TagName string `json:"tag_name"`
+		HTMLURL string `json:"html_url"`
+		Assets  []struct {
+			Name string `json:"name"`
+			URL  string `json:"browser_download_url"`
+		} `json:"assets"`
+	}
+	if err := u.gh.Get(ctx, "/repos/"+Repo+"/releases/latest", &body); err != nil {
+		return nil, fmt.Errorf("selfupdate: failed to find latest release: %w", err)
+	}
+	if !semver.IsValid(body.TagName) {
+		return nil, fmt.Errorf("selfupdate: latest release has invalid version %q", body.TagName)
+	}
+	r := &Release{Version: body.TagName, URL: body.HTMLURL, Assets: make(map[string]string, len(body.Assets))}
+	for _, a := range body.Assets {
+		r.Assets[a.Name] = a.URL
+	}
+	return r, nil
+}
+
+// ArchiveName returns the name of the archive of version for goos and goarch,
+// using the names in .goreleaser.yml, ex: 'shed_1.2.0_Linux_x86_64.tar.gz'.
+func ArchiveName(version, goos, goarch string) string {
+	osNames := map[string]string{"darwin": "Darwin", "linux": "Linux", "windows": "Windows"}
+	archNames := map[string]string{"386": "i386", "amd64": "x86_64", "arm": "armv6"}
+	if name, ok := osNames[goos]; ok {
+		goos = name
+	}
+	if name, ok := archNames[goarch]; ok {
+		goarch = name
+	}
+	return fmt.Sprintf("shed_%s_%s_%s.tar.gz", strings.TrimPrefix(version, "v"), goos, goarch)
+}
+
+// checksumsName returns the name of the file with the hashes of the archives of version.
+func checksumsName(version string) string {
+	return fmt.Sprintf("shed_%s_checksums.txt", strings.TrimPrefix(version, "v"))
+}
+
+// Install downloads the archive of r for the running platform and replaces the binary at exePath with
+// the one in it. The archive must match the hash in the checksums file of the release, otherwise the error
+// matches ErrChecksumMismatch and nothing is changed. The new binary is written next to exePath and renamed
+// over it, so exePath is always either the old or the new binary. On Windows, where a running binary
+// can't be replaced, the old binary is moved to exePath with '.old' added, and removed by the next update.
+func (u *Updater) Install(ctx context.Context, r *Release, exePath string) error {
+	name := ArchiveName(r.Version, runtime.GOOS, runtime.GOARCH)
+	archiveURL, ok := r.Assets[name]
+	if !ok {
+		return fmt.Errorf("%w: %s has no %s", ErrNoAsset, r.Version, name)
+	}
+	sumsURL, ok := r.Assets[checksumsName(r.Version)]
+	if !ok {
+		return fmt.Errorf("%w: %s has no checksums file", ErrNoAsset, r.Version)
+	}
+	var sums bytes.Buffer
+	if err := u.gh.Download(ctx, sumsURL, &sums); err != nil {
+		return fmt.Errorf("selfupdate: failed to download checksums file of %s: %w", r.Version, err)
+	}
+	want, ok := github.FindChecksum(sums.Bytes(), name)
+	if !ok {
+		return fmt.Errorf("selfupdate: checksums file of %s has no hash for %s", r.Version, name)
+	}
+
+	var buf bytes.Buffer
+	if err := u.gh.Download(ctx, archiveURL, &buf); err != nil {
+		return fmt.Errorf("selfupdate: failed to download %s: %w", name, err)
+	}
+	archive := buf.Bytes()
+	got := sha256.Sum256(archive)
+	if got := hex.EncodeToString(got[:]); got != want {
+		return fmt.Errorf("%w: %s has hash %s, want %s", ErrChecksumMismatch, name, got, want)
+	}
+
+	binName := "shed"
+	if runtime.GOOS == "windows" {
+		binName += ".exe"
+	}
+	binary, err := extractFile(archive, binName)
+	if err != nil {
+		return fmt.Errorf("selfupdate: failed to extract %s from %s: %w", binName, name, err)
+	}
+	return replaceBinary(exePath, binary)
+}
+
+// replaceBinary atomically replaces the binary at exePath with binary.
+func replaceBinary(exePath string, binary []byte) error {
+	dir := filepath.Dir(exePath)
+	f, err := ioutil.TempFile(dir, "."+filepath.Base(exePath)+".new*")
+	if err != nil {
+		return fmt.Errorf("selfupdate: failed to create temp file in %s: %w", dir, err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(binary)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("selfupdate: failed to write new binary: %w", err)
+	}
+	// Keep the permissions of the old binary, ex: if it was installed for all users
+	mode := os.FileMode(0o755)
+	if info, err := os.Stat(exePath); err == nil {
+		mode = info.Mode().Perm() | 0o111
+	}
+	if err := os.Chmod(f.Name(), mode); err != nil {
+		return fmt.Errorf("selfupdate: failed to make new binary executable: %w", err)
+	}
+	if runtime.GOOS == "windows" {
+		old := exePath + ".old"
+		// Left behind by the previous update, it can be removed now that it isn't running
+		os.Remove(old)
+		if err := os.Rename(exePath, old); err != nil {
+			return fmt.Errorf("selfupdate: failed to move old binary %s: %w", exePath, err)
+		}
+		if err := os.Rename(f.Name(), exePath); err != nil {
+			// Put the old binary back so shed keeps working
+			os.Rename(old, exePath) //nolint:errcheck
+			return fmt.Errorf("selfupdate: failed to replace %s: %w", exePath, err)
+		}
+		return nil
+	}
+	if err := os.Rename(f.Name(), exePath); err != nil {
+		return fmt.Errorf("selfupdate: failed to replace %s: %w", exePath, err)
+	}
+	return nil
+}
+
+// extractFile returns the contents of the regular file named name at the root of the tar.gz archive.
+func extractFile(archive []byte, name string) ([]byte, error) {
+	gr, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, errors.New("file not found")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag == tar.TypeReg && strings.TrimPrefix(hdr.Name, "./") == name {
+			return ioutil.ReadAll(tr)
+		}
+	}
+}
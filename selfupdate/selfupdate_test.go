@@ -0,0 +1,148 @@
+package selfupdate_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/getshiphub/shed/github"
+	"github.com/getshiphub/shed/selfupdate"
+)
+
+func newArchive(t *testing.T, name string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{{"README.md", []byte("# shed")}, {name, data}} {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0o755, Size: int64(len(f.data))}); err != nil {
+			t.Fatalf("failed to write header %v", err)
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			t.Fatalf("failed to write file %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar writer %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("failed to close gzip writer %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestArchiveName(t *testing.T) {
+	tests := []struct {
+		goos, goarch string
+		want         string
+	}{
+		{"linux", "amd64", "shed_1.2.0_Linux_x86_64.tar.gz"},
+		{"darwin", "arm64", "shed_1.2.0_Darwin_arm64.tar.gz"},
+		{"windows", "386", "shed_1.2.0_Windows_i386.tar.gz"},
+		{"linux", "arm", "shed_1.2.0_Linux_armv6.tar.gz"},
+	}
+	for _, tt := range tests {
+		if got := selfupdate.ArchiveName("v1.2.0", tt.goos, tt.goarch); got != tt.want {
+			t.Errorf("got %s for %s/%s, want %s", got, tt.goos, tt.goarch, tt.want)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	binName := "shed"
+	if runtime.GOOS == "windows" {
+		binName += ".exe"
+	}
+	archiveName := selfupdate.ArchiveName("v1.2.0", runtime.GOOS, runtime.GOARCH)
+	archive := newArchive(t, binName, []byte("new shed"))
+	sum := sha256.Sum256(archive)
+	checksums := fmt.Sprintf("%x  %s\n%x  shed_1.2.0_Other_arch.tar.gz\n", sum, archiveName, sha256.Sum256(nil))
+
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/getshiphub/shed/releases/latest":
+			auth = r.Header.Get("Authorization")
+			fmt.Fprintf(w, `{"tag_name": "v1.2.0", "html_url": "https://github.com/getshiphub/shed/releases/tag/v1.2.0", "assets": [
+				{"name": %q, "browser_download_url": "http://%[2]s/download/archive"},
+				{"name": "shed_1.2.0_checksums.txt", "browser_download_url": "http://%[2]s/download/checksums"}
+			]}`, archiveName, r.Host)
+		case "/download/archive":
+			w.Write(archive) //nolint:errcheck
+		case "/download/checksums":
+			w.Write([]byte(checksums)) //nolint:errcheck
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	updater := selfupdate.New(github.New(srv.Client(), srv.URL, "token"))
+
+	release, err := updater.Latest(context.Background())
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if release.Version != "v1.2.0" || release.URL != "https://github.com/getshiphub/shed/releases/tag/v1.2.0" {
+		t.Errorf("got release %+v, want v1.2.0", release)
+	}
+	if auth != "Bearer token" {
+		t.Errorf("got Authorization header %q, want %q", auth, "Bearer token")
+	}
+	if !release.Newer("v1.1.3") || release.Newer("v1.2.0") || release.Newer("") {
+		t.Errorf("want v1.2.0 to only be newer than v1.1.3")
+	}
+
+	exePath := filepath.Join(t.TempDir(), binName)
+	if err := ioutil.WriteFile(exePath, []byte("old shed"), 0o755); err != nil {
+		t.Fatalf("failed to write binary %v", err)
+	}
+
+	// A corrupted download is rejected and the binary is left as is
+	good := archive
+	archive = append(append([]byte{}, good...), 0)
+	if err := updater.Install(context.Background(), release, exePath); !errors.Is(err, selfupdate.ErrChecksumMismatch) {
+		t.Errorf("got error %v, want %v", err, selfupdate.ErrChecksumMismatch)
+	}
+	if data, _ := ioutil.ReadFile(exePath); string(data) != "old shed" {
+		t.Errorf("got binary %q, want it to be unchanged", data)
+	}
+	archive = good
+
+	if err := updater.Install(context.Background(), release, exePath); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	data, err := ioutil.ReadFile(exePath)
+	if err != nil {
+		t.Fatalf("failed to read binary %v", err)
+	}
+	if string(data) != "new shed" {
+		t.Errorf("got binary %q, want %q", data, "new shed")
+	}
+	entries, err := ioutil.ReadDir(filepath.Dir(exePath))
+	if err != nil {
+		t.Fatalf("failed to read dir %v", err)
+	}
+	for _, e := range entries {
+		if e.Name() != binName && e.Name() != binName+".old" {
+			t.Errorf("got unexpected file %s, want temp files to be removed", e.Name())
+		}
+	}
+
+	delete(release.Assets, archiveName)
+	if err := updater.Install(context.Background(), release, exePath); !errors.Is(err, selfupdate.ErrNoAsset) {
+		t.Errorf("got error %v, want %v", err, selfupdate.ErrNoAsset)
+	}
+}
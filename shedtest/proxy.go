@@ -0,0 +1,123 @@
+package shedtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/getshiphub/shed/resolver"
+	"golang.org/x/mod/module"
+)
+
+// Proxy is a module proxy that serves fixed lists of versions using the GOPROXY protocol.
+// Only the endpoints needed to resolve versions are served: the list of versions, the latest
+// version, and the info of a version. It is safe for concurrent use.
+type Proxy struct {
+	// URL is the URL of the proxy, ex: to use with resolver.NewProxy or as GOPROXY.
+	URL string
+
+	mu       sync.Mutex
+	versions map[string][]string
+	requests []string
+}
+
+// NewProxy starts a Proxy that serves versions, which maps module paths to their versions, and
+// stops it when tb finishes. Versions that aren't valid semantic versions are served as is, which
+// allows testing how invalid responses are handled.
+func NewProxy(tb testing.TB, versions map[string][]string) *Proxy {
+	tb.Helper()
+	p := &Proxy{versions: make(map[string][]string)}
+	for modPath, vs := range versions {
+		p.versions[modPath] = append([]string(nil), vs...)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(p.serveHTTP))
+	tb.Cleanup(srv.Close)
+	p.URL = srv.URL
+	return p
+}
+
+// Resolver returns a resolver that uses p.
+func (p *Proxy) Resolver() *resolver.Proxy {
+	return resolver.NewProxy(nil, p.URL)
+}
+
+// SetVersions replaces the versions of the module modPath, ex: to test a new version being released.
+// If versions is empty, the module is removed from p.
+func (p *Proxy) SetVersions(modPath string, versions ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(versions) == 0 {
+		delete(p.versions, modPath)
+		return
+	}
+	p.versions[modPath] = append([]string(nil), versions...)
+}
+
+// Requests returns the paths of the requests made to p so far, in the order they were made,
+// ex: '/golang.org/x/tools/@v/list'.
+func (p *Proxy) Requests() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.requests...)
+}
+
+func (p *Proxy) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	p.requests = append(p.requests, r.URL.Path)
+	p.mu.Unlock()
+
+	i := strings.Index(r.URL.Path, "/@")
+	if i == -1 {
+		http.NotFound(w, r)
+		return
+	}
+	modPath, err := module.UnescapePath(strings.TrimPrefix(r.URL.Path[:i], "/"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	p.mu.Lock()
+	versions, ok := p.versions[modPath]
+	p.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	file := r.URL.Path[i+2:]
+	switch {
+	case file == "v/list":
+		w.Write([]byte(strings.Join(versions, "\n") + "\n"))
+	case file == "latest":
+		latest := resolver.Latest(versions)
+		if latest == "" {
+			http.NotFound(w, r)
+			return
+		}
+		writeInfo(w, latest)
+	case strings.HasPrefix(file, "v/") && strings.HasSuffix(file, ".info"):
+		v, err := module.UnescapeVersion(strings.TrimSuffix(strings.TrimPrefix(file, "v/"), ".info"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		for _, known := range versions {
+			if known == v {
+				writeInfo(w, v)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// writeInfo writes the version info of version v.
+func writeInfo(w http.ResponseWriter, v string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct{ Version string }{v})
+}
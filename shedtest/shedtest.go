@@ -0,0 +1,263 @@
+// Package shedtest provides fakes for testing code that uses shed, without the network or the go command.
+//
+// Go is a fake of the go command that can be passed to cache.WithGo. It serves a fixed set of modules,
+// like cache.NewMockGo, and can also be made to fail, to be slow, and to record how it was called.
+// NewProxy serves fixed lists of versions using the GOPROXY protocol, for resolving versions with
+// resolver.NewProxy and client.WithResolver.
+//
+// For example, to test how a failed build is reported:
+//
+//	g, err := shedtest.NewGo(map[string]map[string]string{
+//		"golang.org/x/tools/cmd/stringer": {"v0.12.0": "v0.12.0"},
+//	})
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	g.Fail(shedtest.Failure{Op: shedtest.OpBuild, Path: "golang.org/x/tools/cmd/stringer", Err: errors.New("exit status 2")})
+//	s, err := client.NewShed(
+//		client.WithLockfilePath(filepath.Join(t.TempDir(), "shed.lock")),
+//		client.WithCache(shedtest.NewCache(t, g)),
+//	)
+package shedtest
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/tool"
+	"golang.org/x/mod/module"
+)
+
+// Op identifies a method of cache.Go.
+type Op string
+
+const (
+	// OpBuild is cache.Go.Build. Its path is the import path of the package being built.
+	OpBuild Op = "build"
+	// OpDownload is cache.Go.GetD. Its path is the import path of the tool being downloaded.
+	OpDownload Op = "download"
+	// OpList is cache.Go.ListM. Its path is the module path being resolved.
+	OpList Op = "list"
+	// OpModTidy is cache.Go.ModTidy. It has no path.
+	OpModTidy Op = "modtidy"
+	// OpEnv is cache.Go.Env. It has no path.
+	OpEnv Op = "env"
+)
+
+// Call is a call to a method of Go.
+type Call struct {
+	// Op is the method that was called.
+	Op Op
+	// Path is the import path or module path the call was for, see Op. It is empty for OpModTidy and OpEnv.
+	Path string
+	// Version is the version or module query of the call, ex: 'latest'. It is only set for OpDownload and OpList.
+	Version string
+	// Dir is the working directory of the call. It is empty for OpEnv.
+	Dir string
+	// Flags are the build flags of the call. It is only set for OpBuild.
+	Flags []string
+	// Env is the environment the call was made with.
+	Env []string
+	// Vars are the variables requested by the call. It is only set for OpEnv.
+	Vars []string
+	// Err is the error returned by the call.
+	Err error
+}
+
+// Failure makes calls to Go fail.
+type Failure struct {
+	// Op is the method that fails.
+	Op Op
+	// Path is the import path or module path of the calls that fail, see Op.
+	// If it is empty, every call to Op fails.
+	Path string
+	// Err is the error returned by the calls. Errors whose message contains text that the go command prints
+	// for network problems, ex: 'connection reset by peer', are treated as transient and are retried by the cache.
+	Err error
+	// Times is the number of calls that fail, after which calls succeed again. If it is 0, every call fails.
+	Times int
+}
+
+// Go is a fake of the go command that implements cache.Go. It is safe for concurrent use.
+type Go struct {
+	mock cache.Go
+
+	mu       sync.Mutex
+	failures []*Failure
+	latency  map[Op]time.Duration
+	calls    []Call
+}
+
+// NewGo returns a Go that serves the given tools. tools is a map of import paths to a map of queries
+// to versions, the same as cache.NewMockGo. Versions that are also queries, ex: 'v1.2.0': 'v1.2.0', are
+// the versions of the module, and the latest of them is used for 'latest'. Other queries, like branches
+// and commits, resolve to the version they map to.
+//
+// Built binaries contain the build flags they were built with, separated by spaces, instead of a program.
+func NewGo(tools map[string]map[string]string) (*Go, error) {
+	mock, err := cache.NewMockGo(tools)
+	if err != nil {
+		return nil, err
+	}
+	return &Go{mock: mock, latency: make(map[Op]time.Duration)}, nil
+}
+
+// NewCache returns a cache in a temporary directory of tb that uses g instead of the go command.
+// opts are applied after g, ex: to set a remote cache.
+func NewCache(tb testing.TB, g cache.Go, opts ...cache.Option) *cache.Cache {
+	tb.Helper()
+	return cache.New(tb.TempDir(), append([]cache.Option{cache.WithGo(g)}, opts...)...)
+}
+
+// Fail makes the calls described by f fail. Failures are checked in the order they were added,
+// and the first one that matches a call is used.
+func (g *Go) Fail(f Failure) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.failures = append(g.failures, &f)
+}
+
+// SetLatency makes each call to op take at least d, ex: to test timeouts or progress reporting.
+// If the context of a call becomes done first, the call returns the error of the context.
+func (g *Go) SetLatency(op Op, d time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.latency[op] = d
+}
+
+// Calls returns the calls made to g so far, in the order they were made.
+func (g *Go) Calls() []Call {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	calls := make([]Call, len(g.calls))
+	copy(calls, g.calls)
+	return calls
+}
+
+// CallsTo returns the calls made to op so far, in the order they were made.
+func (g *Go) CallsTo(op Op) []Call {
+	var calls []Call
+	for _, c := range g.Calls() {
+		if c.Op == op {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Reset forgets the calls made to g so far, and removes its failures and latencies.
+func (g *Go) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.failures = nil
+	g.latency = make(map[Op]time.Duration)
+	g.calls = nil
+}
+
+// begin waits for the latency of c.Op and returns the error of the failure that matches c, if any.
+func (g *Go) begin(ctx context.Context, c Call) error {
+	g.mu.Lock()
+	d := g.latency[c.Op]
+	var err error
+	for i, f := range g.failures {
+		if f.Op != c.Op || (f.Path != "" && f.Path != c.Path) {
+			continue
+		}
+		err = f.Err
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				g.failures = append(g.failures[:i:i], g.failures[i+1:]...)
+			}
+		}
+		break
+	}
+	g.mu.Unlock()
+	if d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return err
+}
+
+// record records c, which returned err.
+func (g *Go) record(c Call, err error) {
+	c.Err = err
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.calls = append(g.calls, c)
+}
+
+// copyStrings returns a copy of s, so calls can't be changed by the caller after they are recorded.
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string(nil), s...)
+}
+
+// Build implements cache.Go.
+func (g *Go) Build(ctx context.Context, pkg, outPath, dir string, flags, env []string) (err error) {
+	c := Call{Op: OpBuild, Path: pkg, Dir: dir, Flags: copyStrings(flags), Env: copyStrings(env)}
+	defer func() { g.record(c, err) }()
+	if err := g.begin(ctx, c); err != nil {
+		return err
+	}
+	return g.mock.Build(ctx, pkg, outPath, dir, flags, env)
+}
+
+// GetD implements cache.Go.
+func (g *Go) GetD(ctx context.Context, mod, dir string, env []string) (err error) {
+	c := Call{Op: OpDownload, Path: mod, Dir: dir, Env: copyStrings(env)}
+	if t, err := tool.ParseLax(mod); err == nil {
+		c.Path, c.Version = t.ImportPath, t.Version
+	}
+	defer func() { g.record(c, err) }()
+	if err := g.begin(ctx, c); err != nil {
+		return err
+	}
+	return g.mock.GetD(ctx, mod, dir, env)
+}
+
+// ListM implements cache.Go.
+func (g *Go) ListM(ctx context.Context, mod, dir string, env []string) (v module.Version, err error) {
+	c := Call{Op: OpList, Path: mod, Dir: dir, Env: copyStrings(env)}
+	if i := strings.LastIndexByte(mod, '@'); i != -1 {
+		c.Path, c.Version = mod[:i], mod[i+1:]
+	}
+	defer func() { g.record(c, err) }()
+	if err := g.begin(ctx, c); err != nil {
+		return module.Version{}, err
+	}
+	return g.mock.ListM(ctx, mod, dir, env)
+}
+
+// ModTidy implements cache.Go.
+func (g *Go) ModTidy(ctx context.Context, dir string, env []string) (err error) {
+	c := Call{Op: OpModTidy, Dir: dir, Env: copyStrings(env)}
+	defer func() { g.record(c, err) }()
+	if err := g.begin(ctx, c); err != nil {
+		return err
+	}
+	return g.mock.ModTidy(ctx, dir, env)
+}
+
+// Env implements cache.Go. It reports the platform and version of Go that the test is running with,
+// unless they are overridden by env, and falls back to the environment of the process for other variables.
+func (g *Go) Env(ctx context.Context, env []string, vars ...string) (values []string, err error) {
+	c := Call{Op: OpEnv, Env: copyStrings(env), Vars: copyStrings(vars)}
+	defer func() { g.record(c, err) }()
+	if err := g.begin(ctx, c); err != nil {
+		return nil, err
+	}
+	return g.mock.Env(ctx, env, vars...)
+}
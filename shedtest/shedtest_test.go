@@ -0,0 +1,130 @@
+package shedtest_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/getshiphub/shed/cache"
+	"github.com/getshiphub/shed/client"
+	"github.com/getshiphub/shed/lockfile"
+	"github.com/getshiphub/shed/shedtest"
+)
+
+var tools = map[string]map[string]string{
+	"github.com/cszatmary/go-fish": {
+		"v0.1.0": "v0.1.0",
+		"v0.2.0": "v0.2.0",
+	},
+}
+
+func newShed(t *testing.T, g *shedtest.Go, opts ...client.Option) *client.Shed {
+	t.Helper()
+	opts = append([]client.Option{
+		client.WithLockfilePath(filepath.Join(t.TempDir(), "shed.lock")),
+		client.WithCache(shedtest.NewCache(t, g)),
+	}, opts...)
+	s, err := client.NewShed(opts...)
+	if err != nil {
+		t.Fatalf("failed to create shed client %v", err)
+	}
+	return s
+}
+
+func install(s *client.Shed, toolNames ...string) error {
+	installSet, err := s.Install(toolNames...)
+	if err != nil {
+		return err
+	}
+	return installSet.Apply(context.Background())
+}
+
+func TestGoFail(t *testing.T) {
+	g, err := shedtest.NewGo(tools)
+	if err != nil {
+		t.Fatalf("failed to create fake go %v", err)
+	}
+	s := newShed(t, g)
+	g.Fail(shedtest.Failure{Op: shedtest.OpBuild, Path: "github.com/cszatmary/go-fish", Err: errors.New("exit status 2"), Times: 1})
+
+	err = install(s, "github.com/cszatmary/go-fish@v0.1.0")
+	var errs lockfile.ErrorList
+	if !errors.As(err, &errs) || len(errs) != 1 || !errors.Is(errs[0], cache.ErrBuildFailed) {
+		t.Fatalf("got error %v, want %v", err, cache.ErrBuildFailed)
+	}
+	// The failure only happens once
+	if err := install(s, "github.com/cszatmary/go-fish@v0.1.0"); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+
+	builds := g.CallsTo(shedtest.OpBuild)
+	if len(builds) != 2 {
+		t.Fatalf("got %d builds, want 2", len(builds))
+	}
+	if builds[0].Path != "github.com/cszatmary/go-fish" || builds[0].Err == nil || builds[1].Err != nil {
+		t.Errorf("got builds %+v, want the first build of go-fish to fail", builds)
+	}
+	downloads := g.CallsTo(shedtest.OpDownload)
+	if len(downloads) == 0 || downloads[0].Version != "v0.1.0" {
+		t.Errorf("got downloads %+v, want go-fish@v0.1.0 to be downloaded", downloads)
+	}
+
+	g.Reset()
+	if calls := g.Calls(); len(calls) != 0 {
+		t.Errorf("got calls %+v after reset, want none", calls)
+	}
+}
+
+func TestGoLatency(t *testing.T) {
+	g, err := shedtest.NewGo(tools)
+	if err != nil {
+		t.Fatalf("failed to create fake go %v", err)
+	}
+	s := newShed(t, g)
+	g.SetLatency(shedtest.OpDownload, time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	installSet, err := s.Install("github.com/cszatmary/go-fish@v0.1.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := installSet.Apply(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	g, err := shedtest.NewGo(tools)
+	if err != nil {
+		t.Fatalf("failed to create fake go %v", err)
+	}
+	p := shedtest.NewProxy(t, map[string][]string{
+		"github.com/cszatmary/go-fish": {"v0.1.0"},
+	})
+	s := newShed(t, g, client.WithResolver(p.Resolver()))
+
+	if err := install(s, "github.com/cszatmary/go-fish@latest"); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if tl := s.List()[0]; tl.Version != "v0.1.0" {
+		t.Errorf("got version %s, want v0.1.0 from the proxy", tl.Version)
+	}
+
+	// A new release is picked up by the resolver
+	p.SetVersions("github.com/cszatmary/go-fish", "v0.1.0", "v0.2.0")
+	if err := install(s, "github.com/cszatmary/go-fish@^v0.1"); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if err := install(s, "github.com/cszatmary/go-fish@latest"); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if tl := s.List()[0]; tl.Version != "v0.2.0" {
+		t.Errorf("got version %s, want v0.2.0 from the proxy", tl.Version)
+	}
+	if len(p.Requests()) == 0 {
+		t.Error("want requests to the proxy, got none")
+	}
+}
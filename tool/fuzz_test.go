@@ -0,0 +1,55 @@
+//go:build go1.18
+// +build go1.18
+
+package tool_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/getshiphub/shed/internal/util"
+	"github.com/getshiphub/shed/tool"
+)
+
+// FuzzFilepath checks that the filepaths of tools parsed from untrusted input, like a lockfile
+// from a third-party repo, always stay within the tools directory of the cache. Run with:
+//
+//	go test ./tool -run '^$' -fuzz FuzzFilepath
+func FuzzFilepath(f *testing.F) {
+	for _, seed := range []string{
+		"github.com/cszatmary/go-fish@v0.1.0",
+		"github.com/Shopify/ejson/cmd/ejson@v1.1.0",
+		"golang.org/x/tools/cmd/stringer@v0.0.0-20201211185031-d93e913c1a58",
+		"example.com/../../etc@v1.0.0",
+		"example.com/a/..@v1.0.0",
+		"/etc/passwd@v1.0.0",
+		`example.com\..\..\x@v1.0.0`,
+		"example.com/%2e%2e/x@v1.0.0",
+		"example.com/x@v1.0.0/../..",
+	} {
+		f.Add(seed, "")
+		f.Add(seed, "../alias")
+	}
+
+	f.Fuzz(func(t *testing.T, name, alias string) {
+		tl, err := tool.ParseLax(name)
+		if err != nil {
+			return
+		}
+		if alias != "" {
+			if tool.CheckAlias(alias) != nil {
+				return
+			}
+			tl.Alias = alias
+		}
+		for _, get := range []func() (string, error){tl.Filepath, tl.BinaryFilepath} {
+			fp, err := get()
+			if err != nil {
+				continue
+			}
+			if !util.IsLocalPath(filepath.ToSlash(fp)) {
+				t.Fatalf("got non-local path %q for tool %q with alias %q", fp, name, alias)
+			}
+		}
+	})
+}
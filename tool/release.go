@@ -0,0 +1,168 @@
+package tool
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Release describes a tool that is downloaded as a prebuilt binary from a release instead of being
+// built from a Go module. This allows tools that aren't written in Go, like shellcheck, to be managed
+// by shed. The import path of the tool only identifies it, ex: github.com/koalaman/shellcheck,
+// and the version must be an exact version, since the versions of a release can't be listed.
+//
+// The URL, Asset, and Binary fields are templates that are executed with ReleaseData.
+type Release struct {
+	// URL is the URL of the asset to download. Exactly one of URL and Asset must be set.
+	URL string
+	// Asset is the name of an asset of the GitHub release for the tag Version of the repository
+	// in the import path, which must be of the form github.com/OWNER/REPO.
+	Asset string
+	// Binary is the path of the binary in the asset if the asset is a .tar.gz, .tgz, or .zip archive.
+	// If empty, it is the file in the archive named after the last element of the import path,
+	// in any directory. Any other asset is the binary itself.
+	Binary string
+	// Sums are the hashes of the asset of each platform, keyed by 'GOOS/GOARCH',
+	// in the form 'sha256:HEX'. The hash of the asset of a platform is recorded
+	// the first time the tool is installed on it.
+	Sums map[string]string
+}
+
+// ReleaseData is the data used to execute the templates of a Release.
+type ReleaseData struct {
+	// Version is the version of the tool, ex: v0.9.0.
+	Version string
+	// Number is the version without the 'v' prefix, ex: 0.9.0.
+	Number string
+	// OS and Arch are the GOOS and GOARCH of the platform, ex: linux and amd64.
+	OS   string
+	Arch string
+}
+
+// Platform returns the key of the platform goos/goarch in Release.Sums.
+func Platform(goos, goarch string) string {
+	return goos + "/" + goarch
+}
+
+// ParsePlatform splits platform, in the form 'GOOS/GOARCH', into its GOOS and GOARCH.
+// Both must only contain lowercase letters and digits, like the values known to the go command.
+func ParsePlatform(platform string) (goos, goarch string, err error) {
+	i := strings.IndexByte(platform, '/')
+	if i == -1 {
+		return "", "", fmt.Errorf("tool: invalid platform %q, must be GOOS/GOARCH", platform)
+	}
+	goos, goarch = platform[:i], platform[i+1:]
+	for _, part := range []string{goos, goarch} {
+		if part == "" || strings.TrimFunc(part, func(r rune) bool { return ('a' <= r && r <= 'z') || ('0' <= r && r <= '9') }) != "" {
+			return "", "", fmt.Errorf("tool: invalid platform %q, must be GOOS/GOARCH", platform)
+		}
+	}
+	return goos, goarch, nil
+}
+
+// hash returns a short hash of the templates of the release that identifies them in file paths.
+// The sums are not included since they are filled in as the tool is installed.
+func (r *Release) hash() string {
+	h := sha256.Sum256([]byte(strings.Join([]string{r.URL, r.Asset, r.Binary}, "\x00")))
+	return hex.EncodeToString(h[:6])
+}
+
+// IsRelease reports whether t is downloaded from a release instead of being built.
+func (t Tool) IsRelease() bool {
+	return t.Release != nil
+}
+
+// ReleaseURL returns the URL of the release asset of t for the platform goos/goarch.
+func (t Tool) ReleaseURL(goos, goarch string) (string, error) {
+	if t.Release == nil {
+		return "", fmt.Errorf("tool: %s is not a release tool", t)
+	}
+	if t.Release.URL != "" {
+		return t.executeRelease("url", t.Release.URL, goos, goarch)
+	}
+	asset, err := t.executeRelease("asset", t.Release.Asset, goos, goarch)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("https://%s/releases/download/%s/%s", t.ImportPath, t.Version, asset), nil
+}
+
+// ReleaseBinary returns the path of the binary in the release asset of t for the platform goos/goarch,
+// if it is an archive. If Release.Binary is empty, an empty string is returned.
+func (t Tool) ReleaseBinary(goos, goarch string) (string, error) {
+	if t.Release == nil {
+		return "", fmt.Errorf("tool: %s is not a release tool", t)
+	}
+	if t.Release.Binary == "" {
+		return "", nil
+	}
+	return t.executeRelease("binary", t.Release.Binary, goos, goarch)
+}
+
+func (t Tool) executeRelease(name, text, goos, goarch string) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("tool: invalid %s template of %s: %w", name, t.ImportPath, err)
+	}
+	data := ReleaseData{
+		Version: t.Version,
+		Number:  strings.TrimPrefix(t.Version, "v"),
+		OS:      goos,
+		Arch:    goarch,
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("tool: failed to execute %s template of %s: %w", name, t.ImportPath, err)
+	}
+	return buf.String(), nil
+}
+
+// CheckRelease checks that the release of t is valid. Exactly one of URL and Asset must be set,
+// the templates must be valid, and the sums must be SHA-256 hashes keyed by platform.
+// If t is not a release tool, CheckRelease returns nil.
+func CheckRelease(t Tool) error {
+	r := t.Release
+	if r == nil {
+		return nil
+	}
+	switch {
+	case r.URL == "" && r.Asset == "":
+		return fmt.Errorf("tool: release of %s must have a URL or an asset", t.ImportPath)
+	case r.URL != "" && r.Asset != "":
+		return fmt.Errorf("tool: release of %s can't have both a URL and an asset", t.ImportPath)
+	case r.Asset != "" && (!strings.HasPrefix(t.ImportPath, "github.com/") || strings.Count(t.ImportPath, "/") != 2):
+		return fmt.Errorf("tool: release of %s has an asset, so its import path must be github.com/OWNER/REPO", t.ImportPath)
+	case !t.BuildFlags.IsZero():
+		return fmt.Errorf("tool: release tool %s can't have build flags", t.ImportPath)
+	}
+	for _, tmpl := range []struct{ name, text string }{{"url", r.URL}, {"asset", r.Asset}, {"binary", r.Binary}} {
+		if _, err := template.New(tmpl.name).Parse(tmpl.text); err != nil {
+			return fmt.Errorf("tool: invalid %s template of %s: %w", tmpl.name, t.ImportPath, err)
+		}
+	}
+	return checkSums("release of "+t.ImportPath, r.Sums)
+}
+
+// checkSums checks that sums are SHA-256 hashes keyed by platform. what describes the sums in errors.
+func checkSums(what string, sums map[string]string) error {
+	platforms := make([]string, 0, len(sums))
+	for p := range sums {
+		platforms = append(platforms, p)
+	}
+	// Check in order so the same error is always reported
+	sort.Strings(platforms)
+	for _, p := range platforms {
+		if _, _, err := ParsePlatform(p); err != nil {
+			return fmt.Errorf("tool: %s has a sum for invalid platform %q, must be GOOS/GOARCH", what, p)
+		}
+		sum := sums[p]
+		if b, err := hex.DecodeString(strings.TrimPrefix(sum, "sha256:")); !strings.HasPrefix(sum, "sha256:") || err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("tool: %s has invalid sum %q for %s, must be 'sha256:HEX'", what, sum, p)
+		}
+	}
+	return nil
+}
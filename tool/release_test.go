@@ -0,0 +1,112 @@
+package tool_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/getshiphub/shed/tool"
+)
+
+func TestReleaseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		release tool.Release
+		want    string
+	}{
+		{
+			name:    "url",
+			release: tool.Release{URL: "https://releases.hashicorp.com/terraform/{{.Number}}/terraform_{{.Number}}_{{.OS}}_{{.Arch}}.zip"},
+			want:    "https://releases.hashicorp.com/terraform/1.5.0/terraform_1.5.0_linux_amd64.zip",
+		},
+		{
+			name:    "github asset",
+			release: tool.Release{Asset: `tf-{{.Version}}.{{.OS}}.{{if eq .Arch "amd64"}}x86_64{{else}}{{.Arch}}{{end}}.tar.gz`},
+			want:    "https://github.com/hashicorp/terraform/releases/download/v1.5.0/tf-v1.5.0.linux.x86_64.tar.gz",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.release
+			tl := tool.Tool{ImportPath: "github.com/hashicorp/terraform", Version: "v1.5.0", Release: &r}
+			got, err := tl.ReleaseURL("linux", "amd64")
+			if err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReleaseFilepath(t *testing.T) {
+	built := tool.Tool{ImportPath: "github.com/koalaman/shellcheck", Version: "v0.9.0"}
+	released := built
+	released.Release = &tool.Release{Asset: "shellcheck-{{.Version}}.{{.OS}}.tar.gz"}
+	builtPath, err := built.Filepath()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	releasedPath, err := released.Filepath()
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if !strings.HasPrefix(releasedPath, builtPath+"+release.") {
+		t.Errorf("got path %q, want %q with a release hash", releasedPath, builtPath)
+	}
+
+	// The sums are filled in as the tool is installed, so they must not change the path
+	released.Release = &tool.Release{
+		Asset: "shellcheck-{{.Version}}.{{.OS}}.tar.gz",
+		Sums:  map[string]string{"linux/amd64": "sha256:" + strings.Repeat("ab", 32)},
+	}
+	if p, _ := released.Filepath(); p != releasedPath {
+		t.Errorf("got path %q with sums, want %q", p, releasedPath)
+	}
+}
+
+func TestCheckRelease(t *testing.T) {
+	validSum := "sha256:" + strings.Repeat("ab", 32)
+	tests := []struct {
+		name       string
+		importPath string
+		release    tool.Release
+		wantErr    bool
+	}{
+		{"url", "example.org/terraform", tool.Release{URL: "https://example.org/{{.OS}}", Sums: map[string]string{"linux/amd64": validSum}}, false},
+		{"asset", "github.com/koalaman/shellcheck", tool.Release{Asset: "shellcheck.{{.OS}}", Binary: "shellcheck-{{.Version}}/shellcheck"}, false},
+		{"no url or asset", "github.com/koalaman/shellcheck", tool.Release{}, true},
+		{"url and asset", "github.com/koalaman/shellcheck", tool.Release{URL: "https://example.org", Asset: "shellcheck"}, true},
+		{"asset not on github", "example.org/koalaman/shellcheck", tool.Release{Asset: "shellcheck"}, true},
+		{"asset in nested path", "github.com/koalaman/shellcheck/cmd", tool.Release{Asset: "shellcheck"}, true},
+		{"invalid template", "github.com/koalaman/shellcheck", tool.Release{Asset: "shellcheck-{{.Version"}, true},
+		{"invalid platform", "example.org/terraform", tool.Release{URL: "https://example.org", Sums: map[string]string{"linux": validSum}}, true},
+		{"invalid sum", "example.org/terraform", tool.Release{URL: "https://example.org", Sums: map[string]string{"linux/amd64": "sha256:abc"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.release
+			err := tool.CheckRelease(tool.Tool{ImportPath: tt.importPath, Version: "v1.0.0", Release: &r})
+			if tt.wantErr && err == nil {
+				t.Error("want non-nil error, got nil")
+			} else if !tt.wantErr && err != nil {
+				t.Errorf("want nil error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestParsePlatform(t *testing.T) {
+	goos, goarch, err := tool.ParsePlatform("linux/arm64")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if goos != "linux" || goarch != "arm64" {
+		t.Errorf("got %s and %s, want linux and arm64", goos, goarch)
+	}
+	for _, p := range []string{"", "linux", "linux/", "/amd64", "linux/amd64/v3", "Linux/amd64", "linux/amd_64"} {
+		if _, _, err := tool.ParsePlatform(p); err == nil {
+			t.Errorf("%q: want non-nil error, got nil", p)
+		}
+	}
+}
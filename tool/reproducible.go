@@ -0,0 +1,50 @@
+package tool
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Reproducible describes a tool that is built reproducibly, so that building the same version on any
+// machine produces a byte-identical binary. The tool is built with exactly the version of Go in GoVersion,
+// which the go command downloads if needed, with -trimpath, without VCS information or cgo, and without
+// the GOFLAGS of the environment. Reproducible builds can't be used with release tools, which aren't built.
+type Reproducible struct {
+	// GoVersion is the exact version of Go to build the tool with, ex: 'go1.22.4'. It must be go1.21.0 or newer,
+	// since older versions of the go command can't download other versions of Go.
+	GoVersion string
+	// Sums are the hashes of the binary of each platform, keyed by 'GOOS/GOARCH', in the form
+	// 'sha256:HEX'. The hash of the binary of a platform is recorded the first time the tool
+	// is built for it. Building the tool again must produce a binary with the same hash.
+	Sums map[string]string
+}
+
+// goVersionRegex matches the names of Go releases that can be used as GOTOOLCHAIN, ex: go1.22.4.
+var goVersionRegex = regexp.MustCompile(`^go1\.(\d+)\.\d+$`)
+
+// CheckReproducible checks that the reproducible build settings of t are valid. The version of Go must
+// be an exact release that is go1.21.0 or newer, the tool must not need cgo, and the sums must be SHA-256 hashes keyed by platform.
+// If t is not built reproducibly, CheckReproducible returns nil.
+func CheckReproducible(t Tool) error {
+	r := t.Reproducible
+	if r == nil {
+		return nil
+	}
+	if t.Release != nil {
+		return fmt.Errorf("tool: release tool %s can't be built reproducibly", t.ImportPath)
+	}
+	for _, kv := range t.BuildFlags.EnvList() {
+		if kv == "CGO_ENABLED=1" {
+			return fmt.Errorf("tool: %s needs cgo so it can't be built reproducibly", t.ImportPath)
+		}
+	}
+	m := goVersionRegex.FindStringSubmatch(r.GoVersion)
+	if m == nil {
+		return fmt.Errorf("tool: invalid go version %q to build %s with, must be an exact release, ex: go1.22.4", r.GoVersion, t.ImportPath)
+	}
+	if minor, err := strconv.Atoi(m[1]); err != nil || minor < 21 {
+		return fmt.Errorf("tool: go version %s to build %s with is too old, must be go1.21.0 or newer", r.GoVersion, t.ImportPath)
+	}
+	return checkSums("reproducible build of "+t.ImportPath, r.Sums)
+}
@@ -0,0 +1,47 @@
+package tool_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/getshiphub/shed/tool"
+)
+
+func TestCheckReproducible(t *testing.T) {
+	validSum := "sha256:" + strings.Repeat("ab", 32)
+	tests := []struct {
+		name         string
+		reproducible tool.Reproducible
+		release      *tool.Release
+		wantErr      bool
+	}{
+		{"valid", tool.Reproducible{GoVersion: "go1.22.4", Sums: map[string]string{"linux/amd64": validSum}}, nil, false},
+		{"go1.21", tool.Reproducible{GoVersion: "go1.21.0"}, nil, false},
+		{"too old", tool.Reproducible{GoVersion: "go1.20.14"}, nil, true},
+		{"not exact", tool.Reproducible{GoVersion: "go1.22"}, nil, true},
+		{"no go prefix", tool.Reproducible{GoVersion: "1.22.4"}, nil, true},
+		{"invalid sum", tool.Reproducible{GoVersion: "go1.22.4", Sums: map[string]string{"linux/amd64": "abc"}}, nil, true},
+		{"release", tool.Reproducible{GoVersion: "go1.22.4"}, &tool.Release{URL: "https://example.org"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.reproducible
+			err := tool.CheckReproducible(tool.Tool{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.12.0", Reproducible: &r, Release: tt.release})
+			if tt.wantErr && err == nil {
+				t.Error("want non-nil error, got nil")
+			} else if !tt.wantErr && err != nil {
+				t.Errorf("want nil error, got %v", err)
+			}
+		})
+	}
+
+	cgo := tool.Tool{
+		ImportPath:   "golang.org/x/tools/cmd/stringer",
+		Version:      "v0.12.0",
+		BuildFlags:   tool.BuildFlags{Env: "CGO_ENABLED=1"},
+		Reproducible: &tool.Reproducible{GoVersion: "go1.22.4"},
+	}
+	if err := tool.CheckReproducible(cgo); err == nil {
+		t.Error("want non-nil error for a tool that needs cgo, got nil")
+	}
+}
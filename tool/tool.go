@@ -3,9 +3,13 @@
 package tool
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"path"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
 
 	"golang.org/x/mod/module"
@@ -19,19 +23,245 @@ type Tool struct {
 	// This includes the full path to the tool, not just the module.
 	// Ex: For the stringer tool the import path is
 	// golang/x/tools/cmd/stringer not golang/x/tools.
+	// This is the path of the package that is built.
 	ImportPath string
 	// The version of the tool. This correspeonds to the version of
 	// the Go module the tool belongs to. If version is empty,
 	// it signifies that the latest version is desired where allowed.
 	Version string
+	// ModulePath is the path of the Go module that provides the tool.
+	// Ex: For the stringer tool the module path is golang.org/x/tools.
+	// It is determined when the tool is downloaded, so it is empty
+	// if the tool has not been resolved yet.
+	ModulePath string
+	// Sum is the hash of the source of the module that provides the tool,
+	// in the format used by go.sum, ex: 'h1:...'. Like ModulePath, it is
+	// determined when the tool is downloaded.
+	Sum string
+	// BuildFlags are the flags used to build the tool. Tools with different
+	// build flags are separate tools that are stored in different locations.
+	BuildFlags BuildFlags
+	// Alias is an alternate name for the tool. If set, it is used as the
+	// name of the tool instead of the last component of the import path.
+	// This allows tools with the same name to be used in the same project.
+	Alias string
+	// Release is set if the tool is downloaded as a prebuilt binary instead of being
+	// built from a Go module. ModulePath, Sum, and BuildFlags are not used in that case.
+	Release *Release
+	// Reproducible is set if the tool is built reproducibly with a pinned version of Go,
+	// so that every machine builds the same binary. See Reproducible.
+	Reproducible *Reproducible
+	// Groups is a comma-separated list of the groups the tool belongs to, ex: 'ci,dev'. Groups allow
+	// installing a subset of the tools in the lockfile. They don't affect how the tool is installed,
+	// so tools in different groups are stored in the same location. See InGroup and WithGroups.
+	// It is a string rather than a slice so that tools can be compared with ==.
+	Groups string
+	// Why is a note on why the project uses the tool or who owns it, ex: 'lints the protobuf files, ask #platform'.
+	// Like Groups, it doesn't affect how the tool is installed.
+	Why string
+	// Branch is the branch the tool tracks, ex: 'master', if it was installed from a branch instead of a version.
+	// Version is the pseudo-version the branch resolved to, and updating the tool resolves the branch again.
+	// Like Groups, it doesn't affect how the tool is installed. See IsBranch.
+	Branch string
+	// Platform is the platform the tool is built for, in the form 'GOOS/GOARCH', ex: 'linux/amd64'.
+	// If empty, the tool is built for the platform shed is running on. Tools built for another platform
+	// are stored in a different location, but are otherwise the same tool, so Platform is not recorded
+	// in the lockfile.
+	Platform string
 }
 
-// Name returns the name of the tool. This is the name of the
-// binary produced. It is the last component of the import path.
+// BuildFlags are flags that are passed to 'go build' when building a tool,
+// such as build tags or flags to embed a version string.
+// The zero value means the tool is built without any extra flags.
+type BuildFlags struct {
+	// Tags is a comma-separated list of build tags, as passed to -tags.
+	Tags string
+	// Ldflags are the flags passed to the linker with -ldflags, ex: '-X main.version=v1.0.0'.
+	Ldflags string
+	// Trimpath removes file system paths from the binary, see -trimpath.
+	Trimpath bool
+	// Env are the environment variables the tool needs to be built with, ex: 'CGO_ENABLED=1'.
+	// They are 'KEY=VALUE' pairs separated by newlines and sorted by key, see JoinBuildEnv and EnvList.
+	// It is a string rather than a slice so that build flags can be compared with ==.
+	Env string
+}
+
+// IsZero reports whether no build flags are set.
+func (f BuildFlags) IsZero() bool {
+	return f == BuildFlags{}
+}
+
+// Args returns the build flags as arguments for 'go build'.
+func (f BuildFlags) Args() []string {
+	var args []string
+	if f.Tags != "" {
+		args = append(args, "-tags="+f.Tags)
+	}
+	if f.Ldflags != "" {
+		args = append(args, "-ldflags="+f.Ldflags)
+	}
+	if f.Trimpath {
+		args = append(args, "-trimpath")
+	}
+	return args
+}
+
+// EnvList returns the environment variables in Env, in the form 'KEY=VALUE'.
+func (f BuildFlags) EnvList() []string {
+	if f.Env == "" {
+		return nil
+	}
+	return strings.Split(f.Env, "\n")
+}
+
+// hash returns a short hash of the build flags that identifies them in file paths.
+func (f BuildFlags) hash() string {
+	// The environment is added last so the hash of flags without one doesn't change
+	h := sha256.Sum256([]byte(strings.Join(append(f.Args(), f.EnvList()...), "\x00")))
+	return hex.EncodeToString(h[:6])
+}
+
+// reservedBuildEnv are the environment variables that shed sets itself when building tools,
+// so they can't be part of BuildFlags.Env.
+var reservedBuildEnv = map[string]string{
+	"GOOS":        "use a platform instead",
+	"GOARCH":      "use a platform instead",
+	"GOBIN":       "it is set by shed",
+	"GOPATH":      "it is set by shed",
+	"GOMODCACHE":  "it is set by shed",
+	"GOFLAGS":     "use build flags instead",
+	"GOTOOLCHAIN": "use a reproducible build instead",
+}
+
+// JoinBuildEnv validates the environment variables env, in the form 'KEY=VALUE', and joins them
+// into the format of BuildFlags.Env. Keys must be valid environment variable names and can only be
+// given once. Variables that shed sets itself, like GOOS and GOBIN, are not allowed.
+func JoinBuildEnv(env []string) (string, error) {
+	seen := make(map[string]bool, len(env))
+	sorted := make([]string, 0, len(env))
+	for _, kv := range env {
+		i := strings.IndexByte(kv, '=')
+		if i <= 0 {
+			return "", fmt.Errorf("tool: invalid build environment variable %q, must be in the form KEY=VALUE", kv)
+		}
+		key := kv[:i]
+		if strings.TrimFunc(key, func(r rune) bool {
+			return r == '_' || ('A' <= r && r <= 'Z') || ('a' <= r && r <= 'z') || ('0' <= r && r <= '9')
+		}) != "" || ('0' <= key[0] && key[0] <= '9') {
+			return "", fmt.Errorf("tool: invalid build environment variable name %q", key)
+		}
+		if reason, ok := reservedBuildEnv[key]; ok {
+			return "", fmt.Errorf("tool: build environment variable %s can't be set, %s", key, reason)
+		}
+		if strings.ContainsAny(kv, "\n\x00") {
+			return "", fmt.Errorf("tool: build environment variable %s must not contain a newline or NUL", key)
+		}
+		if seen[key] {
+			return "", fmt.Errorf("tool: build environment variable %s is given more than once", key)
+		}
+		seen[key] = true
+		sorted = append(sorted, kv)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i][:strings.IndexByte(sorted[i], '=')] < sorted[j][:strings.IndexByte(sorted[j], '=')]
+	})
+	return strings.Join(sorted, "\n"), nil
+}
+
+// Name returns the name of the tool. This is Alias if it is set,
+// otherwise it is the name of the binary produced, which is the last
+// component of the import path.
 func (t Tool) Name() string {
+	if t.Alias != "" {
+		return t.Alias
+	}
+	return t.binaryName()
+}
+
+// binaryName returns the name of the binary produced by the go command.
+func (t Tool) binaryName() string {
 	return path.Base(t.ImportPath)
 }
 
+// CheckAlias checks that alias can be used as the name of a tool.
+// It must be a valid file name and can't contain a path separator or an '@',
+// since those are used to differentiate names from import paths and versions.
+func CheckAlias(alias string) error {
+	switch {
+	case alias == "":
+		return fmt.Errorf("tool: alias must not be empty")
+	case alias == "." || alias == "..":
+		return fmt.Errorf("tool: invalid alias %q", alias)
+	case strings.ContainsAny(alias, "/\\@"):
+		return fmt.Errorf("tool: invalid alias %q: must not contain '/', '\\', or '@'", alias)
+	case strings.HasPrefix(alias, "-"):
+		return fmt.Errorf("tool: invalid alias %q: must not start with '-'", alias)
+	}
+	return nil
+}
+
+// CheckGroup checks that group can be used as the name of a group.
+// It must not be empty and can only contain letters, digits, '-', '_', and '.'.
+func CheckGroup(group string) error {
+	if group == "" {
+		return fmt.Errorf("tool: group must not be empty")
+	}
+	for _, r := range group {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return fmt.Errorf("tool: invalid group %q: must only contain letters, digits, '-', '_', or '.'", group)
+		}
+	}
+	return nil
+}
+
+// GroupList returns the groups t belongs to, see Groups.
+func (t Tool) GroupList() []string {
+	if t.Groups == "" {
+		return nil
+	}
+	return strings.Split(t.Groups, ",")
+}
+
+// InGroup reports whether t belongs to group.
+func (t Tool) InGroup(group string) bool {
+	for _, g := range t.GroupList() {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// WithGroups returns a copy of t that also belongs to groups.
+// The groups of the returned tool are sorted and without duplicates.
+func (t Tool) WithGroups(groups ...string) Tool {
+	t.Groups = joinGroups(append(t.GroupList(), groups...), nil)
+	return t
+}
+
+// WithoutGroups returns a copy of t that doesn't belong to any of groups.
+func (t Tool) WithoutGroups(groups ...string) Tool {
+	t.Groups = joinGroups(t.GroupList(), groups)
+	return t
+}
+
+// joinGroups returns the groups that aren't in exclude as a sorted comma-separated list without duplicates.
+func joinGroups(groups, exclude []string) string {
+	set := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		set[g] = true
+	}
+	for _, g := range exclude {
+		delete(set, g)
+	}
+	out := make([]string, 0, len(set))
+	for g := range set {
+		out = append(out, g)
+	}
+	sort.Strings(out)
+	return strings.Join(out, ",")
+}
+
 // Module returns the module name suitable for commands like 'go get'.
 // This is the import path plus the version, if it exists, with the
 // format 'IMPORT_PATH@VERSION'. If Version is empty, Module just
@@ -43,12 +273,58 @@ func (t Tool) Module() string {
 	return t.ImportPath + "@" + t.Version
 }
 
+// PackageDir returns the directory of the tool's package relative to the root
+// of its module, using forward slashes. Ex: For the stringer tool this is cmd/stringer.
+// If the package is at the root of the module, PackageDir returns an empty string.
+// PackageDir requires ModulePath to be set, otherwise it returns an error.
+func (t Tool) PackageDir() (string, error) {
+	if t.ModulePath == "" {
+		return "", fmt.Errorf("tool: module path of %s is unknown", t.ImportPath)
+	}
+	if t.ImportPath == t.ModulePath {
+		return "", nil
+	}
+	if !strings.HasPrefix(t.ImportPath, t.ModulePath+"/") {
+		return "", fmt.Errorf("tool: import path %q is not in module %q", t.ImportPath, t.ModulePath)
+	}
+	return strings.TrimPrefix(t.ImportPath, t.ModulePath+"/"), nil
+}
+
 // HasSemver reports whether t.Version is a valid semantic version.
 // HasSemver requires t.Version to be a full semantic version. It does
 // not allow shorthands like vMAJOR or vMAJOR.MINOR.
 func (t Tool) HasSemver() bool {
+	// Canonical removes the build metadata, but +incompatible is part of the version
+	v := strings.TrimSuffix(t.Version, "+incompatible")
 	// Compare against canonical to make sure it isn't a shorthand.
-	return semver.IsValid(t.Version) && t.Version == semver.Canonical(t.Version)
+	return semver.IsValid(v) && v == semver.Canonical(v)
+}
+
+// IsBranch reports whether the module query version is the name of a branch, ex: 'master', rather than a version,
+// a commit hash, a version range, or one of the queries that have a special meaning to the go command, like 'latest'.
+// Unlike versions and commits, a branch resolves to a different version when it changes.
+func IsBranch(version string) bool {
+	switch version {
+	case "", "latest", "upgrade", "patch", "none":
+		return false
+	}
+	if semver.IsValid(strings.TrimSuffix(version, "+incompatible")) || strings.ContainsAny(version[:1], "<>^~") {
+		return false
+	}
+	return !isCommitHash(version)
+}
+
+// isCommitHash reports whether s looks like a full or abbreviated commit hash.
+func isCommitHash(s string) bool {
+	if len(s) < 7 || len(s) > 64 {
+		return false
+	}
+	for _, r := range s {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'f') {
+			return false
+		}
+	}
+	return true
 }
 
 // String returns a string representation of the tool.
@@ -61,7 +337,9 @@ func (t Tool) String() string {
 }
 
 // Filepath returns the relative OS filesystem path represented by this tool.
-// The escape rules required for import paths are followed.
+// The escape rules required for import paths are followed. If the tool has
+// build flags, a hash of them is added to the end of the path. Likewise, if it is
+// a release tool, a hash of the release templates is added.
 // For details on escaped paths see:
 // https://pkg.go.dev/golang.org/x/mod@v0.4.0/module#hdr-Escaped_Paths
 func (t Tool) Filepath() (string, error) {
@@ -77,18 +355,56 @@ func (t Tool) Filepath() (string, error) {
 		}
 		escapedPath += "@" + escapedVersion
 	}
+	if !t.BuildFlags.IsZero() {
+		// Keep tools built with different flags separate so they don't overwrite each other
+		escapedPath += "+build." + t.BuildFlags.hash()
+	}
+	if t.Release != nil {
+		// Keep release tools separate from modules with the same path and from other templates
+		escapedPath += "+release." + t.Release.hash()
+	}
+	if t.Platform != "" {
+		goos, goarch, err := ParsePlatform(t.Platform)
+		if err != nil {
+			return "", err
+		}
+		// Binaries for other platforms can't be used on this one, so keep them separate
+		escapedPath += "+platform." + goos + "_" + goarch
+	}
 
 	return filepath.FromSlash(escapedPath), nil
 }
 
 // BinaryFilepath returns the relative OS filesystem path to the tool binary.
-// This is the Filepath joined with the Name.
+// This is the Filepath joined with the name of the binary. Alias is not used,
+// so that the binary is the same regardless of what the tool is called.
+// If the tool is built for windows, either because Platform is for windows or because Platform is empty
+// and shed is running on windows, the name of the binary ends with '.exe', since windows can only run
+// binaries with that extension.
 func (t Tool) BinaryFilepath() (string, error) {
 	fp, err := t.Filepath()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(fp, t.Name()), nil
+	name := t.binaryName()
+	if t.goos() == "windows" {
+		name += ".exe"
+	}
+	return filepath.Join(fp, name), nil
+}
+
+// goos returns the GOOS the tool is built for, which is the GOOS of Platform,
+// or the one shed is running on if Platform is empty.
+func (t Tool) goos() string {
+	if t.Platform == "" {
+		return runtime.GOOS
+	}
+	goos, _, err := ParsePlatform(t.Platform)
+	if err != nil {
+		// Filepath fails in this case, so the result doesn't matter
+		return ""
+	}
+	return goos
 }
 
 // Parse parses the given tool name and returns a tool containing the
@@ -97,6 +413,10 @@ func (t Tool) BinaryFilepath() (string, error) {
 // passed to a command like 'go get'. The version must be a valid semantic version
 // and it must be prefixed with 'v' (ex: 'v1.2.3'). If a shorthand semantic version
 // is used, it will be canonicalized (ex: 'v1' will become 'v1.0.0').
+//
+// The domain name in the import path is converted to lowercase, since it is case-insensitive.
+// The rest of the import path is left as is. Uppercase letters are escaped when the tool
+// is stored on the filesystem, see Filepath.
 func Parse(name string) (Tool, error) {
 	return parseTool(name, true)
 }
@@ -116,6 +436,17 @@ func ParseLax(name string) (Tool, error) {
 	return parseTool(name, false)
 }
 
+// normalizeImportPath lowercases the first element of importPath. It is a domain name
+// which is case-insensitive, and the go command requires it to be lowercase.
+// The rest of the import path is case-sensitive and is left as is.
+func normalizeImportPath(importPath string) string {
+	i := strings.IndexByte(importPath, '/')
+	if i == -1 {
+		i = len(importPath)
+	}
+	return strings.ToLower(importPath[:i]) + importPath[i:]
+}
+
 func parseTool(name string, strict bool) (Tool, error) {
 	t := Tool{ImportPath: name}
 
@@ -130,6 +461,8 @@ func parseTool(name string, strict bool) (Tool, error) {
 		}
 	}
 
+	t.ImportPath = normalizeImportPath(t.ImportPath)
+
 	// Validations
 	if err := module.CheckPath(t.ImportPath); err != nil {
 		return t, fmt.Errorf("tool: invalid import path %q: %w", t.ImportPath, err)
@@ -145,6 +478,10 @@ func parseTool(name string, strict bool) (Tool, error) {
 	// The semver package allows vMAJOR and vMAJOR.MINOR as shorthands.
 	// Use the canonical version to ensure it is a full semantic version.
 	canonical := semver.Canonical(t.Version)
+	// Canonical removes the build metadata, but +incompatible is part of the version
+	if strings.HasSuffix(t.Version, "+incompatible") {
+		canonical += "+incompatible"
+	}
 	if t.Version != canonical {
 		t.Version = canonical
 	}
@@ -2,6 +2,8 @@ package tool_test
 
 import (
 	"path/filepath"
+	"reflect"
+	"runtime"
 	"testing"
 
 	"github.com/getshiphub/shed/tool"
@@ -56,6 +58,42 @@ func TestTool(t *testing.T) {
 			wantFilepath:       filepath.FromSlash("github.com/!shopify/ejson/cmd/ejson@v1.2.2"),
 			wantBinaryFilepath: filepath.FromSlash("github.com/!shopify/ejson/cmd/ejson@v1.2.2/ejson"),
 		},
+		{
+			name: "build flags",
+			tool: tool.Tool{
+				ImportPath: "github.com/cszatmary/go-fish",
+				Version:    "v0.1.0",
+				BuildFlags: tool.BuildFlags{Tags: "netgo", Trimpath: true},
+			},
+			wantName:           "go-fish",
+			wantModule:         "github.com/cszatmary/go-fish@v0.1.0",
+			wantFilepath:       filepath.FromSlash("github.com/cszatmary/go-fish@v0.1.0+build.88d259c2d616"),
+			wantBinaryFilepath: filepath.FromSlash("github.com/cszatmary/go-fish@v0.1.0+build.88d259c2d616/go-fish"),
+		},
+		{
+			name:               "platform",
+			tool:               tool.Tool{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0", Platform: "linux/amd64"},
+			wantName:           "go-fish",
+			wantModule:         "github.com/cszatmary/go-fish@v0.1.0",
+			wantFilepath:       filepath.FromSlash("github.com/cszatmary/go-fish@v0.1.0+platform.linux_amd64"),
+			wantBinaryFilepath: filepath.FromSlash("github.com/cszatmary/go-fish@v0.1.0+platform.linux_amd64/go-fish"),
+		},
+		{
+			name:               "windows platform",
+			tool:               tool.Tool{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0", Platform: "windows/arm64"},
+			wantName:           "go-fish",
+			wantModule:         "github.com/cszatmary/go-fish@v0.1.0",
+			wantFilepath:       filepath.FromSlash("github.com/cszatmary/go-fish@v0.1.0+platform.windows_arm64"),
+			wantBinaryFilepath: filepath.FromSlash("github.com/cszatmary/go-fish@v0.1.0+platform.windows_arm64/go-fish.exe"),
+		},
+		{
+			name:               "alias",
+			tool:               tool.Tool{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.1.0", Alias: "xstringer"},
+			wantName:           "xstringer",
+			wantModule:         "golang.org/x/tools/cmd/stringer@v0.1.0",
+			wantFilepath:       filepath.FromSlash("golang.org/x/tools/cmd/stringer@v0.1.0"),
+			wantBinaryFilepath: filepath.FromSlash("golang.org/x/tools/cmd/stringer@v0.1.0/stringer"),
+		},
 	}
 
 	for _, tt := range tests {
@@ -82,13 +120,112 @@ func TestTool(t *testing.T) {
 			if err != nil {
 				t.Errorf("want nil error, got %v", err)
 			}
-			if bfp != tt.wantBinaryFilepath {
-				t.Errorf("got %s, want %s", bfp, tt.wantBinaryFilepath)
+			wantBinaryFilepath := tt.wantBinaryFilepath
+			if runtime.GOOS == "windows" && tt.tool.Platform == "" {
+				// Binaries built for the current platform are windows binaries
+				wantBinaryFilepath += ".exe"
+			}
+			if bfp != wantBinaryFilepath {
+				t.Errorf("got %s, want %s", bfp, wantBinaryFilepath)
 			}
 		})
 	}
 }
 
+func TestBuildFlagsArgs(t *testing.T) {
+	flags := tool.BuildFlags{Tags: "netgo,osusergo", Ldflags: "-s -w -X main.version=v1.0.0", Trimpath: true}
+	want := []string{"-tags=netgo,osusergo", "-ldflags=-s -w -X main.version=v1.0.0", "-trimpath"}
+	if got := flags.Args(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if args := (tool.BuildFlags{}).Args(); len(args) != 0 {
+		t.Errorf("got %q, want no args", args)
+	}
+}
+
+func TestJoinBuildEnv(t *testing.T) {
+	env, err := tool.JoinBuildEnv([]string{"CGO_ENABLED=1", "CC=clang", "CGO_CFLAGS=-O2 -g"})
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := []string{"CC=clang", "CGO_CFLAGS=-O2 -g", "CGO_ENABLED=1"}
+	if got := (tool.BuildFlags{Env: env}).EnvList(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	// The environment changes where the tool is stored
+	withEnv, _ := tool.Tool{ImportPath: "example.org/tool", Version: "v1.0.0", BuildFlags: tool.BuildFlags{Env: env}}.Filepath()
+	withoutEnv, _ := tool.Tool{ImportPath: "example.org/tool", Version: "v1.0.0"}.Filepath()
+	if withEnv == withoutEnv {
+		t.Errorf("got the same path %s with and without a build environment", withEnv)
+	}
+	for _, env := range [][]string{{"CGO_ENABLED"}, {"=1"}, {"1CC=clang"}, {"CC-X=clang"}, {"GOOS=linux"}, {"GOFLAGS=-mod=mod"}, {"CC=clang", "CC=gcc"}, {"CC=clang\nCXX=clang++"}} {
+		if _, err := tool.JoinBuildEnv(env); err == nil {
+			t.Errorf("env %q: want non-nil error, got nil", env)
+		}
+	}
+}
+
+func TestCheckAlias(t *testing.T) {
+	for _, alias := range []string{"stringer2", "golangci-lint.v1", "mock_gen"} {
+		if err := tool.CheckAlias(alias); err != nil {
+			t.Errorf("alias %q: want nil error, got %v", alias, err)
+		}
+	}
+	for _, alias := range []string{"", ".", "..", "cmd/stringer", `cmd\stringer`, "stringer@v1", "-stringer"} {
+		if err := tool.CheckAlias(alias); err == nil {
+			t.Errorf("alias %q: want non-nil error, got nil", alias)
+		}
+	}
+}
+
+func TestCheckGroup(t *testing.T) {
+	for _, group := range []string{"ci", "dev-tools", "release_v2", "Lint.strict"} {
+		if err := tool.CheckGroup(group); err != nil {
+			t.Errorf("group %q: want nil error, got %v", group, err)
+		}
+	}
+	for _, group := range []string{"", "ci,dev", "ci dev", "ci/dev", "ci@v1"} {
+		if err := tool.CheckGroup(group); err == nil {
+			t.Errorf("group %q: want non-nil error, got nil", group)
+		}
+	}
+}
+
+func TestIsBranch(t *testing.T) {
+	for _, v := range []string{"master", "main", "release-1.x", "feature/fish", "v2-dev"} {
+		if !tool.IsBranch(v) {
+			t.Errorf("version %q: want branch, got not a branch", v)
+		}
+	}
+	for _, v := range []string{"", "latest", "upgrade", "none", "v1", "v1.2", "v0.1.0", "v2.1.0+incompatible", "v0.0.0-20201211185031-d93e913c1a58", "22d10c9", "22d10c9b658df297b17b33c836a60fb943ef5a5f", "^1.50", "~1.33.2", "<v1.5.0", ">=v1.2.0"} {
+		if tool.IsBranch(v) {
+			t.Errorf("version %q: want not a branch, got branch", v)
+		}
+	}
+}
+
+func TestToolGroups(t *testing.T) {
+	tl := tool.Tool{ImportPath: "golang.org/x/tools/cmd/stringer"}
+	if got := tl.GroupList(); got != nil {
+		t.Errorf("got groups %v, want none", got)
+	}
+	tl = tl.WithGroups("dev", "ci", "dev")
+	if tl.Groups != "ci,dev" {
+		t.Errorf("got groups %q, want %q", tl.Groups, "ci,dev")
+	}
+	if !tl.InGroup("ci") || tl.InGroup("c") || tl.InGroup("release") {
+		t.Errorf("InGroup is wrong for groups %q", tl.Groups)
+	}
+	tl = tl.WithGroups("release").WithoutGroups("dev", "lint")
+	if tl.Groups != "ci,release" {
+		t.Errorf("got groups %q, want %q", tl.Groups, "ci,release")
+	}
+	tl = tl.WithoutGroups("ci", "release")
+	if tl.Groups != "" {
+		t.Errorf("got groups %q, want none", tl.Groups)
+	}
+}
+
 func TestToolHasSemver(t *testing.T) {
 	tests := []struct {
 		name string
@@ -115,6 +252,11 @@ func TestToolHasSemver(t *testing.T) {
 			tool: tool.Tool{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.0.0-20201211185031-d93e913c1a58"},
 			want: true,
 		},
+		{
+			name: "incompatible version",
+			tool: tool.Tool{ImportPath: "github.com/example/tool", Version: "v2.1.0+incompatible"},
+			want: true,
+		},
 		{
 			name: "shorthand major",
 			tool: tool.Tool{ImportPath: "github.com/golangci/golangci-lint/cmd/golangci-lint", Version: "v1"},
@@ -150,6 +292,10 @@ func TestToolFilepathError(t *testing.T) {
 			name: "invalid version",
 			tool: tool.Tool{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.!.0-20201211185031-d93e913c1a58"},
 		},
+		{
+			name: "invalid platform",
+			tool: tool.Tool{ImportPath: "golang.org/x/tools/cmd/stringer", Version: "v0.1.0", Platform: "linux/../amd64"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -176,6 +322,61 @@ func TestToolString(t *testing.T) {
 	}
 }
 
+func TestToolPackageDir(t *testing.T) {
+	tests := []struct {
+		name string
+		tool tool.Tool
+		want string
+	}{
+		{
+			name: "nested package",
+			tool: tool.Tool{ImportPath: "golang.org/x/tools/cmd/stringer", ModulePath: "golang.org/x/tools"},
+			want: "cmd/stringer",
+		},
+		{
+			name: "root package",
+			tool: tool.Tool{ImportPath: "github.com/cszatmary/go-fish", ModulePath: "github.com/cszatmary/go-fish"},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.tool.PackageDir()
+			if err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToolPackageDirError(t *testing.T) {
+	tests := []struct {
+		name string
+		tool tool.Tool
+	}{
+		{
+			name: "unknown module",
+			tool: tool.Tool{ImportPath: "golang.org/x/tools/cmd/stringer"},
+		},
+		{
+			name: "different module",
+			tool: tool.Tool{ImportPath: "golang.org/x/tools/cmd/stringer", ModulePath: "golang.org/x/tool"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.tool.PackageDir(); err == nil {
+				t.Error("want non-nil error, got nil")
+			}
+		})
+	}
+}
+
 func TestParse(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -187,6 +388,11 @@ func TestParse(t *testing.T) {
 			module: "github.com/cszatmary/go-fish@v0.1.0",
 			want:   tool.Tool{ImportPath: "github.com/cszatmary/go-fish", Version: "v0.1.0"},
 		},
+		{
+			name:   "incompatible version",
+			module: "github.com/example/tool@v2.1.0+incompatible",
+			want:   tool.Tool{ImportPath: "github.com/example/tool", Version: "v2.1.0+incompatible"},
+		},
 		{
 			name:   "nested import path",
 			module: "github.com/golangci/golangci-lint/cmd/golangci-lint@v1.33.0",
@@ -202,6 +408,11 @@ func TestParse(t *testing.T) {
 			module: "github.com/Shopify/ejson/cmd/ejson@v1.2.2",
 			want:   tool.Tool{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.2"},
 		},
+		{
+			name:   "uppercase domain",
+			module: "GitHub.com/Shopify/ejson/cmd/ejson@v1.2.2",
+			want:   tool.Tool{ImportPath: "github.com/Shopify/ejson/cmd/ejson", Version: "v1.2.2"},
+		},
 		{
 			name:   "shorthand major",
 			module: "github.com/golangci/golangci-lint/cmd/golangci-lint@v1",
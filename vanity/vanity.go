@@ -0,0 +1,310 @@
+// Package vanity diagnoses problems with resolving vanity import paths.
+//
+// When the go command fetches a module directly from its source, it first determines
+// where the source is located by fetching 'https://IMPORT_PATH?go-get=1' and looking
+// for a go-import meta tag. The errors produced by the go command when this fails are
+// often cryptic, so this package performs the same lookup and reports precisely what
+// went wrong. See https://golang.org/cmd/go/#hdr-Remote_import_paths for details.
+package vanity
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxBodySize is the maximum number of bytes read from a response.
+// The meta tags must be in the head, so anything past this is ignored.
+const maxBodySize = 1 << 20
+
+// Kind identifies the type of problem found with a vanity import path.
+type Kind int
+
+const (
+	// KindNetwork means the request could not be made, ex: because of a DNS error.
+	KindNetwork Kind = iota + 1
+	// KindTLS means the TLS connection failed, ex: because of a self-signed certificate.
+	KindTLS
+	// KindAuth means the server requires authentication.
+	KindAuth
+	// KindRedirect means the request was redirected to a page without a go-import meta tag.
+	KindRedirect
+	// KindHTTPStatus means the server responded with an unsuccessful status.
+	KindHTTPStatus
+	// KindNoMetaTag means the page did not contain any go-import meta tags.
+	KindNoMetaTag
+	// KindBadMetaTag means a go-import meta tag was malformed.
+	KindBadMetaTag
+	// KindPrefixMismatch means no go-import meta tag matched the import path.
+	KindPrefixMismatch
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindNetwork:
+		return "network error"
+	case KindTLS:
+		return "TLS error"
+	case KindAuth:
+		return "authentication required"
+	case KindRedirect:
+		return "bad redirect"
+	case KindHTTPStatus:
+		return "bad HTTP status"
+	case KindNoMetaTag:
+		return "no go-import meta tag"
+	case KindBadMetaTag:
+		return "bad go-import meta tag"
+	case KindPrefixMismatch:
+		return "go-import prefix mismatch"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Error describes a problem with resolving a vanity import path.
+type Error struct {
+	// ImportPath is the import path that was checked.
+	ImportPath string
+	// URL is the URL that was fetched. If the request was redirected,
+	// it is the URL that was redirected to.
+	URL string
+	// Kind is the type of problem.
+	Kind Kind
+	// Detail is a human readable description of the problem.
+	Detail string
+	// Err is the underlying error, if any.
+	Err error
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("vanity: %s: %s: %s", e.ImportPath, e.Kind, e.Detail)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// knownHosts are code hosts that the go command knows how to fetch from without
+// looking up a meta tag.
+var knownHosts = []string{
+	"github.com",
+	"bitbucket.org",
+	"hub.jazz.net",
+	"git.apache.org",
+	"git.openstack.org",
+	"chiselapp.com",
+}
+
+// vcsSuffixes are suffixes that can be used to explicitly declare the version
+// control system in an import path without needing a meta tag.
+var vcsSuffixes = []string{".git", ".hg", ".svn", ".bzr", ".fossil"}
+
+// vcsNames are the values allowed as the VCS in a go-import meta tag.
+var vcsNames = map[string]bool{
+	"git":    true,
+	"hg":     true,
+	"svn":    true,
+	"bzr":    true,
+	"fossil": true,
+	"mod":    true,
+}
+
+// NeedsMetaTag reports whether importPath requires looking up a go-import
+// meta tag to be resolved. This is false for import paths on well known
+// code hosts or ones that specify the VCS explicitly.
+func NeedsMetaTag(importPath string) bool {
+	host := importPath
+	if i := strings.IndexByte(host, '/'); i != -1 {
+		host = host[:i]
+	}
+	for _, h := range knownHosts {
+		if host == h {
+			return false
+		}
+	}
+	for _, elem := range strings.Split(importPath, "/") {
+		for _, suffix := range vcsSuffixes {
+			if strings.HasSuffix(elem, suffix) && elem != suffix {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Checker checks vanity import paths.
+type Checker struct {
+	// Client is the HTTP client used to make requests.
+	// If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+// Check looks up the go-import meta tag for importPath like the go command would.
+// If a problem is found, an *Error describing it is returned. If importPath does not
+// need a meta tag to be resolved, Check returns nil without making any requests.
+//
+// The provided context is used to abort the request.
+func (c *Checker) Check(ctx context.Context, importPath string) error {
+	if !NeedsMetaTag(importPath) {
+		return nil
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	reqURL := "https://" + importPath + "?go-get=1"
+	newErr := func(u string, kind Kind, detail string, err error) error {
+		return &Error{ImportPath: importPath, URL: u, Kind: kind, Detail: detail, Err: err}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	if err != nil {
+		return newErr(reqURL, KindNetwork, "failed to create request", err)
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		if isTLSError(err) {
+			detail := "failed to verify the server's certificate, if the host uses a self-signed certificate add it to GOINSECURE"
+			return newErr(reqURL, KindTLS, detail, err)
+		}
+		return newErr(reqURL, KindNetwork, "failed to fetch", err)
+	}
+	defer resp.Body.Close()
+
+	finalURL := resp.Request.URL
+	imports, err := parseMetaGoImports(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return newErr(finalURL.String(), KindBadMetaTag, err.Error(), nil)
+	}
+
+	var matches []metaImport
+	for _, mi := range imports {
+		if importPath == mi.prefix || strings.HasPrefix(importPath, mi.prefix+"/") {
+			matches = append(matches, mi)
+		}
+	}
+	if len(matches) == 0 {
+		// Figure out why there is no meta tag, the go command parses the body
+		// regardless of the status, so only check it now
+		redirected := finalURL.Host != req.URL.Host
+		switch {
+		case len(imports) > 0:
+			prefixes := make([]string, len(imports))
+			for i, mi := range imports {
+				prefixes[i] = mi.prefix
+			}
+			detail := fmt.Sprintf("found go-import meta tags for %s, but none are a prefix of the import path", strings.Join(prefixes, ", "))
+			return newErr(finalURL.String(), KindPrefixMismatch, detail, nil)
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			detail := fmt.Sprintf("server responded with %s, configure credentials for the host in .netrc and add it to GOPRIVATE", resp.Status)
+			return newErr(finalURL.String(), KindAuth, detail, nil)
+		case redirected:
+			detail := fmt.Sprintf("redirected to %s which has no go-import meta tag, this is usually a login page which means authentication is required", finalURL.Host)
+			return newErr(finalURL.String(), KindRedirect, detail, nil)
+		case resp.StatusCode != http.StatusOK:
+			return newErr(finalURL.String(), KindHTTPStatus, fmt.Sprintf("server responded with %s", resp.Status), nil)
+		}
+		return newErr(finalURL.String(), KindNoMetaTag, "page does not contain a go-import meta tag", nil)
+	}
+	if len(matches) > 1 {
+		detail := fmt.Sprintf("found %d go-import meta tags matching the import path, there must only be one", len(matches))
+		return newErr(finalURL.String(), KindBadMetaTag, detail, nil)
+	}
+
+	mi := matches[0]
+	if !vcsNames[mi.vcs] {
+		return newErr(finalURL.String(), KindBadMetaTag, fmt.Sprintf("unknown VCS %q", mi.vcs), nil)
+	}
+	if u, err := url.Parse(mi.repoRoot); err != nil || u.Scheme == "" || u.Host == "" {
+		return newErr(finalURL.String(), KindBadMetaTag, fmt.Sprintf("repo root %q is not a valid URL", mi.repoRoot), nil)
+	}
+	return nil
+}
+
+// Check checks importPath using a Checker with the default HTTP client.
+// See Checker.Check for details.
+func Check(ctx context.Context, importPath string) error {
+	var c Checker
+	return c.Check(ctx, importPath)
+}
+
+func isTLSError(err error) bool {
+	var (
+		unknownAuthErr x509.UnknownAuthorityError
+		hostnameErr    x509.HostnameError
+		certInvalidErr x509.CertificateInvalidError
+		recordErr      tls.RecordHeaderError
+	)
+	return errors.As(err, &unknownAuthErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &certInvalidErr) ||
+		errors.As(err, &recordErr)
+}
+
+// metaImport is a parsed go-import meta tag.
+type metaImport struct {
+	prefix   string
+	vcs      string
+	repoRoot string
+}
+
+// parseMetaGoImports returns the go-import meta tags in the HTML page read from r.
+// This is adapted from how the go command parses them, which only looks at
+// the head of the document and is lenient with malformed HTML.
+func parseMetaGoImports(r io.Reader) ([]metaImport, error) {
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+	var imports []metaImport
+	for {
+		tok, err := d.RawToken()
+		if err != nil {
+			// Errors after finding the tags are ignored, since the rest of the page doesn't matter
+			if err == io.EOF || len(imports) > 0 {
+				return imports, nil
+			}
+			return nil, fmt.Errorf("failed to parse page: %w", err)
+		}
+		if e, ok := tok.(xml.StartElement); ok && strings.EqualFold(e.Name.Local, "body") {
+			return imports, nil
+		}
+		if e, ok := tok.(xml.EndElement); ok && strings.EqualFold(e.Name.Local, "head") {
+			return imports, nil
+		}
+		e, ok := tok.(xml.StartElement)
+		if !ok || !strings.EqualFold(e.Name.Local, "meta") {
+			continue
+		}
+		if attrValue(e.Attr, "name") != "go-import" {
+			continue
+		}
+		content := attrValue(e.Attr, "content")
+		fields := strings.Fields(content)
+		// The optional fourth field is a subdirectory, which is only supported by newer versions of go
+		if len(fields) != 3 && len(fields) != 4 {
+			return nil, fmt.Errorf("go-import meta tag content %q must contain the fields 'import-prefix vcs repo-root'", content)
+		}
+		imports = append(imports, metaImport{prefix: fields[0], vcs: fields[1], repoRoot: fields[2]})
+	}
+}
+
+func attrValue(attrs []xml.Attr, name string) string {
+	for _, a := range attrs {
+		if strings.EqualFold(a.Name.Local, name) {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,164 @@
+package vanity_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/getshiphub/shed/vanity"
+)
+
+func TestNeedsMetaTag(t *testing.T) {
+	tests := []struct {
+		importPath string
+		want       bool
+	}{
+		{"github.com/cszatmary/go-fish", false},
+		{"bitbucket.org/acme/tool", false},
+		{"git.example.com/acme/tool.git/cmd/tool", false},
+		{"golang.org/x/tools/cmd/stringer", true},
+		{"go.acme.dev/tool", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.importPath, func(t *testing.T) {
+			if got := vanity.NeedsMetaTag(tt.importPath); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	const page = `<!DOCTYPE html>
+<html>
+<head>
+<meta name="go-import" content="%s">
+</head>
+<body>Nothing to see here</body>
+</html>`
+
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		wantKind vanity.Kind
+	}{
+		{
+			name: "valid",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, page, r.Host+"/tool git https://git.example.com/tool")
+			},
+		},
+		{
+			name: "valid with not found status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprintf(w, page, r.Host+"/tool git https://git.example.com/tool")
+			},
+		},
+		{
+			name: "no meta tag",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "<html><head></head><body></body></html>")
+			},
+			wantKind: vanity.KindNoMetaTag,
+		},
+		{
+			name: "malformed meta tag",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, page, r.Host+"/tool git")
+			},
+			wantKind: vanity.KindBadMetaTag,
+		},
+		{
+			name: "unknown vcs",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, page, r.Host+"/tool cvs https://git.example.com/tool")
+			},
+			wantKind: vanity.KindBadMetaTag,
+		},
+		{
+			name: "prefix mismatch",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, page, r.Host+"/other git https://git.example.com/other")
+			},
+			wantKind: vanity.KindPrefixMismatch,
+		},
+		{
+			name: "auth required",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+			},
+			wantKind: vanity.KindAuth,
+		},
+		{
+			name: "server error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			wantKind: vanity.KindHTTPStatus,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewTLSServer(tt.handler)
+			defer srv.Close()
+			c := vanity.Checker{Client: srv.Client()}
+			importPath := strings.TrimPrefix(srv.URL, "https://") + "/tool"
+			err := c.Check(context.Background(), importPath)
+			if tt.wantKind == 0 {
+				if err != nil {
+					t.Errorf("want nil error, got %v", err)
+				}
+				return
+			}
+			var vErr *vanity.Error
+			if !errors.As(err, &vErr) {
+				t.Fatalf("want *vanity.Error, got %T: %v", err, err)
+			}
+			if vErr.Kind != tt.wantKind {
+				t.Errorf("got kind %s, want %s: %v", vErr.Kind, tt.wantKind, err)
+			}
+		})
+	}
+}
+
+func TestCheckTLSError(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	// Use a client that doesn't trust the server's self-signed certificate
+	c := vanity.Checker{Client: &http.Client{}}
+	err := c.Check(context.Background(), strings.TrimPrefix(srv.URL, "https://")+"/tool")
+	var vErr *vanity.Error
+	if !errors.As(err, &vErr) {
+		t.Fatalf("want *vanity.Error, got %T: %v", err, err)
+	}
+	if vErr.Kind != vanity.KindTLS {
+		t.Errorf("got kind %s, want %s: %v", vErr.Kind, vanity.KindTLS, err)
+	}
+}
+
+func TestCheckRedirect(t *testing.T) {
+	login := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><head><title>Sign in</title></head></html>")
+	}))
+	defer login.Close()
+	srv := httptest.NewTLSServer(http.RedirectHandler(login.URL, http.StatusFound))
+	defer srv.Close()
+
+	// Both servers use the same certificate, so the client trusts both
+	c := vanity.Checker{Client: srv.Client()}
+	err := c.Check(context.Background(), strings.TrimPrefix(srv.URL, "https://")+"/tool")
+	var vErr *vanity.Error
+	if !errors.As(err, &vErr) {
+		t.Fatalf("want *vanity.Error, got %T: %v", err, err)
+	}
+	// Both servers are on the same host, so only the port differs
+	if vErr.Kind != vanity.KindRedirect {
+		t.Errorf("got kind %s, want %s: %v", vErr.Kind, vanity.KindRedirect, err)
+	}
+}
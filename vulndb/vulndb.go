@@ -0,0 +1,363 @@
+// Package vulndb queries the Go vulnerability database to find the vulnerabilities
+// that affect versions of a module. It uses the same database as govulncheck.
+// See https://go.dev/security/vuln/database for details on the protocol.
+package vulndb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/semver"
+)
+
+// ErrNotFound is returned when an entry does not exist in the database.
+var ErrNotFound = errors.New("vulndb: not found")
+
+// DefaultURL is the database used if GOVULNDB is not set.
+const DefaultURL = "https://vuln.go.dev"
+
+// maxResponseSize is the maximum number of bytes read from a database response.
+// The index of modules is the largest file and is a few megabytes.
+const maxResponseSize = 64 << 20
+
+// Severity is how severe a vulnerability is.
+type Severity int
+
+const (
+	// SeverityUnknown means the database doesn't say how severe the vulnerability is.
+	// The Go vulnerability database doesn't record severities, so all its entries have it.
+	SeverityUnknown Severity = iota
+	SeverityLow
+	SeverityModerate
+	SeverityHigh
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityUnknown:
+		return "unknown"
+	case SeverityLow:
+		return "low"
+	case SeverityModerate:
+		return "moderate"
+	case SeverityHigh:
+		return "high"
+	case SeverityCritical:
+		return "critical"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// ParseSeverity parses the name of a severity, ex: 'high'. It is case-insensitive
+// and 'medium' is accepted for SeverityModerate.
+func ParseSeverity(name string) (Severity, error) {
+	switch strings.ToLower(name) {
+	case "unknown":
+		return SeverityUnknown, nil
+	case "low":
+		return SeverityLow, nil
+	case "moderate", "medium":
+		return SeverityModerate, nil
+	case "high":
+		return SeverityHigh, nil
+	case "critical":
+		return SeverityCritical, nil
+	}
+	return SeverityUnknown, fmt.Errorf("vulndb: invalid severity %q, must be one of low, moderate, high, or critical", name)
+}
+
+// Entry is a vulnerability in the database.
+type Entry struct {
+	// ID is the ID of the vulnerability in the database, ex: GO-2022-0001.
+	ID string
+	// Summary is a one line description of the vulnerability.
+	Summary string
+	// Aliases are IDs of the vulnerability in other databases, ex: CVE IDs.
+	Aliases []string
+	// Severity is read from the severity in the database specific data of the entry,
+	// which databases derived from GitHub advisories have.
+	Severity Severity
+}
+
+// Client queries a vulnerability database.
+type Client struct {
+	url    string
+	client *http.Client
+	// The index of modules is large, so it is only fetched once
+	indexMu sync.Mutex
+	index   []moduleIndex
+}
+
+// New returns a Client that uses the database at url. If client is nil, http.DefaultClient is used.
+func New(client *http.Client, url string) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Client{url: strings.TrimSuffix(url, "/"), client: client}
+}
+
+// FromEnv returns a Client that uses the database in the GOVULNDB environment variable,
+// like govulncheck. Only the first database is used, and it must be an HTTP URL.
+// If GOVULNDB is not set, DefaultURL is used.
+func FromEnv() *Client {
+	url := DefaultURL
+	if list := os.Getenv("GOVULNDB"); list != "" {
+		u := strings.Split(list, ",")[0]
+		if strings.HasPrefix(u, "https://") || strings.HasPrefix(u, "http://") {
+			url = u
+		}
+	}
+	return New(nil, url)
+}
+
+func (c *Client) get(ctx context.Context, p string, v interface{}) error {
+	url := c.url + p
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("vulndb: failed to create request for %s: %w", url, err)
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vulndb: failed to fetch %s: %w", url, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrNotFound, p)
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("vulndb: failed to fetch %s: %s", url, res.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+	if err != nil {
+		return fmt.Errorf("vulndb: failed to read response from %s: %w", url, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("vulndb: invalid response from %s: %w", url, err)
+	}
+	return nil
+}
+
+// moduleIndex is an entry in index/modules.json.
+type moduleIndex struct {
+	Path  string `json:"path"`
+	Vulns []struct {
+		ID string `json:"id"`
+	} `json:"vulns"`
+}
+
+// osvEntry is the subset of an OSV entry used to find affected versions.
+// See https://ossf.github.io/osv-schema for details.
+type osvEntry struct {
+	ID               string   `json:"id"`
+	Summary          string   `json:"summary"`
+	Details          string   `json:"details"`
+	Aliases          []string `json:"aliases"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+	Affected []struct {
+		Package struct {
+			Name string `json:"name"`
+		} `json:"package"`
+		Ranges []osvRange `json:"ranges"`
+	} `json:"affected"`
+}
+
+type osvRange struct {
+	Type   string `json:"type"`
+	Events []struct {
+		Introduced string `json:"introduced"`
+		Fixed      string `json:"fixed"`
+	} `json:"events"`
+}
+
+// Module is the vulnerabilities of a module.
+type Module struct {
+	path    string
+	entries []*osvEntry
+}
+
+// Module returns the vulnerabilities of the module at modPath.
+// The index of modules in the database is only fetched the first time.
+func (c *Client) Module(ctx context.Context, modPath string) (*Module, error) {
+	c.indexMu.Lock()
+	if c.index == nil {
+		var index []moduleIndex
+		if err := c.get(ctx, "/index/modules.json", &index); err != nil {
+			c.indexMu.Unlock()
+			return nil, err
+		}
+		c.index = index
+	}
+	index := c.index
+	c.indexMu.Unlock()
+
+	m := &Module{path: modPath}
+	for _, mi := range index {
+		if mi.Path != modPath {
+			continue
+		}
+		for _, v := range mi.Vulns {
+			e := &osvEntry{}
+			if err := c.get(ctx, "/ID/"+v.ID+".json", e); err != nil {
+				return nil, err
+			}
+			m.entries = append(m.entries, e)
+		}
+	}
+	return m, nil
+}
+
+// Affecting returns the vulnerabilities that affect version of the module, sorted by ID.
+func (m *Module) Affecting(version string) []Entry {
+	var affecting []Entry
+	for _, e := range m.entries {
+		if e.affects(m.path, version) {
+			affecting = append(affecting, e.entry())
+		}
+	}
+	sort.Slice(affecting, func(i, j int) bool {
+		return affecting[i].ID < affecting[j].ID
+	})
+	return affecting
+}
+
+// Fix returns the version that fixes the vulnerability with the given ID for version of the module, ex: 'v1.2.0'.
+// It is empty if the vulnerability doesn't affect version or hasn't been fixed since.
+func (m *Module) Fix(id, version string) string {
+	for _, e := range m.entries {
+		if e.ID != id {
+			continue
+		}
+		for _, a := range e.Affected {
+			if a.Package.Name != m.path {
+				continue
+			}
+			for _, r := range a.Ranges {
+				if r.Type != "SEMVER" {
+					continue
+				}
+				if affected, fixed := r.find(version); affected {
+					return fixed
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// Fixed returns the vulnerabilities of the module at modPath that affect version from but not version to,
+// which are the vulnerabilities fixed by updating from one to the other. They are sorted by ID.
+func (c *Client) Fixed(ctx context.Context, modPath, from, to string) ([]Entry, error) {
+	m, err := c.Module(ctx, modPath)
+	if err != nil {
+		return nil, err
+	}
+	var fixed []Entry
+	for _, e := range m.Affecting(from) {
+		if !containsID(m.Affecting(to), e.ID) {
+			fixed = append(fixed, e)
+		}
+	}
+	return fixed, nil
+}
+
+func containsID(entries []Entry, id string) bool {
+	for _, e := range entries {
+		if e.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// entry returns the Entry for e.
+func (e *osvEntry) entry() Entry {
+	summary := e.Summary
+	if summary == "" {
+		// Older entries only have details
+		summary = strings.SplitN(strings.TrimSpace(e.Details), "\n", 2)[0]
+	}
+	// Unknown severities are ignored, since some databases use their own
+	severity, _ := ParseSeverity(e.DatabaseSpecific.Severity)
+	return Entry{ID: e.ID, Summary: summary, Aliases: e.Aliases, Severity: severity}
+}
+
+// affects reports whether the vulnerability affects version of the module at modPath.
+func (e *osvEntry) affects(modPath, version string) bool {
+	for _, a := range e.Affected {
+		if a.Package.Name != modPath {
+			continue
+		}
+		for _, r := range a.Ranges {
+			if r.Type == "SEMVER" && r.contains(version) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// contains reports whether version is in the range.
+func (r osvRange) contains(version string) bool {
+	affected, _ := r.find(version)
+	return affected
+}
+
+// find reports whether version is in the range, and if so, the version that fixes it, which is empty
+// if there is none. Versions in OSV don't have the 'v' prefix, and the introduced version '0' means
+// the start of the module.
+func (r osvRange) find(version string) (affected bool, fixed string) {
+	type event struct {
+		version    string
+		introduced bool
+	}
+	var events []event
+	for _, e := range r.Events {
+		if e.Introduced != "" {
+			v := "v" + e.Introduced
+			if e.Introduced == "0" {
+				v = ""
+			}
+			events = append(events, event{version: v, introduced: true})
+		} else if e.Fixed != "" {
+			events = append(events, event{version: "v" + e.Fixed})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return compare(events[i].version, events[j].version) < 0
+	})
+	for _, e := range events {
+		if compare(e.version, version) > 0 {
+			if affected && !e.introduced {
+				fixed = e.version
+			}
+			break
+		}
+		affected = e.introduced
+	}
+	return affected, fixed
+}
+
+// compare is like semver.Compare, but the empty string is lower than every version.
+func compare(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return -1
+	case b == "":
+		return 1
+	}
+	return semver.Compare(a, b)
+}
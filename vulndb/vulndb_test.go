@@ -0,0 +1,126 @@
+package vulndb_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/getshiphub/shed/vulndb"
+)
+
+var dbFiles = map[string]string{
+	"/index/modules.json": `[
+		{"path": "example.org/a", "vulns": [{"id": "GO-2022-0001"}, {"id": "GO-2022-0002"}, {"id": "GO-2022-0003"}]},
+		{"path": "example.org/b", "vulns": [{"id": "GO-2022-0004"}]}
+	]`,
+	"/ID/GO-2022-0001.json": `{
+		"id": "GO-2022-0001",
+		"summary": "Panic on malformed input in example.org/a",
+		"aliases": ["CVE-2022-1234"],
+		"affected": [{"package": {"name": "example.org/a"}, "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.2.0"}]}]}]
+	}`,
+	"/ID/GO-2022-0002.json": `{
+		"id": "GO-2022-0002",
+		"details": "Path traversal in example.org/a.\nMore details.",
+		"affected": [{"package": {"name": "example.org/a"}, "ranges": [{"type": "SEMVER", "events": [{"introduced": "1.1.0"}, {"fixed": "1.1.5"}, {"introduced": "1.3.0"}]}]}]
+	}`,
+	"/ID/GO-2022-0003.json": `{
+		"id": "GO-2022-0003",
+		"summary": "Only affects old versions",
+		"affected": [{"package": {"name": "example.org/a"}, "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.0.0"}]}]}]
+	}`,
+}
+
+func newDB(t *testing.T) *vulndb.Client {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := dbFiles[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(data)) //nolint:errcheck
+	}))
+	t.Cleanup(srv.Close)
+	return vulndb.New(srv.Client(), srv.URL+"/")
+}
+
+func TestFixed(t *testing.T) {
+	db := newDB(t)
+	tests := []struct {
+		name     string
+		modPath  string
+		from, to string
+		want     []string
+	}{
+		{"fixes both", "example.org/a", "v1.1.2", "v1.2.0", []string{"GO-2022-0001", "GO-2022-0002"}},
+		{"fixed in range", "example.org/a", "v1.1.0", "v1.1.5", []string{"GO-2022-0002"}},
+		{"reintroduced", "example.org/a", "v1.1.0", "v1.3.0", []string{"GO-2022-0001"}},
+		{"nothing to fix", "example.org/a", "v1.2.0", "v1.2.1", nil},
+		{"pseudo-version", "example.org/a", "v1.1.1-0.20220101000000-abcdefabcdef", "v1.2.0", []string{"GO-2022-0001", "GO-2022-0002"}},
+		{"unknown module", "example.org/c", "v1.0.0", "v1.1.0", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := db.Fixed(context.Background(), tt.modPath, tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	entries, err := db.Fixed(context.Background(), "example.org/a", "v1.1.2", "v1.2.0")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	want := []vulndb.Entry{
+		{ID: "GO-2022-0001", Summary: "Panic on malformed input in example.org/a", Aliases: []string{"CVE-2022-1234"}},
+		{ID: "GO-2022-0002", Summary: "Path traversal in example.org/a."},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got entries %+v, want %+v", entries, want)
+	}
+}
+
+func TestFixedError(t *testing.T) {
+	// Entries in the index must exist
+	_, err := newDB(t).Fixed(context.Background(), "example.org/b", "v1.0.0", "v1.1.0")
+	if !errors.Is(err, vulndb.ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, vulndb.ErrNotFound)
+	}
+}
+
+func TestFix(t *testing.T) {
+	m, err := newDB(t).Module(context.Background(), "example.org/a")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	tests := []struct {
+		name    string
+		id      string
+		version string
+		want    string
+	}{
+		{"fixed", "GO-2022-0001", "v1.1.2", "v1.2.0"},
+		{"fixed in range", "GO-2022-0002", "v1.1.0", "v1.1.5"},
+		{"reintroduced", "GO-2022-0002", "v1.3.0", ""},
+		{"not affected", "GO-2022-0003", "v1.1.0", ""},
+		{"unknown ID", "GO-2022-0004", "v1.1.0", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.Fix(tt.id, tt.version); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+// Package xdg provides the default directories used by shed on each platform.
+//
+// On all platforms the XDG Base Directory environment variables (XDG_CACHE_HOME,
+// XDG_CONFIG_HOME, XDG_STATE_HOME, and XDG_BIN_HOME) take precedence if they are set
+// to an absolute path. Otherwise the conventional directories for the platform are used:
+//
+//   - Cache: ~/.cache/shed on Linux, ~/Library/Caches/shed on macOS, and %LocalAppData%\shed\cache on Windows.
+//   - Config: ~/.config/shed on Linux, ~/Library/Application Support/shed on macOS, and %AppData%\shed on Windows.
+//   - State: ~/.local/state/shed on Linux, ~/Library/Application Support/shed/state on macOS,
+//     and %LocalAppData%\shed\state on Windows.
+//   - Bin: ~/.local/bin on Linux and macOS, and %LocalAppData%\shed\bin on Windows.
+package xdg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// appName is the name of the directory used for shed within the base directories.
+const appName = "shed"
+
+// goos is the operating system used to determine defaults. It is a variable so it can be changed in tests.
+var goos = runtime.GOOS
+
+// fromEnv returns the value of the environment variable key if it is an absolute path.
+// The XDG spec says relative paths are invalid and should be ignored.
+func fromEnv(key string) (string, bool) {
+	v := os.Getenv(key)
+	if v == "" || !filepath.IsAbs(v) {
+		return "", false
+	}
+	return v, true
+}
+
+func homeDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("xdg: failed to find home directory: %w", err)
+	}
+	return home, nil
+}
+
+// windowsDir returns the value of the given windows environment variable, such as LocalAppData.
+func windowsDir(key string) (string, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return "", fmt.Errorf("xdg: %%%s%% is not defined", key)
+	}
+	return v, nil
+}
+
+// CacheDir returns the directory where shed should store cached data, such as installed tools.
+func CacheDir() (string, error) {
+	if dir, ok := fromEnv("XDG_CACHE_HOME"); ok {
+		return filepath.Join(dir, appName), nil
+	}
+	switch goos {
+	case "windows":
+		dir, err := windowsDir("LocalAppData")
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, appName, "cache"), nil
+	case "darwin", "ios":
+		home, err := homeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, "Library", "Caches", appName), nil
+	}
+	home, err := homeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cache", appName), nil
+}
+
+// ConfigDir returns the directory containing shed's user config files.
+func ConfigDir() (string, error) {
+	if dir, ok := fromEnv("XDG_CONFIG_HOME"); ok {
+		return filepath.Join(dir, appName), nil
+	}
+	switch goos {
+	case "windows":
+		dir, err := windowsDir("AppData")
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, appName), nil
+	case "darwin", "ios":
+		home, err := homeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, "Library", "Application Support", appName), nil
+	}
+	home, err := homeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", appName), nil
+}
+
+// StateDir returns the directory where shed should store state that should persist
+// between runs but is not important enough to be in the config dir, such as logs or history.
+func StateDir() (string, error) {
+	if dir, ok := fromEnv("XDG_STATE_HOME"); ok {
+		return filepath.Join(dir, appName), nil
+	}
+	switch goos {
+	case "windows":
+		dir, err := windowsDir("LocalAppData")
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, appName, "state"), nil
+	case "darwin", "ios":
+		home, err := homeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, "Library", "Application Support", appName, "state"), nil
+	}
+	home, err := homeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", appName), nil
+}
+
+// BinDir returns the directory where shed should place executables, such as shims for tools,
+// so they can be run directly. Unlike the other directories, this is not specific to shed
+// since the directory needs to be in PATH.
+func BinDir() (string, error) {
+	if dir, ok := fromEnv("XDG_BIN_HOME"); ok {
+		return dir, nil
+	}
+	if goos == "windows" {
+		dir, err := windowsDir("LocalAppData")
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, appName, "bin"), nil
+	}
+	home, err := homeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "bin"), nil
+}
+
+// Dirs contains all the directories used by shed. It is useful for displaying them.
+type Dirs struct {
+	Cache  string
+	Config string
+	State  string
+	Bin    string
+}
+
+// All returns all the directories used by shed.
+func All() (Dirs, error) {
+	var d Dirs
+	var err error
+	if d.Cache, err = CacheDir(); err != nil {
+		return d, err
+	}
+	if d.Config, err = ConfigDir(); err != nil {
+		return d, err
+	}
+	if d.State, err = StateDir(); err != nil {
+		return d, err
+	}
+	if d.Bin, err = BinDir(); err != nil {
+		return d, err
+	}
+	return d, nil
+}
@@ -0,0 +1,129 @@
+package xdg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setenv sets the environment variables for the duration of the test.
+// An empty value unsets the variable.
+func setenv(t *testing.T, env map[string]string) {
+	for k, v := range env {
+		old, had := os.LookupEnv(k)
+		if v == "" {
+			os.Unsetenv(k)
+		} else {
+			os.Setenv(k, v)
+		}
+		k := k
+		t.Cleanup(func() {
+			if had {
+				os.Setenv(k, old)
+			} else {
+				os.Unsetenv(k)
+			}
+		})
+	}
+}
+
+func setGOOS(t *testing.T, value string) {
+	old := goos
+	goos = value
+	t.Cleanup(func() { goos = old })
+}
+
+func TestDirs(t *testing.T) {
+	home := filepath.Join(string(filepath.Separator), "home", "shed")
+	tests := []struct {
+		name string
+		goos string
+		env  map[string]string
+		want Dirs
+	}{
+		{
+			name: "linux defaults",
+			goos: "linux",
+			want: Dirs{
+				Cache:  filepath.Join(home, ".cache", "shed"),
+				Config: filepath.Join(home, ".config", "shed"),
+				State:  filepath.Join(home, ".local", "state", "shed"),
+				Bin:    filepath.Join(home, ".local", "bin"),
+			},
+		},
+		{
+			name: "darwin defaults",
+			goos: "darwin",
+			want: Dirs{
+				Cache:  filepath.Join(home, "Library", "Caches", "shed"),
+				Config: filepath.Join(home, "Library", "Application Support", "shed"),
+				State:  filepath.Join(home, "Library", "Application Support", "shed", "state"),
+				Bin:    filepath.Join(home, ".local", "bin"),
+			},
+		},
+		{
+			name: "windows defaults",
+			goos: "windows",
+			env:  map[string]string{"LocalAppData": filepath.Join(home, "Local"), "AppData": filepath.Join(home, "Roaming")},
+			want: Dirs{
+				Cache:  filepath.Join(home, "Local", "shed", "cache"),
+				Config: filepath.Join(home, "Roaming", "shed"),
+				State:  filepath.Join(home, "Local", "shed", "state"),
+				Bin:    filepath.Join(home, "Local", "shed", "bin"),
+			},
+		},
+		{
+			name: "XDG variables take precedence",
+			goos: "darwin",
+			env: map[string]string{
+				"XDG_CACHE_HOME":  filepath.Join(home, "xdg", "cache"),
+				"XDG_CONFIG_HOME": filepath.Join(home, "xdg", "config"),
+				"XDG_STATE_HOME":  filepath.Join(home, "xdg", "state"),
+				"XDG_BIN_HOME":    filepath.Join(home, "xdg", "bin"),
+			},
+			want: Dirs{
+				Cache:  filepath.Join(home, "xdg", "cache", "shed"),
+				Config: filepath.Join(home, "xdg", "config", "shed"),
+				State:  filepath.Join(home, "xdg", "state", "shed"),
+				Bin:    filepath.Join(home, "xdg", "bin"),
+			},
+		},
+		{
+			name: "relative XDG variables are ignored",
+			goos: "linux",
+			env:  map[string]string{"XDG_CACHE_HOME": "cache", "XDG_CONFIG_HOME": "config"},
+			want: Dirs{
+				Cache:  filepath.Join(home, ".cache", "shed"),
+				Config: filepath.Join(home, ".config", "shed"),
+				State:  filepath.Join(home, ".local", "state", "shed"),
+				Bin:    filepath.Join(home, ".local", "bin"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{
+				"HOME":            home,
+				"USERPROFILE":     home,
+				"XDG_CACHE_HOME":  "",
+				"XDG_CONFIG_HOME": "",
+				"XDG_STATE_HOME":  "",
+				"XDG_BIN_HOME":    "",
+			}
+			for k, v := range tt.env {
+				env[k] = v
+			}
+			setenv(t, env)
+			setGOOS(t, tt.goos)
+
+			got, err := All()
+			if err != nil {
+				t.Fatalf("want nil error, got %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}